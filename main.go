@@ -6,6 +6,7 @@ import (
 	"github.com/acorn-io/cmd"
 	"github.com/gptscript-ai/gptscript/pkg/cli"
 	"github.com/gptscript-ai/gptscript/pkg/daemon"
+	"github.com/gptscript-ai/gptscript/pkg/egress"
 	"github.com/gptscript-ai/gptscript/pkg/mvl"
 
 	// Load all VCS
@@ -21,5 +22,8 @@ func main() {
 		}
 		os.Exit(0)
 	}
+	if len(os.Args) > 1 && os.Args[1] == egress.ForwardCommand {
+		os.Exit(egress.SysForward())
+	}
 	cmd.Main(cli.New())
 }
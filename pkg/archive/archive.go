@@ -0,0 +1,345 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultMaxSize is the maximum total size of the files extracted from an archive if Limits does not set one
+	DefaultMaxSize = 1 << 30
+	// DefaultMaxFiles is the maximum number of files extracted from an archive if Limits does not set one
+	DefaultMaxFiles = 10000
+)
+
+type Format string
+
+const (
+	Zip   Format = "zip"
+	Tar   Format = "tar"
+	TarGz Format = "tar.gz"
+)
+
+// FormatOf returns the format of an archive by the extension of its name: .zip, .tar, .tar.gz or .tgz.
+func FormatOf(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz, nil
+	case strings.HasSuffix(name, ".tar"):
+		return Tar, nil
+	}
+	return "", fmt.Errorf("unsupported archive %s, the name must end in .zip, .tar, .tar.gz or .tgz", name)
+}
+
+// Limits are the limits of the files extracted from an archive, to not fill the disk with an archive that
+// decompresses to much more than its size.
+type Limits struct {
+	MaxSize  int64
+	MaxFiles int
+}
+
+// Result is the files and directories that were extracted or added to an archive, and the entries that were
+// skipped because they are not regular files or directories, like symlinks.
+type Result struct {
+	Files   []string
+	Skipped []string
+}
+
+// Extract extracts the files of an archive to dir. The entries of the archive whose paths are not inside dir, like
+// ../file or /etc/file, fail the extraction, and links and other special files are skipped.
+func Extract(archive, dir string, limits Limits) (result Result, _ error) {
+	format, err := FormatOf(archive)
+	if err != nil {
+		return result, err
+	}
+	if limits.MaxSize <= 0 {
+		limits.MaxSize = DefaultMaxSize
+	}
+	if limits.MaxFiles <= 0 {
+		limits.MaxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return result, err
+	}
+
+	e := &extractor{
+		dir:    dir,
+		limits: limits,
+	}
+	if format == Zip {
+		err = e.zip(archive)
+	} else {
+		err = e.tar(archive, format == TarGz)
+	}
+	return e.result, err
+}
+
+type extractor struct {
+	dir    string
+	limits Limits
+	size   int64
+	files  int
+	result Result
+}
+
+func (e *extractor) zip(archive string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		err := e.entry(f.Name, f.Mode(), func() (io.ReadCloser, error) {
+			return f.Open()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) tar(archive string, gzipped bool) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("invalid archive %s: %w", archive, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid archive %s: %w", archive, err)
+		}
+		err = e.entry(header.Name, header.FileInfo().Mode(), func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (e *extractor) entry(name string, mode fs.FileMode, open func() (io.ReadCloser, error)) error {
+	local := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if local == "" || local == "." {
+		return nil
+	}
+	if !filepath.IsLocal(local) {
+		return fmt.Errorf("the entry %s of the archive is outside of the directory it is extracted to", name)
+	}
+	target := filepath.Join(e.dir, local)
+
+	switch {
+	case mode.IsDir():
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	case mode.IsRegular():
+		if e.files++; e.files > e.limits.MaxFiles {
+			return fmt.Errorf("the archive has more than %d files", e.limits.MaxFiles)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := e.inside(target, name); err != nil {
+			return err
+		}
+		if err := e.file(target, mode.Perm()|0600, open); err != nil {
+			return err
+		}
+	default:
+		e.result.Skipped = append(e.result.Skipped, name)
+		return nil
+	}
+
+	e.result.Files = append(e.result.Files, name)
+	return nil
+}
+
+// inside checks that the file of an entry is not written outside of the directory through a symlink that already
+// exists in the directory.
+func (e *extractor) inside(target, name string) error {
+	dir, err := filepath.EvalSymlinks(e.dir)
+	if err != nil {
+		return err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(dir, parent); err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return fmt.Errorf("the entry %s of the archive is outside of the directory it is extracted to", name)
+	}
+	if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("the entry %s of the archive would be written to a symlink", name)
+	}
+	return nil
+}
+
+func (e *extractor) file(target string, perm fs.FileMode, open func() (io.ReadCloser, error)) error {
+	r, err := open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	// Copy one byte more than the limit allows, to know if the file goes over it
+	n, err := io.CopyN(f, r, e.limits.MaxSize-e.size+1)
+	e.size += n
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	if err := errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	if e.size > e.limits.MaxSize {
+		_ = os.Remove(target)
+		return fmt.Errorf("the files of the archive are larger than %d bytes", e.limits.MaxSize)
+	}
+	return nil
+}
+
+// Create creates an archive of the files and directories in dir, the format is chosen by the extension of the name
+// of the archive. Links and other special files are skipped, and so is the archive if it is in dir.
+func Create(archive, dir string) (result Result, err error) {
+	format, err := FormatOf(archive)
+	if err != nil {
+		return result, err
+	}
+
+	archiveAbs, err := filepath.Abs(archive)
+	if err != nil {
+		return result, err
+	}
+
+	f, err := os.Create(archive)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+	}()
+
+	var (
+		add    func(name string, info fs.FileInfo, path string) error
+		finish func() error
+	)
+	if format == Zip {
+		zw := zip.NewWriter(f)
+		finish = zw.Close
+		add = func(name string, info fs.FileInfo, path string) error {
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if info.IsDir() {
+				header.Name += "/"
+				_, err := zw.CreateHeader(header)
+				return err
+			}
+			header.Method = zip.Deflate
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			return copyFile(w, path)
+		}
+	} else {
+		var w io.Writer = f
+		if format == TarGz {
+			gz := gzip.NewWriter(f)
+			defer func() {
+				err = errors.Join(err, gz.Close())
+			}()
+			w = gz
+		}
+		tw := tar.NewWriter(w)
+		finish = tw.Close
+		add = func(name string, info fs.FileInfo, path string) error {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			return copyFile(tw, path)
+		}
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if abs, err := filepath.Abs(path); err == nil && abs == archiveAbs {
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			result.Skipped = append(result.Skipped, name)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := add(name, info, path); err != nil {
+			return err
+		}
+		result.Files = append(result.Files, name)
+		return nil
+	})
+	return result, errors.Join(err, finish())
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
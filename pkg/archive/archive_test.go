@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatOf(t *testing.T) {
+	for name, want := range map[string]Format{
+		"a.zip":    Zip,
+		"a.TAR.GZ": TarGz,
+		"a.tgz":    TarGz,
+		"a.tar":    Tar,
+	} {
+		format, err := FormatOf(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, format, name)
+	}
+
+	_, err := FormatOf("a.rar")
+	assert.Error(t, err)
+}
+
+func TestCreateExtract(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "docs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "README.md"), []byte("readme"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "docs", "guide.md"), []byte("guide"), 0644))
+	require.NoError(t, os.Symlink("README.md", filepath.Join(src, "link")))
+
+	for _, name := range []string{"out.zip", "out.tar", "out.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), name)
+			result, err := Create(archive, src)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"README.md", "docs", "docs/guide.md"}, result.Files)
+			assert.Equal(t, []string{"link"}, result.Skipped)
+
+			dst := filepath.Join(t.TempDir(), "dst")
+			result, err = Extract(archive, dst, Limits{})
+			require.NoError(t, err)
+			assert.Len(t, result.Files, 3)
+
+			data, err := os.ReadFile(filepath.Join(dst, "docs", "guide.md"))
+			require.NoError(t, err)
+			assert.Equal(t, "guide", string(data))
+
+			_, err = Extract(archive, t.TempDir(), Limits{MaxSize: 8})
+			assert.ErrorContains(t, err, "larger than 8 bytes")
+
+			_, err = Extract(archive, t.TempDir(), Limits{MaxFiles: 1})
+			assert.ErrorContains(t, err, "more than 1 files")
+		})
+	}
+}
+
+func TestExtractTraversal(t *testing.T) {
+	dir := t.TempDir()
+
+	zipFile := filepath.Join(dir, "evil.zip")
+	f, err := os.Create(zipFile)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("../evil.txt")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("evil"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	_, err = Extract(zipFile, filepath.Join(dir, "dst"), Limits{})
+	assert.ErrorContains(t, err, "outside of the directory")
+	assert.NoFileExists(t, filepath.Join(dir, "evil.txt"))
+
+	// A symlink that already exists in the directory must not be followed out of it
+	dst := filepath.Join(dir, "links")
+	require.NoError(t, os.MkdirAll(dst, 0755))
+	require.NoError(t, os.Symlink(dir, filepath.Join(dst, "out")))
+
+	tarFile := filepath.Join(dir, "evil.tar")
+	f, err = os.Create(tarFile)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "out/evil.txt", Mode: 0644, Size: 4, Typeflag: tar.TypeReg}))
+	_, err = tw.Write([]byte("evil"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	_, err = Extract(tarFile, dst, Limits{})
+	assert.ErrorContains(t, err, "outside of the directory")
+	assert.NoFileExists(t, filepath.Join(dir, "evil.txt"))
+}
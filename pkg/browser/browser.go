@@ -0,0 +1,361 @@
+// Package browser loads pages with a headless Chrome or Chromium, which it drives with the DevTools protocol, for
+// the pages that can not be fetched without running their scripts.
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+)
+
+// Output is what Load returns of the page.
+type Output string
+
+const (
+	OutputText       = Output("text")
+	OutputHTML       = Output("html")
+	OutputScreenshot = Output("screenshot")
+)
+
+type Options struct {
+	// Script is JavaScript that is evaluated in the page once it is loaded, the result of it is returned instead of
+	// the output. Promises are awaited.
+	Script string
+	Output Output
+	// Wait is how long to wait after the page is loaded, for the pages that render with scripts
+	Wait time.Duration
+	// Proxy is the proxy that the browser sends the requests of the page through
+	Proxy string
+	// Timeout is how long loading the page may take, 30 seconds if not set
+	Timeout time.Duration
+}
+
+// Find returns the path of the browser: the GPTSCRIPT_BROWSER environment variable, or the first Chrome or
+// Chromium that is installed.
+func Find() (string, error) {
+	if browser := os.Getenv("GPTSCRIPT_BROWSER"); browser != "" {
+		return browser, nil
+	}
+
+	candidates := []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "headless-shell"}
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = append(candidates,
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium")
+	case "windows":
+		for _, dir := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)"), os.Getenv("LocalAppData")} {
+			if dir != "" {
+				candidates = append(candidates, filepath.Join(dir, "Google", "Chrome", "Application", "chrome.exe"))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no Chrome or Chromium browser found, install one or set GPTSCRIPT_BROWSER to its path")
+}
+
+// Load starts a headless browser, loads the URL and returns the output of the page, or the result of the script.
+// Screenshots are returned as PNG data.
+func Load(ctx context.Context, url string, opts Options) ([]byte, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	path, err := Find()
+	if err != nil {
+		return nil, err
+	}
+
+	dataDir, err := os.MkdirTemp("", "gptscript-browser-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dataDir)
+	defer cache.Use(dataDir)()
+
+	args := []string{
+		"--headless=new",
+		"--disable-gpu",
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--window-size=1280,1024",
+		"--remote-debugging-port=0",
+		"--user-data-dir=" + dataDir,
+	}
+	if runtime.GOOS == "linux" && os.Geteuid() == 0 {
+		// Chrome does not start its own sandbox as root
+		args = append(args, "--no-sandbox")
+	}
+	if opts.Proxy != "" {
+		// The loopback addresses are sent through the proxy too, since they are hosts that may not be allowed
+		args = append(args, "--proxy-server="+opts.Proxy, "--proxy-bypass-list=<-loopback>")
+	}
+
+	cmd := exec.CommandContext(ctx, path, append(args, "about:blank")...)
+	cmd.Stdout, cmd.Stderr = io.Discard, io.Discard
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", path, err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	wsURL, err := debuggerURL(ctx, dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return LoadWith(ctx, wsURL, url, opts)
+}
+
+// debuggerURL waits for the browser to write the port of its DevTools endpoint to its data directory.
+func debuggerURL(ctx context.Context, dataDir string) (string, error) {
+	for {
+		data, err := os.ReadFile(filepath.Join(dataDir, "DevToolsActivePort"))
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); err == nil && len(lines) == 2 {
+			return fmt.Sprintf("ws://127.0.0.1:%s%s", strings.TrimSpace(lines[0]), strings.TrimSpace(lines[1])), nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("the browser did not start: %w", ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// LoadWith loads the URL in a new page of the browser whose DevTools endpoint is wsURL.
+func LoadWith(ctx context.Context, wsURL, url string, opts Options) ([]byte, error) {
+	c, err := dial(ctx, wsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := c.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return nil, err
+	}
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := c.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &session); err != nil {
+		return nil, err
+	}
+	s := session.SessionID
+
+	if err := c.call(ctx, s, "Page.enable", nil, nil); err != nil {
+		return nil, err
+	}
+	loaded := c.event("Page.loadEventFired", s)
+	var navigate struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := c.call(ctx, s, "Page.navigate", map[string]any{"url": url}, &navigate); err != nil {
+		return nil, err
+	}
+	if navigate.ErrorText != "" {
+		return nil, fmt.Errorf("failed to load %s: %s", url, navigate.ErrorText)
+	}
+
+	select {
+	case <-loaded:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to load %s: %w", url, ctx.Err())
+	}
+	if opts.Wait > 0 {
+		select {
+		case <-time.After(opts.Wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	switch {
+	case opts.Script != "":
+		return c.evaluate(ctx, s, opts.Script)
+	case opts.Output == OutputScreenshot:
+		var screenshot struct {
+			Data string `json:"data"`
+		}
+		if err := c.call(ctx, s, "Page.captureScreenshot", map[string]any{"format": "png", "captureBeyondViewport": true}, &screenshot); err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(screenshot.Data)
+	case opts.Output == OutputHTML:
+		return c.evaluate(ctx, s, "document.documentElement.outerHTML")
+	default:
+		return c.evaluate(ctx, s, "document.body ? document.body.innerText : ''")
+	}
+}
+
+// client is a client of the DevTools protocol, which sends the commands of the sessions of pages over the
+// connection to the browser.
+type client struct {
+	conn *websocket.Conn
+
+	lock     sync.Mutex
+	nextID   int64
+	pending  map[int64]chan message
+	handlers map[string][]chan struct{}
+	err      error
+	done     chan struct{}
+}
+
+type message struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    any             `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func dial(ctx context.Context, wsURL string) (*client, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the browser: %w", err)
+	}
+	c := &client{
+		conn:     conn,
+		pending:  map[int64]chan message{},
+		handlers: map[string][]chan struct{}{},
+		done:     make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+func (c *client) read() {
+	defer close(c.done)
+	for {
+		var msg message
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			c.lock.Lock()
+			c.err = err
+			c.lock.Unlock()
+			return
+		}
+
+		c.lock.Lock()
+		if msg.ID != 0 {
+			if ch, ok := c.pending[msg.ID]; ok {
+				ch <- msg
+				delete(c.pending, msg.ID)
+			}
+		} else if msg.Method != "" {
+			key := msg.Method + "/" + msg.SessionID
+			for _, ch := range c.handlers[key] {
+				close(ch)
+			}
+			delete(c.handlers, key)
+		}
+		c.lock.Unlock()
+	}
+}
+
+// event returns a channel that is closed when the session receives the event.
+func (c *client) event(method, sessionID string) <-chan struct{} {
+	ch := make(chan struct{})
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := method + "/" + sessionID
+	c.handlers[key] = append(c.handlers[key], ch)
+	return ch
+}
+
+func (c *client) call(ctx context.Context, sessionID, method string, params, result any) error {
+	ch := make(chan message, 1)
+	c.lock.Lock()
+	c.nextID++
+	msg := message{
+		ID:        c.nextID,
+		SessionID: sessionID,
+		Method:    method,
+		Params:    params,
+	}
+	c.pending[msg.ID] = ch
+	err := c.conn.WriteJSON(msg)
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, resp.Error.Message)
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-c.done:
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return fmt.Errorf("the connection to the browser closed: %w", c.err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// evaluate evaluates the expression in the page, and returns its result, strings as they are and other values as
+// JSON.
+func (c *client) evaluate(ctx context.Context, sessionID, expression string) ([]byte, error) {
+	var result struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	err := c.call(ctx, sessionID, "Runtime.evaluate", map[string]any{
+		"expression":    expression,
+		"awaitPromise":  true,
+		"returnByValue": true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if e := result.ExceptionDetails; e != nil {
+		return nil, errors.New("the script failed: " + strings.TrimSpace(e.Text+" "+e.Exception.Description))
+	}
+
+	var s string
+	if err := json.Unmarshal(result.Result.Value, &s); err == nil {
+		return []byte(s), nil
+	}
+	return result.Result.Value, nil
+}
+
+func (c *client) close() {
+	_ = c.conn.Close()
+	<-c.done
+}
@@ -0,0 +1,88 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBrowser serves the part of the DevTools protocol that LoadWith uses, evaluating the expressions to
+// what the results map them to.
+func testBrowser(t *testing.T, results map[string]any) string {
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		for {
+			var msg struct {
+				ID        int64          `json:"id"`
+				SessionID string         `json:"sessionId"`
+				Method    string         `json:"method"`
+				Params    map[string]any `json:"params"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			var result any = map[string]any{}
+			switch msg.Method {
+			case "Target.createTarget":
+				result = map[string]any{"targetId": "target"}
+			case "Target.attachToTarget":
+				result = map[string]any{"sessionId": "session"}
+			case "Page.captureScreenshot":
+				result = map[string]any{"data": base64.StdEncoding.EncodeToString([]byte("PNG"))}
+			case "Runtime.evaluate":
+				value, ok := results[msg.Params["expression"].(string)]
+				if !ok {
+					result = map[string]any{"exceptionDetails": map[string]any{"text": "Uncaught", "exception": map[string]any{"description": "ReferenceError"}}}
+				} else {
+					result = map[string]any{"result": map[string]any{"value": value}}
+				}
+			}
+			require.NoError(t, conn.WriteJSON(map[string]any{"id": msg.ID, "sessionId": msg.SessionID, "result": result}))
+			if msg.Method == "Page.navigate" {
+				_ = conn.WriteJSON(map[string]any{"method": "Page.loadEventFired", "sessionId": "session"})
+			}
+		}
+	}))
+	t.Cleanup(s.Close)
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func TestLoadWith(t *testing.T) {
+	wsURL := testBrowser(t, map[string]any{
+		"document.body ? document.body.innerText : ''": "Rendered text",
+		"document.documentElement.outerHTML":           "<html></html>",
+		"document.title":                               "Title",
+		"[1, 2]":                                       []int{1, 2},
+	})
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{}, "Rendered text"},
+		{Options{Output: OutputHTML}, "<html></html>"},
+		{Options{Output: OutputScreenshot}, "PNG"},
+		{Options{Script: "document.title"}, "Title"},
+		{Options{Script: "[1, 2]"}, "[1,2]"},
+	} {
+		out, err := LoadWith(ctx, wsURL, "https://example.com", test.opts)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, string(out))
+	}
+
+	_, err := LoadWith(ctx, wsURL, "https://example.com", Options{Script: "missing()"})
+	assert.ErrorContains(t, err, "ReferenceError")
+}
@@ -1,7 +1,9 @@
 package builtin
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,26 +13,50 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/BurntSushi/locker"
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/google/shlex"
+	"github.com/gptscript-ai/gptscript/pkg/archive"
+	"github.com/gptscript-ai/gptscript/pkg/browser"
+	"github.com/gptscript-ai/gptscript/pkg/clipboard"
 	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/download"
+	"github.com/gptscript-ai/gptscript/pkg/egress"
+	"github.com/gptscript-ai/gptscript/pkg/filewatch"
+	"github.com/gptscript-ai/gptscript/pkg/fsscope"
+	"github.com/gptscript-ai/gptscript/pkg/notify"
+	"github.com/gptscript-ai/gptscript/pkg/sandbox"
+	"github.com/gptscript-ai/gptscript/pkg/schedule"
+	"github.com/gptscript-ai/gptscript/pkg/secrets"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/vars"
+	"github.com/gptscript-ai/gptscript/pkg/vector"
+	"github.com/itchyny/gojq"
 	"github.com/jaytaylor/html2text"
 )
 
 var tools = map[string]types.Tool{
 	"sys.ls": {
 		Parameters: types.Parameters{
-			Description: "Lists the contents of a directory",
+			Description: "Lists the contents of a directory, and of its subdirectories up to a depth",
 			Arguments: types.ObjectSchema(
-				"dir", "The directory to list"),
+				"dir", "The directory to list",
+				"depth", "(optional) How many levels of subdirectories to list, 1 by default for only the directory, 0 for all of them",
+				"pattern", "(optional) A glob pattern, like *.go, that the names of the files to list match. Subdirectories are listed through even if their names do not match",
+				"details", "(optional) If true, returns a JSON object with the entries and their size and modification time"),
 		},
 		BuiltinFunc: SysLs,
 	},
@@ -100,10 +126,33 @@ var tools = map[string]types.Tool{
 			Arguments: types.ObjectSchema(
 				"url", "The URL to POST to",
 				"content", "The content to POST",
-				"contentType", "The \"content type\" of the content such as application/json or text/plain"),
+				"contentType", "The \"content type\" of the content such as application/json or text/plain",
+				"headers", httpHeadersDescription,
+				"contentFile", httpContentFileDescription),
 		},
 		BuiltinFunc: SysHTTPPost,
 	},
+	"sys.http.put": {
+		Parameters: types.Parameters{
+			Description: "Write contents to a http or https URL using the PUT method, returning the status, headers and body of the response",
+			Arguments:   httpRequestSchema(false),
+		},
+		BuiltinFunc: SysHTTPMethod(http.MethodPut),
+	},
+	"sys.http.patch": {
+		Parameters: types.Parameters{
+			Description: "Write contents to a http or https URL using the PATCH method, returning the status, headers and body of the response",
+			Arguments:   httpRequestSchema(false),
+		},
+		BuiltinFunc: SysHTTPMethod(http.MethodPatch),
+	},
+	"sys.http.request": {
+		Parameters: types.Parameters{
+			Description: "Send a request with any method to a http or https URL, returning the status, headers and body of the response",
+			Arguments:   httpRequestSchema(true),
+		},
+		BuiltinFunc: SysHTTPRequest,
+	},
 	"sys.find": {
 		Parameters: types.Parameters{
 			Description: "Traverse a directory looking for files that match a pattern in the style of the unix find command",
@@ -138,10 +187,23 @@ var tools = map[string]types.Tool{
 			Arguments: types.ObjectSchema(
 				"url", "The URL to download, either http or https.",
 				"location", "(optional) The on disk location to store the file. If no location is specified a temp location will be used. If the target file already exists it will fail unless override is set to true.",
-				"override", "If true and a file at the location exists, the file will be overwritten, otherwise fail. Default is false"),
+				"override", "If true and a file at the location exists, the file will be overwritten, otherwise fail. Default is false",
+				"sha256", "(optional) The expected SHA256 of the file, in hex. The download fails if the file has another SHA256"),
 		},
 		BuiltinFunc: SysDownload,
 	},
+	"sys.browser": {
+		Parameters: types.Parameters{
+			Description: "Loads a URL in a headless browser that runs the scripts of the page, for pages that can not be downloaded, returning the rendered text or HTML of the page, the result of a script, or saving a screenshot of it",
+			Arguments: types.ObjectSchema(
+				"url", "The URL to load, either http or https",
+				"output", "What to return of the page: text, the default, html, or screenshot to save a PNG screenshot of it to file",
+				"script", "(optional) JavaScript to run in the page once it is loaded, its result is returned instead of the output",
+				"file", "The file to save the screenshot to",
+				"wait", "(optional) The number of seconds to wait after the page is loaded, for the pages that keep rendering"),
+		},
+		BuiltinFunc: SysBrowser,
+	},
 	"sys.remove": {
 		Parameters: types.Parameters{
 			Description: "Removes the specified files",
@@ -159,6 +221,167 @@ var tools = map[string]types.Tool{
 		},
 		BuiltinFunc: SysStat,
 	},
+	"sys.json.query": {
+		Parameters: types.Parameters{
+			Description: "Runs a jq expression on a JSON document, like .items[] | select(.status == \"open\") | .title, returning each value it outputs as JSON. Use it to extract fields from large JSON documents",
+			Arguments: types.ObjectSchema(
+				"expression", "The jq expression",
+				"json", "The JSON document, if filename is not set",
+				"filename", "The file of the JSON document",
+				"raw", "(true or false) Whether strings are returned without quotes, false by default"),
+		},
+		BuiltinFunc: SysJSONQuery,
+	},
+	"sys.csv.read": {
+		Parameters: types.Parameters{
+			Description: "Reads the rows of a CSV or TSV file as a JSON array, of objects by the names of the columns in the header row, or of arrays if the file has no header",
+			Arguments: types.ObjectSchema(
+				"filename", "The file to read",
+				"delimiter", "(optional) The delimiter of the fields, like ; or tab, a tab for .tsv files and a comma for other files by default",
+				"header", "(true or false) Whether the first row is the names of the columns, true by default",
+				"columns", "(optional) The comma-separated columns to return, by name, or by number starting from 1 if the file has no header, all columns by default",
+				"start", "(optional) The first row to return, starting from 1 after the header, 1 by default",
+				"count", "(optional) The number of rows to return, all rows by default"),
+		},
+		BuiltinFunc: SysCSVRead,
+	},
+	"sys.csv.write": {
+		Parameters: types.Parameters{
+			Description: "Writes a JSON array of rows to a CSV or TSV file. Rows that are objects are written with a header row of the names of their keys, rows that are arrays are written as they are",
+			Arguments: types.ObjectSchema(
+				"filename", "The file to write",
+				"rows", "The JSON array of rows, of objects or of arrays",
+				"columns", "(optional) The comma-separated keys of the objects to write, in the order of the columns, the keys of the rows in the order they first appear by default",
+				"delimiter", "(optional) The delimiter of the fields, like ; or tab, a tab for .tsv files and a comma for other files by default",
+				"append", "(true or false) Whether the rows are appended to the file, without a header row if the file is not empty, false by default"),
+		},
+		BuiltinFunc: SysCSVWrite,
+	},
+	"sys.archive.extract": {
+		Parameters: types.Parameters{
+			Description: "Extracts the files of a .zip, .tar, .tar.gz or .tgz archive to a directory. Entries with paths outside of the directory fail the extraction, and links are skipped",
+			Arguments: types.ObjectSchema(
+				"archive", "The archive file",
+				"directory", "(optional) The directory to extract the files to, the current directory by default",
+				"max_size", "(optional) The maximum total size of the extracted files in MB, 1024 by default",
+				"max_files", "(optional) The maximum number of extracted files, 10000 by default"),
+		},
+		BuiltinFunc: SysArchiveExtract,
+	},
+	"sys.archive.create": {
+		Parameters: types.Parameters{
+			Description: "Creates a .zip, .tar, .tar.gz or .tgz archive of the files in a directory, the format is chosen by the extension of the archive file",
+			Arguments: types.ObjectSchema(
+				"archive", "The archive file to create",
+				"directory", "(optional) The directory of the files to archive, the current directory by default"),
+		},
+		BuiltinFunc: SysArchiveCreate,
+	},
+	"sys.template": {
+		Parameters: types.Parameters{
+			Description: "Renders a Go text/template with a JSON document as its data, like Hello {{.name}}, returning the rendered text or writing it to a file. Use it to produce configuration files, emails and reports",
+			Arguments: types.ObjectSchema(
+				"template", "The template, if filename is not set",
+				"filename", "The file of the template",
+				"data", "(optional) The JSON document that the template is rendered with",
+				"output", "(optional) The file to write the rendered text to, it is returned if not set"),
+		},
+		BuiltinFunc: SysTemplate,
+	},
+	"sys.clipboard.read": {
+		Parameters: types.Parameters{
+			Description: "Returns the text on the clipboard of the user, after the user confirms it can be read",
+			Arguments:   types.ObjectSchema(),
+		},
+		BuiltinFunc: SysClipboardRead,
+	},
+	"sys.clipboard.write": {
+		Parameters: types.Parameters{
+			Description: "Puts text on the clipboard of the user",
+			Arguments: types.ObjectSchema(
+				"content", "The text to put on the clipboard"),
+		},
+		BuiltinFunc: SysClipboardWrite,
+	},
+	"sys.notify": {
+		Parameters: types.Parameters{
+			Description: "Notifies the user, with a desktop notification or the webhook they configured, that the script finished or needs their attention",
+			Arguments: types.ObjectSchema(
+				"title", "(optional) The title of the notification",
+				"message", "The message of the notification"),
+		},
+		BuiltinFunc: SysNotify,
+	},
+	"sys.file.watch": {
+		Parameters: types.Parameters{
+			Description: "Waits until a file, or a file in a directory, is created, modified or removed, and returns the changes. Use it to wait for the files that other processes produce",
+			Arguments: types.ObjectSchema(
+				"path", "The file or directory to watch, the files in its subdirectories are watched too",
+				"pattern", "(optional) The pattern of the names of the files to watch, like *.pdf, all files by default",
+				"timeout", "(optional) How long to wait for a change, like 30s or 10m, 5m by default"),
+		},
+		BuiltinFunc: SysFileWatch,
+	},
+	"sys.schedule": {
+		Parameters: types.Parameters{
+			Description: "Schedules a future run of a tool by the GPTScript server, once at a time or repeatedly on a cron schedule",
+			Arguments: types.ObjectSchema(
+				"program", "The .gpt file of the program to run",
+				"tool", "(optional) The name of the tool of the program to run, the first tool by default",
+				"input", "(optional) The input of the run",
+				"cron", "(optional) A cron expression like \"0 9 * * 1-5\", @daily or \"@every 2h\" to run the tool repeatedly",
+				"at", "(optional) The time of a single run, as an RFC 3339 time like 2024-06-01T09:00:00Z or a duration from now like 30m"),
+		},
+		BuiltinFunc: SysSchedule,
+	},
+	"sys.secrets": {
+		Parameters: types.Parameters{
+			Description: "Gets, sets, deletes or lists the named secrets of the user, like API keys, saved in the credential store. The values of the secrets are masked in the output and the chat history",
+			Arguments: types.ObjectSchema(
+				"action", "One of get, set, delete or list",
+				"name", "The name of the secret, not needed to list them",
+				"value", "The value of the secret to set"),
+		},
+		BuiltinFunc: SysSecrets,
+	},
+	"sys.var.get": {
+		Parameters: types.Parameters{
+			Description: "Gets the value of a variable of the run, like a parameter that the user set with --var, or all the variables as a JSON object if no name is given",
+			Arguments: types.ObjectSchema(
+				"name", "(optional) The name of the variable"),
+		},
+		BuiltinFunc: SysVarGet,
+	},
+	"sys.var.set": {
+		Parameters: types.Parameters{
+			Description: "Sets a variable of the run, that the tools called after it get, and command tools get as the GPTSCRIPT_VAR_<name> environment variable",
+			Arguments: types.ObjectSchema(
+				"name", "The name of the variable, with letters, digits and _",
+				"value", "The value of the variable"),
+		},
+		BuiltinFunc: SysVarSet,
+	},
+	"sys.vector.index": {
+		Parameters: types.Parameters{
+			Description: "Indexes the text files in a directory for semantic search with sys.vector.search, by embedding their contents. Files that did not change since they were indexed are skipped",
+			Arguments: types.ObjectSchema(
+				"index", "The file to save the index to, it is updated if it exists",
+				"directory", "(optional) The directory of the files to index, the current directory by default",
+				"pattern", "(optional) The pattern of the names of the files to index, like *.md, all files by default",
+				"model", "(optional) The embeddings model, the model of the index or text-embedding-3-small by default"),
+		},
+		BuiltinFunc: SysVectorIndex,
+	},
+	"sys.vector.search": {
+		Parameters: types.Parameters{
+			Description: "Searches an index created with sys.vector.index for the parts of the files that are the most relevant to a query, returning their paths, lines and contents",
+			Arguments: types.ObjectSchema(
+				"index", "The file of the index",
+				"query", "The text to search for",
+				"count", "(optional) The number of results to return, 5 by default"),
+		},
+		BuiltinFunc: SysVectorSearch,
+	},
 	"sys.prompt": {
 		Parameters: types.Parameters{
 			Description: "Prompts the user for input",
@@ -229,6 +452,9 @@ func SysFind(ctx context.Context, env []string, input string) (string, error) {
 	if params.Directory == "" {
 		params.Directory = "."
 	}
+	if err := fsscope.Check(ctx, params.Directory); err != nil {
+		return "", err
+	}
 
 	log.Debugf("Finding files %s in %s", params.Pattern, params.Directory)
 	err := fs.WalkDir(os.DirFS(params.Directory), ".", func(pathname string, d fs.DirEntry, err error) error {
@@ -257,6 +483,17 @@ func SysFind(ctx context.Context, env []string, input string) (string, error) {
 	return strings.Join(result, "\n"), nil
 }
 
+// confirmCall asks to confirm the call with the prompt for its parameters. When the user corrects the arguments of the
+// call, they are decoded into the parameters, so the call runs with them, and it returns true.
+func confirmCall(ctx context.Context, input string, params any, prompt func() string) (bool, error) {
+	arguments, err := confirm.Editf(ctx, input, "%s", prompt())
+	if err != nil || arguments == input {
+		return false, err
+	}
+	reflect.ValueOf(params).Elem().SetZero()
+	return true, json.Unmarshal([]byte(arguments), params)
+}
+
 func SysExec(ctx context.Context, env []string, input string) (string, error) {
 	var params struct {
 		Command   string `json:"command,omitempty"`
@@ -266,16 +503,17 @@ func SysExec(ctx context.Context, env []string, input string) (string, error) {
 		return "", err
 	}
 
+	if _, err := confirmCall(ctx, input, &params, func() string {
+		return "Run command: " + params.Command
+	}); err != nil {
+		return "", err
+	}
 	if params.Directory == "" {
 		params.Directory = "."
 	}
 
 	log.Debugf("Running %s in %s", params.Command, params.Directory)
 
-	if err := confirm.Promptf(ctx, "Run command: %s", params.Command); err != nil {
-		return "", err
-	}
-
 	var cmd *exec.Cmd
 
 	if runtime.GOOS == "windows" {
@@ -290,6 +528,10 @@ func SysExec(ctx context.Context, env []string, input string) (string, error) {
 
 	cmd.Env = env
 	cmd.Dir = params.Directory
+	cmd, err := sandbox.Wrap(ctx, cmd)
+	if err != nil {
+		return "", err
+	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		_, _ = os.Stdout.Write(out)
@@ -297,9 +539,22 @@ func SysExec(ctx context.Context, env []string, input string) (string, error) {
 	return string(out), err
 }
 
-func SysLs(_ context.Context, _ []string, input string) (string, error) {
+// maxLsEntries is the number of entries that sys.ls lists at most, so listing a large tree does not flood the context
+const maxLsEntries = 1000
+
+type lsEntry struct {
+	Path     string    `json:"path"`
+	Dir      bool      `json:"dir,omitempty"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+func SysLs(ctx context.Context, _ []string, input string) (string, error) {
 	var params struct {
-		Dir string `json:"dir,omitempty"`
+		Dir     string `json:"dir,omitempty"`
+		Depth   string `json:"depth,omitempty"`
+		Pattern string `json:"pattern,omitempty"`
+		Details string `json:"details,omitempty"`
 	}
 	if err := json.Unmarshal([]byte(input), &params); err != nil {
 		return "", err
@@ -308,24 +563,113 @@ func SysLs(_ context.Context, _ []string, input string) (string, error) {
 	if params.Dir == "" {
 		params.Dir = "."
 	}
+	if err := fsscope.Check(ctx, params.Dir); err != nil {
+		return "", err
+	}
 
-	entries, err := os.ReadDir(params.Dir)
-	if errors.Is(err, fs.ErrNotExist) {
+	depth := 1
+	if params.Depth != "" {
+		var err error
+		if depth, err = strconv.Atoi(params.Depth); err != nil || depth < 0 {
+			return "", fmt.Errorf("invalid depth %q, it must be a number of levels", params.Depth)
+		}
+	}
+	if _, err := filepath.Match(params.Pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid pattern %q: %w", params.Pattern, err)
+	}
+
+	if s, err := os.Stat(params.Dir); errors.Is(err, fs.ErrNotExist) {
 		return fmt.Sprintf("directory does not exist: %s", params.Dir), nil
 	} else if err != nil {
 		return "", err
+	} else if !s.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", params.Dir)
 	}
 
-	var result []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			result = append(result, entry.Name()+"/")
-		} else {
-			result = append(result, entry.Name())
+	var (
+		entries   []lsEntry
+		truncated bool
+	)
+	err := fs.WalkDir(os.DirFS(params.Dir), ".", func(pathname string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if pathname == "." {
+			return nil
+		}
+		if len(entries) >= maxLsEntries {
+			truncated = true
+			return fs.SkipAll
+		}
+
+		level := strings.Count(pathname, "/") + 1
+		if params.Pattern == "" || d.IsDir() {
+			entries = append(entries, lsEntry{Path: pathname, Dir: d.IsDir()})
+		} else if ok, _ := filepath.Match(params.Pattern, d.Name()); ok {
+			entries = append(entries, lsEntry{Path: pathname})
+		}
+		if d.IsDir() && depth != 0 && level >= depth {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if params.Pattern != "" {
+		entries = withoutEmptyDirs(entries)
+	}
+
+	if params.Details != "true" {
+		result := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Dir {
+				result = append(result, entry.Path+"/")
+			} else {
+				result = append(result, entry.Path)
+			}
+		}
+		if truncated {
+			result = append(result, fmt.Sprintf("... only the first %d entries are listed", maxLsEntries))
 		}
+		return strings.Join(result, "\n"), nil
 	}
 
-	return strings.Join(result, "\n"), nil
+	for i, entry := range entries {
+		info, err := os.Stat(filepath.Join(params.Dir, entry.Path))
+		if err != nil {
+			continue
+		}
+		if !entry.Dir {
+			entries[i].Size = info.Size()
+		}
+		entries[i].Modified = info.ModTime().UTC().Truncate(time.Second)
+	}
+	data, err := json.Marshal(map[string]any{
+		"entries":   entries,
+		"truncated": truncated,
+	})
+	return string(data), err
+}
+
+// withoutEmptyDirs removes the directories that have no entries under them from a listing that was filtered by a
+// pattern, so only the directories of the files that match are listed.
+func withoutEmptyDirs(entries []lsEntry) (result []lsEntry) {
+	used := map[string]bool{}
+	for _, entry := range entries {
+		if !entry.Dir {
+			for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+				used[dir] = true
+			}
+		}
+	}
+	for _, entry := range entries {
+		if !entry.Dir || used[entry.Path] {
+			result = append(result, entry)
+		}
+	}
+	return result
 }
 
 func SysRead(ctx context.Context, env []string, input string) (string, error) {
@@ -336,6 +680,10 @@ func SysRead(ctx context.Context, env []string, input string) (string, error) {
 		return "", err
 	}
 
+	if err := fsscope.Check(ctx, params.Filename); err != nil {
+		return "", err
+	}
+
 	// Lock the file to prevent concurrent writes from other tool calls.
 	locker.RLock(params.Filename)
 	defer locker.RUnlock(params.Filename)
@@ -363,6 +711,10 @@ func SysWrite(ctx context.Context, env []string, input string) (string, error) {
 		return "", err
 	}
 
+	if err := fsscope.Check(ctx, params.Filename); err != nil {
+		return "", err
+	}
+
 	// Lock the file to prevent concurrent writes from other tool calls.
 	locker.Lock(params.Filename)
 	defer locker.Unlock(params.Filename)
@@ -396,6 +748,10 @@ func SysAppend(ctx context.Context, env []string, input string) (string, error)
 		return "", err
 	}
 
+	if err := fsscope.Check(ctx, params.Filename); err != nil {
+		return "", err
+	}
+
 	// Lock the file to prevent concurrent writes from other tool calls.
 	locker.Lock(params.Filename)
 	defer locker.Unlock(params.Filename)
@@ -453,7 +809,7 @@ func SysHTTPGet(ctx context.Context, env []string, input string) (_ string, err
 		return "", err
 	}
 
-	c := http.Client{Timeout: 10 * time.Second}
+	c := http.Client{Timeout: 10 * time.Second, Transport: egress.Transport(ctx, nil)}
 
 	log.Debugf("http get %s", params.URL)
 	resp, err := c.Get(params.URL)
@@ -484,42 +840,247 @@ func SysHTTPHtml2Text(ctx context.Context, env []string, input string) (string,
 }
 
 func SysHTTPPost(ctx context.Context, env []string, input string) (_ string, err error) {
-	var params struct {
-		URL         string `json:"url,omitempty"`
-		Content     string `json:"content,omitempty"`
-		ContentType string `json:"contentType,omitempty"`
-	}
+	var params httpRequestParams
 	if err := json.Unmarshal([]byte(input), &params); err != nil {
 		return "", err
 	}
+	params.Method = http.MethodPost
 
-	params.URL, err = fixQueries(params.URL)
+	req, err := newHTTPRequest(ctx, params)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, params.URL, strings.NewReader(params.Content))
+	c := http.Client{Timeout: 10 * time.Second, Transport: egress.Transport(ctx, nil)}
+
+	resp, err := c.Do(req)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
+
+	_, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode > 399 {
+		return "", fmt.Errorf("failed to post %s: %s", req.URL, resp.Status)
+	}
+
+	return fmt.Sprintf("Wrote %d to %s", req.ContentLength, req.URL), nil
+}
+
+const (
+	httpHeadersDescription     = "The headers of the request, a JSON object like {\"Authorization\": \"Bearer ...\"} or a header like \"Accept: application/json\" per line"
+	httpContentFileDescription = "The file to send as the content, instead of content"
+)
+
+func httpRequestSchema(method bool) *openapi3.Schema {
+	kv := []string{
+		"url", "The URL to send the request to",
+		"content", "The content of the request",
+		"contentType", "The \"content type\" of the content such as application/json or text/plain",
+		"headers", httpHeadersDescription,
+		"contentFile", httpContentFileDescription,
+	}
+	if method {
+		kv = append([]string{"method", "The method of the request, like GET, POST, PUT, PATCH or DELETE"}, kv...)
+	}
+	return types.ObjectSchema(kv...)
+}
+
+type httpRequestParams struct {
+	Method      string `json:"method,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Content     string `json:"content,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Headers     string `json:"headers,omitempty"`
+	ContentFile string `json:"contentFile,omitempty"`
+}
+
+// newHTTPRequest returns the request of the params, with the content read from their content file if they have one.
+func newHTTPRequest(ctx context.Context, params httpRequestParams) (_ *http.Request, err error) {
+	params.URL, err = fixQueries(params.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte(params.Content)
+	if params.ContentFile != "" {
+		if err := fsscope.Check(ctx, params.ContentFile); err != nil {
+			return nil, err
+		}
+		if body, err = os.ReadFile(params.ContentFile); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(types.FirstSet(params.Method, http.MethodGet)), params.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := parseHTTPHeaders(params.Headers)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 	if params.ContentType != "" {
 		req.Header.Set("Content-Type", params.ContentType)
 	}
+	return req, nil
+}
+
+// parseHTTPHeaders parses a JSON object of headers, or a header per line.
+func parseHTTPHeaders(s string) (map[string]string, error) {
+	result := map[string]string{}
+	if s = strings.TrimSpace(s); s == "" {
+		return result, nil
+	}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &result); err != nil {
+			return nil, fmt.Errorf("invalid headers, they must be a JSON object of strings: %w", err)
+		}
+		return result, nil
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q, it must be like Name: value", line)
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result, nil
+}
+
+// SysHTTPMethod returns the builtin that sends requests with the method.
+func SysHTTPMethod(method string) types.BuiltinFunc {
+	return func(ctx context.Context, env []string, input string) (string, error) {
+		var params httpRequestParams
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return "", err
+		}
+		params.Method = method
+		return sendHTTPRequest(ctx, params)
+	}
+}
+
+func SysHTTPRequest(ctx context.Context, env []string, input string) (string, error) {
+	var params httpRequestParams
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+	return sendHTTPRequest(ctx, params)
+}
+
+// sendHTTPRequest sends the request and returns the response like it is sent, its status and headers and then its
+// body. Responses with an error status are not errors, so the caller can see what the API responded with.
+func sendHTTPRequest(ctx context.Context, params httpRequestParams) (string, error) {
+	req, err := newHTTPRequest(ctx, params)
+	if err != nil {
+		return "", err
+	}
 
-	c := http.Client{Timeout: 10 * time.Second}
+	c := http.Client{Timeout: 10 * time.Second, Transport: egress.Transport(ctx, nil)}
 
+	log.Debugf("http %s %s", req.Method, req.URL)
 	resp, err := c.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	_, _ = io.ReadAll(resp.Body)
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("failed to post %s: %s", params.URL, resp.Status)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	buf := &strings.Builder{}
+	_, _ = fmt.Fprintf(buf, "%s %s\n", resp.Proto, resp.Status)
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range resp.Header[k] {
+			_, _ = fmt.Fprintf(buf, "%s: %s\n", k, v)
+		}
+	}
+	buf.WriteString("\n")
+	buf.Write(data)
+	return buf.String(), nil
+}
+
+func SysBrowser(ctx context.Context, env []string, input string) (_ string, err error) {
+	var params struct {
+		URL    string `json:"url,omitempty"`
+		Output string `json:"output,omitempty"`
+		Script string `json:"script,omitempty"`
+		File   string `json:"file,omitempty"`
+		Wait   string `json:"wait,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid URL %q, it must be a http or https URL", params.URL)
+	}
+
+	opts := browser.Options{
+		Script: params.Script,
+		Output: browser.Output(strings.ToLower(types.FirstSet(params.Output, string(browser.OutputText)))),
+	}
+	switch opts.Output {
+	case browser.OutputText, browser.OutputHTML:
+	case browser.OutputScreenshot:
+		if params.File == "" {
+			return "", fmt.Errorf("the file to save the screenshot to is required")
+		}
+		if err := fsscope.Check(ctx, params.File); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("invalid output %q, it must be text, html or screenshot", params.Output)
+	}
+	if params.Wait != "" {
+		seconds, err := strconv.ParseFloat(params.Wait, 64)
+		if err != nil || seconds < 0 {
+			return "", fmt.Errorf("invalid wait %q, it must be a number of seconds", params.Wait)
+		}
+		opts.Wait = time.Duration(seconds * float64(time.Second))
+	}
+
+	if egress.Restricted(ctx) {
+		if err := egress.Check(ctx, u.Host); err != nil {
+			return "", err
+		}
+		// The requests of the page, to any host, go through a proxy that only forwards them to the allowed hosts
+		proxy, err := egress.NewProxy(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer proxy.Close()
+		opts.Proxy = proxy.URL()
+	}
+
+	log.Debugf("Loading %s in a browser", params.URL)
+	data, err := browser.Load(ctx, params.URL, opts)
+	if err != nil {
+		return "", err
 	}
 
-	return fmt.Sprintf("Wrote %d to %s", len([]byte(params.Content)), params.URL), nil
+	if opts.Output == browser.OutputScreenshot && opts.Script == "" {
+		if err := os.WriteFile(params.File, data, 0644); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Saved a screenshot of %s to %s", params.URL, params.File), nil
+	}
+	return string(data), nil
 }
 
 func SysGetenv(ctx context.Context, env []string, input string) (string, error) {
@@ -571,8 +1132,18 @@ func SysRemove(ctx context.Context, env []string, input string) (string, error)
 		return "", err
 	}
 
-	if err := confirm.Promptf(ctx, "Remove: %s", params.Location); err != nil {
+	if err := fsscope.Check(ctx, params.Location); err != nil {
+		return "", err
+	}
+
+	if edited, err := confirmCall(ctx, input, &params, func() string {
+		return "Remove: " + params.Location
+	}); err != nil {
 		return "", err
+	} else if edited {
+		if err := fsscope.Check(ctx, params.Location); err != nil {
+			return "", err
+		}
 	}
 
 	// Lock the file to prevent concurrent writes from other tool calls.
@@ -590,6 +1161,10 @@ func SysStat(ctx context.Context, env []string, input string) (string, error) {
 		return "", err
 	}
 
+	if err := fsscope.Check(ctx, params.Filepath); err != nil {
+		return "", err
+	}
+
 	stat, err := os.Stat(params.Filepath)
 	if err != nil {
 		return "", err
@@ -602,11 +1177,981 @@ func SysStat(ctx context.Context, env []string, input string) (string, error) {
 	return fmt.Sprintf("%s %s mode: %s, size: %d bytes, modtime: %s", title, params.Filepath, stat.Mode().String(), stat.Size(), stat.ModTime().String()), nil
 }
 
-func SysDownload(ctx context.Context, env []string, input string) (_ string, err error) {
+func SysJSONQuery(ctx context.Context, env []string, input string) (string, error) {
 	var params struct {
-		URL      string `json:"url,omitempty"`
+		Expression string `json:"expression,omitempty"`
+		JSON       string `json:"json,omitempty"`
+		Filename   string `json:"filename,omitempty"`
+		Raw        string `json:"raw,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	expression := types.FirstSet(params.Expression, ".")
+	parsed, err := gojq.Parse(expression)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	// env and $ENV are the environment of the run, not of gptscript
+	query, err := gojq.Compile(parsed, gojq.WithEnvironLoader(func() []string {
+		return env
+	}))
+	if err != nil {
+		return "", fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+
+	document := []byte(params.JSON)
+	if params.Filename != "" {
+		if err := fsscope.Check(ctx, params.Filename); err != nil {
+			return "", err
+		}
+
+		locker.RLock(params.Filename)
+		document, err = os.ReadFile(params.Filename)
+		locker.RUnlock(params.Filename)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var data any
+	if err := json.Unmarshal(document, &data); err != nil {
+		return "", fmt.Errorf("invalid JSON document: %w", err)
+	}
+
+	log.Debugf("Querying JSON document with %s", expression)
+	var (
+		buf     bytes.Buffer
+		encoder = json.NewEncoder(&buf)
+		values  = query.RunWithContext(ctx, data)
+		found   bool
+	)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	for {
+		value, ok := values.Next()
+		if !ok {
+			break
+		}
+		if err, ok := value.(error); ok {
+			var halt *gojq.HaltError
+			if errors.As(err, &halt) && halt.Value() == nil {
+				break
+			}
+			return "", fmt.Errorf("failed to run %q: %w", expression, err)
+		}
+
+		found = true
+		if s, ok := value.(string); ok && params.Raw == "true" {
+			buf.WriteString(s + "\n")
+		} else if err := encoder.Encode(value); err != nil {
+			return "", err
+		}
+	}
+	if !found {
+		return "No values", nil
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func SysCSVRead(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Filename  string `json:"filename,omitempty"`
+		Delimiter string `json:"delimiter,omitempty"`
+		Header    string `json:"header,omitempty"`
+		Columns   string `json:"columns,omitempty"`
+		Start     string `json:"start,omitempty"`
+		Count     string `json:"count,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	delimiter, err := csvDelimiter(params.Filename, params.Delimiter)
+	if err != nil {
+		return "", err
+	}
+	start, count := 1, -1
+	if params.Start != "" {
+		if start, err = strconv.Atoi(params.Start); err != nil || start < 1 {
+			return "", fmt.Errorf("invalid start %q, it must be a row number starting from 1", params.Start)
+		}
+	}
+	if params.Count != "" {
+		if count, err = strconv.Atoi(params.Count); err != nil || count < 0 {
+			return "", fmt.Errorf("invalid count %q", params.Count)
+		}
+	}
+
+	if err := fsscope.Check(ctx, params.Filename); err != nil {
+		return "", err
+	}
+
+	locker.RLock(params.Filename)
+	data, err := os.ReadFile(params.Filename)
+	locker.RUnlock(params.Filename)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("Reading CSV file %s", params.Filename)
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("invalid CSV file %s: %w", params.Filename, err)
+	}
+
+	var header []string
+	if params.Header != "false" && len(records) > 0 {
+		header, records = records[0], records[1:]
+	}
+
+	// columns are the indexes of the fields to return
+	var columns []int
+	if params.Columns == "" {
+		for i := range header {
+			columns = append(columns, i)
+		}
+	}
+	for _, column := range strings.Split(params.Columns, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		index := -1
+		if header == nil {
+			if n, err := strconv.Atoi(column); err == nil && n > 0 {
+				index = n - 1
+			}
+		} else {
+			index = slices.Index(header, column)
+		}
+		if index < 0 {
+			return "", fmt.Errorf("column %s is not in the file %s", column, params.Filename)
+		}
+		columns = append(columns, index)
+	}
+
+	records = records[min(start-1, len(records)):]
+	if count >= 0 && count < len(records) {
+		records = records[:count]
+	}
+
+	rows := make([]any, 0, len(records))
+	for _, record := range records {
+		field := func(i int) string {
+			if i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		switch {
+		case header != nil:
+			var row csvRow
+			for _, i := range columns {
+				row.keys = append(row.keys, header[i])
+				row.values = append(row.values, field(i))
+			}
+			rows = append(rows, row)
+		case columns != nil:
+			row := make([]string, 0, len(columns))
+			for _, i := range columns {
+				row = append(row, field(i))
+			}
+			rows = append(rows, row)
+		default:
+			rows = append(rows, record)
+		}
+	}
+
+	out, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func SysCSVWrite(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Filename  string `json:"filename,omitempty"`
+		Rows      string `json:"rows,omitempty"`
+		Columns   string `json:"columns,omitempty"`
+		Delimiter string `json:"delimiter,omitempty"`
+		Append    string `json:"append,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	delimiter, err := csvDelimiter(params.Filename, params.Delimiter)
+	if err != nil {
+		return "", err
+	}
+
+	var rows []json.RawMessage
+	if err := json.Unmarshal([]byte(params.Rows), &rows); err != nil {
+		return "", fmt.Errorf("invalid rows, they must be a JSON array: %w", err)
+	}
+
+	var columns []string
+	for _, column := range strings.Split(params.Columns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+
+	var (
+		records [][]string
+		objects bool
+	)
+	for i, row := range rows {
+		row = bytes.TrimSpace(row)
+		if len(row) > 0 && row[0] == '{' {
+			objects = true
+			keys, err := jsonKeys(row)
+			if err != nil {
+				return "", fmt.Errorf("invalid row %d: %w", i+1, err)
+			}
+			if params.Columns == "" {
+				// The columns are the keys in the order they first appear, which a map would not keep
+				for _, key := range keys {
+					if !slices.Contains(columns, key) {
+						columns = append(columns, key)
+					}
+				}
+			}
+			continue
+		}
+		if len(row) == 0 || row[0] != '[' {
+			return "", fmt.Errorf("invalid row %d, it must be an object or an array", i+1)
+		}
+	}
+
+	for i, row := range rows {
+		var record []string
+		if objects {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(row, &fields); err != nil {
+				return "", fmt.Errorf("invalid row %d, it must be an object like the other rows: %w", i+1, err)
+			}
+			for _, column := range columns {
+				record = append(record, csvField(fields[column]))
+			}
+		} else {
+			var fields []json.RawMessage
+			if err := json.Unmarshal(row, &fields); err != nil {
+				return "", fmt.Errorf("invalid row %d: %w", i+1, err)
+			}
+			for _, field := range fields {
+				record = append(record, csvField(field))
+			}
+		}
+		records = append(records, record)
+	}
+
+	if err := fsscope.Check(ctx, params.Filename); err != nil {
+		return "", err
+	}
+
+	// Lock the file to prevent concurrent writes from other tool calls.
+	locker.Lock(params.Filename)
+	defer locker.Unlock(params.Filename)
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	stat, err := os.Stat(params.Filename)
+	exists := err == nil
+	if params.Append == "true" {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		if exists && stat.Size() > 0 {
+			objects = false
+		}
+	} else if exists {
+		if err := confirm.Promptf(ctx, "Overwrite: %s", params.Filename); err != nil {
+			return "", err
+		}
+	}
+	if objects {
+		records = append([][]string{columns}, records...)
+	}
+
+	if dir := filepath.Dir(params.Filename); !exists {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("creating dir %s: %w", dir, err)
+		}
+	}
+
+	f, err := os.OpenFile(params.Filename, flags, 0644)
+	if err != nil {
+		return "", err
+	}
+	writer := csv.NewWriter(f)
+	writer.Comma = delimiter
+	err = writer.WriteAll(records)
+	if err := errors.Join(err, f.Close()); err != nil {
+		return "", err
+	}
+
+	log.Debugf("Wrote %d rows to CSV file %s", len(rows), params.Filename)
+	return fmt.Sprintf("Wrote %d rows to %s", len(rows), params.Filename), nil
+}
+
+// csvRow is a row of a CSV file with a header, that is marshaled as a JSON object with its fields in the order of
+// the columns.
+type csvRow struct {
+	keys, values []string
+}
+
+func (r csvRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// csvDelimiter returns the delimiter of the fields of a CSV file, a tab for .tsv files and a comma for other files
+// if it is not set.
+func csvDelimiter(filename, delimiter string) (rune, error) {
+	switch delimiter {
+	case "":
+		if ext := strings.ToLower(filepath.Ext(filename)); ext == ".tsv" || ext == ".tab" {
+			return '\t', nil
+		}
+		return ',', nil
+	case "tab", "\\t":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(delimiter)
+	if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q, it must be one character", delimiter)
+	}
+	return r, nil
+}
+
+// jsonKeys returns the keys of a JSON object in the order they appear.
+func jsonKeys(object json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.(string))
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// csvField returns the text of a JSON value in a CSV file: strings without quotes, null as an empty field, and
+// objects and arrays as JSON.
+func csvField(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	if value := string(bytes.TrimSpace(value)); value != "null" {
+		return value
+	}
+	return ""
+}
+
+func SysArchiveExtract(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Archive   string `json:"archive,omitempty"`
+		Directory string `json:"directory,omitempty"`
+		MaxSize   string `json:"max_size,omitempty"`
+		MaxFiles  string `json:"max_files,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	var limits archive.Limits
+	if params.MaxSize != "" {
+		size, err := strconv.ParseInt(params.MaxSize, 10, 64)
+		if err != nil || size <= 0 {
+			return "", fmt.Errorf("invalid max_size %q, it must be a number of MB", params.MaxSize)
+		}
+		limits.MaxSize = size << 20
+	}
+	if params.MaxFiles != "" {
+		files, err := strconv.Atoi(params.MaxFiles)
+		if err != nil || files <= 0 {
+			return "", fmt.Errorf("invalid max_files %q", params.MaxFiles)
+		}
+		limits.MaxFiles = files
+	}
+
+	dir := types.FirstSet(params.Directory, ".")
+	if err := fsscope.Check(ctx, params.Archive); err != nil {
+		return "", err
+	}
+	if err := fsscope.Check(ctx, dir); err != nil {
+		return "", err
+	}
+
+	// Lock the directory to prevent concurrent writes from other tool calls.
+	locker.Lock(dir)
+	defer locker.Unlock(dir)
+
+	log.Debugf("Extracting %s to %s", params.Archive, dir)
+	result, err := archive.Extract(params.Archive, dir, limits)
+	if err != nil {
+		return "", err
+	}
+
+	out := fmt.Sprintf("Extracted %d files and directories to %s", len(result.Files), dir)
+	if len(result.Skipped) > 0 {
+		out += fmt.Sprintf(", skipped the links and special files %s", strings.Join(result.Skipped, ", "))
+	}
+	return out, nil
+}
+
+func SysArchiveCreate(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Archive   string `json:"archive,omitempty"`
+		Directory string `json:"directory,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	dir := types.FirstSet(params.Directory, ".")
+	if err := fsscope.Check(ctx, params.Archive); err != nil {
+		return "", err
+	}
+	if err := fsscope.Check(ctx, dir); err != nil {
+		return "", err
+	}
+
+	// Lock the file to prevent concurrent writes from other tool calls.
+	locker.Lock(params.Archive)
+	defer locker.Unlock(params.Archive)
+
+	if _, err := os.Stat(params.Archive); err == nil {
+		if err := confirm.Promptf(ctx, "Overwrite: %s", params.Archive); err != nil {
+			return "", err
+		}
+	}
+
+	log.Debugf("Archiving %s to %s", dir, params.Archive)
+	result, err := archive.Create(params.Archive, dir)
+	if err != nil {
+		return "", err
+	}
+
+	out := fmt.Sprintf("Added %d files and directories of %s to %s", len(result.Files), dir, params.Archive)
+	if len(result.Skipped) > 0 {
+		out += fmt.Sprintf(", skipped the links and special files %s", strings.Join(result.Skipped, ", "))
+	}
+	return out, nil
+}
+
+// templateFuncs are the functions of the templates of sys.template, besides the functions of text/template
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": func(sep string, values []any) string {
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = fmt.Sprint(v)
+		}
+		return strings.Join(s, sep)
+	},
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+}
+
+func SysTemplate(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Template string `json:"template,omitempty"`
+		Filename string `json:"filename,omitempty"`
+		Data     string `json:"data,omitempty"`
+		Output   string `json:"output,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	text, name := params.Template, "template"
+	if params.Filename != "" {
+		if err := fsscope.Check(ctx, params.Filename); err != nil {
+			return "", err
+		}
+
+		locker.RLock(params.Filename)
+		data, err := os.ReadFile(params.Filename)
+		locker.RUnlock(params.Filename)
+		if err != nil {
+			return "", err
+		}
+		text, name = string(data), filepath.Base(params.Filename)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var data any
+	if params.Data != "" {
+		if err := json.Unmarshal([]byte(params.Data), &data); err != nil {
+			return "", fmt.Errorf("invalid JSON data: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	if params.Output == "" {
+		return buf.String(), nil
+	}
+
+	if err := fsscope.Check(ctx, params.Output); err != nil {
+		return "", err
+	}
+
+	// Lock the file to prevent concurrent writes from other tool calls.
+	locker.Lock(params.Output)
+	defer locker.Unlock(params.Output)
+
+	if _, err := os.Stat(params.Output); err == nil {
+		if err := confirm.Promptf(ctx, "Overwrite: %s", params.Output); err != nil {
+			return "", err
+		}
+	}
+	if dir := filepath.Dir(params.Output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("creating dir %s: %w", dir, err)
+		}
+	}
+
+	log.Debugf("Wrote %d bytes of the rendered template to file %s", buf.Len(), params.Output)
+	if err := os.WriteFile(params.Output, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Wrote the rendered template to %s", params.Output), nil
+}
+
+func SysClipboardRead(ctx context.Context, _ []string, _ string) (string, error) {
+	// The clipboard often has passwords and other secrets on it, so it is only read if the user agrees every time
+	if err := confirm.Requiredf(ctx, "Read the clipboard"); err != nil {
+		return "", err
+	}
+
+	text, err := clipboard.Read(ctx)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "The clipboard is empty", nil
+	}
+	return text, nil
+}
+
+func SysClipboardWrite(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Content string `json:"content,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	if err := clipboard.Write(ctx, params.Content); err != nil {
+		return "", err
+	}
+	log.Debugf("Wrote %d bytes to the clipboard", len(params.Content))
+	return fmt.Sprintf("Put %d characters on the clipboard", utf8.RuneCountInString(params.Content)), nil
+}
+
+func SysNotify(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Title   string `json:"title,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+	if params.Message == "" {
+		return "", fmt.Errorf("a message is required")
+	}
+
+	sent, err := notify.Send(ctx, params.Title, params.Message)
+	if err != nil {
+		return "", err
+	}
+	log.Debugf("Sent notification %q to the %s", params.Title, sent)
+	return fmt.Sprintf("Sent the notification to the %s", sent), nil
+}
+
+func SysFileWatch(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Path    string `json:"path,omitempty"`
+		Pattern string `json:"pattern,omitempty"`
+		Timeout string `json:"timeout,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+	if params.Path == "" {
+		return "", fmt.Errorf("a path is required")
+	}
+	if err := fsscope.Check(ctx, params.Path); err != nil {
+		return "", err
+	}
+
+	timeout := 5 * time.Minute
+	if params.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(params.Timeout); err != nil {
+			return "", fmt.Errorf("invalid timeout %q: %w", params.Timeout, err)
+		}
+	}
+
+	log.Debugf("Watching %s for changes for %s", params.Path, timeout)
+	changes, err := filewatch.Wait(ctx, params.Path, params.Pattern, fileWatchInterval, timeout)
+	if err != nil {
+		return "", err
+	}
+	if len(changes) == 0 {
+		return fmt.Sprintf("Nothing changed in %s within %s", params.Path, timeout), nil
+	}
+
+	data, err := json.Marshal(changes)
+	return string(data), err
+}
+
+// fileWatchInterval is how often sys.file.watch checks the files for changes
+var fileWatchInterval = 500 * time.Millisecond
+
+func SysSchedule(_ context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Program string `json:"program,omitempty"`
+		Tool    string `json:"tool,omitempty"`
+		Input   string `json:"input,omitempty"`
+		Cron    string `json:"cron,omitempty"`
+		At      string `json:"at,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+	if (params.Cron == "") == (params.At == "") {
+		return "", fmt.Errorf("either cron or at is required")
+	}
+
+	// The server runs in another directory, so the program is scheduled with its absolute path
+	program, err := filepath.Abs(params.Program)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(program); err != nil {
+		return "", err
+	}
+
+	sched := schedule.Schedule{
+		Program: program,
+		Tool:    params.Tool,
+		Input:   params.Input,
+		Cron:    params.Cron,
+	}
+	if params.At != "" {
+		if d, err := time.ParseDuration(params.At); err == nil {
+			sched.Next = time.Now().Add(d)
+		} else if sched.Next, err = time.Parse(time.RFC3339, params.At); err != nil {
+			return "", fmt.Errorf("invalid time %q, expected an RFC 3339 time or a duration", params.At)
+		}
+	}
+
+	sched, err = (&schedule.Store{Dir: schedule.DefaultDir()}).Add(sched)
+	if err != nil {
+		return "", err
+	}
+	log.Debugf("Added schedule %s of %s", sched.ID, program)
+	return fmt.Sprintf("Scheduled the run %s, next at %s. It runs while the GPTScript server is running", sched.ID, sched.Next.Format(time.RFC3339)), nil
+}
+
+func SysVarGet(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Name string `json:"name,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	v := vars.FromContext(ctx)
+	if v == nil {
+		return "", fmt.Errorf("variables can only be used in a run")
+	}
+
+	if params.Name == "" {
+		data, err := json.Marshal(v.All())
+		return string(data), err
+	}
+	value, ok := v.Get(params.Name)
+	if !ok {
+		return "", fmt.Errorf("the variable %s is not set", params.Name)
+	}
+	return value, nil
+}
+
+func SysVarSet(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Name  string `json:"name,omitempty"`
+		Value string `json:"value,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	v := vars.FromContext(ctx)
+	if v == nil {
+		return "", fmt.Errorf("variables can only be used in a run")
+	}
+
+	if err := v.Set(params.Name, params.Value); err != nil {
+		return "", err
+	}
+	log.Debugf("Set the variable %s", params.Name)
+	return fmt.Sprintf("Set the variable %s", params.Name), nil
+}
+
+func SysSecrets(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Action string `json:"action,omitempty"`
+		Name   string `json:"name,omitempty"`
+		Value  string `json:"value,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+
+	s := secrets.FromContext(ctx)
+	if s == nil {
+		return "", fmt.Errorf("secrets can only be used in a run")
+	}
+
+	switch params.Action {
+	case "get":
+		value, ok, err := s.Get(params.Name)
+		if err != nil {
+			return "", err
+		} else if !ok {
+			return "", fmt.Errorf("the secret %s is not set", params.Name)
+		}
+		return value, nil
+	case "set":
+		if params.Value == "" {
+			return "", fmt.Errorf("a value is required")
+		}
+		if err := s.Set(params.Name, params.Value); err != nil {
+			return "", err
+		}
+		log.Debugf("Set the secret %s", params.Name)
+		return fmt.Sprintf("Set the secret %s", params.Name), nil
+	case "delete":
+		if err := s.Delete(params.Name); err != nil {
+			return "", err
+		}
+		log.Debugf("Deleted the secret %s", params.Name)
+		return fmt.Sprintf("Deleted the secret %s", params.Name), nil
+	case "list":
+		names, err := s.Names()
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "No secrets are set", nil
+		}
+		return strings.Join(names, "\n"), nil
+	default:
+		return "", fmt.Errorf("invalid action %q, expected get, set, delete or list", params.Action)
+	}
+}
+
+func SysVectorIndex(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Index     string `json:"index,omitempty"`
+		Directory string `json:"directory,omitempty"`
+		Pattern   string `json:"pattern,omitempty"`
+		Model     string `json:"model,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+	if params.Index == "" {
+		return "", fmt.Errorf("the index file is required")
+	}
+	params.Directory = types.FirstSet(params.Directory, ".")
+
+	embedder, ok := vector.FromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("no model provider with embeddings is configured")
+	}
+	if err := fsscope.Check(ctx, params.Directory); err != nil {
+		return "", err
+	}
+	if err := fsscope.Check(ctx, params.Index); err != nil {
+		return "", err
+	}
+
+	indexFile, err := filepath.Abs(params.Index)
+	if err != nil {
+		return "", err
+	}
+
+	var files []string
+	err = filepath.WalkDir(params.Directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != params.Directory && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, err := filepath.Abs(path); err == nil && abs == indexFile {
+			return nil
+		}
+		if params.Pattern != "" {
+			if ok, err := filepath.Match(params.Pattern, d.Name()); err != nil {
+				return err
+			} else if !ok {
+				return nil
+			}
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Lock the index to prevent concurrent updates from other tool calls.
+	locker.Lock(params.Index)
+	defer locker.Unlock(params.Index)
+
+	index, err := vector.Load(params.Index)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("Indexing %d files in %s to %s", len(files), params.Directory, params.Index)
+	indexed, err := index.Update(ctx, embedder, params.Model, files)
+	if err != nil {
+		return "", err
+	}
+	if err := index.Save(params.Index); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Indexed %d changed files of %d, the index %s has %d chunks of %d files", indexed, len(files),
+		params.Index, len(index.Chunks), len(index.Files)), nil
+}
+
+func SysVectorSearch(ctx context.Context, _ []string, input string) (string, error) {
+	var params struct {
+		Index string `json:"index,omitempty"`
+		Query string `json:"query,omitempty"`
+		Count string `json:"count,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return "", err
+	}
+	if params.Query == "" {
+		return "", fmt.Errorf("the query is required")
+	}
+
+	count := 5
+	if params.Count != "" {
+		n, err := strconv.Atoi(params.Count)
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("invalid count %q, it must be a positive number", params.Count)
+		}
+		count = n
+	}
+
+	embedder, ok := vector.FromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("no model provider with embeddings is configured")
+	}
+	if err := fsscope.Check(ctx, params.Index); err != nil {
+		return "", err
+	}
+
+	locker.RLock(params.Index)
+	defer locker.RUnlock(params.Index)
+
+	if _, err := os.Stat(params.Index); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("The index %s does not exist, create it with sys.vector.index", params.Index), nil
+	}
+	index, err := vector.Load(params.Index)
+	if err != nil {
+		return "", err
+	}
+
+	log.Debugf("Searching %s for %s", params.Index, params.Query)
+	results, err := index.Search(ctx, embedder, params.Query, count)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("The index %s has no files", params.Index), nil
+	}
+
+	var buf strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		_, _ = fmt.Fprintf(&buf, "%s:%d-%d (score %.2f)\n%s", result.Path, result.Start, result.End, result.Score,
+			strings.TrimRight(result.Text, "\n")+"\n")
+	}
+	return buf.String(), nil
+}
+
+func SysDownload(ctx context.Context, env []string, input string) (_ string, err error) {
+	var params struct {
+		URL      string `json:"url,omitempty"`
 		Location string `json:"location,omitempty"`
 		Override string `json:"override,omitempty"`
+		SHA256   string `json:"sha256,omitempty"`
 	}
 	if err := json.Unmarshal([]byte(input), &params); err != nil {
 		return "", err
@@ -621,6 +2166,9 @@ func SysDownload(ctx context.Context, env []string, input string) (_ string, err
 	tmpDir := ""
 
 	if params.Location != "" {
+		if err := fsscope.Check(ctx, params.Location); err != nil {
+			return "", err
+		}
 		if s, err := os.Stat(params.Location); err == nil && s.IsDir() {
 			tmpDir = params.Location
 			params.Location = ""
@@ -628,6 +2176,13 @@ func SysDownload(ctx context.Context, env []string, input string) (_ string, err
 	}
 
 	if params.Location == "" {
+		if tmpDir == "" {
+			// The downloads without a location are in the directory that every scope of the file tools allows, so
+			// they can be read with sys.read
+			if tmpDir, err = fsscope.TempDir(); err != nil {
+				return "", err
+			}
+		}
 		f, err := os.CreateTemp(tmpDir, "gpt-download*"+urlExt(params.URL))
 		if err != nil {
 			return "", err
@@ -648,29 +2203,10 @@ func SysDownload(ctx context.Context, env []string, input string) (_ string, err
 	}
 
 	log.Infof("download [%s] to [%s]", params.URL, params.Location)
-	resp, err := http.Get(params.URL)
-	if err != nil {
+	c := &http.Client{Transport: egress.Transport(ctx, nil)}
+	if err := download.File(ctx, c, params.URL, params.Location, params.SHA256); err != nil {
 		return "", err
 	}
-	defer func() {
-		_, _ = io.ReadAll(resp.Body)
-		_ = resp.Body.Close()
-	}()
-
-	if resp.StatusCode > 299 {
-		return "", fmt.Errorf("invalid status code [%d] downloading [%s]: %s", resp.StatusCode, params.URL, resp.Status)
-	}
-
-	_ = os.Remove(params.Location)
-	f, err := os.Create(params.Location)
-	if err != nil {
-		return "", fmt.Errorf("failed to create [%s]: %w", params.Location, err)
-	}
-	defer f.Close()
-
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return "", fmt.Errorf("failed copying data from [%s] to [%s]: %w", params.URL, params.Location, err)
-	}
 
 	return params.Location, nil
 }
@@ -0,0 +1,447 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/fsscope"
+	"github.com/gptscript-ai/gptscript/pkg/vars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSysHTTPRequest(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+	defer s.Close()
+
+	input := func(v map[string]string) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	out, err := SysHTTPRequest(context.Background(), nil, input(map[string]string{
+		"method":      "delete",
+		"url":         s.URL,
+		"content":     `{"id": 1}`,
+		"contentType": "application/json",
+		"headers":     `{"Authorization": "Bearer token"}`,
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, out, "HTTP/1.1 201 Created\n")
+	assert.Contains(t, out, "X-Method: DELETE\n")
+	assert.Contains(t, out, "X-Auth: Bearer token\n")
+	assert.Contains(t, out, "X-Content-Type: application/json\n")
+	assert.Contains(t, out, "\n\n{\"id\": 1}")
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "body.txt")
+	require.NoError(t, os.WriteFile(file, []byte("from a file"), 0644))
+
+	out, err = SysHTTPMethod(http.MethodPatch)(context.Background(), nil, input(map[string]string{
+		"url":         s.URL,
+		"contentFile": file,
+		"headers":     "Authorization: Basic abc\n",
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, out, "X-Method: PATCH\n")
+	assert.Contains(t, out, "X-Auth: Basic abc\n")
+	assert.Contains(t, out, "\n\nfrom a file")
+
+	scope, err := fsscope.New(t.TempDir())
+	require.NoError(t, err)
+	_, err = SysHTTPMethod(http.MethodPut)(fsscope.WithContext(context.Background(), scope), nil, input(map[string]string{
+		"url":         s.URL,
+		"contentFile": file,
+	}))
+	var outside *fsscope.ErrOutside
+	assert.ErrorAs(t, err, &outside)
+}
+
+func TestSysJSONQuery(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "issues.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"issues": [{"title": "<crash>", "open": true}, {"title": "typo", "open": false}]}`), 0644))
+
+	input := func(v map[string]string) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	out, err := SysJSONQuery(context.Background(), nil, input(map[string]string{
+		"expression": ".issues[] | select(.open) | .title",
+		"filename":   file,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, `"<crash>"`, out)
+
+	out, err = SysJSONQuery(context.Background(), nil, input(map[string]string{
+		"expression": ".issues[].title",
+		"json":       `{"issues": [{"title": "a"}, {"title": "b"}]}`,
+		"raw":        "true",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb", out)
+
+	out, err = SysJSONQuery(context.Background(), nil, input(map[string]string{
+		"expression": "{count: (.issues | length)}",
+		"filename":   file,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"count\": 2\n}", out)
+
+	// The whole jq language is supported, and env is the environment of the run
+	for expression, want := range map[string]string{
+		`reduce .issues[] as $i (0; . + 1)`:                    "2",
+		`[.issues[] | "\(.title): \(.open)"] | join(", ")`:     `"<crash>: true, typo: false"`,
+		`.issues | map(select(.open) | .title) | @csv`:         `"\"<crash>\""`,
+		`def titles: [.issues[].title]; titles | length`:       "2",
+		`.issues[0].title |= ltrimstr("<") | .issues[0].title`: `"crash>"`,
+		`try error("failed") catch .`:                          `"failed"`,
+		`[paths(type == "boolean")] | length`:                  "2",
+		`env.PROJECT + "/" + $ENV.PROJECT`:                     `"gptscript/gptscript"`,
+		`del(.issues) | keys`:                                  "[]",
+		`[range(0; 10; 3)] | any(. > 5)`:                       "true",
+	} {
+		out, err = SysJSONQuery(context.Background(), []string{"PROJECT=gptscript"}, input(map[string]string{
+			"expression": expression,
+			"filename":   file,
+		}))
+		require.NoError(t, err, expression)
+		assert.Equal(t, want, out, expression)
+	}
+
+	out, err = SysJSONQuery(context.Background(), nil, input(map[string]string{
+		"expression": ".issues[] | select(.title == \"none\")",
+		"filename":   file,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "No values", out)
+
+	_, err = SysJSONQuery(context.Background(), nil, input(map[string]string{
+		"expression": ".issues[",
+		"filename":   file,
+	}))
+	assert.ErrorContains(t, err, "invalid expression")
+
+	_, err = SysJSONQuery(context.Background(), nil, input(map[string]string{
+		"expression": ".issues | error(\"no issues\")",
+		"filename":   file,
+	}))
+	assert.ErrorContains(t, err, "no issues")
+
+	scope, err := fsscope.New(filepath.Join(dir, "other"))
+	require.NoError(t, err)
+	_, err = SysJSONQuery(fsscope.WithContext(context.Background(), scope), nil, input(map[string]string{
+		"expression": ".",
+		"filename":   file,
+	}))
+	assert.ErrorAs(t, err, new(*fsscope.ErrOutside))
+}
+
+func TestSysCSV(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "people.csv")
+
+	input := func(v map[string]string) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	out, err := SysCSVWrite(context.Background(), nil, input(map[string]string{
+		"filename": file,
+		"rows":     `[{"name": "Ada", "age": 36}, {"name": "Alan, T.", "city": "London"}, {"name": "Grace", "age": null}]`,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Wrote 3 rows to "+file, out)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "name,age,city\nAda,36,\n\"Alan, T.\",,London\nGrace,,\n", string(data))
+
+	_, err = SysCSVWrite(context.Background(), nil, input(map[string]string{
+		"filename": file,
+		"rows":     `[{"city": "Arlington", "name": "Grace"}]`,
+		"columns":  "name,age,city",
+		"append":   "true",
+	}))
+	require.NoError(t, err)
+
+	out, err = SysCSVRead(context.Background(), nil, input(map[string]string{
+		"filename": file,
+		"columns":  "city,name",
+		"start":    "2",
+		"count":    "2",
+	}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"city": "London", "name": "Alan, T."}, {"city": "", "name": "Grace"}]`, out)
+	assert.Less(t, strings.Index(out, "city"), strings.Index(out, "name"), "the fields must be in the order of the columns")
+
+	out, err = SysCSVRead(context.Background(), nil, input(map[string]string{
+		"filename": file,
+		"header":   "false",
+		"columns":  "1",
+		"start":    "5",
+	}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[["Grace"]]`, out)
+
+	tsv := filepath.Join(dir, "table.tsv")
+	_, err = SysCSVWrite(context.Background(), nil, input(map[string]string{
+		"filename": tsv,
+		"rows":     `[["a", 1, true], ["b", 2, false]]`,
+	}))
+	require.NoError(t, err)
+	data, err = os.ReadFile(tsv)
+	require.NoError(t, err)
+	assert.Equal(t, "a\t1\ttrue\nb\t2\tfalse\n", string(data))
+
+	_, err = SysCSVRead(context.Background(), nil, input(map[string]string{
+		"filename": file,
+		"columns":  "email",
+	}))
+	assert.ErrorContains(t, err, "column email is not in the file")
+}
+
+func TestSysArchive(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(src, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "notes.txt"), []byte("notes"), 0644))
+
+	input := func(v map[string]string) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	file := filepath.Join(dir, "src.tar.gz")
+	out, err := SysArchiveCreate(context.Background(), nil, input(map[string]string{
+		"archive":   file,
+		"directory": src,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Added 1 files and directories of "+src+" to "+file, out)
+
+	dst := filepath.Join(dir, "dst")
+	_, err = SysArchiveExtract(context.Background(), nil, input(map[string]string{
+		"archive":   file,
+		"directory": dst,
+	}))
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dst, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "notes", string(data))
+
+	scope, err := fsscope.New(src)
+	require.NoError(t, err)
+	_, err = SysArchiveExtract(fsscope.WithContext(context.Background(), scope), nil, input(map[string]string{
+		"archive":   file,
+		"directory": dst,
+	}))
+	assert.ErrorAs(t, err, new(*fsscope.ErrOutside))
+}
+
+func TestSysTemplate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "email.tmpl")
+	require.NoError(t, os.WriteFile(file, []byte("Hello {{.name | upper}},\n{{range .items}}- {{.}}\n{{end}}Tags: {{join \", \" .tags}}"), 0644))
+
+	input := func(v map[string]string) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	data := `{"name": "ada", "items": ["one", "two"], "tags": ["a", "b"]}`
+	out, err := SysTemplate(context.Background(), nil, input(map[string]string{
+		"filename": file,
+		"data":     data,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello ADA,\n- one\n- two\nTags: a, b", out)
+
+	output := filepath.Join(dir, "out", "config.json")
+	_, err = SysTemplate(context.Background(), nil, input(map[string]string{
+		"template": `{"items": {{json .items}}}`,
+		"data":     data,
+		"output":   output,
+	}))
+	require.NoError(t, err)
+	written, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, `{"items": ["one","two"]}`, string(written))
+
+	_, err = SysTemplate(context.Background(), nil, input(map[string]string{
+		"template": "{{.missing}}",
+		"data":     data,
+	}))
+	assert.ErrorContains(t, err, "missing")
+}
+
+type refuse struct {
+	prompts []string
+}
+
+func (r *refuse) Confirm(_ context.Context, prompt string) error {
+	r.prompts = append(r.prompts, prompt)
+	return errors.New("abort")
+}
+
+func TestSysClipboardReadConfirm(t *testing.T) {
+	r := &refuse{}
+	_, err := SysClipboardRead(confirm.WithConfirm(context.Background(), r), nil, "{}")
+	assert.EqualError(t, err, "abort")
+	assert.Equal(t, []string{"Read the clipboard"}, r.prompts)
+}
+
+type correct struct {
+	arguments string
+}
+
+func (c correct) Confirm(context.Context, string) error {
+	return nil
+}
+
+func (c correct) Edit(context.Context, string, string) (string, error) {
+	return c.arguments, nil
+}
+
+func TestSysExecEditedArguments(t *testing.T) {
+	ctx := confirm.WithConfirm(context.Background(), correct{arguments: `{"command": "echo corrected"}`})
+	out, err := SysExec(ctx, os.Environ(), `{"command": "echo wrong"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "corrected\n", out)
+}
+
+func TestSysNotifyWebhook(t *testing.T) {
+	var got map[string]string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer s.Close()
+	t.Setenv("GPTSCRIPT_NOTIFY_WEBHOOK", s.URL)
+
+	out, err := SysNotify(context.Background(), nil, `{"title": "Report", "message": "The report is ready"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Sent the notification to the webhook", out)
+	assert.Equal(t, map[string]string{
+		"title":   "Report",
+		"message": "The report is ready",
+		"text":    "*Report*\nThe report is ready",
+	}, got)
+
+	_, err = SysNotify(context.Background(), nil, `{"title": "Report"}`)
+	assert.EqualError(t, err, "a message is required")
+}
+
+func TestSysLs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pkg", "sub"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pkg", "sub", "sub.go"), []byte("package sub"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "README.md"), []byte("# Docs"), 0644))
+
+	ls := func(args map[string]string) string {
+		args["dir"] = dir
+		data, err := json.Marshal(args)
+		require.NoError(t, err)
+		out, err := SysLs(context.Background(), nil, string(data))
+		require.NoError(t, err)
+		return out
+	}
+
+	assert.Equal(t, "docs/\nmain.go\npkg/", ls(map[string]string{}))
+	assert.Equal(t, "docs/\ndocs/README.md\nmain.go\npkg/\npkg/sub/", ls(map[string]string{"depth": "2"}))
+	assert.Equal(t, "main.go\npkg/\npkg/sub/\npkg/sub/sub.go", ls(map[string]string{"depth": "0", "pattern": "*.go"}))
+
+	var details struct {
+		Entries []lsEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(ls(map[string]string{"pattern": "*.go", "details": "true"})), &details))
+	require.Len(t, details.Entries, 1)
+	assert.Equal(t, "main.go", details.Entries[0].Path)
+	assert.Equal(t, int64(len("package main")), details.Entries[0].Size)
+	assert.False(t, details.Entries[0].Modified.IsZero())
+}
+
+func TestSysVar(t *testing.T) {
+	ctx := vars.WithVars(context.Background(), vars.New(map[string]string{"region": "us-east-1"}))
+
+	out, err := SysVarGet(ctx, nil, `{"name":"region"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", out)
+
+	_, err = SysVarSet(ctx, nil, `{"name":"stage","value":"prod"}`)
+	require.NoError(t, err)
+	out, err = SysVarGet(ctx, nil, `{}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"region":"us-east-1","stage":"prod"}`, out)
+
+	_, err = SysVarGet(ctx, nil, `{"name":"missing"}`)
+	assert.Error(t, err)
+	_, err = SysVarSet(ctx, nil, `{"name":"not a name","value":"x"}`)
+	assert.Error(t, err)
+}
+
+func TestSysDownloadThenRead(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("the report"))
+	}))
+	defer s.Close()
+
+	input := func(v map[string]string) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// The scope of a tool that declares no paths is the working directory, the download is outside of it
+	scope, err := fsscope.New(t.TempDir())
+	require.NoError(t, err)
+	ctx := fsscope.WithContext(context.Background(), scope)
+
+	file, err := SysDownload(ctx, nil, input(map[string]string{
+		"url": s.URL + "/report.txt",
+	}))
+	require.NoError(t, err)
+	defer os.Remove(file)
+	assert.Equal(t, ".txt", filepath.Ext(file))
+
+	out, err := SysRead(ctx, nil, input(map[string]string{
+		"filename": file,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "the report", out)
+
+	// Other temporary files are still outside of the scope
+	other, err := os.CreateTemp("", "gpt-download")
+	require.NoError(t, err)
+	_ = other.Close()
+	defer os.Remove(other.Name())
+	_, err = SysRead(ctx, nil, input(map[string]string{
+		"filename": other.Name(),
+	}))
+	var outside *fsscope.ErrOutside
+	assert.ErrorAs(t, err, &outside)
+}
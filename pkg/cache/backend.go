@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Backend stores the entries of the cache, like the responses of models and the parsed programs, by their keys.
+type Backend interface {
+	Get(key string) ([]byte, bool, error)
+	Store(key string, content []byte) error
+}
+
+// NewBackend returns the backend of the URL: redis://[user:password@]host[:port][/db] for Redis, with rediss:// for
+// TLS, or http(s)://host/path for an HTTP server that the entries are read from with GET and stored to with PUT. The
+// entries are sent with the transport, or http.DefaultTransport if it is nil, and the connections to Redis over TLS
+// use its TLS config.
+func NewBackend(backendURL string, transport *http.Transport) (Backend, error) {
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	u, err := url.Parse(backendURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache URL %q: %w", backendURL, err)
+	}
+	switch u.Scheme {
+	case "redis", "rediss":
+		return newRedisBackend(u, transport.TLSClientConfig)
+	case "http", "https":
+		return newHTTPBackend(u, transport), nil
+	default:
+		return nil, fmt.Errorf("invalid cache URL %q, it must be a redis, rediss, http or https URL", backendURL)
+	}
+}
+
+// diskBackend stores the entries as the files of a directory.
+type diskBackend struct {
+	dir string
+}
+
+func (d diskBackend) Store(key string, content []byte) error {
+	return os.WriteFile(filepath.Join(d.dir, key), content, 0644)
+}
+
+func (d diskBackend) Get(key string) ([]byte, bool, error) {
+	file := filepath.Join(d.dir, key)
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	// The modification time is the last use of the entry when the cache is pruned
+	now := time.Now()
+	_ = os.Chtimes(file, now, now)
+	return data, true, nil
+}
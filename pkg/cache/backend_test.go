@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type httpCache struct {
+	lock    sync.Mutex
+	entries map[string][]byte
+}
+
+func (h *httpCache) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if user, password, _ := req.BasicAuth(); user != "ci" || password != "secret" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	key := strings.TrimPrefix(req.URL.Path, "/cache/")
+	switch req.Method {
+	case http.MethodGet:
+		data, ok := h.entries[key]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = rw.Write(data)
+	case http.MethodPut:
+		h.entries[key], _ = io.ReadAll(req.Body)
+	}
+}
+
+func TestHTTPBackend(t *testing.T) {
+	srv := httptest.NewServer(&httpCache{entries: map[string][]byte{}})
+	defer srv.Close()
+
+	c, err := New(Options{CacheDir: t.TempDir(), CacheURL: strings.Replace(srv.URL, "http://", "http://ci:secret@", 1) + "/cache"})
+	require.NoError(t, err)
+
+	_, ok, err := c.Get("key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Store("key", []byte("the response")))
+	data, ok, err := c.Get("key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "the response", string(data))
+
+	// The failures of a cache that is shared are misses
+	c, err = New(Options{CacheDir: t.TempDir(), CacheURL: srv.URL + "/cache"})
+	require.NoError(t, err)
+	_, ok, err = c.Get("key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedisBackend(t *testing.T) {
+	srv := miniredis.RunT(t)
+	srv.RequireAuth("secret")
+
+	backend, err := NewBackend("redis://:secret@"+srv.Addr()+"?prefix=ci:&ttl=1h", nil)
+	require.NoError(t, err)
+
+	_, ok, err := backend.Get("key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, backend.Store("key", []byte("the\r\nresponse")))
+	value, err := srv.Get("ci:key")
+	require.NoError(t, err)
+	assert.Equal(t, "the\r\nresponse", value)
+	assert.Equal(t, time.Hour, srv.TTL("ci:key"))
+
+	data, ok, err := backend.Get("key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "the\r\nresponse", string(data))
+
+	backend, err = NewBackend("redis://:wrong@"+srv.Addr(), nil)
+	require.NoError(t, err)
+	_, _, err = backend.Get("key")
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	_, err = NewBackend("redis://"+srv.Addr()+"/cache", nil)
+	assert.Error(t, err)
+	_, err = NewBackend("memcached://"+srv.Addr(), nil)
+	assert.Error(t, err)
+}
+
+func TestRedisBackendTLS(t *testing.T) {
+	// The certificate of the test server is for 127.0.0.1, and is signed by a CA that the system does not trust
+	certs := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certs.Close()
+
+	srv := miniredis.NewMiniRedis()
+	require.NoError(t, srv.StartTLS(&tls.Config{Certificates: certs.TLS.Certificates}))
+	defer srv.Close()
+
+	backend, err := NewBackend("rediss://"+srv.Addr(), nil)
+	require.NoError(t, err)
+	assert.Error(t, backend.Store("key", []byte("the response")))
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certs.Certificate())
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	backend, err = NewBackend("rediss://"+srv.Addr(), transport)
+	require.NoError(t, err)
+	require.NoError(t, backend.Store("key", []byte("the response")))
+	value, err := srv.Get("gptscript:key")
+	require.NoError(t, err)
+	assert.Equal(t, "the response", value)
+}
@@ -2,8 +2,8 @@ package cache
 
 import (
 	"context"
-	"errors"
-	"io/fs"
+	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 
@@ -13,19 +13,32 @@ import (
 )
 
 type Client struct {
-	dir  string
-	noop bool
+	dir     string
+	noop    bool
+	only    bool
+	backend Backend
+	// remote is whether the backend is shared over the network, its failures are misses instead of errors, so a run
+	// does not fail when the cache is down
+	remote bool
 }
 
 type Options struct {
-	DisableCache bool   `usage:"Disable caching of LLM API responses"`
+	DisableCache bool   `usage:"Disable caching of LLM API responses and parsed OpenAPI definitions"`
 	CacheDir     string `usage:"Directory to store cache (default: $XDG_CACHE_HOME/gptscript)"`
+	CacheOnly    bool   `usage:"Only use the cached responses of models, and fail on the requests that are not in the cache instead of calling the model provider" env:"GPTSCRIPT_CACHE_ONLY"`
+	CacheURL     string `usage:"URL of a cache that is shared, like redis://host:6379/0 or https://host/path, to store the responses of models and the parsed programs in instead of the cache directory" env:"GPTSCRIPT_CACHE_URL"`
+	// Transport is the transport of the connections to the cache URL, whose TLS config is used for Redis too,
+	// http.DefaultTransport if nil
+	Transport *http.Transport `usage:"-"`
 }
 
 func Complete(opts ...Options) (result Options) {
 	for _, opt := range opts {
 		result.CacheDir = types.FirstSet(opt.CacheDir, result.CacheDir)
 		result.DisableCache = types.FirstSet(opt.DisableCache, result.DisableCache)
+		result.CacheOnly = types.FirstSet(opt.CacheOnly, result.CacheOnly)
+		result.CacheURL = types.FirstSet(opt.CacheURL, result.CacheURL)
+		result.Transport = types.FirstSet(opt.Transport, result.Transport)
 	}
 	if result.CacheDir == "" {
 		result.CacheDir = filepath.Join(xdg.CacheHome, version.ProgramName)
@@ -46,13 +59,44 @@ func WithNoCache(ctx context.Context) context.Context {
 
 func New(opts ...Options) (*Client, error) {
 	opt := Complete(opts...)
+	if opt.DisableCache && opt.CacheOnly {
+		return nil, fmt.Errorf("the cache can not be disabled with --cache-only")
+	}
 	if err := os.MkdirAll(opt.CacheDir, 0755); err != nil {
 		return nil, err
 	}
-	return &Client{
-		dir:  opt.CacheDir,
-		noop: opt.DisableCache,
-	}, nil
+
+	c := &Client{
+		dir:     opt.CacheDir,
+		noop:    opt.DisableCache,
+		only:    opt.CacheOnly,
+		backend: diskBackend{dir: opt.CacheDir},
+	}
+	if opt.CacheURL != "" && !opt.DisableCache {
+		backend, err := NewBackend(opt.CacheURL, opt.Transport)
+		if err != nil {
+			return nil, err
+		}
+		c.backend, c.remote = backend, true
+	}
+	return c, nil
+}
+
+// Only returns whether the responses of models can only come from the cache.
+func (c *Client) Only() bool {
+	return c != nil && c.only
+}
+
+// MissError is the error of a request that is not in the cache when the responses of models can only come from the
+// cache.
+type MissError struct {
+	Key string
+	// Request describes the request, like its model and last message
+	Request string
+}
+
+func (e *MissError) Error() string {
+	return fmt.Sprintf("the response to request [%s] is not in the cache, and --cache-only is set: %s", e.Key, e.Request)
 }
 
 func (c *Client) CacheDir() string {
@@ -63,18 +107,23 @@ func (c *Client) Store(key string, content []byte) error {
 	if c == nil || c.noop {
 		return nil
 	}
-	return os.WriteFile(filepath.Join(c.dir, key), content, 0644)
+	err := c.backend.Store(key, content)
+	if err != nil && c.remote {
+		log.Warnf("Failed to store %s in the cache: %v", key, err)
+		return nil
+	}
+	return err
 }
 
 func (c *Client) Get(key string) ([]byte, bool, error) {
 	if c == nil || c.noop {
 		return nil, false, nil
 	}
-	data, err := os.ReadFile(filepath.Join(c.dir, key))
-	if errors.Is(err, fs.ErrNotExist) {
+	data, ok, err := c.backend.Get(key)
+	// With --cache-only a response that can not be read can not come from anywhere else
+	if err != nil && c.remote && !c.only {
+		log.Warnf("Failed to get %s from the cache: %v", key, err)
 		return nil, false, nil
-	} else if err != nil {
-		return nil, false, err
 	}
-	return data, true, nil
+	return data, ok, err
 }
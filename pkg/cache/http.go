@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpBackend stores the entries on an HTTP server, like a bucket or the cache of a CI service: an entry is read with
+// GET <url>/<key>, which is missing with 404, and stored with PUT <url>/<key>. The user and password of the URL are
+// sent with basic auth.
+type httpBackend struct {
+	url    string
+	user   *url.Userinfo
+	client *http.Client
+}
+
+func newHTTPBackend(u *url.URL, transport http.RoundTripper) httpBackend {
+	user := u.User
+	base := *u
+	base.User = nil
+	return httpBackend{
+		url:  strings.TrimSuffix(base.String(), "/"),
+		user: user,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+func (h httpBackend) do(method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, h.url+"/"+url.PathEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if h.user != nil {
+		password, _ := h.user.Password()
+		req.SetBasicAuth(h.user.Username(), password)
+	}
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return h.client.Do(req)
+}
+
+func (h httpBackend) Get(key string) ([]byte, bool, error) {
+	resp, err := h.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, false, statusError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (h httpBackend) Store(key string, content []byte) error {
+	resp, err := h.do(http.MethodPut, key, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("invalid status code [%d] of the cache: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry is a part of the cache that is removed as a whole, like a runtime or a cloned repository.
+type Entry struct {
+	Kind     string    `json:"kind"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"lastUsed"`
+	// Needs are the paths of the entries that the entry can not be used without, it is removed with any of them
+	Needs []string `json:"needs,omitempty"`
+}
+
+type PruneOptions struct {
+	// OlderThan removes the entries that have not been used for longer
+	OlderThan time.Duration
+	// MaxSize removes the least recently used entries until the size of the rest is at most MaxSize
+	MaxSize int64
+	// DryRun returns the entries that would be removed without removing them
+	DryRun bool
+}
+
+// Entries returns the entries of the cache in dir that the cache client stores: the cached responses of models,
+// the OCI artifacts of tools, the files of local models and the snapshots of working directories. The repos directory is not included, its entries are
+// returned by repos.Entries.
+func Entries(dir string) (result []Entry, _ error) {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.Type().IsRegular() {
+			entry, err := NewEntry("response", filepath.Join(dir, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, entry)
+		}
+	}
+
+	for _, sub := range []struct {
+		dir, kind string
+	}{
+		{"oci", "oci"},
+		{"local-models", "model"},
+		{"snapshots", "snapshot"},
+	} {
+		entries, err := DirEntries(sub.kind, filepath.Join(dir, sub.dir))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
+	}
+
+	return result, nil
+}
+
+// DirEntries returns an entry for each file or directory in dir.
+func DirEntries(kind, dir string) (result []Entry, _ error) {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		entry, err := NewEntry(kind, filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// NewEntry returns the entry of a file or directory. Its size is the size of all the files in it, and it was last
+// used when a file in it was last modified.
+func NewEntry(kind, path string) (Entry, error) {
+	entry := Entry{
+		Kind: kind,
+		Path: path,
+	}
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			entry.Size += info.Size()
+		}
+		if info.ModTime().After(entry.LastUsed) {
+			entry.LastUsed = info.ModTime()
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// Prune removes the entries selected by the options, and the entries that need them, and returns what it removed.
+// An entry that other entries need is considered used when they were last used. The entries that are in use, see
+// Use, and the entries that they need are not removed, and the entries that fail to be removed are logged and
+// skipped.
+func Prune(entries []Entry, opts PruneOptions) ([]Entry, error) {
+	var (
+		neededBy = map[string][]int{}
+		lastUsed = make([]time.Time, len(entries))
+		removed  = make([]bool, len(entries))
+		result   []Entry
+		size     int64
+	)
+
+	for i, entry := range entries {
+		for _, need := range entry.Needs {
+			neededBy[need] = append(neededBy[need], i)
+		}
+		size += entry.Size
+	}
+
+	var usedAt func(i int, seen map[int]bool) time.Time
+	usedAt = func(i int, seen map[int]bool) time.Time {
+		if seen[i] {
+			return time.Time{}
+		}
+		seen[i] = true
+		t := entries[i].LastUsed
+		for _, j := range neededBy[entries[i].Path] {
+			if u := usedAt(j, seen); u.After(t) {
+				t = u
+			}
+		}
+		return t
+	}
+	for i := range entries {
+		lastUsed[i] = usedAt(i, map[int]bool{})
+	}
+
+	var used func(i int, seen map[int]bool) bool
+	used = func(i int, seen map[int]bool) bool {
+		if seen[i] {
+			return false
+		}
+		seen[i] = true
+		if InUse(entries[i].Path) {
+			return true
+		}
+		for _, j := range neededBy[entries[i].Path] {
+			if used(j, seen) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var remove func(i int)
+	remove = func(i int) {
+		if removed[i] {
+			return
+		}
+		removed[i] = true
+		size -= entries[i].Size
+		result = append(result, entries[i])
+		for _, j := range neededBy[entries[i].Path] {
+			remove(j)
+		}
+	}
+
+	inUse := make([]bool, len(entries))
+	for i := range entries {
+		inUse[i] = used(i, map[int]bool{})
+	}
+
+	if opts.OlderThan > 0 {
+		cutoff := time.Now().Add(-opts.OlderThan)
+		for i := range entries {
+			if lastUsed[i].Before(cutoff) && !inUse[i] {
+				remove(i)
+			}
+		}
+	}
+
+	if opts.MaxSize > 0 && size > opts.MaxSize {
+		order := make([]int, len(entries))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return lastUsed[order[a]].Before(lastUsed[order[b]])
+		})
+		for _, i := range order {
+			if size <= opts.MaxSize {
+				break
+			}
+			if !inUse[i] {
+				remove(i)
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	var removedEntries []Entry
+	for _, entry := range result {
+		if err := os.RemoveAll(entry.Path); err != nil {
+			log.Errorf("failed to remove %s from the cache: %v", entry.Path, err)
+			continue
+		}
+		removedEntries = append(removedEntries, entry)
+	}
+	return removedEntries, nil
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		now  = time.Now()
+		old  = now.Add(-48 * time.Hour)
+		path = func(name string) string {
+			return filepath.Join(dir, name)
+		}
+	)
+
+	for _, name := range []string{"runtime", "old-tool", "new-tool", "unused"} {
+		require.NoError(t, os.Mkdir(path(name), 0755))
+	}
+
+	entries := []Entry{
+		{Kind: "runtime", Path: path("runtime"), Size: 100, LastUsed: old},
+		{Kind: "tool", Path: path("old-tool"), Size: 10, LastUsed: old, Needs: []string{path("runtime")}},
+		{Kind: "tool", Path: path("new-tool"), Size: 10, LastUsed: now, Needs: []string{path("runtime")}},
+		{Kind: "runtime", Path: path("unused"), Size: 50, LastUsed: old},
+	}
+
+	// The runtime is used by the new tool, so only the old tool and the unused runtime are old
+	removed, err := Prune(entries, PruneOptions{OlderThan: 24 * time.Hour, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{entries[1], entries[3]}, removed)
+	assert.DirExists(t, path("old-tool"))
+
+	// Removing the runtime to fit in the size removes the tools that need it
+	removed, err = Prune(entries, PruneOptions{MaxSize: 60})
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{entries[1], entries[3], entries[0], entries[2]}, removed)
+	for _, entry := range removed {
+		assert.NoDirExists(t, entry.Path)
+	}
+}
+
+func TestPruneInUse(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		old  = time.Now().Add(-48 * time.Hour)
+		path = func(name string) string {
+			return filepath.Join(dir, name)
+		}
+	)
+
+	for _, name := range []string{"runtime", "tool", "build"} {
+		require.NoError(t, os.Mkdir(path(name), 0755))
+	}
+
+	entries := []Entry{
+		{Kind: "runtime", Path: path("runtime"), Size: 100, LastUsed: old},
+		{Kind: "tool", Path: path("tool"), Size: 10, LastUsed: old, Needs: []string{path("runtime")}},
+		{Kind: "temp", Path: path("build"), Size: 10, LastUsed: old},
+	}
+
+	// The tool is in use, so it and the runtime it needs are kept even over the size
+	release := Use(path("tool"))
+	removed, err := Prune(entries, PruneOptions{OlderThan: 24 * time.Hour, MaxSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{entries[2]}, removed)
+	assert.DirExists(t, path("tool"))
+	assert.DirExists(t, path("runtime"))
+
+	release()
+	assert.False(t, InUse(path("tool")))
+	removed, err = Prune(entries[:2], PruneOptions{OlderThan: 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Len(t, removed, 2)
+}
@@ -0,0 +1,37 @@
+package cache
+
+import "sync"
+
+var (
+	usedLock sync.Mutex
+	used     = map[string]int{}
+)
+
+// Use marks the file or directory of an entry as in use until release is called, so Prune does not remove it. The
+// mark is seen by the Prune of other processes too, with a shared lock of the path, where locks are supported.
+func Use(path string) (release func()) {
+	usedLock.Lock()
+	used[path]++
+	usedLock.Unlock()
+
+	unlock := lockShared(path)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unlock()
+			usedLock.Lock()
+			defer usedLock.Unlock()
+			if used[path]--; used[path] <= 0 {
+				delete(used, path)
+			}
+		})
+	}
+}
+
+// InUse returns whether the path of an entry is marked as in use, by this process or another one.
+func InUse(path string) bool {
+	usedLock.Lock()
+	n := used[path]
+	usedLock.Unlock()
+	return n > 0 || locked(path)
+}
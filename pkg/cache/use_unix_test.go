@@ -0,0 +1,27 @@
+//go:build !windows
+
+package cache
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInUseLockedByOtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	assert.False(t, InUse(dir))
+
+	// The lock of another process is a lock of another open file, that is not marked in this process
+	f, err := os.Open(dir)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, syscall.Flock(int(f.Fd()), syscall.LOCK_SH))
+	assert.True(t, InUse(dir))
+
+	require.NoError(t, syscall.Flock(int(f.Fd()), syscall.LOCK_UN))
+	assert.False(t, InUse(dir))
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmd2 "github.com/acorn-io/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/gc"
+	"github.com/spf13/cobra"
+)
+
+type Cache struct {
+	root *GPTScript
+}
+
+func (c *Cache) Customize(cmd *cobra.Command) {
+	cmd.Use = "cache"
+	cmd.Short = "Show the size of the cache of tools, runtimes and model responses, and of the temporary files of runs"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&CachePrune{root: c.root}))
+}
+
+func (c *Cache) Run(*cobra.Command, []string) error {
+	entries, err := cacheEntries(c.root.CacheOptions)
+	if err != nil {
+		return err
+	}
+	return printEntries(entries)
+}
+
+type CachePrune struct {
+	root      *GPTScript
+	OlderThan string `usage:"Remove the entries that have not been used for longer than this, like 720h or 30d" local:"true"`
+	MaxSize   string `usage:"Remove the least recently used entries until the cache is at most this size, like 10GB" local:"true"`
+	DryRun    bool   `usage:"Report what would be removed without removing it" local:"true"`
+}
+
+func (c *CachePrune) Customize(cmd *cobra.Command) {
+	cmd.Use = "prune"
+	cmd.SilenceUsage = true
+	cmd.Short = "Remove the entries of the cache that are old or over its maximum size"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *CachePrune) Run(cmd *cobra.Command, _ []string) error {
+	if c.OlderThan == "" && c.MaxSize == "" {
+		return fmt.Errorf("set --older-than or --max-size to select the entries to remove")
+	}
+	return collect(cmd.Context(), c.root.CacheOptions, c.OlderThan, c.MaxSize, c.DryRun)
+}
+
+// gcOptions returns the options of the GC of the cache, with the age and size given like 30d and 10GB.
+func gcOptions(cacheOpts CacheOptions, olderThan, maxSize string, dryRun bool) (opts gc.Options, err error) {
+	opts.CacheDir = cache.Complete(cache.Options(cacheOpts)).CacheDir
+	opts.DryRun = dryRun
+	if olderThan != "" {
+		if opts.OlderThan, err = parseAge(olderThan); err != nil {
+			return opts, err
+		}
+	}
+	if maxSize != "" {
+		if opts.MaxSize, err = parseSize(maxSize); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// collect removes the entries of the cache that are older or over the size, and prints what it removed.
+func collect(ctx context.Context, cacheOpts CacheOptions, olderThan, maxSize string, dryRun bool) error {
+	opts, err := gcOptions(cacheOpts, olderThan, maxSize, dryRun)
+	if err != nil {
+		return err
+	}
+
+	removed, entries, err := gc.Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := printEntries(removed); err != nil {
+		return err
+	}
+
+	var reclaimed, total int64
+	for _, entry := range removed {
+		reclaimed += entry.Size
+	}
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	if dryRun {
+		fmt.Printf("\nWould reclaim %s, leaving %s\n", formatSize(reclaimed), formatSize(total-reclaimed))
+	} else {
+		fmt.Printf("\nReclaimed %s, leaving %s\n", formatSize(reclaimed), formatSize(total-reclaimed))
+	}
+	return nil
+}
+
+func cacheEntries(opts CacheOptions) ([]cache.Entry, error) {
+	return gc.Entries(cache.Complete(cache.Options(opts)).CacheDir, "")
+}
+
+// printEntries prints the number and size of the entries of each kind.
+func printEntries(entries []cache.Entry) error {
+	var (
+		kinds []string
+		count = map[string]int{}
+		size  = map[string]int64{}
+	)
+	for _, entry := range entries {
+		if _, ok := count[entry.Kind]; !ok {
+			kinds = append(kinds, entry.Kind)
+		}
+		count[entry.Kind]++
+		size[entry.Kind] += entry.Size
+	}
+	sort.Strings(kinds)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "KIND\tENTRIES\tSIZE")
+	for _, kind := range kinds {
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", kind, count[kind], formatSize(size[kind]))
+	}
+	return w.Flush()
+}
+
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB"}
+
+func formatSize(size int64) string {
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < len(sizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, sizeUnits[unit])
+}
+
+// parseSize parses sizes like 500MB or 10G, in units of 1024.
+func parseSize(s string) (int64, error) {
+	number := strings.TrimSpace(strings.ToUpper(s))
+	multiplier := int64(1)
+	for i := len(sizeUnits) - 1; i > 0; i-- {
+		if unit := sizeUnits[i]; strings.HasSuffix(number, unit) || strings.HasSuffix(number, unit[:1]) {
+			number = strings.TrimSuffix(strings.TrimSuffix(number, unit), unit[:1])
+			multiplier = 1 << (10 * i)
+			break
+		}
+	}
+	number = strings.TrimSpace(strings.TrimSuffix(number, "B"))
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q, use a size like 500MB or 10GB", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// parseAge parses durations like 720h, and days like 30d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(strings.TrimSpace(s), "d"); ok {
+		value, err := strconv.ParseFloat(days, 64)
+		if err == nil && value >= 0 {
+			return time.Duration(value * float64(24*time.Hour)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, use a duration like 720h or 30d", s)
+	}
+	return d, nil
+}
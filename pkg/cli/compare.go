@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/input"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/tokenizer"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+type CompareModels struct {
+	Models string `usage:"Comma separated list of the models to run the program with"`
+	Judge  string `usage:"The model that scores the output of each model from 1 to 10, no scores are given if not set"`
+
+	gptscript *GPTScript
+}
+
+func (c *CompareModels) Customize(cmd *cobra.Command) {
+	cmd.Use = "compare-models [flags] PROGRAM_FILE [INPUT...]"
+	cmd.Short = "Run a program with each of several models, and compare their outputs, latency and token usage"
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+// comparison is the result of running the program with one model
+type comparison struct {
+	Model     string        `json:"model"`
+	Output    string        `json:"output,omitempty"`
+	Err       string        `json:"err,omitempty"`
+	Latency   time.Duration `json:"latency"`
+	Calls     int           `json:"calls"`
+	Tokens    int           `json:"estimatedTokens"`
+	Score     int           `json:"score,omitempty"`
+	Reasoning string        `json:"reasoning,omitempty"`
+}
+
+func (c *CompareModels) Run(cmd *cobra.Command, args []string) error {
+	var models []string
+	for _, model := range strings.Split(c.Models, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("no models to compare, set them with --models")
+	}
+
+	opts, err := c.gptscript.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	usage := &usageMonitor{}
+	opts.Runner.MonitorFactory = usage
+
+	runner, err := gptscript.New(&opts)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+
+	prg, err := c.gptscript.readProgram(cmd.Context(), runner, args)
+	if err != nil {
+		return err
+	}
+
+	toolInput, err := input.FromCLI(c.gptscript.Input, args)
+	if err != nil {
+		return err
+	}
+
+	var results []comparison
+	for _, model := range models {
+		usage.reset(model)
+		start := time.Now()
+		out, err := runner.Run(c.gptscript.NewRunContext(cmd), withModel(prg, model), os.Environ(), toolInput)
+		result := comparison{
+			Model:   model,
+			Output:  out,
+			Latency: time.Since(start).Round(time.Millisecond),
+		}
+		result.Calls, result.Tokens = usage.get()
+		if err != nil {
+			result.Err = err.Error()
+		} else if c.Judge != "" {
+			result.Score, result.Reasoning, err = c.judge(cmd.Context(), runner, prg, toolInput, out)
+			if err != nil {
+				return fmt.Errorf("failed to score the output of %s: %w", model, err)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if c.gptscript.Output != "" && c.gptscript.Output != "-" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(c.gptscript.Output, data, 0644)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MODEL\tLATENCY\tCALLS\tTOKENS (ESTIMATED)\tSCORE")
+	for _, result := range results {
+		score := "-"
+		if result.Score > 0 {
+			score = fmt.Sprintf("%d/10", result.Score)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", result.Model, result.Latency, result.Calls, result.Tokens, score)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		fmt.Printf("\n--- %s\n", result.Model)
+		if result.Err != "" {
+			fmt.Printf("ERROR: %s\n", result.Err)
+			continue
+		}
+		fmt.Println(strings.TrimSpace(result.Output))
+		if result.Reasoning != "" {
+			fmt.Printf("\nScore %d/10: %s\n", result.Score, result.Reasoning)
+		}
+	}
+	return nil
+}
+
+// judge asks the judge model to score the output of the program from 1 to 10.
+func (c *CompareModels) judge(ctx context.Context, runner *gptscript.GPTScript, prg types.Program, toolInput, output string) (int, string, error) {
+	tool := types.Tool{
+		Parameters: types.Parameters{
+			Description:  "score the output of a program",
+			ModelName:    c.Judge,
+			JSONResponse: true,
+		},
+		Instructions: `You are judging how well a program did its task. The task is described by the instructions of the ` +
+			`program and its input. Score the output from 1, for an output that does not do the task at all, to 10, ` +
+			`for an output that does the task perfectly. Respond with a JSON object like {"score": 7, "reasoning": "..."} ` +
+			`with a one sentence reasoning.`,
+	}
+
+	judge, err := loader.ProgramFromSource(ctx, tool.String(), "")
+	if err != nil {
+		return 0, "", err
+	}
+
+	task, err := json.Marshal(map[string]string{
+		"instructions": prg.ToolSet[prg.EntryToolID].Instructions,
+		"input":        toolInput,
+		"output":       output,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	out, err := runner.Run(ctx, judge, os.Environ(), string(task))
+	if err != nil {
+		return 0, "", err
+	}
+
+	var score struct {
+		Score     int    `json:"score"`
+		Reasoning string `json:"reasoning"`
+	}
+	if err := json.Unmarshal([]byte(out), &score); err != nil {
+		return 0, "", fmt.Errorf("invalid score %q: %w", out, err)
+	}
+	return min(max(score.Score, 1), 10), score.Reasoning, nil
+}
+
+// withModel returns a copy of the program that calls the model from every tool, instead of the model of the tool.
+func withModel(prg types.Program, model string) types.Program {
+	toolSet := make(types.ToolSet, len(prg.ToolSet))
+	for id, tool := range prg.ToolSet {
+		if !tool.IsCommand() && !tool.ModelProvider {
+			tool.ModelName = model
+			tool.DraftModelName = ""
+		}
+		toolSet[id] = tool
+	}
+	prg.ToolSet = toolSet
+	return prg
+}
+
+// usageMonitor counts the completion requests of a run, and counts their tokens with the tokenizer of the model of the
+// run from the requests and responses, since the providers stream responses without their token usage.
+type usageMonitor struct {
+	lock   sync.Mutex
+	model  string
+	calls  int
+	tokens int
+}
+
+func (u *usageMonitor) Start(context.Context, *types.Program, []string, string) (runner.Monitor, error) {
+	return u, nil
+}
+
+func (u *usageMonitor) reset(model string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.model, u.calls, u.tokens = model, 0, 0
+}
+
+func (u *usageMonitor) get() (int, int) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.calls, u.tokens
+}
+
+func (u *usageMonitor) Event(event runner.Event) {
+	if event.Type != runner.EventTypeChat {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if event.ChatRequest != nil {
+		u.calls++
+		u.tokens += countTokens(u.model, event.ChatRequest)
+	}
+	if message, ok := event.ChatResponse.(types.CompletionMessage); ok {
+		u.tokens += countTokens(u.model, message.String())
+	} else if event.ChatResponse != nil {
+		u.tokens += countTokens(u.model, event.ChatResponse)
+	}
+}
+
+func (u *usageMonitor) Pause() func() {
+	return func() {}
+}
+
+func (u *usageMonitor) Stop(string, error) {}
+
+// countTokens counts the tokens of a value, as JSON if it is not a string, for the model.
+func countTokens(model string, v any) int {
+	s, ok := v.(string)
+	if !ok {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return 0
+		}
+		s = string(data)
+	}
+	return tokenizer.Count(model, s)
+}
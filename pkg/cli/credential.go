@@ -25,6 +25,8 @@ func (c *Credential) Customize(cmd *cobra.Command) {
 	cmd.Short = "List stored credentials"
 	cmd.Args = cobra.NoArgs
 	cmd.AddCommand(cmd2.Command(&Delete{root: c.root}))
+	cmd.AddCommand(cmd2.Command(&Export{root: c.root}))
+	cmd.AddCommand(cmd2.Command(&Import{root: c.root}))
 }
 
 func (c *Credential) Run(_ *cobra.Command, _ []string) error {
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const passphraseEnv = "GPTSCRIPT_CREDENTIAL_PASSPHRASE"
+
+type Export struct {
+	root     *GPTScript
+	Contexts []string `usage:"Contexts to export credentials from (default is the current credential context)" local:"true"`
+	File     string   `usage:"Write the encrypted credentials to this file instead of stdout" short:"f" local:"true"`
+}
+
+func (c *Export) Customize(cmd *cobra.Command) {
+	cmd.Use = "export"
+	cmd.SilenceUsage = true
+	cmd.Short = "Export stored credentials, encrypted with a passphrase"
+	cmd.Long = "Export stored credentials, encrypted with a passphrase that is read from " + passphraseEnv + " or prompted for"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *Export) Run(_ *cobra.Command, _ []string) error {
+	cfg, err := config.ReadCLIConfig(c.root.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CLI config: %w", err)
+	}
+
+	store, err := credentials.NewStore(cfg, "*")
+	if err != nil {
+		return fmt.Errorf("failed to get credentials store: %w", err)
+	}
+
+	creds, err := store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	contexts := c.Contexts
+	if len(contexts) == 0 {
+		contexts = []string{c.root.CredentialContext}
+	}
+	creds = slices.DeleteFunc(creds, func(cred credentials.Credential) bool {
+		return !slices.Contains(contexts, cred.Context)
+	})
+
+	passphrase, err := readPassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	data, err := credentials.Encrypt(creds, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if c.File != "" && c.File != "-" {
+		if err := os.WriteFile(c.File, data, 0600); err != nil {
+			return err
+		}
+	} else if _, err := os.Stdout.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Exported %d credentials\n", len(creds))
+	return nil
+}
+
+type Import struct {
+	root      *GPTScript
+	Overwrite bool `usage:"Overwrite existing credentials with the imported ones" local:"true"`
+}
+
+func (c *Import) Customize(cmd *cobra.Command) {
+	cmd.Use = "import <file>"
+	cmd.SilenceUsage = true
+	cmd.Short = "Import credentials exported with credential export (\"-\" for stdin)"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (c *Import) Run(_ *cobra.Command, args []string) error {
+	cfg, err := config.ReadCLIConfig(c.root.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CLI config: %w", err)
+	}
+
+	var data []byte
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		return err
+	}
+
+	creds, err := credentials.Decrypt(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	var imported, skipped int
+	for _, cred := range creds {
+		store, err := credentials.NewStore(cfg, cred.Context)
+		if err != nil {
+			return fmt.Errorf("failed to get credentials store: %w", err)
+		}
+
+		if !c.Overwrite {
+			if _, exists, err := store.Get(cred.ToolName); err != nil {
+				return fmt.Errorf("failed to get credential %s: %w", cred.ToolName, err)
+			} else if exists {
+				skipped++
+				continue
+			}
+		}
+
+		if err := store.Add(cred); err != nil {
+			return fmt.Errorf("failed to add credential %s: %w", cred.ToolName, err)
+		}
+		imported++
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "Imported %d credentials", imported)
+	if skipped > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, ", skipped %d that already exist (use --overwrite to replace them)", skipped)
+	}
+	_, _ = fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// readPassphrase reads the passphrase from the environment, or prompts for it on the terminal. When confirm is set,
+// the passphrase has to be entered twice.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("no passphrase set in %s and stdin is not a terminal to prompt for it", passphraseEnv)
+	}
+
+	_, _ = fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if confirm {
+		_, _ = fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(int(os.Stdin.Fd()))
+		_, _ = fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(passphrase) {
+			return "", fmt.Errorf("the passphrases do not match")
+		}
+	}
+
+	return string(passphrase), nil
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/builtin"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/gptscript-ai/gptscript/pkg/input"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+const explainPrompt = `You are reviewing a GPTScript program before it is run. You can not run the program or any of its tools.
+Describe, step by step and in plain language, which tools would most likely be invoked, in what order, and why.
+For each step call out anything with side effects, such as running commands, writing or removing files, or sending HTTP requests,
+and mention any credentials or environment variables the step would use. Finish with a short summary of the risks of running this program.
+`
+
+type Explain struct {
+	Model string `usage:"The model to use for the explanation (default is the model of the entry tool)"`
+
+	gptscript *GPTScript
+}
+
+func (e *Explain) Customize(cmd *cobra.Command) {
+	cmd.Use = "explain [flags] PROGRAM_FILE [INPUT...]"
+	cmd.Short = "Describe what a program would do without running any of its tools"
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+func (e *Explain) Run(cmd *cobra.Command, args []string) error {
+	opts, err := e.gptscript.NewGPTScriptOpts()
+	if err != nil {
+		return err
+	}
+
+	runner, err := gptscript.New(&opts)
+	if err != nil {
+		return err
+	}
+	defer runner.Close()
+
+	prg, err := e.gptscript.readProgram(cmd.Context(), runner, args)
+	if err != nil {
+		return err
+	}
+
+	toolInput, err := input.FromCLI(e.gptscript.Input, args)
+	if err != nil {
+		return err
+	}
+
+	out, err := runner.Run(e.gptscript.NewRunContext(cmd), explainProgram(prg, toolInput, e.Model), os.Environ(), "")
+	if err != nil {
+		return err
+	}
+
+	return e.gptscript.PrintOutput("", out)
+}
+
+// explainProgram returns the program that asks the model to explain prg, with the model of its entry tool unless
+// model is set.
+func explainProgram(prg types.Program, toolInput, model string) types.Program {
+	// The explanation tool has no tools of its own, so the model has no way to invoke anything
+	// from the program being explained.
+	explainTool := builtin.SetDefaults(types.Tool{
+		Parameters: types.Parameters{
+			Name:        "explain",
+			Description: "Explains what a program would do",
+			ModelName:   types.FirstSet(model, prg.ToolSet[prg.EntryToolID].ModelName),
+		},
+		ID:           "explain",
+		Instructions: explainInstructions(prg, toolInput),
+	})
+
+	return types.Program{
+		Name:        explainTool.Name,
+		EntryToolID: explainTool.ID,
+		ToolSet: types.ToolSet{
+			explainTool.ID: explainTool,
+		},
+	}
+}
+
+func explainInstructions(prg types.Program, toolInput string) string {
+	buf := &strings.Builder{}
+	buf.WriteString(explainPrompt)
+
+	if toolInput == "" {
+		buf.WriteString("\nThe program will be run with no input.\n")
+	} else {
+		_, _ = fmt.Fprintf(buf, "\nThe program will be run with the following input:\n%s\n", toolInput)
+	}
+
+	_, _ = fmt.Fprintf(buf, "\nThe entry tool is %s. These are the tools of the program:\n", prg.EntryToolID)
+
+	ids := make([]string, 0, len(prg.ToolSet))
+	for id := range prg.ToolSet {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		buf.WriteString("\n---\n")
+		buf.WriteString(describeTool(prg.ToolSet[id]))
+	}
+
+	return buf.String()
+}
+
+func describeTool(tool types.Tool) string {
+	buf := &strings.Builder{}
+	_, _ = fmt.Fprintf(buf, "ID: %s\n", tool.ID)
+
+	switch {
+	case tool.BuiltinFunc != nil:
+		buf.WriteString("Kind: built-in system tool\n")
+	case tool.IsOpenAPI():
+		buf.WriteString("Kind: HTTP request generated from an OpenAPI definition\n")
+		if inst, err := engine.GetOpenAPIInstructions(tool); err == nil {
+			_, _ = fmt.Fprintf(buf, "Request: %s %s%s\n", strings.ToUpper(inst.Method), inst.Server, inst.Path)
+		}
+	case tool.IsHTTP(), tool.IsDaemon():
+		buf.WriteString("Kind: HTTP request to a service\n")
+	case tool.IsPrint():
+		buf.WriteString("Kind: prints fixed content\n")
+	case tool.IsWorkflow():
+		buf.WriteString("Kind: workflow of steps that call tools in a fixed order\n")
+	case tool.IsCommand():
+		buf.WriteString("Kind: runs a command on the local machine\n")
+	default:
+		buf.WriteString("Kind: prompt sent to the model\n")
+	}
+
+	cp := tool
+	if cp.BuiltinFunc != nil || cp.IsOpenAPI() {
+		// The instructions of these tools are not useful to describe the program
+		cp.Instructions = ""
+	}
+	buf.WriteString(cp.String())
+
+	return buf.String()
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockModel records the requests it is called with, and answers them with a fixed explanation
+type mockModel struct {
+	requests []types.CompletionRequest
+}
+
+func (m *mockModel) Call(_ context.Context, messageRequest types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	m.requests = append(m.requests, messageRequest)
+	return &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text("It reads the notes and removes them."),
+	}, nil
+}
+
+func TestExplain(t *testing.T) {
+	cli := &GPTScript{}
+	g := newGPTScript(t, cli)
+
+	toolFile := filepath.Join("testdata", "explain", "tool.gpt")
+	prg, err := cli.readProgram(context.Background(), g, []string{toolFile})
+	require.NoError(t, err)
+
+	model := &mockModel{}
+	r, err := runner.New(model, "default")
+	require.NoError(t, err)
+	defer r.Close()
+
+	out, err := r.Run(context.Background(), explainProgram(prg, "notes", ""), nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "It reads the notes and removes them.", out)
+
+	// The model is asked once, with the model of the entry tool and none of the tools of the program
+	require.Len(t, model.requests, 1)
+	req := model.requests[0]
+	assert.Equal(t, "gpt-4o", req.Model)
+	assert.Empty(t, req.Tools)
+
+	require.NotEmpty(t, req.Messages)
+	prompt := req.Messages[0].Content[0].Text
+	assert.True(t, strings.HasPrefix(prompt, explainPrompt))
+	assert.Contains(t, prompt, "The program will be run with the following input:\nnotes\n")
+	assert.Contains(t, prompt, "The entry tool is "+prg.EntryToolID+".")
+	assert.Contains(t, prompt, "Kind: built-in system tool")
+	assert.Contains(t, prompt, "Kind: runs a command on the local machine")
+	assert.Contains(t, prompt, "rm -rf ${dir}")
+
+	// The model of the explanation can be chosen
+	_, err = r.Run(context.Background(), explainProgram(prg, "", "claude-3-5-sonnet"), nil, "")
+	require.NoError(t, err)
+	require.Len(t, model.requests, 2)
+	assert.Equal(t, "claude-3-5-sonnet", model.requests[1].Model)
+	assert.Contains(t, model.requests[1].Messages[0].Content[0].Text, "The program will be run with no input.")
+}
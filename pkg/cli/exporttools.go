@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+type ExportTools struct {
+	Format string `usage:"The format of the tool definitions, openai or anthropic" default:"openai"`
+	Self   bool   `usage:"Export the program itself as a tool, instead of the tools it can call"`
+
+	gptscript *GPTScript
+}
+
+func (e *ExportTools) Customize(cmd *cobra.Command) {
+	cmd.Use = "export-tools [flags] PROGRAM_FILE"
+	cmd.Short = "Print the tools of a program as the tool definitions of a model provider"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+type openAITool struct {
+	Type     string             `json:"type"`
+	Function openAIToolFunction `json:"function"`
+}
+
+type openAIToolFunction struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Parameters  *openapi3.Schema `json:"parameters"`
+}
+
+type anthropicTool struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	InputSchema *openapi3.Schema `json:"input_schema"`
+}
+
+func (e *ExportTools) Run(cmd *cobra.Command, args []string) error {
+	// The program is only loaded, so it is loaded without a runner
+	transport, err := tlsconfig.Options(e.gptscript.TLSOptions).Transport()
+	if err != nil {
+		return err
+	}
+
+	cacheClient, err := cache.New(cache.Options(e.gptscript.CacheOptions), cache.Options{Transport: transport})
+	if err != nil {
+		return err
+	}
+
+	opts := loader.Options(e.gptscript.LoaderOptions)
+	opts.Cache = cacheClient
+	opts.Transport = transport
+
+	prg, err := loader.Program(cmd.Context(), args[0], e.gptscript.SubTool, opts)
+	if err != nil {
+		return err
+	}
+
+	return e.export(os.Stdout, prg)
+}
+
+// export writes the tool definitions of the program to out in the format of the model provider
+func (e *ExportTools) export(out io.Writer, prg types.Program) error {
+	var (
+		tools []types.CompletionTool
+		err   error
+	)
+	if e.Self {
+		tools, err = prg.GetCompletionTools()
+	} else {
+		tools, err = prg.ToolSet[prg.EntryToolID].GetCompletionTools(prg)
+	}
+	if err != nil {
+		return err
+	}
+
+	var result []any
+	for _, tool := range tools {
+		parameters := tool.Function.Parameters
+		if parameters == nil {
+			// Both providers require a schema, so tools without arguments get an empty one
+			parameters = &openapi3.Schema{
+				Type:       "object",
+				Properties: openapi3.Schemas{},
+			}
+		}
+
+		switch e.Format {
+		case "openai":
+			result = append(result, openAITool{
+				Type: "function",
+				Function: openAIToolFunction{
+					Name:        tool.Function.Name,
+					Description: tool.Function.Description,
+					Parameters:  parameters,
+				},
+			})
+		case "anthropic":
+			result = append(result, anthropicTool{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				InputSchema: parameters,
+			})
+		default:
+			return fmt.Errorf("invalid format %q, must be openai or anthropic", e.Format)
+		}
+	}
+
+	if result == nil {
+		result = []any{}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTools(t *testing.T) {
+	prg, err := loader.Program(context.Background(), filepath.Join("testdata", "exporttools", "tool.gpt"), "")
+	require.NoError(t, err)
+
+	for _, format := range []string{"openai", "anthropic"} {
+		t.Run(format, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			require.NoError(t, (&ExportTools{Format: format}).export(out, prg))
+
+			golden, err := os.ReadFile(filepath.Join("testdata", "exporttools", format+".golden"))
+			require.NoError(t, err)
+			assert.Equal(t, string(golden), out.String())
+		})
+	}
+
+	err = (&ExportTools{Format: "gemini"}).export(&bytes.Buffer{}, prg)
+	assert.ErrorContains(t, err, `invalid format "gemini"`)
+}
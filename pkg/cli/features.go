@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	cmd2 "github.com/acorn-io/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/features"
+	"github.com/spf13/cobra"
+)
+
+type Features struct {
+	root *GPTScript
+}
+
+func (f *Features) Customize(cmd *cobra.Command) {
+	cmd.Use = "features"
+	cmd.Short = "List the feature flags of experimental behaviors, and whether they are enabled"
+	cmd.Long = `List the feature flags of experimental behaviors, and whether they are enabled.
+
+Feature flags are enabled in the config file with gptscript features enable, and for a run with --features or
+GPTSCRIPT_FEATURES, like --features bounded-parallelism,tool-result-tags=false, which override the config file. The
+flags of a run are reported in the callStart event of its first call.`
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(
+		cmd2.Command(&FeaturesEnable{root: f.root}),
+		cmd2.Command(&FeaturesDisable{root: f.root}),
+	)
+}
+
+func (f *Features) Run(*cobra.Command, []string) error {
+	flags, err := f.root.features()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tENABLED\tDESCRIPTION")
+	for _, flag := range features.Known() {
+		_, _ = fmt.Fprintf(w, "%s\t%t\t%s\n", flag.Name, flags.Enabled(flag.Name), flag.Description)
+	}
+	return w.Flush()
+}
+
+type FeaturesEnable struct {
+	root *GPTScript
+}
+
+func (f *FeaturesEnable) Customize(cmd *cobra.Command) {
+	cmd.Use = "enable FEATURE"
+	cmd.Short = "Enable a feature flag in the config file"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (f *FeaturesEnable) Run(_ *cobra.Command, args []string) error {
+	return setFeature(f.root, args[0], true)
+}
+
+type FeaturesDisable struct {
+	root *GPTScript
+}
+
+func (f *FeaturesDisable) Customize(cmd *cobra.Command) {
+	cmd.Use = "disable FEATURE"
+	cmd.Short = "Disable a feature flag in the config file"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (f *FeaturesDisable) Run(_ *cobra.Command, args []string) error {
+	return setFeature(f.root, args[0], false)
+}
+
+func setFeature(root *GPTScript, name string, enabled bool) error {
+	// Parse checks that the flag is known
+	if _, err := features.Parse(name); err != nil {
+		return err
+	}
+
+	cfg, err := config.ReadCLIConfig(root.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if cfg.Features == nil {
+		cfg.Features = map[string]bool{}
+	}
+	cfg.Features[name] = enabled
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+
+	if enabled {
+		fmt.Printf("Feature %s is enabled\n", name)
+	} else {
+		fmt.Printf("Feature %s is disabled\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type GC struct {
+	root      *GPTScript
+	OlderThan string `usage:"Remove the entries that have not been used for longer than this, like 720h or 30d" default:"30d" local:"true"`
+	MaxSize   string `usage:"Remove the least recently used entries until the cache is at most this size, like 10GB" local:"true"`
+	DryRun    bool   `usage:"Report what would be removed without removing it" local:"true"`
+}
+
+func (c *GC) Customize(cmd *cobra.Command) {
+	cmd.Use = "gc"
+	cmd.SilenceUsage = true
+	cmd.Short = "Remove the tools, repositories, runtimes and model responses of the cache that are old or over its maximum size, and the temporary files runs left behind"
+	cmd.Args = cobra.NoArgs
+}
+
+func (c *GC) Run(cmd *cobra.Command, _ []string) error {
+	return collect(cmd.Context(), c.root.CacheOptions, c.OlderThan, c.MaxSize, c.DryRun)
+}
@@ -3,12 +3,14 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/acorn-io/cmd"
 	"github.com/fatih/color"
@@ -16,15 +18,28 @@ import (
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/chat"
+	"github.com/gptscript-ai/gptscript/pkg/config"
 	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/features"
+	"github.com/gptscript-ai/gptscript/pkg/gc"
 	"github.com/gptscript-ai/gptscript/pkg/gptscript"
 	"github.com/gptscript-ai/gptscript/pkg/input"
 	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/monitor"
 	"github.com/gptscript-ai/gptscript/pkg/mvl"
 	"github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/record"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes"
+	"github.com/gptscript-ai/gptscript/pkg/review"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
 	"github.com/gptscript-ai/gptscript/pkg/server"
+	"github.com/gptscript-ai/gptscript/pkg/snapshot"
+	"github.com/gptscript-ai/gptscript/pkg/step"
+	"github.com/gptscript-ai/gptscript/pkg/telemetry"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/usage"
+	"github.com/gptscript-ai/gptscript/pkg/vars"
 	"github.com/gptscript-ai/gptscript/pkg/version"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -35,12 +50,16 @@ type (
 	DisplayOptions monitor.Options
 	CacheOptions   cache.Options
 	OpenAIOptions  openai.Options
+	LoaderOptions  loader.Options
+	TLSOptions     tlsconfig.Options
 )
 
 type GPTScript struct {
 	CacheOptions
 	OpenAIOptions
 	DisplayOptions
+	LoaderOptions
+	TLSOptions
 	Color              *bool  `usage:"Use color in output (default true)" default:"true"`
 	Confirm            bool   `usage:"Prompt before running potentially dangerous commands"`
 	Debug              bool   `usage:"Enable debug logging"`
@@ -48,12 +67,20 @@ type GPTScript struct {
 	Output             string `usage:"Save output to a file, or - for stdout" short:"o"`
 	EventsStreamTo     string `usage:"Stream events to this location, could be a file descriptor/handle (e.g. fd://2), filename, or named pipe (e.g. \\\\.\\pipe\\my-pipe)" name:"events-stream-to"`
 	Input              string `usage:"Read input from a file (\"-\" for stdin)" short:"f"`
-	SubTool            string `usage:"Use tool of this name, not the first tool in file" local:"true"`
+	SubTool            string `usage:"Use tool of this name, not the first tool in file. Tools of the other files of the program can be used too, like \"parse from lib.gpt\"" local:"true"`
 	Assemble           bool   `usage:"Assemble tool to a single artifact, saved to --output" hidden:"true" local:"true"`
 	ListModels         bool   `usage:"List the models available and exit" local:"true"`
 	ListTools          bool   `usage:"List built-in tools and exit" local:"true"`
 	Server             bool   `usage:"Start server" local:"true"`
-	ListenAddress      string `usage:"Server listen address" default:"127.0.0.1:9090" local:"true"`
+	ListenAddress      string `usage:"Server listen address, localhost is the loopback address of --address-family" default:"localhost:9090" local:"true"`
+	Watch              bool   `usage:"Reload the programs the server loaded as soon as their files change" local:"true"`
+	GCOlderThan        string `usage:"With --server, remove the entries of the cache that have not been used for longer than this every hour, like 30d" local:"true"`
+	GCMaxSize          string `usage:"With --server, remove the least recently used entries of the cache every hour until it is at most this size, like 10GB" local:"true"`
+	MaxRuns            int    `usage:"With --server, the number of runs that run at a time, the other runs wait and runs of a lower priority pause for them" local:"true"`
+	DeadLetterDir      string `usage:"With --server, the directory the runs that fail are saved to, to retry them with POST /sys/dead-letters/<id>/retry, they are not saved by default" local:"true"`
+	DeadLetterMax      int    `usage:"With --server, the number of runs that fail that are kept in --dead-letter-dir, the oldest are removed" default:"100" local:"true"`
+	DeadLetterMaxAge   string `usage:"With --server, how long the runs that fail are kept in --dead-letter-dir, like 30d" default:"7d" local:"true"`
+	AddressFamily      string `usage:"Address family of the loopback address the server and daemons listen on (valid: ipv4, ipv6), by default 127.0.0.1 if available and ::1 otherwise"`
 	Chdir              string `usage:"Change current working directory" short:"C"`
 	Daemon             bool   `usage:"Run tool as a daemon" local:"true" hidden:"true"`
 	Ports              string `usage:"The port range to use for ephemeral daemon ports (ex: 11000-12000)" hidden:"true"`
@@ -61,15 +88,68 @@ type GPTScript struct {
 	CredentialOverride string `usage:"Credentials to override (ex: --credential-override github.com/example/cred-tool:API_TOKEN=1234)"`
 	ChatState          string `usage:"The chat state to continue, or null to start a new chat and return the state"`
 	ForceChat          bool   `usage:"Force an interactive chat session if even the top level tool is not a chat tool"`
+	ModelTemplates     string `usage:"A YAML file of templates that render the requests to families of models, like models fine-tuned with their own tool call format"`
+	LocalModels        string `usage:"A YAML file of models served by local inference servers, like llama.cpp or vLLM, that are started when the models are used"`
+	DisableStream      bool   `usage:"Print the output only once the run is done, instead of streaming it as the model writes it"`
+	ContainerRuntime   string `usage:"The command that runs the tools that have a container, like docker or podman" default:"docker"`
+	Sandbox            bool   `usage:"Run commands in a sandbox where they can only write the working directory and can not use the network, unless their tool declares it"`
+	SandboxImage       string `usage:"The container image that sandboxed commands run in when the OS has no sandbox" default:"alpine"`
+	Snapshot           bool   `usage:"Snapshot the working directory before the run, and restore it if the run fails or its changes are rejected"`
+	GitReview          bool   `usage:"Commit the changes of the run to a review branch of the git repository of the working directory, see gptscript review"`
+	DisableArgCoercion bool   `usage:"Pass the arguments that models call tools with as they are, instead of converting them to the types of the arguments of the tools, like \"5\" to 5"`
+	MaxParallelCalls   int    `usage:"The number of the tool calls that a model responds with at once that run at a time (default: all of them)" env:"GPTSCRIPT_MAX_PARALLEL_CALLS"`
+	MaxCallDepth       int    `usage:"Interrupt the run when a tool call is deeper than this in the calls of tools calling tools, 0 for no limit" default:"50" env:"GPTSCRIPT_MAX_CALL_DEPTH"`
+	MaxRepeatedCalls   int    `usage:"Interrupt the run when a model calls a tool with the same arguments more times than this, 0 for no limit" default:"10" env:"GPTSCRIPT_MAX_REPEATED_CALLS"`
+	Features           string `usage:"Comma separated feature flags of experimental behaviors to enable, like bounded-parallelism or tool-result-tags=false, see gptscript features" env:"GPTSCRIPT_FEATURES"`
+	Timeout            string `usage:"Cancel the run if it does not finish within this duration, like 10m" local:"true"`
+	DebugStep          bool   `usage:"Pause before every call to a model and every tool call, to show what is sent and continue, skip, edit or abort it" local:"true"`
+	DryRun             bool   `usage:"Do not run commands, writes of files and requests that are not GETs, tell the model what they would have run instead, to preview what a script does"`
+	Record             string `usage:"Record the responses of the models and the outputs of the tools of the run to this file, to replay the run with --replay" local:"true"`
+	Replay             string `usage:"Replay the run from the recording of --record in this file, instead of calling models and running tools" local:"true"`
+	JSONL              bool   `usage:"Print a JSON line as soon as each tool call and workflow step of the entry tool finishes, with its id, status, output and usage, and one for the run when it is done, to stdout or --output" name:"jsonl" local:"true"`
+	EventLog           string `usage:"Append every event of the runs and the confirmations of their calls as versioned JSON lines to this file or named pipe, for analysis and auditing" env:"GPTSCRIPT_EVENT_LOG"`
+	Webhook            string `usage:"Post the start and finish of the runs, the calls of their tools and the confirmations they need as JSON to this URL, like a Slack incoming webhook" env:"GPTSCRIPT_WEBHOOK"`
+	WebhookSecret      string `usage:"The secret that the events of --webhook are signed with, in their X-GPTScript-Signature header" env:"GPTSCRIPT_WEBHOOK_SECRET"`
+	Redact             string `usage:"What to redact from the events of each sink, like events-stream=bodies,server=arguments (sinks: display, events-stream, server, event-log, webhook; valid: none, arguments, bodies)" env:"GPTSCRIPT_REDACT"`
+	Prices             string `usage:"A YAML file of the prices of models in dollars per million input and output tokens, for the costs of --usage-report and the runUsage events" env:"GPTSCRIPT_PRICES"`
+	Checkpoint         bool   `usage:"Save the state of the run as it goes, so a run that crashes or is interrupted can be resumed with gptscript resume" env:"GPTSCRIPT_CHECKPOINT"`
+	FSRoot             string `usage:"The directory that file tools, like sys.read and sys.write, can only use the files in, unless the calling tool declares its allowed paths (default: the working directory)"`
+	// Var is repeated for every variable, like --var region=us-east-1 --var stage=prod
+	Var []string `usage:"A variable of the run as name=value, that command tools get as the GPTSCRIPT_VAR_<name> environment variable and models read with sys.var.get" split:"false"`
 
 	readData []byte
+	// aliasReference is the reference to the script that is run by the name it was installed as
+	aliasReference string
+	// linker loads the program, and reloads it on every chat turn without reading the files that did not change
+	linker loader.Linker
+	// telemetryFeatures are the features of the program that was run, which are recorded if telemetry is enabled
+	telemetryFeatures []string
+	// resumed is the paused run that gptscript resume resumes
+	resumed *pausedRun
+	// newRunID is the ID that the state of a run that was not resumed is saved with
+	newRunID string
+	// recorder and replay record the run to --record and replay it from --replay
+	recorder *record.Recorder
+	replay   *record.Replay
+	// eventLog is the log of --event-log, which the confirmations of the calls are written to too
+	eventLog *monitor.EventLog
+	// webhook is the webhook of --webhook, which the confirmations that are needed are posted to too
+	webhook *monitor.Webhook
 }
 
 func New() *cobra.Command {
 	root := &GPTScript{}
 	command := cmd.Command(root, &Eval{
 		gptscript: root,
-	}, &Credential{root: root})
+	}, &Credential{root: root}, &Explain{
+		gptscript: root,
+	}, &ExportTools{
+		gptscript: root,
+	}, &CompareModels{
+		gptscript: root,
+	}, &PushTools{}, &Search{}, &Vendor{
+		gptscript: root,
+	}, &Cache{root: root}, &Install{root: root}, &SelfUpdate{}, &Telemetry{root: root}, &GC{root: root}, &Snapshots{root: root}, &Review{}, &Resume{root: root}, &Features{root: root})
 
 	// Hide all the global flags for the credential subcommand.
 	for _, child := range command.Commands() {
@@ -104,13 +184,32 @@ func New() *cobra.Command {
 		}
 	}
 
+	recordTelemetry(root, command)
 	return command
 }
 
 func (r *GPTScript) NewRunContext(cmd *cobra.Command) context.Context {
 	ctx := cmd.Context()
 	if r.Confirm {
-		ctx = confirm.WithConfirm(ctx, confirm.TextPrompt{})
+		var c confirm.Confirm = confirm.TextPrompt{}
+		if r.eventLog != nil {
+			c = r.eventLog.Confirm(c)
+		}
+		if r.webhook != nil {
+			c = r.webhook.Confirm(c)
+		}
+		if r.TUI {
+			c = monitor.PauseConfirm(c)
+		}
+		ctx = confirm.WithConfirm(ctx, c)
+	}
+	if r.DebugStep {
+		ctx = step.WithStepper(ctx, &terminalStepper{})
+	}
+	if r.replay != nil {
+		ctx = record.WithReplay(ctx, r.replay)
+	} else if r.recorder != nil {
+		ctx = record.WithRecorder(ctx, r.recorder)
 	}
 	return ctx
 }
@@ -120,9 +219,16 @@ func (r *GPTScript) NewGPTScriptOpts() (gptscript.Options, error) {
 		Cache:             cache.Options(r.CacheOptions),
 		OpenAI:            openai.Options(r.OpenAIOptions),
 		Monitor:           monitor.Options(r.DisplayOptions),
+		TLS:               tlsconfig.Options(r.TLSOptions),
 		Quiet:             r.Quiet,
 		Env:               os.Environ(),
 		CredentialContext: r.CredentialContext,
+		ModelTemplates:    r.ModelTemplates,
+		LocalModels:       r.LocalModels,
+	}
+	if r.DebugStep {
+		// The prompts of the steps would be drawn over by the tree
+		opts.Monitor.TUI = false
 	}
 
 	if r.Ports != "" {
@@ -143,6 +249,41 @@ func (r *GPTScript) NewGPTScriptOpts() (gptscript.Options, error) {
 	}
 
 	opts.Runner.CredentialOverride = r.CredentialOverride
+	opts.Runner.AddressFamily = r.AddressFamily
+	opts.Runner.ContainerRuntime = r.ContainerRuntime
+	opts.Runner.Sandbox = r.Sandbox
+	opts.Runner.SandboxImage = r.SandboxImage
+	opts.Runner.FSRoot = r.FSRoot
+	opts.Runner.DisableArgCoercion = r.DisableArgCoercion
+	opts.Runner.MaxParallelToolCalls = r.MaxParallelCalls
+	opts.Runner.MaxCallDepth = r.MaxCallDepth
+	opts.Runner.MaxRepeatedCalls = r.MaxRepeatedCalls
+	opts.Runner.DryRun = r.DryRun
+
+	if r.Prices != "" {
+		prices, err := usage.LoadPrices(r.Prices)
+		if err != nil {
+			return gptscript.Options{}, err
+		}
+		opts.Runner.Prices = prices
+	}
+
+	flags, err := r.features()
+	if err != nil {
+		return gptscript.Options{}, err
+	}
+	opts.Runner.Features = flags
+
+	opts.Runner.Vars, err = vars.Parse(r.Var)
+	if err != nil {
+		return gptscript.Options{}, err
+	}
+
+	redactions, err := monitor.ParseRedactions(r.Redact)
+	if err != nil {
+		return gptscript.Options{}, err
+	}
+	opts.Monitor.Redaction = redactions.For(monitor.SinkDisplay)
 
 	if r.EventsStreamTo != "" {
 		mf, err := monitor.NewFileFactory(r.EventsStreamTo)
@@ -150,12 +291,51 @@ func (r *GPTScript) NewGPTScriptOpts() (gptscript.Options, error) {
 			return gptscript.Options{}, err
 		}
 
-		opts.Runner.MonitorFactory = mf
+		opts.Runner.MonitorFactory = monitor.Redact(mf, redactions.For(monitor.SinkEventsStream))
+	}
+
+	if r.EventLog != "" {
+		if r.eventLog == nil {
+			r.eventLog, err = monitor.NewEventLog(r.EventLog, redactions.For(monitor.SinkEventLog))
+			if err != nil {
+				return gptscript.Options{}, err
+			}
+		}
+
+		opts.Runner.MonitorFactory = r.eventLog.Wrap(opts.Runner.MonitorFactory, opts.Monitor, monitor.Options{
+			DisplayProgress: !*r.Quiet,
+		})
+	}
+
+	if r.Webhook != "" {
+		if r.webhook == nil {
+			r.webhook, err = monitor.NewWebhook(r.Webhook, r.WebhookSecret, redactions.For(monitor.SinkWebhook))
+			if err != nil {
+				return gptscript.Options{}, err
+			}
+		}
+
+		opts.Runner.MonitorFactory = r.webhook.Wrap(opts.Runner.MonitorFactory, opts.Monitor, monitor.Options{
+			DisplayProgress: !*r.Quiet,
+		})
 	}
 
 	return opts, nil
 }
 
+// features returns the feature flags of the config file, overridden by the ones of --features.
+func (r *GPTScript) features() (features.Set, error) {
+	cfg, err := config.ReadCLIConfig(r.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := features.Parse(r.Features)
+	if err != nil {
+		return nil, err
+	}
+	return features.Set(cfg.Features).Merge(flags), nil
+}
+
 func (r *GPTScript) Customize(cmd *cobra.Command) {
 	cmd.Flags().SetInterspersed(false)
 	cmd.Use = version.ProgramName + " [flags] PROGRAM_FILE [INPUT...]"
@@ -196,7 +376,14 @@ func (r *GPTScript) listTools(ctx context.Context, gptScript *gptscript.GPTScrip
 	return nil
 }
 
-func (r *GPTScript) PersistentPre(*cobra.Command, []string) error {
+func (r *GPTScript) PersistentPre(cmd *cobra.Command, args []string) error {
+	// The flags of installed scripts are set before any flag is used
+	if cmd == cmd.Root() {
+		if err := r.expandAlias(cmd, args); err != nil {
+			return err
+		}
+	}
+
 	// chdir as soon as possible
 	if r.Chdir != "" {
 		if err := os.Chdir(r.Chdir); err != nil {
@@ -204,6 +391,13 @@ func (r *GPTScript) PersistentPre(*cobra.Command, []string) error {
 		}
 	}
 
+	// The outbound connections of the commands, like loading programs, are sent with the transport of the context
+	transport, err := tlsconfig.Options(r.TLSOptions).Transport()
+	if err != nil {
+		return err
+	}
+	cmd.SetContext(tlsconfig.WithTransport(cmd.Context(), transport))
+
 	if r.DefaultModel != "" {
 		builtin.SetDefaultModel(r.DefaultModel)
 	}
@@ -251,12 +445,26 @@ func (r *GPTScript) listModels(ctx context.Context, gptScript *gptscript.GPTScri
 	return nil
 }
 
-func (r *GPTScript) readProgram(ctx context.Context, args []string) (prg types.Program, err error) {
+func (r *GPTScript) readProgram(ctx context.Context, gptScript *gptscript.GPTScript, args []string) (prg types.Program, err error) {
 	if len(args) == 0 {
 		return
 	}
 
+	cacheClient, err := cache.New(cache.Options(r.CacheOptions), cache.Options{Transport: gptScript.Transport()})
+	if err != nil {
+		return prg, err
+	}
+
+	opts := loader.Options(r.LoaderOptions)
+	opts.Transport = gptScript.Transport()
+	opts.Cache = cacheClient
+	opts.Monitor = gptScript.LoaderMonitor()
+
 	if args[0] == "-" {
+		if r.Input == "-" {
+			return prg, fmt.Errorf("the program and its input can not both be read from stdin")
+		}
+
 		var (
 			data []byte
 			err  error
@@ -270,10 +478,10 @@ func (r *GPTScript) readProgram(ctx context.Context, args []string) (prg types.P
 			}
 			r.readData = data
 		}
-		return loader.ProgramFromSource(ctx, string(data), r.SubTool)
+		return loader.ProgramFromSource(ctx, string(data), r.SubTool, opts)
 	}
 
-	return loader.Program(ctx, args[0], r.SubTool)
+	return r.linker.Program(ctx, args[0], r.SubTool, opts)
 }
 
 func (r *GPTScript) PrintOutput(toolInput, toolOutput string) (err error) {
@@ -283,13 +491,7 @@ func (r *GPTScript) PrintOutput(toolInput, toolOutput string) (err error) {
 			return err
 		}
 	} else {
-		if !*r.Quiet {
-			if toolInput != "" {
-				_, _ = fmt.Fprint(os.Stderr, "\nINPUT:\n\n")
-				_, _ = fmt.Fprintln(os.Stderr, toolInput)
-			}
-			_, _ = fmt.Fprint(os.Stderr, "\nOUTPUT:\n\n")
-		}
+		r.printHeader(toolInput)
 		fmt.Print(toolOutput)
 		if !strings.HasSuffix(toolOutput, "\n") {
 			fmt.Println()
@@ -299,18 +501,82 @@ func (r *GPTScript) PrintOutput(toolInput, toolOutput string) (err error) {
 	return
 }
 
+func (r *GPTScript) printHeader(toolInput string) {
+	if !*r.Quiet {
+		if toolInput != "" {
+			_, _ = fmt.Fprint(os.Stderr, "\nINPUT:\n\n")
+			_, _ = fmt.Fprintln(os.Stderr, toolInput)
+		}
+		_, _ = fmt.Fprint(os.Stderr, "\nOUTPUT:\n\n")
+	}
+}
+
+// streamOutput returns true if the output of a run is streamed to stdout, which is only done when the output is
+// printed as is, and the progress of the run is displayed.
+func (r *GPTScript) streamOutput() bool {
+	// The output streamed as the model writes it would redraw over the prompts of --debug-step
+	return !r.DisableStream && !*r.Quiet && r.Output == "" && r.ChatState == "" && !r.Server && !r.Daemon && !r.DebugStep && !r.JSONL && !r.TUI
+}
+
 func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
+	if r.aliasReference != "" {
+		args = append([]string{r.aliasReference}, args[1:]...)
+	}
+
+	if r.Record != "" && r.Replay != "" {
+		// A replay does not call the models and run the tools, so it would only record the recording it replays
+		return fmt.Errorf("--record can not be used with --replay")
+	}
+
 	gptOpt, err := r.NewGPTScriptOpts()
 	if err != nil {
 		return err
 	}
 
 	ctx := cmd.Context()
+	stopNotice := context.AfterFunc(ctx, func() {
+		log.Infof("Canceling the run, press Ctrl+C again to exit now")
+	})
+	defer stopNotice()
+
+	if r.Offline && len(args) > 0 {
+		// Tools in repositories are run from the vendor directory, where they were set up by gptscript vendor
+		gptOpt.Runner.RuntimeManager = runtimes.Offline(loader.VendorPath(args[0], r.VendorDir))
+	}
 
 	if r.Server {
+		var gcOpts *gc.Options
+		if r.GCOlderThan != "" || r.GCMaxSize != "" {
+			opts, err := gcOptions(r.CacheOptions, r.GCOlderThan, r.GCMaxSize, false)
+			if err != nil {
+				return err
+			}
+			gcOpts = &opts
+		}
+
+		redactions, err := monitor.ParseRedactions(r.Redact)
+		if err != nil {
+			return err
+		}
+
+		var deadLetterMaxAge time.Duration
+		if r.DeadLetterMaxAge != "" {
+			if deadLetterMaxAge, err = parseAge(r.DeadLetterMaxAge); err != nil {
+				return err
+			}
+		}
+
 		s, err := server.New(&server.Options{
-			ListenAddress: r.ListenAddress,
-			GPTScript:     gptOpt,
+			Redaction:        redactions.For(monitor.SinkServer),
+			Confirm:          r.Confirm,
+			ListenAddress:    r.ListenAddress,
+			Watch:            r.Watch,
+			GC:               gcOpts,
+			MaxRuns:          r.MaxRuns,
+			DeadLetterDir:    r.DeadLetterDir,
+			DeadLetterMax:    r.DeadLetterMax,
+			DeadLetterMaxAge: deadLetterMaxAge,
+			GPTScript:        gptOpt,
 		})
 		if err != nil {
 			return err
@@ -319,6 +585,37 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 		return s.Start(ctx)
 	}
 
+	var lines *jsonLines
+	if r.JSONL {
+		var out io.Writer = os.Stdout
+		if r.Output != "" && r.Output != "-" {
+			f, err := os.Create(r.Output)
+			if err != nil {
+				return fmt.Errorf("opening %s: %w", r.Output, err)
+			}
+			defer f.Close()
+			out = f
+		}
+		if gptOpt.Runner.MonitorFactory == nil {
+			gptOpt.Runner.MonitorFactory = monitor.NewConsole(gptOpt.Monitor, monitor.Options{
+				DisplayProgress: !*r.Quiet,
+			})
+		}
+		lines = newJSONLines(gptOpt.Runner.MonitorFactory, out)
+		gptOpt.Runner.MonitorFactory = lines
+	}
+
+	var stream *outputStream
+	if r.streamOutput() {
+		if gptOpt.Runner.MonitorFactory == nil {
+			gptOpt.Runner.MonitorFactory = monitor.NewConsole(gptOpt.Monitor, monitor.Options{
+				DisplayProgress: true,
+			})
+		}
+		stream = newOutputStream(gptOpt.Runner.MonitorFactory, r.printHeader)
+		gptOpt.Runner.MonitorFactory = stream
+	}
+
 	gptScript, err := gptscript.New(&gptOpt)
 	if err != nil {
 		return err
@@ -329,10 +626,16 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 		return r.listModels(ctx, gptScript, args)
 	}
 
-	prg, err := r.readProgram(ctx, args)
-	if err != nil {
-		return err
+	var prg types.Program
+	if r.resumed != nil && r.resumed.Program != nil {
+		prg = *r.resumed.Program
+	} else {
+		prg, err = r.readProgram(ctx, gptScript, args)
+		if err != nil {
+			return err
+		}
 	}
+	r.telemetryFeatures = telemetry.ProgramFeatures(prg)
 
 	if r.Daemon {
 		prg = prg.SetBlocking()
@@ -369,10 +672,70 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 	if err != nil {
 		return err
 	}
+	if r.resumed != nil {
+		toolInput = r.resumed.Input
+	}
+
+	// The review commits the changes that are left after the snapshot restores the changes of failed runs
+	var output string
+	if r.GitReview {
+		s, err := review.Start(ctx, ".")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			retErr = r.finishReview(ctx, s, args[0], toolInput, output, retErr)
+		}()
+	}
+
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", r.Timeout, err)
+		}
+		timeoutCtx, cancel := runner.WithTimeout(cmd.Context(), timeout)
+		defer cancel()
+		cmd.SetContext(timeoutCtx)
+	}
+
+	if r.Snapshot {
+		s, err := snapshot.Take(".", snapshotDir(r.CacheOptions))
+		if err != nil {
+			return fmt.Errorf("failed to snapshot the working directory: %w", err)
+		}
+		defer func() {
+			retErr = r.finishSnapshot(s, retErr)
+		}()
+	}
+
+	if r.Replay != "" {
+		r.replay, err = record.Load(r.Replay)
+		if err != nil {
+			return fmt.Errorf("failed to read the recording %s: %w", r.Replay, err)
+		}
+	}
+	if r.Record != "" {
+		f, err := os.Create(r.Record)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", r.Record, err)
+		}
+		defer f.Close()
+		r.recorder = record.NewRecorder(f)
+	}
 
 	if r.ChatState != "" {
 		resp, err := gptScript.Chat(r.NewRunContext(cmd), r.ChatState, prg, os.Environ(), toolInput)
-		if err != nil {
+		if errCanceled := (*runner.CancelReason)(nil); errors.As(err, &errCanceled) && resp.State != nil {
+			// The state of a canceled turn is printed too, so the chat goes on and the model is told why it was canceled
+			data, jsonErr := json.Marshal(resp)
+			if jsonErr != nil {
+				return jsonErr
+			}
+			if printErr := r.PrintOutput(toolInput, string(data)); printErr != nil {
+				return printErr
+			}
+			return err
+		} else if err != nil {
 			return err
 		}
 		data, err := json.Marshal(resp)
@@ -384,14 +747,52 @@ func (r *GPTScript) Run(cmd *cobra.Command, args []string) (retErr error) {
 
 	if prg.IsChat() || r.ForceChat {
 		return chat.Start(r.NewRunContext(cmd), nil, gptScript, func() (types.Program, error) {
-			return r.readProgram(ctx, args)
+			return r.readProgram(ctx, gptScript, args)
 		}, os.Environ(), toolInput)
 	}
 
-	s, err := gptScript.Run(r.NewRunContext(cmd), prg, os.Environ(), toolInput)
-	if err != nil {
+	if stream != nil {
+		stream.enable(toolInput)
+	}
+	if lines != nil {
+		lines.enable()
+	}
+
+	runCtx, stopPause := pauseOnSignal(r.NewRunContext(cmd))
+	defer stopPause()
+	if r.checkpointing() {
+		runCtx = r.withCheckpoint(runCtx, args, toolInput, prg)
+	}
+
+	var s string
+	if r.resumed != nil {
+		s, err = gptScript.Resume(runCtx, prg, os.Environ(), r.resumed.State)
+	} else {
+		s, err = gptScript.Run(runCtx, prg, os.Environ(), toolInput)
+	}
+	if errPaused := (*runner.ErrPaused)(nil); errors.As(err, &errPaused) {
+		return r.savePaused(args, toolInput, prg, errPaused.State)
+	} else if err != nil {
+		if r.checkpointing() {
+			if file, fileErr := pausedFile(r.runID()); fileErr == nil {
+				if _, statErr := os.Stat(file); statErr == nil {
+					log.Infof("The run stopped, resume it from its last checkpoint with gptscript resume %s", r.runID())
+				}
+			}
+		}
 		return err
 	}
+	if err := r.finishRun(); err != nil {
+		return err
+	}
+	output = s
 
+	// The output is in the line of the run
+	if lines != nil {
+		return nil
+	}
+	if stream != nil && stream.finish(s) {
+		return nil
+	}
 	return r.PrintOutput(toolInput, s)
 }
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/gptscript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGPTScript returns the GPTScript instance of the options of the command, with a cache in a temporary directory
+func newGPTScript(t *testing.T, cli *GPTScript) *gptscript.GPTScript {
+	t.Helper()
+
+	cli.CacheDir = t.TempDir()
+	opts, err := cli.NewGPTScriptOpts()
+	require.NoError(t, err)
+	g, err := gptscript.New(&opts)
+	require.NoError(t, err)
+	t.Cleanup(g.Close)
+	return g
+}
+
+func TestReadProgramFromStdin(t *testing.T) {
+	cli := &GPTScript{
+		// The program was already read from stdin
+		readData: []byte("echo hi"),
+	}
+	g := newGPTScript(t, cli)
+
+	prg, err := cli.readProgram(context.Background(), g, []string{"-"})
+	require.NoError(t, err)
+	assert.Equal(t, "echo hi", prg.ToolSet[prg.EntryToolID].Instructions)
+
+	// The input can not be read from stdin too
+	cli.Input = "-"
+	_, err = cli.readProgram(context.Background(), g, []string{"-"})
+	assert.EqualError(t, err, "the program and its input can not both be read from stdin")
+
+	// It can when the program is read from a file
+	_, err = cli.readProgram(context.Background(), g, []string{"testdata/explain/tool.gpt"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// aliasName is the form of the names scripts are installed as, which can not be mistaken for files or URLs
+var aliasName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+type Install struct {
+	root *GPTScript
+	As   string `usage:"The name of the command the script is installed as, by default the name of the script" local:"true"`
+}
+
+func (c *Install) Customize(cmd *cobra.Command) {
+	cmd.Use = "install [flags] PROGRAM [-- FLAGS...]"
+	cmd.Short = "Install a script as a command, like gptscript install github.com/org/reviewer --as review, to run it with gptscript review"
+	cmd.Long = `Install a script as a command of its own. The reference to the script is pinned to the revision it resolved to,
+and the flags after -- are the flags it is run with, unless they are set when it is run.`
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+func (c *Install) Run(cmd *cobra.Command, args []string) error {
+	ref, flags := args[0], args[1:]
+
+	name := c.As
+	if name == "" {
+		name = defaultAliasName(ref)
+	}
+	if !aliasName.MatchString(name) {
+		return fmt.Errorf("invalid name %q, it must only have letters, digits, - and _, set another one with --as", name)
+	}
+	for _, command := range cmd.Root().Commands() {
+		if command.Name() == name || command.HasAlias(name) {
+			return fmt.Errorf("can not install a script as %s, it is a command of %s", name, cmd.Root().Name())
+		}
+	}
+
+	// The flags are checked now, so the installed script does not fail on every run. They are parsed by another
+	// command, so they do not apply to this one.
+	root := New()
+	check := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	check.AddFlagSet(root.PersistentFlags())
+	check.AddFlagSet(root.Flags())
+	if err := check.Parse(flags); err != nil {
+		return fmt.Errorf("invalid flags for %s: %w", ref, err)
+	} else if len(check.Args()) > 0 {
+		return fmt.Errorf("only flags can be installed with a script, not %v", check.Args())
+	}
+
+	prg, err := loader.Program(cmd.Context(), ref, "", loader.Options(c.root.LoaderOptions))
+	if err != nil {
+		return err
+	}
+
+	alias := config.Alias{
+		Reference:   pinReference(ref, prg.ToolSet[prg.EntryToolID]),
+		Credentials: programCredentials(prg),
+		Flags:       flags,
+	}
+
+	cfg, err := config.ReadCLIConfig(c.root.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CLI config: %w", err)
+	}
+	if cfg.Aliases == nil {
+		cfg.Aliases = map[string]config.Alias{}
+	}
+	cfg.Aliases[name] = alias
+	if err := cfg.Save(); err != nil {
+		return fmt.Errorf("failed to save CLI config: %w", err)
+	}
+
+	fmt.Printf("Installed %s as %s, run it with %s %s\n", alias.Reference, name, cmd.Root().Name(), name)
+	if len(alias.Credentials) > 0 {
+		fmt.Printf("It needs the credentials of %s\n", strings.Join(alias.Credentials, ", "))
+	}
+	return nil
+}
+
+// defaultAliasName returns the name of the script of a reference, like reviewer for github.com/org/reviewer or
+// review for ./review.gpt.
+func defaultAliasName(ref string) string {
+	ref, _, _ = strings.Cut(ref, loader.DigestPinPrefix)
+	ref, _, _ = strings.Cut(ref, "@")
+	return strings.TrimSuffix(path.Base(filepath.ToSlash(ref)), system.Suffix)
+}
+
+// pinReference returns the reference to the script of the entry tool, pinned to the revision of its repository. Local
+// scripts are referenced by their absolute path, so they can be run from any directory.
+func pinReference(ref string, entry types.Tool) string {
+	if entry.Source.Repo != nil && entry.Source.Repo.Revision != "" {
+		name, _, _ := strings.Cut(ref, "@")
+		return name + "@" + entry.Source.Repo.Revision
+	}
+	if _, err := os.Stat(ref); err == nil {
+		if abs, err := filepath.Abs(ref); err == nil {
+			return abs
+		}
+	}
+	if !strings.Contains(ref, loader.DigestPinPrefix) {
+		log.Warnf("%s is not in a repository, it is installed without pinning its version", ref)
+	}
+	return ref
+}
+
+// programCredentials returns the credential tools of all the tools of the program.
+func programCredentials(prg types.Program) (result []string) {
+	for _, tool := range prg.ToolSet {
+		for _, cred := range tool.Credentials {
+			if !slices.Contains(result, cred) {
+				result = append(result, cred)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// expandAlias looks up the script the program argument names if it was installed with gptscript install, and sets
+// the flags it was installed with that are not set on the command line.
+func (r *GPTScript) expandAlias(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 || !aliasName.MatchString(args[0]) {
+		return nil
+	}
+	if _, err := os.Stat(args[0]); err == nil {
+		return nil
+	}
+
+	cfg, err := config.ReadCLIConfig(r.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CLI config: %w", err)
+	}
+	alias, ok := cfg.Aliases[args[0]]
+	if !ok {
+		return nil
+	}
+
+	flags := pflag.NewFlagSet(args[0], pflag.ContinueOnError)
+	// The flags set on the command line are not in the set, and are skipped
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			flags.AddFlag(f)
+		}
+	})
+	if err := flags.Parse(alias.Flags); err != nil {
+		return fmt.Errorf("invalid flags of %s: %w", args[0], err)
+	}
+
+	r.aliasReference = alias.Reference
+	return nil
+}
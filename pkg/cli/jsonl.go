@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// jsonLineRunID is the id of the line of the run, which is written after the lines of its items.
+const jsonLineRunID = "run"
+
+// jsonLine is a line of the output of --jsonl, for an item of the run or for the run itself.
+type jsonLine struct {
+	ID     string        `json:"id"`
+	Tool   string        `json:"tool,omitempty"`
+	Status string        `json:"status"`
+	Output string        `json:"output,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Usage  jsonLineUsage `json:"usage"`
+}
+
+// jsonLineUsage is what an item or a run used: the responses of models, the tool calls it made, and how long it took.
+type jsonLineUsage struct {
+	ModelCalls int   `json:"modelCalls"`
+	ToolCalls  int   `json:"toolCalls"`
+	DurationMS int64 `json:"durationMs"`
+}
+
+// jsonLines writes a JSON line for every item of a run as soon as it finishes, instead of only the output of the run
+// when it is done. The items of a run are the tool calls and workflow steps of its entry tool, and the calls of its
+// shard tool, with all the calls they make.
+type jsonLines struct {
+	runner.MonitorFactory
+	out     io.Writer
+	lock    sync.Mutex
+	enabled bool
+}
+
+func newJSONLines(factory runner.MonitorFactory, out io.Writer) *jsonLines {
+	return &jsonLines{
+		MonitorFactory: factory,
+		out:            out,
+	}
+}
+
+func (j *jsonLines) Start(ctx context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+	m, err := j.MonitorFactory.Start(ctx, prg, env, input)
+	if err != nil {
+		return nil, err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if !j.enabled {
+		return m, nil
+	}
+	// Only the items of the program are written, not the ones of the runs it starts, like model providers
+	j.enabled = false
+	return &jsonLinesMonitor{
+		Monitor: m,
+		lines:   j,
+		start:   time.Now(),
+		items:   map[string]*jsonLineItem{},
+		itemOf:  map[string]string{},
+	}, nil
+}
+
+// enable writes the items of the next run.
+func (j *jsonLines) enable() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.enabled = true
+}
+
+func (j *jsonLines) LoadProgress(progress loader.Progress) {
+	if p, ok := j.MonitorFactory.(loader.ProgressMonitor); ok {
+		p.LoadProgress(progress)
+	}
+}
+
+func (j *jsonLines) write(line jsonLine) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		log.Errorf("failed to marshal the JSON line of %s: %v", line.ID, err)
+		return
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if _, err := j.out.Write(append(data, '\n')); err != nil {
+		log.Errorf("failed to write the JSON line of %s: %v", line.ID, err)
+	}
+}
+
+type jsonLineItem struct {
+	tool  string
+	start time.Time
+	usage jsonLineUsage
+}
+
+type jsonLinesMonitor struct {
+	runner.Monitor
+	lines *jsonLines
+	start time.Time
+
+	lock  sync.Mutex
+	root  string
+	usage jsonLineUsage
+	items map[string]*jsonLineItem
+	// itemOf is the ID of the item that a call is made for, by the ID of the call
+	itemOf map[string]string
+	order  []string
+}
+
+func (m *jsonLinesMonitor) Event(event runner.Event) {
+	m.Monitor.Event(event)
+	if event.CallContext == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	callCtx := event.CallContext
+	switch {
+	case event.Type == runner.EventTypeCallStart:
+		m.callStart(callCtx, event.Time)
+	case event.Type == runner.EventTypeChat && event.ChatResponse != nil:
+		m.usage.ModelCalls++
+		if item := m.items[m.itemOf[callCtx.ID]]; item != nil {
+			item.usage.ModelCalls++
+		}
+	case event.Type == runner.EventTypeCallFinish:
+		item := m.items[callCtx.ID]
+		if item == nil {
+			return
+		}
+		delete(m.items, callCtx.ID)
+		item.usage.DurationMS = event.Time.Sub(item.start).Milliseconds()
+		m.lines.write(jsonLine{
+			ID:     callCtx.ID,
+			Tool:   item.tool,
+			Status: "ok",
+			Output: event.Content,
+			Usage:  item.usage,
+		})
+	}
+}
+
+func (m *jsonLinesMonitor) callStart(callCtx *engine.CallContext, start time.Time) {
+	if callCtx.ParentID == "" {
+		if m.root == "" {
+			m.root = callCtx.ID
+		}
+		return
+	}
+
+	m.usage.ToolCalls++
+	if callCtx.ParentID == m.root && (callCtx.ToolCategory == engine.NoCategory || callCtx.ToolCategory == engine.ShardToolCategory) {
+		m.itemOf[callCtx.ID] = callCtx.ID
+		m.items[callCtx.ID] = &jsonLineItem{
+			tool:  types.FirstSet(callCtx.ToolName, callCtx.Tool.Name),
+			start: start,
+		}
+		m.order = append(m.order, callCtx.ID)
+		return
+	}
+
+	id, ok := m.itemOf[callCtx.ParentID]
+	if !ok {
+		return
+	}
+	m.itemOf[callCtx.ID] = id
+	if item := m.items[id]; item != nil {
+		item.usage.ToolCalls++
+	}
+}
+
+func (m *jsonLinesMonitor) Stop(output string, err error) {
+	m.Monitor.Stop(output, err)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// The items that did not finish are written with the error of the run, since they stopped with it
+	now := time.Now()
+	for _, id := range m.order {
+		item := m.items[id]
+		if item == nil {
+			continue
+		}
+		item.usage.DurationMS = now.Sub(item.start).Milliseconds()
+		line := jsonLine{
+			ID:     id,
+			Tool:   item.tool,
+			Status: jsonLineStatus(err),
+			Usage:  item.usage,
+		}
+		if err != nil {
+			line.Error = err.Error()
+		}
+		m.lines.write(line)
+	}
+	m.items = map[string]*jsonLineItem{}
+
+	usage := m.usage
+	usage.DurationMS = now.Sub(m.start).Milliseconds()
+	line := jsonLine{
+		ID:     jsonLineRunID,
+		Status: "ok",
+		Output: output,
+		Usage:  usage,
+	}
+	if err != nil {
+		line.Status = jsonLineStatus(err)
+		line.Error = err.Error()
+	}
+	m.lines.write(line)
+}
+
+// jsonLineStatus returns the status of what stopped with the error, which is canceled if the run was canceled.
+func jsonLineStatus(err error) string {
+	if errCanceled := (*runner.CancelReason)(nil); errors.As(err, &errCanceled) || errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	return "error"
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+// pausedRun is a run that was paused, or that saved checkpoints with --checkpoint, saved with the state to resume it
+// from.
+type pausedRun struct {
+	ID    string        `json:"id"`
+	Time  time.Time     `json:"time"`
+	Dir   string        `json:"dir"`
+	Args  []string      `json:"args"`
+	Input string        `json:"input,omitempty"`
+	State *runner.State `json:"state"`
+	// Program is the program of the run, so it resumes with the same program even if its files changed
+	Program *types.Program `json:"program,omitempty"`
+	// Checkpoint is set when the run saves checkpoints, it keeps saving them once it is resumed
+	Checkpoint bool `json:"checkpoint,omitempty"`
+}
+
+type Resume struct {
+	root *GPTScript
+	List bool `usage:"List the paused runs instead of resuming one" local:"true"`
+}
+
+func (r *Resume) Customize(cmd *cobra.Command) {
+	cmd.Use = "resume [ID]"
+	cmd.Short = "Resume a run that was paused with Ctrl+Z, or that stopped after it saved a checkpoint with --checkpoint, by default the latest run of the working directory"
+	cmd.Args = cobra.MaximumNArgs(1)
+}
+
+func (r *Resume) Run(cmd *cobra.Command, args []string) error {
+	runs, err := listPaused()
+	if err != nil {
+		return err
+	}
+
+	if r.List {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tSAVED\tDIRECTORY\tPROGRAM")
+		for _, run := range runs {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.ID, run.Time.Format(time.DateTime), run.Dir, strings.Join(run.Args, " "))
+		}
+		return w.Flush()
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if len(args) == 0 && run.Dir == cwd || len(args) == 1 && run.ID == args[0] {
+			if run.Dir != cwd {
+				log.Infof("Resuming the run in %s, where it was paused", run.Dir)
+				if err := os.Chdir(run.Dir); err != nil {
+					return err
+				}
+			}
+			r.root.resumed = &run
+			return r.root.Run(cmd, run.Args)
+		}
+	}
+	if len(args) == 1 {
+		return fmt.Errorf("paused run %s not found", args[0])
+	}
+	return fmt.Errorf("there are no paused runs in %s", cwd)
+}
+
+// pauseOnSignal returns a context that pauses the run at its next safe point when gptscript receives one of the
+// pause signals, SIGTSTP from Ctrl+Z, and a function to stop listening for them.
+func pauseOnSignal(ctx context.Context) (context.Context, func()) {
+	if len(pauseSignals) == 0 {
+		return ctx, func() {}
+	}
+
+	var (
+		signals = make(chan os.Signal, 1)
+		paused  = make(chan struct{})
+		done    = make(chan struct{})
+	)
+	signal.Notify(signals, pauseSignals...)
+	go func() {
+		select {
+		case <-signals:
+			log.Infof("Pausing the run once the tool calls that are running finish")
+			close(paused)
+		case <-done:
+		}
+	}()
+
+	return runner.WithPause(ctx, paused), func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func pausedFile(id string) (string, error) {
+	return xdg.DataFile(filepath.Join("gptscript", "paused", id+".json"))
+}
+
+// runID returns the ID that the state of the run is saved with, a run that is resumed keeps its ID.
+func (r *GPTScript) runID() string {
+	if r.resumed != nil {
+		return r.resumed.ID
+	}
+	if r.newRunID == "" {
+		r.newRunID = time.Now().UTC().Format("20060102-150405")
+	}
+	return r.newRunID
+}
+
+// checkpointing returns whether the run saves checkpoints.
+func (r *GPTScript) checkpointing() bool {
+	return r.Checkpoint || r.resumed != nil && r.resumed.Checkpoint
+}
+
+// savePaused saves the state of a paused run.
+func (r *GPTScript) savePaused(args []string, input string, prg types.Program, state *runner.State) error {
+	if err := r.saveRun(args, input, prg, state); err != nil {
+		return err
+	}
+	log.Infof("Paused the run, resume it with gptscript resume %s", r.runID())
+	return nil
+}
+
+// withCheckpoint returns a context that saves the state of the run as it goes, so it can be resumed if it crashes or
+// is interrupted.
+func (r *GPTScript) withCheckpoint(ctx context.Context, args []string, input string, prg types.Program) context.Context {
+	id := r.runID()
+	return runner.WithCheckpoint(ctx, func(state *runner.State) error {
+		if err := r.saveRun(args, input, prg, state); err != nil {
+			return err
+		}
+		log.Debugf("Saved the checkpoint of run %s", id)
+		return nil
+	})
+}
+
+func (r *GPTScript) saveRun(args []string, input string, prg types.Program, state *runner.State) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(pausedRun{
+		ID:         r.runID(),
+		Time:       time.Now(),
+		Dir:        cwd,
+		Args:       args,
+		Input:      input,
+		State:      state,
+		Program:    &prg,
+		Checkpoint: r.checkpointing(),
+	})
+	if err != nil {
+		return err
+	}
+	file, err := pausedFile(r.runID())
+	if err != nil {
+		return err
+	}
+	// The state is written whole, or not at all, so a run that crashes while it saves a checkpoint keeps the last one
+	if err := os.WriteFile(file+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+// finishRun removes the saved state of the run once it finishes.
+func (r *GPTScript) finishRun() error {
+	if r.resumed == nil && !r.checkpointing() {
+		return nil
+	}
+	file, err := pausedFile(r.runID())
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// listPaused returns the paused runs, the latest first.
+func listPaused() (result []pausedRun, _ error) {
+	file, err := pausedFile("list")
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(file), f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var run pausedRun
+		if err := json.Unmarshal(data, &run); err != nil {
+			log.Debugf("Skipping the invalid paused run %s: %v", f.Name(), err)
+			continue
+		}
+		result = append(result, run)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result, nil
+}
@@ -0,0 +1,8 @@
+package cli
+
+import (
+	"os"
+)
+
+// Windows has no signal to pause a process with, so runs are not paused
+var pauseSignals []os.Signal
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/spf13/cobra"
+)
+
+type PushTools struct {
+}
+
+func (p *PushTools) Customize(cmd *cobra.Command) {
+	cmd.Use = "push-tools [flags] DIRECTORY oci://REGISTRY/REPOSITORY:TAG"
+	cmd.Short = "Push the tool set in a directory to an OCI registry"
+	cmd.Args = cobra.ExactArgs(2)
+}
+
+func (p *PushTools) Run(cmd *cobra.Command, args []string) error {
+	digest, err := loader.PushOCI(cmd.Context(), args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println(digest)
+	return nil
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cmd2 "github.com/acorn-io/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/review"
+	"github.com/spf13/cobra"
+)
+
+type Review struct {
+	Stat bool `usage:"Show the files that changed and how much, instead of the diff" local:"true"`
+}
+
+func (c *Review) Customize(cmd *cobra.Command) {
+	cmd.Use = "review"
+	cmd.Short = "Show the commits of the runs of the review branch that is checked out, and their consolidated diff"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&ReviewRevert{}))
+}
+
+func (c *Review) Run(cmd *cobra.Command, _ []string) error {
+	s, err := review.Current(cmd.Context(), ".")
+	if err != nil {
+		return err
+	}
+	log, err := s.Log(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Review branch %s, started from %s, with %d runs:\n", s.Branch, s.StartedFrom(), len(log))
+	for _, commit := range log {
+		fmt.Printf("  %s\n", commit)
+	}
+	fmt.Println()
+	if c.Stat {
+		return s.DiffStat(cmd.Context(), os.Stdout)
+	}
+	return s.Diff(cmd.Context(), os.Stdout)
+}
+
+type ReviewRevert struct{}
+
+func (c *ReviewRevert) Customize(cmd *cobra.Command) {
+	cmd.Use = "revert [COMMIT]"
+	cmd.Short = "Revert the commit of a run, or all the runs, by checking out the branch the review started from and deleting the review branch"
+	cmd.Args = cobra.MaximumNArgs(1)
+}
+
+func (c *ReviewRevert) Run(cmd *cobra.Command, args []string) error {
+	s, err := review.Current(cmd.Context(), ".")
+	if err != nil {
+		return err
+	}
+	commit := strings.Join(args, "")
+	if err := s.Revert(cmd.Context(), commit); err != nil {
+		return err
+	}
+	if commit != "" {
+		fmt.Printf("Reverted %s in %s\n", commit, s.Branch)
+	} else {
+		fmt.Printf("Deleted %s with the changes of its runs\n", s.Branch)
+	}
+	return nil
+}
+
+// finishReview commits the changes of the run to the review branch, with a summary of the run: the script, its
+// input and its output, or the error it failed with.
+func (r *GPTScript) finishReview(ctx context.Context, s *review.Session, ref, input, output string, runErr error) error {
+	message := &strings.Builder{}
+	_, _ = fmt.Fprintf(message, "Run %s\n\n", ref)
+	if input != "" {
+		_, _ = fmt.Fprintf(message, "Input: %s\n\n", truncate(input, 500))
+	}
+	if runErr != nil {
+		_, _ = fmt.Fprintf(message, "The run failed: %s\n", truncate(runErr.Error(), 500))
+	} else if output != "" {
+		_, _ = fmt.Fprintf(message, "%s\n", truncate(output, 2000))
+	}
+
+	committed, err := s.Commit(ctx, message.String())
+	if err != nil {
+		return errors.Join(runErr, fmt.Errorf("failed to commit the changes of the run to %s: %w", s.Branch, err))
+	}
+	if committed {
+		log.Infof("Committed the changes of the run to %s, review them with gptscript review", s.Branch)
+	}
+	return runErr
+}
+
+func truncate(s string, length int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
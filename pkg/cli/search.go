@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+type Search struct {
+	Index string `usage:"The tool index to search, a URL or file of a JSON index (default the index of tools.gptscript.ai)" env:"GPTSCRIPT_TOOL_INDEX"`
+	Limit int    `usage:"The maximum number of tools to show, 0 for all" default:"10"`
+}
+
+func (s *Search) Customize(cmd *cobra.Command) {
+	cmd.Use = "search [flags] QUERY..."
+	cmd.Short = "Search the tool index for tools, and print the reference to use them with in a tools line"
+	cmd.Args = cobra.MinimumNArgs(1)
+}
+
+func (s *Search) Run(cmd *cobra.Command, args []string) error {
+	entries, err := registry.New(s.Index).Search(cmd.Context(), strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "No tools found for %q\n", strings.Join(args, " "))
+		return nil
+	}
+	if s.Limit > 0 && len(entries) > s.Limit {
+		entries = entries[:s.Limit]
+	}
+
+	for i, entry := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		if entry.Name != "" {
+			fmt.Println(entry.Name)
+		}
+		if entry.Description != "" {
+			fmt.Println("  " + entry.Description)
+		}
+
+		names := make([]string, 0, len(entry.Params))
+		for name := range entry.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  param %s: %s\n", name, entry.Params[name])
+		}
+
+		fmt.Printf("  tools: %s\n", entry.Reference)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/update"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+	"github.com/spf13/cobra"
+)
+
+type SelfUpdate struct {
+	Channel       string `usage:"The channel to update from, stable or beta, which includes the prereleases" default:"stable" local:"true"`
+	Version       string `usage:"Update to this version instead of the latest version of the channel, like v0.8.0" local:"true"`
+	Check         bool   `usage:"Only report whether there is another version to update to" local:"true"`
+	Rollback      bool   `usage:"Go back to the version that the last update replaced" local:"true"`
+	SkipSignature bool   `usage:"Only verify the checksum of the release, not the signature of its checksums" local:"true"`
+}
+
+func (s *SelfUpdate) Customize(cmd *cobra.Command) {
+	cmd.Use = "self-update"
+	cmd.Short = "Update gptscript to the latest release of a channel, or roll back the last update"
+	cmd.Args = cobra.NoArgs
+}
+
+func (s *SelfUpdate) Run(cmd *cobra.Command, _ []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if strings.Contains(filepath.ToSlash(exe), "/Cellar/") {
+		return fmt.Errorf("gptscript is installed with Homebrew, update it with brew upgrade gptscript")
+	}
+
+	if s.Rollback {
+		if err := update.Rollback(exe); err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %s, run it again with --rollback to undo\n", exe)
+		return nil
+	}
+
+	opts := update.Options{
+		Channel:       s.Channel,
+		SkipSignature: s.SkipSignature,
+	}
+
+	var release *update.Release
+	if s.Version != "" {
+		release, err = update.ByTag(cmd.Context(), s.Version, opts)
+	} else {
+		release, err = update.Latest(cmd.Context(), opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	current := version.Get().Tag
+	if release.Tag == current {
+		fmt.Printf("gptscript %s is up to date\n", current)
+		return nil
+	}
+	if s.Check {
+		fmt.Printf("gptscript %s can be updated to %s\n", current, release.Tag)
+		return nil
+	}
+
+	binary, err := update.Download(cmd.Context(), release, opts)
+	if err != nil {
+		return err
+	}
+	if err := update.Replace(exe, binary); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated gptscript from %s to %s, run gptscript self-update --rollback to go back\n", current, release.Tag)
+	return nil
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	cmd2 "github.com/acorn-io/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/snapshot"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type Snapshots struct {
+	root *GPTScript
+}
+
+func (s *Snapshots) Customize(cmd *cobra.Command) {
+	cmd.Use = "snapshot"
+	cmd.Short = "List the snapshots that runs with --snapshot took of their working directory"
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(cmd2.Command(&SnapshotRestore{root: s.root}))
+}
+
+func (s *Snapshots) Run(*cobra.Command, []string) error {
+	snapshots, err := snapshot.List(snapshotDir(s.root.CacheOptions))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tTAKEN\tDIRECTORY")
+	for _, s := range snapshots {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", s.ID, s.Time.Format(time.DateTime), s.Dir)
+	}
+	return w.Flush()
+}
+
+type SnapshotRestore struct {
+	root *GPTScript
+}
+
+func (s *SnapshotRestore) Customize(cmd *cobra.Command) {
+	cmd.Use = "restore [ID]"
+	cmd.Short = "Restore the directory of a snapshot, by default the latest snapshot of the working directory"
+	cmd.Args = cobra.MaximumNArgs(1)
+}
+
+func (s *SnapshotRestore) Run(_ *cobra.Command, args []string) error {
+	snapshots, err := snapshot.List(snapshotDir(s.root.CacheOptions))
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	for _, snap := range snapshots {
+		if len(args) == 0 && snap.Dir == cwd || len(args) == 1 && snap.ID == args[0] {
+			if err := snap.Restore(); err != nil {
+				return err
+			}
+			fmt.Printf("Restored %s to snapshot %s of %s\n", snap.Dir, snap.ID, snap.Time.Format(time.DateTime))
+			return nil
+		}
+	}
+	if len(args) == 1 {
+		return fmt.Errorf("snapshot %s not found", args[0])
+	}
+	return fmt.Errorf("there are no snapshots of %s", cwd)
+}
+
+func snapshotDir(opts CacheOptions) string {
+	return filepath.Join(cache.Complete(cache.Options(opts)).CacheDir, "snapshots")
+}
+
+// finishSnapshot restores the snapshot that was taken before the run if the run failed, or if the user rejects its
+// changes. The snapshot is kept when the changes are kept, to restore it later, and removed otherwise.
+func (r *GPTScript) finishSnapshot(s *snapshot.Snapshot, runErr error) error {
+	changes, err := s.Changes()
+	if err != nil {
+		return errors.Join(runErr, err)
+	}
+	if len(changes) == 0 {
+		return errors.Join(runErr, s.Remove())
+	}
+
+	restore := runErr != nil
+	if !restore && term.IsTerminal(int(os.Stdin.Fd())) {
+		_, _ = fmt.Fprintf(os.Stderr, "\nThe run changed %d files of %s:\n", len(changes), s.Dir)
+		for _, change := range changes {
+			_, _ = fmt.Fprintf(os.Stderr, "  %s\n", change)
+		}
+		keep := true
+		if err := survey.AskOne(&survey.Confirm{
+			Message: "Keep the changes?",
+			Default: true,
+		}, &keep); err != nil {
+			return err
+		}
+		restore = !keep
+	}
+
+	if !restore {
+		log.Infof("The run changed %d files, restore them with gptscript snapshot restore %s", len(changes), s.ID)
+		return nil
+	}
+
+	if err := s.Restore(); err != nil {
+		return errors.Join(runErr, fmt.Errorf("failed to restore %s to snapshot %s: %w", s.Dir, s.ID, err))
+	}
+	if runErr != nil {
+		log.Infof("Restored the %d files the run changed, since it failed", len(changes))
+	} else {
+		log.Infof("Restored the %d files the run changed", len(changes))
+	}
+	return errors.Join(runErr, s.Remove())
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/gptscript-ai/gptscript/pkg/step"
+)
+
+const (
+	stepContinue = "Continue"
+	stepSkip     = "Skip the call"
+	stepEdit     = "Edit the arguments"
+	stepAbort    = "Abort the run"
+)
+
+// terminalStepper steps through the runs of --debug-step in the terminal. The calls that run at the same time are
+// stepped through one at a time.
+type terminalStepper struct {
+	lock sync.Mutex
+}
+
+func (t *terminalStepper) Step(_ context.Context, s step.Step) (step.Decision, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var (
+		message string
+		options []string
+	)
+	if s.IsModel() {
+		messages, err := json.MarshalIndent(s.Request.Messages, "", "  ")
+		if err != nil {
+			return step.Decision{}, err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "\n%s\n", messages)
+		message = fmt.Sprintf("Call the model %s of [%s] with %d messages", s.Request.Model, s.Tool, len(s.Request.Messages))
+		options = []string{stepContinue, stepAbort}
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "\n%s\n", indentJSON(s.Arguments))
+		message = fmt.Sprintf("Call [%s]", s.Tool)
+		options = []string{stepContinue, stepSkip, stepEdit, stepAbort}
+	}
+
+	var answer string
+	if err := survey.AskOne(&survey.Select{
+		Message: message,
+		Options: options,
+	}, &answer, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)); err != nil {
+		return step.Decision{}, err
+	}
+
+	switch answer {
+	case stepSkip:
+		return step.Decision{Action: step.Skip}, nil
+	case stepAbort:
+		return step.Decision{Action: step.Abort}, nil
+	case stepEdit:
+		var edited string
+		err := survey.AskOne(&survey.Editor{
+			Message:       "Arguments",
+			Default:       indentJSON(s.Arguments),
+			AppendDefault: true,
+			HideDefault:   true,
+			FileName:      "*.json",
+		}, &edited, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr), survey.WithValidator(func(ans any) error {
+			if s, _ := ans.(string); !json.Valid([]byte(s)) {
+				return errors.New("the arguments are not valid JSON")
+			}
+			return nil
+		}))
+		if err != nil {
+			return step.Decision{}, err
+		}
+		compact := &bytes.Buffer{}
+		if err := json.Compact(compact, []byte(edited)); err != nil {
+			return step.Decision{}, err
+		}
+		return step.Decision{Action: step.Continue, Arguments: compact.String()}, nil
+	}
+	return step.Decision{Action: step.Continue}, nil
+}
+
+// indentJSON returns the JSON indented, or as it is if it is not JSON.
+func indentJSON(data string) string {
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, []byte(data), "", "  "); err != nil {
+		return data
+	}
+	return indented.String()
+}
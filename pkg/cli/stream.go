@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// outputStream writes the text of the completions of the entry tool of a run to stdout as the model writes it, so the
+// final output is seen before the run is done. A completion that calls tools is not the final output, so it is no
+// longer streamed once a tool call is seen, and is displayed as progress instead.
+type outputStream struct {
+	runner.MonitorFactory
+	out      io.Writer
+	header   func(input string)
+	lock     sync.Mutex
+	enabled  bool
+	input    string
+	current  string
+	written  string
+	streamed bool
+	skipped  map[string]bool
+}
+
+func newOutputStream(factory runner.MonitorFactory, header func(input string)) *outputStream {
+	return &outputStream{
+		MonitorFactory: factory,
+		out:            os.Stdout,
+		header:         header,
+		skipped:        map[string]bool{},
+	}
+}
+
+func (o *outputStream) Start(ctx context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+	m, err := o.MonitorFactory.Start(ctx, prg, env, input)
+	if err != nil {
+		return nil, err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if !o.enabled {
+		return m, nil
+	}
+	// Only the output of the program is streamed, not the output of the runs it starts, like model providers
+	o.enabled = false
+	return &streamMonitor{Monitor: m, stream: o}, nil
+}
+
+// enable streams the output of the next run, which has the input
+func (o *outputStream) enable(input string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.enabled = true
+	o.input = input
+}
+
+func (o *outputStream) LoadProgress(progress loader.Progress) {
+	if p, ok := o.MonitorFactory.(loader.ProgressMonitor); ok {
+		p.LoadProgress(progress)
+	}
+}
+
+// write streams the progress of a completion of the entry tool, and returns false if it is not streamed
+func (o *outputStream) write(event runner.Event) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.skipped[event.ChatCompletionID] || event.Content == openai.WaitingForModel {
+		return false
+	}
+
+	if event.ChatCompletionID != o.current {
+		if o.streamed && o.written != "" && !strings.HasSuffix(o.written, "\n") {
+			_, _ = fmt.Fprintln(o.out)
+		}
+		o.current = event.ChatCompletionID
+		o.written = ""
+	}
+
+	text, ok := strings.CutPrefix(event.Content, o.written)
+	if !ok || strings.Contains(text, "tool call ") {
+		o.skipped[event.ChatCompletionID] = true
+		return false
+	}
+
+	if text != "" {
+		if !o.streamed {
+			o.streamed = true
+			o.header(o.input)
+		}
+		_, _ = fmt.Fprint(o.out, text)
+		o.written += text
+	}
+	return true
+}
+
+// finish prints what was not streamed of the output. If the text that was streamed last is not where the output
+// starts, like when the output is not the text of the last completion, all of the output is printed after it.
+func (o *outputStream) finish(output string) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if !o.streamed {
+		return false
+	}
+
+	rest, ok := strings.CutPrefix(output, o.written)
+	if !ok {
+		if o.written != "" && !strings.HasSuffix(o.written, "\n") {
+			_, _ = fmt.Fprintln(o.out)
+		}
+		rest = output
+	}
+	_, _ = fmt.Fprint(o.out, rest)
+	if !strings.HasSuffix(o.written+rest, "\n") {
+		_, _ = fmt.Fprintln(o.out)
+	}
+	return true
+}
+
+type streamMonitor struct {
+	runner.Monitor
+	stream *outputStream
+}
+
+func (s *streamMonitor) Event(event runner.Event) {
+	if event.Type == runner.EventTypeCallProgress && event.CallContext != nil && event.CallContext.ParentID == "" &&
+		s.stream.write(event) {
+		return
+	}
+	s.Monitor.Event(event)
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	cmd2 "github.com/acorn-io/cmd"
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/telemetry"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type Telemetry struct {
+	root *GPTScript
+}
+
+func (t *Telemetry) Customize(cmd *cobra.Command) {
+	cmd.Use = "telemetry"
+	cmd.Short = "Show whether anonymous usage statistics are recorded, and what was recorded"
+	cmd.Long = `Show whether anonymous usage statistics are recorded, and a summary of what was recorded.
+
+Telemetry is off unless it is enabled. When it is, the commands that are run, the names of the flags they are run with,
+the kinds of tools and the builtin tools they use and the classes of the errors they fail with are recorded in a local
+file. Arguments, values of flags, paths, names of tools and error messages are never recorded, and nothing is sent
+anywhere: gptscript telemetry export writes the file to share it.`
+	cmd.Args = cobra.NoArgs
+	cmd.AddCommand(
+		cmd2.Command(&TelemetryEnable{root: t.root}),
+		cmd2.Command(&TelemetryDisable{root: t.root}),
+		cmd2.Command(&TelemetryExport{}),
+		cmd2.Command(&TelemetryClear{}),
+	)
+}
+
+func (t *Telemetry) Run(*cobra.Command, []string) error {
+	cfg, err := config.ReadCLIConfig(t.root.ConfigFile)
+	if err != nil {
+		return err
+	}
+	file, err := telemetry.DefaultFile()
+	if err != nil {
+		return err
+	}
+	events, err := telemetry.Events(file)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cfg.Telemetry && telemetry.Disabled():
+		fmt.Println("Telemetry is enabled, but not recorded since DO_NOT_TRACK is set")
+	case cfg.Telemetry:
+		fmt.Printf("Telemetry is enabled, and recorded in %s\n", file)
+	default:
+		fmt.Println("Telemetry is disabled, enable it with gptscript telemetry enable")
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\n%d commands were recorded from %s to %s\n\n", len(events), events[0].Day, events[len(events)-1].Day)
+	summary := telemetry.Summary(events)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "USED\tCOUNT")
+	for _, key := range telemetry.Keys(summary) {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", key, summary[key])
+	}
+	return w.Flush()
+}
+
+type TelemetryEnable struct {
+	root *GPTScript
+}
+
+func (t *TelemetryEnable) Customize(cmd *cobra.Command) {
+	cmd.Use = "enable"
+	cmd.Short = "Record anonymous usage statistics in a local file"
+	cmd.Args = cobra.NoArgs
+}
+
+func (t *TelemetryEnable) Run(*cobra.Command, []string) error {
+	return setTelemetry(t.root, true)
+}
+
+type TelemetryDisable struct {
+	root *GPTScript
+}
+
+func (t *TelemetryDisable) Customize(cmd *cobra.Command) {
+	cmd.Use = "disable"
+	cmd.Short = "Stop recording anonymous usage statistics, what was recorded is kept until it is cleared"
+	cmd.Args = cobra.NoArgs
+}
+
+func (t *TelemetryDisable) Run(*cobra.Command, []string) error {
+	return setTelemetry(t.root, false)
+}
+
+func setTelemetry(root *GPTScript, enabled bool) error {
+	cfg, err := config.ReadCLIConfig(root.ConfigFile)
+	if err != nil {
+		return err
+	}
+	cfg.Telemetry = enabled
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	if enabled {
+		fmt.Println("Telemetry is enabled, see what is recorded with gptscript telemetry")
+	} else {
+		fmt.Println("Telemetry is disabled")
+	}
+	return nil
+}
+
+type TelemetryExport struct {
+	Output string `usage:"The file to write the events to, - for stdout" short:"o" default:"-" local:"true"`
+}
+
+func (t *TelemetryExport) Customize(cmd *cobra.Command) {
+	cmd.Use = "export"
+	cmd.Short = "Write the recorded events as a JSON array, to review and share them"
+	cmd.Args = cobra.NoArgs
+}
+
+func (t *TelemetryExport) Run(*cobra.Command, []string) error {
+	file, err := telemetry.DefaultFile()
+	if err != nil {
+		return err
+	}
+	events, err := telemetry.Events(file)
+	if err != nil {
+		return err
+	}
+	if events == nil {
+		events = []telemetry.Event{}
+	}
+
+	var out io.Writer = os.Stdout
+	if t.Output != "-" {
+		f, err := os.Create(t.Output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
+
+type TelemetryClear struct{}
+
+func (t *TelemetryClear) Customize(cmd *cobra.Command) {
+	cmd.Use = "clear"
+	cmd.Short = "Delete the recorded events"
+	cmd.Args = cobra.NoArgs
+}
+
+func (t *TelemetryClear) Run(*cobra.Command, []string) error {
+	file, err := telemetry.DefaultFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fmt.Println("Deleted the recorded events")
+	return nil
+}
+
+// recordTelemetry wraps the commands so that every command that is run is recorded when telemetry is enabled.
+// Failing to record is never an error of the command.
+func recordTelemetry(root *GPTScript, command *cobra.Command) {
+	for _, child := range command.Commands() {
+		recordTelemetry(root, child)
+	}
+	if command.RunE == nil || strings.HasPrefix(command.CommandPath(), command.Root().Name()+" telemetry") {
+		return
+	}
+
+	run := command.RunE
+	command.RunE = func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+
+		cfg, cfgErr := config.ReadCLIConfig(root.ConfigFile)
+		if cfgErr != nil || !cfg.Telemetry || telemetry.Disabled() {
+			return err
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+		if name == "" {
+			name = "run"
+		}
+		features := root.telemetryFeatures
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			features = append(features, "flag:"+f.Name)
+		})
+
+		if file, fileErr := telemetry.DefaultFile(); fileErr == nil {
+			if recordErr := telemetry.Record(file, telemetry.NewEvent(name, features, err)); recordErr != nil {
+				log.Debugf("failed to record telemetry: %v", recordErr)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/shlex"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+type Vendor struct {
+	gptscript *GPTScript
+}
+
+func (v *Vendor) Customize(cmd *cobra.Command) {
+	cmd.Use = "vendor [flags] PROGRAM_FILE"
+	cmd.Short = "Save the remote tools of a program, with their runtimes and packages, in its vendor directory to run it with --offline"
+	cmd.Args = cobra.ExactArgs(1)
+}
+
+func (v *Vendor) Run(cmd *cobra.Command, args []string) error {
+	opts := loader.Options(v.gptscript.LoaderOptions)
+	opts.Offline = false
+	opts.Vendoring = true
+
+	prg, err := loader.Program(cmd.Context(), args[0], "", opts)
+	if err != nil {
+		return err
+	}
+
+	// Tools in repositories are cloned, and their runtime and packages installed, in the vendor directory, where
+	// they are found when running offline
+	dir := loader.VendorPath(args[0], opts.VendorDir)
+	manager := runtimes.Default(dir)
+
+	ids := make([]string, 0, len(prg.ToolSet))
+	for id := range prg.ToolSet {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		tool := prg.ToolSet[id]
+		if tool.Source.Repo == nil || (!tool.IsCommand() && !tool.IsDaemon()) {
+			continue
+		}
+
+		interpreter, _, _ := strings.Cut(strings.TrimPrefix(tool.Instructions, types.DaemonPrefix), "\n")
+		interpreter = strings.TrimPrefix(strings.TrimSpace(interpreter), types.CommandPrefix)
+		if _, rest, ok := strings.Cut(interpreter, ")"); ok && strings.HasPrefix(interpreter, "(") {
+			// The path of the daemon, like (path=/api)
+			interpreter = rest
+		}
+		command, err := shlex.Split(interpreter)
+		if err != nil {
+			return err
+		}
+		if len(command) == 0 || strings.HasPrefix(command[0], "sys.") {
+			continue
+		}
+
+		if _, _, err := manager.GetContext(cmd.Context(), tool, command, os.Environ()); err != nil {
+			return fmt.Errorf("failed to vendor %s: %w", tool.Source, err)
+		}
+	}
+
+	fmt.Println(dir)
+	return nil
+}
@@ -0,0 +1,76 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when there is no command to use the clipboard with on the OS.
+var ErrUnavailable = errors.New("no clipboard is available, on Linux install wl-clipboard, xclip or xsel")
+
+// commands returns the commands that read and write the clipboard: pbpaste and pbcopy on macOS, PowerShell on
+// Windows, and on Linux wl-paste and wl-copy for Wayland, or xclip or xsel for X11.
+func commands() (read, write []string, _ error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"pbpaste"}, []string{"pbcopy"}, nil
+	case "windows":
+		return []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"},
+			[]string{"powershell", "-NoProfile", "-Command", "Set-Clipboard -Value ([Console]::In.ReadToEnd())"}, nil
+	}
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" && hasCommand("wl-paste") && hasCommand("wl-copy") {
+		return []string{"wl-paste", "--no-newline"}, []string{"wl-copy"}, nil
+	}
+	if hasCommand("xclip") {
+		return []string{"xclip", "-selection", "clipboard", "-out"}, []string{"xclip", "-selection", "clipboard", "-in"}, nil
+	}
+	if hasCommand("xsel") {
+		return []string{"xsel", "--clipboard", "--output"}, []string{"xsel", "--clipboard", "--input"}, nil
+	}
+	return nil, nil, ErrUnavailable
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Read returns the text on the clipboard.
+func Read(ctx context.Context) (string, error) {
+	read, _, err := commands()
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, read[0], read[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("reading the clipboard with %s: %w: %s", read[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Write puts text on the clipboard.
+func Write(ctx context.Context, text string) error {
+	_, write, err := commands()
+	if err != nil {
+		return err
+	}
+
+	// The output is not captured, because xclip and wl-copy leave a process in the background that owns the
+	// clipboard, and waiting for its output to close would wait for the clipboard to change
+	cmd := exec.CommandContext(ctx, write[0], write[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("writing the clipboard with %s: %w", write[0], err)
+	}
+	return nil
+}
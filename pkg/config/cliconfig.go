@@ -44,10 +44,25 @@ func (a *AuthConfig) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Alias is a script installed with gptscript install, which is run as a command of its own, like gptscript review.
+type Alias struct {
+	// Reference is the reference to the script, pinned to the revision it was installed at if it is in a repository
+	Reference string `json:"reference"`
+	// Credentials are the credential tools that the tools of the script need
+	Credentials []string `json:"credentials,omitempty"`
+	// Flags are the flags the script is run with, unless they are set on the command line
+	Flags []string `json:"flags,omitempty"`
+}
+
 type CLIConfig struct {
 	Auths               map[string]AuthConfig `json:"auths,omitempty"`
 	CredentialsStore    string                `json:"credsStore,omitempty"`
 	GPTScriptConfigFile string                `json:"gptscriptConfig,omitempty"`
+	Aliases             map[string]Alias      `json:"aliases,omitempty"`
+	// Telemetry records the anonymous usage of gptscript in a local file, see gptscript telemetry
+	Telemetry bool `json:"telemetry,omitempty"`
+	// Features are the feature flags of experimental behaviors, overridden by --features and GPTSCRIPT_FEATURES
+	Features map[string]bool `json:"features,omitempty"`
 
 	auths     map[string]types.AuthConfig
 	authsLock *sync.Mutex
@@ -1,7 +1,9 @@
 package confirm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -12,6 +14,15 @@ type Confirm interface {
 	Confirm(ctx context.Context, prompt string) error
 }
 
+// Editor is a Confirm that lets the user correct the arguments of a call before it runs, instead of only approving
+// or denying it.
+type Editor interface {
+	Confirm
+	// Edit asks to confirm the prompt, and returns the JSON arguments to run the call with, the arguments of the
+	// call or the ones the user corrected
+	Edit(ctx context.Context, prompt, arguments string) (string, error)
+}
+
 type confirmer struct{}
 
 func WithConfirm(ctx context.Context, c Confirm) context.Context {
@@ -26,6 +37,19 @@ func Promptf(ctx context.Context, fmtString string, args ...any) error {
 	return c.Confirm(ctx, fmt.Sprintf(fmtString, args...))
 }
 
+// Editf asks to confirm the prompt like Promptf, and returns the JSON arguments to run the call with, which the user
+// can correct if the confirmation of the context is an Editor.
+func Editf(ctx context.Context, arguments, fmtString string, args ...any) (string, error) {
+	c, ok := ctx.Value(confirmer{}).(Confirm)
+	if !ok {
+		return arguments, nil
+	}
+	if e, ok := c.(Editor); ok {
+		return e.Edit(ctx, fmt.Sprintf(fmtString, args...), arguments)
+	}
+	return arguments, c.Confirm(ctx, fmt.Sprintf(fmtString, args...))
+}
+
 type TextPrompt struct {
 }
 
@@ -43,3 +67,68 @@ func (t TextPrompt) Confirm(_ context.Context, prompt string) error {
 	}
 	return nil
 }
+
+const (
+	answerYes  = "Yes"
+	answerEdit = "Edit the arguments"
+	answerNo   = "No"
+)
+
+// Edit asks to confirm the prompt, or to edit the arguments in the editor of the user, $VISUAL or $EDITOR, first.
+func (t TextPrompt) Edit(_ context.Context, prompt, arguments string) (string, error) {
+	var answer string
+	err := survey.AskOne(&survey.Select{
+		Message: prompt,
+		Options: []string{answerYes, answerEdit, answerNo},
+		Default: answerNo,
+	}, &answer)
+	if err != nil {
+		return "", err
+	}
+
+	switch answer {
+	case answerYes:
+		return arguments, nil
+	case answerNo:
+		return "", errors.New("abort")
+	}
+
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, []byte(arguments), "", "  "); err != nil {
+		indented.Reset()
+		indented.WriteString(arguments)
+	}
+
+	var edited string
+	err = survey.AskOne(&survey.Editor{
+		Message:       "Arguments",
+		Default:       indented.String(),
+		AppendDefault: true,
+		HideDefault:   true,
+		FileName:      "*.json",
+	}, &edited, survey.WithValidator(func(ans any) error {
+		if s, _ := ans.(string); !json.Valid([]byte(s)) {
+			return errors.New("the arguments are not valid JSON")
+		}
+		return nil
+	}))
+	if err != nil {
+		return "", err
+	}
+
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, []byte(edited)); err != nil {
+		return "", err
+	}
+	return compact.String(), nil
+}
+
+// Requiredf asks to confirm the prompt even if the run does not confirm the calls of tools, with the confirmation
+// of the context, or in the terminal.
+func Requiredf(ctx context.Context, fmtString string, args ...any) error {
+	c, ok := ctx.Value(confirmer{}).(Confirm)
+	if !ok {
+		c = TextPrompt{}
+	}
+	return c.Confirm(ctx, fmt.Sprintf(fmtString, args...))
+}
@@ -0,0 +1,117 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	bundleVersion    = 1
+	bundleKDF        = "pbkdf2-sha256"
+	bundleIterations = 600_000
+)
+
+// bundle is the format of exported credentials. The credentials are encrypted with AES-256-GCM, using a key derived
+// from a passphrase with PBKDF2.
+type bundle struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Encrypt encrypts the credentials with the passphrase, so they can be moved between machines and imported
+// with Decrypt.
+func Encrypt(creds []Credential, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("a passphrase is required to encrypt credentials")
+	}
+
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	b := bundle{
+		Version:    bundleVersion,
+		KDF:        bundleKDF,
+		Iterations: bundleIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(b.Salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(passphrase, b.Salt, b.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(b.Nonce); err != nil {
+		return nil, err
+	}
+	b.Data = gcm.Seal(nil, b.Nonce, data, nil)
+
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// Decrypt decrypts credentials that were encrypted with Encrypt.
+func Decrypt(data []byte, passphrase string) ([]Credential, error) {
+	var b bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid credentials bundle: %w", err)
+	}
+	if b.Version != bundleVersion || b.KDF != bundleKDF || b.Iterations <= 0 {
+		return nil, fmt.Errorf("unsupported credentials bundle version %d using %s", b.Version, b.KDF)
+	}
+
+	gcm, err := newGCM(passphrase, b.Salt, b.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid credentials bundle: bad nonce")
+	}
+
+	plain, err := gcm.Open(nil, b.Nonce, b.Data, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt credentials, the passphrase is wrong or the bundle was modified")
+	}
+
+	var creds []Credential
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials bundle: %w", err)
+	}
+
+	for _, cred := range creds {
+		if err := validateCredentialCtx(cred.Context); err != nil || cred.Context == "*" {
+			return nil, fmt.Errorf("invalid context %q of credential %s in bundle", cred.Context, cred.ToolName)
+		}
+	}
+
+	return creds, nil
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(passphrase, salt, iterations))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey derives the AES-256 key of the bundle from the passphrase with PBKDF2, using HMAC-SHA256 as the
+// pseudorandom function.
+func deriveKey(passphrase string, salt []byte, iterations int) []byte {
+	return pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New)
+}
@@ -0,0 +1,35 @@
+package credentials
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	creds := []Credential{
+		{
+			Context:  "default",
+			ToolName: "github.com/example/cred",
+			Env:      map[string]string{"TOKEN": "secret"},
+		},
+	}
+
+	data, err := Encrypt(creds, "passphrase")
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+
+	decrypted, err := Decrypt(data, "passphrase")
+	require.NoError(t, err)
+	require.Equal(t, creds, decrypted)
+
+	_, err = Decrypt(data, "wrong")
+	require.ErrorContains(t, err, "failed to decrypt")
+}
+
+func TestDeriveKey(t *testing.T) {
+	// Test vector of PBKDF2-HMAC-SHA256 from RFC 7914, section 11, of which the key is the first 32 bytes
+	key := deriveKey("passwd", []byte("salt"), 1)
+	require.Equal(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc", hex.EncodeToString(key))
+}
@@ -0,0 +1,246 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// partSuffix is the suffix of the file that a download is written to until it is complete
+	partSuffix = ".part"
+	// validatorSuffix is the suffix of the file with the ETag or Last-Modified of a partial download, so it is only
+	// resumed if the file did not change on the server
+	validatorSuffix = ".part.etag"
+	// maxAttempts is the number of times a download that is interrupted is resumed before it fails
+	maxAttempts = 5
+)
+
+// progressInterval is the shortest time between two reports of the progress of a download
+var progressInterval = time.Second
+
+// Progress is how much of a file was downloaded.
+type Progress struct {
+	URL        string `json:"url"`
+	Location   string `json:"location"`
+	Downloaded int64  `json:"downloaded"`
+	// Total is the size of the file, or -1 if the server did not send it
+	Total int64 `json:"total"`
+}
+
+func (p Progress) String() string {
+	if p.Total < 0 {
+		return fmt.Sprintf("%s of %s", size(p.Downloaded), p.URL)
+	}
+	return fmt.Sprintf("%s of %s (%d%%) of %s", size(p.Downloaded), size(p.Total), p.Downloaded*100/max(p.Total, 1), p.URL)
+}
+
+func size(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context that the progress of the downloads made with it is reported to.
+func WithProgress(ctx context.Context, report func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+func reportProgress(ctx context.Context, p Progress) {
+	if report, _ := ctx.Value(progressKey{}).(func(Progress)); report != nil {
+		report(p)
+	}
+}
+
+// File downloads the URL to the location. The download is written to the location with a .part suffix until it is
+// complete, so a download that is interrupted is resumed from where it stopped, by this call or the next call for the
+// same location, if the server supports range requests and the file did not change. When expectedSHA256 is set, the
+// download fails and is removed if the SHA256 of the file is not the expected one.
+func File(ctx context.Context, client *http.Client, url, location, expectedSHA256 string) error {
+	var (
+		part      = location + partSuffix
+		validator = location + validatorSuffix
+		lastErr   error
+	)
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			log.Infof("Resuming the download of [%s] after: %v", url, lastErr)
+		}
+		resumable, err := fetch(ctx, client, url, part, validator)
+		if err == nil {
+			break
+		} else if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		} else if !resumable {
+			return err
+		}
+		lastErr = err
+		if attempt == maxAttempts {
+			return fmt.Errorf("failed to download [%s] after %d attempts: %w", url, maxAttempts, lastErr)
+		}
+	}
+
+	if expectedSHA256 != "" {
+		if err := verify(part, expectedSHA256); err != nil {
+			_ = os.Remove(part)
+			_ = os.Remove(validator)
+			return err
+		}
+	}
+
+	_ = os.Remove(validator)
+	return os.Rename(part, location)
+}
+
+// fetch downloads the URL to the part file, from the end of the part file if the server can send the rest. When it
+// fails, it returns whether the download was interrupted, like by a network error, and can be resumed.
+func fetch(ctx context.Context, client *http.Client, url, part, validator string) (bool, error) {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if data, err := os.ReadFile(validator); err == nil && len(data) > 0 {
+			// The server sends the whole file if it changed since the part was downloaded
+			req.Header.Set("If-Range", string(data))
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp) == offset:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The part is the whole file, if it did not change the verification of the checksum finds it
+		return false, nil
+	case resp.StatusCode > 299:
+		return false, fmt.Errorf("invalid status code [%d] downloading [%s]: %s", resp.StatusCode, url, resp.Status)
+	default:
+		// The server does not support ranges, or the file changed, so the download starts over
+		flags |= os.O_TRUNC
+		offset = 0
+	}
+
+	if v := resp.Header.Get("ETag"); v != "" && !strings.HasPrefix(v, "W/") {
+		_ = os.WriteFile(validator, []byte(v), 0600)
+	} else if v := resp.Header.Get("Last-Modified"); v != "" {
+		_ = os.WriteFile(validator, []byte(v), 0600)
+	} else {
+		_ = os.Remove(validator)
+	}
+
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to create [%s]: %w", part, err)
+	}
+	defer f.Close()
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	w := &progressWriter{
+		ctx: ctx,
+		progress: Progress{
+			URL:        url,
+			Location:   strings.TrimSuffix(part, partSuffix),
+			Downloaded: offset,
+			Total:      total,
+		},
+	}
+	if _, err := io.Copy(io.MultiWriter(f, w), resp.Body); err != nil {
+		return true, err
+	}
+	w.report()
+
+	if total >= 0 && w.progress.Downloaded < total {
+		return true, io.ErrUnexpectedEOF
+	}
+	return false, nil
+}
+
+// contentRangeStart returns the first byte of the range of a partial response, or -1 if it has none.
+func contentRangeStart(resp *http.Response) int64 {
+	// Content-Range: bytes 100-999/1000
+	rng, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, _ := strings.Cut(rng, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func verify(file, expected string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, strings.TrimPrefix(expected, "sha256:")) {
+		return fmt.Errorf("the SHA256 of the download is %s, not the expected %s", actual, expected)
+	}
+	return nil
+}
+
+type progressWriter struct {
+	ctx      context.Context
+	progress Progress
+	reported time.Time
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.progress.Downloaded += int64(len(data))
+	if time.Since(p.reported) >= progressInterval {
+		p.report()
+	}
+	return len(data), nil
+}
+
+func (p *progressWriter) report() {
+	p.reported = time.Now()
+	reportProgress(p.ctx, p.progress)
+}
@@ -0,0 +1,82 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var content = bytes.Repeat([]byte("0123456789"), 10000)
+
+func sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func TestFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	var reports int
+	ctx := WithProgress(context.Background(), func(p Progress) {
+		reports++
+		assert.Equal(t, int64(len(content)), p.Total)
+	})
+
+	location := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, File(ctx, srv.Client(), srv.URL, location, sum(content)))
+	data, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.NotZero(t, reports)
+	assert.NoFileExists(t, location+partSuffix)
+
+	err = File(ctx, srv.Client(), srv.URL, filepath.Join(t.TempDir(), "file"), sum([]byte("other")))
+	assert.ErrorContains(t, err, "not the expected")
+}
+
+func TestFileResume(t *testing.T) {
+	var (
+		requests    atomic.Int32
+		rangeHeader atomic.Value
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// The first response is interrupted in the middle of the body
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			return
+		}
+		rangeHeader.Store(r.Header.Get("Range"))
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	location := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, File(context.Background(), srv.Client(), srv.URL, location, sum(content)))
+	data, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, "bytes="+strconv.Itoa(len(content)/2)+"-", rangeHeader.Load())
+}
+
+func TestProgressString(t *testing.T) {
+	assert.Equal(t, "5.0 MiB of 10.0 MiB (50%) of https://example.com/file",
+		Progress{URL: "https://example.com/file", Downloaded: 5 << 20, Total: 10 << 20}.String())
+	assert.Equal(t, "512 B of https://example.com/file", Progress{URL: "https://example.com/file", Downloaded: 512, Total: -1}.String())
+}
@@ -0,0 +1,131 @@
+// Package egress restricts the hosts that tools send requests to, to the hosts that the tools allow.
+package egress
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/trace"
+)
+
+// Allowlist are the hosts a tool may contact, like api.github.com, *.example.com for its subdomains, or
+// localhost:8080 for a single port of a host.
+type Allowlist []string
+
+// Allows returns whether the host, with or without a port, is in the allowlist.
+func (a Allowlist) Allows(host string) bool {
+	hostname, port := splitHostPort(host)
+	for _, allowed := range a {
+		allowedHost, allowedPort := splitHostPort(allowed)
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+		if allowedHost == "*" || allowedHost == hostname {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(allowedHost, "*."); ok && strings.HasSuffix(hostname, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHostPort(host string) (string, string) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+	return strings.ToLower(strings.Trim(hostname, "[]")), port
+}
+
+// ErrBlocked is returned for the requests to hosts that are not in the allowlist of the tool.
+type ErrBlocked struct {
+	Host    string
+	Allowed Allowlist
+}
+
+func (e *ErrBlocked) Error() string {
+	return fmt.Sprintf("request to %s is blocked, the tool only allows %s", e.Host, strings.Join(e.Allowed, ", "))
+}
+
+type contextKey struct{}
+
+type policy struct {
+	allowed Allowlist
+	blocked func(host string)
+}
+
+// WithContext returns a context that restricts the requests made with it to the allowed hosts, and calls blocked
+// with the host of every request it blocks. A context with no allowed hosts is not restricted.
+func WithContext(ctx context.Context, allowed Allowlist, blocked func(host string)) context.Context {
+	if len(allowed) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, policy{
+		allowed: allowed,
+		blocked: blocked,
+	})
+}
+
+// Restricted returns whether the context restricts requests to allowed hosts.
+func Restricted(ctx context.Context) bool {
+	_, ok := ctx.Value(contextKey{}).(policy)
+	return ok
+}
+
+// Check returns an error if the context does not allow requests to the host.
+func Check(ctx context.Context, host string) error {
+	p, ok := ctx.Value(contextKey{}).(policy)
+	if !ok {
+		return nil
+	}
+	return p.check(host)
+}
+
+func (p policy) check(host string) error {
+	if p.allowed.Allows(host) {
+		return nil
+	}
+	if p.blocked != nil {
+		p.blocked(host)
+	}
+	return &ErrBlocked{
+		Host:    host,
+		Allowed: p.allowed,
+	}
+}
+
+// Transport returns the transport that checks the host of every request, including redirects, against the
+// allowlist of the context before sending it with base, or the transport of the context if base is nil, see
+// tlsconfig.FromContext.
+func Transport(ctx context.Context, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = tlsconfig.FromContext(ctx)
+	}
+	// The requests of tools are traced in the spans of their calls
+	base = trace.Transport(base)
+	p, ok := ctx.Value(contextKey{}).(policy)
+	if !ok {
+		return base
+	}
+	return roundTripper{
+		policy: p,
+		base:   base,
+	}
+}
+
+type roundTripper struct {
+	policy policy
+	base   http.RoundTripper
+}
+
+func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := r.policy.check(req.URL.Host); err != nil {
+		return nil, err
+	}
+	return r.base.RoundTrip(req)
+}
@@ -0,0 +1,101 @@
+package egress
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	allowed := Allowlist{"api.github.com", "*.example.com", "localhost:8080"}
+
+	for _, host := range []string{"api.github.com", "API.GitHub.com:443", "docs.example.com", "a.b.example.com", "localhost:8080"} {
+		assert.True(t, allowed.Allows(host), host)
+	}
+	for _, host := range []string{"github.com", "example.com", "evil-example.com", "localhost", "localhost:9090"} {
+		assert.False(t, allowed.Allows(host), host)
+	}
+	assert.True(t, Allowlist{"*"}.Allows("anything.test"))
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	var blocked []string
+	ctx := WithContext(context.Background(), Allowlist{"allowed.test"}, func(host string) {
+		blocked = append(blocked, host)
+	})
+
+	client := http.Client{Transport: Transport(ctx, nil)}
+	_, err = client.Get(server.URL)
+	var errBlocked *ErrBlocked
+	require.ErrorAs(t, err, &errBlocked)
+	assert.Equal(t, u.Host, errBlocked.Host)
+	assert.Equal(t, []string{u.Host}, blocked)
+
+	ctx = WithContext(context.Background(), Allowlist{u.Host}, nil)
+	client = http.Client{Transport: Transport(ctx, nil)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+
+	// Contexts without allowed hosts are not restricted
+	assert.Equal(t, http.DefaultTransport, Transport(context.Background(), nil))
+	assert.NoError(t, Check(WithContext(context.Background(), nil, nil), "any.test"))
+
+	// The requests are sent with the transport of the context
+	transport := &http.Transport{}
+	assert.Same(t, transport, Transport(tlsconfig.WithTransport(context.Background(), transport), nil))
+}
+
+func TestProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	var blocked []string
+	proxy, err := NewProxy(WithContext(context.Background(), Allowlist{u.Host}, func(host string) {
+		blocked = append(blocked, host)
+	}))
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL())
+	require.NoError(t, err)
+	client := http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+
+	resp, err = client.Get("http://blocked.test/")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, []string{"blocked.test"}, blocked)
+
+	_, err = client.Get("https://blocked.test/")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"blocked.test", "blocked.test:443"}, blocked)
+}
@@ -0,0 +1,94 @@
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+)
+
+// ForwardCommand is the command of gptscript that runs SysForward.
+const ForwardCommand = "sys.egress"
+
+// ForwardArgs returns the arguments that run the command with the proxy of the socket on the loopback address of its
+// sandbox: gptscript is run as self, with ForwardCommand, as the command of the sandbox.
+func ForwardArgs(self, socket, proxyURL string, cmd []string) ([]string, error) {
+	addr, err := proxyAddr(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{self, ForwardCommand, socket, addr}, cmd...), nil
+}
+
+func proxyAddr(proxyURL string) (string, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Port() == "" {
+		return "", fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	return u.Host, nil
+}
+
+// SysForward runs in a sandbox without a network, with the arguments of ForwardArgs: it forwards the connections to
+// the address of the proxy on the loopback address of the sandbox to the unix socket of the proxy, which is mounted
+// in the sandbox, and runs the command. So the command can only reach the network through the proxy, even if it does
+// not use the proxy variables. It returns the exit code of the command.
+func SysForward() int {
+	if len(os.Args) < 5 {
+		_, _ = fmt.Fprintf(os.Stderr, "usage: %s %s SOCKET ADDRESS COMMAND [ARG...]\n", os.Args[0], ForwardCommand)
+		return 1
+	}
+	socket, addr, args := os.Args[2], os.Args[3], os.Args[4:]
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to listen on the address of the proxy %s: %v\n", addr, err)
+		return 1
+	}
+	defer l.Close()
+	go forward(l, socket)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func forward(l net.Listener, socket string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			proxy, err := net.Dial("unix", socket)
+			if err != nil {
+				return
+			}
+			defer proxy.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				_, _ = io.Copy(proxy, conn)
+				done <- struct{}{}
+			}()
+			go func() {
+				_, _ = io.Copy(conn, proxy)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
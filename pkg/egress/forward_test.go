@@ -0,0 +1,63 @@
+package egress
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// The test binary forwards to the proxy in the network namespace of TestForwardNoNetwork
+	if len(os.Args) > 1 && os.Args[1] == ForwardCommand {
+		os.Exit(SysForward())
+	}
+	os.Exit(m.Run())
+}
+
+func TestForwardNoNetwork(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("network namespaces are only on Linux")
+	}
+	for _, command := range []string{"unshare", "ip", "curl"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s is not installed", command)
+		}
+	}
+	if err := exec.Command("unshare", "-rn", "true").Run(); err != nil {
+		t.Skipf("network namespaces can not be created: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	proxy, err := NewProxy(WithContext(context.Background(), Allowlist{u.Host}, nil))
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	args, err := ForwardArgs(exe, proxy.Socket(), proxy.URL(), []string{"sh", "-c",
+		`curl -sf --noproxy '*' "$0" && echo direct; curl -sf -x "$1" "$0" && echo proxied`, server.URL, proxy.URL()})
+	require.NoError(t, err)
+
+	// The namespace has only its loopback, like the sandboxes that have no network
+	cmd := exec.Command("unshare", append([]string{"-rn", "sh", "-c", `ip link set lo up && exec "$@"`, "sh"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	// The server is only reached through the proxy, a direct connection fails even to the same address
+	assert.Contains(t, string(out), "okproxied")
+	assert.NotContains(t, string(out), "direct")
+}
@@ -0,0 +1,167 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+// Proxy is an HTTP proxy on the loopback address that only forwards the requests that the allowlist of its context
+// allows, for the commands of tools. HTTPS requests are tunneled with CONNECT, so they are checked by host. The proxy
+// also listens on a unix socket, for the sandboxes that have no network, which reach it through the socket with
+// SysForward.
+type Proxy struct {
+	listener net.Listener
+	socket   net.Listener
+	dir      string
+	// release marks the directory of the socket as no longer in use when the proxy is closed
+	release func()
+	server  *http.Server
+}
+
+// NewProxy starts a proxy that checks requests against the allowlist of the context.
+func NewProxy(ctx context.Context) (*Proxy, error) {
+	p, ok := ctx.Value(contextKey{}).(policy)
+	if !ok {
+		return nil, errors.New("the context has no allowed hosts to proxy requests to")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	// The directory of the socket is private to the user, so only the commands of the user can reach the proxy
+	dir, err := os.MkdirTemp("", "gptscript-egress*")
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	socket, err := net.Listen("unix", filepath.Join(dir, "proxy.sock"))
+	if err != nil {
+		_ = l.Close()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	proxy := &Proxy{
+		listener: l,
+		socket:   socket,
+		dir:      dir,
+		release:  cache.Use(dir),
+		server: &http.Server{
+			Handler:           proxyHandler{policy: p, transport: tlsconfig.FromContext(ctx)},
+			ReadHeaderTimeout: 30 * time.Second,
+		},
+	}
+	go func() {
+		_ = proxy.server.Serve(l)
+	}()
+	go func() {
+		_ = proxy.server.Serve(socket)
+	}()
+	return proxy, nil
+}
+
+// URL is the URL of the proxy, for the HTTP_PROXY and HTTPS_PROXY variables of commands.
+func (p *Proxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Socket is the path of the unix socket of the proxy.
+func (p *Proxy) Socket() string {
+	return p.socket.Addr().String()
+}
+
+func (p *Proxy) Close() error {
+	defer os.RemoveAll(p.dir)
+	p.release()
+	return p.server.Close()
+}
+
+type proxyHandler struct {
+	policy    policy
+	transport http.RoundTripper
+}
+
+func (h proxyHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	host := req.Host
+	if req.Method != http.MethodConnect {
+		host = req.URL.Host
+	}
+	if err := h.policy.check(host); err != nil {
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if req.Method == http.MethodConnect {
+		h.tunnel(rw, req)
+		return
+	}
+
+	if !req.URL.IsAbs() {
+		http.Error(rw, "only proxy requests are supported", http.StatusBadRequest)
+		return
+	}
+
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+
+	resp, err := h.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, v := range resp.Header {
+		rw.Header()[k] = v
+	}
+	rw.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(rw, resp.Body)
+}
+
+func (h proxyHandler) tunnel(rw http.ResponseWriter, req *http.Request) {
+	target, err := net.DialTimeout("tcp", req.Host, 30*time.Second)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer target.Close()
+
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, "tunneling is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(target, buf)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, target)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	AsyncAPIActionPublish = "publish"
+	AsyncAPIActionConsume = "consume"
+
+	defaultConsumeTimeout = 30 * time.Second
+)
+
+var SupportedAsyncAPIProtocols = []string{"kafka", "mqtt", "amqp"}
+
+type AsyncAPIInstructions struct {
+	Protocol string `json:"protocol"` // kafka, mqtt, or amqp
+	Server   string `json:"server"`   // host:port of the broker
+	Channel  string `json:"channel"`  // topic or queue name
+	Action   string `json:"action"`   // publish or consume
+}
+
+// GetAsyncAPIInstructions extracts the AsyncAPIInstructions from the instructions of a tool
+// that was generated from an AsyncAPI definition.
+func GetAsyncAPIInstructions(tool types.Tool) (AsyncAPIInstructions, error) {
+	var instructions AsyncAPIInstructions
+	_, inst, _ := strings.Cut(tool.Instructions, types.AsyncAPIPrefix+" ")
+	inst = strings.TrimPrefix(inst, "'")
+	inst = strings.TrimSuffix(inst, "'")
+	if err := json.Unmarshal([]byte(inst), &instructions); err != nil {
+		return AsyncAPIInstructions{}, fmt.Errorf("failed to unmarshal tool instructions: %w", err)
+	}
+	return instructions, nil
+}
+
+// runAsyncAPI runs a tool that was generated from an AsyncAPI definition. The message is published, or messages are
+// consumed, using the command line client of the protocol: kcat for Kafka, mosquitto_pub and mosquitto_sub for MQTT,
+// and amqp-publish and amqp-consume for AMQP.
+// The tools Instructions field will be in the format "#!sys.asyncapi '{Instructions JSON}'",
+// where {Instructions JSON} is a JSON string of type AsyncAPIInstructions.
+func (e *Engine) runAsyncAPI(ctx context.Context, tool types.Tool, input string) (*Return, error) {
+	envMap := map[string]string{}
+
+	for _, env := range e.Env {
+		k, v, _ := strings.Cut(env, "=")
+		envMap[k] = v
+	}
+
+	instructions, err := GetAsyncAPIInstructions(tool)
+	if err != nil {
+		return nil, err
+	}
+
+	var params struct {
+		Message string `json:"message"`
+		Count   string `json:"count"`
+		Timeout string `json:"timeout"`
+	}
+	if input != "" {
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return nil, fmt.Errorf("failed to parse input: %w", err)
+		}
+	}
+
+	count := 1
+	if params.Count != "" {
+		if count, err = strconv.Atoi(params.Count); err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid count %q, must be a positive number", params.Count)
+		}
+	}
+
+	timeout := defaultConsumeTimeout
+	if params.Timeout != "" {
+		seconds, err := strconv.Atoi(params.Timeout)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid timeout %q, must be a positive number of seconds", params.Timeout)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	host, port, err := net.SplitHostPort(instructions.Server)
+	if err != nil {
+		host = instructions.Server
+	}
+	prefix := "GPTSCRIPT_" + env.ToEnvLike(host) + "_"
+	username, password := envMap[prefix+"USERNAME"], envMap[prefix+"PASSWORD"]
+
+	var (
+		publish = instructions.Action == AsyncAPIActionPublish
+		stdin   string
+		args    []string
+		cmdEnv  []string
+	)
+
+	// The credentials are written to a file that only the user can read, since the command lines of processes can be
+	// read by any user
+	var credentialsDir string
+	if username != "" && instructions.Protocol != "amqp" {
+		credentialsDir, err = os.MkdirTemp("", "gptscript-asyncapi")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(credentialsDir)
+		defer cache.Use(credentialsDir)()
+	}
+
+	switch instructions.Protocol {
+	case "kafka":
+		args = []string{"kcat", "-b", instructions.Server, "-t", instructions.Channel}
+		if publish {
+			args = append(args, "-P")
+			stdin = params.Message
+		} else {
+			args = append(args, "-C", "-o", "end", "-q", "-c", strconv.Itoa(count))
+		}
+		if username != "" {
+			config := filepath.Join(credentialsDir, "kcat.conf")
+			if err := writeCredentials(config, []string{
+				"security.protocol=SASL_SSL",
+				"sasl.mechanisms=PLAIN",
+				"sasl.username=" + username,
+				"sasl.password=" + password,
+			}); err != nil {
+				return nil, err
+			}
+			args = append(args, "-F", config)
+		}
+	case "mqtt":
+		if publish {
+			args = []string{"mosquitto_pub", "-t", instructions.Channel, "-m", params.Message}
+		} else {
+			args = []string{"mosquitto_sub", "-t", instructions.Channel, "-C", strconv.Itoa(count)}
+		}
+		args = append(args, "-h", host, "-p", types.FirstSet(port, "1883"))
+		if username != "" {
+			// The mosquitto clients read their options, one per line, from the file of their name in the XDG config
+			// directory
+			if err := writeCredentials(filepath.Join(credentialsDir, args[0]), []string{"-u " + username, "-P " + password}); err != nil {
+				return nil, err
+			}
+			cmdEnv = append(os.Environ(), "XDG_CONFIG_HOME="+credentialsDir)
+		}
+	case "amqp":
+		client := "amqp-consume"
+		if publish {
+			client = "amqp-publish"
+		}
+		if username != "" {
+			return nil, fmt.Errorf("can not authenticate with the AMQP broker %s: %s only takes the password on its command line, where other users can read it, unset %sUSERNAME to connect without credentials",
+				instructions.Server, client, prefix)
+		}
+		u := url.URL{Scheme: "amqp", Host: instructions.Server}
+		if publish {
+			args = []string{client, "--url=" + u.String(), "-r", instructions.Channel, "-b", params.Message}
+		} else {
+			args = []string{client, "--url=" + u.String(), "-q", instructions.Channel, "-c", strconv.Itoa(count), "cat"}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported AsyncAPI protocol %s", instructions.Protocol)
+	}
+
+	if !publish {
+		// The consumer is stopped at the timeout and whatever was received until then is returned
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = cmdEnv
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("failed to %s on %s channel %s: %w: %s", instructions.Action, instructions.Protocol, instructions.Channel, err, strings.TrimSpace(stderr.String()))
+	}
+
+	result := stdout.String()
+	if publish {
+		result = fmt.Sprintf("Published message to %s", instructions.Channel)
+	} else if result == "" {
+		result = fmt.Sprintf("No messages were received from %s within %s", instructions.Channel, timeout)
+	}
+
+	return &Return{
+		Result: &result,
+	}, nil
+}
+
+// writeCredentials writes the lines of the configuration of a client with credentials to a file that only the user can
+// read.
+func writeCredentials(file string, lines []string) error {
+	return os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func asyncAPITool(t *testing.T, protocol string) types.Tool {
+	data, err := json.Marshal(AsyncAPIInstructions{
+		Protocol: protocol,
+		Server:   "broker.example.com:9092",
+		Channel:  "orders",
+		Action:   AsyncAPIActionPublish,
+	})
+	require.NoError(t, err)
+	return types.Tool{
+		Instructions: types.AsyncAPIPrefix + " '" + string(data) + "'",
+	}
+}
+
+func TestAsyncAPICredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake clients are shell scripts")
+	}
+
+	// The fake clients print their arguments, and the files of their credentials
+	bin, out := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	for name, script := range map[string]string{
+		"kcat":          `echo "$@" > "$OUT"; while [ $# -gt 0 ]; do [ "$1" = -F ] && cat "$2" >> "$OUT" && ls -l "$2" >> "$OUT"; shift; done`,
+		"mosquitto_pub": `echo "$@" > "$OUT"; cat "$XDG_CONFIG_HOME/mosquitto_pub" >> "$OUT"`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("OUT", out)
+
+	e := &Engine{
+		Env: []string{"GPTSCRIPT_BROKER_EXAMPLE_COM_USERNAME=orders", "GPTSCRIPT_BROKER_EXAMPLE_COM_PASSWORD=s3cret"},
+	}
+
+	_, err := e.runAsyncAPI(context.Background(), asyncAPITool(t, "kafka"), `{"message": "apples"}`)
+	require.NoError(t, err)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	args, config, _ := strings.Cut(string(data), "\n")
+	assert.NotContains(t, args, "s3cret", "the password must not be on the command line")
+	assert.Contains(t, config, "sasl.username=orders\nsasl.password=s3cret\n")
+	assert.Contains(t, config, "-rw-------")
+
+	_, err = e.runAsyncAPI(context.Background(), asyncAPITool(t, "mqtt"), `{"message": "apples"}`)
+	require.NoError(t, err)
+	data, err = os.ReadFile(out)
+	require.NoError(t, err)
+	args, config, _ = strings.Cut(string(data), "\n")
+	assert.NotContains(t, args, "s3cret", "the password must not be on the command line")
+	assert.Equal(t, "-u orders\n-P s3cret\n", config)
+
+	// The AMQP clients can only take the password on their command line
+	_, err = e.runAsyncAPI(context.Background(), asyncAPITool(t, "amqp"), `{"message": "apples"}`)
+	assert.ErrorContains(t, err, "amqp-publish only takes the password on its command line")
+}
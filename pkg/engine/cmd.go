@@ -13,15 +13,22 @@ import (
 	"sort"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/google/shlex"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
 	context2 "github.com/gptscript-ai/gptscript/pkg/context"
+	"github.com/gptscript-ai/gptscript/pkg/download"
+	"github.com/gptscript-ai/gptscript/pkg/egress"
 	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/sandbox"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/vars"
+	"github.com/gptscript-ai/gptscript/pkg/vector"
 	"github.com/gptscript-ai/gptscript/pkg/version"
 )
 
-func (e *Engine) runCommand(ctx context.Context, tool types.Tool, input string, toolCategory ToolCategory) (cmdOut string, cmdErr error) {
+func (e *Engine) runCommand(ctx context.Context, tool types.Tool, input string, stdin *string, toolCategory ToolCategory) (cmdOut string, cmdErr error) {
 	id := fmt.Sprint(atomic.AddInt64(&completionID, 1))
 
 	defer func() {
@@ -42,7 +49,30 @@ func (e *Engine) runCommand(ctx context.Context, tool types.Tool, input string,
 				"input":   input,
 			},
 		}
-		return tool.BuiltinFunc(ctx, e.Env, input)
+		if e.Sandbox != nil {
+			// Builtins that run commands, like sys.exec, are limited to what the tool that calls them declares
+			policy, err := sandboxPolicy(callingTool(ctx, tool))
+			if err != nil {
+				return "", err
+			}
+			ctx = sandbox.WithContext(ctx, e.Sandbox, policy)
+		}
+		ctx, err := e.fsContext(ctx, tool)
+		if err != nil {
+			return "", err
+		}
+		// The builtins that index and search files, like sys.vector.search, embed with the models of the engine
+		if embedder, ok := e.Model.(vector.Embedder); ok {
+			ctx = vector.WithContext(ctx, embedder)
+		}
+		// The builtins that download files, like sys.download, report their progress to the monitor
+		ctx = download.WithProgress(ctx, func(p download.Progress) {
+			e.Progress <- types.CompletionStatus{
+				CompletionID:     id,
+				DownloadProgress: p.String(),
+			}
+		})
+		return tool.BuiltinFunc(e.egressContext(ctx, tool), e.Env, input)
 	}
 
 	cmd, stop, err := e.newCommand(ctx, nil, tool, input)
@@ -62,9 +92,14 @@ func (e *Engine) runCommand(ctx context.Context, tool types.Tool, input string,
 	output := &bytes.Buffer{}
 	all := &bytes.Buffer{}
 	cmd.Stdin = os.Stdin
+	if stdin != nil {
+		cmd.Stdin = strings.NewReader(*stdin)
+	}
 	cmd.Stderr = io.MultiWriter(all, os.Stderr)
 	cmd.Stdout = io.MultiWriter(all, output)
 
+	terminateOnCancel(cmd)
+
 	if toolCategory == CredentialToolCategory {
 		pause := context2.GetPauseFuncFromCtx(ctx)
 		unpause := pause()
@@ -112,6 +147,9 @@ func envAsMapAndDeDup(env []string) (sortedEnv []string, _ map[string]string) {
 	return sortedEnv, envMap
 }
 
+// terminateDelay is how long the commands of a run that is canceled have to exit before they are killed
+var terminateDelay = 5 * time.Second
+
 var ignoreENV = map[string]struct{}{
 	"PATH":               {},
 	"Path":               {},
@@ -159,6 +197,7 @@ func appendInputAsEnv(env []string, input string) []string {
 
 func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.Tool, input string) (*exec.Cmd, func(), error) {
 	envvars := append(e.Env[:], extraEnv...)
+	envvars = append(envvars, vars.FromContext(ctx).Env()...)
 	envvars = appendInputAsEnv(envvars, input)
 	if log.IsDebug() {
 		envvars = append(envvars, "GPTSCRIPT_DEBUG=true")
@@ -172,11 +211,21 @@ func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.T
 		return nil, nil, err
 	}
 
-	envvars, err = e.getRuntimeEnv(ctx, tool, args, envvars)
+	runtimeCmd := args
+	if tool.Container != "" {
+		// The runtime of the tool is in its container, only the repository of the tool is checked out
+		runtimeCmd = nil
+	}
+
+	envvars, err = e.getRuntimeEnv(ctx, tool, runtimeCmd, envvars)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if runtimes, ok := e.RuntimeManager.(CommandRuntimeManager); ok && tool.Container == "" {
+		args = runtimes.Command(tool, args)
+	}
+
 	envvars, envMap := envAsMapAndDeDup(envvars)
 	for i, arg := range args {
 		args[i] = os.Expand(arg, func(s string) string {
@@ -191,6 +240,7 @@ func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.T
 	var (
 		cmdArgs = args[1:]
 		stop    = func() {}
+		script  string
 	)
 
 	if strings.TrimSpace(rest) != "" {
@@ -198,7 +248,9 @@ func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.T
 		if err != nil {
 			return nil, nil, err
 		}
+		release := cache.Use(f.Name())
 		stop = func() {
+			release()
 			_ = os.Remove(f.Name())
 		}
 
@@ -209,6 +261,47 @@ func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.T
 			return nil, nil, err
 		}
 		cmdArgs = append(cmdArgs, f.Name())
+		script = f.Name()
+	}
+
+	var policy *sandbox.Policy
+	if e.Sandbox != nil {
+		p, err := sandboxPolicy(tool)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		policy = &p
+	}
+
+	if policy != nil && len(tool.AllowedHosts) > 0 && !tool.IsDaemon() {
+		// Sandboxed commands reach the network through a proxy that only forwards requests to the allowed hosts
+		proxy, err := egress.NewProxy(e.egressContext(ctx, tool))
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		stopCommand := stop
+		stop = func() {
+			stopCommand()
+			_ = proxy.Close()
+		}
+
+		policy.Network, policy.Proxy, policy.ProxySocket = false, proxy.URL(), proxy.Socket()
+		for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "http_proxy", "https_proxy", "all_proxy"} {
+			envvars = append(envvars, key+"="+proxy.URL())
+		}
+		envvars = append(envvars, "NO_PROXY=", "no_proxy=")
+		envvars, envMap = envAsMapAndDeDup(envvars)
+	}
+
+	if tool.Container != "" {
+		cmd, err := e.containerCommand(ctx, tool, envvars, envMap, append([]string{args[0]}, cmdArgs...), script, policy)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		return cmd, stop, nil
 	}
 
 	// This is a workaround for Windows, where the command interpreter is constructed with unix style paths
@@ -224,5 +317,22 @@ func (e *Engine) newCommand(ctx context.Context, extraEnv []string, tool types.T
 
 	cmd := exec.CommandContext(ctx, env.Lookup(envvars, args[0]), cmdArgs...)
 	cmd.Env = envvars
+
+	if policy != nil {
+		if script != "" {
+			policy.Read = append(policy.Read, script)
+		}
+		if toolDir := envMap["GPTSCRIPT_TOOL_DIR"]; toolDir != "" {
+			policy.Read = append(policy.Read, toolDir)
+		}
+		if runtimes, ok := e.RuntimeManager.(DirsRuntimeManager); ok {
+			policy.Read = append(policy.Read, runtimes.Dirs()...)
+		}
+		cmd, err = e.Sandbox.Command(ctx, *policy, cmd)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+	}
 	return cmd, stop, nil
 }
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/sandbox"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// DefaultContainerRuntime is the command that runs the tools that have a container, unless another one is set
+const DefaultContainerRuntime = "docker"
+
+// containerCommand returns the command that runs the command of the tool in the container image of the tool. The
+// working directory, the directory of the tool and the file of its script are mounted at the same paths in the
+// container, so the paths in the command are the same in the container, and the environment of the tool, like its
+// input and credentials, is passed to the container. In the sandbox, the container is also restricted to the policy.
+func (e *Engine) containerCommand(ctx context.Context, tool types.Tool, envvars []string, envMap map[string]string, args []string, script string, policy *sandbox.Policy) (*exec.Cmd, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	containerRuntime := e.ContainerRuntime
+	if containerRuntime == "" {
+		containerRuntime = DefaultContainerRuntime
+	}
+
+	runArgs := []string{"run", "--rm", "-i", "--init"}
+	if policy != nil {
+		policyArgs, err := sandbox.ContainerArgs(*policy)
+		if err != nil {
+			return nil, err
+		}
+		runArgs = append(runArgs, policyArgs...)
+		if args, err = sandbox.ContainerCommand(*policy, args); err != nil {
+			return nil, err
+		}
+	} else {
+		runArgs = append(runArgs, "-v", cwd+":"+cwd)
+	}
+	runArgs = append(runArgs, "-w", cwd)
+
+	if toolDir := envMap["GPTSCRIPT_TOOL_DIR"]; toolDir != "" && toolDir != cwd {
+		runArgs = append(runArgs, "-v", toolDir+":"+toolDir+":ro")
+	}
+	if script != "" {
+		runArgs = append(runArgs, "-v", script+":"+script+":ro")
+	}
+
+	runArgs = append(runArgs, sandbox.UserArgs(containerRuntime)...)
+
+	if port := envMap["GPTSCRIPT_PORT"]; port != "" {
+		// Daemons listen on all the addresses of the container, and their port is published on the loopback address
+		runArgs = append(runArgs, "-p", net.JoinHostPort(envMap["GPTSCRIPT_HOST"], port)+":"+port)
+		envvars = append(envvars, "GPTSCRIPT_HOST=0.0.0.0")
+	}
+
+	runArgs = append(runArgs, sandbox.EnvArgs(envvars)...)
+
+	runArgs = append(runArgs, tool.Container)
+	runArgs = append(runArgs, args...)
+
+	envvars, _ = envAsMapAndDeDup(envvars)
+	cmd := exec.CommandContext(ctx, env.Lookup(envvars, containerRuntime), runArgs...)
+	cmd.Env = envvars
+	return cmd, nil
+}
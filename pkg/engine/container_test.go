@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerCommand(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	e := &Engine{
+		ContainerRuntime: "podman",
+	}
+	tool := types.Tool{
+		Parameters: types.Parameters{
+			Container: "ghcr.io/example/image:latest",
+		},
+	}
+	envvars := []string{"PATH=/usr/bin", "HOME=/home/user", "API_TOKEN=secret", "GPTSCRIPT_TOOL_DIR=/tools/example"}
+	_, envMap := envAsMapAndDeDup(envvars)
+
+	cmd, err := e.containerCommand(context.Background(), tool, envvars, envMap, []string{"python3", "/tmp/script"}, "/tmp/script", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"podman", "run", "--rm", "-i", "--init",
+		"-v", cwd + ":" + cwd,
+		"-w", cwd,
+		"-v", "/tools/example:/tools/example:ro",
+		"-v", "/tmp/script:/tmp/script:ro",
+		"-e", "API_TOKEN",
+		"-e", "GPTSCRIPT_TOOL_DIR",
+		"ghcr.io/example/image:latest", "python3", "/tmp/script"}, cmd.Args)
+	assert.Contains(t, cmd.Env, "API_TOKEN=secret")
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,10 +21,12 @@ type Ports struct {
 	daemonLock  sync.Mutex
 
 	startPort, endPort int64
-	usedPorts          map[int64]struct{}
-	daemonCtx          context.Context
-	daemonClose        func()
-	daemonWG           sync.WaitGroup
+	// host is the loopback address daemons listen on
+	host        string
+	usedPorts   map[int64]struct{}
+	daemonCtx   context.Context
+	daemonClose func()
+	daemonWG    sync.WaitGroup
 }
 
 func (p *Ports) SetPorts(start, end int64) {
@@ -30,6 +34,18 @@ func (p *Ports) SetPorts(start, end int64) {
 	p.endPort = end
 }
 
+func (p *Ports) SetHost(host string) {
+	p.host = host
+}
+
+// Host returns the loopback address daemons listen on
+func (p *Ports) Host() string {
+	if p.host == "" {
+		return "127.0.0.1"
+	}
+	return p.host
+}
+
 func (p *Ports) CloseDaemons() {
 	p.daemonLock.Lock()
 	if p.daemonCtx == nil {
@@ -66,6 +82,14 @@ func (p *Ports) NextPort() int64 {
 			p.usedPorts = map[int64]struct{}{}
 		}
 		p.usedPorts[nextPort] = struct{}{}
+
+		// Skip ports that another process is listening on
+		l, err := net.Listen("tcp", net.JoinHostPort(p.Host(), strconv.FormatInt(nextPort, 10)))
+		if err != nil {
+			log.Debugf("skipping daemon port %d: %v", nextPort, err)
+			continue
+		}
+		_ = l.Close()
 		return nextPort
 	}
 
@@ -93,6 +117,10 @@ func getPath(instructions string) (string, string) {
 	return strings.TrimSpace(rest), strings.TrimSpace(value)
 }
 
+func daemonURL(host string, port int64, path string) string {
+	return "http://" + net.JoinHostPort(host, strconv.FormatInt(port, 10)) + path
+}
+
 func (e *Engine) startDaemon(_ context.Context, tool types.Tool) (string, error) {
 	e.Ports.daemonLock.Lock()
 	defer e.Ports.daemonLock.Unlock()
@@ -102,7 +130,7 @@ func (e *Engine) startDaemon(_ context.Context, tool types.Tool) (string, error)
 	tool.Instructions = types.CommandPrefix + instructions
 
 	port, ok := e.Ports.daemonPorts[tool.ID]
-	url := fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+	url := daemonURL(e.Ports.Host(), port, path)
 	if ok {
 		return url, nil
 	}
@@ -113,11 +141,12 @@ func (e *Engine) startDaemon(_ context.Context, tool types.Tool) (string, error)
 
 	ctx := e.Ports.daemonCtx
 	port = e.Ports.NextPort()
-	url = fmt.Sprintf("http://127.0.0.1:%d%s", port, path)
+	url = daemonURL(e.Ports.Host(), port, path)
 
 	cmd, stop, err := e.newCommand(ctx, []string{
 		fmt.Sprintf("PORT=%d", port),
 		fmt.Sprintf("GPTSCRIPT_PORT=%d", port),
+		"GPTSCRIPT_HOST=" + e.Ports.Host(),
 	},
 		tool,
 		"{}",
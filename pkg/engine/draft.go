@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// uncertain are phrases that models answer with when they are not able to do what was asked
+var uncertain = []string{
+	"i'm not sure",
+	"i am not sure",
+	"i don't know",
+	"i do not know",
+	"i'm unable to",
+	"i am unable to",
+	"i cannot",
+	"i can't",
+}
+
+// call calls the model of the request. If the request has a draft model, the draft model is called first, and its
+// response is used if it passes the checks of checkDraft. Otherwise, the model of the request is called to override
+// the draft, so only the requests that the cheaper draft model can not handle pay for the model of the request.
+func (e *Engine) call(ctx context.Context, request types.CompletionRequest, progress chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	if request.DraftModel == "" || request.DraftModel == request.Model {
+		return e.Model.Call(ctx, request, progress)
+	}
+
+	draft := request
+	draft.Model = request.DraftModel
+	draft.DraftModel = ""
+
+	resp, err := e.Model.Call(ctx, draft, progress)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	} else if err != nil {
+		log.Debugf("draft model %s failed, calling %s: %v", request.DraftModel, request.Model, err)
+	} else if err := checkDraft(request, resp); err != nil {
+		log.Debugf("draft of model %s rejected, calling %s: %v", request.DraftModel, request.Model, err)
+	} else {
+		log.Debugf("using draft of model %s", request.DraftModel)
+		return resp, nil
+	}
+
+	request.DraftModel = ""
+	return e.Model.Call(ctx, request, progress)
+}
+
+// checkDraft returns an error if the response of the draft model can not be trusted: it is empty, it calls tools
+// that do not exist or with arguments that do not match their parameters, it is not JSON when JSON was requested,
+// or it says that it does not know the answer.
+func checkDraft(request types.CompletionRequest, resp *types.CompletionMessage) error {
+	var text strings.Builder
+	for _, content := range resp.Content {
+		if content.ToolCall != nil {
+			if err := checkToolCall(request.Tools, *content.ToolCall); err != nil {
+				return err
+			}
+			continue
+		}
+		text.WriteString(content.Text)
+	}
+
+	answer := strings.TrimSpace(text.String())
+	if answer == "" {
+		if !hasToolCall(resp) {
+			return fmt.Errorf("empty response")
+		}
+		return nil
+	}
+
+	if request.JSONResponse && !json.Valid([]byte(answer)) {
+		return fmt.Errorf("response is not valid JSON")
+	}
+
+	lower := strings.ToLower(answer)
+	for _, phrase := range uncertain {
+		if strings.Contains(lower, phrase) {
+			return fmt.Errorf("response is uncertain, it contains %q", phrase)
+		}
+	}
+
+	return nil
+}
+
+func hasToolCall(resp *types.CompletionMessage) bool {
+	for _, content := range resp.Content {
+		if content.ToolCall != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func checkToolCall(tools []types.CompletionTool, call types.CompletionToolCall) error {
+	for _, tool := range tools {
+		if tool.Function.Name != call.Function.Name {
+			continue
+		}
+
+		params := tool.Function.Parameters
+		if params == nil || len(params.Properties) == 0 {
+			return nil
+		}
+
+		args := map[string]any{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+				return fmt.Errorf("arguments of tool call %s are not a JSON object: %w", call.Function.Name, err)
+			}
+		}
+
+		for _, name := range params.Required {
+			if _, ok := args[name]; !ok {
+				return fmt.Errorf("tool call %s is missing the required argument %s", call.Function.Name, name)
+			}
+		}
+		for name := range args {
+			if _, ok := params.Properties[name]; !ok {
+				return fmt.Errorf("tool call %s has the unknown argument %s", call.Function.Name, name)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("tool call %s is not a tool", call.Function.Name)
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type modelFunc func(types.CompletionRequest) (*types.CompletionMessage, error)
+
+func (m modelFunc) Call(_ context.Context, request types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	return m(request)
+}
+
+func TestDraft(t *testing.T) {
+	var (
+		calls []string
+		draft *types.CompletionMessage
+	)
+	e := &Engine{
+		Model: modelFunc(func(request types.CompletionRequest) (*types.CompletionMessage, error) {
+			calls = append(calls, request.Model)
+			if request.Model == "cheap" {
+				return draft, nil
+			}
+			return &types.CompletionMessage{Content: types.Text("verified")}, nil
+		}),
+	}
+
+	request := types.CompletionRequest{
+		Model:      "expensive",
+		DraftModel: "cheap",
+		Tools: []types.CompletionTool{{
+			Function: types.CompletionFunctionDefinition{
+				Name: "weather",
+				Parameters: &openapi3.Schema{
+					Type:     "object",
+					Required: []string{"city"},
+					Properties: openapi3.Schemas{
+						"city": &openapi3.SchemaRef{Value: &openapi3.Schema{Type: "string"}},
+					},
+				},
+			},
+		}},
+	}
+
+	call := func(message *types.CompletionMessage) string {
+		calls, draft = nil, message
+		resp, err := e.call(context.Background(), request, nil)
+		require.NoError(t, err)
+		if resp.Content[0].ToolCall != nil {
+			return resp.Content[0].ToolCall.Function.Name
+		}
+		return resp.Content[0].Text
+	}
+
+	// A good draft is used without calling the expensive model
+	require.Equal(t, "sunny", call(&types.CompletionMessage{Content: types.Text("sunny")}))
+	require.Equal(t, []string{"cheap"}, calls)
+
+	require.Equal(t, "weather", call(&types.CompletionMessage{Content: []types.ContentPart{{
+		ToolCall: &types.CompletionToolCall{Function: types.CompletionFunctionCall{Name: "weather", Arguments: `{"city": "Paris"}`}},
+	}}}))
+	require.Equal(t, []string{"cheap"}, calls)
+
+	// Drafts that fail the checks are overridden by the expensive model
+	for _, rejected := range []*types.CompletionMessage{
+		{},
+		{Content: types.Text("I'm not sure what the weather is")},
+		{Content: []types.ContentPart{{
+			ToolCall: &types.CompletionToolCall{Function: types.CompletionFunctionCall{Name: "forecast", Arguments: `{}`}},
+		}}},
+		{Content: []types.ContentPart{{
+			ToolCall: &types.CompletionToolCall{Function: types.CompletionFunctionCall{Name: "weather", Arguments: `{"town": "Paris"}`}},
+		}}},
+	} {
+		require.Equal(t, "verified", call(rejected))
+		require.Equal(t, []string{"cheap", "expensive"}, calls)
+	}
+
+	request.JSONResponse = true
+	require.Equal(t, "verified", call(&types.CompletionMessage{Content: types.Text("not json")}))
+	require.Equal(t, `{"a": 1}`, call(&types.CompletionMessage{Content: types.Text(`{"a": 1}`)}))
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// DryRunPrefix starts the results of the calls that are not run in a dry run.
+const DryRunPrefix = "Dry run: "
+
+// dryRunBuiltins are the builtin tools that change something outside of the run, so they are not run in a dry run. The
+// builtins that only change something for some of their arguments decide it from the arguments.
+var dryRunBuiltins = map[string]func(input string) bool{
+	"sys.write":           always,
+	"sys.append":          always,
+	"sys.remove":          always,
+	"sys.exec":            always,
+	"sys.download":        always,
+	"sys.http.post":       always,
+	"sys.http.put":        always,
+	"sys.http.patch":      always,
+	"sys.http.request":    unsafeMethod,
+	"sys.csv.write":       always,
+	"sys.archive.create":  always,
+	"sys.archive.extract": always,
+	"sys.clipboard.write": always,
+	"sys.notify":          always,
+	"sys.schedule":        always,
+	"sys.vector.index":    always,
+	"sys.secrets":         changesSecrets,
+	"sys.browser":         browserChanges,
+}
+
+func always(string) bool {
+	return true
+}
+
+// unsafeMethod returns whether the method of the arguments of sys.http.request is not only a read.
+func unsafeMethod(input string) bool {
+	var args struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal([]byte(input), &args)
+	return !safeMethod(types.FirstSet(args.Method, http.MethodGet))
+}
+
+func safeMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// changesSecrets returns whether the action of the arguments of sys.secrets is not only a read.
+func changesSecrets(input string) bool {
+	var args struct {
+		Action string `json:"action"`
+	}
+	_ = json.Unmarshal([]byte(input), &args)
+	return args.Action != "get" && args.Action != "list"
+}
+
+// browserChanges returns whether the arguments of sys.browser run a script in the page, which can act on the site like
+// a user, or save a screenshot to a file.
+func browserChanges(input string) bool {
+	var args struct {
+		Script string `json:"script"`
+		Output string `json:"output"`
+	}
+	_ = json.Unmarshal([]byte(input), &args)
+	return strings.TrimSpace(args.Script) != "" || strings.EqualFold(strings.TrimSpace(args.Output), "screenshot")
+}
+
+// dryRunAction returns what the tool would do with the input, and false if it does not change anything outside of the
+// run and runs in a dry run too, like the tools that only read, prompts and workflows.
+func dryRunAction(tool types.Tool, input string) (string, bool) {
+	switch {
+	case !tool.IsCommand(), tool.IsPrint(), tool.IsWorkflow(), tool.IsUnavailable():
+		return "", false
+	case tool.BuiltinFunc != nil:
+		changes, ok := dryRunBuiltins[tool.ID]
+		return tool.ID, ok && changes(input)
+	case tool.IsOpenAPI():
+		instructions, err := GetOpenAPIInstructions(tool)
+		if err != nil || safeMethod(instructions.Method) {
+			return "", false
+		}
+		return fmt.Sprintf("%s %s%s", strings.ToUpper(instructions.Method), instructions.Server, instructions.Path), true
+	case tool.IsGRPC(), tool.IsAsyncAPI():
+		return fmt.Sprintf("tool [%s]", tool.Parameters.Name), true
+	}
+	command, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(tool.Instructions), "#!"), "\n")
+	return strings.TrimSpace(command), true
+}
+
+// dryRun returns the result of a call that is not run in a dry run, which tells the model what would have run, and
+// reports it to the monitor.
+func (e *Engine) dryRun(tool types.Tool, action, input string) *Return {
+	message := fmt.Sprintf("would run %s with args %s", action, types.FirstSet(strings.TrimSpace(input), "{}"))
+	log.Infof("Dry run of tool [%s]: %s", tool.Parameters.Name, message)
+	e.Progress <- types.CompletionStatus{
+		CompletionID: fmt.Sprint(atomic.AddInt64(&completionID, 1)),
+		DryRun:       message,
+	}
+
+	result := DryRunPrefix + message
+	return &Return{
+		Result: &result,
+	}
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunAction(t *testing.T) {
+	builtin := func(id string) types.Tool {
+		return types.Tool{
+			ID:           id,
+			Instructions: "#!" + id,
+			BuiltinFunc: func(context.Context, []string, string) (string, error) {
+				return "", nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		tool    types.Tool
+		input   string
+		action  string
+		changes bool
+	}{
+		{name: "write", tool: builtin("sys.write"), action: "sys.write", changes: true},
+		{name: "read", tool: builtin("sys.read"), action: "sys.read"},
+		{name: "request get", tool: builtin("sys.http.request"), input: `{"method": "get"}`, action: "sys.http.request"},
+		{name: "request delete", tool: builtin("sys.http.request"), input: `{"method": "DELETE"}`, action: "sys.http.request", changes: true},
+		{name: "secrets list", tool: builtin("sys.secrets"), input: `{"action": "list"}`, action: "sys.secrets"},
+		{name: "secrets set", tool: builtin("sys.secrets"), input: `{"action": "set"}`, action: "sys.secrets", changes: true},
+		{name: "browser text", tool: builtin("sys.browser"), input: `{"url": "https://example.com"}`, action: "sys.browser"},
+		{name: "browser script", tool: builtin("sys.browser"), input: `{"url": "https://example.com", "script": "document.forms[0].submit()"}`, action: "sys.browser", changes: true},
+		{name: "browser screenshot", tool: builtin("sys.browser"), input: `{"url": "https://example.com", "output": "screenshot", "file": "page.png"}`, action: "sys.browser", changes: true},
+		{name: "command", tool: types.Tool{Instructions: "#!/bin/bash ${GPTSCRIPT_TOOL_DIR}/deploy.sh\n"}, action: "/bin/bash ${GPTSCRIPT_TOOL_DIR}/deploy.sh", changes: true},
+		{name: "prompt", tool: types.Tool{Instructions: "Summarize the input"}},
+		{name: "openapi get", tool: types.Tool{Instructions: types.OpenAPIPrefix + ` {"server": "https://api.example.com", "path": "/items", "method": "GET"}`}},
+		{name: "openapi post", tool: types.Tool{Instructions: types.OpenAPIPrefix + ` {"server": "https://api.example.com", "path": "/items", "method": "post"}`}, action: "POST https://api.example.com/items", changes: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, changes := dryRunAction(tt.tool, tt.input)
+			assert.Equal(t, tt.changes, changes)
+			if changes {
+				assert.Equal(t, tt.action, action)
+			}
+		})
+	}
+}
+
+func TestDryRunStart(t *testing.T) {
+	progress := make(chan types.CompletionStatus, 1)
+	e := &Engine{
+		Progress: progress,
+		DryRun:   true,
+	}
+
+	ret, err := e.Start(Context{
+		commonContext: commonContext{
+			Tool: types.Tool{
+				Parameters:   types.Parameters{Name: "deploy"},
+				Instructions: "#!/bin/false",
+			},
+		},
+		Ctx: context.Background(),
+	}, `{"env": "prod"}`)
+	require.NoError(t, err)
+	require.NotNil(t, ret.Result)
+	assert.Equal(t, `Dry run: would run /bin/false with args {"env": "prod"}`, *ret.Result)
+	assert.Equal(t, `would run /bin/false with args {"env": "prod"}`, (<-progress).DryRun)
+}
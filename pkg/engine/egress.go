@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gptscript-ai/gptscript/pkg/egress"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// callingTool returns the tool that called the tool of the context, or the tool itself if it was not called by one.
+func callingTool(ctx context.Context, tool types.Tool) types.Tool {
+	if c, ok := FromContext(ctx); ok && c.Parent != nil {
+		return c.Parent.Tool
+	}
+	return tool
+}
+
+// egressContext returns the context that restricts the requests of the tool to its allowed hosts. The builtin tools,
+// like sys.http.get, and the tools of OpenAPI definitions are restricted to the allowed hosts of the tool that calls
+// them, since they are not written by the author of the script. Blocked requests are reported to the monitor.
+func (e *Engine) egressContext(ctx context.Context, tool types.Tool) context.Context {
+	allowed := tool.AllowedHosts
+	if len(allowed) == 0 && (tool.BuiltinFunc != nil || tool.IsOpenAPI()) {
+		allowed = callingTool(ctx, tool).AllowedHosts
+	}
+	return egress.WithContext(ctx, allowed, func(host string) {
+		log.Infof("Blocked request of tool [%s] to %s, it is not an allowed host", tool.Parameters.Name, host)
+		e.Progress <- types.CompletionStatus{
+			CompletionID:  fmt.Sprint(atomic.AddInt64(&completionID, 1)),
+			EgressBlocked: fmt.Sprintf("request of tool [%s] to %s was blocked, it is not an allowed host", tool.Parameters.Name, host),
+		}
+	})
+}
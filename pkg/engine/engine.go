@@ -8,6 +8,9 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/gptscript-ai/gptscript/pkg/features"
+	"github.com/gptscript-ai/gptscript/pkg/sandbox"
+	"github.com/gptscript-ai/gptscript/pkg/step"
 	"github.com/gptscript-ai/gptscript/pkg/system"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/gptscript-ai/gptscript/pkg/version"
@@ -23,12 +26,33 @@ type RuntimeManager interface {
 	GetContext(ctx context.Context, tool types.Tool, cmd, env []string) (string, []string, error)
 }
 
+// CommandRuntimeManager is implemented by runtime managers that change the command of a tool before it runs.
+type CommandRuntimeManager interface {
+	Command(tool types.Tool, cmd []string) []string
+}
+
+// DirsRuntimeManager is implemented by runtime managers that keep the repositories and runtimes of tools in
+// directories, which the sandbox lets the commands of tools read.
+type DirsRuntimeManager interface {
+	Dirs() []string
+}
+
 type Engine struct {
 	Model          Model
 	RuntimeManager RuntimeManager
 	Env            []string
 	Progress       chan<- types.CompletionStatus
 	Ports          *Ports
+	// ContainerRuntime is the command that runs the tools that have a container, DefaultContainerRuntime if not set
+	ContainerRuntime string
+	// Sandbox runs commands so that they can only do what their tools declare, commands are not sandboxed if not set
+	Sandbox *sandbox.Sandbox
+	// FSRoot is the directory that the file builtins, like sys.read, are limited to when the calling tool does not
+	// declare its allowed paths, the working directory if not set
+	FSRoot string
+	// DryRun does not run the tools that change something outside of the run, like commands, writes of files and
+	// requests that are not GETs, and tells the model what they would have run instead
+	DryRun bool
 }
 
 type State struct {
@@ -53,6 +77,8 @@ type CallResult struct {
 	CallID string `json:"callID,omitempty"`
 	Result string `json:"result,omitempty"`
 	User   string `json:"user,omitempty"`
+	// System is a note to the model, added to the conversation before the message of the user
+	System string `json:"system,omitempty"`
 }
 
 type commonContext struct {
@@ -74,6 +100,8 @@ type Context struct {
 	Parent       *Context
 	Program      *types.Program
 	ToolCategory ToolCategory
+	// Stdin is the output of the tool the input of this tool is piped from, if it has one
+	Stdin *string
 }
 
 type ToolCategory string
@@ -81,6 +109,9 @@ type ToolCategory string
 const (
 	CredentialToolCategory ToolCategory = "credential"
 	ContextToolCategory    ToolCategory = "context"
+	InputToolCategory      ToolCategory = "input"
+	ValidatorToolCategory  ToolCategory = "validator"
+	ShardToolCategory      ToolCategory = "shard"
 	NoCategory             ToolCategory = ""
 )
 
@@ -137,9 +168,9 @@ func NewContext(ctx context.Context, prg *types.Program) Context {
 }
 
 func (c *Context) SubCall(ctx context.Context, toolID, callID string, toolCategory ToolCategory) (Context, error) {
-	tool, ok := c.Program.ToolSet[toolID]
-	if !ok {
-		return Context{}, fmt.Errorf("failed to file tool for id [%s]", toolID)
+	tool, err := c.Program.GetToolByID(toolID)
+	if err != nil {
+		return Context{}, err
 	}
 
 	if callID == "" {
@@ -172,17 +203,41 @@ func (c *Context) WrappedContext() context.Context {
 func (e *Engine) Start(ctx Context, input string) (*Return, error) {
 	tool := ctx.Tool
 
+	if ctx.Stdin != nil && (tool.IsHTTP() || tool.IsDaemon() || tool.IsOpenAPI() || tool.IsGRPC() || tool.IsAsyncAPI()) {
+		return nil, fmt.Errorf("tool [%s] can not pipe its input from [%s], only commands and prompts can", tool.Parameters.Name, tool.InputFrom)
+	}
+
+	// Credential tools still run, since the tools that only read need their credentials too
+	if e.DryRun && ctx.ToolCategory != CredentialToolCategory {
+		if action, ok := dryRunAction(tool, input); ok {
+			return e.dryRun(tool, action, input), nil
+		}
+	}
+
 	if tool.IsCommand() {
 		if tool.IsHTTP() {
 			return e.runHTTP(ctx.Ctx, ctx.Program, tool, input)
 		} else if tool.IsDaemon() {
 			return e.runDaemon(ctx.Ctx, ctx.Program, tool, input)
 		} else if tool.IsOpenAPI() {
-			return e.runOpenAPI(tool, input)
+			return e.runOpenAPI(e.egressContext(ctx.WrappedContext(), tool), tool, input)
+		} else if tool.IsGRPC() {
+			return e.runGRPC(ctx.Ctx, tool, input)
+		} else if tool.IsAsyncAPI() {
+			return e.runAsyncAPI(ctx.Ctx, tool, input)
 		} else if tool.IsPrint() {
 			return e.runPrint(tool)
+		} else if tool.IsWorkflow() {
+			return nil, fmt.Errorf("workflow tool [%s] can only be run by the runner", tool.Parameters.Name)
+		} else if tool.IsUnavailable() {
+			return nil, fmt.Errorf("tool [%s] is unavailable, it could not be loaded from %s: %s", tool.Parameters.Name,
+				tool.Source.Location, strings.TrimPrefix(tool.Instructions, types.UnavailablePrefix+"\n"))
+		}
+		s, err := e.runCommand(ctx.WrappedContext(), tool, input, ctx.Stdin, ctx.ToolCategory)
+		if err != nil {
+			return nil, err
 		}
-		s, err := e.runCommand(ctx.WrappedContext(), tool, input, ctx.ToolCategory)
+		s, err = formatOutput(tool, s)
 		if err != nil {
 			return nil, err
 		}
@@ -197,6 +252,7 @@ func (e *Engine) Start(ctx Context, input string) (*Return, error) {
 
 	completion := types.CompletionRequest{
 		Model:                tool.Parameters.ModelName,
+		DraftModel:           tool.Parameters.DraftModelName,
 		MaxTokens:            tool.Parameters.MaxTokens,
 		JSONResponse:         tool.Parameters.JSONResponse,
 		Cache:                tool.Parameters.Cache,
@@ -228,7 +284,14 @@ func (e *Engine) Start(ctx Context, input string) (*Return, error) {
 		})
 	}
 
-	return e.complete(ctx.Ctx, &State{
+	if ctx.Stdin != nil {
+		completion.Messages = append(completion.Messages, types.CompletionMessage{
+			Role:    types.CompletionMessageRoleTypeUser,
+			Content: types.Text(*ctx.Stdin),
+		})
+	}
+
+	return e.complete(ctx.Ctx, ctx.Tool, &State{
 		Completion: completion,
 	})
 }
@@ -260,7 +323,11 @@ func addUpdateSystem(ctx Context, tool types.Tool, msgs []types.CompletionMessag
 	return append([]types.CompletionMessage{msg}, msgs...)
 }
 
-func (e *Engine) complete(ctx context.Context, state *State) (*Return, error) {
+func (e *Engine) complete(ctx context.Context, tool types.Tool, state *State) (*Return, error) {
+	if err := step.Model(ctx, tool.Parameters.Name, state.Completion); err != nil {
+		return nil, err
+	}
+
 	var (
 		progress = make(chan types.CompletionStatus)
 		ret      = Return{
@@ -284,7 +351,7 @@ func (e *Engine) complete(ctx context.Context, state *State) (*Return, error) {
 		}
 	}()
 
-	resp, err := e.Model.Call(ctx, state.Completion, progress)
+	resp, err := e.call(ctx, state.Completion, progress)
 	if err != nil {
 		return nil, err
 	}
@@ -330,6 +397,12 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 		if result.CallID != "" {
 			state.Results[result.CallID] = result
 		}
+		if result.System != "" {
+			state.Completion.Messages = append(state.Completion.Messages, types.CompletionMessage{
+				Role:    types.CompletionMessageRoleTypeSystem,
+				Content: types.Text(result.System),
+			})
+		}
 		if result.User != "" {
 			added = true
 			state.Completion.Messages = append(state.Completion.Messages, types.CompletionMessage{
@@ -358,6 +431,14 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 		return &ret, nil
 	}
 
+	// called are the tools that were already called in the conversation, their usage has been added to it
+	called := map[string]bool{}
+	for _, message := range state.Completion.Messages {
+		if message.Role == types.CompletionMessageRoleTypeTool && message.ToolCall != nil {
+			called[message.ToolCall.Function.Name] = true
+		}
+	}
+
 	for _, content := range state.Completion.Messages[len(state.Completion.Messages)-1].Content {
 		if content.ToolCall == nil {
 			continue
@@ -374,10 +455,19 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 				content.ToolCall.ID, version.ProgramName)
 		}
 
+		text := result.Result
+		if features.Enabled(ctx.Ctx, features.ToolResultTags) {
+			text = "<tool-result>\n" + strings.TrimRight(text, "\n") + "\n</tool-result>"
+		}
+		if usage := toolUsage(ctx, result.ToolID); usage != "" && !called[pending.Function.Name] {
+			text = fmt.Sprintf("%s\n\nUsage of the tool %s, for this and the next calls:\n%s", strings.TrimRight(text, "\n"), pending.Function.Name, usage)
+		}
+		called[pending.Function.Name] = true
+
 		added = true
 		state.Completion.Messages = append(state.Completion.Messages, types.CompletionMessage{
 			Role:     types.CompletionMessageRoleTypeTool,
-			Content:  types.Text(result.Result),
+			Content:  types.Text(text),
 			ToolCall: &pending,
 		})
 	}
@@ -387,5 +477,12 @@ func (e *Engine) Continue(ctx Context, state *State, results ...CallResult) (*Re
 	}
 
 	state.Completion.Messages = addUpdateSystem(ctx, ctx.Tool, state.Completion.Messages)
-	return e.complete(ctx.Ctx, state)
+	return e.complete(ctx.Ctx, ctx.Tool, state)
+}
+
+func toolUsage(ctx Context, toolID string) string {
+	if ctx.Program == nil {
+		return ""
+	}
+	return ctx.Program.ToolSet[toolID].Usage
 }
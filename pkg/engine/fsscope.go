@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/gptscript-ai/gptscript/pkg/fsscope"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// fsContext returns the context that limits the file builtins, like sys.read and sys.write, to the allowed paths of
+// the tool that calls them, or to the root of the engine if it declares none.
+func (e *Engine) fsContext(ctx context.Context, tool types.Tool) (context.Context, error) {
+	roots := callingTool(ctx, tool).AllowedPaths
+	if len(roots) == 0 {
+		roots = []string{types.FirstSet(e.FSRoot, ".")}
+	}
+	scope, err := fsscope.New(roots...)
+	if err != nil {
+		return nil, err
+	}
+	return fsscope.WithContext(ctx, scope), nil
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// GRPCInstructions describes the RPC a tool generated from a gRPC service will call.
+type GRPCInstructions struct {
+	Server    string `json:"server"`              // host:port of the server
+	Plaintext bool   `json:"plaintext,omitempty"` // true to not use TLS
+	Method    string `json:"method"`              // fully qualified method, like helloworld.Greeter/SayHello
+	ProtoFile string `json:"protoFile,omitempty"` // absolute path of the proto file, empty to use server reflection
+}
+
+// GetGRPCInstructions extracts the GRPCInstructions from the instructions of a tool
+// that was generated from a gRPC service.
+func GetGRPCInstructions(tool types.Tool) (GRPCInstructions, error) {
+	var instructions GRPCInstructions
+	_, inst, _ := strings.Cut(tool.Instructions, types.GRPCPrefix+" ")
+	inst = strings.TrimPrefix(inst, "'")
+	inst = strings.TrimSuffix(inst, "'")
+	if err := json.Unmarshal([]byte(inst), &instructions); err != nil {
+		return GRPCInstructions{}, fmt.Errorf("failed to unmarshal tool instructions: %w", err)
+	}
+	return instructions, nil
+}
+
+// GRPCArgs returns the grpcurl arguments needed to connect to server. TLS and metadata settings are read from
+// the GPTSCRIPT_<HOST>_CACERT, _CERT, _KEY, _BEARER_TOKEN and _GRPC_METADATA variables in envMap. The metadata
+// variable holds one "key: value" pair per line.
+func GRPCArgs(server string, plaintext bool, protoFile string, envMap map[string]string) []string {
+	var args []string
+	if plaintext {
+		args = append(args, "-plaintext")
+	}
+
+	if protoFile != "" {
+		args = append(args, "-import-path", filepath.Dir(protoFile), "-proto", filepath.Base(protoFile))
+	}
+
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+	}
+	prefix := "GPTSCRIPT_" + env.ToEnvLike(host) + "_"
+
+	for _, flag := range []string{"cacert", "cert", "key"} {
+		if v := envMap[prefix+strings.ToUpper(flag)]; v != "" {
+			args = append(args, "-"+flag, v)
+		}
+	}
+
+	if token := envMap[prefix+"BEARER_TOKEN"]; token != "" {
+		args = append(args, "-H", "authorization: Bearer "+token)
+	}
+
+	for _, line := range strings.Split(envMap[prefix+"GRPC_METADATA"], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			args = append(args, "-H", line)
+		}
+	}
+
+	return args
+}
+
+// runGRPC runs a tool that was generated from a gRPC service by calling the RPC with grpcurl.
+// The tools Instructions field will be in the format "#!sys.grpc '{Instructions JSON}'",
+// where {Instructions JSON} is a JSON string of type GRPCInstructions.
+func (e *Engine) runGRPC(ctx context.Context, tool types.Tool, input string) (*Return, error) {
+	envMap := map[string]string{}
+
+	for _, env := range e.Env {
+		k, v, _ := strings.Cut(env, "=")
+		envMap[k] = v
+	}
+
+	instructions, err := GetGRPCInstructions(tool)
+	if err != nil {
+		return nil, err
+	}
+
+	var params struct {
+		Request string `json:"request"`
+	}
+	if input != "" {
+		if err := json.Unmarshal([]byte(input), &params); err != nil {
+			return nil, fmt.Errorf("failed to parse input: %w", err)
+		}
+	}
+	if params.Request == "" {
+		params.Request = "{}"
+	}
+
+	args := GRPCArgs(instructions.Server, instructions.Plaintext, instructions.ProtoFile, envMap)
+	args = append(args, "-d", "@", instructions.Server, instructions.Method)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "grpcurl", args...)
+	cmd.Stdin = strings.NewReader(params.Request)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to call %s on %s: %w: %s", instructions.Method, instructions.Server, err, strings.TrimSpace(stderr.String()))
+	}
+
+	result := stdout.String()
+	return &Return{
+		Result: &result,
+	}, nil
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
@@ -74,7 +75,7 @@ func (e *Engine) runHTTP(ctx context.Context, prg *types.Program, tool types.Too
 		req.Header.Set("Content-Type", "text/plain")
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package engine
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,7 +11,9 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 
+	"github.com/gptscript-ai/gptscript/pkg/egress"
 	"github.com/gptscript-ai/gptscript/pkg/env"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/tidwall/gjson"
@@ -45,13 +49,52 @@ type OpenAPIInstructions struct {
 	PathParameters   []Parameter      `json:"pathParameters"`
 	HeaderParameters []Parameter      `json:"headerParameters"`
 	CookieParameters []Parameter      `json:"cookieParameters"`
+	// UserAgent is the User-Agent of the requests, from the x-gptscript-user-agent extension of the definition
+	UserAgent string `json:"userAgent,omitempty"`
+	// RequestIDHeader is the header that every request is sent with a new UUID in, like X-Request-ID, from the
+	// x-gptscript-request-id-header extension of the definition
+	RequestIDHeader string `json:"requestIDHeader,omitempty"`
+}
+
+// GetOpenAPIInstructions extracts the OpenAPIInstructions from the instructions of a tool
+// that was generated from an OpenAPI definition.
+func GetOpenAPIInstructions(tool types.Tool) (OpenAPIInstructions, error) {
+	var instructions OpenAPIInstructions
+	_, inst, _ := strings.Cut(tool.Instructions, types.OpenAPIPrefix+" ")
+	inst = strings.TrimPrefix(inst, "'")
+	inst = strings.TrimSuffix(inst, "'")
+	if err := json.Unmarshal([]byte(inst), &instructions); err != nil {
+		return OpenAPIInstructions{}, fmt.Errorf("failed to unmarshal tool instructions: %w", err)
+	}
+	return instructions, nil
+}
+
+// SecurityEnvNames returns the names of the environment variables that are needed to satisfy the
+// given security scheme for requests to host.
+func SecurityEnvNames(host string, info SecurityInfo) []string {
+	envName := "GPTSCRIPT_" + env.ToEnvLike(host) + "_" + env.ToEnvLike(info.Name)
+	if info.Type == "http" && info.Scheme == "basic" {
+		return []string{envName + "_USERNAME", envName + "_PASSWORD"}
+	}
+	return []string{envName}
+}
+
+// newRequestID returns a random UUID, version 4, to identify a request.
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
 }
 
 // runOpenAPI runs a tool that was generated from an OpenAPI definition.
 // The tool itself will have instructions regarding the HTTP request that needs to be made.
 // The tools Instructions field will be in the format "#!sys.openapi '{Instructions JSON}'",
 // where {Instructions JSON} is a JSON string of type OpenAPIInstructions.
-func (e *Engine) runOpenAPI(tool types.Tool, input string) (*Return, error) {
+func (e *Engine) runOpenAPI(ctx context.Context, tool types.Tool, input string) (*Return, error) {
 	envMap := map[string]string{}
 
 	for _, env := range e.Env {
@@ -60,12 +103,9 @@ func (e *Engine) runOpenAPI(tool types.Tool, input string) (*Return, error) {
 	}
 
 	// Extract the instructions from the tool to determine server, path, method, etc.
-	var instructions OpenAPIInstructions
-	_, inst, _ := strings.Cut(tool.Instructions, types.OpenAPIPrefix+" ")
-	inst = strings.TrimPrefix(inst, "'")
-	inst = strings.TrimSuffix(inst, "'")
-	if err := json.Unmarshal([]byte(inst), &instructions); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal tool instructions: %w", err)
+	instructions, err := GetOpenAPIInstructions(tool)
+	if err != nil {
+		return nil, err
 	}
 
 	// Handle path parameters
@@ -78,7 +118,7 @@ func (e *Engine) runOpenAPI(tool types.Tool, input string) (*Return, error) {
 	}
 
 	// Set up the request
-	req, err := http.NewRequest(instructions.Method, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, instructions.Method, u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -99,6 +139,25 @@ func (e *Engine) runOpenAPI(tool types.Tool, input string) (*Return, error) {
 		}
 	}
 
+	// The User-Agent and the request ID header of the definition can be set for the server with the environment, like
+	// GPTSCRIPT_API_EXAMPLE_COM_USER_AGENT
+	if userAgent := types.FirstSet(envMap["GPTSCRIPT_"+env.ToEnvLike(u.Hostname())+"_USER_AGENT"], instructions.UserAgent); userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if header := types.FirstSet(envMap["GPTSCRIPT_"+env.ToEnvLike(u.Hostname())+"_REQUEST_ID_HEADER"], instructions.RequestIDHeader); header != "" {
+		id, err := newRequestID()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(header, id)
+		// The request is reported to the monitor with its ID, to find it in the logs of the server
+		e.Progress <- types.CompletionStatus{
+			CompletionID: fmt.Sprint(atomic.AddInt64(&completionID, 1)),
+			RequestID:    id,
+			APIRequest:   fmt.Sprintf("%s %s://%s%s", req.Method, u.Scheme, u.Host, u.Path),
+		}
+	}
+
 	// Handle query parameters
 	req.URL.RawQuery = handleQueryParameters(req.URL.Query(), instructions.QueryParameters, input).Encode()
 
@@ -146,7 +205,8 @@ func (e *Engine) runOpenAPI(tool types.Tool, input string) (*Return, error) {
 	}
 
 	// Make the request
-	resp, err := http.DefaultClient.Do(req)
+	client := http.Client{Transport: egress.Transport(ctx, nil)}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -172,15 +232,7 @@ func handleAuths(req *http.Request, envMap map[string]string, infoSets [][]Secur
 	for _, infoSet := range infoSets {
 		var missing []string // Keep track of any missing environment variables
 		for _, info := range infoSet {
-			envNames := []string{"GPTSCRIPT_" + env.ToEnvLike(req.URL.Hostname()) + "_" + env.ToEnvLike(info.Name)}
-			if info.Type == "http" && info.Scheme == "basic" {
-				envNames = []string{
-					"GPTSCRIPT_" + env.ToEnvLike(req.URL.Hostname()) + "_" + env.ToEnvLike(info.Name) + "_USERNAME",
-					"GPTSCRIPT_" + env.ToEnvLike(req.URL.Hostname()) + "_" + env.ToEnvLike(info.Name) + "_PASSWORD",
-				}
-			}
-
-			for _, envName := range envNames {
+			for _, envName := range SecurityEnvNames(req.URL.Hostname(), info) {
 				if _, ok := envMap[envName]; !ok {
 					missing = append(missing, envName)
 				}
@@ -201,14 +253,11 @@ func handleAuths(req *http.Request, envMap map[string]string, infoSets [][]Secur
 				case "header":
 					req.Header.Set(info.APIKeyName, envMap[envName])
 				case "query":
-					v := url.Values{}
-					v.Add(info.APIKeyName, envMap[envName])
+					v := req.URL.Query()
+					v.Set(info.APIKeyName, envMap[envName])
 					req.URL.RawQuery = v.Encode()
 				case "cookie":
-					req.AddCookie(&http.Cookie{
-						Name:  info.APIKeyName,
-						Value: envMap[envName],
-					})
+					setCookie(req, info.APIKeyName, envMap[envName])
 				}
 			case "http":
 				switch info.Scheme {
@@ -223,7 +272,24 @@ func handleAuths(req *http.Request, envMap map[string]string, infoSets [][]Secur
 	}
 
 	return fmt.Errorf("did not find the needed environment variables for any of the security options. "+
-		"At least one of these sets of environment variables must be provided: %v", missingVariables)
+		"At least one of these sets of environment variables must be provided, either directly or through a credential "+
+		"stored for %s: %v", req.URL.Hostname(), missingVariables)
+}
+
+// setCookie sets the named cookie on the request, replacing any cookie of the same name that is already on it.
+// The Cookie header is rebuilt so that the request carries a single header, as required by RFC 6265.
+func setCookie(req *http.Request, name, value string) {
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != name {
+			req.AddCookie(c)
+		}
+	}
+	req.AddCookie(&http.Cookie{
+		Name:  name,
+		Value: value,
+	})
 }
 
 // handleQueryParameters extracts each query parameter from the input JSON and adds it to the URL query.
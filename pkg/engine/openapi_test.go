@@ -1,10 +1,15 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"regexp"
 	"testing"
 
+	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -230,6 +235,74 @@ func TestQueryParameterSerialization(t *testing.T) {
 	}
 }
 
+func TestAPIKeyCookieAuth(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com/v1/pets", nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "stale"})
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+
+	envMap := map[string]string{
+		"GPTSCRIPT_API_EXAMPLE_COM_MYCOOKIE": "secret",
+	}
+	infos := [][]SecurityInfo{
+		{
+			{
+				Name:       "MyCookie",
+				Type:       "apiKey",
+				In:         "cookie",
+				APIKeyName: "session",
+			},
+		},
+	}
+
+	require.NoError(t, handleAuths(req, envMap, infos))
+	require.Len(t, req.Header.Values("Cookie"), 1)
+
+	session, err := req.Cookie("session")
+	require.NoError(t, err)
+	require.Equal(t, "secret", session.Value)
+
+	theme, err := req.Cookie("theme")
+	require.NoError(t, err)
+	require.Equal(t, "dark", theme.Value)
+}
+
+func TestOpenAPIUserAgentAndRequestID(t *testing.T) {
+	var header http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		header = req.Header
+	}))
+	defer s.Close()
+
+	inst, err := json.Marshal(OpenAPIInstructions{
+		Server:          s.URL,
+		Path:            "/pets",
+		Method:          http.MethodGet,
+		UserAgent:       "pets-client/1.0",
+		RequestIDHeader: "X-Request-ID",
+	})
+	require.NoError(t, err)
+
+	progress := make(chan types.CompletionStatus, 1)
+	e := &Engine{
+		// The environment overrides the User-Agent of the definition
+		Env:      []string{"GPTSCRIPT_127_0_0_1_USER_AGENT=gateway-client/2.0"},
+		Progress: progress,
+	}
+	_, err = e.runOpenAPI(context.Background(), types.Tool{
+		Instructions: types.OpenAPIPrefix + " '" + string(inst) + "'",
+	}, "{}")
+	require.NoError(t, err)
+
+	require.Equal(t, "gateway-client/2.0", header.Get("User-Agent"))
+	id := header.Get("X-Request-ID")
+	require.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+
+	status := <-progress
+	require.Equal(t, id, status.RequestID)
+	require.Equal(t, "GET "+s.URL+"/pets", status.APIRequest)
+}
+
 func getParameters(style string, explode bool) []Parameter {
 	return []Parameter{
 		{
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// maxSchemaDepth is the depth of the nested objects and arrays that the schema of an output describes
+const maxSchemaDepth = 5
+
+// formatOutput checks the output of a command against the output format of its tool. JSON output is compacted,
+// after dropping the text that the command printed around it, like log lines, and fails the call if it is not
+// valid JSON. With the output schema of the tool, a compact schema of the JSON is added before it.
+func formatOutput(tool types.Tool, output string) (string, error) {
+	if tool.OutputFormat != "json" {
+		return output, nil
+	}
+
+	data, ok := findJSON(output)
+	if !ok {
+		return "", fmt.Errorf("tool [%s] declares JSON output, but its output is not valid JSON: %s", tool.Name, truncate(output, 200))
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return "", err
+	}
+	if !tool.OutputSchema {
+		return buf.String(), nil
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Schema: %s\n%s", schemaOf(v, 0), buf.String()), nil
+}
+
+// findJSON returns the output if it is JSON, or else the JSON object or array that it has between the text before
+// and after it.
+func findJSON(output string) ([]byte, bool) {
+	output = strings.TrimSpace(output)
+	if json.Valid([]byte(output)) {
+		return []byte(output), true
+	}
+
+	start := strings.IndexAny(output, "{[")
+	if start < 0 {
+		return nil, false
+	}
+	closing := "}"
+	if output[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(output, closing)
+	if end < start {
+		return nil, false
+	}
+	data := []byte(output[start : end+1])
+	return data, json.Valid(data)
+}
+
+// schemaOf returns a compact schema of a JSON value, like {id:number,tags:[string]}. The schema of an array is the
+// schema of its first item.
+func schemaOf(v any, depth int) string {
+	switch t := v.(type) {
+	case map[string]any:
+		if depth >= maxSchemaDepth {
+			return "object"
+		}
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fields := make([]string, 0, len(keys))
+		for _, key := range keys {
+			fields = append(fields, key+":"+schemaOf(t[key], depth+1))
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	case []any:
+		if len(t) == 0 {
+			return "[]"
+		}
+		if depth >= maxSchemaDepth {
+			return "array"
+		}
+		return "[" + schemaOf(t[0], depth+1) + "]"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatOutput(t *testing.T) {
+	text := types.Tool{Parameters: types.Parameters{Name: "text"}}
+	out, err := formatOutput(text, "not json\n")
+	require.NoError(t, err)
+	assert.Equal(t, "not json\n", out)
+
+	tool := types.Tool{Parameters: types.Parameters{Name: "list", OutputFormat: "json"}}
+	out, err = formatOutput(tool, "{\n  \"items\": [1, 2]\n}\n")
+	require.NoError(t, err)
+	assert.Equal(t, `{"items":[1,2]}`, out)
+
+	out, err = formatOutput(tool, "fetching...\n[{\"id\": 1}]\ndone\n")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":1}]`, out)
+
+	_, err = formatOutput(tool, "{\"items\": [1, 2}")
+	assert.ErrorContains(t, err, "tool [list] declares JSON output, but its output is not valid JSON")
+
+	tool.OutputSchema = true
+	out, err = formatOutput(tool, `{"name": "a", "tags": ["x"], "owner": {"id": 1, "admin": false}, "parent": null, "children": []}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Schema: {children:[],name:string,owner:{admin:boolean,id:number},parent:null,tags:[string]}\n"+
+		`{"name":"a","tags":["x"],"owner":{"id":1,"admin":false},"parent":null,"children":[]}`, out)
+}
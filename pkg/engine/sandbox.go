@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/sandbox"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// sandboxPolicy returns what the commands of the tool may do in the sandbox: write the working directory, and the
+// network and the paths the tool declares in its Sandbox. Daemons always use the network, since they are called
+// on their port.
+func sandboxPolicy(tool types.Tool) (sandbox.Policy, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return sandbox.Policy{}, err
+	}
+
+	policy := sandbox.Policy{
+		Workspace: cwd,
+		Network:   tool.IsDaemon(),
+	}
+	for _, allow := range tool.Sandbox {
+		if strings.EqualFold(allow, "network") {
+			policy.Network = true
+			continue
+		}
+		if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(allow, "~/") {
+			allow = filepath.Join(home, allow[2:])
+		}
+		if !filepath.IsAbs(allow) {
+			allow = filepath.Join(cwd, allow)
+		}
+		policy.Write = append(policy.Write, filepath.Clean(allow))
+	}
+	return policy, nil
+}
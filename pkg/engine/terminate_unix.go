@@ -0,0 +1,39 @@
+//go:build !windows
+
+package engine
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// terminateOnCancel stops the command with SIGTERM when its context is canceled, so it can clean up, and kills it if it
+// did not exit after terminateDelay. The command runs in its own process group, so the processes that it started stop
+// with it, unless it reads the terminal, which the processes of another process group can not.
+func terminateOnCancel(cmd *exec.Cmd) {
+	// Wait returns even if a process that the command started keeps the output of the command open
+	cmd.WaitDelay = terminateDelay
+
+	if f, ok := cmd.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGTERM)
+		}
+		return
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		time.AfterFunc(terminateDelay, func() {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package engine
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminateOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// The shell waits for the process that it started, which must stop with it
+	cmd := exec.CommandContext(ctx, "sh", "-c", "sleep 60 & wait")
+	terminateOnCancel(cmd)
+	require.NoError(t, cmd.Start())
+
+	start := time.Now()
+	cancel()
+	assert.Error(t, cmd.Wait())
+	assert.Less(t, time.Since(start), terminateDelay)
+}
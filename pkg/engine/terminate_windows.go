@@ -0,0 +1,11 @@
+package engine
+
+import (
+	"os/exec"
+)
+
+// terminateOnCancel kills the command when the context of the command is canceled, and does not wait for the
+// processes that it started for longer than terminateDelay.
+func terminateOnCancel(cmd *exec.Cmd) {
+	cmd.WaitDelay = terminateDelay
+}
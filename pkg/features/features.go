@@ -0,0 +1,115 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BoundedParallelism runs at most as many of the tool calls of a completion at a time as there are CPUs, instead
+	// of all of them at once
+	BoundedParallelism = "bounded-parallelism"
+	// ToolResultTags wraps the results of tool calls in <tool-result> tags, so models tell them apart from the
+	// instructions of the script
+	ToolResultTags = "tool-result-tags"
+)
+
+// Flag is a feature flag that gates an experimental behavior.
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+var known = map[string]Flag{
+	BoundedParallelism: {
+		Name:        BoundedParallelism,
+		Description: "Run at most as many of the tool calls of a completion at a time as there are CPUs",
+	},
+	ToolResultTags: {
+		Name:        ToolResultTags,
+		Description: "Wrap the results of tool calls in <tool-result> tags in the requests to the model",
+	},
+}
+
+// Known returns the feature flags, sorted by name.
+func Known() (result []Flag) {
+	for _, flag := range known {
+		result = append(result, flag)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return
+}
+
+// Set is the feature flags that are set, by name. The flags that are not set have their default.
+type Set map[string]bool
+
+// Parse parses a comma separated list of feature flags to enable, like "bounded-parallelism,tool-result-tags", where
+// a flag can also be set with a value, like tool-result-tags=false.
+func Parse(spec string) (Set, error) {
+	result := Set{}
+	for _, flag := range strings.Split(spec, ",") {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(flag), "=")
+		if name == "" {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown feature flag %q, see gptscript features", name)
+		}
+		enabled := true
+		if hasValue {
+			var err error
+			if enabled, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid value of the feature flag %s: %w", name, err)
+			}
+		}
+		result[name] = enabled
+	}
+	return result, nil
+}
+
+// Merge returns the flags of s, overridden by the flags of overrides.
+func (s Set) Merge(overrides Set) Set {
+	result := maps.Clone(s)
+	if result == nil {
+		result = Set{}
+	}
+	maps.Copy(result, overrides)
+	return result
+}
+
+// Enabled returns whether the flag is enabled, or its default if it is not set.
+func (s Set) Enabled(name string) bool {
+	if enabled, ok := s[name]; ok {
+		return enabled
+	}
+	return known[name].Default
+}
+
+// All returns the value of every known flag, to report them in the metadata of runs.
+func (s Set) All() map[string]bool {
+	result := make(map[string]bool, len(known))
+	for name := range known {
+		result[name] = s.Enabled(name)
+	}
+	return result
+}
+
+type featuresKey struct{}
+
+// WithFeatures returns a context with the feature flags of a run.
+func WithFeatures(ctx context.Context, s Set) context.Context {
+	return context.WithValue(ctx, featuresKey{}, s)
+}
+
+// Enabled returns whether the flag is enabled for the run of the context.
+func Enabled(ctx context.Context, name string) bool {
+	s, _ := ctx.Value(featuresKey{}).(Set)
+	return s.Enabled(name)
+}
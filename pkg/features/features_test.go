@@ -0,0 +1,36 @@
+package features
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	s, err := Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, s)
+
+	s, err = Parse("bounded-parallelism, tool-result-tags=false")
+	require.NoError(t, err)
+	assert.Equal(t, Set{BoundedParallelism: true, ToolResultTags: false}, s)
+
+	_, err = Parse("unknown")
+	assert.ErrorContains(t, err, `unknown feature flag "unknown"`)
+	_, err = Parse("tool-result-tags=maybe")
+	assert.ErrorContains(t, err, "invalid value of the feature flag tool-result-tags")
+}
+
+func TestEnabled(t *testing.T) {
+	config := Set{BoundedParallelism: true, ToolResultTags: true}
+	s := config.Merge(Set{ToolResultTags: false})
+	assert.Equal(t, map[string]bool{BoundedParallelism: true, ToolResultTags: false}, s.All())
+	assert.True(t, config[ToolResultTags], "merging must not change the flags it overrides")
+
+	ctx := WithFeatures(context.Background(), s)
+	assert.True(t, Enabled(ctx, BoundedParallelism))
+	assert.False(t, Enabled(ctx, ToolResultTags))
+	assert.False(t, Enabled(context.Background(), BoundedParallelism))
+}
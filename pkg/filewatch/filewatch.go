@@ -0,0 +1,118 @@
+package filewatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Change is a file that was created, modified or removed.
+type Change struct {
+	Path string `json:"path"`
+	// Op is created, modified or removed
+	Op string `json:"op"`
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// snapshot returns the state of the file at path, or of the files and directories under it whose base names match the
+// pattern, all of them if it is empty.
+func snapshot(path, pattern string) (map[string]fileState, error) {
+	result := map[string]fileState{}
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The path does not exist yet, or the file was removed during the walk
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if p == path && d.IsDir() {
+			return nil
+		}
+		if p != path && pattern != "" {
+			if matched, _ := filepath.Match(pattern, d.Name()); !matched {
+				return nil
+			}
+		}
+
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		result[p] = fileState{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			isDir:   d.IsDir(),
+		}
+		return nil
+	})
+	return result, err
+}
+
+func diff(before, after map[string]fileState) (changes []Change) {
+	for path, state := range after {
+		old, ok := before[path]
+		if !ok {
+			changes = append(changes, Change{Path: path, Op: "created"})
+		} else if !state.isDir && (old.size != state.size || !old.modTime.Equal(state.modTime)) {
+			changes = append(changes, Change{Path: path, Op: "modified"})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, Change{Path: path, Op: "removed"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// Wait blocks until a file or directory at path, or under it if it is a directory, whose base name matches the
+// pattern is created, modified or removed, and returns the changes. The files are checked every interval, so the
+// files written by other processes are seen without depending on the notifications of the OS, and changes that come
+// in quick succession are returned together. Wait returns no changes if none happened within the timeout.
+func Wait(ctx context.Context, path, pattern string, interval, timeout time.Duration) ([]Change, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	before, err := snapshot(path, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, nil
+		case <-ticker.C:
+		}
+
+		after, err := snapshot(path, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if changes := diff(before, after); len(changes) > 0 {
+			return changes, nil
+		}
+	}
+}
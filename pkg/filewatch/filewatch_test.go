@@ -0,0 +1,47 @@
+package filewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWait(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0600))
+
+	// Nothing changes before the timeout
+	changes, err := Wait(context.Background(), dir, "", 10*time.Millisecond, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("ignored"), 0600)
+		time.Sleep(50 * time.Millisecond)
+		_ = os.MkdirAll(filepath.Join(dir, "out"), 0700)
+		_ = os.WriteFile(filepath.Join(dir, "out", "report.pdf"), []byte("report"), 0600)
+	}()
+
+	changes, err = Wait(context.Background(), dir, "*.pdf", 10*time.Millisecond, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Path: filepath.Join(dir, "out", "report.pdf"), Op: "created"}}, changes)
+
+	// A file that does not exist yet is created
+	file := filepath.Join(dir, "done")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.WriteFile(file, nil, 0600)
+	}()
+	changes, err = Wait(context.Background(), file, "", 10*time.Millisecond, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Path: file, Op: "created"}}, changes)
+
+	_, err = Wait(context.Background(), dir, "[", 10*time.Millisecond, time.Second)
+	assert.ErrorContains(t, err, "invalid pattern")
+}
@@ -0,0 +1,141 @@
+// Package fsscope restricts the paths that the file tools, like sys.read and sys.write, operate on.
+package fsscope
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+)
+
+var (
+	tempDirLock sync.Mutex
+	tempDir     string
+)
+
+// TempDir returns the directory that the file tools create their files in when they are not given a location, like
+// sys.download. It is private to the user and created the first time it is used. Every scope allows it, so the files
+// that the tools return can be read, listed and removed with the others.
+func TempDir() (string, error) {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+	if tempDir == "" {
+		dir, err := os.MkdirTemp("", "gptscript-files")
+		if err != nil {
+			return "", err
+		}
+		if tempDir, err = resolve(dir); err != nil {
+			return "", err
+		}
+		// The files are returned to the tools, the directory is used until the program exits
+		cache.Use(tempDir)
+	}
+	return tempDir, nil
+}
+
+func createdTempDir() string {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+	return tempDir
+}
+
+// Scope is the directories, and the files, that file tools may operate on, and everything in them.
+type Scope struct {
+	roots []string
+}
+
+// New returns the scope of the roots. Relative roots are in the working directory, and roots starting with ~/ are in
+// the home directory.
+func New(roots ...string) (Scope, error) {
+	var s Scope
+	for _, root := range roots {
+		path, err := resolve(root)
+		if err != nil {
+			return Scope{}, err
+		}
+		s.roots = append(s.roots, path)
+	}
+	return s, nil
+}
+
+// Allows returns whether the path is in one of the roots of the scope, or in TempDir. Symlinks are resolved, so links
+// in the roots to paths outside them are not allowed.
+func (s Scope) Allows(path string) bool {
+	resolved, err := resolve(path)
+	if err != nil {
+		return false
+	}
+	roots := s.roots
+	if dir := createdTempDir(); dir != "" {
+		roots = append(roots[:len(roots):len(roots)], dir)
+	}
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the absolute path with the symlinks of the part of it that exists resolved.
+func resolve(path string) (string, error) {
+	if home, err := os.UserHomeDir(); err == nil && (path == "~" || strings.HasPrefix(path, "~/")) {
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, rest...)...), nil
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// ErrOutside is returned for the paths that are not in the scope.
+type ErrOutside struct {
+	Path  string
+	Roots []string
+}
+
+func (e *ErrOutside) Error() string {
+	return fmt.Sprintf("%s is outside of the paths the tool can use, which are %s", e.Path, strings.Join(e.Roots, ", "))
+}
+
+type contextKey struct{}
+
+// WithContext returns a context that restricts the file tools to the scope.
+func WithContext(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// Check returns an error if the scope of the context does not allow the path. Contexts without a scope allow all
+// paths.
+func Check(ctx context.Context, path string) error {
+	s, ok := ctx.Value(contextKey{}).(Scope)
+	if !ok || s.Allows(path) {
+		return nil
+	}
+	return &ErrOutside{
+		Path:  path,
+		Roots: s.roots,
+	}
+}
@@ -0,0 +1,47 @@
+package fsscope
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllows(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+	s, err := New(dir)
+	require.NoError(t, err)
+
+	for _, path := range []string{dir, filepath.Join(dir, "data"), filepath.Join(dir, "data", "new", "file.txt"), filepath.Join(dir, "..."+"x")} {
+		assert.True(t, s.Allows(path), path)
+	}
+	for _, path := range []string{outside, filepath.Join(dir, ".."), filepath.Join(dir, "data", "..", ".."), filepath.Join(dir, "link", "file.txt"), dir + "-other"} {
+		assert.False(t, s.Allows(path), path)
+	}
+
+	s, err = New(filepath.Join(dir, "data"), outside)
+	require.NoError(t, err)
+	assert.True(t, s.Allows(filepath.Join(outside, "file.txt")))
+	assert.True(t, s.Allows(filepath.Join(dir, "link", "file.txt")))
+	assert.False(t, s.Allows(filepath.Join(dir, "file.txt")))
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, Check(context.Background(), "/etc/hosts"))
+
+	s, err := New(dir)
+	require.NoError(t, err)
+	ctx := WithContext(context.Background(), s)
+
+	assert.NoError(t, Check(ctx, filepath.Join(dir, "file.txt")))
+	var outside *ErrOutside
+	assert.ErrorAs(t, Check(ctx, "/etc/hosts"), &outside)
+}
@@ -0,0 +1,125 @@
+// Package gc removes the parts of the cache that are old or over its maximum size, and the temporary files that
+// runs leave behind when they are killed.
+package gc
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+)
+
+// DefaultInterval is how often the background GC of the server runs.
+const DefaultInterval = time.Hour
+
+type Options struct {
+	cache.PruneOptions
+	// CacheDir is the cache to collect
+	CacheDir string
+	// TempDir is the directory of the temporary files of runs, os.TempDir() if not set
+	TempDir string
+}
+
+// Entries returns the entries of the cache in cacheDir, the entries of the repos directory first, and the
+// temporary files of runs in tempDir.
+func Entries(cacheDir, tempDir string) ([]cache.Entry, error) {
+	entries, err := cache.Entries(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	repoEntries, err := repos.Entries(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := tempEntries(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(repoEntries, entries...), temp...), nil
+}
+
+// tempEntries returns the temporary files and directories of runs, which all start with the program name, like the
+// scripts of commands and the builds of runtimes. Only the entries of the current user are returned, and the entries
+// that can not be read are skipped, the temporary directory is shared with other users and programs.
+func tempEntries(dir string) (result []cache.Entry, _ error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), version.ProgramName) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil || !owned(info) {
+			continue
+		}
+		entry, err := cache.NewEntry("temp", filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Debugf("Skipping the temporary file %s: %v", entry.Path, err)
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// Run removes the entries selected by the options, and returns the entries that it removed and all the entries
+// there were.
+func Run(ctx context.Context, opts Options) (removed, all []cache.Entry, _ error) {
+	all, err := Entries(opts.CacheDir, opts.TempDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removed, err = cache.Prune(all, opts.PruneOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !opts.DryRun && len(removed) > 0 {
+		if err := repos.Cleanup(ctx, opts.CacheDir); err != nil {
+			return nil, nil, err
+		}
+	}
+	return removed, all, nil
+}
+
+// Background runs the GC every interval until the context is done, starting now. Failures are logged, and retried
+// the next time.
+func Background(ctx context.Context, interval time.Duration, opts Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, _, err := Run(ctx, opts)
+		if err != nil {
+			log.Errorf("failed to collect the cache %s: %v", opts.CacheDir, err)
+		} else if len(removed) > 0 {
+			var size int64
+			for _, entry := range removed {
+				size += entry.Size
+			}
+			log.Infof("Removed %d entries of %d bytes from the cache", len(removed), size)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
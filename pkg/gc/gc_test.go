@@ -0,0 +1,88 @@
+package gc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	cacheDir, tempDir := t.TempDir(), t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	for _, file := range []string{
+		filepath.Join(cacheDir, "old-response"),
+		filepath.Join(cacheDir, "new-response"),
+		filepath.Join(tempDir, "gptscript12345"),
+		filepath.Join(tempDir, "gptscript-verify-1", "tool"),
+		filepath.Join(tempDir, "other-program"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte("data"), 0644))
+	}
+	for _, path := range []string{
+		filepath.Join(cacheDir, "old-response"),
+		filepath.Join(tempDir, "gptscript12345"),
+		filepath.Join(tempDir, "other-program"),
+	} {
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+
+	opts := Options{
+		PruneOptions: cache.PruneOptions{OlderThan: 24 * time.Hour, DryRun: true},
+		CacheDir:     cacheDir,
+		TempDir:      tempDir,
+	}
+
+	removed, all, err := Run(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	var paths []string
+	for _, entry := range removed {
+		paths = append(paths, entry.Path)
+	}
+	assert.ElementsMatch(t, []string{filepath.Join(cacheDir, "old-response"), filepath.Join(tempDir, "gptscript12345")}, paths)
+	assert.FileExists(t, filepath.Join(tempDir, "gptscript12345"))
+
+	opts.DryRun = false
+	_, _, err = Run(context.Background(), opts)
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(tempDir, "gptscript12345"))
+	assert.NoFileExists(t, filepath.Join(cacheDir, "old-response"))
+	assert.FileExists(t, filepath.Join(cacheDir, "new-response"))
+	assert.FileExists(t, filepath.Join(tempDir, "other-program"))
+}
+
+func TestRunSkipsInUse(t *testing.T) {
+	tempDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+
+	build := filepath.Join(tempDir, "gptscript-rust-build1")
+	require.NoError(t, os.MkdirAll(build, 0755))
+	require.NoError(t, os.Chtimes(build, old, old))
+
+	// A run that takes longer than the age is still using its directory
+	release := cache.Use(build)
+	opts := Options{
+		PruneOptions: cache.PruneOptions{OlderThan: 24 * time.Hour},
+		CacheDir:     t.TempDir(),
+		TempDir:      tempDir,
+	}
+	removed, _, err := Run(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.DirExists(t, build)
+
+	release()
+	removed, _, err = Run(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.NoDirExists(t, build)
+}
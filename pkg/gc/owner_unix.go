@@ -0,0 +1,14 @@
+//go:build !windows
+
+package gc
+
+import (
+	"os"
+	"syscall"
+)
+
+// owned returns whether the file belongs to the current user.
+func owned(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
@@ -0,0 +1,10 @@
+package gc
+
+import (
+	"os"
+)
+
+// owned returns whether the file belongs to the current user, the temporary directory is per user on Windows.
+func owned(os.FileInfo) bool {
+	return true
+}
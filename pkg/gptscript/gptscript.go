@@ -2,23 +2,32 @@ package gptscript
 
 import (
 	"context"
+	"net/http"
 	"os"
 
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/identity"
 	"github.com/gptscript-ai/gptscript/pkg/llm"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/local"
 	"github.com/gptscript-ai/gptscript/pkg/monitor"
 	"github.com/gptscript-ai/gptscript/pkg/openai"
 	"github.com/gptscript-ai/gptscript/pkg/remote"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/trace"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
 type GPTScript struct {
-	Registry *llm.Registry
-	Runner   *runner.Runner
+	Registry       *llm.Registry
+	Runner         *runner.Runner
+	monitorFactory runner.MonitorFactory
+	localClient    *local.Client
+	transport      *http.Transport
 }
 
 type Options struct {
@@ -26,8 +35,11 @@ type Options struct {
 	OpenAI            openai.Options
 	Monitor           monitor.Options
 	Runner            runner.Options
+	TLS               tlsconfig.Options
 	CredentialContext string   `usage:"Context name in which to store credentials" default:"default"`
 	Quiet             *bool    `usage:"No output logging (set --quiet=false to force on even when there is no TTY)" short:"q"`
+	ModelTemplates    string   `usage:"A YAML file of templates that render the requests to families of models, like models fine-tuned with their own tool call format"`
+	LocalModels       string   `usage:"A YAML file of models served by local inference servers, like llama.cpp or vLLM, that are started when the models are used"`
 	Env               []string `usage:"-"`
 }
 
@@ -48,16 +60,53 @@ func complete(opts *Options) (result *Options) {
 func New(opts *Options) (*GPTScript, error) {
 	opts = complete(opts)
 
+	if err := trace.Init(); err != nil {
+		return nil, err
+	}
+
 	registry := llm.NewRegistry()
+	if opts.ModelTemplates != "" {
+		if err := registry.LoadTemplates(opts.ModelTemplates); err != nil {
+			return nil, err
+		}
+	}
+
+	// The outbound connections of this instance are configured with the transport, instead of the default transport
+	// that the rest of the process uses
+	transport, err := opts.TLS.Transport()
+	if err != nil {
+		return nil, err
+	}
 
-	cacheClient, err := cache.New(opts.Cache)
+	cacheClient, err := cache.New(opts.Cache, cache.Options{Transport: transport})
 	if err != nil {
 		return nil, err
 	}
 
+	var workloadIdentity *identity.Source
+	if opts.OpenAI.WorkloadIdentity != "" {
+		workloadIdentity, err = identity.New(opts.OpenAI.WorkloadIdentity, transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var localClient *local.Client
+	if opts.LocalModels != "" {
+		localClient, err = local.Load(opts.LocalModels, cacheClient)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.AddClient(localClient); err != nil {
+			return nil, err
+		}
+	}
+
 	oAIClient, err := openai.NewClient(append([]openai.Options{opts.OpenAI}, openai.Options{
-		Cache:   cacheClient,
-		SetSeed: true,
+		Cache:     cacheClient,
+		SetSeed:   true,
+		Identity:  workloadIdentity,
+		Transport: transport,
 	})...)
 	if err != nil {
 		return nil, err
@@ -77,20 +126,29 @@ func New(opts *Options) (*GPTScript, error) {
 		opts.Runner.RuntimeManager = runtimes.Default(cacheClient.CacheDir())
 	}
 
-	runner, err := runner.New(registry, opts.CredentialContext, opts.Runner)
+	runner, err := runner.New(registry, opts.CredentialContext, opts.Runner, runner.Options{
+		Transport: transport,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	remoteClient := remote.New(runner, opts.Env, cacheClient)
+	remoteClient := remote.New(runner, opts.Env, cacheClient, workloadIdentity, transport, openai.RequestLimit{
+		MaxSize:   opts.OpenAI.MaxRequestSize,
+		MaxTokens: opts.OpenAI.MaxRequestTokens,
+		Pruning:   opts.OpenAI.RequestPruning,
+	})
 
 	if err := registry.AddClient(remoteClient); err != nil {
 		return nil, err
 	}
 
 	return &GPTScript{
-		Registry: registry,
-		Runner:   runner,
+		Registry:       registry,
+		Runner:         runner,
+		monitorFactory: opts.Runner.MonitorFactory,
+		localClient:    localClient,
+		transport:      transport,
 	}, nil
 }
 
@@ -102,8 +160,28 @@ func (g *GPTScript) Run(ctx context.Context, prg types.Program, envs []string, i
 	return g.Runner.Run(ctx, prg, envs, input)
 }
 
+func (g *GPTScript) Resume(ctx context.Context, prg types.Program, envs []string, state *runner.State) (string, error) {
+	return g.Runner.Resume(ctx, prg, envs, state)
+}
+
+// Transport returns the transport that the outbound connections are configured with, which the programs that are
+// run are loaded with too.
+func (g *GPTScript) Transport() *http.Transport {
+	return g.transport
+}
+
+// LoaderMonitor returns the configured monitor if it can report the progress of loading a program
+func (g *GPTScript) LoaderMonitor() loader.ProgressMonitor {
+	m, _ := g.monitorFactory.(loader.ProgressMonitor)
+	return m
+}
+
 func (g *GPTScript) Close() {
 	g.Runner.Close()
+	if g.localClient != nil {
+		g.localClient.Close()
+	}
+	trace.Flush()
 }
 
 func (g *GPTScript) GetModel() engine.Model {
@@ -0,0 +1,409 @@
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	url2 "net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Azure = "azure"
+	GCP   = "gcp"
+	AWS   = "aws"
+
+	// TokenEnv is the environment variable that passes the bearer token of the workload to provider tools
+	TokenEnv = "GPTSCRIPT_WORKLOAD_IDENTITY_TOKEN"
+
+	defaultAzureAuthorityHost = "https://login.microsoftonline.com/"
+	defaultAzureScope         = "https://cognitiveservices.azure.com/.default"
+	gcpScope                  = "https://www.googleapis.com/auth/cloud-platform"
+
+	// refreshBefore is how long before they expire that credentials are exchanged again
+	refreshBefore = 5 * time.Minute
+)
+
+// Credentials are the short-lived provider credentials that the OIDC token of the workload was exchanged for.
+type Credentials struct {
+	// Token is a bearer token for the provider API. It is not set for AWS, which signs requests with the Env instead.
+	Token   string
+	Env     []string
+	Expires time.Time
+}
+
+// Source exchanges the OIDC token that CI and cloud runners provide to the workload for provider credentials, so no
+// long-lived API keys have to be stored. The token and the identity to exchange it for are configured with the
+// environment variables that Azure workload identity, GCP workload identity federation, and AWS IRSA already set.
+type Source struct {
+	provider string
+	client   *http.Client
+
+	lock  sync.Mutex
+	creds *Credentials
+}
+
+// New returns the source of the credentials of the provider, which exchanges the token with the transport, or
+// http.DefaultTransport if it is nil.
+func New(provider string, transport http.RoundTripper) (*Source, error) {
+	switch provider {
+	case Azure, GCP, AWS:
+	default:
+		return nil, fmt.Errorf("invalid workload identity %q, must be %s, %s, or %s", provider, Azure, GCP, AWS)
+	}
+	return &Source{
+		provider: provider,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
+
+// Bearer returns true if the credentials include a bearer token for the provider API
+func (s *Source) Bearer() bool {
+	return s.provider != AWS
+}
+
+// Get returns the credentials of the workload, exchanging its OIDC token only if the last credentials expire soon.
+func (s *Source) Get(ctx context.Context) (*Credentials, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.creds != nil && time.Until(s.creds.Expires) > refreshBefore {
+		return s.creds, nil
+	}
+
+	var (
+		creds *Credentials
+		err   error
+	)
+	switch s.provider {
+	case Azure:
+		creds, err = s.azure(ctx)
+	case GCP:
+		creds, err = s.gcp(ctx)
+	case AWS:
+		creds, err = s.aws(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange %s workload identity token: %w", s.provider, err)
+	}
+
+	s.creds = creds
+	return creds, nil
+}
+
+// Transport returns a round tripper that authorizes every request with the bearer token of the workload
+func (s *Source) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{source: s, base: base}
+}
+
+type transport struct {
+	source *Source
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds, err := t.source.Get(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+creds.Token)
+	return t.base.RoundTrip(req)
+}
+
+func bearer(token string, expires time.Time) (*Credentials, error) {
+	if token == "" {
+		return nil, fmt.Errorf("no access token in response")
+	}
+	return &Credentials{
+		Token:   token,
+		Env:     []string{TokenEnv + "=" + token},
+		Expires: expires,
+	}, nil
+}
+
+func readToken(env string) (string, error) {
+	file := os.Getenv(env)
+	if file == "" {
+		return "", fmt.Errorf("%s is not set", env)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// azure exchanges the token for a Microsoft Entra access token, as configured by Azure workload identity
+func (s *Source) azure(ctx context.Context) (*Credentials, error) {
+	clientID, tenantID := os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_TENANT_ID")
+	if clientID == "" || tenantID == "" {
+		return nil, fmt.Errorf("AZURE_CLIENT_ID and AZURE_TENANT_ID must be set")
+	}
+
+	assertion, err := readToken("AZURE_FEDERATED_TOKEN_FILE")
+	if err != nil {
+		return nil, err
+	}
+
+	authorityHost := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = defaultAzureAuthorityHost
+	}
+	scope := os.Getenv("GPTSCRIPT_WORKLOAD_IDENTITY_SCOPE")
+	if scope == "" {
+		scope = defaultAzureScope
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := s.post(ctx, strings.TrimSuffix(authorityHost, "/")+"/"+tenantID+"/oauth2/v2.0/token", url2.Values{
+		"client_id":             {clientID},
+		"scope":                 {scope},
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {assertion},
+	}, &resp); err != nil {
+		return nil, err
+	}
+
+	return bearer(resp.AccessToken, time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second))
+}
+
+// externalAccount is the credential configuration file of GCP workload identity federation
+type externalAccount struct {
+	Type                           string `json:"type"`
+	Audience                       string `json:"audience"`
+	SubjectTokenType               string `json:"subject_token_type"`
+	TokenURL                       string `json:"token_url"`
+	ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	CredentialSource               struct {
+		File    string            `json:"file"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+		Format  struct {
+			Type                  string `json:"type"`
+			SubjectTokenFieldName string `json:"subject_token_field_name"`
+		} `json:"format"`
+	} `json:"credential_source"`
+}
+
+func (s *Source) subjectToken(ctx context.Context, account externalAccount) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	source := account.CredentialSource
+	if source.File != "" {
+		data, err = os.ReadFile(source.File)
+	} else if source.URL != "" {
+		data, err = s.get(ctx, source.URL, source.Headers)
+	} else {
+		return "", fmt.Errorf("only file and url credential sources are supported")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if source.Format.Type != "json" {
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	token, _ := fields[source.Format.SubjectTokenFieldName].(string)
+	if token == "" {
+		return "", fmt.Errorf("no %s field in subject token", source.Format.SubjectTokenFieldName)
+	}
+	return token, nil
+}
+
+// gcp exchanges the token with the Security Token Service for an access token, and then for an access token of the
+// impersonated service account if the credential configuration has one.
+func (s *Source) gcp(ctx context.Context) (*Credentials, error) {
+	file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if file == "" {
+		return nil, fmt.Errorf("GOOGLE_APPLICATION_CREDENTIALS is not set")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var account externalAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid credential configuration %s: %w", file, err)
+	}
+	if account.Type != "external_account" {
+		return nil, fmt.Errorf("credential configuration %s is of type %q, not external_account", file, account.Type)
+	}
+
+	subjectToken, err := s.subjectToken(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := s.post(ctx, account.TokenURL, url2.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"audience":             {account.Audience},
+		"scope":                {gcpScope},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+		"subject_token_type":   {account.SubjectTokenType},
+		"subject_token":        {subjectToken},
+	}, &resp); err != nil {
+		return nil, err
+	}
+
+	if account.ServiceAccountImpersonationURL == "" {
+		return bearer(resp.AccessToken, time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second))
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"scope": []string{gcpScope},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var impersonated struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := s.do(ctx, http.MethodPost, account.ServiceAccountImpersonationURL, bytes.NewReader(body), map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": "Bearer " + resp.AccessToken,
+	}, &impersonated); err != nil {
+		return nil, err
+	}
+
+	return bearer(impersonated.AccessToken, impersonated.ExpireTime)
+}
+
+// aws exchanges the token for temporary credentials of the role configured by IRSA. They are passed to provider tools
+// in the environment variables that the AWS SDKs read.
+func (s *Source) aws(ctx context.Context) (*Credentials, error) {
+	roleARN := os.Getenv("AWS_ROLE_ARN")
+	if roleARN == "" {
+		return nil, fmt.Errorf("AWS_ROLE_ARN is not set")
+	}
+
+	token, err := readToken("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if err != nil {
+		return nil, err
+	}
+
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("gptscript-%d", time.Now().Unix())
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_STS")
+	if endpoint == "" {
+		endpoint = "https://sts.amazonaws.com"
+		if region := os.Getenv("AWS_REGION"); region != "" {
+			endpoint = "https://sts." + region + ".amazonaws.com"
+		}
+	}
+
+	data, err := s.send(ctx, http.MethodPost, endpoint, strings.NewReader(url2.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {token},
+	}.Encode()), map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response of %s: %w", endpoint, err)
+	}
+	if resp.Credentials.AccessKeyID == "" {
+		return nil, fmt.Errorf("no credentials in response of %s", endpoint)
+	}
+
+	return &Credentials{
+		Env: []string{
+			"AWS_ACCESS_KEY_ID=" + resp.Credentials.AccessKeyID,
+			"AWS_SECRET_ACCESS_KEY=" + resp.Credentials.SecretAccessKey,
+			"AWS_SESSION_TOKEN=" + resp.Credentials.SessionToken,
+		},
+		Expires: resp.Credentials.Expiration,
+	}, nil
+}
+
+func (s *Source) get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return s.send(ctx, http.MethodGet, url, nil, headers)
+}
+
+func (s *Source) post(ctx context.Context, url string, form url2.Values, out any) error {
+	return s.do(ctx, http.MethodPost, url, strings.NewReader(form.Encode()), map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+	}, out)
+}
+
+func (s *Source) do(ctx context.Context, method, url string, body io.Reader, headers map[string]string, out any) error {
+	data, err := s.send(ctx, method, url, body, headers)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", url, err)
+	}
+	return nil
+}
+
+func (s *Source) send(ctx context.Context, method, url string, body io.Reader, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request of %s: %w", url, err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to exchange token at %s: %s %s", url, resp.Status, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,159 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	return file
+}
+
+func TestAzure(t *testing.T) {
+	var exchanges int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		require.Equal(t, "/tenant/oauth2/v2.0/token", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client", r.PostForm.Get("client_id"))
+		require.Equal(t, "oidc-token", r.PostForm.Get("client_assertion"))
+		require.Equal(t, defaultAzureScope, r.PostForm.Get("scope"))
+		_, _ = fmt.Fprint(w, `{"access_token": "azure-token", "expires_in": 3600}`)
+	}))
+	defer s.Close()
+
+	t.Setenv("AZURE_AUTHORITY_HOST", s.URL+"/")
+	t.Setenv("AZURE_CLIENT_ID", "client")
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", writeFile(t, "token", "oidc-token\n"))
+	t.Setenv("GPTSCRIPT_WORKLOAD_IDENTITY_SCOPE", "")
+
+	source, err := New(Azure, nil)
+	require.NoError(t, err)
+	require.True(t, source.Bearer())
+
+	creds, err := source.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "azure-token", creds.Token)
+	require.Equal(t, []string{TokenEnv + "=azure-token"}, creds.Env)
+
+	// The token is reused until it expires soon
+	_, err = source.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, exchanges)
+
+	source.creds.Expires = time.Now().Add(time.Minute)
+	_, err = source.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, exchanges)
+
+	// The transport authorizes requests with the token
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	resp, err := (&http.Client{Transport: source.Transport(nil)}).Get(api.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	auth, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer azure-token", string(auth))
+}
+
+func TestGCP(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oidc":
+			require.Equal(t, "bearer runner", r.Header.Get("Authorization"))
+			_, _ = fmt.Fprint(w, `{"value": "oidc-token"}`)
+		case "/token":
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "//iam.googleapis.com/projects/1/providers/p", r.PostForm.Get("audience"))
+			require.Equal(t, "oidc-token", r.PostForm.Get("subject_token"))
+			_, _ = fmt.Fprint(w, `{"access_token": "federated-token", "expires_in": 3600}`)
+		case "/impersonate":
+			require.Equal(t, "Bearer federated-token", r.Header.Get("Authorization"))
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"accessToken": "gcp-token",
+				"expireTime":  time.Now().Add(time.Hour),
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeFile(t, "credentials.json", fmt.Sprintf(`{
+  "type": "external_account",
+  "audience": "//iam.googleapis.com/projects/1/providers/p",
+  "subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+  "token_url": "%[1]s/token",
+  "service_account_impersonation_url": "%[1]s/impersonate",
+  "credential_source": {
+    "url": "%[1]s/oidc",
+    "headers": {"Authorization": "bearer runner"},
+    "format": {"type": "json", "subject_token_field_name": "value"}
+  }
+}`, s.URL)))
+
+	source, err := New(GCP, nil)
+	require.NoError(t, err)
+
+	creds, err := source.Get(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "gcp-token", creds.Token)
+}
+
+func TestAWS(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "AssumeRoleWithWebIdentity", r.PostForm.Get("Action"))
+		require.Equal(t, "arn:aws:iam::1:role/r", r.PostForm.Get("RoleArn"))
+		require.Equal(t, "oidc-token", r.PostForm.Get("WebIdentityToken"))
+		_, _ = fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>key</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer s.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL_STS", s.URL)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::1:role/r")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", writeFile(t, "token", "oidc-token"))
+
+	source, err := New(AWS, nil)
+	require.NoError(t, err)
+	require.False(t, source.Bearer())
+
+	creds, err := source.Get(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, creds.Token)
+	require.Equal(t, []string{
+		"AWS_ACCESS_KEY_ID=key",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"AWS_SESSION_TOKEN=session",
+	}, creds.Env)
+
+	_, err = New("vault", nil)
+	require.Error(t, err)
+}
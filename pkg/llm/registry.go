@@ -16,7 +16,8 @@ type Client interface {
 }
 
 type Registry struct {
-	clients []Client
+	clients   []Client
+	templates []registeredTemplate
 }
 
 func NewRegistry() *Registry {
@@ -50,7 +51,7 @@ func (r *Registry) Call(ctx context.Context, messageRequest types.CompletionRequ
 		if err != nil {
 			errs = append(errs, err)
 		} else if ok {
-			return client.Call(ctx, messageRequest, status)
+			return r.call(ctx, client, messageRequest, status)
 		}
 	}
 	if len(errs) == 0 {
@@ -58,3 +59,50 @@ func (r *Registry) Call(ctx context.Context, messageRequest types.CompletionRequ
 	}
 	return nil, errors.Join(errs...)
 }
+
+func (r *Registry) call(ctx context.Context, client Client, messageRequest types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	t := r.templateFor(messageRequest.Model)
+	if t == nil {
+		return client.Call(ctx, messageRequest, status)
+	}
+
+	rendered, err := t.Render(messageRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the request to model %s: %w", messageRequest.Model, err)
+	}
+
+	resp, err := client.Call(ctx, rendered, status)
+	if err != nil {
+		return nil, err
+	}
+	return t.Parse(messageRequest, resp)
+}
+
+// EmbeddingClient is implemented by the clients of model providers with an embeddings API.
+type EmbeddingClient interface {
+	Embed(ctx context.Context, model string, input []string) ([][]float32, error)
+}
+
+// Embed returns the embeddings of the input texts from the provider of the model.
+func (r *Registry) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	if model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	var errs []error
+	for _, client := range r.clients {
+		embedder, ok := client.(EmbeddingClient)
+		if !ok {
+			continue
+		}
+		ok, err := client.Supports(ctx, model)
+		if err != nil {
+			errs = append(errs, err)
+		} else if ok {
+			return embedder.Embed(ctx, model, input)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("failed to find a model provider with embeddings for model [%s]", model)
+	}
+	return nil, errors.Join(errs...)
+}
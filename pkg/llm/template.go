@@ -0,0 +1,256 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Template changes how completion requests are rendered into messages for a family of models, like models
+// fine-tuned with their own format for tool calls that is not supported by their provider's API.
+type Template interface {
+	// Render returns the request to send to the model instead of the request of the engine
+	Render(request types.CompletionRequest) (types.CompletionRequest, error)
+	// Parse returns the response of the model in the form the engine expects, like with tool calls found in its text
+	Parse(request types.CompletionRequest, response *types.CompletionMessage) (*types.CompletionMessage, error)
+}
+
+type registeredTemplate struct {
+	pattern  string
+	template Template
+}
+
+// AddTemplate renders the requests to the models whose name matches the pattern with the template. Patterns are
+// matched like file names against the model name without its provider, so "hermes-*" matches
+// "hermes-2-pro from github.com/example/provider". The template of the first pattern that matches is used.
+func (r *Registry) AddTemplate(pattern string, t Template) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid model pattern %q: %w", pattern, err)
+	}
+	r.templates = append(r.templates, registeredTemplate{
+		pattern:  pattern,
+		template: t,
+	})
+	return nil
+}
+
+func (r *Registry) templateFor(model string) Template {
+	name, _, _ := strings.Cut(model, " from ")
+	for _, t := range r.templates {
+		if ok, _ := path.Match(t.pattern, strings.TrimSpace(name)); ok {
+			return t.template
+		}
+	}
+	return nil
+}
+
+// TemplateFile is a file of templates, written in YAML, for the families of models they apply to.
+type TemplateFile struct {
+	Templates []TextTemplate `yaml:"templates"`
+}
+
+// TextTemplate renders tools, tool calls and tool results into the text of messages with Go templates, and finds
+// the tool calls in the text of responses with a regular expression, for models that only support text messages.
+type TextTemplate struct {
+	// Models is the pattern of the names of the models the template applies to
+	Models string `yaml:"models"`
+	// Tools renders the tools available to the model, .Tools, into text that is added to the system message
+	Tools string `yaml:"tools,omitempty"`
+	// ToolCall renders a tool call of the model, with .ID, .Name and .Arguments, into the text of the message
+	ToolCall string `yaml:"toolCall,omitempty"`
+	// ToolResult renders the result of a tool call, with .ID, .Name and .Content, into the text of a user message
+	ToolResult string `yaml:"toolResult,omitempty"`
+	// ToolCallPattern finds the tool calls in the text of a response. The first group of each match is a JSON
+	// object with the name of the tool and its arguments, like {"name": "...", "arguments": {...}}.
+	ToolCallPattern string `yaml:"toolCallPattern,omitempty"`
+
+	tools, toolCall, toolResult *template.Template
+	toolCallPattern             *regexp.Regexp
+}
+
+// LoadTemplates adds the templates in the file to the registry.
+func (r *Registry) LoadTemplates(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var templates TemplateFile
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("invalid model templates %s: %w", file, err)
+	}
+
+	for _, t := range templates.Templates {
+		if err := t.compile(); err != nil {
+			return fmt.Errorf("invalid model template for %s in %s: %w", t.Models, file, err)
+		}
+		if err := r.AddTemplate(t.Models, &t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TextTemplate) compile() (err error) {
+	funcs := template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}
+	for _, tmpl := range []struct {
+		text   string
+		target **template.Template
+	}{
+		{t.Tools, &t.tools},
+		{t.ToolCall, &t.toolCall},
+		{t.ToolResult, &t.toolResult},
+	} {
+		if tmpl.text == "" {
+			continue
+		}
+		if *tmpl.target, err = template.New("").Funcs(funcs).Parse(tmpl.text); err != nil {
+			return err
+		}
+	}
+
+	if t.ToolCallPattern != "" {
+		t.toolCallPattern, err = regexp.Compile(t.ToolCallPattern)
+		if err != nil {
+			return err
+		}
+		if t.toolCallPattern.NumSubexp() < 1 {
+			return fmt.Errorf("tool call pattern %q has no group", t.ToolCallPattern)
+		}
+	}
+	return nil
+}
+
+func execute(t *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (t *TextTemplate) Render(request types.CompletionRequest) (types.CompletionRequest, error) {
+	var messages []types.CompletionMessage
+
+	for _, msg := range request.Messages {
+		switch {
+		case msg.Role == types.CompletionMessageRoleTypeTool && t.toolResult != nil && msg.ToolCall != nil:
+			text, err := execute(t.toolResult, map[string]string{
+				"ID":      msg.ToolCall.ID,
+				"Name":    msg.ToolCall.Function.Name,
+				"Content": msg.String(),
+			})
+			if err != nil {
+				return request, err
+			}
+			msg = types.CompletionMessage{
+				Role:    types.CompletionMessageRoleTypeUser,
+				Content: types.Text(text),
+			}
+		case msg.Role == types.CompletionMessageRoleTypeAssistant && t.toolCall != nil:
+			content := make([]types.ContentPart, 0, len(msg.Content))
+			for _, part := range msg.Content {
+				if part.ToolCall != nil {
+					text, err := execute(t.toolCall, map[string]string{
+						"ID":        part.ToolCall.ID,
+						"Name":      part.ToolCall.Function.Name,
+						"Arguments": part.ToolCall.Function.Arguments,
+					})
+					if err != nil {
+						return request, err
+					}
+					part = types.ContentPart{Text: text}
+				}
+				content = append(content, part)
+			}
+			msg.Content = content
+		}
+		messages = append(messages, msg)
+	}
+
+	if t.tools != nil && len(request.Tools) > 0 {
+		text, err := execute(t.tools, map[string]any{
+			"Tools": request.Tools,
+		})
+		if err != nil {
+			return request, err
+		}
+		if len(messages) > 0 && messages[0].Role == types.CompletionMessageRoleTypeSystem {
+			system := messages[0]
+			system.Content = append(append([]types.ContentPart{}, system.Content...), types.ContentPart{Text: "\n" + text})
+			messages[0] = system
+		} else {
+			messages = append([]types.CompletionMessage{{
+				Role:    types.CompletionMessageRoleTypeSystem,
+				Content: types.Text(text),
+			}}, messages...)
+		}
+		request.Tools = nil
+	}
+
+	request.Messages = messages
+	return request, nil
+}
+
+func (t *TextTemplate) Parse(request types.CompletionRequest, response *types.CompletionMessage) (*types.CompletionMessage, error) {
+	if t.toolCallPattern == nil || len(request.Tools) == 0 {
+		return response, nil
+	}
+
+	var (
+		result = &types.CompletionMessage{Role: response.Role}
+		text   = response.String()
+		index  int
+	)
+
+	for _, match := range t.toolCallPattern.FindAllStringSubmatch(text, -1) {
+		var call struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &call); err != nil {
+			return nil, fmt.Errorf("invalid tool call %q in response: %w", match[1], err)
+		}
+
+		arguments := string(call.Arguments)
+		// Arguments are passed to tools as a string of JSON, some models write that string instead of an object
+		var s string
+		if err := json.Unmarshal(call.Arguments, &s); err == nil {
+			arguments = s
+		}
+
+		result.Content = append(result.Content, types.ContentPart{
+			ToolCall: &types.CompletionToolCall{
+				Index: &[]int{index}[0],
+				ID:    "call_" + hash.ID(call.Name, arguments)[:8],
+				Function: types.CompletionFunctionCall{
+					Name:      call.Name,
+					Arguments: arguments,
+				},
+			},
+		})
+		index++
+	}
+
+	if len(result.Content) == 0 {
+		return response, nil
+	}
+	if rest := strings.TrimSpace(t.toolCallPattern.ReplaceAllString(text, "")); rest != "" {
+		result.Content = append([]types.ContentPart{{Text: rest}}, result.Content...)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	requests []types.CompletionRequest
+	response string
+}
+
+func (f *fakeClient) Call(_ context.Context, messageRequest types.CompletionRequest, _ chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	f.requests = append(f.requests, messageRequest)
+	return &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text(f.response),
+	}, nil
+}
+
+func (f *fakeClient) ListModels(context.Context, ...string) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) Supports(context.Context, string) (bool, error) {
+	return true, nil
+}
+
+func TestTemplates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "templates.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`templates:
+- models: hermes-*
+  tools: '<tools>{{ range .Tools }}{{ .Function.Name }} {{ end }}</tools>'
+  toolCall: '<tool_call>{"name": "{{ .Name }}", "arguments": {{ .Arguments }}}</tool_call>'
+  toolResult: '<tool_response>{{ .Content }}</tool_response>'
+  toolCallPattern: '(?s)<tool_call>(.*?)</tool_call>'
+`), 0644))
+
+	var (
+		registry = NewRegistry()
+		client   = &fakeClient{response: `Checking. <tool_call>{"name": "weather", "arguments": {"city": "Paris"}}</tool_call>`}
+		call     = &types.CompletionToolCall{ID: "call_1", Function: types.CompletionFunctionCall{Name: "weather", Arguments: `{"city": "Rome"}`}}
+	)
+	require.NoError(t, registry.AddClient(client))
+	require.NoError(t, registry.LoadTemplates(file))
+
+	request := types.CompletionRequest{
+		Model: "hermes-2-pro from github.com/example/provider",
+		Tools: []types.CompletionTool{{Function: types.CompletionFunctionDefinition{Name: "weather"}}},
+		Messages: []types.CompletionMessage{
+			{Role: types.CompletionMessageRoleTypeSystem, Content: types.Text("Answer.")},
+			{Role: types.CompletionMessageRoleTypeAssistant, Content: []types.ContentPart{{ToolCall: call}}},
+			{Role: types.CompletionMessageRoleTypeTool, Content: types.Text("sunny"), ToolCall: call},
+		},
+	}
+
+	resp, err := registry.Call(context.Background(), request, nil)
+	require.NoError(t, err)
+
+	// The tools, tool calls and tool results are rendered into text
+	sent := client.requests[0]
+	require.Nil(t, sent.Tools)
+	require.Equal(t, "Answer.\n\n<tools>weather </tools>", sent.Messages[0].String())
+	require.Equal(t, `<tool_call>{"name": "weather", "arguments": {"city": "Rome"}}</tool_call>`, sent.Messages[1].String())
+	require.Equal(t, types.CompletionMessageRoleTypeUser, sent.Messages[2].Role)
+	require.Equal(t, "<tool_response>sunny</tool_response>", sent.Messages[2].String())
+
+	// The tool calls in the text of the response are parsed
+	require.Len(t, resp.Content, 2)
+	require.Equal(t, "Checking.", resp.Content[0].Text)
+	require.Equal(t, "weather", resp.Content[1].ToolCall.Function.Name)
+	require.JSONEq(t, `{"city": "Paris"}`, resp.Content[1].ToolCall.Function.Arguments)
+
+	// Other models are not rendered with the template
+	request.Model = "gpt-4o"
+	_, err = registry.Call(context.Background(), request, nil)
+	require.NoError(t, err)
+	require.Equal(t, request.Tools, client.requests[1].Tools)
+}
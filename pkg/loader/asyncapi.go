@@ -0,0 +1,241 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+var nonToolNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+type asyncAPIDocument struct {
+	AsyncAPI string `yaml:"asyncapi"`
+	Info     struct {
+		Title string `yaml:"title"`
+	} `yaml:"info"`
+	Servers    map[string]asyncAPIServer    `yaml:"servers"`
+	Channels   map[string]asyncAPIChannel   `yaml:"channels"`
+	Operations map[string]asyncAPIOperation `yaml:"operations"`
+}
+
+type asyncAPIServer struct {
+	URL      string `yaml:"url"`  // AsyncAPI 2
+	Host     string `yaml:"host"` // AsyncAPI 3
+	Protocol string `yaml:"protocol"`
+}
+
+type asyncAPIChannel struct {
+	Address     string                     `yaml:"address"` // AsyncAPI 3, the channel name is the address in AsyncAPI 2
+	Description string                     `yaml:"description"`
+	Publish     *asyncAPIOperation         `yaml:"publish"`   // AsyncAPI 2
+	Subscribe   *asyncAPIOperation         `yaml:"subscribe"` // AsyncAPI 2
+	Messages    map[string]asyncAPIMessage `yaml:"messages"`  // AsyncAPI 3
+}
+
+type asyncAPIOperation struct {
+	OperationID string          `yaml:"operationId"` // AsyncAPI 2
+	Action      string          `yaml:"action"`      // AsyncAPI 3
+	Summary     string          `yaml:"summary"`
+	Description string          `yaml:"description"`
+	Message     asyncAPIMessage `yaml:"message"` // AsyncAPI 2
+	Channel     struct {
+		Ref string `yaml:"$ref"`
+	} `yaml:"channel"` // AsyncAPI 3
+}
+
+type asyncAPIMessage struct {
+	Payload any `yaml:"payload"`
+}
+
+func isAsyncAPI(data []byte) bool {
+	var fragment struct {
+		AsyncAPI string `json:"asyncapi,omitempty"`
+	}
+
+	if err := json.Unmarshal(data, &fragment); err != nil {
+		if err := yaml.Unmarshal(data, &fragment); err != nil {
+			return false
+		}
+	}
+	return fragment.AsyncAPI != ""
+}
+
+// getAsyncAPITools parses an AsyncAPI definition and generates a tool for each operation that can be performed by a
+// client of the described application. Each tool either publishes a message to a channel or consumes messages from it.
+// The tool's Instructions will be in the format "#!sys.asyncapi '{JSON Instructions}'",
+// where the JSON Instructions are a JSON-serialized engine.AsyncAPIInstructions struct.
+func getAsyncAPITools(data []byte) ([]types.Tool, error) {
+	var doc asyncAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	protocol, server, err := asyncAPIServerAddress(doc.Servers)
+	if err != nil {
+		return nil, err
+	}
+
+	type operation struct {
+		name, action, channel, description string
+		payload                            any
+	}
+
+	var operations []operation
+	if strings.HasPrefix(doc.AsyncAPI, "2.") {
+		// In AsyncAPI 2, publish operations are messages clients publish to the application, and subscribe operations
+		// are messages clients can subscribe to.
+		for _, name := range sortedKeys(doc.Channels) {
+			channel := doc.Channels[name]
+			for _, op := range []struct {
+				action    string
+				operation *asyncAPIOperation
+			}{
+				{action: engine.AsyncAPIActionPublish, operation: channel.Publish},
+				{action: engine.AsyncAPIActionConsume, operation: channel.Subscribe},
+			} {
+				if op.operation == nil {
+					continue
+				}
+				operations = append(operations, operation{
+					name:        types.FirstSet(op.operation.OperationID, op.action+"_"+name),
+					action:      op.action,
+					channel:     name,
+					description: types.FirstSet(op.operation.Summary, op.operation.Description, channel.Description),
+					payload:     op.operation.Message.Payload,
+				})
+			}
+		}
+	} else {
+		// In AsyncAPI 3, the application receives messages clients publish, and sends messages clients consume.
+		for _, name := range sortedKeys(doc.Operations) {
+			op := doc.Operations[name]
+			channelName := strings.TrimPrefix(op.Channel.Ref, "#/channels/")
+			channel, ok := doc.Channels[channelName]
+			if !ok {
+				return nil, fmt.Errorf("channel %s of operation %s not found", op.Channel.Ref, name)
+			}
+
+			var action string
+			switch op.Action {
+			case "receive":
+				action = engine.AsyncAPIActionPublish
+			case "send":
+				action = engine.AsyncAPIActionConsume
+			default:
+				return nil, fmt.Errorf("unsupported action %q for operation %s", op.Action, name)
+			}
+
+			var payload any
+			for _, messageName := range sortedKeys(channel.Messages) {
+				payload = channel.Messages[messageName].Payload
+				break
+			}
+
+			operations = append(operations, operation{
+				name:        name,
+				action:      action,
+				channel:     types.FirstSet(channel.Address, channelName),
+				description: types.FirstSet(op.Summary, op.Description, channel.Description),
+				payload:     payload,
+			})
+		}
+	}
+
+	var (
+		toolNames []string
+		tools     []types.Tool
+		// Each tool gets an operation number, beginning with 1
+		operationNum = 1
+	)
+
+	for _, op := range operations {
+		name := nonToolNameRegexp.ReplaceAllString(op.name, "_")
+		if slices.Contains(toolNames, name) {
+			return nil, fmt.Errorf("duplicate operation name %s", name)
+		}
+
+		tool := types.Tool{
+			Parameters: types.Parameters{
+				Name: name,
+			},
+			Source: types.ToolSource{
+				LineNo: operationNum,
+			},
+		}
+
+		if op.action == engine.AsyncAPIActionPublish {
+			tool.Description = types.FirstSet(op.description, fmt.Sprintf("Publishes a message to the %s channel", op.channel))
+			messageDescription := "The message to publish"
+			if op.payload != nil {
+				if payload, err := json.Marshal(op.payload); err == nil {
+					messageDescription += ", the payload must match this JSON schema: " + string(payload)
+				}
+			}
+			tool.Arguments = types.ObjectSchema("message", messageDescription)
+		} else {
+			tool.Description = types.FirstSet(op.description, fmt.Sprintf("Consumes messages from the %s channel", op.channel))
+			tool.Arguments = types.ObjectSchema(
+				"count", "The number of messages to consume, defaults to 1",
+				"timeout", "The number of seconds to wait for messages, defaults to 30")
+		}
+
+		inst, err := json.Marshal(engine.AsyncAPIInstructions{
+			Protocol: protocol,
+			Server:   server,
+			Channel:  op.channel,
+			Action:   op.action,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tool instructions: %w", err)
+		}
+		tool.Instructions = fmt.Sprintf("%s '%s'", types.AsyncAPIPrefix, string(inst))
+
+		toolNames = append(toolNames, name)
+		tools = append(tools, tool)
+		operationNum++
+	}
+
+	// The first tool we generate is a special tool that just exports all the others.
+	exportTool := types.Tool{
+		Parameters: types.Parameters{
+			Description: fmt.Sprintf("This is a tool set for the %s AsyncAPI spec", doc.Info.Title),
+			Export:      toolNames,
+		},
+	}
+
+	return append([]types.Tool{exportTool}, tools...), nil
+}
+
+// asyncAPIServerAddress returns the protocol and address of the first server, by name, that uses a supported protocol.
+func asyncAPIServerAddress(servers map[string]asyncAPIServer) (string, string, error) {
+	for _, name := range sortedKeys(servers) {
+		server := servers[name]
+		if !slices.Contains(engine.SupportedAsyncAPIProtocols, server.Protocol) {
+			continue
+		}
+
+		address := types.FirstSet(server.Host, server.URL)
+		if _, rest, ok := strings.Cut(address, "://"); ok {
+			address = rest
+		}
+		return server.Protocol, strings.TrimSuffix(address, "/"), nil
+	}
+
+	return "", "", fmt.Errorf("no servers using a supported protocol (%s) found in AsyncAPI spec", strings.Join(engine.SupportedAsyncAPIProtocols, ", "))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
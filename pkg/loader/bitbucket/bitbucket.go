@@ -0,0 +1,165 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	BitbucketPrefix      = "bitbucket.org/"
+	bitbucketAPI         = "https://api.bitbucket.org/2.0/repositories/"
+	bitbucketRepoURL     = "https://bitbucket.org/%s/%s.git"
+	bitbucketDownloadURL = bitbucketAPI + "%s/%s/src/%s/%s"
+	bitbucketCommitURL   = bitbucketAPI + "%s/%s/commit/%s"
+	bitbucketRepoAPIURL  = bitbucketAPI + "%s/%s"
+)
+
+var (
+	// bitbucketAuthToken is a repository, project, or workspace access token
+	bitbucketAuthToken = os.Getenv("BITBUCKET_AUTH_TOKEN")
+	// bitbucketUsername and bitbucketAppPassword are used instead of a token if set
+	bitbucketUsername    = os.Getenv("BITBUCKET_USERNAME")
+	bitbucketAppPassword = os.Getenv("BITBUCKET_APP_PASSWORD")
+)
+
+func init() {
+	loader.AddVSC(Load)
+	loader.AddHeaders(headers)
+}
+
+// headers adds the credentials to downloads from Bitbucket, so tools can be loaded from private repositories
+func headers(url string) http.Header {
+	if !strings.HasPrefix(url, bitbucketAPI) {
+		return nil
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	authorize(req)
+	if len(req.Header) == 0 {
+		return nil
+	}
+	return req.Header
+}
+
+func authorize(req *http.Request) {
+	if bitbucketUsername != "" && bitbucketAppPassword != "" {
+		req.SetBasicAuth(bitbucketUsername, bitbucketAppPassword)
+	} else if bitbucketAuthToken != "" {
+		req.Header.Add("Authorization", "Bearer "+bitbucketAuthToken)
+	}
+}
+
+func get(ctx context.Context, url string, out any) error {
+	client := &http.Client{Transport: tlsconfig.FromContext(ctx)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request of %s: %w", url, err)
+	}
+	authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		c, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return fmt.Errorf("failed to get %s: %s %s", url, resp.Status, c)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", url, err)
+	}
+	return nil
+}
+
+func getCommit(ctx context.Context, workspace, repo, ref string) (string, error) {
+	if ref == "" {
+		// Bitbucket has no HEAD ref, so look up the main branch of the repository
+		var repository struct {
+			MainBranch struct {
+				Name string `json:"name,omitempty"`
+			} `json:"mainbranch,omitempty"`
+		}
+		if err := get(ctx, fmt.Sprintf(bitbucketRepoAPIURL, workspace, repo), &repository); err != nil {
+			return "", fmt.Errorf("failed to get Bitbucket repository %s/%s: %w", workspace, repo, err)
+		}
+		if repository.MainBranch.Name == "" {
+			return "", fmt.Errorf("failed to find the main branch of Bitbucket repository %s/%s", workspace, repo)
+		}
+		ref = repository.MainBranch.Name
+	}
+
+	var commit struct {
+		Hash string `json:"hash,omitempty"`
+	}
+	if err := get(ctx, fmt.Sprintf(bitbucketCommitURL, workspace, repo, ref), &commit); err != nil {
+		return "", fmt.Errorf("failed to get Bitbucket commit of %s/%s at %s: %w", workspace, repo, ref, err)
+	}
+
+	if commit.Hash == "" {
+		return "", fmt.Errorf("failed to find commit of %s/%s at %s, got empty string", workspace, repo, ref)
+	}
+
+	return commit.Hash, nil
+}
+
+// parse splits a reference like bitbucket.org/WORKSPACE/REPO[/FILE][@REF] into its parts. The ref is empty if the
+// reference has none, since Bitbucket has no HEAD ref.
+func parse(urlName string) (workspace, repo, path, ref string, ok bool) {
+	if !strings.HasPrefix(urlName, BitbucketPrefix) {
+		return "", "", "", "", false
+	}
+
+	url, ref, _ := strings.Cut(urlName, "@")
+
+	parts := strings.Split(url, "/")
+	// Must be at least 3 parts bitbucket.org/WORKSPACE/REPO[/FILE]
+	if len(parts) < 3 {
+		return "", "", "", "", false
+	}
+
+	workspace, repo = parts[1], parts[2]
+	path = strings.Join(parts[3:], "/")
+
+	if path == "" || path == "/" {
+		path = "tool.gpt"
+	} else if !strings.HasSuffix(path, system.Suffix) {
+		path += "/tool.gpt"
+	}
+
+	return workspace, repo, path, ref, true
+}
+
+func Load(ctx context.Context, urlName string) (string, *types.Repo, bool, error) {
+	workspace, repo, path, ref, ok := parse(urlName)
+	if !ok {
+		return "", nil, false, nil
+	}
+
+	ref, err := getCommit(ctx, workspace, repo, ref)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	downloadURL := fmt.Sprintf(bitbucketDownloadURL, workspace, repo, ref, path)
+	return downloadURL, &types.Repo{
+		VCS:      "git",
+		Root:     fmt.Sprintf(bitbucketRepoURL, workspace, repo),
+		Path:     filepath.Dir(path),
+		Name:     filepath.Base(path),
+		Revision: ref,
+	}, true, nil
+}
@@ -0,0 +1,27 @@
+package bitbucket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	workspace, repo, path, ref, ok := parse("bitbucket.org/workspace/repo")
+	require.True(t, ok)
+	require.Equal(t, []string{"workspace", "repo", "tool.gpt", ""}, []string{workspace, repo, path, ref})
+
+	workspace, repo, path, ref, ok = parse("bitbucket.org/workspace/repo/tools/search@v1")
+	require.True(t, ok)
+	require.Equal(t, []string{"workspace", "repo", "tools/search/tool.gpt", "v1"}, []string{workspace, repo, path, ref})
+
+	_, _, path, _, ok = parse("bitbucket.org/workspace/repo/other.gpt")
+	require.True(t, ok)
+	require.Equal(t, "other.gpt", path)
+
+	_, _, _, _, ok = parse("bitbucket.org/workspace")
+	require.False(t, ok)
+
+	_, _, _, _, ok = parse("github.com/workspace/repo")
+	require.False(t, ok)
+}
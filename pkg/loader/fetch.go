@@ -0,0 +1,82 @@
+package loader
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/builtin"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// maxConcurrentFetches is the number of referenced files that will be fetched and parsed at the same time
+const maxConcurrentFetches = 8
+
+// parsedSource is the result of reading and parsing a source, before its tools are linked into a program
+type parsedSource struct {
+	source *source
+	data   []byte
+	tools  []types.Tool
+	err    error
+}
+
+// prefetch fetches and parses the files referenced by a tool in parallel. The result is keyed by the
+// name of the file as it is passed to resolve. Nothing is returned for local, built-in, and reused tools.
+func prefetch(ctx context.Context, base *source, targetToolNames []string, localTools types.ToolSet, opts Options) map[string]*parsedSource {
+	var names []string
+	for _, targetToolName := range targetToolNames {
+		if _, ok := localTools[targetToolName]; ok {
+			continue
+		}
+
+		toolName, subTool := SplitToolRef(targetToolName)
+		if _, ok := builtin.Builtin(toolName); ok && subTool == "" {
+			continue
+		}
+		if _, ok := opts.reused(base, toolName, subTool); ok {
+			continue
+		}
+		if !slices.Contains(names, toolName) {
+			names = append(names, toolName)
+		}
+	}
+
+	if len(names) < 2 {
+		// Nothing to gain from doing this concurrently
+		return nil
+	}
+
+	var (
+		result = make(map[string]*parsedSource, len(names))
+		lock   sync.Mutex
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, maxConcurrentFetches)
+	)
+
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// input can modify the base, so each fetch gets its own copy
+			base := *base
+
+			var parsed *parsedSource
+			if s, err := input(ctx, &base, name, opts); err != nil {
+				parsed = &parsedSource{err: err}
+			} else {
+				parsed = readSource(s, opts)
+			}
+
+			lock.Lock()
+			result[name] = parsed
+			lock.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return result
+}
@@ -1,6 +1,7 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
@@ -27,13 +29,25 @@ var (
 
 func init() {
 	loader.AddVSC(Load)
+	loader.AddHeaders(headers)
 }
 
-func getCommit(account, repo, ref string) (string, error) {
+// headers adds the token to downloads from GitHub, so tools can be loaded from private repositories
+func headers(url string) http.Header {
+	if githubAuthToken == "" || !strings.HasPrefix(url, "https://raw.githubusercontent.com/") {
+		return nil
+	}
+
+	return http.Header{
+		"Authorization": []string{"Bearer " + githubAuthToken},
+	}
+}
+
+func getCommit(ctx context.Context, account, repo, ref string) (string, error) {
 	url := fmt.Sprintf(githubCommitURL, account, repo, ref)
-	client := &http.Client{}
+	client := &http.Client{Transport: tlsconfig.FromContext(ctx)}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request of %s/%s at %s: %w", account, repo, url, err)
 	}
@@ -68,7 +82,7 @@ func getCommit(account, repo, ref string) (string, error) {
 	return commit.SHA, nil
 }
 
-func Load(urlName string) (string, *types.Repo, bool, error) {
+func Load(ctx context.Context, urlName string) (string, *types.Repo, bool, error) {
 	if !strings.HasPrefix(urlName, GithubPrefix) {
 		return "", nil, false, nil
 	}
@@ -93,7 +107,7 @@ func Load(urlName string) (string, *types.Repo, bool, error) {
 		path += "/tool.gpt"
 	}
 
-	ref, err := getCommit(account, repo, ref)
+	ref, err := getCommit(ctx, account, repo, ref)
 	if err != nil {
 		return "", nil, false, err
 	}
@@ -0,0 +1,154 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	url2 "net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	gitlabHost        = "gitlab.com"
+	gitlabRepoURL     = "https://%s/%s.git"
+	gitlabDownloadURL = "https://%s/%s/-/raw/%s/%s"
+	gitlabCommitURL   = "https://%s/api/v4/projects/%s/repository/commits/%s"
+)
+
+var (
+	gitlabAuthToken = os.Getenv("GITLAB_AUTH_TOKEN")
+	// gitlabHosts are the hosts of self-managed GitLab instances, in addition to gitlab.com
+	gitlabHosts = hosts(os.Getenv("GPTSCRIPT_GITLAB_HOSTS"))
+)
+
+func init() {
+	loader.AddVSC(Load)
+	loader.AddHeaders(headers)
+}
+
+func hosts(value string) (result []string) {
+	result = append(result, gitlabHost)
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			result = append(result, host)
+		}
+	}
+	return
+}
+
+// headers adds the access token to downloads from GitLab, so tools can be loaded from private projects
+func headers(url string) http.Header {
+	if gitlabAuthToken == "" {
+		return nil
+	}
+
+	u, err := url2.Parse(url)
+	if err != nil || u.Scheme != "https" || !slices.Contains(gitlabHosts, u.Host) {
+		return nil
+	}
+
+	return http.Header{
+		"Private-Token": []string{gitlabAuthToken},
+	}
+}
+
+func getCommit(ctx context.Context, host, project, ref string) (string, error) {
+	url := fmt.Sprintf(gitlabCommitURL, host, url2.PathEscape(project), url2.PathEscape(ref))
+	client := &http.Client{Transport: tlsconfig.FromContext(ctx)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request of %s at %s: %w", project, url, err)
+	}
+
+	if gitlabAuthToken != "" {
+		req.Header.Add("Private-Token", gitlabAuthToken)
+	}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode != http.StatusOK {
+		c, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return "", fmt.Errorf("failed to get GitLab commit of %s at %s: %s %s",
+			project, ref, resp.Status, c)
+	}
+	defer resp.Body.Close()
+
+	var commit struct {
+		ID string `json:"id,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return "", fmt.Errorf("failed to decode GitLab commit of %s at %s: %w", project, url, err)
+	}
+
+	if commit.ID == "" {
+		return "", fmt.Errorf("failed to find commit in response of %s, got empty string", url)
+	}
+
+	return commit.ID, nil
+}
+
+// parse splits a reference like gitlab.com/GROUP/PROJECT[/FILE][@REF] into its parts. Projects in subgroups are
+// referenced by separating the project from the file with /-/, like gitlab.com/GROUP/SUBGROUP/PROJECT/-/FILE.
+func parse(urlName string) (host, project, path, ref string, ok bool) {
+	url, ref, _ := strings.Cut(urlName, "@")
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	host, rest, _ := strings.Cut(url, "/")
+	if !slices.Contains(gitlabHosts, host) {
+		return "", "", "", "", false
+	}
+
+	if project, path, ok = strings.Cut(rest, "/-/"); !ok {
+		parts := strings.Split(rest, "/")
+		// Must be at least 2 parts GROUP/PROJECT[/FILE] after the host
+		if len(parts) < 2 {
+			return "", "", "", "", false
+		}
+		project, path = parts[0]+"/"+parts[1], strings.Join(parts[2:], "/")
+	}
+
+	if path == "" || path == "/" {
+		path = "tool.gpt"
+	} else if !strings.HasSuffix(path, system.Suffix) {
+		path += "/tool.gpt"
+	}
+
+	return host, strings.Trim(project, "/"), path, ref, true
+}
+
+func Load(ctx context.Context, urlName string) (string, *types.Repo, bool, error) {
+	host, project, path, ref, ok := parse(urlName)
+	if !ok {
+		return "", nil, false, nil
+	}
+
+	ref, err := getCommit(ctx, host, project, ref)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	downloadURL := fmt.Sprintf(gitlabDownloadURL, host, project, ref, path)
+	return downloadURL, &types.Repo{
+		VCS:      "git",
+		Root:     fmt.Sprintf(gitlabRepoURL, host, project),
+		Path:     filepath.Dir(path),
+		Name:     filepath.Base(path),
+		Revision: ref,
+	}, true, nil
+}
@@ -0,0 +1,29 @@
+package gitlab
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	host, project, path, ref, ok := parse("gitlab.com/group/project")
+	require.True(t, ok)
+	require.Equal(t, []string{"gitlab.com", "group/project", "tool.gpt", "HEAD"}, []string{host, project, path, ref})
+
+	host, project, path, ref, ok = parse("gitlab.com/group/subgroup/project/-/tools/search@v1")
+	require.True(t, ok)
+	require.Equal(t, []string{"gitlab.com", "group/subgroup/project", "tools/search/tool.gpt", "v1"}, []string{host, project, path, ref})
+
+	defaultHosts := gitlabHosts
+	t.Cleanup(func() {
+		gitlabHosts = defaultHosts
+	})
+	gitlabHosts = hosts("gitlab.example.com")
+	host, project, path, _, ok = parse("gitlab.example.com/group/project/other.gpt")
+	require.True(t, ok)
+	require.Equal(t, []string{"gitlab.example.com", "group/project", "other.gpt"}, []string{host, project, path})
+
+	_, _, _, _, ok = parse("github.com/group/project")
+	require.False(t, ok)
+}
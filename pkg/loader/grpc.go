@@ -0,0 +1,372 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func isGRPC(name string) bool {
+	return strings.HasPrefix(name, "grpc://") || strings.HasPrefix(name, "grpcs://")
+}
+
+// loadGRPC loads the definition of the services of a gRPC server referenced as grpc://host:port, or grpcs://host:port
+// to use TLS. The definition is read using server reflection, or from a local proto file if one is given in the proto
+// query parameter, for example grpcs://api.example.com:443?proto=./api.proto. The files the proto file imports are
+// found relative to its directory.
+func loadGRPC(ctx context.Context, base *source, name string, opts Options) (*source, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no server address found in %s", name)
+	}
+
+	var content []byte
+	if protoFile := u.Query().Get("proto"); protoFile != "" {
+		if base.Remote {
+			return nil, fmt.Errorf("proto file %s for %s must be available locally", protoFile, name)
+		}
+		if !filepath.IsAbs(protoFile) {
+			protoFile = filepath.Join(base.Path, protoFile)
+		}
+		if protoFile, err = filepath.Abs(protoFile); err != nil {
+			return nil, err
+		}
+		if content, err = os.ReadFile(protoFile); err != nil {
+			return nil, err
+		}
+		log.Debugf("opened %s", protoFile)
+
+		q := u.Query()
+		q.Set("proto", protoFile)
+		u.RawQuery = q.Encode()
+	} else {
+		opts.progress(ProgressFetching, name)
+		content, err = describeGRPC(ctx, u.Host, u.Scheme == "grpc", opts.Env)
+		if err != nil {
+			return nil, &unavailableError{location: name, err: err}
+		}
+	}
+
+	return &source{
+		Content:  io.NopCloser(bytes.NewReader(content)),
+		Remote:   base.Remote,
+		Path:     base.Path,
+		Name:     u.Host,
+		Location: u.String(),
+	}, nil
+}
+
+// describeGRPC uses server reflection to get the definitions of the services of the server. The definitions are a
+// serialized FileDescriptorSet, written by grpcurl, of the files that define the services and their dependencies. The
+// connection is configured by the variables of env, the environment of the run.
+func describeGRPC(ctx context.Context, server string, plaintext bool, env []string) ([]byte, error) {
+	envMap := map[string]string{}
+	for _, env := range env {
+		k, v, _ := strings.Cut(env, "=")
+		envMap[k] = v
+	}
+
+	dir, err := os.MkdirTemp("", "gptscript-grpc-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	protoset := filepath.Join(dir, "services.protoset")
+
+	args := append(engine.GRPCArgs(server, plaintext, "", envMap), "-protoset-out", protoset, server, "describe")
+
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "grpcurl", args...)
+	cmd.Env = env
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to describe the services of %s: %w: %s", server, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return os.ReadFile(protoset)
+}
+
+// getGRPCTools generates a tool for each method of the services in a gRPC definition. The location is the
+// grpc:// or grpcs:// URL the definition was loaded from. The definition is the content of the proto file of the
+// proto query parameter of the location if it is set, and the FileDescriptorSet read with server reflection otherwise.
+// The tool's Instructions will be in the format "#!sys.grpc '{JSON Instructions}'",
+// where the JSON Instructions are a JSON-serialized engine.GRPCInstructions struct.
+func getGRPCTools(data []byte, location string) ([]types.Tool, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []protoreflect.FileDescriptor
+	if protoFile := u.Query().Get("proto"); protoFile != "" {
+		files, err = compileProto(protoFile, data)
+	} else {
+		files, err = readDescriptorSet(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		toolNames []string
+		tools     []types.Tool
+		// Each tool gets an operation number, beginning with 1
+		operationNum = 1
+	)
+
+	for _, file := range files {
+		for i := 0; i < file.Services().Len(); i++ {
+			service := file.Services().Get(i)
+			if strings.HasPrefix(string(service.FullName()), "grpc.reflection.") {
+				continue
+			}
+
+			for j := 0; j < service.Methods().Len(); j++ {
+				var (
+					rpc         = service.Methods().Get(j)
+					method      = string(rpc.Name())
+					description = comment(file.SourceLocations().ByDescriptor(rpc).LeadingComments)
+				)
+
+				name := method
+				if slices.Contains(toolNames, name) {
+					name = string(service.Name()) + "_" + method
+				}
+
+				if description == "" {
+					description = fmt.Sprintf("Calls the %s method of the %s gRPC service", method, service.FullName())
+				}
+				if rpc.IsStreamingClient() || rpc.IsStreamingServer() {
+					description += " (streaming)"
+				}
+
+				requestDescription := fmt.Sprintf("The %s request message encoded as JSON", rpc.Input().FullName())
+				if definition := messageDefinition(rpc.Input()); definition != "" {
+					requestDescription += ", the message is defined as:\n" + definition
+				}
+
+				inst, err := json.Marshal(engine.GRPCInstructions{
+					Server:    u.Host,
+					Plaintext: u.Scheme == "grpc",
+					Method:    string(service.FullName()) + "/" + method,
+					ProtoFile: u.Query().Get("proto"),
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal tool instructions: %w", err)
+				}
+
+				toolNames = append(toolNames, name)
+				tools = append(tools, types.Tool{
+					Parameters: types.Parameters{
+						Name:        name,
+						Description: description,
+						Arguments:   types.ObjectSchema("request", requestDescription),
+					},
+					Instructions: fmt.Sprintf("%s '%s'", types.GRPCPrefix, string(inst)),
+					Source: types.ToolSource{
+						LineNo: operationNum,
+					},
+				})
+				operationNum++
+			}
+		}
+	}
+
+	if len(tools) == 0 {
+		return nil, fmt.Errorf("no gRPC methods found in %s", location)
+	}
+
+	// The first tool we generate is a special tool that just exports all the others.
+	exportTool := types.Tool{
+		Parameters: types.Parameters{
+			Description: fmt.Sprintf("This is a tool set for the gRPC services of %s", u.Host),
+			Export:      toolNames,
+		},
+	}
+
+	return append([]types.Tool{exportTool}, tools...), nil
+}
+
+// compileProto compiles a proto file with its content, and returns its descriptor. The files it imports are read
+// relative to its directory, except for the well-known types of google/protobuf, which are built in.
+func compileProto(protoFile string, data []byte) ([]protoreflect.FileDescriptor, error) {
+	protoFile = filepath.Clean(protoFile)
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{filepath.Dir(protoFile)},
+			Accessor: func(path string) (io.ReadCloser, error) {
+				if filepath.Clean(path) == protoFile {
+					return io.NopCloser(bytes.NewReader(data)), nil
+				}
+				return os.Open(path)
+			},
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+
+	files, err := compiler.Compile(context.Background(), filepath.Base(protoFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s: %w", protoFile, err)
+	}
+	return []protoreflect.FileDescriptor{files[0]}, nil
+}
+
+// readDescriptorSet returns the descriptors of the files in a serialized FileDescriptorSet, in its order.
+func readDescriptorSet(data []byte) ([]protoreflect.FileDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid file descriptor set: %w", err)
+	}
+
+	registry, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file descriptor set: %w", err)
+	}
+
+	var files []protoreflect.FileDescriptor
+	for _, f := range set.GetFile() {
+		file, err := registry.FindFileByPath(f.GetName())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// comment returns the text of a comment on one line.
+func comment(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// messageDefinition returns the definition of a message in the proto syntax, followed by the definitions of the
+// messages and enums its fields use. The well-known types of google.protobuf are not defined, since their JSON is
+// not the JSON of their fields.
+func messageDefinition(message protoreflect.MessageDescriptor) string {
+	p := &protoPrinter{
+		printed: map[protoreflect.FullName]bool{},
+		pending: []protoreflect.Descriptor{message},
+	}
+
+	for len(p.pending) > 0 {
+		d := p.pending[0]
+		p.pending = p.pending[1:]
+		if p.printed[d.FullName()] || strings.HasPrefix(string(d.FullName()), "google.protobuf.") {
+			continue
+		}
+
+		if p.out.Len() > 0 {
+			p.out.WriteString("\n")
+		}
+		switch d := d.(type) {
+		case protoreflect.MessageDescriptor:
+			p.message(d, "")
+		case protoreflect.EnumDescriptor:
+			p.enum(d, "")
+		}
+	}
+
+	return strings.TrimSpace(p.out.String())
+}
+
+// protoPrinter prints messages and enums in the proto syntax, with the types their fields use that are not nested
+// in them left pending.
+type protoPrinter struct {
+	out     strings.Builder
+	printed map[protoreflect.FullName]bool
+	pending []protoreflect.Descriptor
+}
+
+func (p *protoPrinter) message(message protoreflect.MessageDescriptor, indent string) {
+	p.printed[message.FullName()] = true
+	fmt.Fprintf(&p.out, "%smessage %s {\n", indent, message.Name())
+
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		oneof := field.ContainingOneof()
+		if oneof == nil || oneof.IsSynthetic() {
+			p.field(field, indent+"  ")
+			continue
+		}
+		if oneof.Fields().Get(0).FullName() != field.FullName() {
+			// The fields of a oneof are printed with its first field
+			continue
+		}
+		fmt.Fprintf(&p.out, "%s  oneof %s {\n", indent, oneof.Name())
+		for j := 0; j < oneof.Fields().Len(); j++ {
+			p.field(oneof.Fields().Get(j), indent+"    ")
+		}
+		fmt.Fprintf(&p.out, "%s  }\n", indent)
+	}
+
+	for i := 0; i < message.Messages().Len(); i++ {
+		if nested := message.Messages().Get(i); !nested.IsMapEntry() {
+			p.message(nested, indent+"  ")
+		}
+	}
+	for i := 0; i < message.Enums().Len(); i++ {
+		p.enum(message.Enums().Get(i), indent+"  ")
+	}
+
+	fmt.Fprintf(&p.out, "%s}\n", indent)
+}
+
+func (p *protoPrinter) enum(enum protoreflect.EnumDescriptor, indent string) {
+	p.printed[enum.FullName()] = true
+	fmt.Fprintf(&p.out, "%senum %s {\n", indent, enum.Name())
+	for i := 0; i < enum.Values().Len(); i++ {
+		value := enum.Values().Get(i)
+		fmt.Fprintf(&p.out, "%s  %s = %d;\n", indent, value.Name(), value.Number())
+	}
+	fmt.Fprintf(&p.out, "%s}\n", indent)
+}
+
+func (p *protoPrinter) field(field protoreflect.FieldDescriptor, indent string) {
+	var label string
+	switch {
+	case field.IsMap():
+	case field.Cardinality() == protoreflect.Repeated:
+		label = "repeated "
+	case field.Cardinality() == protoreflect.Required:
+		label = "required "
+	case field.HasOptionalKeyword():
+		label = "optional "
+	}
+	fmt.Fprintf(&p.out, "%s%s%s %s = %d;\n", indent, label, p.fieldType(field), field.Name(), field.Number())
+}
+
+// fieldType returns the type of a field, and leaves the message or enum it uses pending.
+func (p *protoPrinter) fieldType(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.IsMap():
+		return fmt.Sprintf("map<%s, %s>", p.fieldType(field.MapKey()), p.fieldType(field.MapValue()))
+	case field.Message() != nil:
+		p.pending = append(p.pending, field.Message())
+		return string(field.Message().FullName())
+	case field.Enum() != nil:
+		p.pending = append(p.pending, field.Enum())
+		return string(field.Enum().FullName())
+	default:
+		return field.Kind().String()
+	}
+}
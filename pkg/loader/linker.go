@@ -0,0 +1,298 @@
+package loader
+
+import (
+	"context"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// Linker loads programs and keeps track of the files they were loaded from. When a program is loaded again after
+// some of its files changed, only the changed files and the tools that reference their tools are read and linked
+// again, everything else is reused from the previous load. This keeps reloading programs with large tool libraries
+// fast in the server and in chat, which load the program again every time it is used. The zero value is ready to use.
+type Linker struct {
+	lock     sync.Mutex
+	programs map[linkerKey]*linkedProgram
+	// watching is set while Watch reloads the programs when their files change, so they are not checked on load
+	watching atomic.Bool
+}
+
+type linkerKey struct {
+	name, subToolName string
+	stubUnavailable   bool
+	openAPIToolIDs    string
+}
+
+type linkedProgram struct {
+	program types.Program
+	// files is the digest of the content of each file the program was loaded from
+	files map[string]string
+	// resolved is the ID of the tool each reference to a local file resolved to, keyed by resolvedKey
+	resolved map[string]string
+	// opts are the options the program was loaded with, to reload it with when watching
+	opts []Options
+}
+
+func (l *Linker) Program(ctx context.Context, name, subToolName string, opts ...Options) (types.Program, error) {
+	if subToolName == "" {
+		name, subToolName = splitEntryRef(name)
+	}
+	return l.program(ctx, name, subToolName, !l.watching.Load(), opts...)
+}
+
+// Watch checks the files of the programs loaded by the linker every interval until the context is done, and reloads
+// the programs whose files changed right away. The reloaded program, or the error reloading it, is passed to
+// reloaded. A program that fails to reload is loaded again, and fails again, the next time it is used. While
+// watching, loading a program does not check its files, since it is already reloaded when they change.
+func (l *Linker) Watch(ctx context.Context, interval time.Duration, reloaded func(name string, prg types.Program, err error)) {
+	l.watching.Store(true)
+	defer l.watching.Store(false)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		l.lock.Lock()
+		programs := maps.Clone(l.programs)
+		l.lock.Unlock()
+
+		for key, linked := range programs {
+			if len(linked.changedFiles()) == 0 {
+				continue
+			}
+
+			prg, err := l.program(ctx, key.name, key.subToolName, true, linked.opts...)
+			if err != nil {
+				l.lock.Lock()
+				delete(l.programs, key)
+				l.lock.Unlock()
+			}
+			reloaded(key.name, prg, err)
+		}
+	}
+}
+
+// program loads a program, reusing what did not change since it was last loaded. Unless check is set, a program
+// that was already loaded is returned without checking if its files changed.
+func (l *Linker) program(ctx context.Context, name, subToolName string, check bool, opts ...Options) (types.Program, error) {
+	opt := complete(opts...)
+	if err := opt.validateVerify(); err != nil {
+		return types.Program{}, err
+	}
+	ctx = tlsconfig.WithTransport(ctx, opt.Transport)
+	key := linkerKey{
+		name:            name,
+		subToolName:     subToolName,
+		stubUnavailable: opt.StubUnavailable,
+		openAPIToolIDs:  opt.OpenAPIToolIDs,
+	}
+
+	l.lock.Lock()
+	previous := l.programs[key]
+	l.lock.Unlock()
+
+	toolSet := types.ToolSet{}
+	if previous != nil && !check {
+		return previous.program, nil
+	} else if previous != nil {
+		changed := previous.changedFiles()
+		if len(changed) == 0 {
+			return previous.program, nil
+		}
+		toolSet, opt.reuse = previous.unaffected(changed)
+	} else if opt.Cache != nil && !opt.Vendoring {
+		if cached, ok := getCachedProgram(name, subToolName, opt); ok {
+			l.store(key, &linkedProgram{
+				program:  cached.Program,
+				files:    cached.Files,
+				resolved: cached.Resolved,
+				opts:     opts,
+			})
+			return cached.Program, nil
+		}
+	}
+
+	locks, err := readLock(name, opt.Lock)
+	if err != nil {
+		return types.Program{}, err
+	}
+	opt.locks = locks
+
+	vendor, err := readVendor(name, opt)
+	if err != nil {
+		return types.Program{}, err
+	}
+	opt.vendor = vendor
+
+	opt.sources = newSourceTracker()
+	prg := types.Program{
+		Name:    name,
+		ToolSet: toolSet,
+	}
+	tool, err := resolve(ctx, &prg, &source{}, name, subToolName, nil, opt)
+	tool, err = targetSubTool(prg, tool, err, subToolName)
+	if err != nil {
+		return types.Program{}, err
+	}
+	prg.EntryToolID = tool.ID
+
+	if err := opt.locks.save(); err != nil {
+		return types.Program{}, err
+	}
+	if err := opt.vendor.save(); err != nil {
+		return types.Program{}, err
+	}
+
+	if previous != nil {
+		// Tools reused from the previous load that are no longer referenced are dropped, and the files and
+		// references of the ones still used are carried over because they were not read again.
+		prg.ToolSet = reachable(prg)
+		locations := map[string]struct{}{}
+		for _, tool := range prg.ToolSet {
+			locations[tool.Source.Location] = struct{}{}
+		}
+		for file, digest := range previous.files {
+			if _, ok := locations[file]; ok {
+				if _, ok := opt.sources.files[file]; !ok {
+					opt.sources.files[file] = digest
+				}
+			}
+		}
+		for key, id := range opt.reuse {
+			if _, ok := prg.ToolSet[id]; ok {
+				opt.sources.resolved[key] = id
+			}
+		}
+	}
+
+	if !opt.sources.remote {
+		l.store(key, &linkedProgram{
+			program:  prg,
+			files:    opt.sources.files,
+			resolved: opt.sources.resolved,
+			opts:     opts,
+		})
+	}
+
+	if opt.Cache != nil {
+		storeCachedProgram(name, subToolName, opt, prg)
+	}
+	return prg, nil
+}
+
+func (l *Linker) store(key linkerKey, prg *linkedProgram) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.programs == nil {
+		l.programs = map[linkerKey]*linkedProgram{}
+	}
+	l.programs[key] = prg
+}
+
+// changedFiles returns the files of the program that changed, or were removed, since it was loaded.
+func (p *linkedProgram) changedFiles() map[string]struct{} {
+	changed := map[string]struct{}{}
+	for file, digest := range p.files {
+		content, err := os.ReadFile(file)
+		if err != nil || hash.ID(string(content)) != digest {
+			changed[file] = struct{}{}
+		}
+	}
+	return changed
+}
+
+// unaffected returns the tools of the program that neither come from a changed file nor reference, directly or
+// indirectly, a tool that does, and the references to local files that resolved to them. Nothing is returned if
+// the tools of a changed file can not be told apart, in which case the program has to be loaded from scratch.
+func (p *linkedProgram) unaffected(changed map[string]struct{}) (types.ToolSet, map[string]string) {
+	var (
+		affected = map[string]struct{}{}
+		found    = map[string]struct{}{}
+	)
+
+	for id, tool := range p.program.ToolSet {
+		if _, ok := changed[tool.Source.Location]; ok {
+			affected[id] = struct{}{}
+			found[tool.Source.Location] = struct{}{}
+		}
+	}
+
+	if len(found) != len(changed) {
+		// The tools of assembled programs keep the location of the file they were assembled from, so a changed
+		// file can have no tools located in it.
+		return types.ToolSet{}, nil
+	}
+
+	for last := -1; last != len(affected); {
+		last = len(affected)
+		for id, tool := range p.program.ToolSet {
+			if _, ok := affected[id]; ok {
+				continue
+			}
+			for _, targetID := range tool.ToolMapping {
+				if _, ok := affected[targetID]; ok {
+					affected[id] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+
+	toolSet := types.ToolSet{}
+	for id, tool := range p.program.ToolSet {
+		if _, ok := affected[id]; !ok {
+			toolSet[id] = tool
+		}
+	}
+
+	reuse := map[string]string{}
+	for key, id := range p.resolved {
+		if _, ok := toolSet[id]; ok {
+			reuse[key] = id
+		}
+	}
+
+	return toolSet, reuse
+}
+
+// reachable returns the tools of the program that can be reached from its entry tool.
+func reachable(prg types.Program) types.ToolSet {
+	var (
+		result = types.ToolSet{}
+		visit  func(id string)
+	)
+
+	visit = func(id string) {
+		if _, ok := result[id]; ok {
+			return
+		}
+		tool, ok := prg.ToolSet[id]
+		if !ok {
+			return
+		}
+		result[id] = tool
+		for _, targetID := range tool.ToolMapping {
+			visit(targetID)
+		}
+		for _, localID := range tool.LocalTools {
+			visit(localID)
+		}
+	}
+
+	visit(prg.EntryToolID)
+	return result
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -15,15 +16,71 @@ import (
 	"strings"
 	"unicode/utf8"
 
-	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gptscript-ai/gptscript/pkg/assemble"
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/parser"
 	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"gopkg.in/yaml.v3"
 )
 
+type Options struct {
+	Cache           *cache.Client   `usage:"-"`
+	Monitor         ProgressMonitor `usage:"-"`
+	StubUnavailable bool            `usage:"Load remote tools that can not be fetched as stubs that fail when called, instead of failing to load"`
+	OpenAPIToolIDs  string          `usage:"How the IDs of tools generated from OpenAPI operations are derived, operation to use the operationId (or method and path), or index to use their position in the definition" name:"openapi-tool-ids" default:"operation"`
+	Lock            string          `usage:"Pin remote tools with the gptscript.lock file of the program: install to lock the tools that are not locked yet, update to lock the latest versions of all tools, or verify to fail if a tool is not locked"`
+	// RequireVerification is the policy for remote tools, which are verified against the digest their reference is
+	// pinned to and their cosign signature before they are used
+	RequireVerification string `usage:"Require remote tools to be verified before they are used: digest to require a digest pin or a signature, or signature to require a signature"`
+	CosignKey           string `usage:"Verify the cosign signatures of remote tools with this public key"`
+	CosignIdentity      string `usage:"Verify the keyless cosign signatures of remote tools were made by an identity matching this regular expression"`
+	CosignIssuer        string `usage:"The OIDC issuer of keyless cosign signatures, as a regular expression" name:"cosign-oidc-issuer"`
+	Offline             bool   `usage:"Load remote tools only from the vendor directory of the program, created with gptscript vendor, and never fetch them"`
+	VendorDir           string `usage:"The vendor directory of the program (default gptscript_vendor next to the program)"`
+	// Vendoring saves the remote sources the program is loaded from in its vendor directory
+	Vendoring bool `usage:"-"`
+	// Transport fetches the remote tools, the transport of the context or http.DefaultTransport if nil
+	Transport http.RoundTripper `usage:"-"`
+	// Env is the environment of the run, which configures the connections to the gRPC servers that are described with
+	// reflection, os.Environ() if empty
+	Env []string `usage:"-"`
+
+	sources *sourceTracker
+	locks   *lockState
+	vendor  *vendorState
+	// reuse is the ID of the tool each reference to a local file resolves to, for the files that did not change
+	// since the program was last loaded by a Linker. These tools are already in the program being loaded.
+	reuse map[string]string
+}
+
+func complete(opts ...Options) (result Options) {
+	for _, opt := range opts {
+		result.Cache = types.FirstSet(opt.Cache, result.Cache)
+		result.Monitor = types.FirstSet(opt.Monitor, result.Monitor)
+		result.StubUnavailable = types.FirstSet(opt.StubUnavailable, result.StubUnavailable)
+		result.OpenAPIToolIDs = types.FirstSet(opt.OpenAPIToolIDs, result.OpenAPIToolIDs)
+		result.Lock = types.FirstSet(opt.Lock, result.Lock)
+		result.RequireVerification = types.FirstSet(opt.RequireVerification, result.RequireVerification)
+		result.CosignKey = types.FirstSet(opt.CosignKey, result.CosignKey)
+		result.CosignIdentity = types.FirstSet(opt.CosignIdentity, result.CosignIdentity)
+		result.CosignIssuer = types.FirstSet(opt.CosignIssuer, result.CosignIssuer)
+		result.Offline = types.FirstSet(opt.Offline, result.Offline)
+		result.VendorDir = types.FirstSet(opt.VendorDir, result.VendorDir)
+		result.Vendoring = types.FirstSet(opt.Vendoring, result.Vendoring)
+		result.Transport = types.FirstSet(opt.Transport, result.Transport)
+		if len(opt.Env) > 0 {
+			result.Env = opt.Env
+		}
+	}
+	if len(result.Env) == 0 {
+		result.Env = os.Environ()
+	}
+	return
+}
+
 type source struct {
 	// Content The content of the source
 	Content io.ReadCloser
@@ -38,6 +95,9 @@ type source struct {
 	Location string
 	// Repo The VCS repo where this tool was found, used to clone and provide the local tool code content
 	Repo *types.Repo
+	// revisionPinned is whether the reference or the lock file pinned Repo to a commit, so the code of the tool, which
+	// is checked out and run, is verified by its revision
+	revisionPinned bool
 }
 
 func (s *source) String() string {
@@ -94,6 +154,8 @@ func loadProgram(data []byte, into *types.Program, targetToolName string) (types
 		into.ToolSet[k] = v
 	}
 
+	into.EntryToolID = ext.EntryToolID
+
 	tool := into.ToolSet[ext.EntryToolID]
 	if targetToolName == "" {
 		return tool, nil
@@ -109,28 +171,54 @@ func loadProgram(data []byte, into *types.Program, targetToolName string) (types
 	return tool, nil
 }
 
-func readTool(ctx context.Context, prg *types.Program, base *source, targetToolName string) (types.Tool, error) {
+func readTool(ctx context.Context, prg *types.Program, base *source, targetToolName string, opts Options) (types.Tool, error) {
+	return linkSource(ctx, prg, readSource(base, opts), targetToolName, opts)
+}
+
+// readSource reads and parses the content of the source. The returned result does not depend on the program being
+// loaded, so sources can be read concurrently.
+func readSource(base *source, opts Options) *parsedSource {
 	data, err := io.ReadAll(base.Content)
 	if err != nil {
-		return types.Tool{}, err
+		return &parsedSource{err: err}
 	}
 	_ = base.Content.Close()
 
+	opts.sources.add(base, data)
+
+	result := &parsedSource{
+		source: base,
+		data:   data,
+	}
+
 	if bytes.HasPrefix(data, assemble.Header) {
-		return loadProgram(data, prg, targetToolName)
+		return result
 	}
 
+	opts.progress(ProgressParsing, base.Location)
+
 	var tools []types.Tool
-	if isOpenAPI(data) {
-		if t, err := openapi3.NewLoader().LoadFromData(data); err == nil {
-			if base.Remote {
-				tools, err = getOpenAPITools(t, base.Location)
-			} else {
-				tools, err = getOpenAPITools(t, "")
-			}
-			if err != nil {
-				return types.Tool{}, fmt.Errorf("error parsing OpenAPI definition: %w", err)
-			}
+	if isGRPC(base.Location) {
+		tools, err = getGRPCTools(data, base.Location)
+		if err != nil {
+			result.err = fmt.Errorf("error parsing gRPC definition: %w", err)
+			return result
+		}
+	} else if isAsyncAPI(data) {
+		tools, err = getAsyncAPITools(data)
+		if err != nil {
+			result.err = fmt.Errorf("error parsing AsyncAPI definition: %w", err)
+			return result
+		}
+	} else if isOpenAPI(data) {
+		var defaultHost string
+		if base.Remote {
+			defaultHost = base.Location
+		}
+		tools, err = loadOpenAPITools(data, defaultHost, opts.OpenAPIToolIDs, opts.Cache)
+		if err != nil {
+			result.err = fmt.Errorf("error parsing OpenAPI definition: %w", err)
+			return result
 		}
 	}
 
@@ -151,20 +239,35 @@ func readTool(ctx context.Context, prg *types.Program, base *source, targetToolN
 			AssignGlobals: true,
 		})
 		if err != nil {
-			return types.Tool{}, err
+			result.err = err
+			return result
 		}
 	}
 
 	if len(tools) == 0 {
-		return types.Tool{}, fmt.Errorf("no tools found in %s", base)
+		result.err = fmt.Errorf("no tools found in %s", base)
+	}
+
+	result.tools = tools
+	return result
+}
+
+func linkSource(ctx context.Context, prg *types.Program, parsed *parsedSource, targetToolName string, opts Options) (types.Tool, error) {
+	if parsed.err != nil {
+		return types.Tool{}, parsed.err
+	}
+
+	if bytes.HasPrefix(parsed.data, assemble.Header) {
+		return loadProgram(parsed.data, prg, targetToolName)
 	}
 
 	var (
+		base       = parsed.source
 		localTools = types.ToolSet{}
 		mainTool   types.Tool
 	)
 
-	for i, tool := range tools {
+	for i, tool := range parsed.tools {
 		tool.WorkingDir = base.Path
 		tool.Source.Location = base.Location
 		tool.Source.Repo = base.Repo
@@ -193,10 +296,10 @@ func readTool(ctx context.Context, prg *types.Program, base *source, targetToolN
 		localTools[tool.Parameters.Name] = tool
 	}
 
-	return link(ctx, prg, base, mainTool, localTools)
+	return link(ctx, prg, base, mainTool, localTools, opts)
 }
 
-func link(ctx context.Context, prg *types.Program, base *source, tool types.Tool, localTools types.ToolSet) (types.Tool, error) {
+func link(ctx context.Context, prg *types.Program, base *source, tool types.Tool, localTools types.ToolSet, opts Options) (types.Tool, error) {
 	if existing, ok := prg.ToolSet[tool.ID]; ok {
 		return existing, nil
 	}
@@ -212,11 +315,29 @@ func link(ctx context.Context, prg *types.Program, base *source, tool types.Tool
 	// The below is done in two loops so that local names stay as the tool names
 	// and don't get mangled by external references
 
-	for _, targetToolName := range slices.Concat(tool.Parameters.Tools,
+	targetToolNames := slices.Concat(tool.Parameters.Tools,
 		tool.Parameters.Export,
 		tool.Parameters.ExportContext,
 		tool.Parameters.Context,
-		tool.Parameters.Credentials) {
+		tool.Parameters.Credentials)
+	if tool.Parameters.InputFrom != "" {
+		targetToolNames = append(targetToolNames, tool.Parameters.InputFrom)
+	}
+	if tool.Parameters.Validator != "" {
+		targetToolNames = append(targetToolNames, tool.Parameters.Validator)
+	}
+	if tool.Parameters.ShardWith != "" {
+		targetToolNames = append(targetToolNames, tool.Parameters.ShardWith)
+	}
+
+	// Fetch and parse the referenced files concurrently, linking them is still done in order below
+	prefetched := prefetch(ctx, base, targetToolNames, localTools, opts)
+
+	for _, targetToolName := range targetToolNames {
+		if err := ctx.Err(); err != nil {
+			return types.Tool{}, err
+		}
+
 		localTool, ok := localTools[targetToolName]
 		if ok {
 			var linkedTool types.Tool
@@ -224,7 +345,7 @@ func link(ctx context.Context, prg *types.Program, base *source, tool types.Tool
 				linkedTool = existing
 			} else {
 				var err error
-				linkedTool, err = link(ctx, prg, base, localTool, localTools)
+				linkedTool, err = link(ctx, prg, base, localTool, localTools, opts)
 				if err != nil {
 					return types.Tool{}, fmt.Errorf("failed linking %s at %s: %w", targetToolName, base, err)
 				}
@@ -234,7 +355,11 @@ func link(ctx context.Context, prg *types.Program, base *source, tool types.Tool
 			toolNames[targetToolName] = struct{}{}
 		} else {
 			toolName, subTool := SplitToolRef(targetToolName)
-			resolvedTool, err := resolve(ctx, prg, base, toolName, subTool)
+			resolvedTool, err := resolve(ctx, prg, base, toolName, subTool, prefetched[toolName], opts)
+			var unavailable *unavailableError
+			if opts.StubUnavailable && errors.As(err, &unavailable) {
+				resolvedTool, err = unavailableTool(prg, targetToolName, unavailable, opts), nil
+			}
 			if err != nil {
 				return types.Tool{}, fmt.Errorf("failed resolving %s at %s: %w", targetToolName, base, err)
 			}
@@ -253,38 +378,41 @@ func link(ctx context.Context, prg *types.Program, base *source, tool types.Tool
 	return tool, nil
 }
 
-func ProgramFromSource(ctx context.Context, content, subToolName string) (types.Program, error) {
-	prg := types.Program{
-		ToolSet: types.ToolSet{},
+func ProgramFromSource(ctx context.Context, content, subToolName string, opts ...Options) (types.Program, error) {
+	opt := complete(opts...)
+	if err := opt.validateVerify(); err != nil {
+		return types.Program{}, err
 	}
-	tool, err := readTool(ctx, &prg, &source{
-		Content:  io.NopCloser(strings.NewReader(content)),
-		Location: "inline",
-	}, subToolName)
+	ctx = tlsconfig.WithTransport(ctx, opt.Transport)
+
+	vendor, err := readVendor("", opt)
 	if err != nil {
 		return types.Program{}, err
 	}
-	prg.EntryToolID = tool.ID
-	return prg, nil
-}
+	opt.vendor = vendor
 
-func Program(ctx context.Context, name, subToolName string) (types.Program, error) {
-	if subToolName == "" {
-		name, subToolName = SplitToolRef(name)
-	}
 	prg := types.Program{
-		Name:    name,
 		ToolSet: types.ToolSet{},
 	}
-	tool, err := resolve(ctx, &prg, &source{}, name, subToolName)
+	tool, err := readTool(ctx, &prg, &source{
+		Content:  io.NopCloser(strings.NewReader(content)),
+		Location: "inline",
+	}, subToolName, opt)
+	tool, err = targetSubTool(prg, tool, err, subToolName)
 	if err != nil {
 		return types.Program{}, err
 	}
 	prg.EntryToolID = tool.ID
-	return prg, nil
+	return prg, opt.vendor.save()
 }
 
-func resolve(ctx context.Context, prg *types.Program, base *source, name, subTool string) (types.Tool, error) {
+func Program(ctx context.Context, name, subToolName string, opts ...Options) (types.Program, error) {
+	return new(Linker).Program(ctx, name, subToolName, opts...)
+}
+
+// resolve loads the tool of the given name referenced from base. If the file containing the tool was already
+// fetched and parsed, it can be passed as parsed.
+func resolve(ctx context.Context, prg *types.Program, base *source, name, subTool string, parsed *parsedSource, opts Options) (types.Tool, error) {
 	if subTool == "" {
 		t, ok := builtin.Builtin(name)
 		if ok {
@@ -293,29 +421,101 @@ func resolve(ctx context.Context, prg *types.Program, base *source, name, subToo
 		}
 	}
 
-	s, err := input(ctx, base, name)
+	if id, ok := opts.reused(base, name, subTool); ok {
+		return prg.ToolSet[id], nil
+	}
+
+	if parsed == nil {
+		s, err := input(ctx, base, name, opts)
+		if err != nil {
+			return types.Tool{}, err
+		}
+		parsed = readSource(s, opts)
+	}
+
+	tool, err := linkSource(ctx, prg, parsed, subTool, opts)
 	if err != nil {
 		return types.Tool{}, err
 	}
 
-	return readTool(ctx, prg, s, subTool)
+	opts.sources.addResolved(parsed, subTool, tool.ID)
+	return tool, nil
 }
 
-func input(ctx context.Context, base *source, name string) (*source, error) {
-	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+// reused returns the ID of the tool a reference to a local file resolved to when the program was last loaded,
+// if the file did not change since.
+func (o Options) reused(base *source, name, subTool string) (string, bool) {
+	if len(o.reuse) == 0 || base.Remote || isGRPC(name) || isOCI(name) || isObjectStore(name) || strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return "", false
+	}
+	id, ok := o.reuse[resolvedKey(filepath.Join(base.Path, name), subTool)]
+	return id, ok
+}
+
+func input(ctx context.Context, base *source, name string, opts Options) (*source, error) {
+	name, pin := splitDigestPin(name)
+
+	if isGRPC(name) {
+		s, err := loadGRPC(ctx, base, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		return verifyGRPC(ctx, s, pin, opts)
+	}
+
+	if isOCI(name) {
+		if opts.vendor.isOffline() {
+			return opts.vendor.load(base, name)
+		}
+		s, err := loadOCI(ctx, name, pin, opts)
+		if err != nil {
+			return nil, err
+		}
+		return opts.vendor.add(base, name, s)
+	}
+
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") || isObjectStore(name) {
 		base.Remote = true
 	}
 
 	if !base.Remote {
 		s, ok, err := loadLocal(base, name)
-		if err != nil || ok {
-			return s, err
+		if err != nil {
+			return nil, err
+		} else if ok && pin != "" {
+			return verifySource(ctx, s, pin, Options{})
+		} else if ok {
+			return s, nil
+		}
+	}
+
+	if opts.vendor.isOffline() {
+		s, err := opts.vendor.load(base, name)
+		if err != nil {
+			return nil, err
 		}
+		// Vendored sources were verified when they were vendored, only their digest pin is checked again
+		return verifySource(ctx, s, pin, Options{})
 	}
 
-	s, ok, err := loadURL(ctx, base, name)
-	if err != nil || ok {
-		return s, err
+	s, ok, err := loadURL(ctx, base, name, opts)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	} else if isIntegrityError(err) {
+		return nil, err
+	} else if err != nil {
+		return nil, &unavailableError{location: name, err: err}
+	} else if ok {
+		if s, err = opts.locks.checkContent(s); err != nil {
+			return nil, err
+		}
+		if s, err = verifySource(ctx, s, pin, opts); err != nil {
+			return nil, err
+		}
+		if err := verifyRevision(s, name, opts); err != nil {
+			return nil, err
+		}
+		return opts.vendor.add(base, name, s)
 	}
 
 	return nil, fmt.Errorf("can not load tools path=%s name=%s", base.Path, name)
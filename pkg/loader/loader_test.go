@@ -2,11 +2,34 @@ package loader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/hexops/autogold/v2"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 func toString(obj any) string {
@@ -97,3 +120,923 @@ func TestHelloWorld(t *testing.T) {
   }
 }`).Equal(t, toString(prg))
 }
+
+func TestStubUnavailable(t *testing.T) {
+	content := `tools: http://127.0.0.1:1/missing.gpt
+
+call missing`
+
+	_, err := ProgramFromSource(context.Background(), content, "")
+	require.Error(t, err)
+
+	prg, err := ProgramFromSource(context.Background(), content, "", Options{
+		StubUnavailable: true,
+	})
+	require.NoError(t, err)
+
+	stub := prg.ToolSet[prg.ToolSet[prg.EntryToolID].ToolMapping["http://127.0.0.1:1/missing.gpt"]]
+	require.True(t, stub.IsUnavailable())
+	require.Equal(t, "http://127.0.0.1:1/missing.gpt", stub.Source.Location)
+}
+
+func TestOpenAPICache(t *testing.T) {
+	c, err := cache.New(cache.Options{
+		CacheDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	content := `{
+  "openapi": "3.0.0",
+  "info": {"title": "Pets", "version": "1.0.0"},
+  "servers": [{"url": "https://pets.example.com"}],
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "responses": {"200": {"description": "OK"}}}
+    }
+  }
+}`
+
+	prg, err := ProgramFromSource(context.Background(), content, "", Options{Cache: c})
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(c.CacheDir())
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	cached, err := ProgramFromSource(context.Background(), content, "", Options{Cache: c})
+	require.NoError(t, err)
+	require.Equal(t, toString(prg), toString(cached))
+	require.Equal(t, []string{"listPets"}, cached.ToolSet[cached.EntryToolID].Export)
+}
+
+type progressRecorder []Progress
+
+func (p *progressRecorder) LoadProgress(progress Progress) {
+	*p = append(*p, progress)
+}
+
+func TestOpenAPIToolIDs(t *testing.T) {
+	content := `{
+  "openapi": "3.0.0",
+  "info": {"title": "Pets", "version": "1.0.0"},
+  "servers": [{"url": "https://pets.example.com"}],
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "responses": {"200": {"description": "OK"}}},
+      "delete": {"operationId": "deletePets", "responses": {"200": {"description": "OK"}}}
+    }
+  }
+}`
+
+	prg, err := ProgramFromSource(context.Background(), content, "")
+	require.NoError(t, err)
+
+	mapping := prg.ToolSet[prg.EntryToolID].ToolMapping
+	require.Equal(t, "inline:listPets", mapping["listPets"])
+	require.Equal(t, "inline:deletePets", mapping["deletePets"])
+	require.Equal(t, "delete-"+hash.ID("DELETE", "/pets")[:16], operationKey("delete", "/pets", &openapi3.Operation{}))
+
+	// IDs made of the operation number are not resolved
+	tool, err := prg.GetToolByID("inline:listPets")
+	require.NoError(t, err)
+	require.Equal(t, "listPets", tool.Name)
+	_, err = prg.GetToolByID("inline:2")
+	require.ErrorContains(t, err, "is the operation number of an API definition")
+	_, err = prg.GetToolByID("inline:missing")
+	require.ErrorContains(t, err, "failed to find tool for id [inline:missing]")
+
+	prg, err = ProgramFromSource(context.Background(), content, "", Options{OpenAPIToolIDs: OpenAPIToolIDsIndex})
+	require.NoError(t, err)
+	require.Equal(t, "inline:2", prg.ToolSet[prg.EntryToolID].ToolMapping["listPets"])
+
+	_, err = ProgramFromSource(context.Background(), content, "", Options{OpenAPIToolIDs: "line"})
+	require.ErrorContains(t, err, "invalid OpenAPI tool ID scheme")
+}
+
+func TestToolsFromOpenAPI(t *testing.T) {
+	tools, err := ToolsFromOpenAPI([]byte(`openapi: 3.0.0
+info:
+  title: Pets
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      responses:
+        "200":
+          description: OK
+`), OpenAPIOptions{
+		Location:    "pets.yaml",
+		DefaultHost: "https://pets.example.com/openapi.yaml",
+	})
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	require.Equal(t, []string{"listPets"}, tools[0].Export)
+	require.Equal(t, "pets.yaml:listPets", tools[1].ID)
+
+	inst, err := engine.GetOpenAPIInstructions(tools[1])
+	require.NoError(t, err)
+	require.Equal(t, "https://pets.example.com/", inst.Server)
+	require.Equal(t, "/pets", inst.Path)
+	require.Equal(t, "GET", inst.Method)
+
+	_, err = ToolsFromOpenAPI([]byte("say hi"))
+	require.Error(t, err)
+}
+
+func TestProgressAndCancel(t *testing.T) {
+	content := `tools: http://127.0.0.1:1/missing.gpt
+
+call missing`
+
+	var progress progressRecorder
+	_, err := ProgramFromSource(context.Background(), content, "", Options{
+		StubUnavailable: true,
+		Monitor:         &progress,
+	})
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	require.Equal(t, ProgressParsing, progress[0].Type)
+	require.Equal(t, "inline", progress[0].Location)
+	require.Equal(t, ProgressFetching, progress[1].Type)
+	require.Equal(t, "http://127.0.0.1:1/missing.gpt", progress[1].Location)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = ProgramFromSource(ctx, content, "", Options{
+		StubUnavailable: true,
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConcurrentFetch(t *testing.T) {
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// Both files must be requested at the same time for either to be returned
+		arrived.Done()
+		done := make(chan struct{})
+		go func() {
+			arrived.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			rw.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		_, _ = rw.Write([]byte("name: " + strings.TrimSuffix(path.Base(req.URL.Path), ".gpt") + "\n\nSay hi"))
+	}))
+	defer s.Close()
+
+	prg, err := ProgramFromSource(context.Background(), fmt.Sprintf(`tools: %[1]s/one.gpt, %[1]s/two.gpt
+
+call them`, s.URL), "")
+	require.NoError(t, err)
+
+	entry := prg.ToolSet[prg.EntryToolID]
+	require.Equal(t, "one", prg.ToolSet[entry.ToolMapping[s.URL+"/one.gpt"]].Name)
+	require.Equal(t, "two", prg.ToolSet[entry.ToolMapping[s.URL+"/two.gpt"]].Name)
+}
+
+func TestGRPCProto(t *testing.T) {
+	// Tool references are lowercased by the parser, so the proto file can't be in t.TempDir()
+	dir, err := os.MkdirTemp("", "grpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "person.proto"), []byte(`syntax = "proto3";
+
+package people;
+
+import "google/protobuf/timestamp.proto";
+
+message Person {
+  string name = 1;
+  google.protobuf.Timestamp born = 2;
+}
+`), 0644))
+
+	protoFile := filepath.Join(dir, "greeter.proto")
+	require.NoError(t, os.WriteFile(protoFile, []byte(`syntax = "proto3";
+
+package helloworld;
+
+import "person.proto";
+
+option go_package = "example.com/helloworld";
+
+// The greeting service definition.
+service Greeter {
+  // Sends a greeting
+  rpc SayHello (HelloRequest) returns (HelloReply) {}
+  rpc SayHellos (HelloRequest) returns (stream HelloReply);
+}
+
+message HelloRequest {
+  message Options {
+    bool formal = 1;
+  }
+
+  string name = 1;
+  Options options = 2;
+  repeated people.Person friends = 3;
+}
+
+message HelloReply {
+  string message = 1;
+}
+`), 0644))
+
+	prg, err := ProgramFromSource(context.Background(), "tools: grpc://localhost:50051?proto="+protoFile+"\n\ncall it", "")
+	require.NoError(t, err)
+
+	entry := prg.ToolSet[prg.EntryToolID]
+	exports := prg.ToolSet[entry.ToolMapping["grpc://localhost:50051?proto="+protoFile]]
+	require.Equal(t, []string{"SayHello", "SayHellos"}, exports.Export)
+
+	sayHello := prg.ToolSet[exports.ToolMapping["SayHello"]]
+	require.Equal(t, "Sends a greeting", sayHello.Description)
+	require.Equal(t, `The helloworld.HelloRequest request message encoded as JSON, the message is defined as:
+message HelloRequest {
+  string name = 1;
+  helloworld.HelloRequest.Options options = 2;
+  repeated people.Person friends = 3;
+  message Options {
+    bool formal = 1;
+  }
+}
+
+message Person {
+  string name = 1;
+  google.protobuf.Timestamp born = 2;
+}`, sayHello.Arguments.Properties["request"].Value.Description)
+
+	inst, err := engine.GetGRPCInstructions(sayHello)
+	require.NoError(t, err)
+	require.Equal(t, engine.GRPCInstructions{
+		Server:    "localhost:50051",
+		Plaintext: true,
+		Method:    "helloworld.Greeter/SayHello",
+		ProtoFile: protoFile,
+	}, inst)
+
+	sayHellos := prg.ToolSet[exports.ToolMapping["SayHellos"]]
+	require.Equal(t, "Calls the SayHellos method of the helloworld.Greeter gRPC service (streaming)", sayHellos.Description)
+}
+
+func TestGRPCReflection(t *testing.T) {
+	files, err := compileProto("/greeter.proto", []byte(`syntax = "proto3";
+
+package helloworld;
+
+service Greeter {
+  rpc SayHello (HelloRequest) returns (HelloReply);
+}
+
+message HelloRequest {
+  oneof to {
+    string name = 1;
+    Group group = 2;
+  }
+}
+
+enum Group {
+  GROUP_UNSPECIFIED = 0;
+  GROUP_FRIENDS = 1;
+}
+
+message HelloReply {
+  string message = 1;
+}
+`))
+	require.NoError(t, err)
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(grpc_reflection_v1alpha.File_grpc_reflection_v1alpha_reflection_proto),
+			protodesc.ToFileDescriptorProto(files[0]),
+		},
+	})
+	require.NoError(t, err)
+
+	tools, err := getGRPCTools(data, "grpcs://api.example.com:443")
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	require.Equal(t, []string{"SayHello"}, tools[0].Export)
+	require.Equal(t, "Calls the SayHello method of the helloworld.Greeter gRPC service", tools[1].Description)
+	require.Equal(t, `The helloworld.HelloRequest request message encoded as JSON, the message is defined as:
+message HelloRequest {
+  oneof to {
+    string name = 1;
+    helloworld.Group group = 2;
+  }
+}
+
+enum Group {
+  GROUP_UNSPECIFIED = 0;
+  GROUP_FRIENDS = 1;
+}`, tools[1].Arguments.Properties["request"].Value.Description)
+
+	inst, err := engine.GetGRPCInstructions(tools[1])
+	require.NoError(t, err)
+	require.Equal(t, engine.GRPCInstructions{
+		Server: "api.example.com:443",
+		Method: "helloworld.Greeter/SayHello",
+	}, inst)
+
+	_, err = getGRPCTools([]byte("service Greeter {}"), "grpcs://api.example.com:443")
+	require.ErrorContains(t, err, "invalid file descriptor set")
+}
+
+func TestObjectStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake aws command is a shell script")
+	}
+
+	c, err := cache.New(cache.Options{
+		CacheDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	// A fake aws command that serves the objects in its directory and logs how it was called
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "aws"), []byte(`#!/bin/sh
+echo "$@" >> "$(dirname "$0")/calls"
+case "$1" in
+s3api) echo '"etag"' ;;
+s3) cat "$(dirname "$0")/$(basename "$3")" ;;
+esac
+`), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "tool.gpt"), []byte("tools: ./sub.gpt\n\ncall sub"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "sub.gpt"), []byte("say hi"), 0644))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	prg, err := Program(context.Background(), "s3://bucket/dir/tool.gpt", "", Options{Cache: c})
+	require.NoError(t, err)
+
+	entry := prg.ToolSet[prg.EntryToolID]
+	require.Equal(t, "s3://bucket/dir/tool.gpt", entry.Source.Location)
+	sub := prg.ToolSet[entry.ToolMapping["./sub.gpt"]]
+	require.Equal(t, "s3://bucket/dir/sub.gpt", sub.Source.Location)
+	require.Equal(t, "say hi", sub.Instructions)
+
+	// The second time, the objects are not downloaded because their ETags did not change
+	_, err = Program(context.Background(), "s3://bucket/dir/tool.gpt", "", Options{Cache: c})
+	require.NoError(t, err)
+
+	calls, err := os.ReadFile(filepath.Join(bin, "calls"))
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(calls), "s3 cp"))
+	require.Equal(t, 4, strings.Count(string(calls), "s3api head-object"))
+}
+
+func TestObjectStoreAzure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake az command is a shell script")
+	}
+
+	// A fake az command that downloads the blobs in its directory to the file it is given
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "az"), []byte(`#!/bin/sh
+dir="$(dirname "$0")"
+echo "$@" >> "$dir/calls"
+while [ $# -gt 0 ]; do
+  case "$1" in
+  --name) name="$2" ;;
+  --file) file="$2" ;;
+  esac
+  shift
+done
+cp "$dir/$(basename "$name")" "$file"
+`), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "tool.gpt"), []byte("tools: ./sub.gpt\n\ncall sub"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "sub.gpt"), []byte("say hi"), 0644))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	prg, err := Program(context.Background(), "az://account/container/dir/tool.gpt", "")
+	require.NoError(t, err)
+
+	entry := prg.ToolSet[prg.EntryToolID]
+	sub := prg.ToolSet[entry.ToolMapping["./sub.gpt"]]
+	require.Equal(t, "az://account/container/dir/sub.gpt", sub.Source.Location)
+	require.Equal(t, "say hi", sub.Instructions)
+
+	calls, err := os.ReadFile(filepath.Join(bin, "calls"))
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(calls), "storage blob download"))
+	require.NotContains(t, string(calls), "/dev/stdout")
+}
+
+func TestOCI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake oras command is a shell script")
+	}
+
+	c, err := cache.New(cache.Options{
+		CacheDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	// A fake oras command that resolves every reference to the same digest and pulls a tool set with two files
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "oras"), []byte(`#!/bin/sh
+echo "$@" >> "$(dirname "$0")/calls"
+case "$1" in
+resolve) echo sha256:0123456789abcdef ;;
+pull)
+	printf 'tools: ./sub.gpt\n\ncall sub' > "$3/tool.gpt"
+	printf 'say hi' > "$3/sub.gpt"
+	;;
+esac
+`), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	content := "tools: oci://registry.example.com/tools:v1\n\ncall tools"
+	for i := 0; i < 2; i++ {
+		prg, err := ProgramFromSource(context.Background(), content, "", Options{Cache: c})
+		require.NoError(t, err)
+
+		tool := prg.ToolSet[prg.ToolSet[prg.EntryToolID].ToolMapping["oci://registry.example.com/tools:v1"]]
+		require.Equal(t, filepath.Join(c.CacheDir(), "oci", "sha256-0123456789abcdef"), tool.WorkingDir)
+		require.Equal(t, "say hi", prg.ToolSet[tool.ToolMapping["./sub.gpt"]].Instructions)
+	}
+
+	// The artifact is pulled by digest, and only once
+	calls, err := os.ReadFile(filepath.Join(bin, "calls"))
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(calls), "pull --output"))
+	require.Contains(t, string(calls), "registry.example.com/tools@sha256:0123456789abcdef")
+}
+
+func TestAsyncAPI(t *testing.T) {
+	v2 := []byte(`asyncapi: 2.6.0
+info:
+  title: Accounts
+servers:
+  production:
+    url: mqtt://broker.example.com:1883
+    protocol: mqtt
+channels:
+  user/signedup:
+    publish:
+      operationId: signUp
+      summary: Sign up a user
+      message:
+        payload:
+          type: object
+    subscribe:
+      summary: Users that signed up
+`)
+	require.True(t, isAsyncAPI(v2))
+
+	tools, err := getAsyncAPITools(v2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"signUp", "consume_user_signedup"}, tools[0].Export)
+
+	inst, err := engine.GetAsyncAPIInstructions(tools[1])
+	require.NoError(t, err)
+	require.Equal(t, engine.AsyncAPIInstructions{
+		Protocol: "mqtt",
+		Server:   "broker.example.com:1883",
+		Channel:  "user/signedup",
+		Action:   engine.AsyncAPIActionPublish,
+	}, inst)
+	require.Contains(t, tools[1].Arguments.Properties["message"].Value.Description, `{"type":"object"}`)
+
+	v3 := []byte(`{
+  "asyncapi": "3.0.0",
+  "info": {"title": "Orders"},
+  "servers": {"kafka": {"host": "kafka.example.com:9092", "protocol": "kafka"}},
+  "channels": {"orders": {"address": "orders.created"}},
+  "operations": {
+    "onOrderCreated": {"action": "send", "channel": {"$ref": "#/channels/orders"}},
+    "createOrder": {"action": "receive", "channel": {"$ref": "#/channels/orders"}}
+  }
+}`)
+	require.True(t, isAsyncAPI(v3))
+	require.False(t, isOpenAPI(v3))
+
+	tools, err = getAsyncAPITools(v3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"createOrder", "onOrderCreated"}, tools[0].Export)
+
+	inst, err = engine.GetAsyncAPIInstructions(tools[2])
+	require.NoError(t, err)
+	require.Equal(t, engine.AsyncAPIInstructions{
+		Protocol: "kafka",
+		Server:   "kafka.example.com:9092",
+		Channel:  "orders.created",
+		Action:   engine.AsyncAPIActionConsume,
+	}, inst)
+}
+
+func TestProgramCache(t *testing.T) {
+	c, err := cache.New(cache.Options{
+		CacheDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	entry, sub := filepath.Join(dir, "entry.gpt"), filepath.Join(dir, "sub.gpt")
+	require.NoError(t, os.WriteFile(entry, []byte("tools: ./sub.gpt\n\ncall sub"), 0644))
+	require.NoError(t, os.WriteFile(sub, []byte("say hi"), 0644))
+
+	prg, err := Program(context.Background(), entry, "", Options{Cache: c})
+	require.NoError(t, err)
+
+	cached, ok := getCachedProgram(entry, "", Options{Cache: c})
+	require.True(t, ok)
+	require.Equal(t, toString(prg), toString(cached.Program))
+
+	// Changing any of the files invalidates the cached program
+	require.NoError(t, os.WriteFile(sub, []byte("say bye"), 0644))
+	_, ok = getCachedProgram(entry, "", Options{Cache: c})
+	require.False(t, ok)
+
+	prg, err = Program(context.Background(), entry, "", Options{Cache: c})
+	require.NoError(t, err)
+	require.Equal(t, "say bye", prg.ToolSet[prg.ToolSet[prg.EntryToolID].ToolMapping["./sub.gpt"]].Instructions)
+}
+
+func TestLinker(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"entry.gpt": "tools: ./a.gpt, ./b.gpt\n\ncall a and b",
+		"a.gpt":     "say a",
+		"b.gpt":     "tools: ./c.gpt\n\ncall c",
+		"c.gpt":     "say c",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	var (
+		linker Linker
+		entry  = filepath.Join(dir, "entry.gpt")
+	)
+
+	prg, err := linker.Program(context.Background(), entry, "")
+	require.NoError(t, err)
+
+	// Nothing changed, so nothing is read
+	var progress progressRecorder
+	reloaded, err := linker.Program(context.Background(), entry, "", Options{Monitor: &progress})
+	require.NoError(t, err)
+	require.Empty(t, progress)
+	require.Equal(t, toString(prg), toString(reloaded))
+
+	// Only the changed file and the entry tool that references it are read again
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.gpt"), []byte("say a again"), 0644))
+	reloaded, err = linker.Program(context.Background(), entry, "", Options{Monitor: &progress})
+	require.NoError(t, err)
+	require.Len(t, progress, 2)
+	require.Equal(t, entry, progress[0].Location)
+	require.Equal(t, filepath.Join(dir, "a.gpt"), progress[1].Location)
+
+	full, err := Program(context.Background(), entry, "")
+	require.NoError(t, err)
+	require.Equal(t, toString(full), toString(reloaded))
+	require.Equal(t, prg.ToolSet[prg.ToolSet[prg.EntryToolID].ToolMapping["./b.gpt"]], reloaded.ToolSet[reloaded.ToolSet[reloaded.EntryToolID].ToolMapping["./b.gpt"]])
+
+	// Tools that are no longer referenced are dropped
+	require.NoError(t, os.WriteFile(entry, []byte("tools: ./a.gpt\n\ncall a"), 0644))
+	reloaded, err = linker.Program(context.Background(), entry, "")
+	require.NoError(t, err)
+	full, err = Program(context.Background(), entry, "")
+	require.NoError(t, err)
+	require.Equal(t, toString(full), toString(reloaded))
+	require.Len(t, reloaded.ToolSet, 2)
+}
+
+func TestLinkerWatch(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "entry.gpt")
+	require.NoError(t, os.WriteFile(entry, []byte("say one"), 0644))
+
+	var linker Linker
+	_, err := linker.Program(context.Background(), entry, "")
+	require.NoError(t, err)
+
+	type reload struct {
+		prg types.Program
+		err error
+	}
+	var (
+		reloads     = make(chan reload, 1)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	defer cancel()
+	go linker.Watch(ctx, 10*time.Millisecond, func(name string, prg types.Program, err error) {
+		require.Equal(t, entry, name)
+		reloads <- reload{prg: prg, err: err}
+	})
+	require.Eventually(t, linker.watching.Load, time.Second, time.Millisecond)
+
+	// The program is reloaded when its file changes, and used without checking the file again
+	require.NoError(t, os.WriteFile(entry, []byte("say two"), 0644))
+	r := <-reloads
+	require.NoError(t, r.err)
+	require.Equal(t, "say two", r.prg.ToolSet[r.prg.EntryToolID].Instructions)
+
+	prg, err := linker.Program(context.Background(), entry, "")
+	require.NoError(t, err)
+	require.Equal(t, "say two", prg.ToolSet[prg.EntryToolID].Instructions)
+
+	// A program that fails to reload fails the next time it is used
+	require.NoError(t, os.WriteFile(entry, []byte("tools: ./missing.gpt\n\ncall it"), 0644))
+	r = <-reloads
+	require.Error(t, r.err)
+	_, err = linker.Program(context.Background(), entry, "")
+	require.Error(t, err)
+}
+
+func TestLock(t *testing.T) {
+	var (
+		lock   sync.Mutex
+		latest = "rev1"
+		plain  = "say plain"
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/rev1/tool.gpt":
+			_, _ = w.Write([]byte("say one"))
+		case "/rev2/tool.gpt":
+			_, _ = w.Write([]byte("say two"))
+		case "/plain.gpt":
+			_, _ = w.Write([]byte(plain))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	// A repository whose references resolve to the latest revision unless a revision is given
+	lookups := vcsLookups
+	defer func() { vcsLookups = lookups }()
+	AddVSC(func(_ context.Context, name string) (string, *types.Repo, bool, error) {
+		ref, rev, ok := strings.Cut(name, "@")
+		if ref != "example.test/tool" {
+			return "", nil, false, nil
+		}
+		if !ok {
+			lock.Lock()
+			rev = latest
+			lock.Unlock()
+		}
+		return s.URL + "/" + rev + "/tool.gpt", &types.Repo{Revision: rev}, true, nil
+	})
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tool.gpt")
+	require.NoError(t, os.WriteFile(file, []byte("tools: example.test/tool, "+s.URL+"/plain.gpt\n\ncall them"), 0644))
+
+	instructions := func(prg types.Program) []string {
+		var result []string
+		for _, id := range prg.ToolSet[prg.EntryToolID].ToolMapping {
+			result = append(result, prg.ToolSet[id].Instructions)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	prg, err := Program(context.Background(), file, "", Options{Lock: LockInstall})
+	require.NoError(t, err)
+	require.Equal(t, []string{"say one", "say plain"}, instructions(prg))
+
+	data, err := os.ReadFile(filepath.Join(dir, LockFile))
+	require.NoError(t, err)
+	var locked Lock
+	require.NoError(t, json.Unmarshal(data, &locked))
+	require.Equal(t, "rev1", locked.Tools["example.test/tool"].Revision)
+	require.Len(t, locked.Tools[s.URL+"/plain.gpt"].SHA256, 64)
+
+	// The lock file is used without a mode, so the new revision is not loaded
+	lock.Lock()
+	latest = "rev2"
+	lock.Unlock()
+	prg, err = Program(context.Background(), file, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"say one", "say plain"}, instructions(prg))
+
+	// Changed content fails to load, even when unavailable tools are stubbed
+	lock.Lock()
+	plain = "say changed"
+	lock.Unlock()
+	_, err = Program(context.Background(), file, "", Options{StubUnavailable: true})
+	require.ErrorContains(t, err, "changed since it was locked")
+
+	prg, err = Program(context.Background(), file, "", Options{Lock: LockUpdate})
+	require.NoError(t, err)
+	require.Equal(t, []string{"say changed", "say two"}, instructions(prg))
+
+	prg, err = Program(context.Background(), file, "", Options{Lock: LockVerify})
+	require.NoError(t, err)
+	require.Equal(t, []string{"say changed", "say two"}, instructions(prg))
+
+	// New references have to be locked when verifying
+	require.NoError(t, os.WriteFile(file, []byte("tools: example.test/tool, "+s.URL+"/rev1/tool.gpt\n\ncall them"), 0644))
+	_, err = Program(context.Background(), file, "", Options{Lock: LockVerify})
+	require.ErrorContains(t, err, "is not locked")
+}
+
+func TestVerify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake cosign command is a shell script")
+	}
+
+	var (
+		lock   sync.Mutex
+		bundle = "good"
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/tool.gpt":
+			_, _ = w.Write([]byte("say hi"))
+		case "/tool.gpt.bundle":
+			_, _ = w.Write([]byte(bundle))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	// A fake cosign command that accepts the bundles with the content "good"
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "cosign"), []byte(`#!/bin/sh
+echo "$@" >> "$(dirname "$0")/calls"
+[ "$(cat "$3")" = good ]
+`), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	digest := sha256.Sum256([]byte("say hi"))
+	pinned := s.URL + "/tool.gpt#sha256:" + hex.EncodeToString(digest[:])
+	load := func(ref string, opts Options) (types.Program, error) {
+		return ProgramFromSource(context.Background(), "tools: "+ref+"\n\ncall it", "", opts)
+	}
+
+	prg, err := load(pinned, Options{RequireVerification: VerifyDigest})
+	require.NoError(t, err)
+	require.Equal(t, "say hi", prg.ToolSet[prg.ToolSet[prg.EntryToolID].ToolMapping[pinned]].Instructions)
+
+	_, err = load(s.URL+"/tool.gpt#sha256:"+strings.Repeat("0", 64), Options{StubUnavailable: true})
+	require.ErrorContains(t, err, "the digest of "+s.URL+"/tool.gpt is sha256:"+hex.EncodeToString(digest[:]))
+
+	_, err = load(s.URL+"/tool.gpt", Options{RequireVerification: VerifyDigest})
+	require.ErrorContains(t, err, "must be pinned to a digest")
+
+	_, err = load(s.URL+"/tool.gpt", Options{RequireVerification: VerifySignature})
+	require.ErrorContains(t, err, "--cosign-key or --cosign-identity must be set")
+
+	_, err = load(s.URL+"/tool.gpt", Options{RequireVerification: VerifySignature, CosignKey: "cosign.pub"})
+	require.NoError(t, err)
+
+	calls, err := os.ReadFile(filepath.Join(bin, "calls"))
+	require.NoError(t, err)
+	require.Contains(t, string(calls), "verify-blob --bundle ")
+	require.Contains(t, string(calls), " --key cosign.pub ")
+
+	lock.Lock()
+	bundle = "bad"
+	lock.Unlock()
+	_, err = load(s.URL+"/tool.gpt", Options{CosignKey: "cosign.pub", StubUnavailable: true})
+	require.ErrorContains(t, err, "failed to verify the signature of "+s.URL+"/tool.gpt")
+}
+
+func TestVerifyRevision(t *testing.T) {
+	s := &source{
+		Location: "https://raw.githubusercontent.com/org/tools/" + strings.Repeat("a", 40) + "/tool.gpt",
+		Repo: &types.Repo{
+			VCS:      "git",
+			Root:     "https://github.com/org/tools.git",
+			Revision: strings.Repeat("a", 40),
+		},
+	}
+
+	require.NoError(t, verifyRevision(s, "github.com/org/tools", Options{}))
+	err := verifyRevision(s, "github.com/org/tools@main", Options{RequireVerification: VerifyDigest})
+	require.ErrorContains(t, err, "must be pinned to a commit like github.com/org/tools@<commit>")
+
+	s.revisionPinned = true
+	require.NoError(t, verifyRevision(s, "github.com/org/tools@"+strings.Repeat("a", 40), Options{RequireVerification: VerifySignature}))
+
+	require.True(t, isCommit(strings.Repeat("0f", 20)))
+	require.False(t, isCommit("main"))
+	require.False(t, isCommit(strings.Repeat("0f", 4)))
+}
+
+func TestVerifyGRPC(t *testing.T) {
+	definition := "service Greeter {}"
+	digest := sha256.Sum256([]byte(definition))
+	newSource := func(location string) *source {
+		return &source{
+			Content:  io.NopCloser(strings.NewReader(definition)),
+			Location: location,
+		}
+	}
+
+	_, err := verifyGRPC(context.Background(), newSource("grpc://localhost:50051"), "", Options{RequireVerification: VerifyDigest})
+	require.ErrorContains(t, err, "must be pinned to the digest of its definition")
+
+	_, err = verifyGRPC(context.Background(), newSource("grpc://localhost:50051"), "sha256:"+hex.EncodeToString(digest[:]), Options{RequireVerification: VerifyDigest})
+	require.NoError(t, err)
+
+	_, err = verifyGRPC(context.Background(), newSource("grpc://localhost:50051"), "sha256:"+strings.Repeat("0", 64), Options{})
+	require.ErrorContains(t, err, "the digest of grpc://localhost:50051")
+
+	_, err = verifyGRPC(context.Background(), newSource("grpc://localhost:50051"), "sha256:"+hex.EncodeToString(digest[:]), Options{RequireVerification: VerifySignature, CosignKey: "cosign.pub"})
+	require.ErrorContains(t, err, "can not be signed")
+
+	// The definitions in local proto files are local files
+	_, err = verifyGRPC(context.Background(), newSource("grpc://localhost:50051?proto=%2Fapi.proto"), "", Options{RequireVerification: VerifySignature, CosignKey: "cosign.pub"})
+	require.NoError(t, err)
+}
+
+func TestVendor(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tools/main.gpt":
+			_, _ = w.Write([]byte("tools: helper.gpt\n\ncall helper"))
+		case "/tools/helper.gpt":
+			_, _ = w.Write([]byte("say helper"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "tool.gpt")
+	require.NoError(t, os.WriteFile(file, []byte("tools: "+s.URL+"/tools/main.gpt\n\ncall main"), 0644))
+
+	instructions := func(prg types.Program) []string {
+		var result []string
+		for _, tool := range prg.ToolSet {
+			result = append(result, tool.Instructions)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	_, err := Program(context.Background(), file, "", Options{Offline: true})
+	require.ErrorContains(t, err, "run gptscript vendor")
+
+	prg, err := Program(context.Background(), file, "", Options{Vendoring: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"call helper", "call main", "say helper"}, instructions(prg))
+
+	data, err := os.ReadFile(filepath.Join(dir, VendorDir, VendorIndex))
+	require.NoError(t, err)
+	var vendor Vendor
+	require.NoError(t, json.Unmarshal(data, &vendor))
+	require.Len(t, vendor.Sources, 2)
+	require.FileExists(t, filepath.Join(dir, VendorDir, vendor.Sources[s.URL+"/tools/helper.gpt"].File))
+
+	// Offline, the vendored tools are loaded without fetching them
+	s.Close()
+	offline, err := Program(context.Background(), file, "", Options{Offline: true})
+	require.NoError(t, err)
+	require.Equal(t, instructions(prg), instructions(offline))
+
+	// Tools that are not vendored fail to load instead of being fetched
+	require.NoError(t, os.WriteFile(file, []byte("tools: "+s.URL+"/tools/other.gpt\n\ncall other"), 0644))
+	_, err = Program(context.Background(), file, "", Options{Offline: true})
+	require.ErrorContains(t, err, "is not vendored in")
+}
+
+func TestSubTool(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"entry.gpt": "tools: parse from ./lib.gpt, ./other.gpt\n\ncall parse\n---\nname: local\n\nsay local",
+		"lib.gpt":   "name: lib\n\nsay lib\n---\nname: parse\n\nparse lib",
+		"other.gpt": "name: other\ntools: parse\n\ncall parse\n---\nname: parse\n\nparse other",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	entry := filepath.Join(dir, "entry.gpt")
+
+	prg, err := Program(context.Background(), entry, "local")
+	require.NoError(t, err)
+	require.Equal(t, "say local", prg.ToolSet[prg.EntryToolID].Instructions)
+
+	// Tools of the other files of the program can be targeted, qualified with their file when the name is ambiguous
+	_, err = Program(context.Background(), entry, "parse")
+	require.ErrorContains(t, err, `qualify the sub tool with its file, like "parse from`)
+
+	prg, err = Program(context.Background(), entry, "parse from lib.gpt")
+	require.NoError(t, err)
+	require.Equal(t, "parse lib", prg.ToolSet[prg.EntryToolID].Instructions)
+
+	prg, err = new(Linker).Program(context.Background(), "parse from ./other.gpt from "+entry, "")
+	require.NoError(t, err)
+	require.Equal(t, "parse other", prg.ToolSet[prg.EntryToolID].Instructions)
+
+	_, err = Program(context.Background(), entry, "missing")
+	require.ErrorContains(t, err, "tool not found: missing")
+}
@@ -0,0 +1,220 @@
+package loader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	LockFile = "gptscript.lock"
+
+	// LockInstall loads the locked versions of remote tools, and locks the ones that are not locked yet
+	LockInstall = "install"
+	// LockUpdate loads the latest versions of all remote tools and locks them
+	LockUpdate = "update"
+	// LockVerify loads the locked versions of remote tools, and fails if a remote tool is not locked
+	LockVerify = "verify"
+
+	lockVersion = 1
+)
+
+// Lock is the content of a lock file, which pins the remote tools a program references to the versions they
+// resolved to, so the program loads the same tools on every run.
+type Lock struct {
+	Version int `json:"version"`
+	// Tools are keyed by the reference to the remote tool, or by the URL of tools that are only locked by content
+	Tools map[string]LockedTool `json:"tools"`
+}
+
+type LockedTool struct {
+	// Revision is the commit a reference to a tool in a repository resolved to
+	Revision string `json:"revision,omitempty"`
+	// Digest is the digest of the artifact a reference to a tool set in an OCI registry resolved to
+	Digest string `json:"digest,omitempty"`
+	// SHA256 is the digest of the content of a tool loaded from a URL that has no versions
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// integrityError is returned when a remote tool does not match the lock file or fails verification. It is never
+// stubbed, since the tool may well be available.
+type integrityError struct {
+	msg string
+}
+
+func (e *integrityError) Error() string {
+	return e.msg
+}
+
+func isIntegrityError(err error) bool {
+	var integrityErr *integrityError
+	return errors.As(err, &integrityErr)
+}
+
+// lockState is the lock file of the program being loaded and the versions its remote tools resolved to.
+type lockState struct {
+	lock     sync.Mutex
+	mode     string
+	file     string
+	exists   bool
+	locked   map[string]LockedTool
+	resolved map[string]LockedTool
+}
+
+// lockFilePath returns the path of the lock file of a program, which is next to the program if it is local, and in
+// the current directory otherwise.
+func lockFilePath(name string) string {
+	if s, err := os.Stat(name); err == nil {
+		if s.IsDir() {
+			return filepath.Join(name, LockFile)
+		}
+		return filepath.Join(filepath.Dir(name), LockFile)
+	}
+	return LockFile
+}
+
+// readLock reads the lock file of the program. Nil is returned if no mode is set and the program has no lock file,
+// in which case remote tools are not locked.
+func readLock(name, mode string) (*lockState, error) {
+	switch mode {
+	case "", LockInstall, LockUpdate, LockVerify:
+	default:
+		return nil, fmt.Errorf("invalid lock mode %q, must be %s, %s, or %s", mode, LockInstall, LockUpdate, LockVerify)
+	}
+
+	l := &lockState{
+		mode:     mode,
+		file:     lockFilePath(name),
+		locked:   map[string]LockedTool{},
+		resolved: map[string]LockedTool{},
+	}
+
+	data, err := os.ReadFile(l.file)
+	if errors.Is(err, os.ErrNotExist) {
+		if mode == "" {
+			return nil, nil
+		} else if mode == LockVerify {
+			return nil, fmt.Errorf("lock file %s does not exist", l.file)
+		}
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", l.file, err)
+	}
+	if lock.Version != lockVersion {
+		return nil, fmt.Errorf("unsupported version %d of lock file %s", lock.Version, l.file)
+	}
+	if lock.Tools != nil {
+		l.locked = lock.Tools
+	}
+	l.exists = true
+	return l, nil
+}
+
+// get returns the locked version of the remote tool. Locked versions are ignored when updating the lock file.
+func (l *lockState) get(key string) (LockedTool, bool, error) {
+	if l == nil || l.mode == LockUpdate {
+		return LockedTool{}, false, nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	tool, ok := l.locked[key]
+	if !ok && l.mode == LockVerify {
+		return LockedTool{}, false, &integrityError{msg: fmt.Sprintf("%s is not locked in %s, run with --lock=%s to lock it", key, l.file, LockInstall)}
+	}
+	return tool, ok, nil
+}
+
+func (l *lockState) add(key string, tool LockedTool) {
+	if l == nil {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.resolved[key] = tool
+}
+
+// pinRevision returns the reference to a tool in a repository, pinned to the locked revision if it is locked.
+func (l *lockState) pinRevision(name string) (string, error) {
+	tool, ok, err := l.get(name)
+	if err != nil || !ok || tool.Revision == "" {
+		return name, err
+	}
+	ref, _, _ := strings.Cut(name, "@")
+	return ref + "@" + tool.Revision, nil
+}
+
+// checkContent locks tools loaded from URLs that have no versions by the digest of their content, and fails if
+// the content changed since it was locked.
+func (l *lockState) checkContent(s *source) (*source, error) {
+	if l == nil || s.Repo != nil {
+		return s, nil
+	}
+
+	tool, ok, err := l.get(s.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(s.Content)
+	_ = s.Content.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	sum := hex.EncodeToString(digest[:])
+	if ok && tool.SHA256 != sum {
+		return nil, &integrityError{msg: fmt.Sprintf("the content of %s changed since it was locked in %s, run with --lock=%s to lock the new content", s.Location, l.file, LockUpdate)}
+	}
+	l.add(s.Location, LockedTool{SHA256: sum})
+
+	s.Content = io.NopCloser(bytes.NewReader(data))
+	return s, nil
+}
+
+// save writes the versions that remote tools resolved to into the lock file when installing or updating. When
+// installing, the tools that are already locked are kept. When updating, tools that are no longer referenced
+// are dropped.
+func (l *lockState) save() error {
+	if l == nil || (l.mode != LockInstall && l.mode != LockUpdate) {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	tools := l.resolved
+	if l.mode == LockInstall {
+		tools = maps.Clone(l.locked)
+		maps.Copy(tools, l.resolved)
+		if l.exists && maps.Equal(tools, l.locked) {
+			return nil
+		}
+	}
+
+	data, err := json.MarshalIndent(Lock{
+		Version: lockVersion,
+		Tools:   tools,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.file, append(data, '\n'), 0644)
+}
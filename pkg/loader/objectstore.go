@@ -0,0 +1,167 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	url2 "net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+)
+
+// objectStoreSchemes are the URL schemes of the object stores tools can be loaded from: s3://bucket/key for
+// Amazon S3, gs://bucket/object for Google Cloud Storage, and az://account/container/blob for Azure Blob Storage.
+var objectStoreSchemes = []string{"s3://", "gs://", "az://"}
+
+func isObjectStore(name string) bool {
+	for _, scheme := range objectStoreSchemes {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectRef is a reference to an object in a bucket, or in a container of an account for Azure
+type objectRef struct {
+	scheme, bucket, container, key string
+}
+
+func parseObjectRef(url string) (objectRef, error) {
+	u, err := url2.Parse(url)
+	if err != nil {
+		return objectRef{}, err
+	}
+
+	ref := objectRef{
+		scheme: u.Scheme,
+		bucket: u.Host,
+		key:    strings.TrimPrefix(path.Clean("/"+u.Path), "/"),
+	}
+	if ref.scheme == "az" {
+		ref.container, ref.key, _ = strings.Cut(ref.key, "/")
+	}
+	if ref.bucket == "" || ref.key == "" || (ref.scheme == "az" && ref.container == "") {
+		return objectRef{}, fmt.Errorf("invalid object reference %s", url)
+	}
+	return ref, nil
+}
+
+func (o objectRef) String() string {
+	if o.scheme == "az" {
+		return fmt.Sprintf("az://%s/%s/%s", o.bucket, o.container, o.key)
+	}
+	return fmt.Sprintf("%s://%s/%s", o.scheme, o.bucket, o.key)
+}
+
+// versionArgs returns the command that prints the ETag of the object
+func (o objectRef) versionArgs() []string {
+	switch o.scheme {
+	case "s3":
+		return []string{"aws", "s3api", "head-object", "--bucket", o.bucket, "--key", o.key, "--query", "ETag", "--output", "text"}
+	case "gs":
+		return []string{"gcloud", "storage", "objects", "describe", o.String(), "--format=value(etag)"}
+	default:
+		return []string{"az", "storage", "blob", "show", "--auth-mode", "login", "--account-name", o.bucket,
+			"--container-name", o.container, "--name", o.key, "--query", "etag", "--output", "tsv"}
+	}
+}
+
+// readArgs returns the command that prints the content of the object, or for Azure, which can only download blobs
+// to files, the command that writes it to file
+func (o objectRef) readArgs(file string) []string {
+	switch o.scheme {
+	case "s3":
+		return []string{"aws", "s3", "cp", o.String(), "-"}
+	case "gs":
+		return []string{"gcloud", "storage", "cat", o.String()}
+	default:
+		return []string{"az", "storage", "blob", "download", "--auth-mode", "login", "--account-name", o.bucket,
+			"--container-name", o.container, "--name", o.key, "--file", file, "--no-progress", "--output", "none"}
+	}
+}
+
+// readObject returns the content of the object. Azure blobs are downloaded to a temporary file, instead of
+// /dev/stdout, which does not exist on Windows.
+func readObject(ctx context.Context, ref objectRef) ([]byte, error) {
+	if ref.scheme != "az" {
+		return runObjectCommand(ctx, ref.readArgs(""))
+	}
+
+	dir, err := os.MkdirTemp("", "gptscript-object-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "blob")
+	if _, err := runObjectCommand(ctx, ref.readArgs(file)); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(file)
+}
+
+// loadObject loads a tool from an object store using the command line client of the store, so the credentials
+// configured for the client are used and private buckets work like public ones. When caching is enabled, the
+// content is cached by the ETag of the object and only downloaded again once the object changed.
+func loadObject(ctx context.Context, url string, opts Options) (*source, bool, error) {
+	ref, err := parseObjectRef(url)
+	if err != nil {
+		return nil, false, err
+	}
+	location := ref.String()
+
+	opts.progress(ProgressFetching, location)
+
+	var key string
+	if opts.Cache != nil {
+		etag, err := runObjectCommand(ctx, ref.versionArgs())
+		if err != nil {
+			return nil, false, err
+		}
+		key = "object-" + hash.ID(location, strings.TrimSpace(string(etag)))
+	}
+
+	content, ok, err := opts.Cache.Get(key)
+	if err != nil {
+		log.Debugf("failed to read cached object %s: %v", location, err)
+	}
+	if !ok {
+		content, err = readObject(ctx, ref)
+		if err != nil {
+			return nil, false, err
+		}
+		if key != "" {
+			if err := opts.Cache.Store(key, content); err != nil {
+				log.Debugf("failed to cache object %s: %v", location, err)
+			}
+		}
+	}
+
+	log.Debugf("opened %s", location)
+
+	return &source{
+		Content:  io.NopCloser(bytes.NewReader(content)),
+		Remote:   true,
+		Path:     location[:strings.LastIndex(location, "/")],
+		Name:     path.Base(ref.key),
+		Location: location,
+	}, true, nil
+}
+
+func runObjectCommand(ctx context.Context, args []string) ([]byte, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s %s: %w: %s", args[0], args[1], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,152 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	OCIPrefix = "oci://"
+	// OCIArtifactType is the artifact type of tool sets pushed to OCI registries
+	OCIArtifactType = "application/vnd.gptscript.tools.v1"
+)
+
+func isOCI(name string) bool {
+	return strings.HasPrefix(name, OCIPrefix)
+}
+
+// loadOCI loads the tool.gpt file of a tool set that was pushed to an OCI registry and is referenced as
+// oci://registry/repository:tag, or oci://registry/repository@digest. The artifact is pulled using the oras command
+// line client, so the credentials configured for the registry with oras or docker are used. Artifacts are stored by
+// digest in the cache directory and only pulled again when the reference points to a new digest. References locked in
+// the lock file of the program, or pinned to a digest, are not resolved, that digest is pulled instead.
+func loadOCI(ctx context.Context, name, pin string, opts Options) (*source, error) {
+	ref := strings.TrimPrefix(name, OCIPrefix)
+
+	opts.progress(ProgressFetching, name)
+
+	locked, _, err := opts.locks.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := types.FirstSet(pin, locked.Digest)
+	if digest == "" {
+		out, err := runOras(ctx, "", "resolve", ref)
+		if err != nil {
+			return nil, &unavailableError{location: name, err: err}
+		}
+		digest = strings.TrimSpace(string(out))
+	}
+	if _, hex, ok := strings.Cut(digest, ":"); !ok || hex == "" {
+		return nil, fmt.Errorf("invalid digest %q for %s", digest, name)
+	}
+
+	if err := verifyOCI(ctx, name, ociRepository(ref), digest, pin, opts); err != nil {
+		return nil, err
+	}
+
+	cacheDir := cache.Complete().CacheDir
+	if opts.Cache != nil {
+		cacheDir = opts.Cache.CacheDir()
+	}
+	dir := filepath.Join(cacheDir, "oci", strings.ReplaceAll(digest, ":", "-"))
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := pullOCI(ctx, ref, digest, dir); err != nil {
+			return nil, &unavailableError{location: name, err: err}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	opts.locks.add(name, LockedTool{Digest: digest})
+
+	// The tag can be moved to a different artifact at any time, so programs using it are not cached
+	opts.sources.setRemote()
+
+	s, ok, err := loadLocal(&source{Path: dir}, "tool.gpt")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("no tool.gpt found in %s", name)
+	}
+	return s, nil
+}
+
+func pullOCI(ctx context.Context, ref, digest, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "pull-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// Pull by digest, in case the tag was moved since it was resolved
+	if _, err := runOras(ctx, "", "pull", "--output", tmp, ociRepository(ref)+"@"+digest); err != nil {
+		return err
+	}
+
+	// Rename the complete artifact into place, so a partially pulled artifact is never used
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another process may have pulled the same artifact at the same time
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ociRepository returns the repository of a reference without its tag or digest
+func ociRepository(ref string) string {
+	repository, _, _ := strings.Cut(ref, "@")
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return repository
+}
+
+// PushOCI pushes the tool set in dir, which must contain a tool.gpt file, to an OCI registry as an artifact that can be
+// referenced as ref, like oci://registry/repository:tag. All the files in dir are pushed, so tools can include their
+// code and other assets. It returns the digest of the pushed artifact.
+func PushOCI(ctx context.Context, dir, ref string) (string, error) {
+	if !isOCI(ref) {
+		return "", fmt.Errorf("invalid reference %s, must start with %s", ref, OCIPrefix)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "tool.gpt")); err != nil {
+		return "", fmt.Errorf("no tool.gpt found in %s: %w", dir, err)
+	}
+
+	if _, err := runOras(ctx, dir, "push", "--artifact-type", OCIArtifactType, strings.TrimPrefix(ref, OCIPrefix), "."); err != nil {
+		return "", err
+	}
+
+	out, err := runOras(ctx, "", "resolve", strings.TrimPrefix(ref, OCIPrefix))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func runOras(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "oras", args...)
+	cmd.Dir = dir
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run oras %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -6,12 +6,136 @@ import (
 	"net/url"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/version"
 )
 
+const (
+	// OpenAPIToolIDsOperation identifies the tools generated from OpenAPI operations by their operationId, or by
+	// the method and path of operations without one.
+	OpenAPIToolIDsOperation = "operation"
+	// OpenAPIToolIDsIndex identifies the tools generated from OpenAPI operations by their position in the definition,
+	// as older versions did.
+	OpenAPIToolIDsIndex = "index"
+)
+
+// OpenAPIOptions are the options for converting an OpenAPI definition to tools with ToolsFromOpenAPI
+type OpenAPIOptions struct {
+	// Location is where the definition was loaded from. It is the location of the source of the tools, and the
+	// first part of their IDs.
+	Location string
+	// DefaultHost is the URL of the server to use if the definition does not list any
+	DefaultHost string
+	// ToolIDs is how the IDs of the tools are derived, one of the OpenAPIToolIDs constants, OpenAPIToolIDsOperation
+	// by default
+	ToolIDs string
+	// Cache is used to reuse the tools generated for the same definition before, if set
+	Cache *cache.Client
+}
+
+func completeOpenAPI(opts ...OpenAPIOptions) (result OpenAPIOptions) {
+	for _, opt := range opts {
+		result.Location = types.FirstSet(opt.Location, result.Location)
+		result.DefaultHost = types.FirstSet(opt.DefaultHost, result.DefaultHost)
+		result.ToolIDs = types.FirstSet(opt.ToolIDs, result.ToolIDs)
+		result.Cache = types.FirstSet(opt.Cache, result.Cache)
+	}
+	return
+}
+
+// ToolsFromOpenAPI converts an OpenAPI v3 definition, in JSON or YAML, to the tools GPTScript would load from it,
+// without loading them into a program. The first tool exports all the others, each of which calls one operation.
+// The Instructions of the operation tools are in the format "#!sys.openapi '{JSON Instructions}'", where the JSON
+// Instructions are a JSON-serialized engine.OpenAPIInstructions struct describing the request to make.
+func ToolsFromOpenAPI(data []byte, opts ...OpenAPIOptions) ([]types.Tool, error) {
+	opt := completeOpenAPI(opts...)
+
+	if !isOpenAPI(data) {
+		return nil, fmt.Errorf("not an OpenAPI definition, no paths found")
+	}
+
+	tools, err := loadOpenAPITools(data, opt.DefaultHost, opt.ToolIDs, opt.Cache)
+	if err != nil {
+		return nil, err
+	} else if len(tools) == 0 {
+		// loadOpenAPITools does not return the error, because the content could be something else than OpenAPI
+		_, err := openapi3.NewLoader().LoadFromData(data)
+		return nil, fmt.Errorf("failed to parse OpenAPI definition: %w", err)
+	}
+
+	for i := range tools {
+		tools[i].Source.Location = opt.Location
+		tools[i].ID = tools[i].Source.String()
+	}
+
+	return tools, nil
+}
+
+// loadOpenAPITools parses an OpenAPI definition and generates its tools, reusing the tools generated by a previous
+// run for the same content if they are in the cache. If the content can not be parsed as OpenAPI, no tools are returned.
+// The IDs of the tools are derived according to idScheme, one of the OpenAPIToolIDs constants.
+func loadOpenAPITools(data []byte, defaultHost, idScheme string, c *cache.Client) ([]types.Tool, error) {
+	tools, err := loadCachedOpenAPITools(data, defaultHost, c)
+	if err != nil || len(tools) == 0 {
+		return tools, err
+	}
+
+	switch idScheme {
+	case "", OpenAPIToolIDsOperation:
+	case OpenAPIToolIDsIndex:
+		for i := range tools {
+			tools[i].Source.Operation = ""
+		}
+	default:
+		return nil, fmt.Errorf("invalid OpenAPI tool ID scheme %q, must be %s or %s", idScheme, OpenAPIToolIDsOperation, OpenAPIToolIDsIndex)
+	}
+
+	return tools, nil
+}
+
+func loadCachedOpenAPITools(data []byte, defaultHost string, c *cache.Client) ([]types.Tool, error) {
+	key := "openapi-" + hash.ID(version.Get().String(), defaultHost, string(data))
+
+	if cached, ok, err := c.Get(key); err != nil {
+		log.Debugf("failed to read cached OpenAPI tools: %v", err)
+	} else if ok {
+		var tools []types.Tool
+		if err := json.Unmarshal(cached, &tools); err != nil {
+			log.Debugf("ignoring invalid cached OpenAPI tools: %v", err)
+		} else {
+			return tools, nil
+		}
+	}
+
+	start := time.Now()
+	t, err := openapi3.NewLoader().LoadFromData(data)
+	if err != nil {
+		return nil, nil
+	}
+
+	tools, err := getOpenAPITools(t, defaultHost)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("parsed OpenAPI definition into %d tools in %s", len(tools), time.Since(start))
+
+	cached, err := json.Marshal(tools)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Store(key, cached); err != nil {
+		log.Debugf("failed to cache OpenAPI tools: %v", err)
+	}
+
+	return tools, nil
+}
+
 // getOpenAPITools parses an OpenAPI definition and generates a set of tools from it.
 // Each operation will become a tool definition.
 // The tool's Instructions will be in the format "#!sys.openapi '{JSON Instructions}'",
@@ -35,6 +159,10 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 		return nil, err
 	}
 
+	// The requests of the tools are sent with the User-Agent and the request ID header that the definition declares
+	userAgent, _ := t.Extensions["x-gptscript-user-agent"].(string)
+	requestIDHeader, _ := t.Extensions["x-gptscript-request-id-header"].(string)
+
 	var globalSecurity []map[string]struct{}
 	if t.Security != nil {
 		for _, item := range t.Security {
@@ -56,7 +184,9 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 		tools        []types.Tool
 		operationNum = 1 // Each tool gets an operation number, beginning with 1
 	)
-	for pathString, pathObj := range t.Paths.Map() {
+	// Operations are numbered in the order of their paths and methods, so the numbers don't change between runs
+	for _, pathString := range sortedKeys(t.Paths.Map()) {
+		pathObj := t.Paths.Value(pathString)
 		// Handle path-level server override, if one exists
 		pathServer := defaultServer
 		if pathObj.Servers != nil && len(pathObj.Servers) > 0 {
@@ -67,7 +197,8 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 		}
 
 	operations:
-		for method, operation := range pathObj.Operations() {
+		for _, method := range sortedKeys(pathObj.Operations()) {
+			operation := pathObj.Operations()[method]
 			// Handle operation-level server override, if one exists
 			operationServer := pathServer
 			if operation.Servers != nil && len(*operation.Servers) > 0 {
@@ -116,7 +247,8 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 				Source: types.ToolSource{
 					// We need some concept of a line number in order for tools to have different IDs
 					// So we basically just treat it as an "operation number" in this case
-					LineNo: operationNum,
+					LineNo:    operationNum,
+					Operation: operationKey(method, pathString, operation),
 				},
 			}
 
@@ -238,7 +370,19 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 			}
 
 			var err error
-			tool.Instructions, err = instructionString(operationServer, method, pathString, bodyMIME, queryParameters, pathParameters, headerParameters, cookieParameters, infos)
+			tool.Instructions, err = instructionString(engine.OpenAPIInstructions{
+				Server:           operationServer,
+				Path:             pathString,
+				Method:           method,
+				BodyContentMIME:  bodyMIME,
+				SecurityInfos:    infos,
+				QueryParameters:  queryParameters,
+				PathParameters:   pathParameters,
+				HeaderParameters: headerParameters,
+				CookieParameters: cookieParameters,
+				UserAgent:        userAgent,
+				RequestIDHeader:  requestIDHeader,
+			})
 			if err != nil {
 				return nil, err
 			}
@@ -266,18 +410,15 @@ func getOpenAPITools(t *openapi3.T, defaultHost string) ([]types.Tool, error) {
 	return tools, nil
 }
 
-func instructionString(server, method, path, bodyMIME string, queryParameters, pathParameters, headerParameters, cookieParameters []engine.Parameter, infos [][]engine.SecurityInfo) (string, error) {
-	inst := engine.OpenAPIInstructions{
-		Server:           server,
-		Path:             path,
-		Method:           method,
-		BodyContentMIME:  bodyMIME,
-		SecurityInfos:    infos,
-		QueryParameters:  queryParameters,
-		PathParameters:   pathParameters,
-		HeaderParameters: headerParameters,
-		CookieParameters: cookieParameters,
+// operationKey returns a key for the operation that does not change when other operations in the definition do.
+func operationKey(method, path string, operation *openapi3.Operation) string {
+	if operation.OperationID != "" {
+		return operation.OperationID
 	}
+	return strings.ToLower(method) + "-" + hash.ID(strings.ToUpper(method), path)[:16]
+}
+
+func instructionString(inst engine.OpenAPIInstructions) (string, error) {
 	instBytes, err := json.Marshal(inst)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal tool instructions: %w", err)
@@ -0,0 +1,151 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/builtin"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+)
+
+// sourceTracker records the files a program was loaded from, so the program can be cached until one of them changes.
+type sourceTracker struct {
+	lock  sync.Mutex
+	files map[string]string
+	// resolved is the ID of the tool each reference to a local file resolved to, keyed by resolvedKey
+	resolved map[string]string
+	// remote is set when anything was loaded from a remote location. Remote content can change at any time, and
+	// checking it would cost as much as loading it, so these programs are not cached.
+	remote bool
+}
+
+func newSourceTracker() *sourceTracker {
+	return &sourceTracker{
+		files:    map[string]string{},
+		resolved: map[string]string{},
+	}
+}
+
+func resolvedKey(location, subToolName string) string {
+	return location + "\x00" + subToolName
+}
+
+func (s *sourceTracker) add(base *source, data []byte) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if base.Remote || isGRPC(base.Location) {
+		s.remote = true
+		return
+	}
+
+	s.files[base.Location] = hash.ID(string(data))
+}
+
+func (s *sourceTracker) addResolved(parsed *parsedSource, subToolName, id string) {
+	if s == nil || parsed.source == nil || parsed.source.Remote || isGRPC(parsed.source.Location) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.resolved[resolvedKey(parsed.source.Location, subToolName)] = id
+}
+
+func (s *sourceTracker) setRemote() {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.remote = true
+}
+
+type cachedProgram struct {
+	// Files is the digest of the content of each file the program was loaded from
+	Files map[string]string `json:"files"`
+	// Resolved is the ID of the tool each reference to a local file resolved to
+	Resolved map[string]string `json:"resolved,omitempty"`
+	Program  types.Program     `json:"program"`
+}
+
+func programCacheKey(name, subToolName string, opts Options) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return "program-" + hash.ID(version.Get().String(), cwd, name, subToolName, fmt.Sprint(opts.StubUnavailable), opts.OpenAPIToolIDs), nil
+}
+
+// getCachedProgram returns the cached program of the given name if none of the files it was loaded from changed.
+func getCachedProgram(name, subToolName string, opts Options) (cachedProgram, bool) {
+	key, err := programCacheKey(name, subToolName, opts)
+	if err != nil {
+		return cachedProgram{}, false
+	}
+
+	data, ok, err := opts.Cache.Get(key)
+	if err != nil {
+		log.Debugf("failed to read cached program %s: %v", name, err)
+		return cachedProgram{}, false
+	} else if !ok {
+		return cachedProgram{}, false
+	}
+
+	var cached cachedProgram
+	if err := json.Unmarshal(data, &cached); err != nil {
+		log.Debugf("ignoring invalid cached program %s: %v", name, err)
+		return cachedProgram{}, false
+	}
+
+	for file, fileDigest := range cached.Files {
+		content, err := os.ReadFile(file)
+		if err != nil || hash.ID(string(content)) != fileDigest {
+			return cachedProgram{}, false
+		}
+	}
+
+	// The functions of built-in tools are not serialized, so put them back
+	for id := range cached.Program.ToolSet {
+		if builtinTool, ok := builtin.Builtin(id); ok {
+			cached.Program.ToolSet[id] = builtinTool
+		}
+	}
+
+	log.Debugf("using cached program for %s", name)
+	return cached, true
+}
+
+func storeCachedProgram(name, subToolName string, opts Options, prg types.Program) {
+	if opts.sources == nil || opts.sources.remote {
+		return
+	}
+
+	key, err := programCacheKey(name, subToolName, opts)
+	if err != nil {
+		return
+	}
+
+	data, err := json.Marshal(cachedProgram{
+		Files:    opts.sources.files,
+		Resolved: opts.sources.resolved,
+		Program:  prg,
+	})
+	if err != nil {
+		log.Debugf("failed to marshal program %s for caching: %v", name, err)
+		return
+	}
+
+	if err := opts.Cache.Store(key, data); err != nil {
+		log.Debugf("failed to cache program %s: %v", name, err)
+	}
+}
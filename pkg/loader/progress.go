@@ -0,0 +1,36 @@
+package loader
+
+import (
+	"time"
+)
+
+type ProgressType string
+
+const (
+	ProgressFetching = ProgressType("fetching")
+	ProgressParsing  = ProgressType("parsing")
+)
+
+// Progress is an event describing what the loader is currently doing
+type Progress struct {
+	Time     time.Time    `json:"time,omitempty"`
+	Type     ProgressType `json:"type,omitempty"`
+	Location string       `json:"location,omitempty"`
+}
+
+// ProgressMonitor receives progress events while a program is being loaded. Files are fetched concurrently,
+// so LoadProgress can be called concurrently.
+type ProgressMonitor interface {
+	LoadProgress(progress Progress)
+}
+
+func (o Options) progress(progressType ProgressType, location string) {
+	if o.Monitor == nil {
+		return
+	}
+	o.Monitor.LoadProgress(Progress{
+		Time:     time.Now(),
+		Type:     progressType,
+		Location: location,
+	})
+}
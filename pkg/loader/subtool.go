@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// splitEntryRef splits the reference to the entry tool of a program at its last "from", so the sub tool can be
+// qualified with its file, like "parse from lib.gpt from github.com/org/repo".
+func splitEntryRef(ref string) (name, subToolName string) {
+	fields := strings.Fields(ref)
+	for i := len(fields) - 1; i > 0; i-- {
+		if fields[i] == "from" {
+			return strings.Join(fields[i+1:], " "), strings.Join(fields[:i], " ")
+		}
+	}
+	return SplitToolRef(ref)
+}
+
+// targetSubTool returns the tool that the sub tool name of a program targets, given the entry tool the program was
+// loaded with, or the error loading it. A sub tool that is not a tool of the entry file of the program is looked up
+// in every file of the program, so that tools the program does not export can be run from the CLI in development.
+// The name can be qualified with the file of the tool, like "parse from lib.gpt", when tools of several files have
+// the name.
+func targetSubTool(prg types.Program, entry types.Tool, err error, subToolName string) (types.Tool, error) {
+	var notFound *types.ErrToolNotFound
+	if errors.As(err, &notFound) && prg.EntryToolID != "" {
+		// The sub tool is not a tool of the entry file of an assembled program, it can still be one of its other tools
+		entry, err = prg.ToolSet[prg.EntryToolID], nil
+	}
+	if err != nil {
+		return types.Tool{}, err
+	}
+	if subToolName == "" || strings.EqualFold(entry.Parameters.Name, subToolName) {
+		return entry, nil
+	}
+
+	name, location := subToolName, ""
+	if i := strings.LastIndex(subToolName, " from "); i != -1 {
+		name, location = strings.TrimSpace(subToolName[:i]), strings.TrimSpace(subToolName[i+len(" from "):])
+	}
+
+	var matches []types.Tool
+	for _, tool := range prg.ToolSet {
+		if tool.BuiltinFunc != nil || !strings.EqualFold(tool.Parameters.Name, name) {
+			continue
+		}
+		if location != "" && !matchesLocation(tool.Source.Location, location) {
+			continue
+		}
+		matches = append(matches, tool)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID < matches[j].ID
+	})
+
+	switch len(matches) {
+	case 0:
+		return types.Tool{}, types.NewErrToolNotFound(subToolName)
+	case 1:
+	default:
+		var locations []string
+		for _, match := range matches {
+			locations = append(locations, match.Source.String())
+		}
+		return types.Tool{}, fmt.Errorf("tools named [%s] are found in %s, qualify the sub tool with its file, like \"%s from %s\"",
+			name, strings.Join(locations, ", "), name, path.Base(matches[0].Source.Location))
+	}
+
+	if matches[0].Source.Location != entry.Source.Location {
+		log.Warnf("Running tool [%s] of %s directly, bypassing the tools that %s exports", matches[0].Parameters.Name,
+			matches[0].Source, entry.Source.Location)
+	}
+	return matches[0], nil
+}
+
+// matchesLocation returns whether location is the location of a file, or ends with its path, like lib.gpt or
+// ./tools/lib.gpt for github.com/org/repo/tools/lib.gpt.
+func matchesLocation(location, file string) bool {
+	file = strings.TrimPrefix(path.Clean(strings.ReplaceAll(file, "\\", "/")), "./")
+	location = strings.ReplaceAll(location, "\\", "/")
+	return location == file || strings.HasSuffix(location, "/"+file)
+}
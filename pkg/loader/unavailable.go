@@ -0,0 +1,48 @@
+package loader
+
+import (
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// unavailableError is returned when a remote tool could not be fetched
+type unavailableError struct {
+	location string
+	err      error
+}
+
+func (u *unavailableError) Error() string {
+	return u.err.Error()
+}
+
+func (u *unavailableError) Unwrap() error {
+	return u.err
+}
+
+// unavailableTool adds a stub to the program in place of a tool that could not be fetched. The stub
+// will return an error explaining the tool is unavailable when it is called.
+func unavailableTool(prg *types.Program, name string, unavailable *unavailableError, opts Options) types.Tool {
+	// The tool might be available next time, so don't cache the program
+	opts.sources.setRemote()
+
+	id := types.UnavailablePrefix[len(types.CommandPrefix):] + ":" + unavailable.location
+	if existing, ok := prg.ToolSet[id]; ok {
+		return existing
+	}
+
+	log.Infof("Failed to load %s, using an unavailable stub: %v", unavailable.location, unavailable.err)
+
+	tool := types.Tool{
+		Parameters: types.Parameters{
+			Name:        name,
+			Description: "This tool is unavailable and will fail if called",
+		},
+		ID:           id,
+		Instructions: types.UnavailablePrefix + "\n" + unavailable.err.Error(),
+		Source: types.ToolSource{
+			Location: unavailable.location,
+		},
+	}
+
+	prg.ToolSet[tool.ID] = tool
+	return tool
+}
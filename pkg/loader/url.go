@@ -8,10 +8,11 @@ import (
 	"path"
 	"strings"
 
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
-type VCSLookup func(string) (string, *types.Repo, bool, error)
+type VCSLookup func(context.Context, string) (string, *types.Repo, bool, error)
 
 var vcsLookups []VCSLookup
 
@@ -19,11 +20,21 @@ func AddVSC(lookup VCSLookup) {
 	vcsLookups = append(vcsLookups, lookup)
 }
 
-func loadURL(ctx context.Context, base *source, name string) (*source, bool, error) {
+// HeaderLookup returns the headers, like credentials, to send when downloading the given URL, or nil if there are none.
+type HeaderLookup func(url string) http.Header
+
+var headerLookups []HeaderLookup
+
+func AddHeaders(lookup HeaderLookup) {
+	headerLookups = append(headerLookups, lookup)
+}
+
+func loadURL(ctx context.Context, base *source, name string, opts Options) (*source, bool, error) {
 	var (
-		repo     *types.Repo
-		url      = name
-		relative = strings.HasPrefix(name, ".") || !strings.Contains(name, "/")
+		repo           *types.Repo
+		revisionPinned bool
+		url            = name
+		relative       = strings.HasPrefix(name, ".") || !strings.Contains(name, "/")
 	)
 
 	if base.Path != "" && relative {
@@ -37,21 +48,35 @@ func loadURL(ctx context.Context, base *source, name string) (*source, bool, err
 		newRepo.Path = path.Dir(newPath)
 		newRepo.Name = path.Base(newPath)
 		repo = &newRepo
+		revisionPinned = base.revisionPinned
 	}
 
 	if repo == nil || !relative {
+		// References to tools in repositories are locked by revision. Whether the reference is to a repository is only
+		// known after the lookup, so an error because it is not locked is only returned then.
+		pinned, lockErr := opts.locks.pinRevision(name)
 		for _, vcs := range vcsLookups {
-			newURL, newRepo, ok, err := vcs(name)
+			newURL, newRepo, ok, err := vcs(ctx, pinned)
 			if err != nil {
 				return nil, false, err
 			} else if ok {
+				if lockErr != nil {
+					return nil, false, lockErr
+				}
 				repo = newRepo
 				url = newURL
+				_, ref, _ := strings.Cut(pinned, "@")
+				revisionPinned = isCommit(ref) && strings.EqualFold(ref, repo.Revision)
+				opts.locks.add(name, LockedTool{Revision: repo.Revision})
 				break
 			}
 		}
 	}
 
+	if isObjectStore(url) {
+		return loadObject(ctx, url, opts)
+	}
+
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
 		return nil, false, nil
 	}
@@ -74,12 +99,20 @@ func loadURL(ctx context.Context, base *source, name string) (*source, bool, err
 		url = pathString + "/" + name
 	}
 
+	opts.progress(ProgressFetching, url)
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, false, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	for _, lookup := range headerLookups {
+		for k, v := range lookup(url) {
+			req.Header[k] = v
+		}
+	}
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
 	if err != nil {
 		return nil, false, err
 	} else if resp.StatusCode != http.StatusOK {
@@ -89,11 +122,12 @@ func loadURL(ctx context.Context, base *source, name string) (*source, bool, err
 	log.Debugf("opened %s", url)
 
 	return &source{
-		Content:  resp.Body,
-		Remote:   true,
-		Path:     pathString,
-		Name:     name,
-		Location: url,
-		Repo:     repo,
+		Content:        resp.Body,
+		Remote:         true,
+		Path:           pathString,
+		Name:           name,
+		Location:       url,
+		Repo:           repo,
+		revisionPinned: revisionPinned,
 	}, true, nil
 }
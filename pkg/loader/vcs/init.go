@@ -2,5 +2,7 @@ package vcs
 
 import (
 	// Load all VCS
+	_ "github.com/gptscript-ai/gptscript/pkg/loader/bitbucket"
 	_ "github.com/gptscript-ai/gptscript/pkg/loader/github"
+	_ "github.com/gptscript-ai/gptscript/pkg/loader/gitlab"
 )
@@ -0,0 +1,267 @@
+package loader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// VendorDir is the directory next to a program that gptscript vendor saves its remote tools in
+	VendorDir = "gptscript_vendor"
+	// VendorIndex is the file in the vendor directory that maps the references to remote tools to their content
+	VendorIndex = "vendor.json"
+
+	vendorVersion = 1
+)
+
+// Vendor is the content of the index of a vendor directory.
+type Vendor struct {
+	Version int `json:"version"`
+	// Sources are keyed by the reference to the remote source, resolved against the remote source referencing it
+	Sources map[string]VendoredSource `json:"sources"`
+}
+
+type VendoredSource struct {
+	// File is the path of the content in the vendor directory
+	File string `json:"file"`
+	// Remote is set for sources loaded from URLs, which are parsed as if they were loaded from Path again, so the
+	// relative references in them resolve to the same keys. Other sources, like OCI artifacts, are vendored with
+	// all their files, and relative references in them are loaded from the vendor directory.
+	Remote   bool        `json:"remote,omitempty"`
+	Path     string      `json:"path,omitempty"`
+	Name     string      `json:"name,omitempty"`
+	Location string      `json:"location,omitempty"`
+	Repo     *types.Repo `json:"repo,omitempty"`
+}
+
+// VendorPath returns the vendor directory of a program, which is dir if set, and the directory next to the program
+// otherwise.
+func VendorPath(name, dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(lockFilePath(name)), VendorDir)
+}
+
+// vendorState is the vendor directory of the program being loaded, either written while vendoring or read when
+// loading the program offline.
+type vendorState struct {
+	lock    sync.Mutex
+	dir     string
+	offline bool
+	sources map[string]VendoredSource
+}
+
+// readVendor returns the vendor directory to load the program from when offline, or to save its remote sources in
+// when vendoring. Nil is returned if neither is the case.
+func readVendor(name string, opts Options) (*vendorState, error) {
+	if !opts.Offline && !opts.Vendoring {
+		return nil, nil
+	}
+
+	v := &vendorState{
+		dir:     VendorPath(name, opts.VendorDir),
+		offline: !opts.Vendoring,
+		sources: map[string]VendoredSource{},
+	}
+	if opts.Vendoring {
+		return v, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(v.dir, VendorIndex))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("vendor directory %s does not exist, run gptscript vendor to create it", v.dir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var vendor Vendor
+	if err := json.Unmarshal(data, &vendor); err != nil {
+		return nil, fmt.Errorf("invalid vendor index %s: %w", filepath.Join(v.dir, VendorIndex), err)
+	}
+	if vendor.Version != vendorVersion {
+		return nil, fmt.Errorf("unsupported version %d of vendor index %s", vendor.Version, filepath.Join(v.dir, VendorIndex))
+	}
+	if vendor.Sources != nil {
+		v.sources = vendor.Sources
+	}
+	return v, nil
+}
+
+// vendorKey returns the key of a reference in the vendor index, which is the reference resolved the same way it is
+// resolved when it is fetched.
+func vendorKey(base *source, name string) string {
+	if base.Path != "" && (strings.HasPrefix(name, ".") || !strings.Contains(name, "/")) {
+		return base.Path + "/" + name
+	}
+	return name
+}
+
+func (v *vendorState) isOffline() bool {
+	return v != nil && v.offline
+}
+
+// load returns the vendored source of the reference. Nothing is fetched, so a reference that is not vendored fails.
+func (v *vendorState) load(base *source, name string) (*source, error) {
+	key := vendorKey(base, name)
+
+	v.lock.Lock()
+	vendored, ok := v.sources[key]
+	v.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s is not vendored in %s, run gptscript vendor to vendor it", key, v.dir)
+	}
+
+	file := filepath.Join(v.dir, filepath.FromSlash(vendored.File))
+	if !vendored.Remote {
+		s, ok, err := loadLocal(&source{Path: filepath.Dir(file)}, filepath.Base(file))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("%s is missing from vendor directory %s", vendored.File, v.dir)
+		}
+		return s, nil
+	}
+
+	content, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("opened vendored %s for %s", file, vendored.Location)
+
+	return &source{
+		Content:  content,
+		Remote:   true,
+		Path:     vendored.Path,
+		Name:     vendored.Name,
+		Location: vendored.Location,
+		Repo:     vendored.Repo,
+	}, nil
+}
+
+// add saves the content of a source fetched for the reference in the vendor directory. Sources that are not remote,
+// like the tool.gpt of an OCI artifact, are saved with all the files of their directory.
+func (v *vendorState) add(base *source, name string, s *source) (*source, error) {
+	if v == nil || v.offline {
+		return s, nil
+	}
+
+	var vendored VendoredSource
+	if s.Remote {
+		data, err := io.ReadAll(s.Content)
+		_ = s.Content.Close()
+		if err != nil {
+			return nil, err
+		}
+		s.Content = io.NopCloser(bytes.NewReader(data))
+
+		digest := sha256.Sum256(data)
+		vendored = VendoredSource{
+			File:     "sources/" + hex.EncodeToString(digest[:]),
+			Remote:   true,
+			Path:     s.Path,
+			Name:     s.Name,
+			Location: s.Location,
+			Repo:     s.Repo,
+		}
+		if err := writeFile(filepath.Join(v.dir, filepath.FromSlash(vendored.File)), data); err != nil {
+			return nil, err
+		}
+	} else {
+		dir := "oci/" + filepath.Base(s.Path)
+		if err := copyDir(filepath.Join(v.dir, filepath.FromSlash(dir)), s.Path); err != nil {
+			return nil, err
+		}
+		vendored = VendoredSource{
+			File:     dir + "/" + s.Name,
+			Location: name,
+		}
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.sources[vendorKey(base, name)] = vendored
+	return s, nil
+}
+
+// save writes the index of the vendor directory and removes the content of sources that are no longer referenced.
+func (v *vendorState) save() error {
+	if v == nil || v.offline {
+		return nil
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	used := map[string]struct{}{}
+	for _, vendored := range v.sources {
+		// Files are saved as sources/<digest> or oci/<digest>/<file>
+		parts := strings.SplitN(vendored.File, "/", 3)
+		used[strings.Join(parts[:min(len(parts), 2)], "/")] = struct{}{}
+	}
+	for _, dir := range []string{"sources", "oci"} {
+		entries, err := os.ReadDir(filepath.Join(v.dir, dir))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for _, entry := range entries {
+			if _, ok := used[dir+"/"+entry.Name()]; !ok {
+				if err := os.RemoveAll(filepath.Join(v.dir, dir, entry.Name())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(Vendor{
+		Version: vendorVersion,
+		Sources: v.sources,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(v.dir, VendorIndex), append(data, '\n'))
+}
+
+func writeFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// copyDir copies the files in src to dst, replacing what is in dst.
+func copyDir(dst, src string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeFile(filepath.Join(dst, rel), data)
+	})
+}
@@ -0,0 +1,213 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+)
+
+const (
+	// DigestPinPrefix starts the digest a reference to a remote tool can be pinned to, like
+	// https://example.com/tool.gpt#sha256:<hex>
+	DigestPinPrefix = "#sha256:"
+
+	// VerifyDigest requires remote tools to be pinned to a digest or signed
+	VerifyDigest = "digest"
+	// VerifySignature requires remote tools to be signed
+	VerifySignature = "signature"
+
+	// SignatureBundleSuffix is appended to the location of a remote tool to find its cosign signature bundle
+	SignatureBundleSuffix = ".bundle"
+)
+
+// splitDigestPin splits the digest a reference is pinned to from the reference.
+func splitDigestPin(name string) (string, string) {
+	ref, pin, ok := strings.Cut(name, DigestPinPrefix)
+	if !ok {
+		return name, ""
+	}
+	return ref, "sha256:" + pin
+}
+
+func (o Options) validateVerify() error {
+	switch o.RequireVerification {
+	case "", VerifyDigest, VerifySignature:
+	default:
+		return fmt.Errorf("invalid verification requirement %q, must be %s or %s", o.RequireVerification, VerifyDigest, VerifySignature)
+	}
+	if o.RequireVerification == VerifySignature && !o.signing() {
+		return fmt.Errorf("--cosign-key or --cosign-identity must be set to require signatures")
+	}
+	if o.CosignIdentity != "" && o.CosignIssuer == "" {
+		return fmt.Errorf("--cosign-oidc-issuer must be set to verify keyless signatures")
+	}
+	return nil
+}
+
+// signing returns true if signatures of remote tools are verified
+func (o Options) signing() bool {
+	return o.CosignKey != "" || o.CosignIdentity != ""
+}
+
+// cosignArgs are the arguments of cosign to verify a signature with the configured key or identity
+func (o Options) cosignArgs() []string {
+	if o.CosignKey != "" {
+		return []string{"--key", o.CosignKey}
+	}
+	return []string{
+		"--certificate-identity-regexp", o.CosignIdentity,
+		"--certificate-oidc-issuer-regexp", o.CosignIssuer,
+	}
+}
+
+// verifySource verifies the content of a remote tool against the digest its reference is pinned to, and its
+// signature if signatures are verified, before the tool is used.
+func verifySource(ctx context.Context, s *source, pin string, opts Options) (*source, error) {
+	if pin == "" && opts.RequireVerification == "" && !opts.signing() {
+		return s, nil
+	}
+
+	data, err := io.ReadAll(s.Content)
+	_ = s.Content.Close()
+	if err != nil {
+		return nil, err
+	}
+	s.Content = io.NopCloser(bytes.NewReader(data))
+
+	verified := false
+	if pin != "" {
+		digest := sha256.Sum256(data)
+		if sum := "sha256:" + hex.EncodeToString(digest[:]); sum != pin {
+			return nil, &integrityError{msg: fmt.Sprintf("the digest of %s is %s, not %s", s.Location, sum, pin)}
+		}
+		verified = true
+	}
+
+	if opts.signing() {
+		if err := verifyBlobSignature(ctx, s.Location, data, opts); err != nil {
+			return nil, err
+		}
+		verified = true
+	} else if opts.RequireVerification == VerifySignature {
+		return nil, &integrityError{msg: fmt.Sprintf("%s is not signed", s.Location)}
+	}
+
+	if !verified && opts.RequireVerification != "" {
+		return nil, &integrityError{msg: fmt.Sprintf("%s must be pinned to a digest like %s%s<hex> or signed", s.Location, s.Location, DigestPinPrefix)}
+	}
+	return s, nil
+}
+
+// verifyRevision verifies that a tool in a repository is pinned to a commit when verification is required. A digest pin
+// or a signature only verify the file of the tool, the code of the tool that is checked out and run is verified by the
+// commit, which is the hash of its tree.
+func verifyRevision(s *source, name string, opts Options) error {
+	if s.Repo == nil || s.revisionPinned || opts.RequireVerification == "" {
+		return nil
+	}
+	ref, _, _ := strings.Cut(name, "@")
+	return &integrityError{msg: fmt.Sprintf("the code of %s in %s must be pinned to a commit like %s@<commit>, or locked with --lock", name, s.Repo.Root, ref)}
+}
+
+// isCommit returns true if the ref is the full hash of a commit, of SHA-1 or SHA-256, which can not be moved like a
+// branch or a tag.
+func isCommit(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(ref)
+	return err == nil
+}
+
+// verifyGRPC verifies the definition of a gRPC server against the digest its reference is pinned to. A definition
+// that is read with server reflection can not be signed, so it must be pinned to a digest when verification is
+// required. A definition read from a local proto file is verified like a local file.
+func verifyGRPC(ctx context.Context, s *source, pin string, opts Options) (*source, error) {
+	if u, err := url.Parse(s.Location); err == nil && u.Query().Get("proto") != "" {
+		return verifySource(ctx, s, pin, Options{})
+	}
+	switch {
+	case opts.RequireVerification == VerifySignature:
+		return nil, &integrityError{msg: fmt.Sprintf("%s can not be signed, since its definition is read with server reflection", s.Location)}
+	case opts.RequireVerification == VerifyDigest && pin == "":
+		return nil, &integrityError{msg: fmt.Sprintf("%s must be pinned to the digest of its definition like %s%s<hex>", s.Location, s.Location, DigestPinPrefix)}
+	}
+	return verifySource(ctx, s, pin, Options{})
+}
+
+func verifyBlobSignature(ctx context.Context, location string, data []byte, opts Options) error {
+	bundle, ok, err := loadURL(ctx, &source{}, location+SignatureBundleSuffix, opts)
+	if err == nil && !ok {
+		err = fmt.Errorf("can not load %s", location+SignatureBundleSuffix)
+	}
+	if err != nil {
+		return &integrityError{msg: fmt.Sprintf("failed to load the signature of %s: %v", location, err)}
+	}
+	bundleData, err := io.ReadAll(bundle.Content)
+	_ = bundle.Content.Close()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "gptscript-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	defer cache.Use(dir)()
+
+	blobFile, bundleFile := filepath.Join(dir, "tool"), filepath.Join(dir, "tool"+SignatureBundleSuffix)
+	if err := os.WriteFile(blobFile, data, 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(bundleFile, bundleData, 0600); err != nil {
+		return err
+	}
+
+	args := append([]string{"verify-blob", "--bundle", bundleFile}, opts.cosignArgs()...)
+	if _, err := runCosign(ctx, append(args, blobFile)...); err != nil {
+		return &integrityError{msg: fmt.Sprintf("failed to verify the signature of %s: %v", location, err)}
+	}
+	return nil
+}
+
+// verifyOCI verifies the signature of the artifact of a tool set in an OCI registry. Artifacts are always pulled by
+// digest, so the content of one that is pinned to a digest is verified by the registry client.
+func verifyOCI(ctx context.Context, name, repository, digest, pin string, opts Options) error {
+	if opts.signing() {
+		args := append([]string{"verify"}, opts.cosignArgs()...)
+		if _, err := runCosign(ctx, append(args, repository+"@"+digest)...); err != nil {
+			return &integrityError{msg: fmt.Sprintf("failed to verify the signature of %s: %v", name, err)}
+		}
+		return nil
+	}
+
+	switch {
+	case opts.RequireVerification == VerifySignature:
+		return &integrityError{msg: fmt.Sprintf("%s is not signed", name)}
+	case opts.RequireVerification == VerifyDigest && pin == "" && !strings.Contains(name, "@sha256:"):
+		return &integrityError{msg: fmt.Sprintf("%s must be pinned to a digest like %s@sha256:<hex> or signed", name, OCIPrefix+repository)}
+	}
+	return nil
+}
+
+func runCosign(ctx context.Context, args ...string) ([]byte, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "cosign", args...)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run cosign %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
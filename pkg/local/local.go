@@ -0,0 +1,204 @@
+// Package local runs models with inference servers that gptscript launches on this machine, like the llama.cpp
+// server or vLLM, and stops again when they are idle.
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+var log = mvl.Package()
+
+const (
+	ServerLlamaCPP = "llama.cpp"
+	ServerVLLM     = "vllm"
+
+	defaultIdleTimeout  = 10 * time.Minute
+	defaultReadyTimeout = 5 * time.Minute
+)
+
+// Config is the file of local models, written in YAML.
+type Config struct {
+	Models []Model `yaml:"models"`
+}
+
+// Model is a model served by a local inference server.
+type Model struct {
+	// Name is the name that tools use for the model
+	Name string `yaml:"name"`
+	// Server is the inference server that serves the model, llama.cpp or vllm
+	Server string `yaml:"server"`
+	// Command is the command that starts the server, llama-server for llama.cpp and vllm for vLLM by default
+	Command string `yaml:"command,omitempty"`
+	// Model is the path of the model file for llama.cpp, or the name of the model for vLLM
+	Model string `yaml:"model,omitempty"`
+	// URL is downloaded to the cache for the model file of llama.cpp if set, instead of using Model
+	URL string `yaml:"url,omitempty"`
+	// Digest is the SHA-256 digest of the file at URL, which is checked if set
+	Digest string `yaml:"digest,omitempty"`
+	// Args are added to the command of the server
+	Args []string `yaml:"args,omitempty"`
+	// IdleTimeout is how long the server runs without requests before it is stopped, 10m by default
+	IdleTimeout string `yaml:"idleTimeout,omitempty"`
+	// ReadyTimeout is how long to wait for the server to be ready after it starts, 5m by default
+	ReadyTimeout string `yaml:"readyTimeout,omitempty"`
+
+	idleTimeout, readyTimeout time.Duration
+}
+
+func (m *Model) complete() error {
+	if m.Name == "" {
+		return fmt.Errorf("model has no name")
+	}
+
+	switch m.Server {
+	case ServerLlamaCPP:
+		if m.Command == "" {
+			m.Command = "llama-server"
+		}
+		if m.Model == "" && m.URL == "" {
+			return fmt.Errorf("model %s has neither a model file nor a URL to download it from", m.Name)
+		}
+	case ServerVLLM:
+		if m.Command == "" {
+			m.Command = "vllm"
+		}
+		if m.Model == "" {
+			m.Model = m.Name
+		}
+		if m.URL != "" {
+			return fmt.Errorf("model %s is served by vllm, which downloads its models itself, it can not have a URL", m.Name)
+		}
+	default:
+		return fmt.Errorf("model %s has unsupported server %q, it must be %s or %s", m.Name, m.Server, ServerLlamaCPP, ServerVLLM)
+	}
+
+	var err error
+	if m.idleTimeout, err = duration(m.IdleTimeout, defaultIdleTimeout); err != nil {
+		return fmt.Errorf("invalid idle timeout of model %s: %w", m.Name, err)
+	}
+	if m.readyTimeout, err = duration(m.ReadyTimeout, defaultReadyTimeout); err != nil {
+		return fmt.Errorf("invalid ready timeout of model %s: %w", m.Name, err)
+	}
+	return nil
+}
+
+func duration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Client is a model provider for the local models. Their servers are started on the first request, and stopped
+// when they are idle or the client is closed.
+type Client struct {
+	lock     sync.Mutex
+	cache    *cache.Client
+	modelDir string
+	models   map[string]Model
+	servers  map[string]*server
+}
+
+// Load returns a client for the local models in the file.
+func Load(file string, cache *cache.Client) (*Client, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid local models %s: %w", file, err)
+	}
+
+	return New(config, cache)
+}
+
+func New(config Config, cache *cache.Client) (*Client, error) {
+	c := &Client{
+		cache:    cache,
+		modelDir: filepath.Join(cache.CacheDir(), "local-models"),
+		models:   map[string]Model{},
+		servers:  map[string]*server{},
+	}
+	for _, model := range config.Models {
+		if err := model.complete(); err != nil {
+			return nil, err
+		}
+		if _, ok := c.models[model.Name]; ok {
+			return nil, fmt.Errorf("local model %s is defined more than once", model.Name)
+		}
+		c.models[model.Name] = model
+	}
+	return c, nil
+}
+
+func (c *Client) Supports(_ context.Context, modelName string) (bool, error) {
+	_, ok := c.models[modelName]
+	return ok, nil
+}
+
+func (c *Client) ListModels(_ context.Context, providers ...string) (result []string, _ error) {
+	if len(providers) != 0 && !slices.Contains(providers, "") {
+		return nil, nil
+	}
+	for name := range c.models {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (c *Client) Call(ctx context.Context, messageRequest types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	s, err := c.server(ctx, messageRequest.Model)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release()
+
+	return s.client.Call(ctx, messageRequest, status)
+}
+
+// server returns the running server of the model, starting it if it is not running.
+func (c *Client) server(ctx context.Context, name string) (*server, error) {
+	model, ok := c.models[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to find local model %s", name)
+	}
+
+	c.lock.Lock()
+	s, ok := c.servers[name]
+	if !ok {
+		s = &server{
+			model:    model,
+			cache:    c.cache,
+			modelDir: c.modelDir,
+		}
+		c.servers[name] = s
+	}
+	c.lock.Unlock()
+
+	return s, s.acquire(ctx)
+}
+
+// Close stops the servers that are running.
+func (c *Client) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, s := range c.servers {
+		s.stop()
+	}
+}
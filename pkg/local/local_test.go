@@ -0,0 +1,86 @@
+package local
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCache(t *testing.T) *cache.Client {
+	c, err := cache.New(cache.Options{
+		CacheDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(Config{
+		Models: []Model{
+			{Name: "llama3", Server: ServerLlamaCPP, Model: "llama3.gguf"},
+			{Name: "mistralai/Mistral-7B-Instruct-v0.3", Server: ServerVLLM, IdleTimeout: "1m"},
+		},
+	}, testCache(t))
+	require.NoError(t, err)
+
+	models, err := c.ListModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"llama3", "mistralai/Mistral-7B-Instruct-v0.3"}, models)
+
+	ok, err := c.Supports(context.Background(), "gpt-4o")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	llama := server{model: c.models["llama3"]}
+	assert.Equal(t, "llama-server", llama.model.Command)
+	assert.Equal(t, []string{"-m", "llama3.gguf", "--alias", "llama3", "--host", "127.0.0.1", "--port", "8080"},
+		llama.args("llama3.gguf", 8080))
+
+	vllm := server{model: c.models["mistralai/Mistral-7B-Instruct-v0.3"]}
+	assert.Equal(t, "1m0s", vllm.model.idleTimeout.String())
+	assert.Equal(t, []string{"serve", "mistralai/Mistral-7B-Instruct-v0.3", "--served-model-name",
+		"mistralai/Mistral-7B-Instruct-v0.3", "--host", "127.0.0.1", "--port", "8080"}, vllm.args("", 8080))
+
+	for _, model := range []Model{
+		{Server: ServerLlamaCPP, Model: "llama3.gguf"},
+		{Name: "llama3", Server: ServerLlamaCPP},
+		{Name: "llama3", Server: "ollama"},
+		{Name: "llama3", Server: ServerLlamaCPP, Model: "llama3.gguf", IdleTimeout: "soon"},
+	} {
+		_, err := New(Config{Models: []Model{model}}, testCache(t))
+		assert.Error(t, err, "%#v", model)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("model"))
+	}))
+	defer srv.Close()
+
+	model := Model{
+		Name:   "llama3",
+		Server: ServerLlamaCPP,
+		URL:    srv.URL + "/llama3.gguf",
+		// Not the digest of what the server returns
+		Digest: "a4c6ae2e2e4ab2b3a5ef0fc2d5e0ac0ee82a8dc5aa3223d1e63f0e7d9cbc1a55",
+	}
+	require.NoError(t, model.complete())
+
+	s := server{model: model, modelDir: t.TempDir()}
+	_, err := s.download(context.Background())
+	assert.ErrorContains(t, err, "expected digest")
+
+	s.model.Digest = ""
+	file, err := s.download(context.Background())
+	require.NoError(t, err)
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "model", string(data))
+}
@@ -0,0 +1,285 @@
+package local
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/openai"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+// server is the inference server of a model. It runs while it is used, and is stopped once it has not been used
+// for the idle timeout of the model.
+type server struct {
+	lock     sync.Mutex
+	model    Model
+	cache    *cache.Client
+	modelDir string
+
+	cmd    *exec.Cmd
+	exited chan struct{}
+	client *openai.Client
+	inUse  int
+	idle   *time.Timer
+}
+
+func (s *server) running() bool {
+	if s.cmd == nil {
+		return false
+	}
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// acquire starts the server if it is not running, and keeps it running until release is called.
+func (s *server) acquire(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.idle != nil {
+		s.idle.Stop()
+		s.idle = nil
+	}
+
+	if !s.running() {
+		if err := s.start(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.inUse++
+	return nil
+}
+
+func (s *server) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.inUse--
+	if s.inUse > 0 || s.cmd == nil {
+		return
+	}
+
+	s.idle = time.AfterFunc(s.model.idleTimeout, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if s.inUse == 0 {
+			log.Infof("Stopping %s server of model %s after being idle for %s", s.model.Server, s.model.Name, s.model.idleTimeout)
+			s.stopLocked()
+		}
+	})
+}
+
+func (s *server) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopLocked()
+}
+
+func (s *server) stopLocked() {
+	if s.idle != nil {
+		s.idle.Stop()
+		s.idle = nil
+	}
+	if s.cmd == nil {
+		return
+	}
+
+	if s.running() {
+		if err := s.cmd.Cancel(); err != nil {
+			log.Debugf("failed to stop %s server of model %s: %v", s.model.Server, s.model.Name, err)
+		}
+		<-s.exited
+	}
+	s.cmd = nil
+	s.client = nil
+}
+
+func (s *server) args(modelPath string, port int) []string {
+	host := []string{"--host", "127.0.0.1", "--port", strconv.Itoa(port)}
+	if s.model.Server == ServerVLLM {
+		return append(append([]string{"serve", s.model.Model, "--served-model-name", s.model.Name}, host...), s.model.Args...)
+	}
+	return append(append([]string{"-m", modelPath, "--alias", s.model.Name}, host...), s.model.Args...)
+}
+
+func (s *server) start(ctx context.Context) error {
+	modelPath := s.model.Model
+	if s.model.URL != "" {
+		var err error
+		modelPath, err = s.download(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(s.model.Command, s.args(modelPath, port)...)
+	// Servers are interrupted so they release the GPU, and killed if they do not exit in time
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = 10 * time.Second
+	if log.IsDebug() {
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+	}
+
+	log.Infof("Starting %s server of model %s: %v", s.model.Server, s.model.Name, cmd.Args)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s server of model %s: %w", s.model.Server, s.model.Name, err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			log.Debugf("%s server of model %s exited: %v", s.model.Server, s.model.Name, err)
+		}
+		close(exited)
+	}()
+
+	s.cmd, s.exited = cmd, exited
+
+	baseURL := "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if err := s.waitReady(ctx, baseURL); err != nil {
+		s.stopLocked()
+		return err
+	}
+
+	s.client, err = openai.NewClient(openai.Options{
+		BaseURL:  baseURL + "/v1",
+		APIKey:   "local",
+		Cache:    s.cache,
+		CacheKey: hash.ID("local", s.model.Server, s.model.Name, modelPath),
+	})
+	if err != nil {
+		s.stopLocked()
+		return err
+	}
+	return nil
+}
+
+// waitReady waits for the health check of the server to pass, which it does once the model is loaded.
+func (s *server) waitReady(ctx context.Context, baseURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.model.readyTimeout)
+	defer cancel()
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/health", nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-s.exited:
+			return fmt.Errorf("%s server of model %s exited before it was ready", s.model.Server, s.model.Name)
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for %s server of model %s to be ready: %w", s.model.Server, s.model.Name, ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// download downloads the model file from the URL of the model to the cache, unless it was downloaded before.
+func (s *server) download(ctx context.Context) (string, error) {
+	parsed, err := url.Parse(s.model.URL)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(s.modelDir, hash.ID(s.model.URL, s.model.Digest), path.Base(parsed.Path))
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	log.Infof("Downloading model %s from %s", s.model.Name, s.model.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.model.URL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download model %s from %s: %s", s.model.Name, s.model.URL, resp.Status)
+	}
+
+	tmp := target + ".download"
+	defer os.Remove(tmp)
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	digester := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, digester), resp.Body); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+
+	if digest := hex.EncodeToString(digester.Sum(nil)); s.model.Digest != "" && !strings.EqualFold(digest, s.model.Digest) {
+		return "", fmt.Errorf("downloaded model %s from %s and expected digest %s but got %s", s.model.Name, s.model.URL, s.model.Digest, digest)
+	}
+
+	return target, os.Rename(tmp, target)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -14,14 +14,21 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
 	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/usage"
+	"golang.org/x/term"
 )
 
 type Options struct {
 	DisplayProgress bool   `usage:"-"`
 	DumpState       string `usage:"Dump the internal execution state to a file"`
 	DebugMessages   bool   `usage:"Enable logging of chat completion calls"`
+	TUI             bool   `usage:"Show the progress of the run as a live tree of its calls, with how long they run, their tokens and the output their models stream, instead of a log of its events" name:"tui"`
+	UsageReport     bool   `usage:"Print the tokens and the cost of the calls to models of the run by model and by tool when it finishes, see --prices"`
+	// Redaction is what is removed from the events before they are displayed
+	Redaction Redaction `usage:"-"`
 }
 
 func complete(opts ...Options) (result Options) {
@@ -29,6 +36,9 @@ func complete(opts ...Options) (result Options) {
 		result.DumpState = types.FirstSet(opt.DumpState, result.DumpState)
 		result.DisplayProgress = types.FirstSet(opt.DisplayProgress, result.DisplayProgress)
 		result.DebugMessages = types.FirstSet(opt.DebugMessages, result.DebugMessages)
+		result.UsageReport = types.FirstSet(opt.UsageReport, result.UsageReport)
+		result.TUI = types.FirstSet(opt.TUI, result.TUI)
+		result.Redaction = types.FirstSet(opt.Redaction, result.Redaction)
 	}
 	return
 }
@@ -37,6 +47,9 @@ type Console struct {
 	dumpState       string
 	displayProgress bool
 	printMessages   bool
+	usageReport     bool
+	tui             bool
+	redaction       Redaction
 }
 
 var (
@@ -44,20 +57,46 @@ var (
 	prettyIDCounter int64
 )
 
+func (c *Console) LoadProgress(progress loader.Progress) {
+	log.Fields("location", progress.Location).Infof("%-8s [%s]", progress.Type, progress.Location)
+}
+
 func (c *Console) Start(_ context.Context, prg *types.Program, _ []string, input string) (runner.Monitor, error) {
+	if c.redaction.redacts() {
+		input = redact(input)
+	}
+
+	if mon := c.startTUI(); mon != nil {
+		if c.redaction.redacts() {
+			return redactingMonitor{
+				Monitor:   mon,
+				redaction: c.redaction,
+			}, nil
+		}
+		return mon, nil
+	}
+
 	id := atomic.AddInt64(&runID, 1)
 	mon := newDisplay(c.dumpState, c.displayProgress, c.printMessages)
+	mon.usageReport = c.usageReport
 	mon.dump.ID = fmt.Sprint(id)
 	mon.dump.Program = prg
 	mon.dump.Input = input
 
 	log.Fields("runID", mon.dump.ID, "input", input, "program", prg).Debugf("Run started")
+	if c.redaction.redacts() {
+		return redactingMonitor{
+			Monitor:   mon,
+			redaction: c.redaction,
+		}, nil
+	}
 	return mon, nil
 }
 
 type display struct {
 	dump          dump
 	printMessages bool
+	usageReport   bool
 	livePrinter   *livePrinter
 	dumpState     string
 	callIDMap     map[string]string
@@ -232,6 +271,10 @@ func (d *display) Event(event runner.Event) {
 		d.livePrinter.end()
 		currentCall.Start = event.Time
 		currentCall.Input = event.Content
+		if event.Features != nil {
+			d.dump.Features = event.Features
+			log.Fields("features", event.Features).Debugf("features [%s]", callName)
+		}
 		log.Fields("input", event.Content).Infof("started  [%s]", callName)
 	case runner.EventTypeCallSubCalls:
 		d.livePrinter.progressEnd(currentCall)
@@ -267,6 +310,40 @@ func (d *display) Event(event runner.Event) {
 			Response:     event.ChatResponse,
 			Cached:       event.ChatResponseCached,
 		})
+	case runner.EventTypeEgressBlocked:
+		d.livePrinter.end()
+		log.Infof("blocked  [%s] %s", callName, event.Content)
+	case runner.EventTypeRequestPruned:
+		d.livePrinter.end()
+		log.Fields("completionID", event.ChatCompletionID).Infof("pruned   [%s] %s", callName, event.Content)
+	case runner.EventTypeAPIRequest:
+		log.Fields("requestID", event.RequestID).Debugf("request  [%s] %s", callName, event.Content)
+	case runner.EventTypeStreamResumed:
+		d.livePrinter.end()
+		log.Fields("completionID", event.ChatCompletionID).Infof("resumed  [%s] %s", callName, event.Content)
+	case runner.EventTypeDownloadProgress:
+		d.livePrinter.end()
+		log.Fields("completionID", event.ChatCompletionID).Infof("download [%s] %s", callName, event.Content)
+	case runner.EventTypeDryRun:
+		d.livePrinter.end()
+		log.Infof("dry run  [%s] %s", callName, event.Content)
+	case runner.EventTypeCallLoop:
+		d.livePrinter.end()
+		log.Infof("loop     [%s] %s", callName, event.Content)
+	case runner.EventTypeRunCanceled:
+		d.livePrinter.end()
+		log.Fields("cancelReason", event.CancelReason).Infof("canceled [%s] %s", callName, event.CancelReason)
+	case runner.EventTypeRunUsage:
+		d.livePrinter.end()
+		d.dump.Usage = event.Usage
+		log.Fields("usage", event.Usage).Debugf("usage    [%s] %d calls, %d input and %d output tokens", callName,
+			event.Usage.Total.Calls, event.Usage.Total.InputTokens, event.Usage.Total.OutputTokens)
+		if d.usageReport {
+			_, _ = fmt.Fprintln(os.Stderr)
+			if err := event.Usage.Write(os.Stderr); err != nil {
+				log.Errorf("Failed to print the usage of the run: %v", err)
+			}
+		}
 	case runner.EventTypeCallFinish:
 		d.livePrinter.progressEnd(currentCall)
 		d.livePrinter.end()
@@ -294,12 +371,27 @@ func (d *display) Stop(output string, err error) {
 	}
 }
 
+// startTUI returns the live tree of the run if it is enabled and stderr is a terminal, or nil. The tree does not dump
+// the state of the run or log its messages, so it is not used with --dump-state or --debug-messages.
+func (c *Console) startTUI() runner.Monitor {
+	if !c.tui || !c.displayProgress || c.dumpState != "" || c.printMessages || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	if t := startTUI(os.Stderr, c.usageReport); t != nil {
+		return t
+	}
+	return nil
+}
+
 func NewConsole(opts ...Options) *Console {
 	opt := complete(opts...)
 	return &Console{
 		dumpState:       opt.DumpState,
 		displayProgress: opt.DisplayProgress,
 		printMessages:   opt.DebugMessages,
+		usageReport:     opt.UsageReport,
+		tui:             opt.TUI,
+		redaction:       opt.Redaction,
 	}
 }
 
@@ -412,6 +504,10 @@ type dump struct {
 	Input   string         `json:"input,omitempty"`
 	Output  string         `json:"output,omitempty"`
 	Err     error          `json:"err,omitempty"`
+	// Features are the feature flags the run was run with
+	Features map[string]bool `json:"features,omitempty"`
+	// Usage is the usage of the calls to models of the run
+	Usage *usage.Report `json:"usage,omitempty"`
 }
 
 type message struct {
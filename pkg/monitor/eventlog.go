@@ -0,0 +1,253 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// EventLogVersion is the version of the schema of the entries of event logs. It changes when a field of the entries
+// changes its meaning or is removed, not when one is added, so a reader of the logs can tell which schema an entry
+// has.
+const EventLogVersion = 1
+
+// The types of the entries of event logs that are not the types of the events of runs.
+const (
+	LogTypeRunStart        = "runStart"
+	LogTypeRunFinish       = "runFinish"
+	LogTypeConfirmRequest  = "confirmRequest"
+	LogTypeConfirmResponse = "confirmResponse"
+)
+
+// LogEntry is a line of an event log: the start or finish of a run, an event of a run, or a confirmation of a call
+// and its answer.
+type LogEntry struct {
+	// Version is the version of the schema of the entry, see EventLogVersion
+	Version int `json:"version"`
+	// RunID identifies the run of the entry in the log, the entries of the runs that run at the same time are
+	// interleaved
+	RunID        string `json:"runID,omitempty"`
+	runner.Event `json:",inline"`
+	Program      *types.Program `json:"program,omitempty"`
+	Input        string         `json:"input,omitempty"`
+	Output       string         `json:"output,omitempty"`
+	Err          string         `json:"err,omitempty"`
+	// Confirm is the confirmation of a call, set on the confirmRequest and confirmResponse entries
+	Confirm *LogConfirm `json:"confirm,omitempty"`
+}
+
+// LogConfirm is a confirmation of a call that was asked for, or its answer.
+type LogConfirm struct {
+	CallID    string `json:"callID,omitempty"`
+	ToolName  string `json:"toolName,omitempty"`
+	Prompt    string `json:"prompt"`
+	Arguments string `json:"arguments,omitempty"`
+	// Approved is whether the call was confirmed, set on the confirmResponse entries
+	Approved *bool `json:"approved,omitempty"`
+	// Err is why the call was not confirmed, like abort
+	Err string `json:"err,omitempty"`
+}
+
+// EventLog writes every event of the runs, and the confirmations of their calls, as JSON lines to a file or a named
+// pipe, for analysis and auditing after the runs. The entries are written as soon as they happen, so the log of a run
+// that crashed has everything until then.
+type EventLog struct {
+	lock      sync.Mutex
+	out       io.Writer
+	redaction Redaction
+	runID     int64
+}
+
+// NewEventLog opens an event log at the location, which can be a file descriptor/handle (e.g. fd://2), a file name,
+// which is appended to, or a named pipe (e.g. \\.\pipe\my-pipe). The redaction is applied to the entries before they
+// are written.
+func NewEventLog(loc string, redaction Redaction) (*EventLog, error) {
+	file, err := openLocation(loc, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the event log %s: %w", loc, err)
+	}
+	return newEventLog(file, redaction), nil
+}
+
+func newEventLog(out io.Writer, redaction Redaction) *EventLog {
+	return &EventLog{
+		out:       out,
+		redaction: redaction,
+	}
+}
+
+func (l *EventLog) write(entry LogEntry) {
+	entry.Version = EventLogVersion
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Failed to marshal the entry of the event log: %v", err)
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err := l.out.Write(append(b, '\n')); err != nil {
+		log.Errorf("Failed to write the entry of the event log: %v", err)
+	}
+}
+
+// Wrap returns a factory of the monitors of the factory that write the events of the runs to the log too. The
+// monitors of the console are wrapped if the factory is nil.
+func (l *EventLog) Wrap(factory runner.MonitorFactory, opts ...Options) runner.MonitorFactory {
+	if factory == nil {
+		factory = NewConsole(opts...)
+	}
+	return eventLogFactory{
+		factory: factory,
+		log:     l,
+	}
+}
+
+type eventLogFactory struct {
+	factory runner.MonitorFactory
+	log     *EventLog
+}
+
+func (e eventLogFactory) LoadProgress(progress loader.Progress) {
+	if m, ok := e.factory.(loader.ProgressMonitor); ok {
+		m.LoadProgress(progress)
+	}
+}
+
+func (e eventLogFactory) Start(ctx context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+	m, err := e.factory.Start(ctx, prg, env, input)
+	if err != nil {
+		return nil, err
+	}
+
+	mon := &eventLogMonitor{
+		Monitor: m,
+		log:     e.log,
+		runID:   fmt.Sprint(atomic.AddInt64(&e.log.runID, 1)),
+	}
+	if e.log.redaction.redacts() {
+		input = redact(input)
+	}
+	e.log.write(LogEntry{
+		RunID: mon.runID,
+		Event: runner.Event{
+			Type: LogTypeRunStart,
+		},
+		Program: prg,
+		Input:   input,
+	})
+	return mon, nil
+}
+
+type eventLogMonitor struct {
+	runner.Monitor
+	log   *EventLog
+	runID string
+}
+
+func (e *eventLogMonitor) Event(event runner.Event) {
+	e.log.write(LogEntry{
+		RunID: e.runID,
+		Event: e.log.redaction.Apply(event),
+	})
+	e.Monitor.Event(event)
+}
+
+func (e *eventLogMonitor) Stop(output string, err error) {
+	entry := LogEntry{
+		RunID: e.runID,
+		Event: runner.Event{
+			Type: LogTypeRunFinish,
+		},
+		Output: output,
+	}
+	if e.log.redaction == RedactBodies {
+		entry.Output = redact(output)
+	}
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	e.log.write(entry)
+	e.Monitor.Stop(output, err)
+}
+
+// Confirm returns a confirmation that asks with c, and writes the confirmations that are asked for and their
+// answers to the log.
+func (l *EventLog) Confirm(c confirm.Confirm) confirm.Confirm {
+	return eventLogConfirm{
+		confirm: c,
+		log:     l,
+	}
+}
+
+type eventLogConfirm struct {
+	confirm confirm.Confirm
+	log     *EventLog
+}
+
+func (e eventLogConfirm) Confirm(ctx context.Context, prompt string) error {
+	_, err := e.Edit(ctx, prompt, "")
+	return err
+}
+
+func (e eventLogConfirm) Edit(ctx context.Context, prompt, arguments string) (string, error) {
+	request := LogConfirm{
+		Prompt:    prompt,
+		Arguments: arguments,
+	}
+	if callCtx, ok := engine.FromContext(ctx); ok {
+		request.CallID = callCtx.ID
+		request.ToolName = callCtx.Tool.Name
+	}
+	if e.log.redaction.redacts() {
+		request.Prompt = redact(request.Prompt)
+		request.Arguments = redact(request.Arguments)
+	}
+	e.log.write(LogEntry{
+		Event: runner.Event{
+			Type: LogTypeConfirmRequest,
+		},
+		Confirm: &request,
+	})
+
+	var (
+		result = arguments
+		err    error
+	)
+	if editor, ok := e.confirm.(confirm.Editor); ok && arguments != "" {
+		result, err = editor.Edit(ctx, prompt, arguments)
+	} else {
+		err = e.confirm.Confirm(ctx, prompt)
+	}
+
+	approved := err == nil
+	response := request
+	response.Approved = &approved
+	if err != nil {
+		response.Err = err.Error()
+	} else if result != arguments && !e.log.redaction.redacts() {
+		response.Arguments = result
+	}
+	e.log.write(LogEntry{
+		Event: runner.Event{
+			Type: LogTypeConfirmResponse,
+		},
+		Confirm: &response,
+	})
+	return result, err
+}
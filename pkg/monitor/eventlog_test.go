@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopFactory struct {
+	events []runner.Event
+}
+
+func (n *nopFactory) Start(context.Context, *types.Program, []string, string) (runner.Monitor, error) {
+	return n, nil
+}
+
+func (n *nopFactory) Event(event runner.Event) {
+	n.events = append(n.events, event)
+}
+
+func (n *nopFactory) Pause() func() {
+	return func() {}
+}
+
+func (n *nopFactory) Stop(string, error) {}
+
+type denyConfirm struct{}
+
+func (denyConfirm) Confirm(context.Context, string) error {
+	return errors.New("abort")
+}
+
+func readLog(t *testing.T, out *bytes.Buffer) (result []LogEntry) {
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry LogEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestEventLog(t *testing.T) {
+	var (
+		out   = &bytes.Buffer{}
+		inner = &nopFactory{}
+		l     = newEventLog(out, RedactNone)
+	)
+
+	m, err := l.Wrap(inner).Start(context.Background(), &types.Program{Name: "prg"}, nil, "input")
+	require.NoError(t, err)
+	m.Event(runner.Event{Type: runner.EventTypeCallStart, Content: "content"})
+
+	prg := &types.Program{ToolSet: types.ToolSet{"tool": {Parameters: types.Parameters{Name: "write"}}}, EntryToolID: "tool"}
+	callCtx := engine.NewContext(context.Background(), prg)
+	assert.Error(t, l.Confirm(denyConfirm{}).Confirm(callCtx.WrappedContext(), "Overwrite: file"))
+	m.Stop("output", nil)
+
+	require.Len(t, inner.events, 1)
+	entries := readLog(t, out)
+	require.Len(t, entries, 5)
+	for _, entry := range entries {
+		assert.Equal(t, EventLogVersion, entry.Version)
+		assert.False(t, entry.Time.IsZero())
+	}
+
+	assert.Equal(t, runner.EventType(LogTypeRunStart), entries[0].Type)
+	assert.Equal(t, "input", entries[0].Input)
+	assert.Equal(t, "prg", entries[0].Program.Name)
+	assert.Equal(t, "1", entries[0].RunID)
+	assert.Equal(t, runner.EventTypeCallStart, entries[1].Type)
+	assert.Equal(t, "content", entries[1].Content)
+	assert.Equal(t, "1", entries[1].RunID)
+
+	assert.Equal(t, runner.EventType(LogTypeConfirmRequest), entries[2].Type)
+	assert.Equal(t, "Overwrite: file", entries[2].Confirm.Prompt)
+	assert.Equal(t, "write", entries[2].Confirm.ToolName)
+	assert.Equal(t, callCtx.ID, entries[2].Confirm.CallID)
+	assert.Nil(t, entries[2].Confirm.Approved)
+	assert.Equal(t, runner.EventType(LogTypeConfirmResponse), entries[3].Type)
+	require.NotNil(t, entries[3].Confirm.Approved)
+	assert.False(t, *entries[3].Confirm.Approved)
+	assert.Equal(t, "abort", entries[3].Confirm.Err)
+
+	assert.Equal(t, runner.EventType(LogTypeRunFinish), entries[4].Type)
+	assert.Equal(t, "output", entries[4].Output)
+}
+
+func TestEventLogRedaction(t *testing.T) {
+	var (
+		out   = &bytes.Buffer{}
+		inner = &nopFactory{}
+		l     = newEventLog(out, RedactBodies)
+	)
+
+	m, err := l.Wrap(inner).Start(context.Background(), &types.Program{}, nil, "secret input")
+	require.NoError(t, err)
+	m.Event(runner.Event{Type: runner.EventTypeCallFinish, Content: "secret output"})
+	m.Stop("secret output", nil)
+
+	// The other sinks get the events as they are
+	assert.Equal(t, "secret output", inner.events[0].Content)
+	assert.NotContains(t, out.String(), "secret")
+	assert.Contains(t, out.String(), Redacted)
+}
@@ -9,20 +9,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
 type Event struct {
 	runner.Event `json:",inline"`
-	Program      *types.Program `json:"program,omitempty"`
-	Input        string         `json:"input,omitempty"`
-	Output       string         `json:"output,omitempty"`
-	Err          string         `json:"err,omitempty"`
+	Program      *types.Program   `json:"program,omitempty"`
+	Input        string           `json:"input,omitempty"`
+	Output       string           `json:"output,omitempty"`
+	Err          string           `json:"err,omitempty"`
+	LoadProgress *loader.Progress `json:"loadProgress,omitempty"`
 }
 
 type fileFactory struct {
 	file *os.File
+	lock sync.Mutex
 }
 
 // NewFileFactory creates a new monitor factory that writes events to the location specified.
@@ -31,31 +34,51 @@ type fileFactory struct {
 // 2. a file name
 // 3. a named pipe in the form "\\.\pipe\my-pipe"
 func NewFileFactory(loc string) (runner.MonitorFactory, error) {
-	var (
-		file *os.File
-		err  error
-	)
+	file, err := openLocation(loc, os.O_WRONLY|os.O_CREATE, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileFactory{
+		file: file,
+	}, nil
+}
 
+// openLocation opens a location that events are written to, a file descriptor/handle, a file name or a named pipe,
+// with the flags and permissions of the file if it is created.
+func openLocation(loc string, flag int, perm os.FileMode) (*os.File, error) {
 	if strings.HasPrefix(loc, "fd://") {
 		fd, err := strconv.Atoi(strings.TrimPrefix(loc, "fd://"))
 		if err != nil {
 			return nil, err
 		}
 
-		file = os.NewFile(uintptr(fd), "events")
-	} else {
-		file, err = os.OpenFile(loc, os.O_WRONLY|os.O_CREATE, 0)
-		if err != nil {
-			return nil, err
-		}
+		return os.NewFile(uintptr(fd), "events"), nil
 	}
+	return os.OpenFile(loc, flag, perm)
+}
 
-	return &fileFactory{
-		file: file,
-	}, nil
+func (s *fileFactory) LoadProgress(progress loader.Progress) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	b, err := json.Marshal(Event{
+		Event: runner.Event{
+			Time: progress.Time,
+			Type: "loadProgress",
+		},
+		LoadProgress: &progress,
+	})
+	if err != nil {
+		log.Errorf("Failed to marshal event: %v", err)
+		return
+	}
+
+	if _, err = s.file.Write(append(b, '\n', '\n')); err != nil {
+		log.Errorf("Failed to write event to file: %v", err)
+	}
 }
 
-func (s fileFactory) Start(_ context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+func (s *fileFactory) Start(_ context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
 	fd := &fd{
 		prj:   prg,
 		env:   env,
@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// Redaction is the policy of what is removed from the events of a run before a sink of events gets them.
+type Redaction string
+
+const (
+	// RedactNone sends the events as they are
+	RedactNone = Redaction("none")
+	// RedactArguments removes the inputs of runs and calls, and the arguments of the tool calls of models
+	RedactArguments = Redaction("arguments")
+	// RedactBodies removes the arguments, and the requests to and responses of models, and the outputs of calls
+	RedactBodies = Redaction("bodies")
+)
+
+// Redacted is what the values that are removed from events are replaced with.
+const Redacted = "[redacted]"
+
+// The sinks of events that the redaction of can be set.
+const (
+	SinkDisplay      = "display"
+	SinkEventsStream = "events-stream"
+	SinkServer       = "server"
+	SinkEventLog     = "event-log"
+	SinkWebhook      = "webhook"
+)
+
+// Sinks are the names of the sinks of events.
+var Sinks = []string{SinkDisplay, SinkEventsStream, SinkServer, SinkEventLog, SinkWebhook}
+
+// Redactions are the redactions of the sinks of events, by the name of the sink. The redaction of the sinks that are
+// not set is the one of "*", or none.
+type Redactions map[string]Redaction
+
+// ParseRedactions parses a comma separated list of the redactions of sinks, like events-stream=bodies,display=none. A
+// redaction without a sink, like bodies, is the redaction of the sinks that are not in the list.
+func ParseRedactions(s string) (Redactions, error) {
+	result := Redactions{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		sink, policy, ok := strings.Cut(item, "=")
+		if !ok {
+			sink, policy = "*", sink
+		} else if sink = strings.TrimSpace(sink); !slices.Contains(Sinks, sink) {
+			return nil, fmt.Errorf("unknown sink of events %q, the sinks are %s", sink, strings.Join(Sinks, ", "))
+		}
+
+		switch redaction := Redaction(strings.TrimSpace(policy)); redaction {
+		case RedactNone, RedactArguments, RedactBodies:
+			result[sink] = redaction
+		default:
+			return nil, fmt.Errorf("invalid redaction %q of sink %s (valid: none, arguments, bodies)", policy, sink)
+		}
+	}
+	return result, nil
+}
+
+// For returns the redaction of the sink.
+func (r Redactions) For(sink string) Redaction {
+	if redaction, ok := r[sink]; ok {
+		return redaction
+	}
+	return types.FirstSet(r["*"], RedactNone)
+}
+
+// redacts returns whether the redaction removes anything from the events.
+func (r Redaction) redacts() bool {
+	return r == RedactArguments || r == RedactBodies
+}
+
+// Apply returns the event with what the redaction removes replaced by Redacted.
+func (r Redaction) Apply(event runner.Event) runner.Event {
+	if !r.redacts() {
+		return event
+	}
+
+	if event.Type == runner.EventTypeCallStart || event.Type == runner.EventTypeDryRun {
+		event.Content = redact(event.Content)
+	}
+	if len(event.ToolSubCalls) > 0 {
+		subCalls := make(map[string]engine.Call, len(event.ToolSubCalls))
+		for id, call := range event.ToolSubCalls {
+			call.Input = redact(call.Input)
+			subCalls[id] = call
+		}
+		event.ToolSubCalls = subCalls
+	}
+
+	if r == RedactBodies {
+		event.ChatRequest = nil
+		event.ChatResponse = nil
+		if event.Type == runner.EventTypeCallProgress || event.Type == runner.EventTypeCallFinish {
+			event.Content = redact(event.Content)
+		}
+	}
+	return event
+}
+
+func redact(value string) string {
+	if value == "" {
+		return value
+	}
+	return Redacted
+}
+
+// Redact returns a factory of the monitors of the factory that get the events of runs with the redaction applied.
+func Redact(factory runner.MonitorFactory, redaction Redaction) runner.MonitorFactory {
+	if !redaction.redacts() {
+		return factory
+	}
+	return redactingFactory{
+		factory:   factory,
+		redaction: redaction,
+	}
+}
+
+type redactingFactory struct {
+	factory   runner.MonitorFactory
+	redaction Redaction
+}
+
+func (r redactingFactory) LoadProgress(progress loader.Progress) {
+	if m, ok := r.factory.(loader.ProgressMonitor); ok {
+		m.LoadProgress(progress)
+	}
+}
+
+func (r redactingFactory) Start(ctx context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+	m, err := r.factory.Start(ctx, prg, env, redact(input))
+	if err != nil {
+		return nil, err
+	}
+	return redactingMonitor{
+		Monitor:   m,
+		redaction: r.redaction,
+	}, nil
+}
+
+type redactingMonitor struct {
+	runner.Monitor
+	redaction Redaction
+}
+
+func (r redactingMonitor) Event(event runner.Event) {
+	r.Monitor.Event(r.redaction.Apply(event))
+}
+
+func (r redactingMonitor) Stop(output string, err error) {
+	if r.redaction == RedactBodies {
+		output = redact(output)
+	}
+	r.Monitor.Stop(output, err)
+}
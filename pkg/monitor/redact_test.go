@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedactions(t *testing.T) {
+	redactions, err := ParseRedactions("arguments, events-stream=bodies,display=none")
+	require.NoError(t, err)
+	assert.Equal(t, RedactNone, redactions.For(SinkDisplay))
+	assert.Equal(t, RedactBodies, redactions.For(SinkEventsStream))
+	assert.Equal(t, RedactArguments, redactions.For(SinkServer))
+
+	redactions, err = ParseRedactions("")
+	require.NoError(t, err)
+	assert.Equal(t, RedactNone, redactions.For(SinkServer))
+
+	_, err = ParseRedactions("webhooks=bodies")
+	assert.Error(t, err)
+	_, err = ParseRedactions("server=all")
+	assert.Error(t, err)
+}
+
+func TestRedactionApply(t *testing.T) {
+	event := runner.Event{
+		Type: runner.EventTypeCallSubCalls,
+		ToolSubCalls: map[string]engine.Call{
+			"call_1": {ToolID: "tool", Input: `{"token":"abc"}`},
+		},
+		ChatRequest: map[string]any{"messages": []any{}},
+	}
+
+	redacted := RedactArguments.Apply(event)
+	assert.Equal(t, Redacted, redacted.ToolSubCalls["call_1"].Input)
+	assert.NotNil(t, redacted.ChatRequest)
+	// The event of the other sinks is not changed
+	assert.Equal(t, `{"token":"abc"}`, event.ToolSubCalls["call_1"].Input)
+
+	redacted = RedactBodies.Apply(event)
+	assert.Nil(t, redacted.ChatRequest)
+
+	finish := runner.Event{Type: runner.EventTypeCallFinish, Content: "the output"}
+	assert.Equal(t, "the output", RedactArguments.Apply(finish).Content)
+	assert.Equal(t, Redacted, RedactBodies.Apply(finish).Content)
+	assert.Equal(t, finish, RedactNone.Apply(finish))
+}
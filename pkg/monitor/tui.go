@@ -0,0 +1,394 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/tokenizer"
+	"github.com/gptscript-ai/gptscript/pkg/usage"
+	"golang.org/x/term"
+)
+
+// tuiInterval is how often the tree of the calls is redrawn, for the spinners and the elapsed times.
+const tuiInterval = 100 * time.Millisecond
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+var (
+	activeLock sync.Mutex
+	active     *tui
+)
+
+// tui displays the progress of a run in the terminal as a live tree of its calls, redrawn as the run goes: how long
+// each call has run, the tokens of its calls to the model, and the last line that its model streams. The branches of
+// the calls that finished collapse to one line, so the calls that run stand out in large runs. The logs of the run are
+// printed above the tree.
+type tui struct {
+	out         *os.File
+	usageReport bool
+
+	lock    sync.Mutex
+	calls   map[string]*tuiCall
+	roots   []string
+	lines   int
+	frame   int
+	paused  int
+	logOut  io.Writer
+	done    chan struct{}
+	stopped chan struct{}
+	usage   *usage.Report
+}
+
+type tuiCall struct {
+	id       string
+	name     string
+	model    string
+	start    time.Time
+	end      time.Time
+	finished bool
+	children []string
+	// modelCalls, inputTokens and outputTokens are of the calls to the model, streamTokens are of the response that
+	// the model streams
+	modelCalls   int
+	inputTokens  int
+	outputTokens int
+	streamTokens int
+	streaming    string
+}
+
+// startTUI returns the tree of the run in the terminal, or nil if the terminal has one already, since two trees can
+// not be redrawn at once.
+func startTUI(out *os.File, usageReport bool) *tui {
+	activeLock.Lock()
+	defer activeLock.Unlock()
+	if active != nil {
+		return nil
+	}
+
+	t := &tui{
+		out:         out,
+		usageReport: usageReport,
+		calls:       map[string]*tuiCall{},
+		logOut:      mvl.Output(),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	mvl.SetOutput(tuiLogWriter{tui: t})
+	active = t
+	go t.run()
+	return t
+}
+
+func (t *tui) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(tuiInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			t.lock.Lock()
+			t.frame++
+			t.draw()
+			t.lock.Unlock()
+		}
+	}
+}
+
+func (t *tui) Event(event runner.Event) {
+	if event.CallContext == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	c, ok := t.calls[event.CallContext.ID]
+	if !ok {
+		c = &tuiCall{
+			id:    event.CallContext.ID,
+			name:  tuiCallName(event.CallContext),
+			model: event.CallContext.Tool.ModelName,
+			start: event.Time,
+		}
+		if c.start.IsZero() {
+			c.start = time.Now()
+		}
+		t.calls[c.id] = c
+		if parent, ok := t.calls[event.CallContext.ParentID]; ok {
+			parent.children = append(parent.children, c.id)
+		} else {
+			t.roots = append(t.roots, c.id)
+		}
+	}
+
+	switch event.Type {
+	case runner.EventTypeCallProgress:
+		c.streaming = lastLine(event.Content)
+		c.streamTokens = tokenizer.Count(c.model, event.Content)
+	case runner.EventTypeChat:
+		if event.ChatRequest != nil {
+			c.modelCalls++
+			c.inputTokens += tokenizer.Count(c.model, toJSON(event.ChatRequest).String())
+		} else if event.ChatResponse != nil {
+			c.outputTokens += tokenizer.Count(c.model, toJSON(event.ChatResponse).String())
+			c.streamTokens = 0
+		}
+	case runner.EventTypeCallContinue, runner.EventTypeCallSubCalls:
+		c.streaming = ""
+	case runner.EventTypeRunUsage:
+		t.usage = event.Usage
+	case runner.EventTypeCallFinish:
+		c.finished = true
+		c.end = event.Time
+		if c.end.IsZero() {
+			c.end = time.Now()
+		}
+		c.streaming = ""
+	}
+}
+
+func (t *tui) Pause() func() {
+	t.lock.Lock()
+	t.paused++
+	t.clear()
+	t.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.paused--
+			t.draw()
+		})
+	}
+}
+
+func (t *tui) Stop(string, error) {
+	close(t.done)
+	<-t.stopped
+
+	t.lock.Lock()
+	for _, c := range t.calls {
+		if !c.finished {
+			c.finished = true
+			c.end = time.Now()
+		}
+	}
+	t.draw()
+	// The tree stays in the terminal as it was drawn last
+	t.lines = 0
+	if t.usageReport && t.usage != nil {
+		_, _ = fmt.Fprintln(t.out)
+		_ = t.usage.Write(t.out)
+	}
+	t.lock.Unlock()
+
+	activeLock.Lock()
+	defer activeLock.Unlock()
+	mvl.SetOutput(t.logOut)
+	active = nil
+}
+
+// clear erases the tree from the terminal.
+func (t *tui) clear() {
+	if t.lines > 0 {
+		_, _ = fmt.Fprintf(t.out, "\x1b[%dA\r\x1b[J", t.lines)
+		t.lines = 0
+	}
+}
+
+// draw draws the tree over the tree that was drawn last.
+func (t *tui) draw() {
+	if t.paused > 0 {
+		return
+	}
+
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	var lines []string
+	for i, id := range t.roots {
+		lines = t.appendLines(lines, id, "", i == len(t.roots)-1, true, width)
+	}
+	if limit := height - 1; limit > 0 && len(lines) > limit {
+		hidden := len(lines) - limit + 1
+		lines = append([]string{color.New(color.Faint).Sprintf("… %d more lines", hidden)}, lines[hidden:]...)
+	}
+
+	buf := &bytes.Buffer{}
+	if t.lines > 0 {
+		_, _ = fmt.Fprintf(buf, "\x1b[%dA", t.lines)
+	}
+	buf.WriteString("\r")
+	for _, line := range lines {
+		buf.WriteString("\x1b[2K")
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\x1b[J")
+	_, _ = t.out.Write(buf.Bytes())
+	t.lines = len(lines)
+}
+
+// appendLines appends the lines of the call, and of its calls unless it finished, to lines.
+func (t *tui) appendLines(lines []string, id, indent string, last, root bool, width int) []string {
+	c := t.calls[id]
+
+	branch, childIndent := "", indent
+	if !root {
+		branch, childIndent = indent+"├─ ", indent+"│  "
+		if last {
+			branch, childIndent = indent+"└─ ", indent+"   "
+		}
+	}
+
+	var (
+		symbol  = color.CyanString(spinner[t.frame%len(spinner)])
+		elapsed = time.Since(c.start)
+	)
+	if c.finished {
+		symbol = color.GreenString("✓")
+		elapsed = c.end.Sub(c.start)
+	}
+
+	info := []string{elapsed.Round(100 * time.Millisecond).String()}
+	if c.modelCalls > 0 {
+		info = append(info, fmt.Sprintf("%d in / %d out tokens", c.inputTokens, c.outputTokens+c.streamTokens))
+	}
+	// The calls of the roots stay expanded, so the tree of a run that finished shows what it called
+	collapse := c.finished && !root
+	if collapse {
+		if n := t.descendants(c); n == 1 {
+			info = append(info, "1 call")
+		} else if n > 1 {
+			info = append(info, fmt.Sprintf("%d calls", n))
+		}
+	}
+
+	text := truncate(fmt.Sprintf("%s  %s", c.name, strings.Join(info, "  ")), width-utf8.RuneCountInString(branch)-3)
+	lines = append(lines, branch+symbol+" "+text)
+	if collapse {
+		return lines
+	}
+
+	if c.streaming != "" {
+		streamIndent := childIndent + "   "
+		if len(c.children) > 0 {
+			streamIndent = childIndent + "│  "
+		}
+		lines = append(lines, streamIndent+color.New(color.Faint).Sprint(truncate(c.streaming, width-utf8.RuneCountInString(streamIndent)-1)))
+	}
+	for i, child := range c.children {
+		lines = t.appendLines(lines, child, childIndent, i == len(c.children)-1, false, width)
+	}
+	return lines
+}
+
+// descendants returns the number of the calls under the call.
+func (t *tui) descendants(c *tuiCall) (result int) {
+	for _, id := range c.children {
+		result += 1 + t.descendants(t.calls[id])
+	}
+	return result
+}
+
+func tuiCallName(callCtx *engine.CallContext) string {
+	name := callCtx.ToolName
+	if name == "" {
+		name = callCtx.Tool.Name
+	}
+	if name == "" {
+		name = "main"
+	}
+	if callCtx.ToolCategory != engine.NoCategory {
+		name = fmt.Sprintf("%s: %s", callCtx.ToolCategory, name)
+	}
+	return name
+}
+
+// lastLine returns the last line of the text that is not empty.
+func lastLine(text string) string {
+	text = strings.TrimRight(text, " \t\r\n")
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// truncate cuts the text to the number of runes, with an ellipsis if it is cut.
+func truncate(text string, n int) string {
+	if n < 1 {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n-1]) + "…"
+}
+
+// tuiLogWriter writes the logs of the run above the tree.
+type tuiLogWriter struct {
+	tui *tui
+}
+
+func (w tuiLogWriter) Write(p []byte) (int, error) {
+	w.tui.lock.Lock()
+	defer w.tui.lock.Unlock()
+	w.tui.clear()
+	n, err := w.tui.logOut.Write(p)
+	w.tui.draw()
+	return n, err
+}
+
+// PauseConfirm returns a confirmation that asks with c while the tree of the run is erased from the terminal, so the
+// prompt is not drawn over.
+func PauseConfirm(c confirm.Confirm) confirm.Confirm {
+	return pausedConfirm{
+		confirm: c,
+	}
+}
+
+type pausedConfirm struct {
+	confirm confirm.Confirm
+}
+
+func pauseActive() func() {
+	activeLock.Lock()
+	t := active
+	activeLock.Unlock()
+	if t == nil {
+		return func() {}
+	}
+	return t.Pause()
+}
+
+func (p pausedConfirm) Confirm(ctx context.Context, prompt string) error {
+	defer pauseActive()()
+	return p.confirm.Confirm(ctx, prompt)
+}
+
+func (p pausedConfirm) Edit(ctx context.Context, prompt, arguments string) (string, error) {
+	defer pauseActive()()
+	if editor, ok := p.confirm.(confirm.Editor); ok {
+		return editor.Edit(ctx, prompt, arguments)
+	}
+	return arguments, p.confirm.Confirm(ctx, prompt)
+}
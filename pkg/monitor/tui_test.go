@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTUITree(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() {
+		color.NoColor = noColor
+	}()
+
+	var (
+		tree  = &tui{calls: map[string]*tuiCall{}}
+		start = time.Now().Add(-time.Minute)
+		prg   = &types.Program{
+			EntryToolID: "main",
+			ToolSet: types.ToolSet{
+				"main":   {Parameters: types.Parameters{Name: "triage"}},
+				"search": {Parameters: types.Parameters{Name: "search"}},
+				"fetch":  {Parameters: types.Parameters{Name: "fetch"}},
+				"write":  {Parameters: types.Parameters{Name: "write"}},
+			},
+		}
+		root = engine.NewContext(context.Background(), prg)
+	)
+	search, err := root.SubCall(context.Background(), "search", "search_1", engine.NoCategory)
+	require.NoError(t, err)
+	fetch, err := search.SubCall(context.Background(), "fetch", "fetch_1", engine.NoCategory)
+	require.NoError(t, err)
+	write, err := root.SubCall(context.Background(), "write", "write_1", engine.NoCategory)
+	require.NoError(t, err)
+
+	for _, event := range []runner.Event{
+		{Time: start, Type: runner.EventTypeCallStart, CallContext: root.GetCallContext()},
+		{Time: start, Type: runner.EventTypeChat, CallContext: root.GetCallContext(), ChatRequest: "the request"},
+		{Time: start, Type: runner.EventTypeCallStart, CallContext: search.GetCallContext()},
+		{Time: start, Type: runner.EventTypeCallStart, CallContext: fetch.GetCallContext()},
+		{Time: start.Add(2 * time.Second), Type: runner.EventTypeCallFinish, CallContext: fetch.GetCallContext()},
+		{Time: start.Add(3 * time.Second), Type: runner.EventTypeCallFinish, CallContext: search.GetCallContext()},
+		{Time: start, Type: runner.EventTypeCallStart, CallContext: write.GetCallContext()},
+		{Time: start, Type: runner.EventTypeCallProgress, CallContext: write.GetCallContext(), Content: "Writing\nthe report to report.md\n"},
+	} {
+		tree.Event(event)
+	}
+
+	lines := tree.appendLines(nil, root.ID, "", true, true, 60)
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "triage  1m0s  4 in / 0 out tokens")
+	assert.Equal(t, "├─ ✓ search  3s  1 call", lines[1])
+	assert.Contains(t, lines[2], "└─ ")
+	assert.Contains(t, lines[2], "write  1m0s")
+	assert.Equal(t, "      the report to report.md", lines[3])
+
+	// A call that finished collapses its calls, but the root stays expanded
+	tree.Event(runner.Event{Time: start.Add(time.Minute), Type: runner.EventTypeCallFinish, CallContext: write.GetCallContext()})
+	tree.Event(runner.Event{Time: start.Add(time.Minute), Type: runner.EventTypeCallFinish, CallContext: root.GetCallContext()})
+	lines = tree.appendLines(nil, root.ID, "", true, true, 60)
+	assert.Equal(t, []string{
+		"✓ triage  1m0s  4 in / 0 out tokens",
+		"├─ ✓ search  3s  1 call",
+		"└─ ✓ write  1m0s",
+	}, lines)
+
+	lines = tree.appendLines(nil, root.ID, "", true, true, 12)
+	assert.Equal(t, "✓ triage  …", lines[0])
+}
+
+func TestLastLine(t *testing.T) {
+	assert.Equal(t, "second", lastLine("first\n second \n\n"))
+	assert.Equal(t, "only", lastLine("only"))
+	assert.Equal(t, "", lastLine("\n"))
+}
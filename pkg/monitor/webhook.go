@@ -0,0 +1,317 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// maxWebhookQueue is the number of events that wait to be posted, the events that happen when it is full are
+	// dropped
+	maxWebhookQueue = 1000
+	// webhookAttempts is the number of times an event is posted before it is dropped
+	webhookAttempts = 3
+)
+
+// WebhookSignatureHeader is the header of the HMAC-SHA256 of the body of the events that are posted with a secret,
+// like sha256=<hex>, so the receiver can verify that they were sent by gptscript.
+const WebhookSignatureHeader = "X-GPTScript-Signature"
+
+// WebhookEvent is an event that is posted to a webhook, an entry of an event log with a description of it.
+type WebhookEvent struct {
+	LogEntry
+	// Text describes the event in a sentence, for the webhooks of chat apps, like Slack, that show it
+	Text string `json:"text"`
+}
+
+// Webhook posts the events of the lifecycle of the runs to a URL: the start of each run, the calls of its tools, the
+// confirmations of its calls that are needed, and its finish, so integrations like chat apps, incident tools or CI get
+// them without polling. The events are posted in the order they happen, in the background, so a slow webhook does not
+// slow the runs down, and a run does not finish until its events were posted.
+type Webhook struct {
+	url       string
+	secret    string
+	redaction Redaction
+	client    *http.Client
+	retryWait time.Duration
+	runID     int64
+
+	lock    sync.Mutex
+	queue   []WebhookEvent
+	sending bool
+	sent    *sync.Cond
+}
+
+// NewWebhook returns a webhook that posts the events to the URL, signed with the secret if it is set. The redaction is
+// applied to the events before they are posted.
+func NewWebhook(url, secret string, redaction Redaction) (*Webhook, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid webhook URL %q, it must be an http or https URL", url)
+	}
+
+	w := &Webhook{
+		url:       url,
+		secret:    secret,
+		redaction: redaction,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		retryWait: time.Second,
+	}
+	w.sent = sync.NewCond(&w.lock)
+	return w, nil
+}
+
+// send queues the event to be posted.
+func (w *Webhook) send(event WebhookEvent) {
+	event.Version = EventLogVersion
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.queue) >= maxWebhookQueue {
+		log.Warnf("Dropping the %s event of the webhook, %d events wait to be posted", event.Type, len(w.queue))
+		return
+	}
+	w.queue = append(w.queue, event)
+	if !w.sending {
+		w.sending = true
+		go w.run()
+	}
+}
+
+func (w *Webhook) run() {
+	for {
+		w.lock.Lock()
+		if len(w.queue) == 0 {
+			w.sending = false
+			w.sent.Broadcast()
+			w.lock.Unlock()
+			return
+		}
+		event := w.queue[0]
+		w.queue = w.queue[1:]
+		w.lock.Unlock()
+
+		if err := w.post(event); err != nil {
+			log.Errorf("Failed to post the %s event to the webhook: %v", event.Type, err)
+		}
+	}
+}
+
+// flush waits until the queued events are posted.
+func (w *Webhook) flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for w.sending {
+		w.sent.Wait()
+	}
+}
+
+// post posts the event, and posts it again when the webhook failed or could not be reached.
+func (w *Webhook) post(event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := w.postOnce(body)
+		if err == nil || !retry || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * w.retryWait)
+	}
+}
+
+func (w *Webhook) postOnce(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode > 499
+		return retry, fmt.Errorf("invalid status code [%d]: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return false, nil
+}
+
+// Wrap returns a factory of the monitors of the factory that post the events of the lifecycle of the runs to the
+// webhook too. The monitors of the console are wrapped if the factory is nil.
+func (w *Webhook) Wrap(factory runner.MonitorFactory, opts ...Options) runner.MonitorFactory {
+	if factory == nil {
+		factory = NewConsole(opts...)
+	}
+	return webhookFactory{
+		factory: factory,
+		webhook: w,
+	}
+}
+
+type webhookFactory struct {
+	factory runner.MonitorFactory
+	webhook *Webhook
+}
+
+func (f webhookFactory) LoadProgress(progress loader.Progress) {
+	if m, ok := f.factory.(loader.ProgressMonitor); ok {
+		m.LoadProgress(progress)
+	}
+}
+
+func (f webhookFactory) Start(ctx context.Context, prg *types.Program, env []string, input string) (runner.Monitor, error) {
+	m, err := f.factory.Start(ctx, prg, env, input)
+	if err != nil {
+		return nil, err
+	}
+
+	mon := &webhookMonitor{
+		Monitor: m,
+		webhook: f.webhook,
+		runID:   fmt.Sprint(atomic.AddInt64(&f.webhook.runID, 1)),
+		name:    prg.Name,
+	}
+	if tool, ok := prg.ToolSet[prg.EntryToolID]; ok {
+		mon.name = types.FirstSet(tool.Name, mon.name)
+	}
+	if f.webhook.redaction.redacts() {
+		input = redact(input)
+	}
+	f.webhook.send(WebhookEvent{
+		LogEntry: LogEntry{
+			RunID: mon.runID,
+			Event: runner.Event{
+				Type: LogTypeRunStart,
+			},
+			Input: input,
+		},
+		Text: fmt.Sprintf("Run %s of %s started", mon.runID, mon.name),
+	})
+	return mon, nil
+}
+
+type webhookMonitor struct {
+	runner.Monitor
+	webhook *Webhook
+	runID   string
+	name    string
+}
+
+func (w *webhookMonitor) Event(event runner.Event) {
+	if event.Type == runner.EventTypeCallStart && event.CallContext != nil {
+		w.webhook.send(WebhookEvent{
+			LogEntry: LogEntry{
+				RunID: w.runID,
+				Event: w.webhook.redaction.Apply(event),
+			},
+			Text: fmt.Sprintf("Run %s of %s called tool %s", w.runID, w.name, event.CallContext.Tool.Name),
+		})
+	}
+	w.Monitor.Event(event)
+}
+
+func (w *webhookMonitor) Stop(output string, err error) {
+	event := WebhookEvent{
+		LogEntry: LogEntry{
+			RunID: w.runID,
+			Event: runner.Event{
+				Type: LogTypeRunFinish,
+			},
+			Output: output,
+		},
+		Text: fmt.Sprintf("Run %s of %s finished", w.runID, w.name),
+	}
+	if w.webhook.redaction == RedactBodies {
+		event.Output = redact(output)
+	}
+	if err != nil {
+		event.Err = err.Error()
+		event.Text = fmt.Sprintf("Run %s of %s failed: %v", w.runID, w.name, err)
+	}
+	w.webhook.send(event)
+	w.webhook.flush()
+	w.Monitor.Stop(output, err)
+}
+
+// Confirm returns a confirmation that asks with c, and posts the confirmations that are needed to the webhook before
+// it asks.
+func (w *Webhook) Confirm(c confirm.Confirm) confirm.Confirm {
+	return webhookConfirm{
+		confirm: c,
+		webhook: w,
+	}
+}
+
+type webhookConfirm struct {
+	confirm confirm.Confirm
+	webhook *Webhook
+}
+
+func (w webhookConfirm) notify(ctx context.Context, prompt, arguments string) {
+	request := LogConfirm{
+		Prompt:    prompt,
+		Arguments: arguments,
+	}
+	if callCtx, ok := engine.FromContext(ctx); ok {
+		request.CallID = callCtx.ID
+		request.ToolName = callCtx.Tool.Name
+	}
+	if w.webhook.redaction.redacts() {
+		request.Prompt = redact(request.Prompt)
+		request.Arguments = redact(request.Arguments)
+	}
+	w.webhook.send(WebhookEvent{
+		LogEntry: LogEntry{
+			Event: runner.Event{
+				Type: LogTypeConfirmRequest,
+			},
+			Confirm: &request,
+		},
+		Text: fmt.Sprintf("Confirmation needed: %s", request.Prompt),
+	})
+}
+
+func (w webhookConfirm) Confirm(ctx context.Context, prompt string) error {
+	w.notify(ctx, prompt, "")
+	return w.confirm.Confirm(ctx, prompt)
+}
+
+func (w webhookConfirm) Edit(ctx context.Context, prompt, arguments string) (string, error) {
+	w.notify(ctx, prompt, arguments)
+	if editor, ok := w.confirm.(confirm.Editor); ok {
+		return editor.Edit(ctx, prompt, arguments)
+	}
+	return arguments, w.confirm.Confirm(ctx, prompt)
+}
@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receiver struct {
+	lock     sync.Mutex
+	events   []WebhookEvent
+	failures int
+	secret   string
+	invalid  int
+}
+
+func (r *receiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	mac := hmac.New(sha256.New, []byte(r.secret))
+	mac.Write(body)
+	if req.Header.Get(WebhookSignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		r.invalid++
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err == nil {
+		r.events = append(r.events, event)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	r := &receiver{failures: 1, secret: "secret"}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	w, err := NewWebhook(srv.URL, "secret", RedactNone)
+	require.NoError(t, err)
+	w.retryWait = 0
+
+	prg := &types.Program{
+		Name:        "deploy.gpt",
+		EntryToolID: "main",
+		ToolSet: types.ToolSet{
+			"main":  {Parameters: types.Parameters{Name: "deploy"}},
+			"write": {Parameters: types.Parameters{Name: "write"}},
+		},
+	}
+	inner := &nopFactory{}
+	m, err := w.Wrap(inner).Start(context.Background(), prg, nil, "staging")
+	require.NoError(t, err)
+
+	callCtx := engine.NewContext(context.Background(), prg)
+	subCtx, err := callCtx.SubCall(context.Background(), "write", "call_1", engine.NoCategory)
+	require.NoError(t, err)
+	m.Event(runner.Event{Type: runner.EventTypeCallStart, CallContext: subCtx.GetCallContext()})
+	m.Event(runner.Event{Type: runner.EventTypeCallProgress, CallContext: subCtx.GetCallContext(), Content: "progress"})
+	assert.Error(t, w.Confirm(denyConfirm{}).Confirm(subCtx.WrappedContext(), "Overwrite: file"))
+	m.Stop("", errors.New("failed"))
+
+	// The other sinks get every event
+	assert.Len(t, inner.events, 2)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	assert.Equal(t, 0, r.invalid)
+	require.Len(t, r.events, 4)
+
+	assert.Equal(t, runner.EventType(LogTypeRunStart), r.events[0].Type)
+	assert.Equal(t, "staging", r.events[0].Input)
+	assert.Equal(t, "Run 1 of deploy started", r.events[0].Text)
+	assert.Equal(t, EventLogVersion, r.events[0].Version)
+
+	assert.Equal(t, runner.EventTypeCallStart, r.events[1].Type)
+	assert.Equal(t, "Run 1 of deploy called tool write", r.events[1].Text)
+
+	assert.Equal(t, runner.EventType(LogTypeConfirmRequest), r.events[2].Type)
+	assert.Equal(t, "write", r.events[2].Confirm.ToolName)
+	assert.Equal(t, "call_1", r.events[2].Confirm.CallID)
+	assert.Equal(t, "Confirmation needed: Overwrite: file", r.events[2].Text)
+
+	assert.Equal(t, runner.EventType(LogTypeRunFinish), r.events[3].Type)
+	assert.Equal(t, "failed", r.events[3].Err)
+	assert.Equal(t, "Run 1 of deploy failed: failed", r.events[3].Text)
+}
+
+func TestWebhookURL(t *testing.T) {
+	_, err := NewWebhook("hooks.slack.com/services/T000", "", RedactNone)
+	assert.Error(t, err)
+}
@@ -81,6 +81,11 @@ func SetOutput(out io.Writer) {
 	logrus.SetOutput(out)
 }
 
+// Output returns the writer that the logs are written to.
+func Output() io.Writer {
+	return logrus.StandardLogger().Out
+}
+
 type Logger struct {
 	log    *logrus.Logger
 	fields logrus.Fields
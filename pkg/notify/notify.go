@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+// WebhookEnv is the environment variable with the URL that notifications are posted to instead of being shown on the
+// desktop, for runs on servers and in CI.
+const WebhookEnv = "GPTSCRIPT_NOTIFY_WEBHOOK"
+
+// ErrUnavailable is returned when there is no webhook and no command to show desktop notifications with on the OS.
+var ErrUnavailable = errors.New("no desktop notifications are available, set " + WebhookEnv + " to the URL of a webhook or install notify-send")
+
+// Notification is a notification of a run.
+type Notification struct {
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Text is the title and the message together, it is the field that Slack, Mattermost and Discord compatible
+	// webhooks show.
+	Text string `json:"text,omitempty"`
+}
+
+// Send posts the notification to the webhook of GPTSCRIPT_NOTIFY_WEBHOOK, or shows it on the desktop if it is not set.
+// It returns where the notification was sent.
+func Send(ctx context.Context, title, message string) (string, error) {
+	if url := os.Getenv(WebhookEnv); url != "" {
+		return "webhook", Webhook(ctx, url, title, message)
+	}
+	return "desktop", Desktop(ctx, title, message)
+}
+
+// Webhook posts the notification to the URL as JSON.
+func Webhook(ctx context.Context, url, title, message string) error {
+	text := message
+	if title != "" {
+		text = "*" + title + "*\n" + message
+	}
+
+	body, err := json.Marshal(Notification{
+		Title:   title,
+		Message: message,
+		Text:    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		// The URL of the webhook is a secret for Slack, so it is not in the error
+		return fmt.Errorf("posting the notification to the webhook: %w", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("posting the notification to the webhook: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// Desktop shows the notification on the desktop, with osascript on macOS, PowerShell on Windows and notify-send on
+// Linux.
+func Desktop(ctx context.Context, title, message string) error {
+	if title == "" {
+		title = "GPTScript"
+	}
+
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		args = []string{"osascript", "-e", fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))}
+	case "windows":
+		// The title and message are passed in the environment, so they are never parsed as PowerShell
+		args = []string{"powershell", "-NoProfile", "-Command", `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:GPTSCRIPT_NOTIFY_TITLE)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:GPTSCRIPT_NOTIFY_MESSAGE)) | Out-Null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('GPTScript').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`}
+	default:
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return ErrUnavailable
+		}
+		args = []string{"notify-send", "--app-name=GPTScript", "--", title, message}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "GPTSCRIPT_NOTIFY_TITLE="+title, "GPTSCRIPT_NOTIFY_MESSAGE="+message)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("showing the notification with %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheOnly(t *testing.T) {
+	cacheClient, err := cache.New(cache.Options{
+		CacheDir:  t.TempDir(),
+		CacheOnly: true,
+	})
+	require.NoError(t, err)
+
+	// Nothing listens on the base URL, so a call to the provider fails differently than a request not in the cache
+	c, err := NewClient(Options{
+		APIKey:       "key",
+		BaseURL:      "http://127.0.0.1:1",
+		DefaultModel: "model",
+		Cache:        cacheClient,
+	})
+	require.NoError(t, err)
+
+	request := types.CompletionRequest{
+		Messages: []types.CompletionMessage{{
+			Role:    types.CompletionMessageRoleTypeUser,
+			Content: types.Text("Hello,   world"),
+		}},
+	}
+	status := make(chan types.CompletionStatus, 10)
+
+	_, err = c.Call(context.Background(), request, status)
+	var miss *cache.MissError
+	require.True(t, errors.As(err, &miss), "unexpected error: %v", err)
+	require.Equal(t, `model model, 2 messages, last user message "Hello, world"`, miss.Request)
+
+	require.NoError(t, c.store(context.Background(), miss.Key, []openai.ChatCompletionStreamResponse{{
+		Choices: []openai.ChatCompletionStreamChoice{{
+			Delta: openai.ChatCompletionStreamChoiceDelta{
+				Role:    "assistant",
+				Content: "Hi",
+			},
+		}},
+	}}))
+
+	// Tools with caching off get the recorded response too
+	request.Cache = new(bool)
+	result, err := c.Call(context.Background(), request, status)
+	require.NoError(t, err)
+	require.Equal(t, "Hi", result.String())
+}
+
+func TestCacheOnlyDisabled(t *testing.T) {
+	_, err := cache.New(cache.Options{
+		CacheDir:     t.TempDir(),
+		DisableCache: true,
+		CacheOnly:    true,
+	})
+	require.Error(t, err)
+}
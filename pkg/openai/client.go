@@ -6,8 +6,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"slices"
 	"sort"
@@ -17,7 +17,9 @@ import (
 	openai "github.com/gptscript-ai/chat-completion-client"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/identity"
 	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/trace"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 )
 
@@ -35,23 +37,37 @@ var (
 type Client struct {
 	defaultModel string
 	c            *openai.Client
+	config       openai.ClientConfig
+	apiKey       string
 	cache        *cache.Client
 	invalidAuth  bool
 	cacheKeyBase string
 	setSeed      bool
+	flights      flights
+	limit        RequestLimit
 }
 
+// WaitingForModel is the partial response of a completion until the model starts to respond.
+const WaitingForModel = "Waiting for model response..."
+
 type Options struct {
-	BaseURL      string         `usage:"OpenAI base URL" name:"openai-base-url" env:"OPENAI_BASE_URL"`
-	APIKey       string         `usage:"OpenAI API KEY" name:"openai-api-key" env:"OPENAI_API_KEY"`
-	APIVersion   string         `usage:"OpenAI API Version (for Azure)" name:"openai-api-version" env:"OPENAI_API_VERSION"`
-	APIType      openai.APIType `usage:"OpenAI API Type (valid: OPEN_AI, AZURE, AZURE_AD)" name:"openai-api-type" env:"OPENAI_API_TYPE"`
-	OrgID        string         `usage:"OpenAI organization ID" name:"openai-org-id" env:"OPENAI_ORG_ID"`
-	DefaultModel string         `usage:"Default LLM model to use" default:"gpt-4-turbo-preview"`
-	ConfigFile   string         `usage:"Path to GPTScript config file" name:"config"`
-	SetSeed      bool           `usage:"-"`
-	CacheKey     string         `usage:"-"`
-	Cache        *cache.Client
+	BaseURL          string           `usage:"OpenAI base URL" name:"openai-base-url" env:"OPENAI_BASE_URL"`
+	APIKey           string           `usage:"OpenAI API KEY" name:"openai-api-key" env:"OPENAI_API_KEY"`
+	APIVersion       string           `usage:"OpenAI API Version (for Azure)" name:"openai-api-version" env:"OPENAI_API_VERSION"`
+	APIType          openai.APIType   `usage:"OpenAI API Type (valid: OPEN_AI, AZURE, AZURE_AD)" name:"openai-api-type" env:"OPENAI_API_TYPE"`
+	OrgID            string           `usage:"OpenAI organization ID" name:"openai-org-id" env:"OPENAI_ORG_ID"`
+	DefaultModel     string           `usage:"Default LLM model to use" default:"gpt-4-turbo-preview"`
+	ConfigFile       string           `usage:"Path to GPTScript config file" name:"config"`
+	WorkloadIdentity string           `usage:"Exchange the OIDC token of the CI or cloud runner for provider credentials (valid: azure, gcp, aws)" env:"GPTSCRIPT_WORKLOAD_IDENTITY"`
+	MaxRequestSize   int              `usage:"Maximum size in bytes of the requests to the model provider, 0 for no limit" env:"GPTSCRIPT_MAX_REQUEST_SIZE"`
+	MaxRequestTokens int              `usage:"Maximum number of tokens of the requests to the model provider, counted with the tokenizer of the model, 0 for no limit" env:"GPTSCRIPT_MAX_REQUEST_TOKENS"`
+	RequestPruning   string           `usage:"How the requests larger than --max-request-size or --max-request-tokens are pruned (valid: tool-output, none)" env:"GPTSCRIPT_REQUEST_PRUNING"`
+	Identity         *identity.Source `usage:"-"`
+	SetSeed          bool             `usage:"-"`
+	CacheKey         string           `usage:"-"`
+	Cache            *cache.Client
+	// Transport sends the requests to the provider, http.DefaultTransport if nil
+	Transport http.RoundTripper `usage:"-"`
 }
 
 func complete(opts ...Options) (result Options, err error) {
@@ -65,6 +81,19 @@ func complete(opts ...Options) (result Options, err error) {
 		result.DefaultModel = types.FirstSet(opt.DefaultModel, result.DefaultModel)
 		result.SetSeed = types.FirstSet(opt.SetSeed, result.SetSeed)
 		result.CacheKey = types.FirstSet(opt.CacheKey, result.CacheKey)
+		result.WorkloadIdentity = types.FirstSet(opt.WorkloadIdentity, result.WorkloadIdentity)
+		result.Identity = types.FirstSet(opt.Identity, result.Identity)
+		result.Transport = types.FirstSet(opt.Transport, result.Transport)
+		result.MaxRequestSize = types.FirstSet(opt.MaxRequestSize, result.MaxRequestSize)
+		result.MaxRequestTokens = types.FirstSet(opt.MaxRequestTokens, result.MaxRequestTokens)
+		result.RequestPruning = types.FirstSet(opt.RequestPruning, result.RequestPruning)
+	}
+
+	if result.Identity == nil && result.WorkloadIdentity != "" {
+		result.Identity, err = identity.New(result.WorkloadIdentity, result.Transport)
+		if err != nil {
+			return result, err
+		}
 	}
 
 	if result.Cache == nil {
@@ -77,7 +106,7 @@ func complete(opts ...Options) (result Options, err error) {
 		result.BaseURL = url
 	}
 
-	if result.APIKey == "" && key != "" {
+	if result.APIKey == "" && key != "" && result.Identity == nil {
 		result.APIKey = key
 	}
 
@@ -114,6 +143,23 @@ func NewClient(opts ...Options) (*Client, error) {
 	cfg.APIVersion = types.FirstSet(opt.APIVersion, cfg.APIVersion)
 	cfg.APIType = types.FirstSet(opt.APIType, cfg.APIType)
 
+	// An API key takes precedence over the workload identity
+	transport := opt.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	bearer := opt.Identity != nil && opt.Identity.Bearer() && opt.APIKey == ""
+	if bearer {
+		// The token of the workload is an Azure AD token, not an API key
+		if cfg.APIType == openai.APITypeAzure {
+			cfg.APIType = openai.APITypeAzureAD
+		}
+		transport = opt.Identity.Transport(transport)
+	}
+	cfg.HTTPClient = &http.Client{
+		Transport: trace.Transport(transport),
+	}
+
 	cacheKeyBase := opt.CacheKey
 	if cacheKeyBase == "" {
 		cacheKeyBase = hash.ID(opt.APIKey, opt.BaseURL)
@@ -121,11 +167,18 @@ func NewClient(opts ...Options) (*Client, error) {
 
 	return &Client{
 		c:            openai.NewClientWithConfig(cfg),
+		config:       cfg,
+		apiKey:       opt.APIKey,
 		cache:        opt.Cache,
 		defaultModel: opt.DefaultModel,
 		cacheKeyBase: cacheKeyBase,
-		invalidAuth:  opt.APIKey == "" && opt.BaseURL == "",
+		invalidAuth:  opt.APIKey == "" && opt.BaseURL == "" && !bearer,
 		setSeed:      opt.SetSeed,
+		limit: RequestLimit{
+			MaxSize:   opt.MaxRequestSize,
+			MaxTokens: opt.MaxRequestTokens,
+			Pruning:   opt.RequestPruning,
+		},
 	}, nil
 }
 
@@ -191,11 +244,15 @@ func (c *Client) seed(request openai.ChatCompletionRequest) int {
 	return hash.Seed(newRequest)
 }
 
+// cacheable returns false if the response to the request should not be reused, like for tools with caching off that
+// expect a different response every time.
+func cacheable(ctx context.Context, messageRequest types.CompletionRequest) bool {
+	return !cache.IsNoCache(ctx) && (messageRequest.Cache == nil || *messageRequest.Cache)
+}
+
 func (c *Client) fromCache(ctx context.Context, messageRequest types.CompletionRequest, request openai.ChatCompletionRequest) (result []openai.ChatCompletionStreamResponse, _ bool, _ error) {
-	if cache.IsNoCache(ctx) {
-		return nil, false, nil
-	}
-	if messageRequest.Cache != nil && !*messageRequest.Cache {
+	// The responses to every request are stored, so with --cache-only they are replayed even for tools with caching off
+	if !cacheable(ctx, messageRequest) && !c.cache.Only() {
 		return nil, false, nil
 	}
 
@@ -213,6 +270,27 @@ func (c *Client) fromCache(ctx context.Context, messageRequest types.CompletionR
 	return result, true, json.NewDecoder(gz).Decode(&result)
 }
 
+// describeRequest returns the model and the last message of a request, for the errors of requests that are not in the
+// cache.
+func describeRequest(request openai.ChatCompletionRequest) string {
+	result := fmt.Sprintf("model %s, %d messages", request.Model, len(request.Messages))
+	if len(request.Messages) == 0 {
+		return result
+	}
+
+	last := request.Messages[len(request.Messages)-1]
+	content := last.Content
+	for _, part := range last.MultiContent {
+		content += part.Text
+	}
+	if runes := []rune(strings.Join(strings.Fields(content), " ")); len(runes) > 100 {
+		content = string(runes[:100]) + "..."
+	} else {
+		content = string(runes)
+	}
+	return fmt.Sprintf("%s, last %s message %q", result, last.Role, content)
+}
+
 func toToolCall(call types.CompletionToolCall) openai.ToolCall {
 	return openai.ToolCall{
 		ID:   call.ID,
@@ -339,6 +417,17 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 	}
 
 	id := fmt.Sprint(atomic.AddInt64(&completionID, 1))
+	pruned, err := c.limit.prune(&request)
+	if err != nil {
+		return nil, err
+	}
+	if pruned != "" {
+		status <- types.CompletionStatus{
+			CompletionID:  id,
+			RequestPruned: pruned,
+		}
+	}
+
 	status <- types.CompletionStatus{
 		CompletionID: id,
 		Request:      request,
@@ -351,6 +440,22 @@ func (c *Client) Call(ctx context.Context, messageRequest types.CompletionReques
 	response, ok, err := c.fromCache(ctx, messageRequest, request)
 	if err != nil {
 		return nil, err
+	} else if !ok && c.cache.Only() {
+		return nil, &cache.MissError{
+			Key:     c.cacheKey(request),
+			Request: describeRequest(request),
+		}
+	} else if !ok && cacheable(ctx, messageRequest) {
+		var shared bool
+		response, shared, err = c.flights.do(ctx, c.cacheKey(request), func() ([]openai.ChatCompletionStreamResponse, error) {
+			return c.call(ctx, request, id, status)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if shared {
+			slog.Debug("shared the response of an identical request in flight", "completion", id)
+		}
 	} else if !ok {
 		response, err = c.call(ctx, request, id, status)
 		if err != nil {
@@ -470,7 +575,7 @@ func (c *Client) call(ctx context.Context, request openai.ChatCompletionRequest,
 		CompletionID: transactionID,
 		PartialResponse: &types.CompletionMessage{
 			Role:    types.CompletionMessageRoleTypeAssistant,
-			Content: types.Text("Waiting for model response..."),
+			Content: types.Text(WaitingForModel),
 		},
 	}
 
@@ -503,32 +608,11 @@ func (c *Client) call(ctx context.Context, request openai.ChatCompletionRequest,
 		}, nil
 	}
 
-	stream, err := c.c.CreateChatCompletionStream(ctx, request)
+	responses, err := c.resumeStream(ctx, request, transactionID, partial)
 	if err != nil {
 		return nil, err
 	}
-	defer stream.Close()
-
-	var partialMessage types.CompletionMessage
-	for {
-		response, err := stream.Recv()
-		if err == io.EOF {
-			return responses, c.store(ctx, cacheKey, responses)
-		} else if err != nil {
-			return nil, err
-		}
-		if len(response.Choices) > 0 {
-			slog.Debug("stream", "content", response.Choices[0].Delta.Content)
-		}
-		if partial != nil {
-			partialMessage = appendMessage(partialMessage, response)
-			partial <- types.CompletionStatus{
-				CompletionID:    transactionID,
-				PartialResponse: &partialMessage,
-			}
-		}
-		responses = append(responses, response)
-	}
+	return responses, c.store(ctx, cacheKey, responses)
 }
 
 func ptr[T any](v T) *T {
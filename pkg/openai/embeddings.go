@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+)
+
+type embeddingsRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Embed returns the embeddings of the input texts, from the embeddings API of the provider.
+func (c *Client) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	if err := c.ValidAuth(); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(embeddingsRequest{
+		Model: model,
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimRight(c.config.BaseURL, "/") + "/embeddings"
+	if c.config.APIType == openai.APITypeAzure || c.config.APIType == openai.APITypeAzureAD {
+		url = fmt.Sprintf("%s/openai/deployments/%s/embeddings?api-version=%s", strings.TrimRight(c.config.BaseURL, "/"),
+			c.config.GetAzureDeploymentByModel(model), c.config.APIVersion)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIType == openai.APITypeAzure {
+		req.Header.Set(openai.AzureAPIKeyHeader, c.apiKey)
+	} else if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.config.OrgID != "" {
+		req.Header.Set("OpenAI-Organization", c.config.OrgID)
+	}
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result embeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && resp.StatusCode < 400 {
+		return nil, fmt.Errorf("invalid embeddings response of model %s: %w", model, err)
+	}
+	if resp.StatusCode >= 400 {
+		if result.Error != nil && result.Error.Message != "" {
+			return nil, fmt.Errorf("failed to embed with model %s: %s", model, result.Error.Message)
+		}
+		return nil, fmt.Errorf("failed to embed with model %s: %s", model, resp.Status)
+	}
+
+	embeddings := make([][]float32, len(input))
+	for _, data := range result.Data {
+		if data.Index < 0 || data.Index >= len(embeddings) {
+			return nil, fmt.Errorf("invalid embeddings response of model %s: index %d out of range", model, data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,43 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbed(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+
+		var req embeddingsRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Model != "text-embedding-3-small" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"message": "model not found"}}`))
+			return
+		}
+		// The embeddings are returned out of order, with their index
+		_, _ = w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer s.Close()
+
+	c, err := NewClient(Options{
+		BaseURL: s.URL + "/v1",
+		APIKey:  "key",
+	})
+	require.NoError(t, err)
+
+	embeddings, err := c.Embed(context.Background(), "text-embedding-3-small", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, embeddings)
+
+	_, err = c.Embed(context.Background(), "other", []string{"a"})
+	assert.ErrorContains(t, err, "model not found")
+}
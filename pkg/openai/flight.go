@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+)
+
+// flights collapses identical requests that are in flight at the same time into one call to the provider, so
+// parallel subtasks sending the same request share the response instead of paying for it more than once.
+type flights struct {
+	lock    sync.Mutex
+	pending map[string]*flight
+}
+
+type flight struct {
+	done      chan struct{}
+	responses []openai.ChatCompletionStreamResponse
+	err       error
+}
+
+// do calls fn, unless a call with the same key is already in flight, in which case its result is returned once it
+// is done. If the call in flight is canceled by its caller, fn is called for the callers that are still waiting.
+func (f *flights) do(ctx context.Context, key string, fn func() ([]openai.ChatCompletionStreamResponse, error)) ([]openai.ChatCompletionStreamResponse, bool, error) {
+	f.lock.Lock()
+	if existing, ok := f.pending[key]; ok {
+		f.lock.Unlock()
+		select {
+		case <-existing.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if errors.Is(existing.err, context.Canceled) || errors.Is(existing.err, context.DeadlineExceeded) {
+			responses, err := fn()
+			return responses, false, err
+		}
+		return existing.responses, true, existing.err
+	}
+
+	current := &flight{
+		done: make(chan struct{}),
+	}
+	if f.pending == nil {
+		f.pending = map[string]*flight{}
+	}
+	f.pending[key] = current
+	f.lock.Unlock()
+
+	defer func() {
+		f.lock.Lock()
+		delete(f.pending, key)
+		f.lock.Unlock()
+		close(current.done)
+	}()
+
+	current.responses, current.err = fn()
+	return current.responses, false, current.err
+}
@@ -0,0 +1,98 @@
+package openai
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlights(t *testing.T) {
+	var (
+		f       flights
+		calls   atomic.Int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+		shared  atomic.Int32
+	)
+
+	fn := func() ([]openai.ChatCompletionStreamResponse, error) {
+		calls.Add(1)
+		<-release
+		return []openai.ChatCompletionStreamResponse{{ID: "response"}}, nil
+	}
+
+	// The leader is in flight before the followers start
+	started := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, err := f.do(context.Background(), "key", func() ([]openai.ChatCompletionStreamResponse, error) {
+			close(started)
+			return fn()
+		})
+		require.NoError(t, err)
+	}()
+	<-started
+
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses, ok, err := f.do(context.Background(), "key", fn)
+			require.NoError(t, err)
+			require.Equal(t, "response", responses[0].ID)
+			if ok {
+				shared.Add(1)
+			}
+		}()
+	}
+
+	// Give the followers time to wait on the leader
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// Every caller either called fn or shared the response of a call in flight
+	require.Equal(t, int32(4), calls.Load()+shared.Load())
+	require.Equal(t, int32(1), calls.Load())
+	require.Empty(t, f.pending)
+}
+
+func TestFlightsCanceledLeader(t *testing.T) {
+	var (
+		f       flights
+		started = make(chan struct{})
+		done    = make(chan struct{})
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer close(done)
+		_, _, err := f.do(ctx, "key", func() ([]openai.ChatCompletionStreamResponse, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	}()
+	<-started
+
+	result := make(chan bool)
+	go func() {
+		responses, shared, err := f.do(context.Background(), "key", func() ([]openai.ChatCompletionStreamResponse, error) {
+			return []openai.ChatCompletionStreamResponse{{ID: "retried"}}, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, "retried", responses[0].ID)
+		result <- shared
+	}()
+
+	cancel()
+	<-done
+	require.False(t, <-result)
+}
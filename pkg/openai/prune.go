@@ -0,0 +1,117 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/tokenizer"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// PruneToolOutput replaces the output of the oldest tool calls of a request that is too large, until it fits.
+	PruneToolOutput = "tool-output"
+	// PruneNone fails requests that are too large.
+	PruneNone = "none"
+)
+
+// RequestLimit is the limit of the size of the requests to a provider, and how the requests that are larger are
+// pruned to fit.
+type RequestLimit struct {
+	// MaxSize is the maximum size in bytes of the serialized request, 0 for no limit
+	MaxSize int
+	// MaxTokens is the maximum number of tokens of the serialized request, counted with the tokenizer of its model,
+	// 0 for no limit
+	MaxTokens int
+	// Pruning is the strategy to prune requests with, PruneToolOutput if it is not set
+	Pruning string
+}
+
+// requestSize is the size of a request, or of a message of a request, in bytes and in tokens of its model.
+type requestSize struct {
+	bytes  int
+	tokens int
+}
+
+func sizeOf(model string, v any) requestSize {
+	data, _ := json.Marshal(v)
+	return requestSize{
+		bytes:  len(data),
+		tokens: tokenizer.Count(model, string(data)),
+	}
+}
+
+// over describes how the request of the size is larger than the limit, or returns nothing if it fits.
+func (l RequestLimit) over(size requestSize) string {
+	if l.MaxSize > 0 && size.bytes > l.MaxSize {
+		return fmt.Sprintf("the request of %d bytes is larger than the limit of %d bytes of the provider", size.bytes, l.MaxSize)
+	}
+	if l.MaxTokens > 0 && size.tokens > l.MaxTokens {
+		return fmt.Sprintf("the request of %d tokens is larger than the limit of %d tokens of the provider", size.tokens, l.MaxTokens)
+	}
+	return ""
+}
+
+// prune prunes the request to fit the limit, and returns a description of what was removed, or nothing if the request
+// fits.
+func (l RequestLimit) prune(request *openai.ChatCompletionRequest) (string, error) {
+	if l.MaxSize <= 0 && l.MaxTokens <= 0 {
+		return "", nil
+	}
+
+	size := sizeOf(request.Model, request)
+	over := l.over(size)
+	if over == "" {
+		return "", nil
+	}
+
+	switch l.Pruning {
+	case "", PruneToolOutput:
+	case PruneNone:
+		return "", errors.New(over)
+	default:
+		return "", fmt.Errorf("invalid request pruning strategy %q (valid: %s, %s)", l.Pruning, PruneToolOutput, PruneNone)
+	}
+
+	var (
+		pruned  int
+		removed requestSize
+		newSize = size
+	)
+	// The messages are in the order of the conversation, so the output of the oldest tool calls is removed first
+	for i, msg := range request.Messages {
+		if l.over(newSize) == "" {
+			break
+		}
+		if msg.Role != string(types.CompletionMessageRoleTypeTool) {
+			continue
+		}
+
+		before := sizeOf(request.Model, msg)
+		content := msg.Content
+		for _, part := range msg.MultiContent {
+			content += part.Text
+		}
+		msg.Content = fmt.Sprintf("[The output of this tool call, %d bytes, was removed to fit the size limit of the request]", len(content))
+		msg.MultiContent = nil
+		after := sizeOf(request.Model, msg)
+		if before.bytes <= after.bytes && before.tokens <= after.tokens {
+			continue
+		}
+
+		request.Messages[i] = msg
+		newSize.bytes -= before.bytes - after.bytes
+		newSize.tokens -= before.tokens - after.tokens
+		removed.bytes += before.bytes - after.bytes
+		removed.tokens += before.tokens - after.tokens
+		pruned++
+	}
+
+	if over := l.over(newSize); over != "" {
+		return "", fmt.Errorf("%s, even without the output of %d tool calls", over, pruned)
+	}
+	return fmt.Sprintf("removed the output of %d tool calls, %d bytes and %d tokens, to fit the request of %d bytes and %d tokens in the limit of the provider",
+		pruned, removed.bytes, removed.tokens, size.bytes, size.tokens), nil
+}
@@ -0,0 +1,72 @@
+package openai
+
+import (
+	"strings"
+	"testing"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimitPrune(t *testing.T) {
+	newRequest := func() openai.ChatCompletionRequest {
+		return openai.ChatCompletionRequest{
+			Model: "model",
+			Messages: []openai.ChatCompletionMessage{
+				{Role: "system", Content: "You are a helpful assistant"},
+				{Role: "user", Content: "Summarize the files"},
+				{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("a", 1000)},
+				{Role: "tool", ToolCallID: "call_2", Content: strings.Repeat("b", 1000)},
+				{Role: "tool", ToolCallID: "call_3", Content: "short"},
+			},
+		}
+	}
+
+	request := newRequest()
+	pruned, err := RequestLimit{}.prune(&request)
+	require.NoError(t, err)
+	assert.Empty(t, pruned)
+	assert.Equal(t, newRequest(), request)
+
+	// Only the oldest tool output is removed when that is enough
+	pruned, err = RequestLimit{MaxSize: 1500}.prune(&request)
+	require.NoError(t, err)
+	assert.Contains(t, pruned, "removed the output of 1 tool calls")
+	assert.Contains(t, request.Messages[2].Content, "1000 bytes, was removed")
+	assert.Equal(t, strings.Repeat("b", 1000), request.Messages[3].Content)
+	assert.Equal(t, "Summarize the files", request.Messages[1].Content)
+
+	request = newRequest()
+	_, err = RequestLimit{MaxSize: 1500, Pruning: PruneNone}.prune(&request)
+	assert.ErrorContains(t, err, "is larger than the limit of 1500 bytes")
+	assert.Equal(t, newRequest(), request)
+
+	request = newRequest()
+	_, err = RequestLimit{MaxSize: 100}.prune(&request)
+	assert.ErrorContains(t, err, "even without the output of 2 tool calls")
+}
+
+func TestRequestLimitPruneTokens(t *testing.T) {
+	request := openai.ChatCompletionRequest{
+		Model: "claude-3-5-sonnet",
+		Messages: []openai.ChatCompletionMessage{
+			{Role: "user", Content: "Summarize the files"},
+			{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("a ", 500)},
+			{Role: "tool", ToolCallID: "call_2", Content: "short"},
+		},
+	}
+
+	// The tokens of claude are counted at 3.5 characters per token, not 4
+	size := sizeOf(request.Model, request)
+	assert.Greater(t, size.tokens, size.bytes/4)
+
+	_, err := RequestLimit{MaxTokens: 100, Pruning: PruneNone}.prune(&request)
+	assert.ErrorContains(t, err, "is larger than the limit of 100 tokens")
+
+	pruned, err := RequestLimit{MaxTokens: 100}.prune(&request)
+	require.NoError(t, err)
+	assert.Contains(t, pruned, "removed the output of 1 tool calls")
+	assert.Contains(t, request.Messages[1].Content, "1000 bytes, was removed")
+	assert.Equal(t, "short", request.Messages[2].Content)
+}
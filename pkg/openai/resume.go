@@ -0,0 +1,134 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// maxStreamResumes is the number of times a streamed response that drops is resumed before the call fails
+	maxStreamResumes = 3
+	// resumePrompt asks the model to continue a response that was cut off, after the part of it that was received
+	resumePrompt = "Your last response was cut off. Continue it from exactly where it stopped, without repeating any of it or adding anything before it."
+)
+
+// stream streams the response to the request, adding its chunks to the partial message and sending it as they are
+// received. The partial message is not sent while its text is a part of the text that was sent last, emitted, like
+// when the response starts over after it dropped, so the text is not streamed twice. When the stream fails after it
+// started, like when the connection drops, it returns the chunks that were received and that it dropped.
+func (c *Client) stream(ctx context.Context, request openai.ChatCompletionRequest, transactionID string, partial chan<- types.CompletionStatus, partialMessage *types.CompletionMessage, emitted *string) (responses []openai.ChatCompletionStreamResponse, dropped bool, _ error) {
+	stream, err := c.c.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, false, err
+	}
+	defer stream.Close()
+
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			return responses, false, nil
+		} else if err != nil {
+			var apiErr *openai.APIError
+			return responses, !errors.As(err, &apiErr), err
+		}
+		if len(response.Choices) > 0 {
+			slog.Debug("stream", "content", response.Choices[0].Delta.Content)
+		}
+		*partialMessage = appendMessage(*partialMessage, response)
+		if text := partialMessage.String(); partial != nil && (*emitted == "" || !strings.HasPrefix(*emitted, text)) {
+			*emitted = text
+			partial <- types.CompletionStatus{
+				CompletionID:    transactionID,
+				PartialResponse: snapshot(*partialMessage),
+			}
+		}
+		responses = append(responses, response)
+	}
+}
+
+// snapshot returns a copy of the message that the chunks appended to it later do not change.
+func snapshot(msg types.CompletionMessage) *types.CompletionMessage {
+	msg.Content = slices.Clone(msg.Content)
+	for i, content := range msg.Content {
+		if content.ToolCall != nil {
+			toolCall := *content.ToolCall
+			msg.Content[i].ToolCall = &toolCall
+		}
+	}
+	return &msg
+}
+
+// resumeRequest returns the request that continues the response to the request after the partial response that was
+// received before the stream dropped, and false if the response starts over instead, since it has no text yet, or it
+// has tool calls whose arguments can not be continued.
+func resumeRequest(request openai.ChatCompletionRequest, partialMessage types.CompletionMessage) (openai.ChatCompletionRequest, bool) {
+	text := partialMessage.String()
+	if text == "" {
+		return request, false
+	}
+	for _, content := range partialMessage.Content {
+		if content.ToolCall != nil {
+			return request, false
+		}
+	}
+
+	request.Messages = append(request.Messages[:len(request.Messages):len(request.Messages)],
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: text,
+		},
+		openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: resumePrompt,
+		})
+	return request, true
+}
+
+// resumeStream streams the response to the request, and resumes it when the stream drops, with the partial response
+// that was received, or starts it over if it can not be continued.
+func (c *Client) resumeStream(ctx context.Context, request openai.ChatCompletionRequest, transactionID string, partial chan<- types.CompletionStatus) (responses []openai.ChatCompletionStreamResponse, _ error) {
+	var (
+		partialMessage types.CompletionMessage
+		next           = request
+		emitted        string
+	)
+	for resumes := 0; ; resumes++ {
+		chunks, dropped, err := c.stream(ctx, next, transactionID, partial, &partialMessage, &emitted)
+		responses = append(responses, chunks...)
+		if err == nil {
+			return responses, nil
+		} else if !dropped || ctx.Err() != nil {
+			return nil, err
+		} else if resumes == maxStreamResumes {
+			return nil, fmt.Errorf("the response stream dropped %d times, last: %w", resumes+1, err)
+		}
+
+		var (
+			continued bool
+			recovery  string
+		)
+		next, continued = resumeRequest(request, partialMessage)
+		if continued {
+			recovery = fmt.Sprintf("the response stream dropped after %d chunks (%v), continuing it from the partial response", len(responses), err)
+		} else {
+			recovery = fmt.Sprintf("the response stream dropped after %d chunks (%v), starting it over", len(responses), err)
+			responses = nil
+			partialMessage = types.CompletionMessage{}
+		}
+		slog.Debug("resuming stream", "completion", transactionID, "recovery", recovery)
+		if partial != nil {
+			partial <- types.CompletionStatus{
+				CompletionID:  transactionID,
+				StreamResumed: recovery,
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openai "github.com/gptscript-ai/chat-completion-client"
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeStream(t *testing.T) {
+	var requests []openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var request openai.ChatCompletionRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&request))
+		requests = append(requests, request)
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		chunk := func(content string) {
+			data, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+				Choices: []openai.ChatCompletionStreamChoice{{
+					Delta: openai.ChatCompletionStreamChoiceDelta{
+						Role:    openai.ChatMessageRoleAssistant,
+						Content: content,
+					},
+				}},
+			})
+			_, _ = fmt.Fprintf(rw, "data: %s\n\n", data)
+		}
+
+		if len(requests) == 1 {
+			chunk("Hello, ")
+			rw.(http.Flusher).Flush()
+			// Drop the connection in the middle of the response
+			panic(http.ErrAbortHandler)
+		}
+		chunk("world")
+		_, _ = fmt.Fprint(rw, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	cacheClient, err := cache.New(cache.Options{
+		CacheDir:     t.TempDir(),
+		DisableCache: true,
+	})
+	require.NoError(t, err)
+	c, err := NewClient(Options{
+		APIKey:       "key",
+		BaseURL:      server.URL,
+		DefaultModel: "model",
+		Cache:        cacheClient,
+	})
+	require.NoError(t, err)
+
+	status := make(chan types.CompletionStatus, 100)
+	result, err := c.Call(context.Background(), types.CompletionRequest{
+		Messages: []types.CompletionMessage{{
+			Role:    types.CompletionMessageRoleTypeUser,
+			Content: types.Text("Say hello"),
+		}},
+	}, status)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world", result.String())
+
+	require.Len(t, requests, 2)
+	resumed := requests[1].Messages
+	require.Len(t, resumed, len(requests[0].Messages)+2)
+	assert.Equal(t, "Hello, ", resumed[len(resumed)-2].Content)
+	assert.Equal(t, resumePrompt, resumed[len(resumed)-1].Content)
+
+	close(status)
+	var recoveries []string
+	for s := range status {
+		if s.StreamResumed != "" {
+			recoveries = append(recoveries, s.StreamResumed)
+		}
+	}
+	assert.Len(t, recoveries, 1)
+}
+
+func TestResumeRequestToolCalls(t *testing.T) {
+	request := openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+	}
+	_, continued := resumeRequest(request, types.CompletionMessage{
+		Content: []types.ContentPart{{ToolCall: &types.CompletionToolCall{}}},
+	})
+	assert.False(t, continued, "tool calls start over")
+}
+
+func TestResumeStreamStartOver(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		rw.Header().Set("Content-Type", "text/event-stream")
+		chunk := func(delta openai.ChatCompletionStreamChoiceDelta) {
+			delta.Role = openai.ChatMessageRoleAssistant
+			data, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+				Choices: []openai.ChatCompletionStreamChoice{{Delta: delta}},
+			})
+			_, _ = fmt.Fprintf(rw, "data: %s\n\n", data)
+		}
+		toolCall := func(name, arguments string) openai.ChatCompletionStreamChoiceDelta {
+			return openai.ChatCompletionStreamChoiceDelta{
+				ToolCalls: []openai.ToolCall{{
+					Index:    ptr(1),
+					ID:       "call_1",
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: name, Arguments: arguments},
+				}},
+			}
+		}
+
+		chunk(openai.ChatCompletionStreamChoiceDelta{Content: "Let me check. "})
+		chunk(toolCall("weather", `{"city":`))
+		if requests == 1 {
+			rw.(http.Flusher).Flush()
+			// Drop the connection in the middle of the tool call, the response starts over
+			panic(http.ErrAbortHandler)
+		}
+		chunk(toolCall("", `"Paris"}`))
+		_, _ = fmt.Fprint(rw, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	cacheClient, err := cache.New(cache.Options{
+		CacheDir:     t.TempDir(),
+		DisableCache: true,
+	})
+	require.NoError(t, err)
+	c, err := NewClient(Options{
+		APIKey:       "key",
+		BaseURL:      server.URL,
+		DefaultModel: "model",
+		Cache:        cacheClient,
+	})
+	require.NoError(t, err)
+
+	status := make(chan types.CompletionStatus, 100)
+	result, err := c.Call(context.Background(), types.CompletionRequest{
+		Messages: []types.CompletionMessage{{
+			Role:    types.CompletionMessageRoleTypeUser,
+			Content: types.Text("What is the weather in Paris?"),
+		}},
+	}, status)
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	close(status)
+	var partials []string
+	for s := range status {
+		if s.PartialResponse != nil && s.PartialResponse.String() != WaitingForModel {
+			partials = append(partials, s.PartialResponse.String())
+		}
+	}
+
+	// Each partial response continues the one before it, the text that was sent before the stream dropped is not
+	// sent again
+	require.Len(t, partials, 3)
+	for i := 1; i < len(partials); i++ {
+		assert.True(t, strings.HasPrefix(partials[i], partials[i-1]), "%q does not continue %q", partials[i], partials[i-1])
+	}
+	assert.Equal(t, result.String(), partials[len(partials)-1])
+}
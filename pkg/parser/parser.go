@@ -84,10 +84,18 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 		tool.Parameters.ModelProvider = true
 	case "model", "modelname":
 		tool.Parameters.ModelName = value
+	case "draftmodel", "draftmodelname":
+		tool.Parameters.DraftModelName = value
 	case "globalmodel", "globalmodelname":
 		tool.Parameters.GlobalModelName = value
 	case "description":
 		tool.Parameters.Description = value
+	case "usage":
+		// Usage is long-form, every usage line of the tool adds a line to it
+		if tool.Parameters.Usage != "" {
+			tool.Parameters.Usage += "\n"
+		}
+		tool.Parameters.Usage += value
 	case "internalprompt":
 		v, err := toBool(value)
 		if err != nil {
@@ -130,6 +138,16 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 		if err != nil {
 			return false, err
 		}
+	case "outputformat":
+		tool.Parameters.OutputFormat = strings.ToLower(value)
+		if tool.Parameters.OutputFormat != "text" && tool.Parameters.OutputFormat != "json" {
+			return false, fmt.Errorf("invalid output format, must be \"text\" or \"json\", got [%s]", value)
+		}
+	case "outputschema":
+		tool.Parameters.OutputSchema, err = toBool(value)
+		if err != nil {
+			return false, err
+		}
 	case "temperature":
 		tool.Parameters.Temperature, err = toFloatPtr(value)
 		if err != nil {
@@ -137,6 +155,32 @@ func isParam(line string, tool *types.Tool) (_ bool, err error) {
 		}
 	case "credentials", "creds", "credential", "cred":
 		tool.Parameters.Credentials = append(tool.Parameters.Credentials, csv(strings.ToLower(value))...)
+	case "container", "image":
+		tool.Parameters.Container = value
+	case "runtime":
+		tool.Parameters.Runtime = value
+	case "sandbox":
+		tool.Parameters.Sandbox = append(tool.Parameters.Sandbox, csv(value)...)
+	case "allowedhosts", "hosts":
+		tool.Parameters.AllowedHosts = append(tool.Parameters.AllowedHosts, csv(strings.ToLower(value))...)
+	case "allowedpaths", "paths":
+		tool.Parameters.AllowedPaths = append(tool.Parameters.AllowedPaths, csv(value)...)
+	case "inputfrom", "stdin":
+		tool.Parameters.InputFrom = strings.ToLower(value)
+	case "validator", "validatewith":
+		tool.Parameters.Validator = strings.ToLower(value)
+	case "maxattempts", "attempts":
+		tool.Parameters.MaxAttempts, err = strconv.Atoi(value)
+		if err != nil {
+			return false, err
+		}
+	case "shardwith":
+		tool.Parameters.ShardWith = strings.ToLower(value)
+	case "shardsize":
+		tool.Parameters.ShardSize, err = strconv.Atoi(value)
+		if err != nil {
+			return false, err
+		}
 	default:
 		return false, nil
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/hexops/autogold/v2"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -110,3 +111,19 @@ name: seven
 		},
 	}).Equal(t, out)
 }
+
+func TestParseUsage(t *testing.T) {
+	var input = `
+name: greet
+description: Greets a person
+usage: Pass the full name of the person.
+usage: Greet each person only once.
+
+Say hello
+`
+	out, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	assert.Equal(t, "Pass the full name of the person.\nGreet each person only once.", out[0].Usage)
+	assert.Contains(t, out[0].String(), "Usage: Pass the full name of the person.\nUsage: Greet each person only once.\n")
+}
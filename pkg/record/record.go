@@ -0,0 +1,293 @@
+// Package record records the responses of models and the outputs of tools of runs, and replays runs from their
+// recordings, so that a run can be run again exactly like it was, like to debug a flaky script or in a regression
+// test.
+package record
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// The types of the entries of recordings.
+const (
+	TypeModel = "model"
+	TypeTool  = "tool"
+)
+
+// Entry is a line of a recording: a response of a model to a tool, or the output of a tool, or the error of either.
+// A recording is written as the run goes, an entry per line, so the recording of a run that crashed has everything
+// until it crashed.
+type Entry struct {
+	Type string `json:"type"`
+	// Tool is the name of the tool that called the model, or that output Output
+	Tool string `json:"tool"`
+	// Key identifies the request to the model or the input of the tool, see requestKey and toolKey
+	Key      string                   `json:"key"`
+	Response *types.CompletionMessage `json:"response,omitempty"`
+	Output   *string                  `json:"output,omitempty"`
+	// Error is the error the model or the tool failed with, it is returned again when the entry is replayed
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder writes the recordings of the runs made with a context of WithRecorder.
+type Recorder struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{
+		out: out,
+	}
+}
+
+func (r *Recorder) write(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, err = r.out.Write(append(data, '\n'))
+	return err
+}
+
+// Replay replays the runs made with a context of WithReplay from a recording. The responses and outputs of the
+// recording are used once each, the one of the same request or input first, and else the next one of the same tool,
+// so a run still replays when the requests differ a little, like the paths of the tools.
+type Replay struct {
+	lock    sync.Mutex
+	entries []Entry
+	used    []bool
+}
+
+// NewReplay reads a recording that was written by a Recorder.
+func NewReplay(in io.Reader) (*Replay, error) {
+	var (
+		result  = &Replay{}
+		scanner = bufio.NewScanner(in)
+	)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d of the recording: %w", line, err)
+		}
+		result.entries = append(result.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	result.used = make([]bool, len(result.entries))
+	return result, nil
+}
+
+// Load reads the recording of the file.
+func Load(file string) (*Replay, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplay(f)
+}
+
+// next returns the entry of the request or input of the key, or else the next one of the tool, and marks it used.
+func (r *Replay) next(entryType, tool, key string) (Entry, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	match := -1
+	for i, entry := range r.entries {
+		if r.used[i] || entry.Type != entryType || entry.Tool != tool {
+			continue
+		}
+		if entry.Key == key {
+			match = i
+			break
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return Entry{}, false
+	}
+	if r.entries[match].Key != key {
+		log.Debugf("Replaying the next %s entry of tool [%s], the recording has none for the same request", entryType, tool)
+	}
+	r.used[match] = true
+	return r.entries[match], true
+}
+
+type recorderKey struct{}
+
+type replayKey struct{}
+
+// WithRecorder returns a context that the responses of the models and the outputs of the tools of the runs made with
+// it are recorded with.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// WithReplay returns a context that the runs made with it are replayed from the recording with, instead of calling
+// models and running tools.
+func WithReplay(ctx context.Context, r *Replay) context.Context {
+	return context.WithValue(ctx, replayKey{}, r)
+}
+
+// requestKey identifies a request to a model. The IDs of the tools are not part of it, since they are the paths of
+// the files of the tools, which are not the same in every checkout.
+func requestKey(request types.CompletionRequest) string {
+	request.Tools = append([]types.CompletionTool(nil), request.Tools...)
+	for i := range request.Tools {
+		request.Tools[i].Function.ToolID = ""
+	}
+	data, _ := json.Marshal(request)
+	return hash(data)
+}
+
+// toolKey identifies the input of a tool.
+func toolKey(input string) string {
+	return hash([]byte(input))
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+var completionID int64
+
+// Model returns the model that the tool calls, which is replayed or recorded if the context has a replay or a
+// recorder.
+func Model(ctx context.Context, tool string, model engine.Model) engine.Model {
+	if replay, ok := ctx.Value(replayKey{}).(*Replay); ok {
+		return replayModel{replay: replay, tool: tool}
+	}
+	if recorder, ok := ctx.Value(recorderKey{}).(*Recorder); ok {
+		return recordModel{Model: model, recorder: recorder, tool: tool}
+	}
+	return model
+}
+
+type replayModel struct {
+	replay *Replay
+	tool   string
+}
+
+func (m replayModel) Call(_ context.Context, request types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	entry, ok := m.replay.next(TypeModel, m.tool, requestKey(request))
+	if !ok || (entry.Response == nil && entry.Error == "") {
+		return nil, fmt.Errorf("the recording has no more responses of the model of tool [%s]", m.tool)
+	}
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+
+	id := fmt.Sprintf("replay-%d", atomic.AddInt64(&completionID, 1))
+	status <- types.CompletionStatus{
+		CompletionID: id,
+		Request:      request,
+	}
+	status <- types.CompletionStatus{
+		CompletionID: id,
+		Response:     *entry.Response,
+		Cached:       true,
+	}
+	return entry.Response, nil
+}
+
+type recordModel struct {
+	engine.Model
+	recorder *Recorder
+	tool     string
+}
+
+func (m recordModel) Call(ctx context.Context, request types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	resp, err := m.Model.Call(ctx, request, status)
+	if err != nil {
+		// The calls that are canceled are not recorded, the replay is canceled by itself
+		if ctx.Err() == nil {
+			if err := m.recorder.write(Entry{
+				Type:  TypeModel,
+				Tool:  m.tool,
+				Key:   requestKey(request),
+				Error: err.Error(),
+			}); err != nil {
+				log.Errorf("failed to record the error of the model of tool [%s]: %v", m.tool, err)
+			}
+		}
+		return nil, err
+	}
+	if err := m.recorder.write(Entry{
+		Type:     TypeModel,
+		Tool:     m.tool,
+		Key:      requestKey(request),
+		Response: resp,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record the response of the model of tool [%s]: %w", m.tool, err)
+	}
+	return resp, nil
+}
+
+// Tool runs the tool with run, or replays its output or error if the context has a replay, and records its output or
+// error if the context has a recorder.
+func Tool(ctx context.Context, tool, input string, run func() (*engine.Return, error)) (*engine.Return, error) {
+	if replay, ok := ctx.Value(replayKey{}).(*Replay); ok {
+		entry, ok := replay.next(TypeTool, tool, toolKey(input))
+		if !ok || (entry.Output == nil && entry.Error == "") {
+			return nil, fmt.Errorf("the recording has no more outputs of tool [%s]", tool)
+		}
+		if entry.Error != "" {
+			return nil, errors.New(entry.Error)
+		}
+		return &engine.Return{
+			Result: entry.Output,
+		}, nil
+	}
+
+	ret, err := run()
+	recorder, recorded := ctx.Value(recorderKey{}).(*Recorder)
+	if err != nil {
+		// The calls that are canceled are not recorded, the replay is canceled by itself
+		if recorded && ctx.Err() == nil {
+			if err := recorder.write(Entry{
+				Type:  TypeTool,
+				Tool:  tool,
+				Key:   toolKey(input),
+				Error: err.Error(),
+			}); err != nil {
+				log.Errorf("failed to record the error of tool [%s]: %v", tool, err)
+			}
+		}
+		return nil, err
+	}
+	if recorded && ret.Result != nil {
+		if err := recorder.write(Entry{
+			Type:   TypeTool,
+			Tool:   tool,
+			Key:    toolKey(input),
+			Output: ret.Result,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to record the output of tool [%s]: %w", tool, err)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,147 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeModel struct {
+	calls int
+}
+
+func (f *fakeModel) Call(context.Context, types.CompletionRequest, chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	f.calls++
+	return &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text("the answer"),
+	}, nil
+}
+
+func drain() chan<- types.CompletionStatus {
+	status := make(chan types.CompletionStatus)
+	go func() {
+		for range status {
+		}
+	}()
+	return status
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var (
+		recording bytes.Buffer
+		model     = &fakeModel{}
+		runs      int
+		request   = types.CompletionRequest{
+			Model:    "gpt-4o",
+			Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("question")}},
+			Tools:    []types.CompletionTool{{Function: types.CompletionFunctionDefinition{ToolID: "/home/me/tool.gpt:lookup", Name: "lookup"}}},
+		}
+		run = func() (*engine.Return, error) {
+			runs++
+			output := "looked up"
+			return &engine.Return{Result: &output}, nil
+		}
+	)
+
+	ctx := WithRecorder(context.Background(), NewRecorder(&recording))
+	resp, err := Model(ctx, "main", model).Call(ctx, request, drain())
+	require.NoError(t, err)
+	assert.Equal(t, "the answer", resp.String())
+	ret, err := Tool(ctx, "lookup", `{"id": 1}`, run)
+	require.NoError(t, err)
+	assert.Equal(t, "looked up", *ret.Result)
+	assert.Equal(t, 1, model.calls)
+	assert.Equal(t, 1, runs)
+
+	replay, err := NewReplay(&recording)
+	require.NoError(t, err)
+	ctx = WithReplay(context.Background(), replay)
+
+	// The paths of the tools are not the same in every checkout
+	request.Tools[0].Function.ToolID = "/ci/build/tool.gpt:lookup"
+	resp, err = Model(ctx, "main", model).Call(ctx, request, drain())
+	require.NoError(t, err)
+	assert.Equal(t, "the answer", resp.String())
+	ret, err = Tool(ctx, "lookup", `{"id": 1}`, run)
+	require.NoError(t, err)
+	assert.Equal(t, "looked up", *ret.Result)
+	assert.Equal(t, 1, model.calls)
+	assert.Equal(t, 1, runs)
+
+	// The responses and outputs are replayed once
+	_, err = Model(ctx, "main", model).Call(ctx, request, drain())
+	assert.ErrorContains(t, err, "no more responses of the model of tool [main]")
+	_, err = Tool(ctx, "lookup", `{"id": 1}`, run)
+	assert.ErrorContains(t, err, "no more outputs of tool [lookup]")
+}
+
+func TestReplayNextOfTool(t *testing.T) {
+	first, second := "first", "second"
+	replay := &Replay{
+		entries: []Entry{
+			{Type: TypeTool, Tool: "lookup", Key: toolKey("a"), Output: &first},
+			{Type: TypeTool, Tool: "lookup", Key: toolKey("b"), Output: &second},
+		},
+		used: make([]bool, 2),
+	}
+
+	// The output of the same input is replayed first, and else the next one of the tool
+	entry, ok := replay.next(TypeTool, "lookup", toolKey("b"))
+	require.True(t, ok)
+	assert.Equal(t, "second", *entry.Output)
+	entry, ok = replay.next(TypeTool, "lookup", toolKey("c"))
+	require.True(t, ok)
+	assert.Equal(t, "first", *entry.Output)
+	_, ok = replay.next(TypeTool, "lookup", toolKey("a"))
+	assert.False(t, ok)
+}
+
+func TestRecordAndReplayErrors(t *testing.T) {
+	var (
+		recording bytes.Buffer
+		runs      int
+		run       = func() (*engine.Return, error) {
+			runs++
+			if runs == 1 {
+				return nil, errors.New("exit status 1: connection refused")
+			}
+			output := "looked up"
+			return &engine.Return{Result: &output}, nil
+		}
+	)
+
+	// The tool fails, and succeeds when the model calls it again with the same input
+	ctx := WithRecorder(context.Background(), NewRecorder(&recording))
+	_, err := Tool(ctx, "lookup", `{"id": 1}`, run)
+	assert.EqualError(t, err, "exit status 1: connection refused")
+	_, err = Tool(ctx, "lookup", `{"id": 1}`, run)
+	require.NoError(t, err)
+
+	replay, err := NewReplay(&recording)
+	require.NoError(t, err)
+	ctx = WithReplay(context.Background(), replay)
+
+	_, err = Tool(ctx, "lookup", `{"id": 1}`, run)
+	assert.EqualError(t, err, "exit status 1: connection refused")
+	ret, err := Tool(ctx, "lookup", `{"id": 1}`, run)
+	require.NoError(t, err)
+	assert.Equal(t, "looked up", *ret.Result)
+	assert.Equal(t, 2, runs)
+
+	// The calls that are canceled are not recorded
+	recording.Reset()
+	canceled, cancel := context.WithCancel(WithRecorder(context.Background(), NewRecorder(&recording)))
+	cancel()
+	_, err = Tool(canceled, "lookup", `{"id": 1}`, func() (*engine.Return, error) {
+		return nil, canceled.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, recording.String())
+}
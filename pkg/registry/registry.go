@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+// DefaultIndex is the index of the tools published on tools.gptscript.ai
+const DefaultIndex = "https://tools.gptscript.ai/index.json"
+
+// Entry is a tool in an index.
+type Entry struct {
+	// Reference is what the tool is referenced with in a tools line, like github.com/gptscript-ai/browser
+	Reference   string `json:"reference"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Params are the descriptions of the arguments of the tool by name
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Index finds tools. Indexes other than a JSON document, like the search API of a registry, implement it to be
+// searched with the same command.
+type Index interface {
+	Search(ctx context.Context, query string) ([]Entry, error)
+}
+
+// document is an index in a JSON document, which is read from a file or a URL and searched locally.
+type document struct {
+	location string
+}
+
+type documentContent struct {
+	Tools []Entry `json:"tools"`
+}
+
+// New returns the index at the location, which is a URL or a file of a JSON document like
+// {"tools": [{"reference": "...", "description": "..."}]}. The default index is used if location is empty.
+func New(location string) Index {
+	if location == "" {
+		location = DefaultIndex
+	}
+	return &document{location: location}
+}
+
+func (d *document) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(d.location, "http://") && !strings.HasPrefix(d.location, "https://") {
+		return os.ReadFile(d.location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error reading tool index %s: %s", d.location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (d *document) Search(ctx context.Context, query string) ([]Entry, error) {
+	data, err := d.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var content documentContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("invalid tool index %s: %w", d.location, err)
+	}
+
+	return Match(content.Tools, query), nil
+}
+
+// Match returns the entries that contain every word of the query, with the entries matching in their name or
+// reference first.
+func Match(entries []Entry, query string) []Entry {
+	var (
+		terms  = strings.Fields(strings.ToLower(query))
+		result []Entry
+		scores = map[string]int{}
+	)
+
+	for _, entry := range entries {
+		var (
+			name       = strings.ToLower(entry.Name)
+			reference  = strings.ToLower(entry.Reference)
+			everything = strings.ToLower(strings.Join(append([]string{entry.Name, entry.Reference, entry.Description}, params(entry)...), " "))
+			score      int
+		)
+
+		matched := !slices.ContainsFunc(terms, func(term string) bool {
+			return !strings.Contains(everything, term)
+		})
+		if !matched {
+			continue
+		}
+
+		for _, term := range terms {
+			if strings.Contains(name, term) {
+				score += 3
+			}
+			if strings.Contains(reference, term) {
+				score += 2
+			}
+		}
+		scores[entry.Reference] = score
+		result = append(result, entry)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if scores[result[i].Reference] != scores[result[j].Reference] {
+			return scores[result[i].Reference] > scores[result[j].Reference]
+		}
+		return result[i].Reference < result[j].Reference
+	})
+	return result
+}
+
+func params(entry Entry) (result []string) {
+	for name, description := range entry.Params {
+		result = append(result, name, description)
+	}
+	return
+}
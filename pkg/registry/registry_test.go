@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"tools": [
+			{"reference": "github.com/example/weather", "name": "weather", "description": "Get the forecast of a city", "params": {"city": "The city"}},
+			{"reference": "github.com/example/news", "name": "news", "description": "Read the news, like the weather report"},
+			{"reference": "github.com/example/calendar", "name": "calendar", "description": "Manage events"}
+		]}`))
+	}))
+	defer s.Close()
+
+	var references []string
+	entries, err := New(s.URL).Search(context.Background(), "Weather")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		references = append(references, entry.Reference)
+	}
+	// Matches in the name come first
+	require.Equal(t, []string{"github.com/example/weather", "github.com/example/news"}, references)
+
+	// Every word has to match, in any field
+	entries, err = New(s.URL).Search(context.Background(), "forecast city")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "The city", entries[0].Params["city"])
+
+	_, err = New("missing.json").Search(context.Background(), "weather")
+	require.Error(t, err)
+}
@@ -3,14 +3,18 @@ package remote
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/gptscript-ai/gptscript/pkg/cache"
 	env2 "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/identity"
 	"github.com/gptscript-ai/gptscript/pkg/loader"
 	"github.com/gptscript-ai/gptscript/pkg/mvl"
 	"github.com/gptscript-ai/gptscript/pkg/openai"
@@ -27,13 +31,19 @@ type Client struct {
 	models      map[string]*openai.Client
 	runner      *runner.Runner
 	envs        []string
+	identity    *identity.Source
+	transport   http.RoundTripper
+	limit       openai.RequestLimit
 }
 
-func New(r *runner.Runner, envs []string, cache *cache.Client) *Client {
+func New(r *runner.Runner, envs []string, cache *cache.Client, identity *identity.Source, transport http.RoundTripper, limit openai.RequestLimit) *Client {
 	return &Client{
-		cache:  cache,
-		runner: r,
-		envs:   envs,
+		cache:     cache,
+		runner:    r,
+		envs:      envs,
+		identity:  identity,
+		transport: transport,
+		limit:     limit,
 	}
 }
 
@@ -102,16 +112,50 @@ func (c *Client) clientFromURL(apiURL string) (*openai.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	env := "GPTSCRIPT_PROVIDER_" + env2.ToEnvLike(parsed.Hostname()) + "_API_KEY"
+	prefix := "GPTSCRIPT_PROVIDER_" + env2.ToEnvLike(parsed.Hostname())
+	env := prefix + "_API_KEY"
 	apiKey := os.Getenv(env)
+
+	// The size limits of the requests to the provider override the limits of all providers
+	maxRequestSize := c.limit.MaxSize
+	if size := os.Getenv(prefix + "_MAX_REQUEST_SIZE"); size != "" {
+		maxRequestSize, err = strconv.Atoi(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s_MAX_REQUEST_SIZE: %w", prefix, err)
+		}
+	}
+	maxRequestTokens := c.limit.MaxTokens
+	if tokens := os.Getenv(prefix + "_MAX_REQUEST_TOKENS"); tokens != "" {
+		maxRequestTokens, err = strconv.Atoi(tokens)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s_MAX_REQUEST_TOKENS: %w", prefix, err)
+		}
+	}
+
+	if apiKey == "" && c.identity != nil && c.identity.Bearer() {
+		// Authenticate with the token of the workload instead
+		return openai.NewClient(openai.Options{
+			BaseURL:          apiURL,
+			Cache:            c.cache,
+			Identity:         c.identity,
+			MaxRequestSize:   maxRequestSize,
+			MaxRequestTokens: maxRequestTokens,
+			RequestPruning:   c.limit.Pruning,
+			Transport:        c.transport,
+		})
+	}
 	if apiKey == "" {
 		log.Warnf("No API key found for %s", env)
 		apiKey = "<unset>"
 	}
 	return openai.NewClient(openai.Options{
-		BaseURL: apiURL,
-		Cache:   c.cache,
-		APIKey:  apiKey,
+		BaseURL:          apiURL,
+		Cache:            c.cache,
+		APIKey:           apiKey,
+		MaxRequestSize:   maxRequestSize,
+		MaxRequestTokens: maxRequestTokens,
+		RequestPruning:   c.limit.Pruning,
+		Transport:        c.transport,
 	})
 }
 
@@ -137,12 +181,25 @@ func (c *Client) load(ctx context.Context, toolName string) (*openai.Client, err
 		return remoteClient, nil
 	}
 
-	prg, err := loader.Program(ctx, toolName, "")
+	prg, err := loader.Program(ctx, toolName, "", loader.Options{
+		Transport: c.transport,
+		Env:       c.envs,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	url, err := c.runner.Run(ctx, prg.SetBlocking(), c.envs, "")
+	envs := c.envs
+	if c.identity != nil {
+		// Provider tools get the credentials of the workload when they start
+		creds, err := c.identity.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		envs = append(slices.Clone(envs), creds.Env...)
+	}
+
+	url, err := c.runner.Run(ctx, prg.SetBlocking(), envs, "")
 	if err != nil {
 		return nil, err
 	}
@@ -154,9 +211,13 @@ func (c *Client) load(ctx context.Context, toolName string) (*openai.Client, err
 	}
 
 	client, err = openai.NewClient(openai.Options{
-		BaseURL:  url,
-		Cache:    c.cache,
-		CacheKey: prg.EntryToolID,
+		BaseURL:          url,
+		Cache:            c.cache,
+		CacheKey:         prg.EntryToolID,
+		MaxRequestSize:   c.limit.MaxSize,
+		MaxRequestTokens: c.limit.MaxTokens,
+		RequestPruning:   c.limit.Pruning,
+		Transport:        c.transport,
 	})
 	if err != nil {
 		return nil, err
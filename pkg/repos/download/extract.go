@@ -3,8 +3,10 @@ package download
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,6 +14,8 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/mholt/archiver/v4"
 )
 
@@ -30,14 +34,23 @@ func Extract(ctx context.Context, downloadURL, digest, targetDir string) error {
 	}
 	defer os.Remove(tmpFile.Name())
 	defer tmpFile.Close()
+	defer cache.Use(tmpFile.Name())()
 
-	resp, err := http.Get(downloadURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	digester := sha256.New()
+	var digester hash.Hash = sha256.New()
+	// Some projects only publish SHA-512 digests of their releases
+	if len(digest) == sha512.Size*2 {
+		digester = sha512.New()
+	}
 	input := io.TeeReader(resp.Body, digester)
 
 	if _, err = io.Copy(tmpFile, input); err != nil {
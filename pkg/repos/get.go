@@ -8,6 +8,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/BurntSushi/locker"
 	"github.com/gptscript-ai/gptscript/pkg/repos/git"
@@ -20,6 +22,12 @@ type Runtime interface {
 	Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error)
 }
 
+// CommandRuntime is implemented by runtimes that change the command of the tools they run, like to add the flags
+// that grant a tool its permissions.
+type CommandRuntime interface {
+	Command(tool types.Tool, cmd []string) []string
+}
+
 type noopRuntime struct {
 }
 
@@ -40,6 +48,8 @@ type Manager struct {
 	gitDir     string
 	runtimeDir string
 	runtimes   []Runtime
+	// offline managers only use the repositories that are already set up, like the ones in a vendor directory
+	offline bool
 }
 
 func New(cacheDir string, runtimes ...Runtime) *Manager {
@@ -52,6 +62,14 @@ func New(cacheDir string, runtimes ...Runtime) *Manager {
 	}
 }
 
+// NewOffline returns a manager that uses the repositories set up in cacheDir by a manager created with New, and
+// fails for the ones that are not set up instead of cloning them.
+func NewOffline(cacheDir string, runtimes ...Runtime) *Manager {
+	m := New(cacheDir, runtimes...)
+	m.offline = true
+	return m
+}
+
 func (m *Manager) setup(ctx context.Context, runtime Runtime, tool types.Tool, env []string) (string, []string, error) {
 	locker.Lock(tool.ID)
 	defer locker.Unlock(tool.ID)
@@ -63,12 +81,19 @@ func (m *Manager) setup(ctx context.Context, runtime Runtime, tool types.Tool, e
 	if err == nil {
 		var savedEnv []string
 		if err := json.Unmarshal(envData, &savedEnv); err == nil {
+			// The modification time of the done file is the last use of the tool when the cache is pruned
+			now := time.Now()
+			_ = os.Chtimes(doneFile, now, now)
 			return targetFinal, append(env, savedEnv...), nil
 		}
 	} else if !errors.Is(err, fs.ErrNotExist) {
 		return "", nil, err
 	}
 
+	if m.offline {
+		return "", nil, fmt.Errorf("%s at revision %s is not set up in %s, and can not be cloned offline", tool.Source.Repo.Root, tool.Source.Repo.Revision, m.storageDir)
+	}
+
 	// Cleanup previous failed runs
 	_ = os.RemoveAll(doneFile + ".tmp")
 	_ = os.RemoveAll(doneFile)
@@ -109,6 +134,15 @@ func (m *Manager) GetContext(ctx context.Context, tool types.Tool, cmd, env []st
 		return "", nil, fmt.Errorf("only git is supported, found VCS %s for %s", tool.Source.Repo.VCS, tool.ID)
 	}
 
+	if tool.Runtime != "" {
+		runtime, err := m.constrainedRuntime(tool, cmd)
+		if err != nil {
+			return "", nil, err
+		}
+		log.Debugf("Runtime %s satisfies %s for %v", runtime.ID(), tool.Runtime, cmd)
+		return m.setup(ctx, runtime, tool, env)
+	}
+
 	for _, runtime := range m.runtimes {
 		if runtime.Supports(cmd) {
 			log.Debugf("Runtime %s supports %v", runtime.ID(), cmd)
@@ -118,3 +152,66 @@ func (m *Manager) GetContext(ctx context.Context, tool types.Tool, cmd, env []st
 
 	return m.setup(ctx, &noopRuntime{}, tool, env)
 }
+
+// constrainedRuntime returns the runtime of the newest version that satisfies the version constraint of the tool,
+// among the versions that the runtimes of its language can set up.
+func (m *Manager) constrainedRuntime(tool types.Tool, cmd []string) (Runtime, error) {
+	constraint, err := ParseConstraint(tool.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		supported bool
+		available []string
+	)
+	for _, runtime := range m.runtimes {
+		versioned, ok := runtime.(VersionedRuntime)
+		if !ok || versioned.Language() != constraint.Language || !runtime.Supports(cmd) {
+			continue
+		}
+		supported = true
+
+		versions := versioned.Versions()
+		sortVersions(versions)
+		for _, version := range versions {
+			if !slices.Contains(available, version) {
+				available = append(available, version)
+			}
+			if !constraint.Matches(version) {
+				continue
+			}
+			if candidate := versioned.WithVersion(version); candidate.Supports(cmd) {
+				return candidate, nil
+			}
+		}
+	}
+
+	if !supported {
+		return nil, fmt.Errorf("tool %s requires %s, but its command %v is not run by a %s runtime", tool.Parameters.Name, tool.Runtime, cmd, constraint.Language)
+	}
+	return nil, fmt.Errorf("tool %s requires %s, but no version that can be set up satisfies it, the versions are %v", tool.Parameters.Name, tool.Runtime, available)
+}
+
+// Dirs returns the directory of the repositories of tools and their runtimes.
+func (m *Manager) Dirs() []string {
+	return []string{m.storageDir}
+}
+
+// Command returns the command to run the tool with, as changed by the runtime that supports it.
+func (m *Manager) Command(tool types.Tool, cmd []string) []string {
+	if tool.Source.Repo == nil {
+		return cmd
+	}
+
+	for _, runtime := range m.runtimes {
+		if runtime.Supports(cmd) {
+			if c, ok := runtime.(CommandRuntime); ok {
+				return c.Command(tool, cmd)
+			}
+			return cmd
+		}
+	}
+
+	return cmd
+}
@@ -1,4 +1,4 @@
-package repos
+package repos_test
 
 import (
 	"context"
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/python"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/samber/lo"
@@ -19,7 +20,7 @@ var (
 )
 
 func TestManager_GetContext(t *testing.T) {
-	m := New(testCacheHome, &python.Runtime{
+	m := repos.New(testCacheHome, &python.Runtime{
 		Version: "3.11",
 	})
 	cwd, env, err := m.GetContext(context.Background(), types.Tool{
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"encoding/base64"
+	"fmt"
+	url2 "net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/env"
+)
+
+// hostTokens are the environment variables of the tokens that tools are loaded from private repositories with, so
+// the repositories are cloned with them too, and the user names they are sent with.
+var hostTokens = map[string]struct{ env, username string }{
+	"github.com":    {env: "GITHUB_AUTH_TOKEN", username: "x-access-token"},
+	"gitlab.com":    {env: "GITLAB_AUTH_TOKEN", username: "oauth2"},
+	"bitbucket.org": {env: "BITBUCKET_AUTH_TOKEN", username: "x-token-auth"},
+}
+
+// credentials returns the user name and password or token to clone repositories from the host with over HTTPS.
+// Tokens for any host can be set in GPTSCRIPT_GIT_<HOST>_TOKEN, with the user name in GPTSCRIPT_GIT_<HOST>_USERNAME.
+func credentials(host string) (string, string, bool) {
+	prefix := "GPTSCRIPT_GIT_" + env.ToEnvLike(host)
+	if token := os.Getenv(prefix + "_TOKEN"); token != "" {
+		return os.Getenv(prefix + "_USERNAME"), token, true
+	}
+
+	if host == "bitbucket.org" && os.Getenv("BITBUCKET_USERNAME") != "" && os.Getenv("BITBUCKET_APP_PASSWORD") != "" {
+		return os.Getenv("BITBUCKET_USERNAME"), os.Getenv("BITBUCKET_APP_PASSWORD"), true
+	}
+
+	if known, ok := hostTokens[host]; ok {
+		if token := os.Getenv(known.env); token != "" {
+			return known.username, token, true
+		}
+	}
+
+	return "", "", false
+}
+
+func splitHosts(value string) (result []string) {
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			result = append(result, host)
+		}
+	}
+	return
+}
+
+// authEnv returns the environment to run git with to clone or fetch the repository. Repositories on the hosts in
+// GPTSCRIPT_GIT_SSH_HOSTS are cloned over SSH, with the key in GPTSCRIPT_GIT_SSH_KEY or the keys of the SSH agent.
+// Repositories on other hosts are cloned over HTTPS with the token of the host, or the credential helper in
+// GPTSCRIPT_GIT_CREDENTIAL_HELPER, in addition to the credential helpers configured for git. Git never prompts for
+// credentials, so cloning a private repository without them fails instead of waiting for input.
+func authEnv(repo string) []string {
+	var (
+		result = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		config [][2]string
+	)
+
+	if u, err := url2.Parse(repo); err == nil && u.Scheme == "https" && u.Host != "" {
+		base := "https://" + u.Host + "/"
+		if slices.Contains(splitHosts(os.Getenv("GPTSCRIPT_GIT_SSH_HOSTS")), u.Host) {
+			config = append(config, [2]string{"url.git@" + u.Hostname() + ":.insteadOf", base})
+		} else if username, password, ok := credentials(u.Host); ok {
+			if username == "" {
+				username = "git"
+			}
+			auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+			config = append(config, [2]string{"http." + base + ".extraHeader", "Authorization: Basic " + auth})
+		}
+	}
+
+	if helper := os.Getenv("GPTSCRIPT_GIT_CREDENTIAL_HELPER"); helper != "" {
+		config = append(config, [2]string{"credential.helper", helper})
+	}
+
+	if key := os.Getenv("GPTSCRIPT_GIT_SSH_KEY"); key != "" {
+		result = append(result, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %q -o IdentitiesOnly=yes -o BatchMode=yes", key))
+	} else if os.Getenv("GIT_SSH_COMMAND") == "" {
+		result = append(result, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+
+	// The configuration is passed in the environment, so tokens do not show up in the arguments of the process. It
+	// is added to the configuration that may already be in the environment.
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for i, kv := range config {
+		result = append(result,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", count+i, kv[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count+i, kv[1]))
+	}
+	if len(config) > 0 {
+		result = append(result, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+len(config)))
+	}
+
+	return result
+}
@@ -2,6 +2,9 @@ package git
 
 import (
 	"context"
+	"fmt"
+	"os/exec"
+	"strings"
 
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 )
@@ -13,6 +16,7 @@ func newGitCommand(ctx context.Context, args ...string) *debugcmd.WrappedCmd {
 
 func cloneBare(ctx context.Context, repo, toDir string) error {
 	cmd := newGitCommand(ctx, "clone", "--bare", "--depth", "1", repo, toDir)
+	cmd.Env = authEnv(repo)
 	return cmd.Run()
 }
 
@@ -22,7 +26,21 @@ func gitWorktreeAdd(ctx context.Context, gitDir, commitDir, commit string) error
 	return cmd.Run()
 }
 
-func fetchCommit(ctx context.Context, gitDir, commit string) error {
+func fetchCommit(ctx context.Context, gitDir, repo, commit string) error {
 	cmd := newGitCommand(ctx, "--git-dir", gitDir, "fetch", "origin", commit)
+	cmd.Env = authEnv(repo)
 	return cmd.Run()
 }
+
+func gitWorktreePrune(ctx context.Context, gitDir string) error {
+	cmd := newGitCommand(ctx, "--git-dir", gitDir, "worktree", "prune")
+	return cmd.Run()
+}
+
+func revParseHead(ctx context.Context, dir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the commit of %s: %w", dir, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gptscript-ai/gptscript/pkg/hash"
 )
@@ -34,7 +35,20 @@ func Checkout(ctx context.Context, base, repo, commit, toDir string) error {
 	}
 
 	log.Infof("Checking out %s to %s", commit, toDir)
-	return gitWorktreeAdd(ctx, gitDir(base, repo), toDir, commit)
+	if err := gitWorktreeAdd(ctx, gitDir(base, repo), toDir, commit); err != nil {
+		return err
+	}
+
+	// The code of the tool is verified to be at the commit, which is the hash of its tree, before it is set up and run
+	head, err := revParseHead(ctx, toDir)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(head, commit) {
+		_ = os.RemoveAll(toDir)
+		return fmt.Errorf("the checkout of %s is at commit %s, not at the commit %s the tool was loaded at", repo, head, commit)
+	}
+	return nil
 }
 
 func gitDir(base, repo string) string {
@@ -52,5 +66,22 @@ func Fetch(ctx context.Context, base, repo, commit string) error {
 		}
 	}
 	log.Infof("Fetching %s at %s", commit, repo)
-	return fetchCommit(ctx, gitDir, commit)
+	return fetchCommit(ctx, gitDir, repo, commit)
+}
+
+// PruneWorktrees removes the worktrees that no longer exist from the clones in base, like the checkouts of tools
+// removed from the cache.
+func PruneWorktrees(ctx context.Context, base string) error {
+	clones, err := os.ReadDir(filepath.Join(base, "repos"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, clone := range clones {
+		if err := gitWorktreePrune(ctx, filepath.Join(base, "repos", clone.Name())); err != nil {
+			log.Warnf("failed to prune the worktrees of %s: %v", clone.Name(), err)
+		}
+	}
+	return nil
 }
@@ -2,8 +2,10 @@ package git
 
 import (
 	"context"
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/adrg/xdg"
@@ -32,3 +34,39 @@ func TestCheckout(t *testing.T) {
 		testCommit, commitDir)
 	require.NoError(t, err)
 }
+
+func TestAuthEnv(t *testing.T) {
+	lookup := func(env []string) map[string]string {
+		result := map[string]string{}
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			result[k] = v
+		}
+		return result
+	}
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GITHUB_AUTH_TOKEN", "gh-token")
+	t.Setenv("GPTSCRIPT_GIT_GIT_EXAMPLE_COM_TOKEN", "example-token")
+	t.Setenv("GPTSCRIPT_GIT_SSH_HOSTS", "ssh.example.com")
+	t.Setenv("GPTSCRIPT_GIT_SSH_KEY", "/keys/id_ed25519")
+	t.Setenv("GPTSCRIPT_GIT_CREDENTIAL_HELPER", "")
+
+	env := lookup(authEnv("https://github.com/example/private.git"))
+	require.Equal(t, "0", env["GIT_TERMINAL_PROMPT"])
+	require.Equal(t, "2", env["GIT_CONFIG_COUNT"])
+	require.Equal(t, "http.https://github.com/.extraHeader", env["GIT_CONFIG_KEY_1"])
+	require.Equal(t, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte("x-access-token:gh-token")), env["GIT_CONFIG_VALUE_1"])
+	require.Equal(t, `ssh -i "/keys/id_ed25519" -o IdentitiesOnly=yes -o BatchMode=yes`, env["GIT_SSH_COMMAND"])
+
+	env = lookup(authEnv("https://git.example.com/example/private.git"))
+	require.Equal(t, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte("git:example-token")), env["GIT_CONFIG_VALUE_1"])
+
+	env = lookup(authEnv("https://ssh.example.com/example/private.git"))
+	require.Equal(t, "url.git@ssh.example.com:.insteadOf", env["GIT_CONFIG_KEY_1"])
+	require.Equal(t, "https://ssh.example.com/", env["GIT_CONFIG_VALUE_1"])
+
+	// No credentials are configured for the host
+	env = lookup(authEnv("https://other.example.com/example/public.git"))
+	require.Equal(t, "1", env["GIT_CONFIG_COUNT"])
+}
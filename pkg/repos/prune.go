@@ -0,0 +1,103 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/repos/git"
+)
+
+// Entries returns the entries of the repos directory of the cache in cacheDir: the tools checked out at each
+// revision, the clones of their repositories and the runtimes they are set up with. A tool needs the clone it is
+// checked out from and the runtimes in its environment.
+func Entries(cacheDir string) ([]cache.Entry, error) {
+	m := New(cacheDir)
+
+	clones, err := cache.DirEntries("git", filepath.Join(m.gitDir, "repos"))
+	if err != nil {
+		return nil, err
+	}
+
+	runtimeDirs, err := os.ReadDir(m.runtimeDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var runtimes []cache.Entry
+	for _, dir := range runtimeDirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entries, err := cache.DirEntries("runtime", filepath.Join(m.runtimeDir, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		runtimes = append(runtimes, entries...)
+	}
+
+	revisions, err := os.ReadDir(m.storageDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var tools []cache.Entry
+	for _, revision := range revisions {
+		path := filepath.Join(m.storageDir, revision.Name())
+		if path == m.gitDir || path == m.runtimeDir {
+			continue
+		}
+		tool, err := cache.NewEntry("tool", path)
+		if err != nil {
+			return nil, err
+		}
+		tool.Needs, err = toolNeeds(path, clones, runtimes)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool)
+	}
+
+	return append(append(tools, clones...), runtimes...), nil
+}
+
+// toolNeeds returns the clones that the checkouts of a revision are worktrees of, and the runtimes that are in the
+// environments saved in their done files.
+func toolNeeds(dir string, clones, runtimes []cache.Entry) (result []string, _ error) {
+	var refs strings.Builder
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// The .git files of worktrees reference their clone, and the done files the environment of their runtime
+		if d.IsDir() || (d.Name() != ".git" && !strings.HasSuffix(d.Name(), ".done")) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		refs.Write(data)
+		refs.WriteString("\n")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text := filepath.ToSlash(refs.String())
+	for _, entry := range append(append([]cache.Entry{}, clones...), runtimes...) {
+		if strings.Contains(text, filepath.ToSlash(entry.Path)+"/") {
+			result = append(result, entry.Path)
+		}
+	}
+	return result, nil
+}
+
+// Cleanup removes what git knows about the checkouts of tools that were pruned from the cache in cacheDir, so they
+// can be checked out again.
+func Cleanup(ctx context.Context, cacheDir string) error {
+	return git.PruneWorktrees(ctx, New(cacheDir).gitDir)
+}
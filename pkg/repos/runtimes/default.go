@@ -3,9 +3,13 @@ package runtimes
 import (
 	"github.com/gptscript-ai/gptscript/pkg/engine"
 	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/deno"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/golang"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/java"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/node"
 	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/python"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/ruby"
+	"github.com/gptscript-ai/gptscript/pkg/repos/runtimes/rust"
 )
 
 var Runtimes = []repos.Runtime{
@@ -23,11 +27,31 @@ var Runtimes = []repos.Runtime{
 		Version: "21",
 		Default: true,
 	},
+	&deno.Runtime{
+		Version: "2.1.4",
+		Default: true,
+	},
 	&golang.Runtime{
 		Version: "1.22.1",
 	},
+	&rust.Runtime{
+		Version: "1.82.0",
+	},
+	&java.Runtime{
+		Version: "21",
+		Default: true,
+	},
+	&ruby.Runtime{
+		Version: "3.3.5",
+		Default: true,
+	},
 }
 
 func Default(cacheDir string) engine.RuntimeManager {
 	return repos.New(cacheDir, Runtimes...)
 }
+
+// Offline returns a runtime manager that only uses the tools and runtimes already set up in cacheDir.
+func Offline(cacheDir string) engine.RuntimeManager {
+	return repos.NewOffline(cacheDir, Runtimes...)
+}
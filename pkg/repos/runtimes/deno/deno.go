@@ -0,0 +1,225 @@
+package deno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
+	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/repos/download"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const downloadURL = "https://github.com/denoland/deno/releases/download/v%s/deno-%s.zip"
+
+var digestPattern = regexp.MustCompile(`\b[0-9a-fA-F]{64}\b`)
+
+// configFiles are the files in a tool that have dependencies to install before the tool runs
+var configFiles = []string{"deno.json", "deno.jsonc", "package.json"}
+
+type Runtime struct {
+	// version something like "2.1.4"
+	Version string
+	// If true this is the version that will be used for deno
+	Default bool
+}
+
+func (r *Runtime) ID() string {
+	return "deno" + r.Version
+}
+
+func (r *Runtime) Language() string {
+	return "deno"
+}
+
+func (r *Runtime) Versions() []string {
+	return []string{r.Version}
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+		Default: true,
+	}
+}
+
+func (r *Runtime) Supports(cmd []string) bool {
+	if runtimeEnv.Matches(cmd, r.ID()) {
+		return true
+	}
+	if !r.Default {
+		return false
+	}
+	return runtimeEnv.Matches(cmd, "deno")
+}
+
+func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error) {
+	binPath, err := r.getRuntime(ctx, dataRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	newEnv := runtimeEnv.AppendPath(env, binPath)
+	// Each tool gets its own cache of modules, so tools do not share or change the dependencies of each other
+	newEnv = append(newEnv,
+		"DENO_DIR="+filepath.Join(toolSource, ".deno"),
+		"DENO_NO_UPDATE_CHECK=1",
+		"DENO_NO_PROMPT=1")
+
+	if err := r.runInstall(ctx, toolSource, binPath, append(env, newEnv...)); err != nil {
+		return nil, err
+	}
+
+	return newEnv, nil
+}
+
+// Command adds the permissions of the tool to commands that run it with deno, unless the command of the tool already
+// sets its permissions. Tools can read their own files and the environment, which is how they get their arguments,
+// and tools with credentials can use the network, since credentials are for calling remote services.
+func (r *Runtime) Command(tool types.Tool, cmd []string) []string {
+	i := 0
+	if len(cmd) > 1 && (cmd[0] == "/usr/bin/env" || cmd[0] == "/bin/env") {
+		i = 1
+	}
+	if i+1 >= len(cmd) || cmd[i+1] != "run" {
+		return cmd
+	}
+
+	for _, arg := range cmd[i+2:] {
+		if arg == "-A" || arg == "-P" || strings.HasPrefix(arg, "--allow-") || strings.HasPrefix(arg, "--deny-") ||
+			strings.HasPrefix(arg, "--permission-set") {
+			return cmd
+		}
+	}
+
+	permissions := []string{"--allow-env", "--allow-read=${GPTSCRIPT_TOOL_DIR}"}
+	if len(tool.Credentials) > 0 {
+		permissions = append(permissions, "--allow-net")
+	}
+
+	result := append([]string{}, cmd[:i+2]...)
+	result = append(result, permissions...)
+	return append(result, cmd[i+2:]...)
+}
+
+func target() (string, error) {
+	var arch string
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = "x86_64"
+	case "arm64":
+		arch = "aarch64"
+	default:
+		return "", fmt.Errorf("deno is not available for arch=%s", runtime.GOARCH)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return arch + "-unknown-linux-gnu", nil
+	case "darwin":
+		return arch + "-apple-darwin", nil
+	case "windows":
+		if arch == "x86_64" {
+			return arch + "-pc-windows-msvc", nil
+		}
+	}
+	return "", fmt.Errorf("deno is not available for os=%s arch=%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// getReleaseAndDigest returns the release for this platform and its digest, which is published next to the release.
+func (r *Runtime) getReleaseAndDigest(ctx context.Context) (string, string, error) {
+	target, err := target()
+	if err != nil {
+		return "", "", err
+	}
+
+	url := fmt.Sprintf(downloadURL, r.Version, target)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+".sha256sum", nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to find %s release for %s: %s", r.ID(), target, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	digest := digestPattern.Find(data)
+	if digest == nil {
+		return "", "", fmt.Errorf("failed to find digest of %s in %s.sha256sum", r.ID(), url)
+	}
+
+	return url, strings.ToLower(string(digest)), nil
+}
+
+func (r *Runtime) runInstall(ctx context.Context, toolSource, binDir string, env []string) error {
+	for _, file := range configFiles {
+		if _, err := os.Stat(filepath.Join(toolSource, file)); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		log.Infof("Running deno install in %s", toolSource)
+		cmd := debugcmd.New(ctx, filepath.Join(binDir, "deno"), "install")
+		cmd.Env = env
+		cmd.Dir = toolSource
+		return cmd.Run()
+	}
+	return nil
+}
+
+func (r *Runtime) getRuntime(ctx context.Context, cwd string) (string, error) {
+	// The release is named by its version, so an existing download is used without checking its digest again
+	target := filepath.Join(cwd, "deno", hash.ID(r.ID(), runtime.GOOS, runtime.GOARCH))
+	if _, err := os.Stat(target); err == nil {
+		return target, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	url, sha, err := r.getReleaseAndDigest(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Downloading Deno %s", r.Version)
+	tmp := target + ".download"
+	defer os.RemoveAll(tmp)
+
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return "", err
+	}
+
+	if err := download.Extract(ctx, url, sha, tmp); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
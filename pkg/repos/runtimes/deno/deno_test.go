@@ -0,0 +1,43 @@
+package deno
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCacheHome = lo.Must(xdg.CacheFile("gptscript-test-cache/runtime"))
+)
+
+func TestRuntime(t *testing.T) {
+	r := Runtime{
+		Version: "2.1.4",
+	}
+
+	s, err := r.Setup(context.Background(), testCacheHome, "testdata", os.Environ())
+	require.NoError(t, err)
+	assert.Contains(t, s, "DENO_NO_PROMPT=1")
+}
+
+func TestCommand(t *testing.T) {
+	r := Runtime{
+		Version: "2.1.4",
+		Default: true,
+	}
+
+	assert.Equal(t, []string{"deno", "run", "--allow-env", "--allow-read=${GPTSCRIPT_TOOL_DIR}", "tool.ts"},
+		r.Command(types.Tool{}, []string{"deno", "run", "tool.ts"}))
+	assert.Equal(t, []string{"/usr/bin/env", "deno", "run", "--allow-env", "--allow-read=${GPTSCRIPT_TOOL_DIR}", "--allow-net", "tool.ts"},
+		r.Command(types.Tool{Parameters: types.Parameters{Credentials: []string{"token"}}}, []string{"/usr/bin/env", "deno", "run", "tool.ts"}))
+	assert.Equal(t, []string{"deno", "run", "-A", "tool.ts"},
+		r.Command(types.Tool{}, []string{"deno", "run", "-A", "tool.ts"}))
+	assert.Equal(t, []string{"deno", "task", "start"},
+		r.Command(types.Tool{}, []string{"deno", "task", "start"}))
+}
@@ -11,11 +11,13 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
 	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 )
 
@@ -37,6 +39,32 @@ func (r *Runtime) Supports(cmd []string) bool {
 	return len(cmd) > 0 && cmd[0] == "${GPTSCRIPT_TOOL_DIR}/bin/gptscript-go-tool"
 }
 
+func (r *Runtime) Language() string {
+	return "go"
+}
+
+// Versions returns the versions of the releases for this OS and architecture, like 1.22.1.
+func (r *Runtime) Versions() (result []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(releasesData))
+	key := "." + runtime.GOOS + "-" + runtime.GOARCH
+	for scanner.Scan() {
+		line := strings.Split(scanner.Text(), "  ")
+		if len(line) != 2 {
+			continue
+		}
+		if version, _, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line[1]), "go"), key); ok && !slices.Contains(result, version) {
+			result = append(result, version)
+		}
+	}
+	return result
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+	}
+}
+
 func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error) {
 	binPath, err := r.getRuntime(ctx, dataRoot)
 	if err != nil {
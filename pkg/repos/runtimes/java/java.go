@@ -0,0 +1,304 @@
+package java
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
+	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/repos/download"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+const (
+	// releasesURL lists the latest release of the Temurin JDK for a feature version, os and arch
+	releasesURL = "https://api.adoptium.net/v3/assets/latest/%s/hotspot?image_type=jdk&vendor=eclipse&os=%s&architecture=%s"
+
+	mavenVersion = "3.9.9"
+	mavenURL     = "https://archive.apache.org/dist/maven/maven-3/%[1]s/binaries/apache-maven-%[1]s-bin.tar.gz"
+
+	gradleVersion = "8.10.2"
+	gradleURL     = "https://services.gradle.org/distributions/gradle-%s-bin.zip"
+)
+
+var digestPattern = regexp.MustCompile(`\b([0-9a-fA-F]{128}|[0-9a-fA-F]{64})\b`)
+
+type Runtime struct {
+	// version something like "21"
+	Version string
+	// If true this is the version that will be used for java
+	Default bool
+}
+
+func (r *Runtime) ID() string {
+	return "java" + r.Version
+}
+
+func (r *Runtime) Language() string {
+	return "java"
+}
+
+func (r *Runtime) Versions() []string {
+	return []string{r.Version}
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+		Default: true,
+	}
+}
+
+func (r *Runtime) Supports(cmd []string) bool {
+	for _, testCmd := range []string{"java", "javac", "mvn", "gradle"} {
+		if r.supports(testCmd, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Runtime) supports(testCmd string, cmd []string) bool {
+	if runtimeEnv.Matches(cmd, testCmd+r.Version) {
+		return true
+	}
+	if !r.Default {
+		return false
+	}
+	return runtimeEnv.Matches(cmd, testCmd)
+}
+
+func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error) {
+	javaHome, err := r.getRuntime(ctx, dataRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	newEnv := runtimeEnv.AppendPath(env, filepath.Join(javaHome, "bin"))
+	newEnv = append(newEnv, "JAVA_HOME="+javaHome)
+
+	if exists(toolSource, "pom.xml") {
+		mvn, err := r.getBuildTool(ctx, dataRoot, toolSource, "mvn", "mvnw", fmt.Sprintf(mavenURL, mavenVersion))
+		if err != nil {
+			return nil, err
+		}
+		if err := r.run(ctx, toolSource, append(env, newEnv...), mvn, "-B", "-q", "-DskipTests",
+			"-Dmaven.repo.local="+filepath.Join(dataRoot, "java", "m2"), "package", "dependency:copy-dependencies"); err != nil {
+			return nil, err
+		}
+	} else if exists(toolSource, "build.gradle") || exists(toolSource, "build.gradle.kts") {
+		gradle, err := r.getBuildTool(ctx, dataRoot, toolSource, "gradle", "gradlew", fmt.Sprintf(gradleURL, gradleVersion))
+		if err != nil {
+			return nil, err
+		}
+		gradleEnv := append(append(env, newEnv...), "GRADLE_USER_HOME="+filepath.Join(dataRoot, "java", "gradle"))
+		if err := r.run(ctx, toolSource, gradleEnv, gradle, "--no-daemon", "-q", "build", "-x", "test"); err != nil {
+			return nil, err
+		}
+	}
+
+	return newEnv, nil
+}
+
+func exists(dir, file string) bool {
+	_, err := os.Stat(filepath.Join(dir, file))
+	return err == nil
+}
+
+func osName() string {
+	if runtime.GOOS == "darwin" {
+		return "mac"
+	}
+	return runtime.GOOS
+}
+
+func arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "arm64":
+		return "aarch64"
+	}
+	return runtime.GOARCH
+}
+
+func script(name string) string {
+	if runtime.GOOS != "windows" {
+		return name
+	}
+	switch name {
+	case "mvn", "mvnw":
+		return name + ".cmd"
+	}
+	return name + ".bat"
+}
+
+func (r *Runtime) getReleaseAndDigest(ctx context.Context) (string, string, error) {
+	data, err := get(ctx, fmt.Sprintf(releasesURL, url.PathEscape(r.Version), osName(), arch()))
+	if err != nil {
+		return "", "", err
+	}
+
+	var releases []struct {
+		Binary struct {
+			Package struct {
+				Link     string `json:"link"`
+				Checksum string `json:"checksum"`
+			} `json:"package"`
+		} `json:"binary"`
+	}
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return "", "", fmt.Errorf("invalid releases of %s: %w", r.ID(), err)
+	}
+
+	for _, release := range releases {
+		if release.Binary.Package.Link != "" && release.Binary.Package.Checksum != "" {
+			return release.Binary.Package.Link, release.Binary.Package.Checksum, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("failed to find %s release for os=%s arch=%s", r.ID(), osName(), arch())
+}
+
+// getDigest returns the digest of a release, which is published next to the release.
+func getDigest(ctx context.Context, url, ext string) (string, error) {
+	data, err := get(ctx, url+ext)
+	if err != nil {
+		return "", err
+	}
+
+	digest := digestPattern.Find(data)
+	if digest == nil {
+		return "", fmt.Errorf("failed to find digest in %s%s", url, ext)
+	}
+	return strings.ToLower(string(digest)), nil
+}
+
+func get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (r *Runtime) run(ctx context.Context, toolSource string, env []string, bin string, args ...string) error {
+	log.Infof("Running %s in %s", filepath.Base(bin), toolSource)
+	cmd := debugcmd.New(ctx, bin, args...)
+	cmd.Env = env
+	cmd.Dir = toolSource
+	return cmd.Run()
+}
+
+// getBuildTool returns the wrapper of the build tool in the tool, like mvnw, or the build tool downloaded from url.
+func (r *Runtime) getBuildTool(ctx context.Context, dataRoot, toolSource, name, wrapper, url string) (string, error) {
+	if exists(toolSource, script(wrapper)) {
+		return filepath.Join(toolSource, script(wrapper)), nil
+	}
+
+	ext := ".sha256"
+	if name == "mvn" {
+		// Maven only publishes SHA-512 digests
+		ext = ".sha512"
+	}
+
+	home, err := install(ctx, filepath.Join(dataRoot, "java", hash.ID(url)), name, func() (string, string, error) {
+		digest, err := getDigest(ctx, url, ext)
+		return url, digest, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "bin", script(name)), nil
+}
+
+func (r *Runtime) getRuntime(ctx context.Context, dataRoot string) (string, error) {
+	// The latest release of a version changes, so the release that was downloaded first is used from then on
+	target := filepath.Join(dataRoot, "java", hash.ID(r.ID(), runtime.GOOS, runtime.GOARCH))
+	return install(ctx, target, "Java "+r.Version, func() (string, string, error) {
+		return r.getReleaseAndDigest(ctx)
+	})
+}
+
+// install downloads the release to target, unless it is already there, and returns the directory it was extracted to.
+func install(ctx context.Context, target, name string, release func() (string, string, error)) (string, error) {
+	if _, err := os.Stat(target); err == nil {
+		return homeDir(target)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	url, sha, err := release()
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Downloading %s", name)
+	tmp := target + ".download"
+	defer os.RemoveAll(tmp)
+
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return "", err
+	}
+
+	if err := download.Extract(ctx, url, sha, tmp); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		return "", err
+	}
+
+	return homeDir(target)
+}
+
+// homeDir returns the directory with the bin directory of a release, which is extracted into a sub directory, and
+// is in Contents/Home of that directory for the JDK on macOS.
+func homeDir(rel string) (string, error) {
+	entries, err := os.ReadDir(rel)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		for _, home := range []string{
+			filepath.Join(rel, entry.Name()),
+			filepath.Join(rel, entry.Name(), "Contents", "Home"),
+		} {
+			if _, err := os.Stat(filepath.Join(home, "bin")); err == nil {
+				return home, nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+	}
+
+	return "", fmt.Errorf("failed to find bin dir in %s", rel)
+}
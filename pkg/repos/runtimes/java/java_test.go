@@ -0,0 +1,40 @@
+package java
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCacheHome = lo.Must(xdg.CacheFile("gptscript-test-cache/runtime"))
+)
+
+func TestRuntime(t *testing.T) {
+	r := Runtime{
+		Version: "21",
+	}
+
+	s, err := r.Setup(context.Background(), testCacheHome, "testdata", os.Environ())
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(s[0], "/bin"), "missing /bin: %s", s)
+}
+
+func TestHomeDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "jdk-21.0.5+11", "Contents", "Home", "bin"), 0755))
+
+	home, err := homeDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "jdk-21.0.5+11", "Contents", "Home"), home)
+
+	_, err = homeDir(t.TempDir())
+	assert.Error(t, err)
+}
@@ -11,11 +11,13 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
 	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 )
 
@@ -54,6 +56,34 @@ func (r *Runtime) supports(testCmd string, cmd []string) bool {
 	return runtimeEnv.Matches(cmd, testCmd)
 }
 
+func (r *Runtime) Language() string {
+	return "node"
+}
+
+// Versions returns the versions of the releases for this OS and architecture, like 20.11.1.
+func (r *Runtime) Versions() (result []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(releasesData))
+	key := "-" + osName() + "-" + arch()
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), "  ")
+		if len(parts) != 2 || !strings.Contains(parts[1], key) {
+			continue
+		}
+		version := strings.TrimPrefix(strings.Split(strings.TrimSpace(parts[1]), "-")[1], "v")
+		if !slices.Contains(result, version) {
+			result = append(result, version)
+		}
+	}
+	return result
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+		Default: true,
+	}
+}
+
 func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error) {
 	binPath, err := r.getRuntime(ctx, dataRoot)
 	if err != nil {
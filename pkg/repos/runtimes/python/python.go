@@ -12,10 +12,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 
 	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
 	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
 	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
 	"github.com/gptscript-ai/gptscript/pkg/repos/download"
 )
 
@@ -53,6 +55,27 @@ func (r *Runtime) Supports(cmd []string) bool {
 	return runtimeEnv.Matches(cmd, "python") || runtimeEnv.Matches(cmd, "python3")
 }
 
+func (r *Runtime) Language() string {
+	return "python"
+}
+
+// Versions returns the versions of the releases for this OS and architecture.
+func (r *Runtime) Versions() (result []string) {
+	for _, release := range readRelease() {
+		if release.OS == runtime.GOOS && release.Arch == runtime.GOARCH && !slices.Contains(result, release.Version) {
+			result = append(result, release.Version)
+		}
+	}
+	return result
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+		Default: true,
+	}
+}
+
 func pythonCmd(base string) string {
 	if runtime.GOOS == "windows" {
 		return filepath.Join(base, "python.exe")
@@ -0,0 +1,216 @@
+package ruby
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
+	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/repos/download"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+const (
+	// indexURL lists every release of Ruby with its digests
+	indexURL    = "https://cache.ruby-lang.org/pub/ruby/index.txt"
+	downloadURL = "https://cache.ruby-lang.org/pub/ruby/%s/ruby-%s.tar.gz"
+)
+
+var digestPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+type Runtime struct {
+	// version something like "3.3.5"
+	Version string
+	// If true this is the version that will be used for ruby or bundle
+	Default bool
+}
+
+func (r *Runtime) ID() string {
+	return "ruby" + r.Version
+}
+
+func (r *Runtime) Language() string {
+	return "ruby"
+}
+
+func (r *Runtime) Versions() []string {
+	return []string{r.Version}
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+		Default: true,
+	}
+}
+
+func (r *Runtime) Supports(cmd []string) bool {
+	if runtimeEnv.Matches(cmd, r.ID()) {
+		return true
+	}
+	if !r.Default {
+		return false
+	}
+	return runtimeEnv.Matches(cmd, "ruby") || runtimeEnv.Matches(cmd, "bundle")
+}
+
+func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error) {
+	binPath, err := r.getRuntime(ctx, dataRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	newEnv := runtimeEnv.AppendPath(env, binPath)
+	if _, err := os.Stat(filepath.Join(toolSource, "Gemfile")); errors.Is(err, fs.ErrNotExist) {
+		return newEnv, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The gems of a tool are installed in the tool, so tools do not share or change the gems of each other
+	newEnv = append(newEnv,
+		"BUNDLE_GEMFILE="+filepath.Join(toolSource, "Gemfile"),
+		"BUNDLE_PATH="+filepath.Join(toolSource, "vendor", "bundle"))
+
+	if err := r.runBundle(ctx, toolSource, binPath, append(env, newEnv...)); err != nil {
+		return nil, err
+	}
+
+	return newEnv, nil
+}
+
+// getReleaseAndDigest returns the source release of the version and its digest, from the index of releases.
+func (r *Runtime) getReleaseAndDigest(ctx context.Context) (string, string, error) {
+	parts := strings.Split(r.Version, ".")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("invalid ruby version %s, it must be like 3.3.5", r.Version)
+	}
+	url := fmt.Sprintf(downloadURL, parts[0]+"."+parts[1], r.Version)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to get %s: %s", indexURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	digest, ok := findDigest(data, url)
+	if !ok {
+		return "", "", fmt.Errorf("failed to find %s release %s in %s", r.ID(), url, indexURL)
+	}
+	return url, digest, nil
+}
+
+// findDigest returns the SHA-256 digest of the release at url in the index, which has a line for every release
+// with its name, url and its SHA-1, SHA-256 and SHA-512 digests.
+func findDigest(index []byte, url string) (string, bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(index))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[1] != url {
+			continue
+		}
+		for _, field := range fields[2:] {
+			if digestPattern.MatchString(field) {
+				return strings.ToLower(field), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (r *Runtime) runBundle(ctx context.Context, toolSource, binDir string, env []string) error {
+	log.Infof("Running bundle install in %s", toolSource)
+	cmd := debugcmd.New(ctx, filepath.Join(binDir, "bundle"), "install")
+	cmd.Env = env
+	cmd.Dir = toolSource
+	return cmd.Run()
+}
+
+// build builds Ruby from its source in src and installs it in prefix. Ruby is built to load its libraries relative
+// to where it is, so prefix can be moved after it is installed.
+func (r *Runtime) build(ctx context.Context, src, prefix string) error {
+	for _, args := range [][]string{
+		{"./configure", "--prefix=" + prefix, "--enable-load-relative", "--disable-install-doc"},
+		{"make", "-j", strconv.Itoa(runtime.NumCPU())},
+		{"make", "install"},
+	} {
+		cmd := debugcmd.New(ctx, args[0], args[1:]...)
+		cmd.Dir = src
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to build %s, a C compiler and the development files of openssl, libyaml, "+
+				"zlib and libffi are needed to build it: %w", r.ID(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Runtime) getRuntime(ctx context.Context, cwd string) (string, error) {
+	if runtime.GOOS == "windows" {
+		return "", fmt.Errorf("%s can not be installed on windows, run the tool with a ruby that is installed instead", r.ID())
+	}
+
+	target := filepath.Join(cwd, "ruby", hash.ID(r.ID(), runtime.GOOS, runtime.GOARCH))
+	if _, err := os.Stat(target); err == nil {
+		return filepath.Join(target, "bin"), nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	url, sha, err := r.getReleaseAndDigest(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Downloading Ruby %s", r.Version)
+	tmp := target + ".download"
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		return "", err
+	}
+
+	if err := download.Extract(ctx, url, sha, src); err != nil {
+		return "", err
+	}
+
+	log.Infof("Building Ruby %s, this can take a few minutes", r.Version)
+	prefix := filepath.Join(tmp, "ruby")
+	if err := r.build(ctx, filepath.Join(src, "ruby-"+r.Version), prefix); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(prefix, target); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(target, "bin"), nil
+}
@@ -0,0 +1,40 @@
+package ruby
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCacheHome = lo.Must(xdg.CacheFile("gptscript-test-cache/runtime"))
+)
+
+func TestRuntime(t *testing.T) {
+	r := Runtime{
+		Version: "3.3.5",
+	}
+
+	s, err := r.Setup(context.Background(), testCacheHome, "testdata", os.Environ())
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(s[0], "/bin"), "missing /bin: %s", s)
+}
+
+func TestFindDigest(t *testing.T) {
+	index := []byte("name\turl\tsha1\tsha256\tsha512\n" +
+		"ruby-3.3.5\thttps://cache.ruby-lang.org/pub/ruby/3.3/ruby-3.3.5.tar.gz\t" + strings.Repeat("a", 40) + "\t" +
+		strings.Repeat("B", 64) + "\t" + strings.Repeat("c", 128) + "\n")
+
+	digest, ok := findDigest(index, "https://cache.ruby-lang.org/pub/ruby/3.3/ruby-3.3.5.tar.gz")
+	assert.True(t, ok)
+	assert.Equal(t, strings.Repeat("b", 64), digest)
+
+	_, ok = findDigest(index, "https://cache.ruby-lang.org/pub/ruby/3.3/ruby-3.3.4.tar.gz")
+	assert.False(t, ok)
+}
@@ -0,0 +1,275 @@
+package rust
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/debugcmd"
+	runtimeEnv "github.com/gptscript-ai/gptscript/pkg/env"
+	"github.com/gptscript-ai/gptscript/pkg/hash"
+	"github.com/gptscript-ai/gptscript/pkg/repos"
+	"github.com/gptscript-ai/gptscript/pkg/repos/download"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
+)
+
+const downloadURL = "https://static.rust-lang.org/dist/rust-%s-%s.tar.gz"
+
+var digestPattern = regexp.MustCompile(`\b[0-9a-fA-F]{64}\b`)
+
+type Runtime struct {
+	// version something like "1.82.0"
+	Version string
+}
+
+func (r *Runtime) ID() string {
+	return "rust" + r.Version
+}
+
+func (r *Runtime) Language() string {
+	return "rust"
+}
+
+func (r *Runtime) Versions() []string {
+	return []string{r.Version}
+}
+
+func (r *Runtime) WithVersion(version string) repos.Runtime {
+	return &Runtime{
+		Version: version,
+	}
+}
+
+func (r *Runtime) Supports(cmd []string) bool {
+	return len(cmd) > 0 && cmd[0] == "${GPTSCRIPT_TOOL_DIR}/bin/gptscript-rust-tool"
+}
+
+func (r *Runtime) Setup(ctx context.Context, dataRoot, toolSource string, env []string) ([]string, error) {
+	binPath, err := r.getRuntime(ctx, dataRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	newEnv := runtimeEnv.AppendPath(env, binPath)
+	newEnv = append(newEnv, "CARGO_HOME="+filepath.Join(dataRoot, "rust", "cargo"))
+
+	if err := r.build(ctx, dataRoot, toolSource, binPath, append(env, newEnv...)); err != nil {
+		return nil, err
+	}
+
+	return newEnv, nil
+}
+
+func artifactName() string {
+	if runtime.GOOS == "windows" {
+		return "gptscript-rust-tool.exe"
+	}
+	return "gptscript-rust-tool"
+}
+
+func target() (string, error) {
+	var arch string
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = "x86_64"
+	case "arm64":
+		arch = "aarch64"
+	default:
+		return "", fmt.Errorf("rust is not available for arch=%s", runtime.GOARCH)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return arch + "-unknown-linux-gnu", nil
+	case "darwin":
+		return arch + "-apple-darwin", nil
+	}
+	return "", fmt.Errorf("rust can not be installed on os=%s, build the tool with a rust that is installed instead", runtime.GOOS)
+}
+
+// artifactKey returns the key of the binary built from the tool, which is the tree of the tool at the commit it is
+// checked out at, so the binary is built once for each change to the tool. The binary is not cached if the tool is
+// not in a git checkout.
+func (r *Runtime) artifactKey(ctx context.Context, toolSource string) string {
+	out, err := exec.CommandContext(ctx, "git", "-C", toolSource, "rev-parse", "HEAD:./").Output()
+	if err != nil {
+		log.Debugf("not caching the build of %s, failed to find its commit: %v", toolSource, err)
+		return ""
+	}
+	return hash.ID(r.ID(), runtime.GOOS, runtime.GOARCH, strings.TrimSpace(string(out)))
+}
+
+// build builds the binary of the tool with cargo, and saves it as bin/gptscript-rust-tool in the tool. A binary that
+// was built before for the same commit is used instead of building it again.
+func (r *Runtime) build(ctx context.Context, dataRoot, toolSource, binDir string, env []string) error {
+	artifact := filepath.Join(toolSource, "bin", artifactName())
+
+	var cached string
+	if key := r.artifactKey(ctx, toolSource); key != "" {
+		cached = filepath.Join(dataRoot, "rust", "artifacts", key, artifactName())
+		if _, err := os.Stat(cached); err == nil {
+			log.Infof("Using the binary of %s built before", toolSource)
+			return copyFile(artifact, cached)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	root, err := os.MkdirTemp("", "gptscript-rust-build")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(root)
+	defer cache.Use(root)()
+
+	args := []string{"install", "--path", ".", "--root", root}
+	if _, err := os.Stat(filepath.Join(toolSource, "Cargo.lock")); err == nil {
+		args = append(args, "--locked")
+	}
+
+	log.Infof("Running cargo install in %s", toolSource)
+	cmd := debugcmd.New(ctx, filepath.Join(binDir, "cargo"), args...)
+	cmd.Env = env
+	cmd.Dir = toolSource
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "bin"))
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return fmt.Errorf("tool %s must build exactly one binary, found %v", toolSource, names)
+	}
+
+	built := filepath.Join(root, "bin", entries[0].Name())
+	if err := copyFile(artifact, built); err != nil {
+		return err
+	}
+	if cached != "" {
+		return copyFile(cached, built)
+	}
+	return nil
+}
+
+func copyFile(dst, src string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// getReleaseAndDigest returns the release for this platform and its digest, which is published next to the release.
+func (r *Runtime) getReleaseAndDigest(ctx context.Context) (string, string, string, error) {
+	target, err := target()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	url := fmt.Sprintf(downloadURL, r.Version, target)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+".sha256", nil)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	resp, err := (&http.Client{Transport: tlsconfig.FromContext(ctx)}).Do(req)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("failed to find %s release for %s: %s", r.ID(), target, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	digest := digestPattern.Find(data)
+	if digest == nil {
+		return "", "", "", fmt.Errorf("failed to find digest of %s in %s.sha256", r.ID(), url)
+	}
+
+	return url, strings.ToLower(string(digest)), target, nil
+}
+
+func (r *Runtime) getRuntime(ctx context.Context, cwd string) (string, error) {
+	// The release is named by its version, so an existing install is used without checking its digest again
+	target := filepath.Join(cwd, "rust", hash.ID(r.ID(), runtime.GOOS, runtime.GOARCH))
+	if _, err := os.Stat(target); err == nil {
+		return filepath.Join(target, "bin"), nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	url, sha, triple, err := r.getReleaseAndDigest(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Downloading Rust %s", r.Version)
+	tmp := target + ".download"
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		return "", err
+	}
+
+	if err := download.Extract(ctx, url, sha, src); err != nil {
+		return "", err
+	}
+
+	// The release has an installer that puts its components together in prefix, from where they can be moved
+	prefix := filepath.Join(tmp, "rust")
+	cmd := debugcmd.New(ctx, "sh", "install.sh", "--prefix="+prefix, "--disable-ldconfig",
+		"--components=rustc,cargo,rust-std-"+triple)
+	cmd.Dir = filepath.Join(src, fmt.Sprintf("rust-%s-%s", r.Version, triple))
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(prefix, target); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(target, "bin"), nil
+}
@@ -0,0 +1,35 @@
+package rust
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCacheHome = lo.Must(xdg.CacheFile("gptscript-test-cache/runtime"))
+)
+
+func TestRuntime(t *testing.T) {
+	t.Cleanup(func() {
+		_ = os.RemoveAll("testdata/bin")
+		_ = os.RemoveAll("testdata/target")
+		_ = os.Remove("testdata/Cargo.lock")
+	})
+
+	r := Runtime{
+		Version: "1.82.0",
+	}
+
+	s, err := r.Setup(context.Background(), testCacheHome, "testdata", os.Environ())
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(s[0], "/bin"), "missing /bin: %s", s)
+	assert.FileExists(t, filepath.Join("testdata", "bin", artifactName()))
+}
@@ -0,0 +1,162 @@
+package repos
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VersionedRuntime is implemented by runtimes that can set up the versions of their language that tools require.
+type VersionedRuntime interface {
+	// Language is the name of the language in the version constraints of tools, like python or node
+	Language() string
+	// Versions returns the versions of the language that the runtime knows how to set up, without looking up releases
+	Versions() []string
+	// WithVersion returns the runtime that sets up one of the versions, and runs the commands of the language
+	WithVersion(version string) Runtime
+}
+
+// Constraint is the range of versions of a language that a tool requires, like "python >=3.11,<3.13" or
+// "node 20.x".
+type Constraint struct {
+	Language string
+	terms    []versionTerm
+}
+
+type versionTerm struct {
+	op      string
+	version []int
+	// prefix terms match the versions that start with version, like 20.x or 3.11
+	prefix bool
+}
+
+// ParseConstraint parses a constraint of a tool. The terms of the range are separated by commas or spaces, and
+// are either a comparison, with >=, >, <=, <, = or !=, or a version that matches the versions starting with it,
+// like 3.11, 20.x or 1.22.*.
+func ParseConstraint(s string) (*Constraint, error) {
+	language, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+	if language == "" {
+		return nil, fmt.Errorf("invalid runtime %q, it must start with the language, like python >=3.11", s)
+	}
+
+	c := &Constraint{
+		Language: language,
+	}
+	for _, term := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		var t versionTerm
+		for _, op := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+			if v, ok := strings.CutPrefix(term, op); ok {
+				t.op, term = strings.TrimPrefix(op, "="), v
+				break
+			}
+		}
+
+		var parts []string
+		for _, part := range strings.Split(strings.TrimPrefix(term, "v"), ".") {
+			if part == "x" || part == "X" || part == "*" {
+				t.prefix = true
+				break
+			}
+			parts = append(parts, part)
+		}
+		if t.op == "" || t.op == "=" {
+			t.prefix = true
+		}
+
+		version, err := parseVersion(strings.Join(parts, "."))
+		if err != nil || (t.op != "" && t.op != "=" && len(parts) < len(strings.Split(term, "."))) {
+			return nil, fmt.Errorf("invalid version %q in runtime %q", term, s)
+		}
+		t.version = version
+		c.terms = append(c.terms, t)
+	}
+
+	return c, nil
+}
+
+// Matches returns whether the version is in the range of the constraint.
+func (c *Constraint) Matches(version string) bool {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.terms {
+		if !t.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t versionTerm) matches(v []int) bool {
+	if t.prefix {
+		if len(v) < len(t.version) {
+			return false
+		}
+		return compareVersions(v[:len(t.version)], t.version) == 0
+	}
+
+	cmp := compareVersions(v, t.version)
+	switch t.op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	}
+	return false
+}
+
+func parseVersion(s string) (result []int, _ error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(s, "v"), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// compareVersions compares versions component by component, missing components are 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// sortVersions sorts the versions from the newest to the oldest, the versions that can not be parsed last.
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, errA := parseVersion(versions[i])
+		b, errB := parseVersion(versions[j])
+		if errA != nil || errB != nil {
+			return errB != nil && errA == nil
+		}
+		return compareVersions(a, b) > 0
+	})
+}
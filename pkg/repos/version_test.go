@@ -0,0 +1,88 @@
+package repos
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraint(t *testing.T) {
+	for _, test := range []struct {
+		constraint string
+		matches    []string
+		misses     []string
+	}{
+		{"python >=3.11,<3.13", []string{"3.11", "3.12", "3.12.4"}, []string{"3.10", "3.13"}},
+		{"node 20.x", []string{"20.11.1", "20"}, []string{"21.7.0", "2"}},
+		{"go 1.22", []string{"1.22.1", "1.22"}, []string{"1.2", "1.23.0"}},
+		{"ruby >3.2 !=3.3.0", []string{"3.3.5", "3.2.1"}, []string{"3.2", "3.3.0"}},
+		{"java", []string{"21"}, nil},
+	} {
+		c, err := ParseConstraint(test.constraint)
+		require.NoError(t, err)
+		for _, version := range test.matches {
+			assert.True(t, c.Matches(version), "%s should match %s", test.constraint, version)
+		}
+		for _, version := range test.misses {
+			assert.False(t, c.Matches(version), "%s should not match %s", test.constraint, version)
+		}
+	}
+
+	for _, constraint := range []string{"", "python >=3.x", "node abc"} {
+		_, err := ParseConstraint(constraint)
+		assert.Error(t, err, constraint)
+	}
+}
+
+type testRuntime struct {
+	noopRuntime
+	version  string
+	versions []string
+}
+
+func (r testRuntime) ID() string {
+	return "test" + r.version
+}
+
+func (r testRuntime) Supports(cmd []string) bool {
+	return len(cmd) > 0 && cmd[0] == "test"
+}
+
+func (r testRuntime) Language() string {
+	return "test"
+}
+
+func (r testRuntime) Versions() []string {
+	return r.versions
+}
+
+func (r testRuntime) WithVersion(version string) Runtime {
+	r.version = version
+	return r
+}
+
+func TestConstrainedRuntime(t *testing.T) {
+	m := New(t.TempDir(), testRuntime{
+		version:  "2",
+		versions: []string{"1.9", "2.1", "1.10"},
+	})
+
+	tool := types.Tool{
+		Parameters: types.Parameters{
+			Runtime: "test <2",
+		},
+	}
+	runtime, err := m.constrainedRuntime(tool, []string{"test"})
+	require.NoError(t, err)
+	assert.Equal(t, "test1.10", runtime.ID())
+
+	tool.Runtime = "test 3.x"
+	_, err = m.constrainedRuntime(tool, []string{"test"})
+	assert.ErrorContains(t, err, "[2.1 1.10 1.9]")
+
+	tool.Runtime = "other 1.x"
+	_, err = m.constrainedRuntime(tool, []string{"test"})
+	assert.ErrorContains(t, err, "is not run by a other runtime")
+}
@@ -0,0 +1,228 @@
+// Package review commits the changes of runs to a review branch of the git repository they run in, so the changes
+// can be reviewed, merged and reverted with git.
+package review
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// BranchPrefix is the prefix of the review branches, the runs in a review branch add their commits to it.
+const BranchPrefix = "gptscript/review-"
+
+// Session is a review branch, with the commits of the runs since its base.
+type Session struct {
+	// Dir is the top level directory of the repository
+	Dir    string
+	Branch string
+	// Base is the commit the review branch started at
+	Base string
+	// From is the branch the review branch started from, empty if it started from a detached HEAD
+	From string
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func topLevel(ctx context.Context, dir string) (string, error) {
+	top, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%s is not in a git repository", dir)
+	}
+	return top, nil
+}
+
+// currentBranch returns the branch of HEAD, or empty if HEAD is detached.
+func currentBranch(ctx context.Context, dir string) string {
+	branch, _ := git(ctx, dir, "symbolic-ref", "--short", "-q", "HEAD")
+	return branch
+}
+
+// Start returns the review branch of the repository of dir. When HEAD is not a review branch, a review branch is
+// created from it and checked out. The repository must have no changes, so that the commits of the review only
+// have the changes of runs.
+func Start(ctx context.Context, dir string) (*Session, error) {
+	top, err := topLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if status, err := git(ctx, top, "status", "--porcelain"); err != nil {
+		return nil, err
+	} else if status != "" {
+		return nil, fmt.Errorf("%s has uncommitted changes, commit or stash them so the changes of the run can be reviewed", top)
+	}
+
+	if branch := currentBranch(ctx, top); strings.HasPrefix(branch, BranchPrefix) {
+		return Current(ctx, top)
+	}
+
+	base, err := git(ctx, top, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Dir:    top,
+		Branch: BranchPrefix + time.Now().Format("20060102-150405"),
+		Base:   base,
+		From:   currentBranch(ctx, top),
+	}
+	if _, err := git(ctx, top, "checkout", "-q", "-b", s.Branch); err != nil {
+		return nil, err
+	}
+	if _, err := git(ctx, top, "config", "branch."+s.Branch+".gptscriptBase", s.Base); err != nil {
+		return nil, err
+	}
+	if s.From != "" {
+		if _, err := git(ctx, top, "config", "branch."+s.Branch+".gptscriptFrom", s.From); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Current returns the review branch that is checked out in the repository of dir.
+func Current(ctx context.Context, dir string) (*Session, error) {
+	top, err := topLevel(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	branch := currentBranch(ctx, top)
+	if !strings.HasPrefix(branch, BranchPrefix) {
+		return nil, fmt.Errorf("%s is not on a review branch, run a script with --git-review to start one", top)
+	}
+
+	base, err := git(ctx, top, "config", "branch."+branch+".gptscriptBase")
+	if err != nil {
+		return nil, fmt.Errorf("the review branch %s has no base commit", branch)
+	}
+	from, _ := git(ctx, top, "config", "branch."+branch+".gptscriptFrom")
+	return &Session{
+		Dir:    top,
+		Branch: branch,
+		Base:   base,
+		From:   from,
+	}, nil
+}
+
+// Commit commits all the changes of the repository to the review branch, with the message and the files it
+// changed, and returns false if there were no changes.
+func (s *Session) Commit(ctx context.Context, message string) (bool, error) {
+	if _, err := git(ctx, s.Dir, "add", "-A"); err != nil {
+		return false, err
+	}
+	if _, err := git(ctx, s.Dir, "diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+
+	stat, err := git(ctx, s.Dir, "diff", "--cached", "--stat")
+	if err != nil {
+		return false, err
+	}
+	message = strings.TrimSpace(message) + "\n\n" + stat + "\n"
+
+	args := append(identity(ctx, s.Dir), "commit", "-q", "-F", "-")
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.Dir}, args...)...)
+	cmd.Stdin = strings.NewReader(message)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("git commit: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return true, nil
+}
+
+// Log returns the commits of the runs in the review branch, from the newest to the oldest, with their subject.
+func (s *Session) Log(ctx context.Context) ([]string, error) {
+	out, err := git(ctx, s.Dir, "log", "--format=%h %s", s.Base+"..HEAD")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// Diff writes the consolidated diff of all the runs of the review branch.
+func (s *Session) Diff(ctx context.Context, w io.Writer) error {
+	return s.diff(ctx, w)
+}
+
+// DiffStat writes the files that all the runs of the review branch changed, and how many lines of them.
+func (s *Session) DiffStat(ctx context.Context, w io.Writer) error {
+	return s.diff(ctx, w, "--stat")
+}
+
+func (s *Session) diff(ctx context.Context, w io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.Dir, "diff"}, append(args, s.Base, "HEAD")...)...)
+	cmd.Stdout, cmd.Stderr = w, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git diff: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Revert reverts the commit of one run, with a commit that undoes it, or when commit is empty, checks out the branch
+// the review started from and deletes the review branch.
+func (s *Session) Revert(ctx context.Context, commit string) error {
+	if commit != "" {
+		sha, err := git(ctx, s.Dir, "rev-parse", "--verify", "-q", commit+"^{commit}")
+		if err != nil || sha == s.Base || !s.contains(ctx, sha) {
+			return fmt.Errorf("%s is not a commit of the review branch %s", commit, s.Branch)
+		}
+		_, err = git(ctx, s.Dir, append(identity(ctx, s.Dir), "revert", "--no-edit", sha)...)
+		return err
+	}
+
+	// The uncommitted changes of the review branch are dropped with it
+	if _, err := git(ctx, s.Dir, "reset", "-q", "--hard"); err != nil {
+		return err
+	}
+	if _, err := git(ctx, s.Dir, "clean", "-q", "-f", "-d"); err != nil {
+		return err
+	}
+	if _, err := git(ctx, s.Dir, "checkout", "-q", s.StartedFrom()); err != nil {
+		return err
+	}
+	_, err := git(ctx, s.Dir, "branch", "-q", "-D", s.Branch)
+	return err
+}
+
+// contains returns whether the commit is between the base of the review branch and its HEAD.
+func (s *Session) contains(ctx context.Context, commit string) bool {
+	if _, err := git(ctx, s.Dir, "merge-base", "--is-ancestor", s.Base, commit); err != nil {
+		return false
+	}
+	_, err := git(ctx, s.Dir, "merge-base", "--is-ancestor", commit, "HEAD")
+	return err == nil
+}
+
+// identity returns the arguments that set the identity of the commits of runs in repositories that have none, like
+// the ones in containers.
+func identity(ctx context.Context, dir string) []string {
+	if email, _ := git(ctx, dir, "config", "user.email"); email != "" {
+		return nil
+	}
+	return []string{"-c", "user.name=gptscript", "-c", "user.email=gptscript@localhost"}
+}
+
+// StartedFrom returns the branch the review branch started from, or its base commit if it started from a detached
+// HEAD.
+func (s *Session) StartedFrom() string {
+	if s.From != "" {
+		return s.From
+	}
+	return s.Base
+}
@@ -0,0 +1,84 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRepo(t *testing.T) string {
+	dir := t.TempDir()
+	ctx := context.Background()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+	} {
+		_, err := git(ctx, dir, args...)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme\n"), 0644))
+	_, err := git(ctx, dir, "add", "-A")
+	require.NoError(t, err)
+	_, err = git(ctx, dir, "commit", "-q", "-m", "initial")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestReview(t *testing.T) {
+	ctx := context.Background()
+	dir := testRepo(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dirty.txt"), []byte("dirty"), 0644))
+	_, err := Start(ctx, dir)
+	assert.ErrorContains(t, err, "uncommitted changes")
+	require.NoError(t, os.Remove(filepath.Join(dir, "dirty.txt")))
+
+	s, err := Start(ctx, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "main", s.From)
+
+	committed, err := s.Commit(ctx, "nothing")
+	require.NoError(t, err)
+	assert.False(t, committed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("changed\n"), 0644))
+	committed, err = s.Commit(ctx, "first run")
+	require.NoError(t, err)
+	assert.True(t, committed)
+
+	// A second run continues the review branch
+	s2, err := Start(ctx, dir)
+	require.NoError(t, err)
+	assert.Equal(t, s.Branch, s2.Branch)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644))
+	_, err = s2.Commit(ctx, "second run")
+	require.NoError(t, err)
+
+	log, err := s2.Log(ctx)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	assert.Contains(t, log[0], "second run")
+
+	var diff bytes.Buffer
+	require.NoError(t, s2.Diff(ctx, &diff))
+	assert.Contains(t, diff.String(), "+changed")
+	assert.Contains(t, diff.String(), "+new")
+
+	assert.ErrorContains(t, s2.Revert(ctx, s2.Base), "is not a commit of the review branch")
+	require.NoError(t, s2.Revert(ctx, log[1][:7]))
+	data, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "readme\n", string(data))
+
+	require.NoError(t, s2.Revert(ctx, ""))
+	assert.Equal(t, "main", currentBranch(ctx, dir))
+	assert.NoFileExists(t, filepath.Join(dir, "new.txt"))
+	_, err = Current(ctx, dir)
+	assert.ErrorContains(t, err, "not on a review branch")
+}
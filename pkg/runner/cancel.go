@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// The kinds of the reasons runs are canceled for.
+const (
+	// CancelUser is a run canceled by its user, like with Ctrl-C, or when the client of the run disconnects
+	CancelUser = "user"
+	// CancelTimeout is a run that took longer than it was allowed to
+	CancelTimeout = "timeout"
+	// CancelBudget is a run that used more than it was allowed to, like tokens or money
+	CancelBudget = "budget"
+	// CancelPolicy is a run stopped by a policy, like one of the organization running it
+	CancelPolicy = "policy"
+)
+
+// CancelReason is why a run was canceled. It is the error of the runs that are canceled, and it is reported in the
+// runCanceled event of the run.
+type CancelReason struct {
+	// Kind is one of CancelUser, CancelTimeout, CancelBudget or CancelPolicy
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+func (c *CancelReason) Error() string {
+	if c.Message == "" {
+		return fmt.Sprintf("the run was canceled (%s)", c.Kind)
+	}
+	return fmt.Sprintf("the run was canceled (%s): %s", c.Kind, c.Message)
+}
+
+// WithCancelReason returns a context that cancel cancels with the reason, so the runs made with it fail with it.
+func WithCancelReason(ctx context.Context) (context.Context, func(reason CancelReason)) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return ctx, func(reason CancelReason) {
+		cancel(&reason)
+	}
+}
+
+// WithTimeout returns a context that cancels the runs made with it after the timeout, with a CancelTimeout reason.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, timeout, &CancelReason{
+		Kind:    CancelTimeout,
+		Message: fmt.Sprintf("the run did not finish within %s", timeout),
+	})
+}
+
+// CancelReasonOf returns why the context was canceled, or nil if it was not. A context that was canceled without a
+// reason was canceled by the user, or timed out if its deadline passed.
+func CancelReasonOf(ctx context.Context) *CancelReason {
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	var reason *CancelReason
+	if cause := context.Cause(ctx); errors.As(cause, &reason) {
+		return reason
+	} else if errors.Is(cause, context.DeadlineExceeded) {
+		return &CancelReason{Kind: CancelTimeout}
+	}
+	return &CancelReason{Kind: CancelUser}
+}
+
+// canceled reports the reason the run of the call was canceled for, if it was, and returns it as the error of the
+// run. The errors of the runs that were not canceled are returned as they are.
+func canceled(callCtx engine.Context, monitor Monitor, err error) error {
+	reason := CancelReasonOf(callCtx.Ctx)
+	if err == nil || reason == nil {
+		return err
+	}
+
+	monitor.Event(Event{
+		Time:         time.Now(),
+		CallContext:  callCtx.GetCallContext(),
+		Type:         EventTypeRunCanceled,
+		Content:      reason.Message,
+		CancelReason: reason,
+	})
+	return reason
+}
+
+type cancelNoteKey struct{}
+
+// withCancelNote returns a context that resumes the chat with a system note of why its last turn was canceled, so the
+// model can explain to the user what happened.
+func withCancelNote(ctx context.Context, reason *CancelReason) context.Context {
+	note := fmt.Sprintf("Your previous response was not finished, the run was canceled by the %s.", reason.Kind)
+	if reason.Kind != CancelUser {
+		note = fmt.Sprintf("Your previous response was not finished, the run was canceled because of a %s.", reason.Kind)
+	}
+	if reason.Message != "" {
+		note += " Reason: " + reason.Message + "."
+	}
+	note += " Briefly tell the user what happened if it is relevant to their next message."
+	return context.WithValue(ctx, cancelNoteKey{}, note)
+}
+
+func cancelNote(ctx context.Context) string {
+	note, _ := ctx.Value(cancelNoteKey{}).(string)
+	return note
+}
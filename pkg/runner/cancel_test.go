@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelReasonOf(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.Nil(t, CancelReasonOf(ctx))
+	cancel()
+	assert.Equal(t, &CancelReason{Kind: CancelUser}, CancelReasonOf(ctx))
+
+	ctx, cancelWithReason := WithCancelReason(context.Background())
+	cancelWithReason(CancelReason{Kind: CancelBudget, Message: "the run used 10000 tokens"})
+	assert.Equal(t, &CancelReason{Kind: CancelBudget, Message: "the run used 10000 tokens"}, CancelReasonOf(ctx))
+	assert.Equal(t, "the run was canceled (budget): the run used 10000 tokens", CancelReasonOf(ctx).Error())
+
+	ctx, cancel = WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.Equal(t, CancelTimeout, CancelReasonOf(ctx).Kind)
+}
+
+func TestCancelNote(t *testing.T) {
+	ctx := withCancelNote(context.Background(), &CancelReason{Kind: CancelTimeout, Message: "the run did not finish within 10m0s"})
+	assert.Equal(t, "Your previous response was not finished, the run was canceled because of a timeout. Reason: the run did not finish within 10m0s. Briefly tell the user what happened if it is relevant to their next message.", cancelNote(ctx))
+	assert.Empty(t, cancelNote(context.Background()))
+}
@@ -0,0 +1,171 @@
+package runner
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"golang.org/x/exp/maps"
+)
+
+type checkpointKey struct{}
+
+// WithCheckpoint returns a context that the runs made with it save their state with as they go, every time a call
+// starts the tool calls that the model responded with and every time one of them finishes. A run that crashes or is
+// interrupted is resumed with Resume from the last state that was saved, the calls that finished keep their results,
+// the calls that were running resume from their own last state, and the calls that had no state yet start over.
+func WithCheckpoint(ctx context.Context, save func(*State) error) context.Context {
+	return context.WithValue(ctx, checkpointKey{}, &checkpointer{
+		save: save,
+	})
+}
+
+type checkpointer struct {
+	lock sync.Mutex
+	save func(*State) error
+	root *frame
+}
+
+// frame is the state of a call that is saved in the checkpoints of its run, with the frames of its tool calls.
+type frame struct {
+	cp           *checkpointer
+	parent       *frame
+	toolID       string
+	callID       string
+	continuation *engine.Return
+	results      map[string]SubCallResult
+	children     map[string]*frame
+}
+
+type frameKey struct{}
+
+// checkpointFrame adds the frame of the call to the checkpoints of its run, and sets it in the context of the call for
+// its tool calls. It returns nil if the run is not checkpointed, or if the call can not be resumed, like the calls of
+// workflows and context tools, so it runs again from the start when the run is resumed.
+func checkpointFrame(callCtx *engine.Context) *frame {
+	cp, _ := callCtx.Ctx.Value(checkpointKey{}).(*checkpointer)
+	if cp == nil {
+		return nil
+	}
+
+	var f *frame
+	if resumable(*callCtx) {
+		cp.lock.Lock()
+		if callCtx.Parent == nil {
+			f = &frame{cp: cp}
+			cp.root = f
+		} else if parent, _ := callCtx.Ctx.Value(frameKey{}).(*frame); parent != nil {
+			f = &frame{
+				cp:     cp,
+				parent: parent,
+				toolID: callCtx.Tool.ID,
+				callID: callCtx.ID,
+			}
+			if parent.children == nil {
+				parent.children = map[string]*frame{}
+			}
+			parent.children[f.callID] = f
+		}
+		cp.lock.Unlock()
+	}
+
+	callCtx.Ctx = context.WithValue(callCtx.Ctx, frameKey{}, f)
+	return f
+}
+
+func frameOf(callCtx engine.Context) *frame {
+	f, _ := callCtx.Ctx.Value(frameKey{}).(*frame)
+	return f
+}
+
+// start saves the state of the call before its tool calls run, with the results of the calls that finished before the
+// run was resumed.
+func (f *frame) start(state *State) {
+	if f == nil {
+		return
+	}
+
+	f.cp.lock.Lock()
+	defer f.cp.lock.Unlock()
+
+	f.continuation = state.Continuation
+	f.results = map[string]SubCallResult{}
+	f.children = map[string]*frame{}
+	if state.Paused {
+		for _, subCall := range state.SubCalls {
+			if subCall.State != nil && subCall.State.Result != nil {
+				f.results[subCall.CallID] = subCall
+			}
+		}
+	}
+	f.cp.saveLocked()
+}
+
+// finish saves the result of a tool call of the call.
+func (f *frame) finish(result SubCallResult) {
+	if f == nil || result.State == nil || result.State.Result == nil {
+		return
+	}
+
+	f.cp.lock.Lock()
+	defer f.cp.lock.Unlock()
+
+	f.results[result.CallID] = result
+	delete(f.children, result.CallID)
+	f.cp.saveLocked()
+}
+
+// exit removes the frame of a call that returned from the frame of its parent.
+func (f *frame) exit() {
+	if f == nil || f.parent == nil {
+		return
+	}
+
+	f.cp.lock.Lock()
+	defer f.cp.lock.Unlock()
+
+	if f.parent.children[f.callID] == f {
+		delete(f.parent.children, f.callID)
+	}
+}
+
+func (c *checkpointer) saveLocked() {
+	state := c.root.state()
+	if state == nil {
+		return
+	}
+	if err := c.save(state); err != nil {
+		log.Errorf("failed to save the checkpoint of the run: %v", err)
+	}
+}
+
+// state returns the state of the call to resume it from, as if it was paused, or nil if the model did not respond to
+// the call yet.
+func (f *frame) state() *State {
+	if f == nil || f.continuation == nil {
+		return nil
+	}
+
+	result := &State{
+		Continuation: f.continuation,
+		Paused:       true,
+	}
+
+	ids := maps.Keys(f.continuation.Calls)
+	sort.Strings(ids)
+	for _, id := range ids {
+		if subCall, ok := f.results[id]; ok {
+			result.SubCalls = append(result.SubCalls, subCall)
+		} else if child := f.children[id]; child != nil {
+			if state := child.state(); state != nil {
+				result.SubCalls = append(result.SubCalls, SubCallResult{
+					ToolID: child.toolID,
+					CallID: id,
+					State:  state,
+				})
+			}
+		}
+	}
+	return result
+}
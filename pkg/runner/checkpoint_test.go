@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointState(t *testing.T) {
+	var saved *State
+	cp := &checkpointer{
+		save: func(state *State) error {
+			saved = state
+			return nil
+		},
+	}
+	root := &frame{cp: cp}
+	cp.root = root
+
+	root.start(&State{
+		Continuation: &engine.Return{
+			Calls: map[string]engine.Call{
+				"a": {ToolID: "one"},
+				"b": {ToolID: "two"},
+				"c": {ToolID: "three"},
+			},
+		},
+	})
+	require.NotNil(t, saved)
+	assert.True(t, saved.Paused)
+	assert.Empty(t, saved.SubCalls)
+
+	// A call that the model responded to is saved with its own state, a call that it did not respond to yet is not
+	child := &frame{cp: cp, parent: root, toolID: "two", callID: "b"}
+	root.children["b"] = child
+	root.children["c"] = &frame{cp: cp, parent: root, toolID: "three", callID: "c"}
+	child.start(&State{
+		Continuation: &engine.Return{
+			Calls: map[string]engine.Call{
+				"x": {ToolID: "four"},
+			},
+		},
+	})
+	require.Len(t, saved.SubCalls, 1)
+	assert.Equal(t, "b", saved.SubCalls[0].CallID)
+	assert.True(t, saved.SubCalls[0].State.Paused)
+
+	result := "done"
+	root.finish(SubCallResult{ToolID: "one", CallID: "a", State: &State{Result: &result}})
+	require.Len(t, saved.SubCalls, 2)
+	assert.Equal(t, "a", saved.SubCalls[0].CallID)
+	assert.Equal(t, "done", *saved.SubCalls[0].State.Result)
+	assert.Equal(t, "b", saved.SubCalls[1].CallID)
+
+	child.exit()
+	assert.NotContains(t, root.children, "b")
+
+	// A resumed call keeps the results of the calls that finished before
+	root.start(saved)
+	assert.Contains(t, root.results, "a")
+	assert.NotContains(t, root.results, "b")
+}
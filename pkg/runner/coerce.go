@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// coerce returns the input of a tool call of the model, with its arguments converted to the types of the arguments
+// of the tool unless the conversion is disabled.
+func (r *Runner) coerce(callCtx engine.Context, call engine.Call) string {
+	if !r.coerceArgs || callCtx.Program == nil {
+		return call.Input
+	}
+	tool := callCtx.Program.ToolSet[call.ToolID]
+	input, coerced := coerceArguments(tool.Arguments, call.Input)
+	if len(coerced) > 0 {
+		log.Debugf("Coerced the arguments of the call of %s: %s", tool.Name, strings.Join(coerced, ", "))
+	}
+	return input
+}
+
+// coerceArguments converts the arguments that a model called a tool with to the types of the schema of the tool, for
+// the mistakes models often make: numbers and booleans sent as strings, or as values when the tool takes strings,
+// objects and arrays sent as JSON strings, and the whole arguments sent as a JSON string of an object. It returns
+// the input unchanged if it is not JSON or nothing was converted, and the descriptions of the conversions.
+func coerceArguments(schema *openapi3.Schema, input string) (string, []string) {
+	if schema == nil || strings.TrimSpace(input) == "" {
+		return input, nil
+	}
+
+	var args any
+	if err := unmarshal(input, &args); err != nil {
+		return input, nil
+	}
+
+	c := coercer{}
+	args = c.value("arguments", schema, args)
+	if len(c.coerced) == 0 {
+		return input, nil
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return input, nil
+	}
+	return string(data), c.coerced
+}
+
+type coercer struct {
+	coerced []string
+}
+
+func (c *coercer) value(path string, schema *openapi3.Schema, v any) any {
+	if schema == nil || v == nil {
+		return v
+	}
+
+	switch schema.Type {
+	case "string":
+		switch t := v.(type) {
+		case json.Number:
+			// The literal of the number, so large numbers, like IDs and phone numbers, are not rounded or in
+			// exponent notation
+			return c.record(path, v, t.String())
+		case bool:
+			return c.record(path, v, fmt.Sprint(t))
+		case map[string]any, []any:
+			data, err := json.Marshal(t)
+			if err != nil {
+				return v
+			}
+			return c.record(path, v, string(data))
+		}
+	case "integer":
+		if s, ok := v.(string); ok {
+			if i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+				return c.record(path, v, i)
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil && f == math.Trunc(f) {
+				return c.record(path, v, int64(f))
+			}
+		}
+	case "number":
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return c.record(path, v, f)
+			}
+		}
+	case "boolean":
+		if s, ok := v.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(s)) {
+			case "true":
+				return c.record(path, v, true)
+			case "false":
+				return c.record(path, v, false)
+			}
+		}
+	case "array":
+		if s, ok := v.(string); ok {
+			var array []any
+			if err := unmarshal(s, &array); err == nil {
+				v = c.record(path, v, array)
+			}
+		}
+		if array, ok := v.([]any); ok && schema.Items != nil {
+			for i, item := range array {
+				array[i] = c.value(fmt.Sprintf("%s[%d]", path, i), schema.Items.Value, item)
+			}
+		}
+	case "object", "":
+		if s, ok := v.(string); ok && schema.Type == "object" {
+			var object map[string]any
+			if err := unmarshal(s, &object); err == nil {
+				v = c.record(path, v, object)
+			}
+		}
+		if object, ok := v.(map[string]any); ok {
+			for key, value := range object {
+				if property := schema.Properties[key]; property != nil {
+					object[key] = c.value(path+"."+key, property.Value, value)
+				}
+			}
+		}
+	}
+	return v
+}
+
+// unmarshal decodes the JSON with the numbers as json.Number, so they keep their literals.
+func unmarshal(data string, v any) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid JSON, it has more than one value")
+	}
+	return nil
+}
+
+func (c *coercer) record(path string, from, to any) any {
+	fromJSON, _ := json.Marshal(from)
+	toJSON, _ := json.Marshal(to)
+	c.coerced = append(c.coerced, fmt.Sprintf("%s from %s to %s", path, fromJSON, toJSON))
+	return to
+}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoerceArguments(t *testing.T) {
+	schema := &openapi3.Schema{
+		Type: "object",
+		Properties: openapi3.Schemas{
+			"id":      openapi3.NewSchemaRef("", openapi3.NewStringSchema()),
+			"phone":   openapi3.NewSchemaRef("", openapi3.NewStringSchema()),
+			"ratio":   openapi3.NewSchemaRef("", openapi3.NewStringSchema()),
+			"count":   openapi3.NewSchemaRef("", openapi3.NewIntegerSchema()),
+			"enabled": openapi3.NewSchemaRef("", openapi3.NewBoolSchema()),
+			"tags":    openapi3.NewSchemaRef("", openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema())),
+		},
+	}
+
+	input, coerced := coerceArguments(schema, `{"id": 10000000, "phone": 15551234567, "ratio": 0.5, "count": "3", "enabled": "true", "tags": "[9007199254740993]"}`)
+	assert.JSONEq(t, `{"id": "10000000", "phone": "15551234567", "ratio": "0.5", "count": 3, "enabled": true, "tags": ["9007199254740993"]}`, input)
+	assert.Len(t, coerced, 7)
+
+	// The numbers that are not converted keep their literals, even above 2^53
+	input, _ = coerceArguments(schema, `{"id": 12345678901234567890, "count": 9007199254740993, "enabled": "yes"}`)
+	assert.Equal(t, `{"count":9007199254740993,"enabled":"yes","id":"12345678901234567890"}`, input)
+
+	input, coerced = coerceArguments(schema, `{"id": "a", "count": 1}`)
+	assert.Equal(t, `{"id": "a", "count": 1}`, input)
+	assert.Empty(t, coerced)
+
+	input, coerced = coerceArguments(schema, `not json`)
+	assert.Equal(t, "not json", input)
+	assert.Empty(t, coerced)
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleOpenAPICredentials(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{"credsStore": "file"}`), 0600))
+	t.Setenv("GPTSCRIPT_CONFIG_FILE", configFile)
+
+	cfg, err := config.ReadCLIConfig("")
+	require.NoError(t, err)
+	store, err := credentials.NewStore(cfg, "default")
+	require.NoError(t, err)
+	require.NoError(t, store.Add(credentials.Credential{
+		ToolName: "api.example.com",
+		Env: map[string]string{
+			"GPTSCRIPT_API_EXAMPLE_COM_TOKEN": "stored",
+			"GPTSCRIPT_API_EXAMPLE_COM_OTHER": "stored",
+		},
+	}))
+
+	inst, err := json.Marshal(engine.OpenAPIInstructions{
+		Server: "https://api.example.com",
+		SecurityInfos: [][]engine.SecurityInfo{{{
+			Name:   "token",
+			Type:   "http",
+			Scheme: "bearer",
+		}}},
+	})
+	require.NoError(t, err)
+	callCtx := engine.Context{Ctx: context.Background()}
+	callCtx.Tool = types.Tool{
+		Instructions: types.OpenAPIPrefix + " '" + string(inst) + "'",
+	}
+	r := &Runner{credCtx: "default"}
+
+	// The credential fills in the variables that are not set
+	env, err := r.handleOpenAPICredentials(callCtx, []string{"GPTSCRIPT_API_EXAMPLE_COM_OTHER=set"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"GPTSCRIPT_API_EXAMPLE_COM_OTHER=set", "GPTSCRIPT_API_EXAMPLE_COM_TOKEN=stored"}, env)
+
+	// The store is not read when the environment already satisfies the security scheme
+	env, err = r.handleOpenAPICredentials(callCtx, []string{"GPTSCRIPT_API_EXAMPLE_COM_TOKEN=set"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GPTSCRIPT_API_EXAMPLE_COM_TOKEN=set"}, env)
+
+	// A config that can not be read does not fail the call
+	require.NoError(t, os.WriteFile(configFile, []byte("not json"), 0600))
+	env, err = r.handleOpenAPICredentials(callCtx, []string{"PATH=/bin"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PATH=/bin"}, env)
+}
@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// refExpr is a reference of a workflow, like ${input.repo | lower}: a value, and the filters that transform it in
+// order.
+type refExpr struct {
+	// value is input, input.<arg>, steps.<id>.output, env.<name>, date, time, timestamp, or a quoted string
+	value   string
+	literal bool
+	filters []refFilter
+}
+
+type refFilter struct {
+	name string
+	args []string
+}
+
+// refFilters are the filters of references, by name, with the number of their arguments.
+var refFilters = map[string]int{
+	"lower":   0,
+	"upper":   0,
+	"trim":    0,
+	"default": 1,
+	"replace": 2,
+}
+
+// parseRef parses the text of a reference, between ${ and }.
+func parseRef(ref string) (result refExpr, _ error) {
+	for i, segment := range splitUnquoted(ref, '|') {
+		tokens, err := refTokens(segment)
+		if err != nil {
+			return result, err
+		}
+		if len(tokens) == 0 {
+			return result, fmt.Errorf("empty expression in ${%s}", ref)
+		}
+
+		if i == 0 {
+			if len(tokens) != 1 {
+				return result, fmt.Errorf("unknown reference ${%s}", ref)
+			}
+			result.value, result.literal = tokens[0].text, tokens[0].quoted
+			continue
+		}
+
+		name := tokens[0].text
+		arity, ok := refFilters[name]
+		if !ok || tokens[0].quoted {
+			return result, fmt.Errorf("unknown filter %s in ${%s}", name, ref)
+		}
+		if len(tokens)-1 != arity {
+			return result, fmt.Errorf("filter %s takes %d arguments, not %d, in ${%s}", name, arity, len(tokens)-1, ref)
+		}
+		filter := refFilter{name: name}
+		for _, token := range tokens[1:] {
+			filter.args = append(filter.args, token.text)
+		}
+		result.filters = append(result.filters, filter)
+	}
+	return result, nil
+}
+
+// apply returns the value transformed by the filters of the reference.
+func (e refExpr) apply(value string) string {
+	for _, filter := range e.filters {
+		switch filter.name {
+		case "lower":
+			value = strings.ToLower(value)
+		case "upper":
+			value = strings.ToUpper(value)
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "default":
+			if strings.TrimSpace(value) == "" {
+				value = filter.args[0]
+			}
+		case "replace":
+			value = strings.ReplaceAll(value, filter.args[0], filter.args[1])
+		}
+	}
+	return value
+}
+
+type refToken struct {
+	text   string
+	quoted bool
+}
+
+// refTokens splits a segment of a reference into words and quoted strings, like replace " " "-".
+func refTokens(segment string) (result []refToken, _ error) {
+	for rest := strings.TrimSpace(segment); rest != ""; rest = strings.TrimLeftFunc(rest, unicode.IsSpace) {
+		if rest[0] != '"' {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			result = append(result, refToken{text: rest[:end]})
+			rest = rest[end:]
+			continue
+		}
+
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string in %q: %w", segment, err)
+		}
+		text, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, refToken{text: text, quoted: true})
+		rest = rest[len(quoted):]
+	}
+	return result, nil
+}
+
+// splitUnquoted splits the text at the separators that are not in a quoted string.
+func splitUnquoted(text string, sep byte) (result []string) {
+	var (
+		start   int
+		quoted  bool
+		escaped bool
+	)
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			result = append(result, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, text[start:])
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandRefs(t *testing.T) {
+	vars := workflowVars{
+		input:   `{"repo": "My Repo", "count": 3}`,
+		args:    map[string]any{"repo": "My Repo", "count": 3},
+		outputs: map[string]string{"build": "  ok\n"},
+		env:     map[string]string{"REGION": "us-east-1"},
+		now:     time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC),
+	}
+
+	assert.Equal(t, "my-repo-backup-2024-06-01", vars.expand(`${input.repo | lower | replace " " "-"}-backup-${date}`))
+	assert.Equal(t, "US-EAST-1 3", vars.expand("${env.REGION|upper} ${input.count}"))
+	assert.Equal(t, "ok", vars.expand("${ steps.build.output | trim }"))
+	assert.Equal(t, "main", vars.expand(`${input.branch | default "main"}`))
+	assert.Equal(t, "a|b", vars.expand(`${"a|b"}`))
+	assert.Equal(t, "2024-06-01T09:30:00Z 1717234200", vars.expand("${time} ${timestamp}"))
+}
+
+func TestStepRefs(t *testing.T) {
+	steps := map[string]struct{}{"build": {}}
+
+	refs, err := stepRefs(`${steps.build.output | trim} ${env.HOME} ${input.x | default "y"}`, steps)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build"}, refs)
+
+	_, err = stepRefs("${input | reverse}", steps)
+	assert.ErrorContains(t, err, "unknown filter reverse")
+	_, err = stepRefs("${input | replace \"a\"}", steps)
+	assert.ErrorContains(t, err, "takes 2 arguments")
+	_, err = stepRefs("${output}", steps)
+	assert.ErrorContains(t, err, "unknown reference")
+	_, err = stepRefs("${steps.test.output}", steps)
+	assert.ErrorContains(t, err, "unknown step test")
+}
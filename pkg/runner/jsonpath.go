@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/maps"
+)
+
+// jsonPath is a parsed JSONPath expression. Only the child operators are supported: $, .name, ['name'], [index]
+// with negative indexes counting from the end, and the wildcards .* and [*].
+type jsonPath struct {
+	text     string
+	segments []jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseJSONPath(text string) (*jsonPath, error) {
+	p := &jsonPath{
+		text: text,
+	}
+
+	rest := strings.TrimSpace(text)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: it must start with $", text)
+	}
+	rest = rest[1:]
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			return nil, fmt.Errorf("invalid JSONPath %q: recursive descent is not supported", text)
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty name", text)
+			}
+			rest = rest[end:]
+			if key == "*" {
+				p.segments = append(p.segments, jsonPathSegment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, jsonPathSegment{key: key})
+			}
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSONPath %q: missing ]", text)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			switch {
+			case selector == "*":
+				p.segments = append(p.segments, jsonPathSegment{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				p.segments = append(p.segments, jsonPathSegment{key: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: unsupported selector [%s]", text, selector)
+				}
+				p.segments = append(p.segments, jsonPathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", text, rest)
+		}
+	}
+
+	return p, nil
+}
+
+// hasWildcard returns whether the path can match more than one value.
+func (p *jsonPath) hasWildcard() bool {
+	for _, segment := range p.segments {
+		if segment.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the values that the path matches in data, which is decoded JSON.
+func (p *jsonPath) find(data any) []any {
+	matches := []any{data}
+	for _, segment := range p.segments {
+		var next []any
+		for _, match := range matches {
+			switch v := match.(type) {
+			case map[string]any:
+				if segment.wildcard {
+					keys := maps.Keys(v)
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, v[key])
+					}
+				} else if value, ok := v[segment.key]; ok && !segment.isIndex {
+					next = append(next, value)
+				}
+			case []any:
+				if segment.wildcard {
+					next = append(next, v...)
+				} else if segment.isIndex {
+					index := segment.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		matches = next
+	}
+	return matches
+}
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+)
+
+// maxLoopArguments is the number of characters of the arguments of a repeated call that its error shows
+const maxLoopArguments = 200
+
+// ErrCallLoop is the error of a run that was interrupted since it looks like its tools call each other in a loop: a
+// call is deeper than the maximum call depth, or a model called a tool with the same arguments more times than the
+// maximum of repeated calls.
+type ErrCallLoop struct {
+	Tool string
+	// Depth is the depth of the call that was too deep, 0 if the call was repeated too many times
+	Depth int
+	// Repeats is the number of times the tool was called with the same arguments, 0 if the call was too deep
+	Repeats   int
+	Arguments string
+	Limit     int
+}
+
+func (e *ErrCallLoop) Error() string {
+	if e.Depth > 0 {
+		return fmt.Sprintf("interrupted the run, the call of tool [%s] is %d calls deep, deeper than the maximum of %d, the tools may be calling each other in a loop",
+			e.Tool, e.Depth, e.Limit)
+	}
+	args := e.Arguments
+	if utf8.RuneCountInString(args) > maxLoopArguments {
+		args = string([]rune(args)[:maxLoopArguments]) + "..."
+	}
+	return fmt.Sprintf("interrupted the run, tool [%s] was called %d times with the same arguments, more than the maximum of %d, the model may be calling it in a loop: %s",
+		e.Tool, e.Repeats, e.Limit, args)
+}
+
+type callCountsKey struct{}
+
+// callCounts are the numbers of times that the models of a run called the tools with the same arguments, by tool and
+// arguments.
+type callCounts struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func withCallCounts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callCountsKey{}, &callCounts{
+		counts: map[string]int{},
+	})
+}
+
+func (c *callCounts) add(toolID, input string) int {
+	if c == nil {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.counts[toolID+"\x00"+input]++
+	return c.counts[toolID+"\x00"+input]
+}
+
+// checkDepth returns an ErrCallLoop if the call is deeper than the maximum call depth of the runs, and reports it with
+// a callLoop event.
+func (r *Runner) checkDepth(callCtx engine.Context, monitor Monitor) error {
+	if r.maxCallDepth <= 0 {
+		return nil
+	}
+
+	var depth int
+	for parent := callCtx.Parent; parent != nil; parent = parent.Parent {
+		depth++
+	}
+	if depth <= r.maxCallDepth {
+		return nil
+	}
+
+	return callLoop(callCtx, monitor, &ErrCallLoop{
+		Tool:  callCtx.Tool.Parameters.Name,
+		Depth: depth,
+		Limit: r.maxCallDepth,
+	})
+}
+
+// checkRepeats counts the tool call that the model of the call responded with, and returns an ErrCallLoop if the
+// model called the tool with the same arguments more times than the maximum of repeated calls of the runs, and reports
+// it with a callLoop event.
+func (r *Runner) checkRepeats(callCtx engine.Context, monitor Monitor, toolID, input string) error {
+	if r.maxRepeatedCalls <= 0 {
+		return nil
+	}
+
+	counts, _ := callCtx.Ctx.Value(callCountsKey{}).(*callCounts)
+	repeats := counts.add(toolID, input)
+	if repeats <= r.maxRepeatedCalls {
+		return nil
+	}
+
+	tool, _ := callCtx.Program.GetToolByID(toolID)
+	return callLoop(callCtx, monitor, &ErrCallLoop{
+		Tool:      tool.Parameters.Name,
+		Repeats:   repeats,
+		Arguments: input,
+		Limit:     r.maxRepeatedCalls,
+	})
+}
+
+func callLoop(callCtx engine.Context, monitor Monitor, err *ErrCallLoop) error {
+	monitor.Event(Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeCallLoop,
+		Content:     err.Error(),
+	})
+	return err
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordEvents struct {
+	noopMonitor
+	events []Event
+}
+
+func (r *recordEvents) Event(event Event) {
+	r.events = append(r.events, event)
+}
+
+func TestCheckRepeats(t *testing.T) {
+	prg := types.Program{
+		ToolSet: types.ToolSet{
+			"search": {Parameters: types.Parameters{Name: "search"}},
+		},
+	}
+	r := &Runner{maxRepeatedCalls: 2}
+	callCtx := engine.NewContext(withCallCounts(context.Background()), &prg)
+	monitor := &recordEvents{}
+
+	require.NoError(t, r.checkRepeats(callCtx, monitor, "search", `{"q": "go"}`))
+	require.NoError(t, r.checkRepeats(callCtx, monitor, "search", `{"q": "go"}`))
+	require.NoError(t, r.checkRepeats(callCtx, monitor, "search", `{"q": "rust"}`))
+
+	err := r.checkRepeats(callCtx, monitor, "search", `{"q": "go"}`)
+	var loop *ErrCallLoop
+	require.ErrorAs(t, err, &loop)
+	assert.Equal(t, 3, loop.Repeats)
+	assert.Equal(t, "search", loop.Tool)
+	require.Len(t, monitor.events, 1)
+	assert.Equal(t, EventTypeCallLoop, monitor.events[0].Type)
+	assert.Equal(t, err.Error(), monitor.events[0].Content)
+}
+
+func TestCheckDepth(t *testing.T) {
+	prg := types.Program{
+		ToolSet: types.ToolSet{
+			"recurse": {Parameters: types.Parameters{Name: "recurse"}},
+		},
+	}
+	r := &Runner{maxCallDepth: 2}
+	monitor := &recordEvents{}
+
+	// Every call has a context of its own, that the next call points to as its parent
+	root := engine.NewContext(context.Background(), &prg)
+	parent := &root
+	for depth := 1; depth <= 2; depth++ {
+		callCtx, err := parent.SubCall(context.Background(), "recurse", "", engine.NoCategory)
+		require.NoError(t, err)
+		require.NoError(t, r.checkDepth(callCtx, monitor))
+		parent = &callCtx
+	}
+
+	callCtx, err := parent.SubCall(context.Background(), "recurse", "", engine.NoCategory)
+	require.NoError(t, err)
+	var loop *ErrCallLoop
+	require.ErrorAs(t, r.checkDepth(callCtx, monitor), &loop)
+	assert.Equal(t, 3, loop.Depth)
+}
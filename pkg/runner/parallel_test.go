@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcherLimit(t *testing.T) {
+	const calls = 8
+	for _, limit := range []int{1, 2, 4} {
+		var (
+			d                   = (&Runner{maxParallel: limit}).newDispatcher(context.Background())
+			running, maxRunning atomic.Int32
+			started             = make(chan struct{}, calls)
+			release             = make(chan struct{})
+			done                = make(chan error, 1)
+		)
+
+		// Run waits while the calls that run are at the limit, so the calls are dispatched as the others finish
+		go func() {
+			for i := 0; i < calls; i++ {
+				d.Run(func(context.Context) error {
+					n := running.Add(1)
+					for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+					}
+					started <- struct{}{}
+					<-release
+					running.Add(-1)
+					return nil
+				})
+			}
+			done <- d.Wait()
+		}()
+
+		// The calls up to the limit run at the same time, and a call only finishes once the limit is reached
+		for i := 0; i < calls; i++ {
+			<-started
+			if i >= limit-1 {
+				release <- struct{}{}
+			}
+		}
+		for i := 0; i < limit-1; i++ {
+			release <- struct{}{}
+		}
+		require.NoError(t, <-done)
+		assert.Equal(t, int32(limit), maxRunning.Load())
+	}
+}
+
+func TestCallOrder(t *testing.T) {
+	toolCall := func(id string) types.ContentPart {
+		return types.ContentPart{ToolCall: &types.CompletionToolCall{ID: id}}
+	}
+	ret := &engine.Return{
+		State: &engine.State{
+			Completion: types.CompletionRequest{
+				Messages: []types.CompletionMessage{{
+					Role:    types.CompletionMessageRoleTypeAssistant,
+					Content: []types.ContentPart{{Text: "Fetching the pages"}, toolCall("call_2"), toolCall("call_10"), toolCall("call_1")},
+				}},
+			},
+		},
+		Calls: map[string]engine.Call{
+			"call_1":  {},
+			"call_2":  {},
+			"call_10": {},
+			"extra_b": {},
+			"extra_a": {},
+		},
+	}
+
+	// The calls are in the order of the model, not of their IDs, and the ones it did not make are last
+	assert.Equal(t, []string{"call_2", "call_10", "call_1", "extra_a", "extra_b"}, callOrder(ret))
+
+	ret.State = nil
+	assert.Equal(t, []string{"call_1", "call_10", "call_2", "extra_a", "extra_b"}, callOrder(ret))
+}
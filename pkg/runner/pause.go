@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/secrets"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"golang.org/x/exp/maps"
+)
+
+type pauseKey struct{}
+
+// WithPause returns a context that pauses the runs made with it at their next safe point, once paused is closed. The
+// safe points are between the tool calls, after the model responds with the calls and before they run.
+func WithPause(ctx context.Context, paused <-chan struct{}) context.Context {
+	return context.WithValue(ctx, pauseKey{}, paused)
+}
+
+// ErrPaused is returned by the runs that were paused, with the state to resume them from.
+type ErrPaused struct {
+	State *State
+}
+
+func (e *ErrPaused) Error() string {
+	return "the run was paused"
+}
+
+// pauseRequested returns whether the call should pause.
+func pauseRequested(callCtx engine.Context) bool {
+	paused, _ := callCtx.Ctx.Value(pauseKey{}).(<-chan struct{})
+	if paused == nil {
+		return false
+	}
+	select {
+	case <-paused:
+	default:
+		return false
+	}
+	return resumable(callCtx)
+}
+
+// resumable returns whether the call can be paused and resumed. The calls of workflows and validators, and of the
+// context, credential and input tools can not, since the tools that make them need their results to go on.
+func resumable(callCtx engine.Context) bool {
+	for c := &callCtx; c != nil; c = c.Parent {
+		if c.GetCallContext().ToolCategory != engine.NoCategory || c.Tool.IsWorkflow() || c.Tool.Validator != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Resume resumes a paused run from the state of its ErrPaused, with the program it was run with.
+func (r *Runner) Resume(ctx context.Context, prg types.Program, env []string, state *State) (output string, err error) {
+	ctx, span := traceRun(ctx, &prg, "resume")
+	defer func() {
+		span.End(err)
+	}()
+
+	callCtx := r.newContext(ctx, &prg)
+	monitor, err := r.factory.Start(ctx, &prg, env, "")
+	if err != nil {
+		return "", err
+	}
+	monitor = maskSecrets(monitor, secrets.FromContext(callCtx.Ctx))
+	defer func() {
+		err = canceled(callCtx, monitor, err)
+		reportUsage(callCtx, monitor)
+		monitor.Stop(output, err)
+	}()
+
+	state, err = r.resume(callCtx, monitor, env, state)
+	if err != nil {
+		return "", err
+	}
+	if state.Paused {
+		return "", &ErrPaused{
+			State: state,
+		}
+	}
+	if state.Continuation != nil {
+		return "", &ErrContinuation{
+			State: state,
+		}
+	}
+	return *state.Result, nil
+}
+
+// resumeSubCalls resumes the calls that were paused, the calls that finished before the pause keep their results. The
+// calls that have no state, like the calls that were running when a checkpointed run crashed, start over.
+func (r *Runner) resumeSubCalls(callCtx engine.Context, monitor Monitor, env []string, state *State) (_ *State, callResults []SubCallResult, _ error) {
+	var (
+		d        = r.newDispatcher(callCtx.Ctx)
+		subCalls = map[string]SubCallResult{}
+	)
+	for _, subCall := range state.SubCalls {
+		subCalls[subCall.CallID] = subCall
+	}
+
+	ids := maps.Keys(state.Continuation.Calls)
+	sort.Strings(ids)
+
+	callResults = make([]SubCallResult, len(ids))
+	for i, id := range ids {
+		subCall, ok := subCalls[id]
+		if ok && !subCall.State.Paused {
+			callResults[i] = subCall
+			continue
+		}
+		call := state.Continuation.Calls[id]
+		d.Run(func(ctx context.Context) error {
+			var (
+				result *State
+				err    error
+			)
+			if ok {
+				result, err = r.subCallResume(ctx, callCtx, monitor, env, subCall.ToolID, id, subCall.State)
+			} else {
+				result, err = r.subCall(ctx, callCtx, monitor, env, call.ToolID, r.coerce(callCtx, call), id, "")
+			}
+			if err != nil {
+				return err
+			}
+
+			callResults[i] = SubCallResult{
+				ToolID: call.ToolID,
+				CallID: id,
+				State:  result,
+			}
+			frameOf(callCtx).finish(callResults[i])
+			return nil
+		})
+	}
+
+	if err := d.Wait(); err != nil {
+		return nil, nil, err
+	}
+	return state, callResults, nil
+}
@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -15,8 +19,16 @@ import (
 	context2 "github.com/gptscript-ai/gptscript/pkg/context"
 	"github.com/gptscript-ai/gptscript/pkg/credentials"
 	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/features"
+	"github.com/gptscript-ai/gptscript/pkg/record"
+	"github.com/gptscript-ai/gptscript/pkg/sandbox"
+	"github.com/gptscript-ai/gptscript/pkg/secrets"
+	"github.com/gptscript-ai/gptscript/pkg/step"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+	"github.com/gptscript-ai/gptscript/pkg/tlsconfig"
 	"github.com/gptscript-ai/gptscript/pkg/types"
-	"golang.org/x/exp/maps"
+	"github.com/gptscript-ai/gptscript/pkg/usage"
+	"github.com/gptscript-ai/gptscript/pkg/vars"
 )
 
 type MonitorFactory interface {
@@ -34,8 +46,36 @@ type Options struct {
 	RuntimeManager     engine.RuntimeManager `usage:"-"`
 	StartPort          int64                 `usage:"-"`
 	EndPort            int64                 `usage:"-"`
+	AddressFamily      string                `usage:"-"`
 	CredentialOverride string                `usage:"-"`
 	Sequential         bool                  `usage:"-"`
+	ContainerRuntime   string                `usage:"-"`
+	Sandbox            bool                  `usage:"-"`
+	SandboxImage       string                `usage:"-"`
+	FSRoot             string                `usage:"-"`
+	// DisableArgCoercion passes the arguments that models call tools with as they are, instead of converting them
+	// to the types of the arguments of the tools
+	DisableArgCoercion bool `usage:"-"`
+	// Features are the feature flags of the experimental behaviors of the runs, reported in the callStart event of
+	// their first call
+	Features features.Set `usage:"-"`
+	// MaxParallelToolCalls is the number of the tool calls of a completion that run at a time, all of them if 0
+	MaxParallelToolCalls int `usage:"-"`
+	// Vars are the variables that the runs start with, see vars.Vars
+	Vars map[string]string `usage:"-"`
+	// MaxCallDepth is the depth of the calls of tools that interrupts a run, as a loop of tools calling each other,
+	// no limit if 0
+	MaxCallDepth int `usage:"-"`
+	// MaxRepeatedCalls is the number of times a model of a run may call a tool with the same arguments, the run is
+	// interrupted as a loop when it calls it once more, no limit if 0
+	MaxRepeatedCalls int `usage:"-"`
+	// DryRun does not run the tools that change something outside of the runs, see engine.Engine.DryRun
+	DryRun bool `usage:"-"`
+	// Prices are the prices of the models that the costs of the usage of the runs are of, usage.DefaultPrices if nil
+	Prices usage.Prices `usage:"-"`
+	// Transport sends the outbound HTTP requests of the runs, like the requests of OpenAPI tools and of built-in
+	// tools, http.DefaultTransport if nil
+	Transport http.RoundTripper `usage:"-"`
 }
 
 func complete(opts ...Options) (result Options) {
@@ -44,8 +84,31 @@ func complete(opts ...Options) (result Options) {
 		result.RuntimeManager = types.FirstSet(opt.RuntimeManager, result.RuntimeManager)
 		result.StartPort = types.FirstSet(opt.StartPort, result.StartPort)
 		result.EndPort = types.FirstSet(opt.EndPort, result.EndPort)
+		result.AddressFamily = types.FirstSet(opt.AddressFamily, result.AddressFamily)
 		result.CredentialOverride = types.FirstSet(opt.CredentialOverride, result.CredentialOverride)
 		result.Sequential = types.FirstSet(opt.Sequential, result.Sequential)
+		result.MaxParallelToolCalls = types.FirstSet(opt.MaxParallelToolCalls, result.MaxParallelToolCalls)
+		result.ContainerRuntime = types.FirstSet(opt.ContainerRuntime, result.ContainerRuntime)
+		result.Sandbox = types.FirstSet(opt.Sandbox, result.Sandbox)
+		result.SandboxImage = types.FirstSet(opt.SandboxImage, result.SandboxImage)
+		result.FSRoot = types.FirstSet(opt.FSRoot, result.FSRoot)
+		result.DisableArgCoercion = types.FirstSet(opt.DisableArgCoercion, result.DisableArgCoercion)
+		result.MaxCallDepth = types.FirstSet(opt.MaxCallDepth, result.MaxCallDepth)
+		result.MaxRepeatedCalls = types.FirstSet(opt.MaxRepeatedCalls, result.MaxRepeatedCalls)
+		result.DryRun = types.FirstSet(opt.DryRun, result.DryRun)
+		result.Transport = types.FirstSet(opt.Transport, result.Transport)
+		if result.Prices == nil {
+			result.Prices = opt.Prices
+		}
+		result.Features = opt.Features.Merge(result.Features)
+		for name, value := range opt.Vars {
+			if _, ok := result.Vars[name]; !ok {
+				if result.Vars == nil {
+					result.Vars = map[string]string{}
+				}
+				result.Vars[name] = value
+			}
+		}
 	}
 	if result.MonitorFactory == nil {
 		result.MonitorFactory = noopFactory{}
@@ -60,27 +123,59 @@ func complete(opts ...Options) (result Options) {
 }
 
 type Runner struct {
-	c              engine.Model
-	factory        MonitorFactory
-	runtimeManager engine.RuntimeManager
-	ports          engine.Ports
-	credCtx        string
-	credMutex      sync.Mutex
-	credOverrides  string
-	sequential     bool
+	c                engine.Model
+	factory          MonitorFactory
+	runtimeManager   engine.RuntimeManager
+	ports            engine.Ports
+	credCtx          string
+	credMutex        sync.Mutex
+	credOverrides    string
+	sequential       bool
+	maxParallel      int
+	containerRuntime string
+	sandbox          *sandbox.Sandbox
+	fsRoot           string
+	// coerceArgs converts the arguments of the tool calls of models to the types of the arguments of the tools
+	coerceArgs bool
+	features   features.Set
+	vars       map[string]string
+	// maxCallDepth and maxRepeatedCalls interrupt the runs that call tools in a loop, see ErrCallLoop
+	maxCallDepth     int
+	maxRepeatedCalls int
+	dryRun           bool
+	prices           usage.Prices
+	transport        http.RoundTripper
 }
 
 func New(client engine.Model, credCtx string, opts ...Options) (*Runner, error) {
 	opt := complete(opts...)
 
 	runner := &Runner{
-		c:              client,
-		factory:        opt.MonitorFactory,
-		runtimeManager: opt.RuntimeManager,
-		credCtx:        credCtx,
-		credMutex:      sync.Mutex{},
-		credOverrides:  opt.CredentialOverride,
-		sequential:     opt.Sequential,
+		c:                client,
+		factory:          opt.MonitorFactory,
+		runtimeManager:   opt.RuntimeManager,
+		credCtx:          credCtx,
+		credMutex:        sync.Mutex{},
+		credOverrides:    opt.CredentialOverride,
+		sequential:       opt.Sequential,
+		maxParallel:      opt.MaxParallelToolCalls,
+		containerRuntime: opt.ContainerRuntime,
+		fsRoot:           opt.FSRoot,
+		dryRun:           opt.DryRun,
+		coerceArgs:       !opt.DisableArgCoercion,
+		features:         opt.Features,
+		vars:             opt.Vars,
+		maxCallDepth:     opt.MaxCallDepth,
+		maxRepeatedCalls: opt.MaxRepeatedCalls,
+		prices:           opt.Prices,
+		transport:        opt.Transport,
+	}
+
+	if opt.Sandbox {
+		runner.sandbox = sandbox.New(sandbox.Options{
+			Image:            opt.SandboxImage,
+			ContainerRuntime: opt.ContainerRuntime,
+		})
 	}
 
 	if opt.StartPort != 0 {
@@ -90,6 +185,12 @@ func New(client engine.Model, credCtx string, opts ...Options) (*Runner, error)
 		runner.ports.SetPorts(opt.StartPort, opt.EndPort)
 	}
 
+	host, err := system.Loopback(opt.AddressFamily)
+	if err != nil {
+		return nil, err
+	}
+	runner.ports.SetHost(host)
+
 	return runner, nil
 }
 
@@ -137,15 +238,45 @@ func (r *Runner) Chat(ctx context.Context, prevState ChatState, prg types.Progra
 		}
 	}
 
+	// prev is the state of the chat before this turn, that it goes on from if this turn is canceled
+	prev := state
+	if state != nil && state.Canceled != nil {
+		// The last turn was canceled, so the model is told why
+		ctx = withCancelNote(ctx, state.Canceled)
+		state = state.WithInput(state.ResumeInput)
+		state.Canceled = nil
+	}
+
+	ctx, span := traceRun(ctx, &prg, "chat")
+	defer func() {
+		span.End(err)
+	}()
+
+	callCtx := r.newContext(ctx, &prg)
 	monitor, err := r.factory.Start(ctx, &prg, env, input)
 	if err != nil {
 		return resp, err
 	}
+	monitor = maskSecrets(monitor, secrets.FromContext(callCtx.Ctx))
 	defer func() {
+		err = canceled(callCtx, monitor, err)
+		var reason *CancelReason
+		if errors.As(err, &reason) && prev != nil {
+			canceledState := *prev
+			canceledState.Canceled = reason
+			resp = ChatResponse{
+				Content: reason.Error(),
+				State:   &canceledState,
+			}
+		}
+		if s, ok := resp.State.(*State); ok {
+			resp.State = maskState(secrets.FromContext(callCtx.Ctx), s)
+		}
+		resp.Content = secrets.FromContext(callCtx.Ctx).Apply(resp.Content)
+		reportUsage(callCtx, monitor)
 		monitor.Stop(resp.Content, err)
 	}()
 
-	callCtx := engine.NewContext(ctx, &prg)
 	if state == nil {
 		startResult, err := r.start(callCtx, monitor, env, input)
 		if err != nil {
@@ -155,7 +286,7 @@ func (r *Runner) Chat(ctx context.Context, prevState ChatState, prg types.Progra
 			Continuation: startResult,
 		}
 	} else {
-		state.ResumeInput = &input
+		state = state.WithInput(&input)
 	}
 
 	state, err = r.resume(callCtx, monitor, env, state)
@@ -163,6 +294,12 @@ func (r *Runner) Chat(ctx context.Context, prevState ChatState, prg types.Progra
 		return resp, err
 	}
 
+	if state.Paused {
+		return resp, &ErrPaused{
+			State: state,
+		}
+	}
+
 	if state.Result != nil {
 		return ChatResponse{
 			Done:    true,
@@ -188,18 +325,31 @@ func (r *Runner) Chat(ctx context.Context, prevState ChatState, prg types.Progra
 }
 
 func (r *Runner) Run(ctx context.Context, prg types.Program, env []string, input string) (output string, err error) {
+	ctx, span := traceRun(ctx, &prg, "run")
+	defer func() {
+		span.End(err)
+	}()
+
+	callCtx := r.newContext(ctx, &prg)
 	monitor, err := r.factory.Start(ctx, &prg, env, input)
 	if err != nil {
 		return "", err
 	}
+	monitor = maskSecrets(monitor, secrets.FromContext(callCtx.Ctx))
 	defer func() {
+		err = canceled(callCtx, monitor, err)
+		reportUsage(callCtx, monitor)
 		monitor.Stop(output, err)
 	}()
 
-	callCtx := engine.NewContext(ctx, &prg)
 	state, err := r.call(callCtx, monitor, env, input)
 	if err != nil {
-		return "", nil
+		return "", err
+	}
+	if state.Paused {
+		return "", &ErrPaused{
+			State: state,
+		}
 	}
 	if state.Continuation != nil {
 		return "", &ErrContinuation{
@@ -209,6 +359,18 @@ func (r *Runner) Run(ctx context.Context, prg types.Program, env []string, input
 	return *state.Result, nil
 }
 
+// newContext returns the context of the first call of a run, with the feature flags, the secrets and the variables of
+// the run, the tracker of its usage, and the transport of its requests.
+func (r *Runner) newContext(ctx context.Context, prg *types.Program) engine.Context {
+	ctx = tlsconfig.WithTransport(ctx, r.transport)
+	ctx = features.WithFeatures(ctx, r.features)
+	ctx = secrets.WithSecrets(ctx, secrets.New(r.credCtx))
+	ctx = vars.WithVars(ctx, vars.New(r.vars))
+	ctx = withCallCounts(ctx)
+	ctx = usage.WithTracker(ctx, usage.NewTracker(r.prices))
+	return engine.NewContext(ctx, prg)
+}
+
 type Event struct {
 	Time               time.Time              `json:"time,omitempty"`
 	CallContext        *engine.CallContext    `json:"callContext,omitempty"`
@@ -220,6 +382,14 @@ type Event struct {
 	ChatResponse       any                    `json:"chatResponse,omitempty"`
 	ChatResponseCached bool                   `json:"chatResponseCached,omitempty"`
 	Content            string                 `json:"content,omitempty"`
+	// CancelReason is why the run was canceled, set on its runCanceled event
+	CancelReason *CancelReason `json:"cancelReason,omitempty"`
+	// Features are the feature flags of the run, set on the callStart event of its first call
+	Features map[string]bool `json:"features,omitempty"`
+	// RequestID is the ID that a request of the call to an API was sent with, set on apiRequest events
+	RequestID string `json:"requestID,omitempty"`
+	// Usage is the usage of the calls to models of the run, set on its runUsage event
+	Usage *usage.Report `json:"usage,omitempty"`
 }
 
 type EventType string
@@ -231,6 +401,24 @@ var (
 	EventTypeCallProgress = EventType("callProgress")
 	EventTypeChat         = EventType("callChat")
 	EventTypeCallFinish   = EventType("callFinish")
+	// EventTypeEgressBlocked is a request of a call to a host that its tool does not allow
+	EventTypeEgressBlocked = EventType("egressBlocked")
+	// EventTypeRequestPruned is a request to the model that was pruned to fit the size limit of the provider
+	EventTypeRequestPruned = EventType("requestPruned")
+	// EventTypeAPIRequest is a request of a call to an API that was sent with a request ID
+	EventTypeAPIRequest = EventType("apiRequest")
+	// EventTypeStreamResumed is a streamed response of the model that dropped and was resumed
+	EventTypeStreamResumed = EventType("streamResumed")
+	// EventTypeDownloadProgress is the progress of a file that a call downloads
+	EventTypeDownloadProgress = EventType("downloadProgress")
+	// EventTypeDryRun is a call that was not run, since the run is a dry run, with what it would have run
+	EventTypeDryRun = EventType("dryRun")
+	// EventTypeCallLoop is a call that interrupted its run, since its tools look like they call each other in a loop
+	EventTypeCallLoop = EventType("callLoop")
+	// EventTypeRunCanceled is a run that was canceled, with the reason it was canceled for
+	EventTypeRunCanceled = EventType("runCanceled")
+	// EventTypeRunUsage is the tokens that the calls to models of a run used and their cost, sent when it finishes
+	EventTypeRunUsage = EventType("runUsage")
 )
 
 func (r *Runner) getContext(callCtx engine.Context, monitor Monitor, env []string) (result []engine.InputContext, _ error) {
@@ -255,7 +443,46 @@ func (r *Runner) getContext(callCtx engine.Context, monitor Monitor, env []strin
 	return result, nil
 }
 
-func (r *Runner) call(callCtx engine.Context, monitor Monitor, env []string, input string) (*State, error) {
+// getStdin runs the tool that the input of the tool is piped from, with the same input, and returns its output.
+func (r *Runner) getStdin(callCtx engine.Context, monitor Monitor, env []string, input string) (*string, error) {
+	if callCtx.Tool.InputFrom == "" {
+		return nil, nil
+	}
+
+	toolIDs, err := callCtx.Tool.GetToolIDsFromNames([]string{callCtx.Tool.InputFrom})
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, toolIDs[0], input, "", engine.InputToolCategory)
+	if err != nil {
+		return nil, err
+	}
+	if state.Result == nil {
+		return nil, fmt.Errorf("the tool the input is piped from can not result in a chat continuation")
+	}
+	return state.Result, nil
+}
+
+func (r *Runner) call(callCtx engine.Context, monitor Monitor, env []string, input string) (_ *State, err error) {
+	span := traceCall(&callCtx)
+	defer func() {
+		span.End(err)
+	}()
+
+	if sharded(callCtx.Tool, input) {
+		return r.callSharded(callCtx, monitor, env, input)
+	}
+	if callCtx.Tool.IsWorkflow() {
+		return r.runWorkflow(callCtx, monitor, env, input)
+	}
+	if callCtx.Tool.Validator != "" {
+		return r.callValidated(callCtx, monitor, env, input)
+	}
+	return r.callTool(callCtx, monitor, env, input)
+}
+
+func (r *Runner) callTool(callCtx engine.Context, monitor Monitor, env []string, input string) (*State, error) {
 	result, err := r.start(callCtx, monitor, env, input)
 	if err != nil {
 		return nil, err
@@ -277,32 +504,66 @@ func (r *Runner) start(callCtx engine.Context, monitor Monitor, env []string, in
 		}
 	}
 
+	if callCtx.Tool.IsOpenAPI() {
+		var err error
+		env, err = r.handleOpenAPICredentials(callCtx, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var err error
 	callCtx.InputContext, err = r.getContext(callCtx, monitor, env)
 	if err != nil {
 		return nil, err
 	}
 
+	callCtx.Stdin, err = r.getStdin(callCtx, monitor, env, input)
+	if err != nil {
+		return nil, err
+	}
+
 	e := engine.Engine{
-		Model:          r.c,
-		RuntimeManager: r.runtimeManager,
-		Progress:       progress,
-		Env:            env,
-		Ports:          &r.ports,
+		Model:            r.model(callCtx),
+		RuntimeManager:   r.runtimeManager,
+		Progress:         progress,
+		Env:              env,
+		Ports:            &r.ports,
+		ContainerRuntime: r.containerRuntime,
+		Sandbox:          r.sandbox,
+		FSRoot:           r.fsRoot,
+		DryRun:           r.dryRun,
 	}
 
-	monitor.Event(Event{
+	event := Event{
 		Time:        time.Now(),
 		CallContext: callCtx.GetCallContext(),
 		Type:        EventTypeCallStart,
 		Content:     input,
-	})
+	}
+	if callCtx.Parent == nil {
+		event.Features = r.features.All()
+	}
+	monitor.Event(event)
 
 	callCtx.Ctx = context2.AddPauseFuncToCtx(callCtx.Ctx, monitor.Pause)
 
+	// The outputs of credential tools are not recorded, since they are secrets, so they run when a run is replayed too
+	if callCtx.Tool.IsCommand() && callCtx.ToolCategory != engine.CredentialToolCategory {
+		return record.Tool(callCtx.Ctx, callCtx.Tool.Parameters.Name, input, func() (*engine.Return, error) {
+			return e.Start(callCtx, input)
+		})
+	}
 	return e.Start(callCtx, input)
 }
 
+// model returns the model that the call calls, which is recorded or replayed if its run is, and traced.
+func (r *Runner) model(callCtx engine.Context) engine.Model {
+	model := record.Model(callCtx.Ctx, callCtx.Tool.Parameters.Name, r.c)
+	model = usage.Model(callCtx.Ctx, callCtx.Tool.Parameters.Name, model)
+	return traceModel(callCtx.Tool.Parameters.Name, model)
+}
+
 type State struct {
 	Continuation       *engine.Return `json:"continuation,omitempty"`
 	ContinuationToolID string         `json:"continuationToolID,omitempty"`
@@ -311,6 +572,10 @@ type State struct {
 	ResumeInput *string         `json:"resumeInput,omitempty"`
 	SubCalls    []SubCallResult `json:"subCalls,omitempty"`
 	SubCallID   string          `json:"subCallID,omitempty"`
+	// Paused is set when the call was paused before its calls ran, or when some of its sub calls were paused
+	Paused bool `json:"paused,omitempty"`
+	// Canceled is why the last turn of a chat was canceled, the state is the one before that turn
+	Canceled *CancelReason `json:"canceled,omitempty"`
 }
 
 func (s State) WithInput(input *string) *State {
@@ -353,6 +618,9 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 	progress, progressClose := streamProgress(&callCtx, monitor)
 	defer progressClose()
 
+	checkpoint := checkpointFrame(&callCtx)
+	defer checkpoint.exit()
+
 	if len(callCtx.Tool.Credentials) > 0 {
 		var err error
 		env, err = r.handleCredentials(callCtx, monitor, env)
@@ -368,11 +636,15 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 	}
 
 	e := engine.Engine{
-		Model:          r.c,
-		RuntimeManager: r.runtimeManager,
-		Progress:       progress,
-		Env:            env,
-		Ports:          &r.ports,
+		Model:            r.model(callCtx),
+		RuntimeManager:   r.runtimeManager,
+		Progress:         progress,
+		Env:              env,
+		Ports:            &r.ports,
+		ContainerRuntime: r.containerRuntime,
+		Sandbox:          r.sandbox,
+		FSRoot:           r.fsRoot,
+		DryRun:           r.dryRun,
 	}
 
 	for {
@@ -395,6 +667,17 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 			}, nil
 		}
 
+		if !state.Paused && state.SubCallID == "" && state.ResumeInput == nil && pauseRequested(callCtx) {
+			return &State{
+				Continuation: state.Continuation,
+				Paused:       true,
+			}, nil
+		}
+
+		if state.SubCallID == "" && state.ResumeInput == nil {
+			checkpoint.start(state)
+		}
+
 		monitor.Event(Event{
 			Time:         time.Now(),
 			CallContext:  callCtx.GetCallContext(),
@@ -416,6 +699,16 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 			return nil, err
 		}
 
+		for _, callResult := range callResults {
+			if callResult.State.Paused {
+				return &State{
+					Continuation: state.Continuation,
+					SubCalls:     callResults,
+					Paused:       true,
+				}, nil
+			}
+		}
+
 		var engineResults []engine.CallResult
 		for _, callResult := range callResults {
 			if callResult.State.Continuation == nil {
@@ -434,6 +727,11 @@ func (r *Runner) resume(callCtx engine.Context, monitor Monitor, env []string, s
 		}
 
 		if state.ResumeInput != nil {
+			if note := cancelNote(callCtx.Ctx); note != "" {
+				engineResults = append(engineResults, engine.CallResult{
+					System: note,
+				})
+			}
 			engineResults = append(engineResults, engine.CallResult{
 				User: *state.ResumeInput,
 			})
@@ -466,7 +764,55 @@ func streamProgress(callCtx *engine.Context, monitor Monitor) (chan<- types.Comp
 	go func() {
 		defer wg.Done()
 		for status := range progress {
-			if message := status.PartialResponse; message != nil {
+			if status.EgressBlocked != "" {
+				monitor.Event(Event{
+					Time:        time.Now(),
+					CallContext: callCtx.GetCallContext(),
+					Type:        EventTypeEgressBlocked,
+					Content:     status.EgressBlocked,
+				})
+			} else if status.RequestPruned != "" {
+				monitor.Event(Event{
+					Time:             time.Now(),
+					CallContext:      callCtx.GetCallContext(),
+					Type:             EventTypeRequestPruned,
+					ChatCompletionID: status.CompletionID,
+					Content:          status.RequestPruned,
+				})
+			} else if status.APIRequest != "" {
+				monitor.Event(Event{
+					Time:             time.Now(),
+					CallContext:      callCtx.GetCallContext(),
+					Type:             EventTypeAPIRequest,
+					ChatCompletionID: status.CompletionID,
+					Content:          status.APIRequest,
+					RequestID:        status.RequestID,
+				})
+			} else if status.StreamResumed != "" {
+				monitor.Event(Event{
+					Time:             time.Now(),
+					CallContext:      callCtx.GetCallContext(),
+					Type:             EventTypeStreamResumed,
+					ChatCompletionID: status.CompletionID,
+					Content:          status.StreamResumed,
+				})
+			} else if status.DryRun != "" {
+				monitor.Event(Event{
+					Time:             time.Now(),
+					CallContext:      callCtx.GetCallContext(),
+					Type:             EventTypeDryRun,
+					ChatCompletionID: status.CompletionID,
+					Content:          status.DryRun,
+				})
+			} else if status.DownloadProgress != "" {
+				monitor.Event(Event{
+					Time:             time.Now(),
+					CallContext:      callCtx.GetCallContext(),
+					Type:             EventTypeDownloadProgress,
+					ChatCompletionID: status.CompletionID,
+					Content:          status.DownloadProgress,
+				})
+			} else if message := status.PartialResponse; message != nil {
 				monitor.Event(Event{
 					Time:             time.Now(),
 					CallContext:      callCtx.GetCallContext(),
@@ -502,6 +848,9 @@ func (r *Runner) subCall(ctx context.Context, parentContext engine.Context, moni
 	if err != nil {
 		return nil, err
 	}
+	if err := r.checkDepth(callCtx, monitor); err != nil {
+		return nil, err
+	}
 
 	return r.call(callCtx, monitor, env, input)
 }
@@ -522,16 +871,50 @@ type SubCallResult struct {
 }
 
 func (r *Runner) newDispatcher(ctx context.Context) dispatcher {
-	if r.sequential {
+	if r.sequential || r.maxParallel == 1 {
 		return newSerialDispatcher(ctx)
 	}
-	return newParallelDispatcher(ctx)
+	if r.maxParallel > 0 {
+		return newParallelDispatcher(ctx, r.maxParallel)
+	}
+	if features.Enabled(ctx, features.BoundedParallelism) {
+		return newParallelDispatcher(ctx, runtime.NumCPU())
+	}
+	return newParallelDispatcher(ctx, 0)
+}
+
+// callOrder returns the IDs of the calls of the return in the order the model made them in its last response. The
+// calls that are not in it, which a model does not return, are last in the order of their IDs.
+func callOrder(ret *engine.Return) []string {
+	var ids []string
+	if ret.State != nil && len(ret.State.Completion.Messages) > 0 {
+		for _, content := range ret.State.Completion.Messages[len(ret.State.Completion.Messages)-1].Content {
+			if content.ToolCall == nil {
+				continue
+			}
+			if _, ok := ret.Calls[content.ToolCall.ID]; ok && !slices.Contains(ids, content.ToolCall.ID) {
+				ids = append(ids, content.ToolCall.ID)
+			}
+		}
+	}
+	if len(ids) == len(ret.Calls) {
+		return ids
+	}
+
+	var rest []string
+	for id := range ret.Calls {
+		if !slices.Contains(ids, id) {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	return append(ids, rest...)
 }
 
 func (r *Runner) subCalls(callCtx engine.Context, monitor Monitor, env []string, state *State) (_ *State, callResults []SubCallResult, _ error) {
-	var (
-		resultLock sync.Mutex
-	)
+	if state.Paused && len(state.SubCalls) > 0 {
+		return r.resumeSubCalls(callCtx, monitor, env, state)
+	}
 
 	if state.SubCallID != "" {
 		if state.ResumeInput == nil {
@@ -564,28 +947,57 @@ func (r *Runner) subCalls(callCtx engine.Context, monitor Monitor, env []string,
 		return state, callResults, nil
 	}
 
-	d := r.newDispatcher(callCtx.Ctx)
+	// The calls are in the order of the response of the model, so the results are too however long the calls take
+	ids := callOrder(state.Continuation)
 
-	// Sort the id so if sequential the results are predictable
-	ids := maps.Keys(state.Continuation.Calls)
-	sort.Strings(ids)
+	// The calls are stepped through and checked for a loop before any of them runs
+	var (
+		inputs  = make([]string, len(ids))
+		skipped = make([]bool, len(ids))
+	)
+	for i, id := range ids {
+		call := state.Continuation.Calls[id]
+		tool, _ := callCtx.Program.GetToolByID(call.ToolID)
+		input, skip, err := step.Tool(callCtx.Ctx, tool.Parameters.Name, r.coerce(callCtx, call))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := r.checkRepeats(callCtx, monitor, call.ToolID, input); err != nil {
+			return nil, nil, err
+		}
+		inputs[i], skipped[i] = input, skip
+	}
+
+	d := r.newDispatcher(callCtx.Ctx)
 
-	for _, id := range ids {
+	// Every call only sets its own result, so the results are assembled in the order of the calls
+	callResults = make([]SubCallResult, len(ids))
+	for i, id := range ids {
 		call := state.Continuation.Calls[id]
+		if skipped[i] {
+			result := step.Skipped
+			callResults[i] = SubCallResult{
+				ToolID: call.ToolID,
+				CallID: id,
+				State: &State{
+					Result: &result,
+				},
+			}
+			frameOf(callCtx).finish(callResults[i])
+			continue
+		}
 		d.Run(func(ctx context.Context) error {
-			result, err := r.subCall(ctx, callCtx, monitor, env, call.ToolID, call.Input, id, "")
+			result, err := r.subCall(ctx, callCtx, monitor, env, call.ToolID, inputs[i], id, "")
 			if err != nil {
 				return err
 			}
 
-			resultLock.Lock()
-			defer resultLock.Unlock()
-			callResults = append(callResults, SubCallResult{
+			callResults[i] = SubCallResult{
 				ToolID: call.ToolID,
 				CallID: id,
 				State:  result,
-			})
-
+			}
+			frameOf(callCtx).finish(callResults[i])
 			return nil
 		})
 	}
@@ -707,6 +1119,75 @@ func (r *Runner) handleCredentials(callCtx engine.Context, monitor Monitor, env
 	return env, nil
 }
 
+// handleOpenAPICredentials looks up a credential stored for the server of an OpenAPI tool and adds its values
+// to the environment. This allows the security schemes of the tool (such as apiKey values sent in a header,
+// query parameter, or cookie) to be satisfied from the credential store. Variables already set in the
+// environment take precedence over stored values, and the store is not read at all when they already satisfy one
+// of the security schemes. The credential is optional, so a store that can not be read is logged and the call goes
+// on with the environment it has.
+func (r *Runner) handleOpenAPICredentials(callCtx engine.Context, env []string) ([]string, error) {
+	instructions, err := engine.GetOpenAPIInstructions(callCtx.Tool)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instructions.SecurityInfos) == 0 {
+		return env, nil
+	}
+
+	u, err := url.Parse(instructions.Server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server URL %s: %w", instructions.Server, err)
+	}
+
+	existing := map[string]struct{}{}
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		existing[k] = struct{}{}
+	}
+
+	if slices.ContainsFunc(instructions.SecurityInfos, func(infoSet []engine.SecurityInfo) bool {
+		for _, info := range infoSet {
+			for _, name := range engine.SecurityEnvNames(u.Hostname(), info) {
+				if _, ok := existing[name]; !ok {
+					return false
+				}
+			}
+		}
+		return true
+	}) {
+		return env, nil
+	}
+
+	c, err := config.ReadCLIConfig("")
+	if err != nil {
+		log.Warnf("Not looking up the credential of the server %s, failed to read the CLI config: %v", u.Hostname(), err)
+		return env, nil
+	}
+
+	store, err := credentials.NewStore(c, r.credCtx)
+	if err != nil {
+		log.Warnf("Not looking up the credential of the server %s, failed to create the credential store: %v", u.Hostname(), err)
+		return env, nil
+	}
+
+	cred, exists, err := store.Get(u.Hostname())
+	if err != nil {
+		log.Warnf("Failed to get the credential of the server %s: %v", u.Hostname(), err)
+		return env, nil
+	} else if !exists {
+		return env, nil
+	}
+
+	for k, v := range cred.Env {
+		if _, ok := existing[k]; !ok {
+			env = append(env, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+
+	return env, nil
+}
+
 func isGitHubTool(toolName string) bool {
 	return strings.HasPrefix(toolName, "github.com")
 }
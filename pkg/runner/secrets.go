@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/secrets"
+)
+
+// maskingMonitor masks the values of the secrets that the run read or wrote with sys.secrets in its events and output.
+type maskingMonitor struct {
+	Monitor
+	secrets *secrets.Secrets
+}
+
+func maskSecrets(monitor Monitor, s *secrets.Secrets) Monitor {
+	if s == nil {
+		return monitor
+	}
+	return maskingMonitor{
+		Monitor: monitor,
+		secrets: s,
+	}
+}
+
+func (m maskingMonitor) Event(event Event) {
+	if m.secrets.Masking() {
+		event.Content = m.secrets.Apply(event.Content)
+		event.ChatRequest = maskJSON(m.secrets, event.ChatRequest)
+		event.ChatResponse = maskJSON(m.secrets, event.ChatResponse)
+		if len(event.ToolSubCalls) > 0 {
+			subCalls := make(map[string]engine.Call, len(event.ToolSubCalls))
+			for id, call := range event.ToolSubCalls {
+				call.Input = m.secrets.Apply(call.Input)
+				subCalls[id] = call
+			}
+			event.ToolSubCalls = subCalls
+		}
+	}
+	m.Monitor.Event(event)
+}
+
+func (m maskingMonitor) Stop(output string, err error) {
+	m.Monitor.Stop(m.secrets.Apply(output), err)
+}
+
+// maskJSON returns the value with the secrets masked, as the generic JSON value of the value if it had any to mask.
+func maskJSON(s *secrets.Secrets, v any) any {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	masked := s.ApplyJSON(data)
+	if bytes.Equal(data, masked) {
+		return v
+	}
+	var result any
+	if err := json.Unmarshal(masked, &result); err != nil {
+		return v
+	}
+	return result
+}
+
+// maskState returns the state of a chat with the secrets masked in its messages, so they are not saved in the history
+// of the chat.
+func maskState(s *secrets.Secrets, state *State) *State {
+	if state == nil || !s.Masking() {
+		return state
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return state
+	}
+	masked := s.ApplyJSON(data)
+	if bytes.Equal(data, masked) {
+		return state
+	}
+	result := &State{}
+	if err := json.Unmarshal(masked, result); err != nil {
+		return state
+	}
+	return result
+}
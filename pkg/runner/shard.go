@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/tokenizer"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// defaultShardSize is the size in tokens of the largest input of a tool with Shard With, unless it sets Shard Size
+	defaultShardSize = 32000
+	// maxShardRounds is the number of times the outputs of the shards are sharded again before the input is too large
+	maxShardRounds = 5
+)
+
+// sharded returns whether the input of a tool is too large for it, and is processed by its Shard With tool first.
+func sharded(tool types.Tool, input string) bool {
+	return tool.ShardWith != "" && tokenizer.Count(tool.ModelName, input) > types.FirstSet(tool.ShardSize, defaultShardSize)
+}
+
+// callSharded calls a tool whose input is too large for it with the combined outputs of its Shard With tool for the
+// shards of the input. The shard tool is called for every shard, in parallel, like a map, and the tool is called once
+// with all their outputs, like a reduce. When the combined outputs are still too large, they are sharded again.
+func (r *Runner) callSharded(callCtx engine.Context, monitor Monitor, env []string, input string) (*State, error) {
+	toolIDs, err := callCtx.Tool.GetToolIDsFromNames([]string{callCtx.Tool.ShardWith})
+	if err != nil {
+		return nil, err
+	}
+
+	size := types.FirstSet(callCtx.Tool.ShardSize, defaultShardSize)
+	for round := 1; sharded(callCtx.Tool, input); round++ {
+		if round > maxShardRounds {
+			return nil, fmt.Errorf("the input of tool [%s] is still larger than %d tokens after it was sharded %d times",
+				callCtx.Tool.Parameters.Name, size, maxShardRounds)
+		}
+
+		// The shards are split by size, at the number of bytes per token of the input for the model of the tool
+		shards := splitShards(input, size*len(input)/max(tokenizer.Count(callCtx.Tool.ModelName, input), 1))
+		log.Infof("Sharding the input of tool [%s] into %d shards for [%s]", callCtx.Tool.Parameters.Name, len(shards), callCtx.Tool.ShardWith)
+
+		var (
+			outputs = make([]string, len(shards))
+			d       = r.newDispatcher(callCtx.Ctx)
+		)
+		for i, shard := range shards {
+			d.Run(func(ctx context.Context) error {
+				state, err := r.subCall(ctx, callCtx, monitor, env, toolIDs[0], shard, "", engine.ShardToolCategory)
+				if err != nil {
+					return err
+				}
+				if state.Result == nil {
+					return fmt.Errorf("shard tool can not result in a chat continuation")
+				}
+				outputs[i] = *state.Result
+				return nil
+			})
+		}
+		if err := d.Wait(); err != nil {
+			return nil, err
+		}
+
+		combined := combineShards(outputs)
+		if len(combined) >= len(input) {
+			return nil, fmt.Errorf("the outputs of shard tool [%s] are not smaller than the input of tool [%s]",
+				callCtx.Tool.ShardWith, callCtx.Tool.Parameters.Name)
+		}
+		input = combined
+	}
+
+	return r.call(callCtx, monitor, env, input)
+}
+
+// splitShards splits the text into shards of at most size bytes, at the ends of lines unless a line is longer.
+func splitShards(text string, size int) (shards []string) {
+	var shard strings.Builder
+	flush := func() {
+		if shard.Len() > 0 {
+			shards = append(shards, shard.String())
+			shard.Reset()
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if shard.Len()+len(line) > size {
+			flush()
+		}
+		for len(line) > size {
+			cut := size
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = size
+			}
+			shards = append(shards, line[:cut])
+			line = line[cut:]
+		}
+		shard.WriteString(line)
+	}
+	flush()
+	return shards
+}
+
+// combineShards returns the outputs of the shards as the input of the tool, numbered in the order of the shards.
+func combineShards(outputs []string) string {
+	parts := make([]string, 0, len(outputs))
+	for i, output := range outputs {
+		parts = append(parts, fmt.Sprintf("[Part %d of %d]\n%s", i+1, len(outputs), strings.TrimSpace(output)))
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitShards(t *testing.T) {
+	assert.Equal(t, []string{"one\ntwo\n", "three\n", "four"}, splitShards("one\ntwo\nthree\nfour", 9))
+	assert.Equal(t, []string{"abcd", "efgh", "ij\nk"}, splitShards("abcdefghij\nk", 4))
+	// Lines are not split in the middle of a character
+	assert.Equal(t, []string{"aé", "éé"}, splitShards("aééé", 4)[:2])
+
+	input := strings.Repeat("a line of the log\n", 1000)
+	assert.Equal(t, input, strings.Join(splitShards(input, 1000), ""))
+}
+
+func TestSharded(t *testing.T) {
+	tool := types.Tool{Parameters: types.Parameters{ShardWith: "summarize", ShardSize: 10}}
+	assert.False(t, sharded(tool, strings.Repeat("a", 40)))
+	assert.True(t, sharded(tool, strings.Repeat("a", 41)))
+	assert.False(t, sharded(types.Tool{}, strings.Repeat("a", 1000000)))
+}
+
+func TestCombineShards(t *testing.T) {
+	assert.Equal(t, "[Part 1 of 2]\nfirst\n\n[Part 2 of 2]\nsecond", combineShards([]string{"first\n", " second"}))
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/tokenizer"
+	"github.com/gptscript-ai/gptscript/pkg/trace"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// traceRun starts the span of a run of the program, the parent of the spans of its calls.
+func traceRun(ctx context.Context, prg *types.Program, operation string) (context.Context, *trace.Span) {
+	name := prg.Name
+	if tool, ok := prg.ToolSet[prg.EntryToolID]; ok {
+		name = types.FirstSet(tool.Parameters.Name, name)
+	}
+	return trace.Start(ctx, operation+" "+name, trace.KindInternal,
+		trace.String("gptscript.program", prg.Name),
+		trace.String("gptscript.run.operation", operation))
+}
+
+// traceCall starts the span of a call of a tool, and sets it in the context of the call for the spans of its tool
+// calls, its calls to the model and its requests.
+func traceCall(callCtx *engine.Context) *trace.Span {
+	var span *trace.Span
+	callCtx.Ctx, span = trace.Start(callCtx.Ctx, "tool "+callCtx.Tool.Parameters.Name, trace.KindInternal,
+		trace.String("gptscript.tool.name", callCtx.Tool.Parameters.Name),
+		trace.String("gptscript.tool.id", callCtx.Tool.ID),
+		trace.String("gptscript.tool.category", string(callCtx.ToolCategory)),
+		trace.String("gptscript.call.id", callCtx.ID))
+	return span
+}
+
+// traceModel returns the model that the tool calls, whose calls are traced with the tokens of their requests and
+// responses, counted with the tokenizer of the model.
+func traceModel(tool string, model engine.Model) engine.Model {
+	return tracedModel{
+		Model: model,
+		tool:  tool,
+	}
+}
+
+type tracedModel struct {
+	engine.Model
+	tool string
+}
+
+func (m tracedModel) Call(ctx context.Context, request types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	ctx, span := trace.Start(ctx, "chat "+request.Model, trace.KindClient,
+		trace.String("gen_ai.operation.name", "chat"),
+		trace.String("gen_ai.request.model", request.Model),
+		trace.String("gptscript.tool.name", m.tool))
+	if span == nil {
+		return m.Model.Call(ctx, request, status)
+	}
+
+	messages, _ := json.Marshal(request.Messages)
+	span.SetAttributes(
+		trace.Int("gen_ai.usage.input_tokens", tokenizer.Count(request.Model, string(messages))),
+		trace.Int("gptscript.request.messages", len(request.Messages)),
+		trace.Int("gptscript.request.tools", len(request.Tools)))
+
+	resp, err := m.Model.Call(ctx, request, status)
+	if resp != nil {
+		content, _ := json.Marshal(resp.Content)
+		span.SetAttributes(trace.Int("gen_ai.usage.output_tokens", tokenizer.Count(request.Model, string(content))))
+	}
+	span.End(err)
+	return resp, err
+}
@@ -0,0 +1,27 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/usage"
+)
+
+// reportUsage sends the usage of the calls to models of the run in a runUsage event, if it called a model.
+func reportUsage(callCtx engine.Context, monitor Monitor) {
+	tracker := usage.FromContext(callCtx.Ctx)
+	if tracker == nil {
+		return
+	}
+
+	report := tracker.Report()
+	if report.Total.Calls == 0 {
+		return
+	}
+	monitor.Event(Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeRunUsage,
+		Usage:       &report,
+	})
+}
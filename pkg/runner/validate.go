@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// defaultMaxAttempts is the number of times a tool with a validator is called, unless it sets Max Attempts
+const defaultMaxAttempts = 3
+
+// callValidated calls a tool until its validator accepts its output. After the validator rejects an output, the tool
+// is called again with the feedback of the validator added to its input, up to the max attempts of the tool.
+func (r *Runner) callValidated(callCtx engine.Context, monitor Monitor, env []string, input string) (*State, error) {
+	if callCtx.Tool.Chat {
+		return nil, fmt.Errorf("chat tool [%s] can not have a validator", callCtx.Tool.Parameters.Name)
+	}
+
+	toolIDs, err := callCtx.Tool.GetToolIDsFromNames([]string{callCtx.Tool.Validator})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		attempts  = types.FirstSet(callCtx.Tool.MaxAttempts, defaultMaxAttempts)
+		toolInput = input
+	)
+	for attempt := 1; ; attempt++ {
+		state, err := r.callTool(callCtx, monitor, env, toolInput)
+		if err != nil {
+			return nil, err
+		}
+		if state.Result == nil {
+			return nil, fmt.Errorf("the output of tool [%s] can not be validated, it resulted in a chat continuation", callCtx.Tool.Parameters.Name)
+		}
+
+		feedback, err := r.validate(callCtx, monitor, env, toolIDs[0], input, *state.Result)
+		if err != nil {
+			return nil, err
+		}
+		if feedback == "" {
+			return state, nil
+		}
+
+		if attempt >= attempts {
+			return nil, fmt.Errorf("validator [%s] rejected the output of tool [%s] %d times, the last time with: %s",
+				callCtx.Tool.Validator, callCtx.Tool.Parameters.Name, attempt, feedback)
+		}
+		log.Infof("Validator [%s] rejected the output of tool [%s], attempt %d of %d: %s",
+			callCtx.Tool.Validator, callCtx.Tool.Parameters.Name, attempt, attempts, feedback)
+		toolInput = withFeedback(input, *state.Result, feedback)
+	}
+}
+
+// validate runs the validator on the input and output of a tool, and returns why the output was rejected, or nothing
+// if it was accepted. Commands reject an output by failing, and prompts by responding with anything other than OK.
+func (r *Runner) validate(callCtx engine.Context, monitor Monitor, env []string, toolID, input, output string) (string, error) {
+	validatorInput, err := json.Marshal(map[string]string{
+		"input":  input,
+		"output": output,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	state, err := r.subCall(callCtx.Ctx, callCtx, monitor, env, toolID, string(validatorInput), "", engine.ValidatorToolCategory)
+	if ctxErr := callCtx.Ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	} else if err != nil {
+		return err.Error(), nil
+	}
+	if state.Result == nil {
+		return "", fmt.Errorf("validator tool can not result in a chat continuation")
+	}
+
+	if validator, _ := callCtx.Program.GetToolByID(toolID); validator.IsCommand() {
+		return "", nil
+	}
+	if result := strings.TrimSpace(*state.Result); strings.EqualFold(strings.TrimSuffix(result, "."), "ok") {
+		return "", nil
+	} else if result == "" {
+		return "the validator did not respond with OK", nil
+	} else {
+		return result, nil
+	}
+}
+
+// withFeedback returns the input of a tool with the feedback of the validator on its previous output. The feedback
+// is a feedback argument of inputs that are empty or JSON objects, so commands get it as $FEEDBACK, and is appended
+// to other inputs.
+func withFeedback(input, output, feedback string) string {
+	text := fmt.Sprintf("Your previous output was rejected:\n%s\n\nYour previous output was:\n%s", feedback, output)
+
+	args := map[string]any{}
+	if strings.TrimSpace(input) != "" {
+		if err := json.Unmarshal([]byte(input), &args); err != nil || args == nil {
+			return input + "\n\n" + text
+		}
+	}
+
+	args["feedback"] = text
+	data, err := json.Marshal(args)
+	if err != nil {
+		return input + "\n\n" + text
+	}
+	return string(data)
+}
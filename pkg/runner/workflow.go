@@ -0,0 +1,554 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow is the definition of a tool with #!sys.workflow instructions. Its steps call the tools of the workflow in
+// the order of their dependencies, with inputs wired from the input of the workflow and the outputs of other steps,
+// so no model decides which tool is called next. Only the steps that call prompt tools call a model.
+type Workflow struct {
+	Steps []WorkflowStep `yaml:"steps"`
+	// Output is the output of the workflow, the output of the last step if not set
+	Output string `yaml:"output,omitempty"`
+
+	// waves are the steps in the order they run, the steps of a wave only depend on the steps of the waves before it
+	waves [][]WorkflowStep
+}
+
+type WorkflowStep struct {
+	// ID is the name that other steps reference the output of the step by
+	ID string `yaml:"id"`
+	// Tool is the name of the tool the step calls, from the tools of the workflow. It is the ID if not set.
+	Tool string `yaml:"tool,omitempty"`
+	// Input is the input of the tool, either a string or an object of arguments
+	Input any `yaml:"input,omitempty"`
+	// Needs are the steps that must finish before the step, in addition to the steps it references
+	Needs []string `yaml:"needs,omitempty"`
+	// If skips the step, leaving its output empty, when it is false, unless the step has an else branch
+	If *WorkflowCondition `yaml:"if,omitempty"`
+	// Else is called instead of the tool of the step when its condition is false, for the output of the step
+	Else *WorkflowBranch `yaml:"else,omitempty"`
+	// Retries is the number of times the tool is called again when it fails
+	Retries int `yaml:"retries,omitempty"`
+}
+
+// WorkflowBranch is the tool that a step calls when its condition is false.
+type WorkflowBranch struct {
+	Tool    string `yaml:"tool"`
+	Input   any    `yaml:"input,omitempty"`
+	Retries int    `yaml:"retries,omitempty"`
+}
+
+// WorkflowCondition is the condition of a step. It is either text, which is false when it expands to "", "0" or
+// "false", or an object that tests the value a JSONPath expression selects in the JSON output of a prior step.
+type WorkflowCondition struct {
+	// Expr is the text of a condition that is not an object
+	Expr string `yaml:"-"`
+	// Step is the step whose output is tested
+	Step string `yaml:"step,omitempty"`
+	// Path selects the value that is tested in the output of the step, the whole output if not set. A path with a
+	// wildcard selects the list of its matches.
+	Path string `yaml:"path,omitempty"`
+	// Exists tests whether the path matches a value
+	Exists *bool `yaml:"exists,omitempty"`
+	// Empty tests whether the value is missing, null, "", [] or {}
+	Empty *bool `yaml:"empty,omitempty"`
+	// Equals tests whether the value equals it. If no test is set, the condition is true unless the value is empty,
+	// false or 0.
+	Equals any `yaml:"equals,omitempty"`
+
+	path *jsonPath
+}
+
+func (c *WorkflowCondition) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&c.Expr)
+	}
+	type condition WorkflowCondition
+	return value.Decode((*condition)(c))
+}
+
+func (s WorkflowStep) elseStep() WorkflowStep {
+	return WorkflowStep{
+		ID:      s.ID,
+		Tool:    s.Else.Tool,
+		Input:   s.Else.Input,
+		Retries: s.Else.Retries,
+	}
+}
+
+func (s WorkflowStep) tool() string {
+	return types.FirstSet(s.Tool, s.ID)
+}
+
+// workflowRef matches the references in the inputs, conditions and output of a workflow: ${input}, ${input.<arg>},
+// ${steps.<id>.output}, ${env.<name>}, ${date}, ${time}, ${timestamp} and quoted strings, which can be passed through
+// filters, like ${input.repo | lower | replace " " "-"}
+var workflowRef = regexp.MustCompile(`\$\{\s*([^}]*?)\s*}`)
+
+// ParseWorkflow parses the definition of a workflow from the instructions of its tool, and checks that its steps
+// reference tools and steps that exist, without a cycle.
+func ParseWorkflow(tool types.Tool) (*Workflow, error) {
+	_, body, _ := strings.Cut(tool.Instructions, "\n")
+
+	var workflow Workflow
+	if err := yaml.Unmarshal([]byte(body), &workflow); err != nil {
+		return nil, fmt.Errorf("invalid workflow [%s]: %w", tool.Parameters.Name, err)
+	}
+	if err := workflow.plan(tool); err != nil {
+		return nil, fmt.Errorf("invalid workflow [%s]: %w", tool.Parameters.Name, err)
+	}
+	return &workflow, nil
+}
+
+func (w *Workflow) plan(tool types.Tool) error {
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+
+	steps := map[string]struct{}{}
+	for _, step := range w.Steps {
+		if step.ID == "" {
+			return fmt.Errorf("step without an id")
+		}
+		if _, ok := steps[step.ID]; ok {
+			return fmt.Errorf("duplicate step %s", step.ID)
+		}
+		if _, ok := tool.ToolMapping[step.tool()]; !ok {
+			return fmt.Errorf("step %s calls %s, which is not a tool of the workflow", step.ID, step.tool())
+		}
+		if step.Else != nil {
+			if step.If == nil {
+				return fmt.Errorf("step %s has an else branch without a condition", step.ID)
+			}
+			if _, ok := tool.ToolMapping[step.Else.Tool]; !ok {
+				return fmt.Errorf("else branch of step %s calls %s, which is not a tool of the workflow", step.ID, step.Else.Tool)
+			}
+		}
+		if c := step.If; c != nil && c.Expr == "" {
+			if c.Step == "" {
+				return fmt.Errorf("condition of step %s does not set the step whose output it tests", step.ID)
+			}
+			path, err := parseJSONPath(types.FirstSet(c.Path, "$"))
+			if err != nil {
+				return fmt.Errorf("condition of step %s: %w", step.ID, err)
+			}
+			c.path = path
+		}
+		steps[step.ID] = struct{}{}
+	}
+
+	if _, err := stepRefs(w.Output, steps); err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
+
+	needs := map[string]map[string]struct{}{}
+	for _, step := range w.Steps {
+		deps, err := step.deps(steps)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		needs[step.ID] = deps
+	}
+
+	done := map[string]struct{}{}
+	for len(done) < len(w.Steps) {
+		var wave []WorkflowStep
+		for _, step := range w.Steps {
+			if _, ok := done[step.ID]; ok {
+				continue
+			}
+			ready := true
+			for dep := range needs[step.ID] {
+				if _, ok := done[dep]; !ok {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, step)
+			}
+		}
+		if len(wave) == 0 {
+			var pending []string
+			for _, step := range w.Steps {
+				if _, ok := done[step.ID]; !ok {
+					pending = append(pending, step.ID)
+				}
+			}
+			return fmt.Errorf("steps %s depend on each other", strings.Join(pending, ", "))
+		}
+		for _, step := range wave {
+			done[step.ID] = struct{}{}
+		}
+		w.waves = append(w.waves, wave)
+	}
+
+	return nil
+}
+
+// deps returns the steps that the step needs or references.
+func (s WorkflowStep) deps(steps map[string]struct{}) (map[string]struct{}, error) {
+	deps := map[string]struct{}{}
+	for _, need := range s.Needs {
+		if _, ok := steps[need]; !ok {
+			return nil, fmt.Errorf("needs unknown step %s", need)
+		}
+		deps[need] = struct{}{}
+	}
+
+	var walk func(v any) error
+	walk = func(v any) error {
+		switch v := v.(type) {
+		case string:
+			refs, err := stepRefs(v, steps)
+			for _, ref := range refs {
+				deps[ref] = struct{}{}
+			}
+			return err
+		case map[string]any:
+			for _, value := range v {
+				if err := walk(value); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, value := range v {
+				if err := walk(value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if s.If != nil {
+		if s.If.Step != "" {
+			if _, ok := steps[s.If.Step]; !ok {
+				return nil, fmt.Errorf("condition tests unknown step %s", s.If.Step)
+			}
+			deps[s.If.Step] = struct{}{}
+		}
+		if err := walk(s.If.Expr); err != nil {
+			return nil, err
+		}
+	}
+	if err := walk(s.Input); err != nil {
+		return nil, err
+	}
+	if s.Else != nil {
+		if err := walk(s.Else.Input); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := deps[s.ID]; ok {
+		return nil, fmt.Errorf("references itself")
+	}
+	return deps, nil
+}
+
+// stepRefs returns the steps whose outputs are referenced in the text.
+func stepRefs(text string, steps map[string]struct{}) (result []string, _ error) {
+	for _, match := range workflowRef.FindAllStringSubmatch(text, -1) {
+		expr, err := parseRef(match[1])
+		if err != nil {
+			return nil, err
+		}
+		ref := expr.value
+		switch {
+		case expr.literal:
+		case ref == "input", strings.HasPrefix(ref, "input."):
+		case strings.HasPrefix(ref, "env.") && len(ref) > len("env."):
+		case ref == "date", ref == "time", ref == "timestamp":
+		case strings.HasPrefix(ref, "steps.") && strings.HasSuffix(ref, ".output"):
+			id := strings.TrimSuffix(strings.TrimPrefix(ref, "steps."), ".output")
+			if _, ok := steps[id]; !ok {
+				return nil, fmt.Errorf("reference to unknown step %s", id)
+			}
+			result = append(result, id)
+		default:
+			return nil, fmt.Errorf("unknown reference ${%s}", match[1])
+		}
+	}
+	return result, nil
+}
+
+// workflowVars are the values that the references of a workflow expand to.
+type workflowVars struct {
+	input   string
+	args    map[string]any
+	outputs map[string]string
+	env     map[string]string
+	// now is the time the workflow started, so all of its references to the date and time are the same
+	now time.Time
+}
+
+func (v workflowVars) expand(text string) string {
+	return workflowRef.ReplaceAllStringFunc(text, func(match string) string {
+		// The references were checked when the workflow was parsed
+		expr, err := parseRef(workflowRef.FindStringSubmatch(match)[1])
+		if err != nil {
+			return ""
+		}
+		return expr.apply(v.value(expr))
+	})
+}
+
+func (v workflowVars) value(expr refExpr) string {
+	ref := expr.value
+	switch {
+	case expr.literal:
+		return ref
+	case ref == "input":
+		return v.input
+	case strings.HasPrefix(ref, "input."):
+		arg, ok := v.args[strings.TrimPrefix(ref, "input.")]
+		if !ok {
+			return ""
+		}
+		if s, ok := arg.(string); ok {
+			return s
+		}
+		data, _ := json.Marshal(arg)
+		return string(data)
+	case strings.HasPrefix(ref, "env."):
+		return v.env[strings.TrimPrefix(ref, "env.")]
+	case ref == "date":
+		return v.now.Format(time.DateOnly)
+	case ref == "time":
+		return v.now.Format(time.RFC3339)
+	case ref == "timestamp":
+		return strconv.FormatInt(v.now.Unix(), 10)
+	default:
+		return v.outputs[strings.TrimSuffix(strings.TrimPrefix(ref, "steps."), ".output")]
+	}
+}
+
+func (v workflowVars) expandValue(value any) any {
+	switch value := value.(type) {
+	case string:
+		return v.expand(value)
+	case map[string]any:
+		result := make(map[string]any, len(value))
+		for k, item := range value {
+			result[k] = v.expandValue(item)
+		}
+		return result
+	case []any:
+		result := make([]any, 0, len(value))
+		for _, item := range value {
+			result = append(result, v.expandValue(item))
+		}
+		return result
+	}
+	return value
+}
+
+// stepInput returns the input of the tool of the step. Objects of arguments are passed to the tool as JSON.
+func (v workflowVars) stepInput(step WorkflowStep) (string, error) {
+	switch input := v.expandValue(step.Input).(type) {
+	case nil:
+		return "", nil
+	case string:
+		return input, nil
+	default:
+		data, err := json.Marshal(input)
+		if err != nil {
+			return "", fmt.Errorf("invalid input of step %s: %w", step.ID, err)
+		}
+		return string(data), nil
+	}
+}
+
+// test returns whether the condition of a step is true. A missing condition is always true.
+func (v workflowVars) test(c *WorkflowCondition) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	if c.path == nil {
+		switch strings.ToLower(strings.TrimSpace(v.expand(c.Expr))) {
+		case "", "0", "false":
+			return false, nil
+		}
+		return true, nil
+	}
+
+	// The output of a skipped step is empty, which is tested like null
+	var data any
+	if output := strings.TrimSpace(v.outputs[c.Step]); output != "" {
+		if err := json.Unmarshal([]byte(output), &data); err != nil {
+			return false, fmt.Errorf("output of step %s is not JSON: %w", c.Step, err)
+		}
+	}
+
+	var (
+		matches = c.path.find(data)
+		value   any
+		found   bool
+	)
+	if c.path.hasWildcard() {
+		value, found = matches, len(matches) > 0
+	} else if len(matches) > 0 {
+		value, found = matches[0], true
+	}
+
+	switch {
+	case c.Exists != nil:
+		return found == *c.Exists, nil
+	case c.Empty != nil:
+		return isEmpty(value) == *c.Empty, nil
+	case c.Equals != nil:
+		return equalJSON(value, c.Equals), nil
+	}
+	return !isEmpty(value) && value != false && value != float64(0), nil
+}
+
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// equalJSON returns whether a value decoded from JSON equals a value of a workflow, which is decoded from YAML with
+// other types, like ints for numbers.
+func equalJSON(value, expected any) bool {
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(value, normalized)
+}
+
+// runWorkflow runs the steps of a workflow tool. The steps of each wave run at the same time, unless the runner is
+// sequential, once all the steps of the waves before it are done.
+func (r *Runner) runWorkflow(callCtx engine.Context, monitor Monitor, env []string, input string) (*State, error) {
+	workflow, err := ParseWorkflow(callCtx.Tool)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(callCtx.Tool.Credentials) > 0 {
+		env, err = r.handleCredentials(callCtx, monitor, env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	monitor.Event(Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeCallStart,
+		Content:     input,
+	})
+
+	vars := workflowVars{
+		input:   input,
+		outputs: map[string]string{},
+		env:     map[string]string{},
+		now:     time.Now(),
+	}
+	for _, e := range env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			vars.env[k] = v
+		}
+	}
+	// The input is not required to be an object, the arguments are only set when it is
+	_ = json.Unmarshal([]byte(input), &vars.args)
+
+	for _, wave := range workflow.waves {
+		// The steps of a wave only write their own output, the outputs are added to the vars once they are all done
+		outputs := make([]string, len(wave))
+		d := r.newDispatcher(callCtx.Ctx)
+		for i, step := range wave {
+			run, err := vars.test(step.If)
+			if err != nil {
+				return nil, fmt.Errorf("condition of step %s: %w", step.ID, err)
+			} else if !run && step.Else == nil {
+				log.Debugf("skipping step %s of workflow %s", step.ID, callCtx.Tool.Parameters.Name)
+				continue
+			} else if !run {
+				log.Debugf("running the else branch of step %s of workflow %s", step.ID, callCtx.Tool.Parameters.Name)
+				step = step.elseStep()
+			}
+
+			stepInput, err := vars.stepInput(step)
+			if err != nil {
+				return nil, err
+			}
+			toolIDs, err := callCtx.Tool.GetToolIDsFromNames([]string{step.tool()})
+			if err != nil {
+				return nil, err
+			}
+
+			d.Run(func(ctx context.Context) (err error) {
+				outputs[i], err = r.runStep(ctx, callCtx, monitor, env, step, toolIDs[0], stepInput)
+				return err
+			})
+		}
+		if err := d.Wait(); err != nil {
+			return nil, err
+		}
+		for i, step := range wave {
+			vars.outputs[step.ID] = outputs[i]
+		}
+	}
+
+	var output string
+	if workflow.Output != "" {
+		output = vars.expand(workflow.Output)
+	} else {
+		output = vars.outputs[workflow.Steps[len(workflow.Steps)-1].ID]
+	}
+
+	monitor.Event(Event{
+		Time:        time.Now(),
+		CallContext: callCtx.GetCallContext(),
+		Type:        EventTypeCallFinish,
+		Content:     output,
+	})
+
+	return &State{
+		Result: &output,
+	}, nil
+}
+
+// runStep calls the tool of a step, and calls it again when it fails, up to the retries of the step.
+func (r *Runner) runStep(ctx context.Context, callCtx engine.Context, monitor Monitor, env []string, step WorkflowStep, toolID, input string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		state, err := r.subCall(ctx, callCtx, monitor, env, toolID, input, "", engine.NoCategory)
+		if err == nil {
+			if state.Result == nil {
+				return "", fmt.Errorf("step %s of workflow can not result in a chat continuation", step.ID)
+			}
+			return *state.Result, nil
+		}
+		if attempt >= step.Retries || ctx.Err() != nil {
+			return "", fmt.Errorf("step %s of workflow failed: %w", step.ID, err)
+		}
+		log.Infof("step %s of workflow failed, retrying (%d/%d): %v", step.ID, attempt+1, step.Retries, err)
+	}
+}
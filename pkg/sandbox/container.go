@@ -0,0 +1,47 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// hostEnv are the variables that describe the host, which are not passed to containers since their images set them
+var hostEnv = map[string]struct{}{
+	"PATH":     {},
+	"Path":     {},
+	"HOME":     {},
+	"HOSTNAME": {},
+	"PWD":      {},
+	"OLDPWD":   {},
+	"SHELL":    {},
+	"TMPDIR":   {},
+	"TMP":      {},
+	"TEMP":     {},
+	"USER":     {},
+	"LOGNAME":  {},
+}
+
+// EnvArgs returns the arguments of container run that pass the environment to the container, except the variables
+// that describe the host. Only the names are passed, the values are read from the environment of the command, so
+// secrets are not in its arguments.
+func EnvArgs(envvars []string) (args []string) {
+	for _, kv := range envvars {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := hostEnv[key]; !ok && key != "" {
+			args = append(args, "-e", key)
+		}
+	}
+	return args
+}
+
+// UserArgs returns the arguments of container run that run the container as the user running gptscript, so files
+// written to the workspace are owned by them and not by root. Only docker needs them, podman maps root to the user.
+func UserArgs(containerRuntime string) []string {
+	if runtime.GOOS == "linux" && filepath.Base(containerRuntime) == "docker" {
+		return []string{"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())}
+	}
+	return nil
+}
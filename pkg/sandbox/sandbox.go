@@ -0,0 +1,339 @@
+// Package sandbox runs commands so that they can only write their workspace and the paths their tool declares, and
+// can only use the network if their tool declares it.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/gptscript-ai/gptscript/pkg/egress"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// systemDirs are the directories of the system that sandboxed commands read, for their commands and libraries. The
+// home directory is not one of them, so a command can not read the credentials of the user unless its tool declares
+// them.
+var systemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/opt", "/nix", "/etc", "/run/systemd/resolve"}
+
+// secretFiles are the files in systemDirs that sandboxed commands can not read, they are replaced with empty files
+// and directories.
+var secretFiles = []string{"/etc/shadow", "/etc/shadow-", "/etc/gshadow", "/etc/gshadow-", "/etc/sudoers", "/etc/sudoers.d",
+	"/etc/ssh", "/etc/ssl/private"}
+
+const (
+	// DefaultImage is the container image that sandboxed commands run in when the OS has no sandbox
+	DefaultImage = "alpine"
+	// DefaultContainerRuntime is the command that runs the container of sandboxed commands, unless another one is set
+	DefaultContainerRuntime = "docker"
+)
+
+type Options struct {
+	// Image is the container image that commands run in when the OS has no sandbox, DefaultImage if not set
+	Image string
+	// ContainerRuntime is the command that runs the container of commands, DefaultContainerRuntime if not set
+	ContainerRuntime string
+}
+
+func complete(opts ...Options) (result Options) {
+	for _, opt := range opts {
+		result.Image = types.FirstSet(opt.Image, result.Image)
+		result.ContainerRuntime = types.FirstSet(opt.ContainerRuntime, result.ContainerRuntime)
+	}
+	if result.Image == "" {
+		result.Image = DefaultImage
+	}
+	if result.ContainerRuntime == "" {
+		result.ContainerRuntime = DefaultContainerRuntime
+	}
+	return
+}
+
+// Policy is what a sandboxed command may do. Besides the system directories, a command may only read the paths of its
+// policy.
+type Policy struct {
+	// Workspace is the directory the command may write, and the directory it runs in unless it sets another one
+	Workspace string
+	// Network allows the command to use the network
+	Network bool
+	// Proxy is the URL of the proxy on the loopback address that the command reaches the network through, when its
+	// tool only allows some hosts
+	Proxy string
+	// ProxySocket is the unix socket of the proxy. The sandboxes that have no network of their own, bubblewrap and
+	// containers, mount it, and the command runs with egress.SysForward, which listens on the address of the proxy in
+	// the sandbox and forwards to the socket, so the command can not reach any other host even without the proxy
+	// variables
+	ProxySocket string
+	// Write are the other paths the command may write
+	Write []string
+	// Read are the other paths the command may read, like its script, the directory of its tool and the directories
+	// of its runtime
+	Read []string
+}
+
+// Sandbox runs commands in the sandbox of the OS: bubblewrap on Linux, which runs them in their own user, mount and
+// network namespaces, and sandbox-exec on macOS. On other systems, or when bubblewrap is not installed, commands run
+// in a container.
+type Sandbox struct {
+	opts Options
+}
+
+func New(opts ...Options) *Sandbox {
+	return &Sandbox{
+		opts: complete(opts...),
+	}
+}
+
+// Command returns the command that runs cmd in the sandbox with the policy. The sandbox runs the path and arguments
+// of cmd with its environment, directory and standard streams.
+func (s *Sandbox) Command(ctx context.Context, policy Policy, cmd *exec.Cmd) (*exec.Cmd, error) {
+	if policy.Workspace == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		policy.Workspace = cwd
+	}
+
+	dir := cmd.Dir
+	if dir == "" {
+		dir = policy.Workspace
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	switch {
+	case runtime.GOOS == "linux" && hasCommand("bwrap"):
+		// The command may be outside the directories of the system, like in a directory of the user
+		policy.Read = append(policy.Read, cmd.Path)
+		args, err = bwrapArgs(policy, dir, append([]string{cmd.Path}, cmd.Args[1:]...))
+		if err != nil {
+			return nil, err
+		}
+	case runtime.GOOS == "darwin":
+		policy.Read = append(policy.Read, cmd.Path)
+		args = []string{"sandbox-exec", "-p", seatbeltProfile(policy), cmd.Path}
+		args = append(args, cmd.Args[1:]...)
+	default:
+		if !hasCommand(s.opts.ContainerRuntime) {
+			return nil, fmt.Errorf("failed to sandbox command %v: the OS has no sandbox and %s is not installed to run it in a container",
+				cmd.Args, s.opts.ContainerRuntime)
+		}
+		runArgs, err := ContainerArgs(policy)
+		if err != nil {
+			return nil, err
+		}
+		// The image has its own commands, they are looked up in its PATH instead of the paths of the host
+		command, err := ContainerCommand(policy, append([]string{filepath.Base(cmd.Path)}, cmd.Args[1:]...))
+		if err != nil {
+			return nil, err
+		}
+		args = append([]string{s.opts.ContainerRuntime, "run", "--rm", "-i"}, runArgs...)
+		args = append(args, UserArgs(s.opts.ContainerRuntime)...)
+		args = append(args, "-w", dir)
+		args = append(args, EnvArgs(cmd.Env)...)
+		args = append(args, s.opts.Image)
+		args = append(args, command...)
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sandbox command %v: %w", cmd.Args, err)
+	}
+
+	result := exec.CommandContext(ctx, path, args[1:]...)
+	result.Env = cmd.Env
+	result.Dir = dir
+	result.Stdin = cmd.Stdin
+	result.Stdout = cmd.Stdout
+	result.Stderr = cmd.Stderr
+	return result, nil
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// executable returns the path of gptscript, which runs egress.SysForward in the sandbox.
+var executable = defaultExecutable
+
+func defaultExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// forwardArgs returns the command that runs cmd with the proxy of the policy in a sandbox without a network, and the
+// path of gptscript that runs it, or cmd if the policy has no proxy.
+func forwardArgs(policy Policy, cmd []string) (string, []string, error) {
+	if policy.ProxySocket == "" {
+		return "", cmd, nil
+	}
+	exe, err := executable()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to find gptscript to forward to the proxy of the sandbox: %w", err)
+	}
+	cmd, err = egress.ForwardArgs(exe, policy.ProxySocket, policy.Proxy, cmd)
+	return exe, cmd, err
+}
+
+func bwrapArgs(policy Policy, dir string, cmd []string) ([]string, error) {
+	exe, cmd, err := forwardArgs(policy, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"bwrap", "--die-with-parent", "--new-session", "--unshare-all"}
+	if policy.Network {
+		args = append(args, "--share-net")
+	}
+	args = append(args, "--cap-drop", "ALL")
+	for _, dir := range systemDirs {
+		args = append(args, "--ro-bind-try", dir, dir)
+	}
+	for _, file := range secretFiles {
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			args = append(args, "--tmpfs", file)
+		} else if err == nil {
+			args = append(args, "--ro-bind", "/dev/null", file)
+		}
+	}
+	args = append(args,
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", os.TempDir(),
+	)
+	// The home directory is empty, for the commands that need it, unless the policy declares its paths
+	if home, err := os.UserHomeDir(); err == nil && home != "/" {
+		args = append(args, "--tmpfs", home)
+	}
+	for _, path := range policy.Read {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+	for _, path := range append([]string{policy.Workspace}, policy.Write...) {
+		args = append(args, "--bind-try", path, path)
+	}
+	if exe != "" {
+		args = append(args, "--ro-bind", exe, exe, "--bind", policy.ProxySocket, policy.ProxySocket)
+	}
+	args = append(args, "--chdir", dir, "--")
+	return append(args, cmd...), nil
+}
+
+// seatbeltProfile returns the sandbox-exec profile of the policy. Reads are allowed to the directories of the system
+// and the paths of the policy, and writes to the devices and the temporary directories, which are private to users on
+// macOS.
+func seatbeltProfile(policy Policy) string {
+	profile := "(version 1)\n(allow default)\n"
+	// The metadata of paths, which commands look up to resolve paths, are readable, but not their contents
+	profile += "(deny file-read*)\n(allow file-read-metadata)\n(allow file-read*\n  (literal \"/\")"
+	for _, dir := range []string{"/usr", "/bin", "/sbin", "/opt", "/System", "/Library", "/private/etc", "/private/var/db",
+		"/dev", "/private/tmp", "/private/var/folders"} {
+		profile += fmt.Sprintf("\n  (subpath %q)", dir)
+	}
+	for _, path := range append(append([]string{policy.Workspace}, policy.Read...), policy.Write...) {
+		profile += fmt.Sprintf("\n  (subpath %q)", resolve(path))
+	}
+	profile += ")\n"
+	for _, file := range []string{"/private/etc/sudoers", "/private/etc/master.passwd", "/private/etc/ssh", "/Library/Keychains"} {
+		profile += fmt.Sprintf("(deny file-read* (subpath %q))\n", file)
+	}
+	if !policy.Network {
+		profile += "(deny network*)\n"
+		// The only unix socket the command may connect to is the one of the proxy
+		if policy.ProxySocket != "" {
+			profile += fmt.Sprintf("(allow network-outbound (remote unix-socket (path-literal %q)))\n", resolve(policy.ProxySocket))
+		}
+		if u, err := url.Parse(policy.Proxy); err == nil && u.Port() != "" {
+			profile += fmt.Sprintf("(allow network-outbound (remote ip \"localhost:%s\"))\n", u.Port())
+		}
+	}
+	profile += "(deny file-write*)\n(allow file-write*\n  (subpath \"/dev\")\n  (subpath \"/private/tmp\")\n  (subpath \"/private/var/folders\")"
+	for _, path := range append([]string{policy.Workspace, os.TempDir()}, policy.Write...) {
+		profile += fmt.Sprintf("\n  (subpath %q)", resolve(path))
+	}
+	return profile + ")\n"
+}
+
+// resolve returns the path with its symlinks resolved, since the profiles of sandbox-exec match resolved paths.
+func resolve(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+// ContainerArgs returns the arguments of container run that apply the policy: the root filesystem of the container
+// is read-only, and only the workspace and the paths of the policy are mounted writable. The container has no
+// network unless the policy allows it, with a proxy the socket of the proxy and gptscript, which forwards to it, are
+// mounted, and the command of the container must be the command of ContainerCommand.
+func ContainerArgs(policy Policy) ([]string, error) {
+	args := []string{"--read-only", "--tmpfs", "/tmp", "--cap-drop", "ALL", "--security-opt", "no-new-privileges"}
+	if !policy.Network {
+		args = append(args, "--network", "none")
+	}
+	if policy.ProxySocket != "" {
+		exe, _, err := containerForwardArgs(policy, nil)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-v", exe+":"+exe+":ro", "-v", policy.ProxySocket+":"+policy.ProxySocket)
+	}
+	for _, path := range policy.Read {
+		args = append(args, "-v", path+":"+path+":ro")
+	}
+	for _, path := range append([]string{policy.Workspace}, policy.Write...) {
+		args = append(args, "-v", path+":"+path)
+	}
+	return args, nil
+}
+
+// ContainerCommand returns the command that runs cmd in the container of the policy, with the proxy of the policy if
+// it has one.
+func ContainerCommand(policy Policy, cmd []string) ([]string, error) {
+	_, cmd, err := containerForwardArgs(policy, cmd)
+	return cmd, err
+}
+
+func containerForwardArgs(policy Policy, cmd []string) (string, []string, error) {
+	if policy.ProxySocket != "" && runtime.GOOS != "linux" {
+		// gptscript forwards to the proxy in the container, so it must be a Linux binary
+		return "", nil, fmt.Errorf("the commands of tools with allowed hosts can only be sandboxed in a container on Linux")
+	}
+	return forwardArgs(policy, cmd)
+}
+
+type contextKey struct{}
+
+type contextValue struct {
+	sandbox *Sandbox
+	policy  Policy
+}
+
+// WithContext returns a context that sandboxes the commands that are wrapped with it, for the builtin tools that run
+// commands.
+func WithContext(ctx context.Context, s *Sandbox, policy Policy) context.Context {
+	return context.WithValue(ctx, contextKey{}, contextValue{
+		sandbox: s,
+		policy:  policy,
+	})
+}
+
+// Wrap returns the command that runs cmd in the sandbox of the context, or cmd if the context has no sandbox.
+func Wrap(ctx context.Context, cmd *exec.Cmd) (*exec.Cmd, error) {
+	v, ok := ctx.Value(contextKey{}).(contextValue)
+	if !ok || v.sandbox == nil {
+		return cmd, nil
+	}
+	return v.sandbox.Command(ctx, v.policy, cmd)
+}
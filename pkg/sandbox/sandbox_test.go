@@ -0,0 +1,121 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBwrapArgs(t *testing.T) {
+	args, err := bwrapArgs(Policy{
+		Workspace: "/work",
+		Write:     []string{"/data"},
+		Read:      []string{"/tmp/script"},
+	}, "/work/sub", []string{"/bin/sh", "-c", "ls"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bwrap", "--die-with-parent", "--new-session", "--unshare-all",
+		"--cap-drop", "ALL",
+		"--ro-bind-try", "/usr", "/usr"}, args[:9])
+	assert.Equal(t, []string{
+		"--ro-bind-try", "/tmp/script", "/tmp/script",
+		"--bind-try", "/work", "/work",
+		"--bind-try", "/data", "/data",
+		"--chdir", "/work/sub", "--",
+		"/bin/sh", "-c", "ls"}, args[len(args)-15:])
+
+	// Only the directories of the system and of the policy are mounted, the home directory is empty
+	assert.NotContains(t, strings.Join(args, " "), "--ro-bind / /")
+	assert.Contains(t, strings.Join(args, " "), "--ro-bind-try /etc /etc")
+	assert.Contains(t, strings.Join(args, " "), "--tmpfs "+os.TempDir())
+	if home, err := os.UserHomeDir(); err == nil {
+		assert.Contains(t, strings.Join(args, " "), "--tmpfs "+home)
+	}
+	if _, err := os.Stat("/etc/shadow"); err == nil {
+		assert.Contains(t, strings.Join(args, " "), "--ro-bind /dev/null /etc/shadow")
+	}
+
+	args, err = bwrapArgs(Policy{Workspace: "/work", Network: true}, "/work", []string{"ls"})
+	require.NoError(t, err)
+	assert.Contains(t, args, "--share-net")
+}
+
+func TestBwrapArgsProxy(t *testing.T) {
+	executable = func() (string, error) {
+		return "/usr/bin/gptscript", nil
+	}
+	defer func() {
+		executable = defaultExecutable
+	}()
+
+	args, err := bwrapArgs(Policy{
+		Workspace:   "/work",
+		Proxy:       "http://127.0.0.1:4000",
+		ProxySocket: "/tmp/gptscript-egress1/proxy.sock",
+	}, "/work", []string{"/usr/bin/curl", "https://api.github.com"})
+	require.NoError(t, err)
+
+	// The network is not shared, the command reaches the proxy through its socket
+	assert.NotContains(t, args, "--share-net")
+	assert.Equal(t, []string{
+		"--ro-bind", "/usr/bin/gptscript", "/usr/bin/gptscript",
+		"--bind", "/tmp/gptscript-egress1/proxy.sock", "/tmp/gptscript-egress1/proxy.sock",
+		"--chdir", "/work", "--",
+		"/usr/bin/gptscript", "sys.egress", "/tmp/gptscript-egress1/proxy.sock", "127.0.0.1:4000",
+		"/usr/bin/curl", "https://api.github.com"}, args[len(args)-15:])
+}
+
+func TestSeatbeltProfile(t *testing.T) {
+	profile := seatbeltProfile(Policy{
+		Workspace: "/work",
+		Write:     []string{"/data"},
+	})
+	assert.Contains(t, profile, "(deny network*)")
+	assert.Contains(t, profile, "(deny file-read*)")
+	assert.Contains(t, profile, "(deny file-write*)")
+	assert.Contains(t, profile, `(subpath "/usr")`)
+	assert.NotContains(t, profile, `(subpath "/Users")`)
+	assert.Contains(t, profile, `(subpath "/work")`)
+	assert.Contains(t, profile, `(subpath "/data")`)
+	assert.NotContains(t, profile, "unix-socket")
+
+	profile = seatbeltProfile(Policy{
+		Workspace:   "/work",
+		Proxy:       "http://127.0.0.1:4000",
+		ProxySocket: "/tmp/gptscript-egress1/proxy.sock",
+	})
+	assert.Contains(t, profile, `(allow network-outbound (remote unix-socket (path-literal "/tmp/gptscript-egress1/proxy.sock")))`)
+	assert.NotContains(t, profile, "(allow network* (remote unix-socket))")
+	assert.Contains(t, profile, `(allow network-outbound (remote ip "localhost:4000"))`)
+
+	assert.NotContains(t, seatbeltProfile(Policy{Workspace: "/work", Network: true}), "network")
+}
+
+func TestContainerArgs(t *testing.T) {
+	args, err := ContainerArgs(Policy{
+		Workspace: "/work",
+		Read:      []string{"/tmp/script"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--read-only", "--tmpfs", "/tmp", "--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"--network", "none",
+		"-v", "/tmp/script:/tmp/script:ro",
+		"-v", "/work:/work"}, args)
+
+	args, err = ContainerArgs(Policy{Workspace: "/work", Network: true})
+	require.NoError(t, err)
+	assert.NotContains(t, args, "--network")
+}
+
+func TestWrap(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "ls")
+
+	wrapped, err := Wrap(context.Background(), cmd)
+	require.NoError(t, err)
+	assert.Same(t, cmd, wrapped)
+}
@@ -0,0 +1,202 @@
+package schedule
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+// Schedule is a run of a tool that the server starts at a time, or every time its cron expression matches.
+type Schedule struct {
+	ID      string `json:"id"`
+	Program string `json:"program"`
+	Tool    string `json:"tool,omitempty"`
+	Input   string `json:"input,omitempty"`
+	// Cron is the cron expression of a schedule that runs repeatedly, not set for a single run at Next
+	Cron string `json:"cron,omitempty"`
+	// Next is the time of the next run
+	Next    time.Time `json:"next"`
+	Created time.Time `json:"created"`
+	// LastRun is the time the last run started
+	LastRun time.Time `json:"lastRun,omitempty"`
+}
+
+// DefaultDir is the directory of the schedules of the server and the sys.schedule tool, GPTSCRIPT_SCHEDULE_DIR if it is
+// set, or gptscript/schedules in the XDG data directory.
+func DefaultDir() string {
+	if dir := os.Getenv("GPTSCRIPT_SCHEDULE_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(xdg.DataHome, "gptscript", "schedules")
+}
+
+// Store saves the schedules as JSON files in a directory, so schedules added by the runs of scripts are started by
+// the server.
+type Store struct {
+	Dir  string
+	lock sync.Mutex
+}
+
+func (s *Store) file(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid schedule ID %q", id)
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+// Add validates the schedule, sets its ID and the time of its first run if they are not set, and saves it.
+func (s *Store) Add(schedule Schedule) (Schedule, error) {
+	if schedule.Program == "" {
+		return schedule, fmt.Errorf("the program of the schedule is required")
+	}
+
+	now := time.Now()
+	if schedule.Cron != "" {
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			return schedule, err
+		}
+		if schedule.Next.IsZero() {
+			schedule.Next = cron.Next(now)
+		}
+		if schedule.Next.IsZero() {
+			return schedule, fmt.Errorf("the cron expression %q never matches", schedule.Cron)
+		}
+	} else if schedule.Next.IsZero() {
+		return schedule, fmt.Errorf("the cron expression or the time of the run is required")
+	}
+
+	if schedule.ID == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return schedule, err
+		}
+		schedule.ID = now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+	}
+	schedule.Created = now
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return schedule, s.save(schedule)
+}
+
+func (s *Store) save(schedule Schedule) error {
+	file, err := s.file(schedule.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0600)
+}
+
+// Remove removes the schedule, fs.ErrNotExist is returned if it does not exist.
+func (s *Store) Remove(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	file, err := s.file(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// List returns the schedules, the next to run first.
+func (s *Store) List() ([]Schedule, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.list()
+}
+
+func (s *Store) list() (result []Schedule, _ error) {
+	files, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var schedule Schedule
+		if err := json.Unmarshal(data, &schedule); err != nil {
+			log.Debugf("Skipping the invalid schedule %s: %v", f.Name(), err)
+			continue
+		}
+		result = append(result, schedule)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Next.Before(result[j].Next)
+	})
+	return result, nil
+}
+
+// Due returns the schedules that are due to run at now. The schedules of a single run are removed, and the schedules
+// of a cron expression are saved with the time of their next run, so a schedule is due only once even if the server
+// was down for several of its runs.
+func (s *Store) Due(now time.Time) (due []Schedule, _ error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	schedules, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schedule := range schedules {
+		if schedule.Next.After(now) {
+			break
+		}
+
+		if schedule.Cron == "" {
+			due = append(due, schedule)
+			if err := os.Remove(filepath.Join(s.Dir, schedule.ID+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			log.Errorf("Skipping the schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		due = append(due, schedule)
+		schedule.LastRun = now
+		schedule.Next = cron.Next(now)
+		if schedule.Next.IsZero() {
+			err = os.Remove(filepath.Join(s.Dir, schedule.ID+".json"))
+		} else {
+			err = s.save(schedule)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return due, nil
+}
@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreDue(t *testing.T) {
+	s := &Store{Dir: t.TempDir()}
+	now := time.Now()
+
+	once, err := s.Add(Schedule{Program: "once.gpt", Next: now.Add(time.Minute)})
+	require.NoError(t, err)
+	hourly, err := s.Add(Schedule{Program: "hourly.gpt", Cron: "@every 1h"})
+	require.NoError(t, err)
+
+	_, err = s.Add(Schedule{Program: "invalid.gpt"})
+	assert.ErrorContains(t, err, "is required")
+	_, err = s.Add(Schedule{Program: "invalid.gpt", Cron: "0 0 31 2 *"})
+	assert.ErrorContains(t, err, "never matches")
+
+	due, err := s.Due(now)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	due, err = s.Due(now.Add(2 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, once.ID, due[0].ID)
+
+	// The run of the schedule is due once, even if it is late for several of its runs
+	later := now.Add(5 * time.Hour)
+	due, err = s.Due(later)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, hourly.ID, due[0].ID)
+
+	schedules, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, schedules, 1, "the schedule of a single run must be removed once it is due")
+	assert.Equal(t, later.Add(time.Hour).Unix(), schedules[0].Next.Unix())
+
+	require.NoError(t, s.Remove(hourly.ID))
+	schedules, err = s.List()
+	require.NoError(t, err)
+	assert.Empty(t, schedules)
+}
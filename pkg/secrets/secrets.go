@@ -0,0 +1,195 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gptscript-ai/gptscript/pkg/config"
+	"github.com/gptscript-ai/gptscript/pkg/credentials"
+)
+
+// credentialPrefix is the prefix of the names of the credentials that secrets are saved as in the credential store
+const credentialPrefix = "gptscript-secret/"
+
+// Masked is what the values of secrets are replaced with in the output of runs.
+const Masked = "********"
+
+// minMaskedLength is the length of the shortest value that is masked, shorter values would mask ordinary text.
+const minMaskedLength = 4
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Secrets are the named secrets that the scripts of a run read and write, saved in the credential store of its
+// credential context. The values that the run reads or writes are masked in its output.
+type Secrets struct {
+	credCtx string
+	lock    sync.RWMutex
+	values  []string
+}
+
+func New(credCtx string) *Secrets {
+	return &Secrets{
+		credCtx: credCtx,
+	}
+}
+
+func (s *Secrets) store() (*credentials.Store, error) {
+	cfg, err := config.ReadCLIConfig("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CLI config: %w", err)
+	}
+	return credentials.NewStore(cfg, s.credCtx)
+}
+
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q, names can only have letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
+
+// Get returns the value of the secret, and false if it is not set.
+func (s *Secrets) Get(name string) (string, bool, error) {
+	if err := checkName(name); err != nil {
+		return "", false, err
+	}
+	store, err := s.store()
+	if err != nil {
+		return "", false, err
+	}
+	cred, ok, err := store.Get(credentialPrefix + name)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	value := cred.Env["value"]
+	s.Mask(value)
+	return value, true, nil
+}
+
+// Set saves the value of the secret.
+func (s *Secrets) Set(name, value string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	s.Mask(value)
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.Add(credentials.Credential{
+		ToolName: credentialPrefix + name,
+		Env: map[string]string{
+			"value": value,
+		},
+	})
+}
+
+// Delete removes the secret.
+func (s *Secrets) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	store, err := s.store()
+	if err != nil {
+		return err
+	}
+	return store.Remove(credentialPrefix + name)
+}
+
+// Names returns the names of the secrets, sorted.
+func (s *Secrets) Names() (result []string, _ error) {
+	store, err := s.store()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, cred := range creds {
+		if name, ok := strings.CutPrefix(cred.ToolName, credentialPrefix); ok {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Mask adds a value to mask in the output of the run.
+func (s *Secrets) Mask(value string) {
+	if len(value) < minMaskedLength {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, existing := range s.values {
+		if existing == value {
+			return
+		}
+	}
+	s.values = append(s.values, value)
+	// The longest values are replaced first, so the values that contain other values are masked whole
+	sort.Slice(s.values, func(i, j int) bool {
+		return len(s.values[i]) > len(s.values[j])
+	})
+}
+
+// Masking returns whether there are values to mask.
+func (s *Secrets) Masking() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.values) > 0
+}
+
+// Apply returns the text with the values of the secrets that the run read or wrote masked.
+func (s *Secrets) Apply(text string) string {
+	if s == nil {
+		return text
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, value := range s.values {
+		text = strings.ReplaceAll(text, value, Masked)
+	}
+	return text
+}
+
+// ApplyJSON returns the JSON document with the values of the secrets that the run read or wrote masked in its strings.
+func (s *Secrets) ApplyJSON(data []byte) []byte {
+	if s == nil {
+		return data
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, value := range s.values {
+		quoted, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		data = bytes.ReplaceAll(data, quoted[1:len(quoted)-1], []byte(Masked))
+	}
+	return data
+}
+
+type secretsKey struct{}
+
+// WithSecrets returns a context with the secrets of a run.
+func WithSecrets(ctx context.Context, s *Secrets) context.Context {
+	return context.WithValue(ctx, secretsKey{}, s)
+}
+
+// FromContext returns the secrets of the run of the context, or nil if it has none.
+func FromContext(ctx context.Context) *Secrets {
+	s, _ := ctx.Value(secretsKey{}).(*Secrets)
+	return s
+}
@@ -0,0 +1,33 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	s := New("default")
+	assert.False(t, s.Masking())
+	assert.Equal(t, "token sk-1234", s.Apply("token sk-1234"))
+
+	s.Mask("sk-1234")
+	s.Mask("sk-1234-extra")
+	s.Mask("abc")
+	assert.True(t, s.Masking())
+	assert.Equal(t, "token ******** and ******** for abc", s.Apply("token sk-1234 and sk-1234-extra for abc"))
+}
+
+func TestApplyJSON(t *testing.T) {
+	s := New("default")
+	s.Mask(`pa"ss\word`)
+	assert.Equal(t, `{"content":"the password is ********"}`, string(s.ApplyJSON([]byte(`{"content":"the password is pa\"ss\\word"}`))))
+}
+
+func TestCheckName(t *testing.T) {
+	assert.NoError(t, checkName("OPENAI_API_KEY"))
+	assert.NoError(t, checkName("github.token-2"))
+	assert.Error(t, checkName(""))
+	assert.Error(t, checkName("a/b"))
+	assert.Error(t, checkName("a///b"))
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/acorn-io/broadcaster"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+)
+
+// confirmsPath is the path of the endpoints that list and answer the calls that wait to be confirmed
+const confirmsPath = "/sys/confirms"
+
+var confirmID int64
+
+// ConfirmRequest is a call of a run that waits for a client of the server to confirm it, sent in a confirmRequest
+// event and answered with POST /sys/confirms/<id>.
+type ConfirmRequest struct {
+	ID     string `json:"id"`
+	RunID  string `json:"runID,omitempty"`
+	Prompt string `json:"prompt"`
+	// Arguments are the JSON arguments of the call, that the client can correct in its answer
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ConfirmResponse is the answer of a client to a ConfirmRequest.
+type ConfirmResponse struct {
+	Approved bool `json:"approved"`
+	// Arguments are the corrected JSON arguments of the call, it runs with its own if not set
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type pendingConfirm struct {
+	request ConfirmRequest
+	asked   time.Time
+	answer  chan ConfirmResponse
+}
+
+// confirms asks the clients of the server to confirm the calls of its runs, with events, and waits for their answers.
+type confirms struct {
+	events  *broadcaster.Broadcaster[Event]
+	lock    sync.Mutex
+	pending map[string]*pendingConfirm
+}
+
+func (c *confirms) Confirm(ctx context.Context, prompt string) error {
+	_, err := c.Edit(ctx, prompt, "")
+	return err
+}
+
+func (c *confirms) Edit(ctx context.Context, prompt, arguments string) (string, error) {
+	p := &pendingConfirm{
+		request: ConfirmRequest{
+			ID:        fmt.Sprint(atomic.AddInt64(&confirmID, 1)),
+			RunID:     IDFromContext(ctx),
+			Prompt:    prompt,
+			Arguments: arguments,
+		},
+		asked:  time.Now(),
+		answer: make(chan ConfirmResponse, 1),
+	}
+
+	c.lock.Lock()
+	if c.pending == nil {
+		c.pending = map[string]*pendingConfirm{}
+	}
+	c.pending[p.request.ID] = p
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		delete(c.pending, p.request.ID)
+	}()
+
+	c.events.C <- Event{
+		Event: runner.Event{
+			Time: time.Now(),
+			Type: "confirmRequest",
+		},
+		RunID:   p.request.RunID,
+		Confirm: &p.request,
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", context.Cause(ctx)
+	case answer := <-p.answer:
+		if !answer.Approved {
+			return "", errors.New("abort")
+		}
+		if answer.Arguments == "" {
+			return arguments, nil
+		}
+		return answer.Arguments, nil
+	}
+}
+
+// list returns the calls that wait to be confirmed, the oldest first.
+func (c *confirms) list() []ConfirmRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pending := make([]*pendingConfirm, 0, len(c.pending))
+	for _, p := range c.pending {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].asked.Before(pending[j].asked)
+	})
+
+	result := make([]ConfirmRequest, 0, len(pending))
+	for _, p := range pending {
+		result = append(result, p.request)
+	}
+	return result
+}
+
+// answer answers the call that waits to be confirmed, and returns false if no call waits with the ID.
+func (c *confirms) answer(id string, response ConfirmResponse) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p, ok := c.pending[id]
+	if ok {
+		delete(c.pending, id)
+		p.answer <- response
+	}
+	return ok
+}
+
+// serveConfirms lists the calls that wait to be confirmed with GET /sys/confirms, and answers one with POST
+// /sys/confirms/<id>, whose body approves or denies the call, and can correct its arguments.
+func (s *Server) serveConfirms(rw http.ResponseWriter, req *http.Request) {
+	if s.confirms == nil {
+		http.Error(rw, "the server does not confirm calls, start it with --confirm", http.StatusNotFound)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, confirmsPath), "/")
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		writeJSON(rw, s.confirms.list())
+	case id != "" && !strings.Contains(id, "/") && req.Method == http.MethodPost:
+		var response ConfirmResponse
+		if err := json.NewDecoder(req.Body).Decode(&response); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if response.Arguments != "" && !json.Valid([]byte(response.Arguments)) {
+			http.Error(rw, "the arguments are not valid JSON", http.StatusBadRequest)
+			return
+		}
+		if !s.confirms.answer(id, response) {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(rw, req)
+	}
+}
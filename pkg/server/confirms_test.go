@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/acorn-io/broadcaster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmEditedArguments(t *testing.T) {
+	events := broadcaster.New[Event]()
+	s := &Server{confirms: &confirms{events: events}}
+
+	go func() {
+		e := <-events.C
+		assert.Equal(t, "confirmRequest", string(e.Type))
+		assert.Equal(t, []ConfirmRequest{*e.Confirm}, s.confirms.list())
+
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, confirmsPath+"/"+e.Confirm.ID,
+			strings.NewReader(`{"approved": true, "arguments": "{\"command\": \"ls\"}"}`)))
+		assert.Equal(t, http.StatusNoContent, rw.Code)
+	}()
+
+	arguments, err := s.confirms.Edit(ContextWithNewID(context.Background()), "Run command: rm -rf /", `{"command": "rm -rf /"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"command": "ls"}`, arguments)
+	assert.Empty(t, s.confirms.list())
+
+	go func() {
+		e := <-events.C
+		s.confirms.answer(e.Confirm.ID, ConfirmResponse{})
+	}()
+	_, err = s.confirms.Edit(ContextWithNewID(context.Background()), "Remove: file", `{"location": "file"}`)
+	assert.EqualError(t, err, "abort")
+}
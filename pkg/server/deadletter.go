@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/confirm"
+	"github.com/gptscript-ai/gptscript/pkg/monitor"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// deadLetterPath is the path of the endpoints that list, retry and remove the dead letters
+const deadLetterPath = "/sys/dead-letters"
+
+const (
+	// DefaultDeadLetterMax is the number of dead letters that are kept by default
+	DefaultDeadLetterMax = 100
+	// DefaultDeadLetterMaxAge is how long the dead letters are kept by default
+	DefaultDeadLetterMaxAge = 7 * 24 * time.Hour
+)
+
+// DeadLetter is a run of the server that failed, saved with its input and the events of its calls until it
+// failed, so it can be looked into and retried.
+type DeadLetter struct {
+	ID      string    `json:"id"`
+	RunID   string    `json:"runID"`
+	Time    time.Time `json:"time"`
+	Program string    `json:"program"`
+	Tool    string    `json:"tool,omitempty"`
+	Input   string    `json:"input,omitempty"`
+	// Redacted is whether the input was removed by the redaction of the server, the run can not be retried then
+	Redacted bool   `json:"redacted,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	NoCache  bool   `json:"noCache,omitempty"`
+	Err      string `json:"err"`
+	// Retries is the number of times the run was retried and failed again
+	Retries int            `json:"retries,omitempty"`
+	Events  []runner.Event `json:"events,omitempty"`
+}
+
+// deadLetters saves the dead letters as JSON files in a directory, which only the user can read. The input of the
+// letters is redacted like the events, and the oldest letters are removed when there are more than max of them or
+// they are older than maxAge.
+type deadLetters struct {
+	dir       string
+	max       int
+	maxAge    time.Duration
+	redaction monitor.Redaction
+	lock      sync.Mutex
+}
+
+func (d *deadLetters) file(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid dead letter ID %q", id)
+	}
+	return filepath.Join(d.dir, id+".json"), nil
+}
+
+func (d *deadLetters) save(letter DeadLetter) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	file, err := d.file(letter.ID)
+	if err != nil {
+		return err
+	}
+	if input := d.redaction.Apply(runner.Event{Type: runner.EventTypeCallStart, Content: letter.Input}).Content; input != letter.Input {
+		letter.Input = input
+		letter.Redacted = true
+	}
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return err
+	}
+	d.prune()
+	return nil
+}
+
+// prune removes the letters that are older than maxAge, and then the oldest ones over max. It is called with the
+// lock held.
+func (d *deadLetters) prune() {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		log.Errorf("failed to read the dead letters to prune them: %v", err)
+		return
+	}
+
+	type letterFile struct {
+		name    string
+		modTime time.Time
+	}
+	var (
+		letters []letterFile
+		cutoff  = time.Now().Add(-d.maxAge)
+	)
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		if d.maxAge > 0 && info.ModTime().Before(cutoff) {
+			d.removeFile(f.Name())
+			continue
+		}
+		letters = append(letters, letterFile{name: f.Name(), modTime: info.ModTime()})
+	}
+
+	if d.max <= 0 || len(letters) <= d.max {
+		return
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].modTime.After(letters[j].modTime)
+	})
+	for _, letter := range letters[d.max:] {
+		d.removeFile(letter.name)
+	}
+}
+
+func (d *deadLetters) removeFile(name string) {
+	if err := os.Remove(filepath.Join(d.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Errorf("failed to remove the dead letter %s: %v", strings.TrimSuffix(name, ".json"), err)
+	}
+}
+
+func (d *deadLetters) get(id string) (DeadLetter, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	var letter DeadLetter
+	file, err := d.file(id)
+	if err != nil {
+		return letter, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return letter, err
+	}
+	return letter, json.Unmarshal(data, &letter)
+}
+
+func (d *deadLetters) remove(id string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	file, err := d.file(id)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// list returns the dead letters without their events, the latest first.
+func (d *deadLetters) list() (result []DeadLetter, _ error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	files, err := os.ReadDir(d.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			log.Debugf("Skipping the invalid dead letter %s: %v", f.Name(), err)
+			continue
+		}
+		letter.Events = nil
+		result = append(result, letter)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result, nil
+}
+
+type traceKey struct{}
+
+// trace collects the events of a run, across the times it is paused and resumed.
+type trace struct {
+	lock   sync.Mutex
+	events []runner.Event
+}
+
+func (t *trace) add(event runner.Event) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.events = append(t.events, event)
+}
+
+func traceFromContext(ctx context.Context) *trace {
+	t, _ := ctx.Value(traceKey{}).(*trace)
+	return t
+}
+
+// execute runs the program of the letter with its input. A run that fails is saved as a dead letter, or updates
+// the letter if it is retried, and a retried letter is removed once its run succeeds. Runs that are canceled, like
+// when the client of the run disconnects, did not fail and are not saved.
+func (s *Server) execute(ctx context.Context, letter DeadLetter, prg types.Program) (string, error) {
+	t := &trace{}
+	ctx = context.WithValue(ctx, traceKey{}, t)
+	ctx, done := s.runs.track(ctx, letter)
+	defer done()
+	if s.confirms != nil {
+		ctx = confirm.WithConfirm(ctx, s.confirms)
+	}
+	if letter.NoCache {
+		ctx = cache.WithNoCache(ctx)
+	}
+
+	out, err := s.schedule(ctx, letter.Priority, prg, letter.Input)
+	if s.deadLetters == nil {
+		return out, err
+	}
+	if err == nil {
+		if letter.ID != "" {
+			if err := s.deadLetters.remove(letter.ID); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Errorf("failed to remove the dead letter %s: %v", letter.ID, err)
+			}
+		}
+		return out, nil
+	}
+	if ctx.Err() != nil {
+		return out, err
+	}
+
+	letter.RunID = IDFromContext(ctx)
+	letter.Time = time.Now()
+	letter.Err = err.Error()
+	letter.Events = t.events
+	if letter.ID == "" {
+		letter.ID = fmt.Sprintf("%s-%s", letter.Time.UTC().Format("20060102-150405"), letter.RunID)
+	} else {
+		letter.Retries++
+	}
+	if err := s.deadLetters.save(letter); err != nil {
+		log.Errorf("failed to save the failed run %s as a dead letter: %v", letter.RunID, err)
+	} else {
+		log.Infof("run %s failed, saved it as the dead letter %s", letter.RunID, letter.ID)
+	}
+	return out, err
+}
+
+// serveDeadLetters lists the dead letters with GET /sys/dead-letters, returns one with GET /sys/dead-letters/<id>,
+// removes it with DELETE /sys/dead-letters/<id>, and retries its run with POST /sys/dead-letters/<id>/retry.
+func (s *Server) serveDeadLetters(rw http.ResponseWriter, req *http.Request) {
+	if s.deadLetters == nil {
+		http.Error(rw, "the runs that fail are not saved, set --dead-letter-dir to save them", http.StatusNotFound)
+		return
+	}
+
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(req.URL.Path, deadLetterPath), "/"), "/")
+
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		letters, err := s.deadLetters.list()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if letters == nil {
+			letters = []DeadLetter{}
+		}
+		writeJSON(rw, letters)
+	case id != "" && action == "" && req.Method == http.MethodGet:
+		letter, err := s.deadLetters.get(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(rw, req)
+		} else if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		} else {
+			writeJSON(rw, letter)
+		}
+	case id != "" && action == "" && req.Method == http.MethodDelete:
+		err := s.deadLetters.remove(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(rw, req)
+		} else if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		} else {
+			rw.WriteHeader(http.StatusNoContent)
+		}
+	case id != "" && action == "retry" && req.Method == http.MethodPost:
+		letter, err := s.deadLetters.get(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(rw, req)
+			return
+		} else if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if letter.Redacted {
+			http.Error(rw, "the input of the dead letter was redacted, it can not be retried", http.StatusConflict)
+			return
+		}
+
+		prg, err := s.linker.Program(req.Context(), letter.Program, letter.Tool, s.loaderOptions())
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusNotAcceptable)
+			return
+		}
+		s.start(rw, req, letter, prg)
+	default:
+		http.NotFound(rw, req)
+	}
+}
+
+func writeJSON(rw http.ResponseWriter, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/monitor"
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetters(t *testing.T) {
+	d := &deadLetters{dir: t.TempDir()}
+
+	letters, err := d.list()
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	now := time.Now()
+	require.NoError(t, d.save(DeadLetter{
+		ID:      "old",
+		Time:    now.Add(-time.Hour),
+		Program: "old.gpt",
+		Err:     "failed",
+	}))
+	require.NoError(t, d.save(DeadLetter{
+		ID:      "new",
+		Time:    now,
+		Program: "new.gpt",
+		Input:   "input",
+		Err:     "failed again",
+		Events:  []runner.Event{{Type: runner.EventTypeCallStart}},
+	}))
+
+	letters, err = d.list()
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "new", letters[0].ID)
+	assert.Equal(t, "old", letters[1].ID)
+	assert.Empty(t, letters[0].Events, "the list must not include the events of the runs")
+
+	letter, err := d.get("new")
+	require.NoError(t, err)
+	assert.Equal(t, "input", letter.Input)
+	assert.Len(t, letter.Events, 1)
+
+	require.NoError(t, d.remove("old"))
+	_, err = d.get("old")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = d.get("../new")
+	assert.ErrorContains(t, err, "invalid dead letter ID")
+}
+
+func TestDeadLettersPrune(t *testing.T) {
+	d := &deadLetters{dir: filepath.Join(t.TempDir(), "dead-letters"), max: 2, maxAge: time.Hour}
+
+	expired := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, d.save(DeadLetter{ID: "expired", Err: "failed"}))
+	require.NoError(t, os.Chtimes(filepath.Join(d.dir, "expired.json"), expired, expired))
+
+	for i, id := range []string{"first", "second", "third"} {
+		require.NoError(t, d.save(DeadLetter{ID: id, Err: "failed"}))
+		saved := time.Now().Add(time.Duration(i-3) * time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(d.dir, id+".json"), saved, saved))
+	}
+	require.NoError(t, d.save(DeadLetter{ID: "fourth", Err: "failed"}))
+
+	files, err := os.ReadDir(d.dir)
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+		info, err := f.Info()
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0600), info.Mode().Perm())
+	}
+	assert.ElementsMatch(t, []string{"third.json", "fourth.json"}, names)
+
+	info, err := os.Stat(d.dir)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0700), info.Mode().Perm())
+}
+
+func TestDeadLettersRedaction(t *testing.T) {
+	d := &deadLetters{dir: t.TempDir(), redaction: monitor.RedactArguments}
+
+	require.NoError(t, d.save(DeadLetter{ID: "secret", Input: "the token", Err: "failed"}))
+	require.NoError(t, d.save(DeadLetter{ID: "empty", Err: "failed"}))
+
+	letter, err := d.get("secret")
+	require.NoError(t, err)
+	assert.Equal(t, monitor.Redacted, letter.Input)
+	assert.True(t, letter.Redacted)
+
+	data, err := os.ReadFile(filepath.Join(d.dir, "secret.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "the token")
+
+	// There is nothing to retry without the input
+	letter, err = d.get("empty")
+	require.NoError(t, err)
+	assert.False(t, letter.Redacted)
+}
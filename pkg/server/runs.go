@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+)
+
+// runsPath is the path of the endpoints that list and cancel the runs that are running
+const runsPath = "/sys/runs"
+
+// runningRun is a run that the server is running.
+type runningRun struct {
+	ID      string    `json:"id"`
+	Program string    `json:"program"`
+	Tool    string    `json:"tool,omitempty"`
+	Started time.Time `json:"started"`
+	cancel  func(runner.CancelReason)
+}
+
+// runs are the runs that the server is running, by ID, so they can be canceled.
+type runs struct {
+	lock    sync.Mutex
+	running map[string]*runningRun
+}
+
+// track returns a context that cancels the run of the letter when it is canceled with cancel, and a function to call
+// once the run finishes.
+func (r *runs) track(ctx context.Context, letter DeadLetter) (context.Context, func()) {
+	ctx, cancel := runner.WithCancelReason(ctx)
+	run := &runningRun{
+		ID:      IDFromContext(ctx),
+		Program: letter.Program,
+		Tool:    letter.Tool,
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.running == nil {
+		r.running = map[string]*runningRun{}
+	}
+	r.running[run.ID] = run
+
+	return ctx, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		delete(r.running, run.ID)
+	}
+}
+
+// list returns the runs that are running, the oldest first.
+func (r *runs) list() []runningRun {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result := make([]runningRun, 0, len(r.running))
+	for _, run := range r.running {
+		result = append(result, *run)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
+
+// cancel cancels the run with the reason, and returns false if it is not running.
+func (r *runs) cancel(id string, reason runner.CancelReason) bool {
+	r.lock.Lock()
+	run, ok := r.running[id]
+	r.lock.Unlock()
+	if ok {
+		run.cancel(reason)
+	}
+	return ok
+}
+
+// serveRuns lists the runs that are running with GET /sys/runs, and cancels one with POST /sys/runs/<id>/cancel. The
+// run stops its calls to models and its commands, and fails with why it was canceled, the message of the body of the
+// request if it has one.
+func (s *Server) serveRuns(rw http.ResponseWriter, req *http.Request) {
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(req.URL.Path, runsPath), "/"), "/")
+
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		writeJSON(rw, s.runs.list())
+	case id != "" && action == "cancel" && req.Method == http.MethodPost:
+		var body struct {
+			Message string `json:"message,omitempty"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !s.runs.cancel(id, runner.CancelReason{
+			Kind:    runner.CancelUser,
+			Message: body.Message,
+		}) {
+			http.NotFound(rw, req)
+			return
+		}
+		log.Infof("canceled run %s", id)
+		rw.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(rw, req)
+	}
+}
+
+// writeCanceled responds to the request of a run that was canceled with why it was canceled.
+func writeCanceled(rw http.ResponseWriter, reason *runner.CancelReason) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(rw).Encode(map[string]any{
+		"canceled": reason,
+	})
+}
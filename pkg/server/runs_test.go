@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelRun(t *testing.T) {
+	s := &Server{}
+	ctx, done := s.runs.track(ContextWithNewID(context.Background()), DeadLetter{Program: "report.gpt"})
+	defer done()
+
+	running := s.runs.list()
+	require.Len(t, running, 1)
+	assert.Equal(t, "report.gpt", running[0].Program)
+
+	rw := httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, runsPath+"/missing/cancel", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.NoError(t, ctx.Err())
+
+	rw = httptest.NewRecorder()
+	s.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, runsPath+"/"+running[0].ID+"/cancel",
+		strings.NewReader(`{"message": "closed"}`)))
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Equal(t, &runner.CancelReason{Kind: runner.CancelUser, Message: "closed"}, runner.CancelReasonOf(ctx))
+
+	done()
+	assert.Empty(t, s.runs.list())
+}
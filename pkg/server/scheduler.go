@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// ParsePriority parses the priority of a run: low, normal, high, or a number, higher numbers running first. The
+// priority is normal if it is empty.
+func ParsePriority(s string) (int, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	priority, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority %q, use low, normal, high or a number", s)
+	}
+	return priority, nil
+}
+
+// scheduler limits the number of runs that run at a time. Waiting runs start in the order of their priority, and
+// the runs that were submitted first start first. When a run waits while runs of a lower priority hold all the
+// slots, the lowest of them is asked to pause at its next safe point, and waits for a slot again to resume.
+type scheduler struct {
+	lock    sync.Mutex
+	slots   int
+	seq     int64
+	running map[*scheduledRun]struct{}
+	waiting []*scheduledRun
+}
+
+type scheduledRun struct {
+	priority int
+	// seq orders the runs of the same priority, a paused run keeps it to resume before the runs submitted after it
+	seq int64
+	// ready is closed when the run gets a slot
+	ready chan struct{}
+	// pause is closed when the run should pause for a run of a higher priority
+	pause     chan struct{}
+	preempted bool
+}
+
+func newScheduler(slots int) *scheduler {
+	return &scheduler{
+		slots:   slots,
+		running: map[*scheduledRun]struct{}{},
+	}
+}
+
+func (s *scheduler) newRun(priority int) *scheduledRun {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.seq++
+	return &scheduledRun{
+		priority: priority,
+		seq:      s.seq,
+	}
+}
+
+// acquire waits until the run gets a slot, and returns the channel that is closed when the run should pause.
+func (s *scheduler) acquire(ctx context.Context, run *scheduledRun) (<-chan struct{}, error) {
+	s.lock.Lock()
+	run.ready = make(chan struct{})
+	run.pause = make(chan struct{})
+	run.preempted = false
+	s.waiting = append(s.waiting, run)
+	sort.SliceStable(s.waiting, func(i, j int) bool {
+		if s.waiting[i].priority != s.waiting[j].priority {
+			return s.waiting[i].priority > s.waiting[j].priority
+		}
+		return s.waiting[i].seq < s.waiting[j].seq
+	})
+	s.schedule()
+	s.lock.Unlock()
+
+	select {
+	case <-run.ready:
+		return run.pause, nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		select {
+		case <-run.ready:
+			// The run got its slot while it was canceled
+			s.releaseLocked(run)
+		default:
+			for i, waiting := range s.waiting {
+				if waiting == run {
+					s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+					break
+				}
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release frees the slot of the run.
+func (s *scheduler) release(run *scheduledRun) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.releaseLocked(run)
+}
+
+func (s *scheduler) releaseLocked(run *scheduledRun) {
+	delete(s.running, run)
+	s.schedule()
+}
+
+// schedule starts the waiting runs while there are free slots, and preempts a running run for the first waiting run
+// if it has a higher priority.
+func (s *scheduler) schedule() {
+	for len(s.waiting) > 0 && len(s.running) < s.slots {
+		run := s.waiting[0]
+		s.waiting = s.waiting[1:]
+		s.running[run] = struct{}{}
+		close(run.ready)
+	}
+	if len(s.waiting) == 0 {
+		return
+	}
+
+	var lowest *scheduledRun
+	for run := range s.running {
+		if run.preempted {
+			// A run that is pausing already frees its slot for the first waiting run
+			return
+		}
+		if lowest == nil || run.priority < lowest.priority || run.priority == lowest.priority && run.seq > lowest.seq {
+			lowest = run
+		}
+	}
+	if lowest != nil && lowest.priority < s.waiting[0].priority {
+		lowest.preempted = true
+		close(lowest.pause)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriority(t *testing.T) {
+	for s, want := range map[string]int{
+		"":       PriorityNormal,
+		"low":    PriorityLow,
+		"normal": PriorityNormal,
+		"high":   PriorityHigh,
+		"10":     10,
+		"-5":     -5,
+	} {
+		priority, err := ParsePriority(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, priority, s)
+	}
+
+	_, err := ParsePriority("urgent")
+	assert.Error(t, err)
+}
+
+func acquired(t *testing.T, s *scheduler, run *scheduledRun) <-chan (<-chan struct{}) {
+	t.Helper()
+	result := make(chan (<-chan struct{}), 1)
+	go func() {
+		pause, err := s.acquire(context.Background(), run)
+		if err == nil {
+			result <- pause
+		}
+	}()
+	return result
+}
+
+func closed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	s := newScheduler(1)
+	first := s.newRun(PriorityNormal)
+	_, err := s.acquire(context.Background(), first)
+	require.NoError(t, err)
+
+	low := acquired(t, s, s.newRun(PriorityLow))
+	normal := acquired(t, s, s.newRun(PriorityNormal))
+	require.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return len(s.waiting) == 2
+	}, time.Second, time.Millisecond)
+
+	s.release(first)
+	select {
+	case <-normal:
+	case <-low:
+		t.Fatal("the low priority run started before the normal priority run")
+	case <-time.After(time.Second):
+		t.Fatal("the normal priority run did not start")
+	}
+}
+
+func TestSchedulerPreempt(t *testing.T) {
+	s := newScheduler(1)
+	low := s.newRun(PriorityLow)
+	pause, err := s.acquire(context.Background(), low)
+	require.NoError(t, err)
+	assert.False(t, closed(pause))
+
+	high := acquired(t, s, s.newRun(PriorityHigh))
+	require.Eventually(t, func() bool {
+		return closed(pause)
+	}, time.Second, time.Millisecond)
+
+	// The low priority run pauses, and waits for the high priority run to resume
+	s.release(low)
+	resumed := acquired(t, s, low)
+
+	var highPause <-chan struct{}
+	select {
+	case highPause = <-high:
+	case <-time.After(time.Second):
+		t.Fatal("the high priority run did not start")
+	}
+	assert.False(t, closed(highPause), "the high priority run must not be preempted by a lower priority run")
+
+	// The low priority run waits again once its goroutine acquires
+	require.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return len(s.waiting) == 1
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, resumed)
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := newScheduler(1)
+	first := s.newRun(PriorityNormal)
+	_, err := s.acquire(context.Background(), first)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.acquire(ctx, s.newRun(PriorityNormal))
+	require.ErrorIs(t, err, context.Canceled)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	assert.Empty(t, s.waiting)
+}
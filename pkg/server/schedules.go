@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gptscript-ai/gptscript/pkg/schedule"
+	"github.com/gptscript-ai/gptscript/pkg/system"
+)
+
+// schedulePath is the path of the endpoints that list, add and remove the schedules of runs
+const schedulePath = "/sys/schedules"
+
+// runSchedules starts the runs of the schedules that are due, checking them every interval until the context is
+// done. The runs of schedules that fail are saved as dead letters, like the other runs.
+func (s *Server) runSchedules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.startDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) startDue(ctx context.Context, now time.Time) {
+	due, err := s.schedules.Due(now)
+	if err != nil {
+		log.Errorf("failed to read the schedules: %v", err)
+		return
+	}
+
+	for _, sched := range due {
+		prg, err := s.linker.Program(ctx, sched.Program, sched.Tool, s.loaderOptions())
+		if err != nil {
+			log.Errorf("failed to load %s for the schedule %s: %v", sched.Program, sched.ID, err)
+			continue
+		}
+
+		runCtx := ContextWithNewID(ctx)
+		log.Infof("starting run %s of the schedule %s", IDFromContext(runCtx), sched.ID)
+		go func() {
+			_, _ = s.execute(runCtx, DeadLetter{
+				Program: sched.Program,
+				Tool:    sched.Tool,
+				Input:   sched.Input,
+			}, prg)
+		}()
+	}
+}
+
+// serveSchedules lists the schedules with GET /sys/schedules, adds one with POST /sys/schedules, and removes one with
+// DELETE /sys/schedules/<id>.
+func (s *Server) serveSchedules(rw http.ResponseWriter, req *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(req.URL.Path, schedulePath), "/")
+
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		schedules, err := s.schedules.List()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if schedules == nil {
+			schedules = []schedule.Schedule{}
+		}
+		writeJSON(rw, schedules)
+	case id == "" && req.Method == http.MethodPost:
+		var sched schedule.Schedule
+		if err := json.NewDecoder(req.Body).Decode(&sched); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Like the runs of the server, the programs are relative to the directory it runs in
+		if sched.Program != "" && !strings.HasSuffix(sched.Program, system.Suffix) {
+			sched.Program += system.Suffix
+		}
+		sched, err := s.schedules.Add(sched)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(rw, sched)
+	case id != "" && req.Method == http.MethodDelete:
+		err := s.schedules.Remove(id)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(rw, req)
+		} else if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		} else {
+			rw.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.NotFound(rw, req)
+	}
+}
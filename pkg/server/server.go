@@ -18,9 +18,12 @@ import (
 	"github.com/acorn-io/broadcaster"
 	"github.com/gptscript-ai/gptscript/pkg/builtin"
 	"github.com/gptscript-ai/gptscript/pkg/cache"
+	"github.com/gptscript-ai/gptscript/pkg/gc"
 	"github.com/gptscript-ai/gptscript/pkg/gptscript"
 	"github.com/gptscript-ai/gptscript/pkg/loader"
+	"github.com/gptscript-ai/gptscript/pkg/monitor"
 	"github.com/gptscript-ai/gptscript/pkg/runner"
+	"github.com/gptscript-ai/gptscript/pkg/schedule"
 	"github.com/gptscript-ai/gptscript/pkg/system"
 	"github.com/gptscript-ai/gptscript/pkg/types"
 	"github.com/gptscript-ai/gptscript/static"
@@ -30,7 +33,35 @@ import (
 
 type Options struct {
 	ListenAddress string
-	GPTScript     gptscript.Options
+	// Watch reloads the programs the server loaded as soon as their files change, instead of checking the files
+	// every time a program is used
+	Watch bool
+	// GC removes the entries of the cache that the options select every gc.DefaultInterval, the cache is not
+	// collected if not set
+	GC *gc.Options
+	// MaxRuns is the number of runs that run at a time, the runs over it wait for a slot in the order of their
+	// priority. A run waiting for a slot pauses the runs of a lower priority at their next safe point. The runs are
+	// not limited if not set
+	MaxRuns int
+	// DeadLetterDir is the directory that the runs that fail are saved to, to list and retry them with the
+	// /sys/dead-letters endpoints, the runs that fail are not saved if not set
+	DeadLetterDir string
+	// DeadLetterMax is the number of dead letters that are kept, the oldest are removed when a run fails over it,
+	// DefaultDeadLetterMax if not set
+	DeadLetterMax int
+	// DeadLetterMaxAge is how long the dead letters are kept, DefaultDeadLetterMaxAge if not set
+	DeadLetterMaxAge time.Duration
+	// ScheduleDir is the directory of the schedules of runs that the server starts when they are due, added with
+	// the /sys/schedules endpoints and the sys.schedule tool, GPTSCRIPT_SCHEDULE_DIR or gptscript/schedules in the XDG
+	// data directory by default
+	ScheduleDir string
+	// Redaction is what is removed from the events of runs before they are sent to the clients of the server and
+	// saved with the dead letters
+	Redaction monitor.Redaction
+	// Confirm asks the clients of the server to confirm the potentially dangerous calls of runs with confirmRequest
+	// events, answered with the /sys/confirms endpoints
+	Confirm   bool
+	GPTScript gptscript.Options
 }
 
 func complete(opts *Options) (result *Options) {
@@ -41,7 +72,16 @@ func complete(opts *Options) (result *Options) {
 
 	result.ListenAddress = types.FirstSet(result.ListenAddress, result.ListenAddress)
 	if result.ListenAddress == "" {
-		result.ListenAddress = "127.0.0.1:9090"
+		result.ListenAddress = "localhost:9090"
+	}
+	if result.DeadLetterMax <= 0 {
+		result.DeadLetterMax = DefaultDeadLetterMax
+	}
+	if result.DeadLetterMaxAge <= 0 {
+		result.DeadLetterMaxAge = DefaultDeadLetterMaxAge
+	}
+	if result.ScheduleDir == "" {
+		result.ScheduleDir = schedule.DefaultDir()
 	}
 
 	return
@@ -50,18 +90,52 @@ func complete(opts *Options) (result *Options) {
 func New(opts *Options) (*Server, error) {
 	events := broadcaster.New[Event]()
 	opts = complete(opts)
-	opts.GPTScript.Runner.MonitorFactory = NewSessionFactory(events)
+	opts.GPTScript.Runner.MonitorFactory = monitor.Redact(NewSessionFactory(events), opts.Redaction)
+
+	listenAddress, loopback, err := system.ListenAddress(opts.ListenAddress, opts.GPTScript.Runner.AddressFamily)
+	if err != nil {
+		return nil, err
+	}
+	if !loopback {
+		log.Infof("WARNING: listening on %s, which is not a loopback address, exposes the server to the network", listenAddress)
+	}
 
 	g, err := gptscript.New(&opts.GPTScript)
 	if err != nil {
 		return nil, err
 	}
 
+	var scheduler *scheduler
+	if opts.MaxRuns > 0 {
+		scheduler = newScheduler(opts.MaxRuns)
+	}
+
+	var c *confirms
+	if opts.Confirm {
+		c = &confirms{events: events}
+	}
+
+	var d *deadLetters
+	if opts.DeadLetterDir != "" {
+		d = &deadLetters{
+			dir:       opts.DeadLetterDir,
+			max:       opts.DeadLetterMax,
+			maxAge:    opts.DeadLetterMaxAge,
+			redaction: opts.Redaction,
+		}
+	}
+
 	return &Server{
+		confirms:      c,
 		melody:        melody.New(),
+		scheduler:     scheduler,
+		deadLetters:   d,
+		schedules:     &schedule.Store{Dir: opts.ScheduleDir},
 		events:        events,
 		runner:        g,
-		listenAddress: opts.ListenAddress,
+		listenAddress: listenAddress,
+		watch:         opts.Watch,
+		gc:            opts.GC,
 	}, nil
 }
 
@@ -72,6 +146,8 @@ type Event struct {
 	Input        string         `json:"input,omitempty"`
 	Output       string         `json:"output,omitempty"`
 	Err          string         `json:"err,omitempty"`
+	// Confirm is the call that waits to be confirmed, set on confirmRequest events
+	Confirm *ConfirmRequest `json:"confirm,omitempty"`
 }
 
 type Server struct {
@@ -80,6 +156,19 @@ type Server struct {
 	runner        *gptscript.GPTScript
 	events        *broadcaster.Broadcaster[Event]
 	listenAddress string
+	watch         bool
+	gc            *gc.Options
+	// scheduler limits the runs that run at a time, nil if they are not limited
+	scheduler *scheduler
+	// deadLetters saves the runs that fail, nil if they are not saved
+	deadLetters *deadLetters
+	schedules   *schedule.Store
+	// linker reloads the programs on every request, only reading the files that changed since the last one
+	linker loader.Linker
+	// runs are the runs that are running, that can be canceled with the /sys/runs endpoints
+	runs runs
+	// confirms are the calls that wait for the clients to confirm them, nil if the server does not confirm calls
+	confirms *confirms
 }
 
 var (
@@ -96,6 +185,13 @@ func IDFromContext(ctx context.Context) string {
 	return ctx.Value(execKey{}).(string)
 }
 
+// loaderOptions are the options that the programs of the server are loaded with.
+func (s *Server) loaderOptions() loader.Options {
+	return loader.Options{
+		Transport: s.runner.Transport(),
+	}
+}
+
 func (s *Server) Close() {
 	s.runner.Close()
 }
@@ -110,7 +206,7 @@ func (s *Server) list(rw http.ResponseWriter, req *http.Request) {
 		_ = enc.Encode(builtin.SysProgram())
 		return
 	} else if strings.HasSuffix(path, system.Suffix) {
-		prg, err := loader.Program(req.Context(), path, req.URL.Query().Get("tool"))
+		prg, err := s.linker.Program(req.Context(), path, req.URL.Query().Get("tool"), s.loaderOptions())
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
@@ -151,7 +247,7 @@ func (s *Server) run(rw http.ResponseWriter, req *http.Request) {
 		path += system.Suffix
 	}
 
-	prg, err := loader.Program(req.Context(), path, req.URL.Query().Get("tool"))
+	prg, err := s.linker.Program(req.Context(), path, req.URL.Query().Get("tool"), s.loaderOptions())
 	if errors.Is(err, fs.ErrNotExist) {
 		http.NotFound(rw, req)
 		return
@@ -166,10 +262,27 @@ func (s *Server) run(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	priority, err := ParsePriority(req.URL.Query().Get("priority"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.start(rw, req, DeadLetter{
+		Program:  path,
+		Tool:     req.URL.Query().Get("tool"),
+		Input:    string(body),
+		Priority: priority,
+		NoCache:  req.URL.Query().Has("nocache"),
+	}, prg)
+}
+
+// start runs the program with the input of the letter, in the background if the request is async.
+func (s *Server) start(rw http.ResponseWriter, req *http.Request, letter DeadLetter, prg types.Program) {
 	id, ctx := s.getContext(req)
 	if isAsync(req) {
 		go func() {
-			_, _ = s.runner.Run(ctx, prg, os.Environ(), string(body))
+			_, _ = s.execute(ctx, letter, prg)
 		}()
 		rw.Header().Set("Content-Type", "application/json")
 		err := json.NewEncoder(rw).Encode(map[string]any{
@@ -179,15 +292,53 @@ func (s *Server) run(rw http.ResponseWriter, req *http.Request) {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 		}
 	} else {
-		out, err := s.runner.Run(ctx, prg, os.Environ(), string(body))
+		out, err := s.execute(ctx, letter, prg)
+		var reason *runner.CancelReason
 		if err == nil {
 			_, _ = rw.Write([]byte(out))
+		} else if errors.As(err, &reason) {
+			writeCanceled(rw, reason)
 		} else {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 		}
 	}
 }
 
+// schedule runs the program when the scheduler gives the run a slot. A run that pauses for a run of a higher
+// priority waits for a slot again, and resumes from the state it paused in.
+func (s *Server) schedule(ctx context.Context, priority int, prg types.Program, input string) (string, error) {
+	if s.scheduler == nil {
+		return s.runner.Run(ctx, prg, os.Environ(), input)
+	}
+
+	var (
+		run   = s.scheduler.newRun(priority)
+		state *runner.State
+	)
+	for {
+		pause, err := s.scheduler.acquire(ctx, run)
+		if err != nil {
+			return "", err
+		}
+
+		var out string
+		runCtx := runner.WithPause(ctx, pause)
+		if state == nil {
+			out, err = s.runner.Run(runCtx, prg, os.Environ(), input)
+		} else {
+			out, err = s.runner.Resume(runCtx, prg, os.Environ(), state)
+		}
+		s.scheduler.release(run)
+
+		var paused *runner.ErrPaused
+		if !errors.As(err, &paused) {
+			return out, err
+		}
+		log.Debugf("run %s paused for a run of a higher priority", IDFromContext(ctx))
+		state = paused.State
+	}
+}
+
 func isAsync(req *http.Request) bool {
 	return req.URL.Query().Has("async")
 }
@@ -210,6 +361,13 @@ func (s *Server) Start(ctx context.Context) error {
 	s.ctx = ctx
 	s.melody.HandleConnect(s.Connect)
 	go s.events.Start(ctx)
+	if s.watch {
+		go s.linker.Watch(ctx, time.Second, s.reloaded)
+	}
+	if s.gc != nil {
+		go gc.Background(ctx, gc.DefaultInterval, *s.gc)
+	}
+	go s.runSchedules(ctx, 30*time.Second)
 	log.Infof("Listening on http://%s", s.listenAddress)
 	handler := cors.Default().Handler(s)
 	server := &http.Server{Addr: s.listenAddress, Handler: handler}
@@ -222,6 +380,25 @@ func (s *Server) Start(ctx context.Context) error {
 	return server.ListenAndServe()
 }
 
+// reloaded sends an event for each program that was reloaded because its files changed, so clients can refresh it
+func (s *Server) reloaded(name string, prg types.Program, err error) {
+	e := Event{
+		Event: runner.Event{
+			Time:    time.Now(),
+			Type:    "programReload",
+			Content: name,
+		},
+	}
+	if err != nil {
+		log.Errorf("failed to reload %s: %v", name, err)
+		e.Err = err.Error()
+	} else {
+		log.Infof("reloaded %s", name)
+		e.Program = &prg
+	}
+	s.events.C <- e
+}
+
 func (s *Server) Connect(session *melody.Session) {
 	go func() {
 		sub := s.events.Subscribe()
@@ -271,6 +448,26 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if req.URL.Path == deadLetterPath || strings.HasPrefix(req.URL.Path, deadLetterPath+"/") {
+		s.serveDeadLetters(rw, req)
+		return
+	}
+
+	if req.URL.Path == schedulePath || strings.HasPrefix(req.URL.Path, schedulePath+"/") {
+		s.serveSchedules(rw, req)
+		return
+	}
+
+	if req.URL.Path == runsPath || strings.HasPrefix(req.URL.Path, runsPath+"/") {
+		s.serveRuns(rw, req)
+		return
+	}
+
+	if req.URL.Path == confirmsPath || strings.HasPrefix(req.URL.Path, confirmsPath+"/") {
+		s.serveConfirms(rw, req)
+		return
+	}
+
 	switch req.Method {
 	case http.MethodPost:
 		s.run(rw, req)
@@ -316,21 +513,27 @@ func (s SessionFactory) Start(ctx context.Context, prg *types.Program, env []str
 		env:    env,
 		input:  input,
 		events: s.events,
+		trace:  traceFromContext(ctx),
 	}, nil
 }
 
 type Session struct {
-	id      string
-	prj     *types.Program
-	env     []string
-	input   string
-	events  *broadcaster.Broadcaster[Event]
+	id     string
+	prj    *types.Program
+	env    []string
+	input  string
+	events *broadcaster.Broadcaster[Event]
+	// trace collects the events of the run to save them if it fails, nil if they are not collected
+	trace   *trace
 	runLock sync.Mutex
 }
 
 func (s *Session) Event(event runner.Event) {
 	s.runLock.Lock()
 	defer s.runLock.Unlock()
+	if s.trace != nil {
+		s.trace.add(event)
+	}
 	s.events.C <- Event{
 		Event: event,
 		RunID: s.id,
@@ -348,7 +551,10 @@ func (s *Session) Stop(output string, err error) {
 		Input:  s.input,
 		Output: output,
 	}
-	if err != nil {
+	if errors.As(err, new(*runner.ErrPaused)) {
+		// The run resumes with a new runStart event when it gets a slot again
+		e.Type = "runPaused"
+	} else if err != nil {
 		e.Err = err.Error()
 	}
 
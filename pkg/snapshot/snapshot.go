@@ -0,0 +1,387 @@
+// Package snapshot takes snapshots of the working directory before runs, to restore it when a run fails or its
+// changes are rejected.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hashRecord is the PAX record of the SHA-256 hash of the contents of the files, to find the files that changed
+const hashRecord = "GPTSCRIPT.sha256"
+
+// Snapshot is a tar of a directory, stored with its metadata in a directory of the store of the snapshots.
+type Snapshot struct {
+	ID string `json:"id"`
+	// Dir is the absolute path of the directory of the snapshot
+	Dir  string    `json:"dir"`
+	Time time.Time `json:"time"`
+
+	// store is the directory of the snapshots, which is not in the snapshots when it is in their directory
+	store string
+}
+
+func (s *Snapshot) file() string {
+	return filepath.Join(s.store, s.ID, "snapshot.tar.gz")
+}
+
+func (s *Snapshot) metadataFile() string {
+	return filepath.Join(s.store, s.ID, "snapshot.json")
+}
+
+// Take takes a snapshot of the directory, which is stored in storeDir.
+func Take(dir, storeDir string) (_ *Snapshot, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	storeDir, err = filepath.Abs(storeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &Snapshot{
+		ID:    fmt.Sprint(now.UnixNano()),
+		Dir:   dir,
+		Time:  now,
+		store: storeDir,
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file()), 0700); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = s.Remove()
+		}
+	}()
+
+	f, err := os.OpenFile(s.file(), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		// The snapshots are never in the snapshots, when the cache is in the directory
+		if path == storeDir {
+			return filepath.SkipDir
+		}
+		return addFile(tw, dir, path, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return s, os.WriteFile(s.metadataFile(), data, 0600)
+}
+
+func addFile(tw *tar.Writer, dir, path string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	} else if !info.IsDir() && !info.Mode().IsRegular() {
+		// Sockets, devices and pipes are not files that runs change
+		return nil
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(rel)
+	header.Format = tar.FormatPAX
+	header.Uname, header.Gname = "", ""
+
+	if !info.Mode().IsRegular() {
+		return tw.WriteHeader(header)
+	}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	header.PAXRecords = map[string]string{hashRecord: hash}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(tw, f, header.Size)
+	return err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// List returns the snapshots in storeDir, from the newest to the oldest.
+func List(storeDir string) (result []*Snapshot, err error) {
+	storeDir, err = filepath.Abs(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(storeDir, "*", "snapshot.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var s Snapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("invalid snapshot %s: %w", file, err)
+		}
+		s.store = storeDir
+		result = append(result, &s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result, nil
+}
+
+// each calls fn with the header of each entry of the snapshot, and its contents.
+func (s *Snapshot) each(fn func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(s.file())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// path returns the path of an entry of the snapshot in its directory, which can not be outside of it.
+func (s *Snapshot) path(name string) (string, error) {
+	path := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(s.Dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %s in snapshot %s", name, s.ID)
+	}
+	return path, nil
+}
+
+// Changes returns the paths, relative to the directory, that were added, changed or removed since the snapshot.
+func (s *Snapshot) Changes() (result []string, _ error) {
+	seen := map[string]bool{}
+	err := s.each(func(header *tar.Header, _ io.Reader) error {
+		seen[header.Name] = true
+		path, err := s.path(header.Name)
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			result = append(result, header.Name)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if !info.IsDir() {
+				result = append(result, header.Name)
+			}
+		case tar.TypeSymlink:
+			if link, err := os.Readlink(path); err != nil || link != header.Linkname {
+				result = append(result, header.Name)
+			}
+		default:
+			if !info.Mode().IsRegular() || info.Size() != header.Size || info.Mode().Perm() != fs.FileMode(header.Mode).Perm() {
+				result = append(result, header.Name)
+			} else if hash, err := hashFile(path); err != nil {
+				return err
+			} else if hash != header.PAXRecords[hashRecord] {
+				result = append(result, header.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := s.added(seen)
+	if err != nil {
+		return nil, err
+	}
+	result = append(result, added...)
+	sort.Strings(result)
+	return result, nil
+}
+
+// added returns the paths in the directory that are not in the snapshot.
+func (s *Snapshot) added(seen map[string]bool) (result []string, _ error) {
+	err := filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == s.Dir {
+			return nil
+		}
+		if path == s.store {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); !seen[name] {
+			result = append(result, name)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Restore restores the directory to the snapshot: the files that were changed or removed are written again, and the
+// files that were added are removed.
+func (s *Snapshot) Restore() error {
+	changes, err := s.Changes()
+	if err != nil {
+		return err
+	}
+	changed := map[string]bool{}
+	for _, name := range changes {
+		changed[name] = true
+	}
+
+	seen := map[string]bool{}
+	err = s.each(func(header *tar.Header, r io.Reader) error {
+		seen[header.Name] = true
+		if !changed[header.Name] {
+			return nil
+		}
+		path, err := s.path(header.Name)
+		if err != nil {
+			return err
+		}
+
+		if info, err := os.Lstat(path); err == nil && (info.IsDir() != (header.Typeflag == tar.TypeDir)) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			return os.MkdirAll(path, fs.FileMode(header.Mode).Perm())
+		case tar.TypeSymlink:
+			_ = os.Remove(path)
+			return os.Symlink(header.Linkname, path)
+		}
+
+		// The file is removed first, so files that are links are replaced and not written through
+		_ = os.Remove(path)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(header.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(path, header.ModTime, header.ModTime)
+	})
+	if err != nil {
+		return err
+	}
+
+	added, err := s.added(seen)
+	if err != nil {
+		return err
+	}
+	for _, name := range added {
+		if err := os.RemoveAll(filepath.Join(s.Dir, filepath.FromSlash(name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes the snapshot from its store.
+func (s *Snapshot) Remove() error {
+	return os.RemoveAll(filepath.Join(s.store, s.ID))
+}
@@ -0,0 +1,59 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, ".cache", "snapshots")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0644))
+	require.NoError(t, os.Symlink("README.md", filepath.Join(dir, "link")))
+
+	s, err := Take(dir, store)
+	require.NoError(t, err)
+
+	changes, err := s.Changes()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package other"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "README.md")))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "out", "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "out", "bin", "app"), []byte("app"), 0755))
+
+	changes, err = s.Changes()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md", "out", "src/main.go"}, changes)
+
+	snapshots, err := List(store)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, s.ID, snapshots[0].ID)
+	assert.Equal(t, dir, snapshots[0].Dir)
+
+	require.NoError(t, snapshots[0].Restore())
+	data, err := os.ReadFile(filepath.Join(dir, "src", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package main", string(data))
+	data, err = os.ReadFile(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "readme", string(data))
+	assert.NoDirExists(t, filepath.Join(dir, "out"))
+
+	changes, err = s.Changes()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	require.NoError(t, s.Remove())
+	snapshots, err = List(store)
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
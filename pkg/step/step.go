@@ -0,0 +1,107 @@
+package step
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+// ErrAborted is the error of a run that the user aborted while stepping through it.
+var ErrAborted = errors.New("aborted the run while stepping through it")
+
+// Skipped is the result of a tool call that the user skipped while stepping through the run.
+const Skipped = "The user skipped this tool call, it did not run."
+
+// Action is what the user chose to do with a step of a run.
+type Action string
+
+const (
+	// Continue runs the step, with the corrected arguments of the decision if it has them
+	Continue = Action("continue")
+	// Skip does not run the tool call of the step, its result is Skipped
+	Skip = Action("skip")
+	// Abort stops the run with ErrAborted
+	Abort = Action("abort")
+)
+
+// Step is a call to a model or a tool call that a run is about to make.
+type Step struct {
+	// Tool is the name of the tool that calls the model, or the tool that is called
+	Tool string
+	// Request is the request that is sent to the model, set for the calls to models
+	Request *types.CompletionRequest
+	// Arguments are the JSON arguments of the tool call, set for tool calls
+	Arguments string
+}
+
+// IsModel returns whether the step calls a model, which can not be skipped or edited.
+func (s Step) IsModel() bool {
+	return s.Request != nil
+}
+
+// Decision is what to do with a step.
+type Decision struct {
+	Action Action
+	// Arguments are the arguments that the user corrected the arguments of a tool call to, the tool is called with
+	// its own if not set
+	Arguments string
+}
+
+// Stepper pauses a run before every step, to show it to the user and ask what to do with it, like a debugger.
+type Stepper interface {
+	Step(ctx context.Context, step Step) (Decision, error)
+}
+
+type stepperKey struct{}
+
+// WithStepper returns a context that the runs made with it step through with the stepper.
+func WithStepper(ctx context.Context, s Stepper) context.Context {
+	return context.WithValue(ctx, stepperKey{}, s)
+}
+
+// Model asks the stepper of the context, if it has one, before the tool calls its model with the request, and returns
+// ErrAborted if the user aborts the run.
+func Model(ctx context.Context, tool string, request types.CompletionRequest) error {
+	s, ok := ctx.Value(stepperKey{}).(Stepper)
+	if !ok {
+		return nil
+	}
+	decision, err := s.Step(ctx, Step{
+		Tool:    tool,
+		Request: &request,
+	})
+	if err != nil {
+		return err
+	}
+	if decision.Action == Abort {
+		return ErrAborted
+	}
+	return nil
+}
+
+// Tool asks the stepper of the context, if it has one, before the tool is called with the arguments. It returns the
+// arguments to call the tool with, and whether the call is skipped, or ErrAborted if the user aborts the run.
+func Tool(ctx context.Context, tool, arguments string) (string, bool, error) {
+	s, ok := ctx.Value(stepperKey{}).(Stepper)
+	if !ok {
+		return arguments, false, nil
+	}
+	decision, err := s.Step(ctx, Step{
+		Tool:      tool,
+		Arguments: arguments,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	switch decision.Action {
+	case Abort:
+		return "", false, ErrAborted
+	case Skip:
+		return arguments, true, nil
+	}
+	if decision.Arguments != "" {
+		return decision.Arguments, false, nil
+	}
+	return arguments, false, nil
+}
@@ -0,0 +1,53 @@
+package step
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decide struct {
+	decision Decision
+	steps    []Step
+}
+
+func (d *decide) Step(_ context.Context, step Step) (Decision, error) {
+	d.steps = append(d.steps, step)
+	return d.decision, nil
+}
+
+func TestTool(t *testing.T) {
+	args, skip, err := Tool(context.Background(), "search", `{"q": "go"}`)
+	require.NoError(t, err)
+	assert.False(t, skip)
+	assert.Equal(t, `{"q": "go"}`, args, "the calls of runs that are not stepped through run as they are")
+
+	d := &decide{decision: Decision{Action: Continue, Arguments: `{"q": "rust"}`}}
+	ctx := WithStepper(context.Background(), d)
+	args, skip, err = Tool(ctx, "search", `{"q": "go"}`)
+	require.NoError(t, err)
+	assert.False(t, skip)
+	assert.Equal(t, `{"q": "rust"}`, args)
+	assert.Equal(t, []Step{{Tool: "search", Arguments: `{"q": "go"}`}}, d.steps)
+
+	d.decision = Decision{Action: Skip}
+	_, skip, err = Tool(ctx, "search", `{"q": "go"}`)
+	require.NoError(t, err)
+	assert.True(t, skip)
+
+	d.decision = Decision{Action: Abort}
+	_, _, err = Tool(ctx, "search", `{"q": "go"}`)
+	assert.ErrorIs(t, err, ErrAborted)
+}
+
+func TestModel(t *testing.T) {
+	d := &decide{decision: Decision{Action: Abort}}
+	err := Model(WithStepper(context.Background(), d), "main", types.CompletionRequest{Model: "gpt-4o"})
+	assert.ErrorIs(t, err, ErrAborted)
+	require.Len(t, d.steps, 1)
+	assert.True(t, d.steps[0].IsModel())
+	assert.Equal(t, "gpt-4o", d.steps[0].Request.Model)
+}
@@ -0,0 +1,61 @@
+package system
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	IPv4 = "ipv4"
+	IPv6 = "ipv6"
+)
+
+// Loopback returns the loopback address of the address family, 127.0.0.1 for ipv4 and ::1 for ipv6. If no family is
+// given, 127.0.0.1 is used if it can be listened on, and ::1 otherwise, so environments with only IPv6 loopback work.
+func Loopback(family string) (string, error) {
+	switch family {
+	case IPv4:
+		return "127.0.0.1", nil
+	case IPv6:
+		return "::1", nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid address family %q, must be %s or %s", family, IPv4, IPv6)
+	}
+
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err == nil {
+			_ = l.Close()
+			return host, nil
+		}
+	}
+	return "", fmt.Errorf("neither 127.0.0.1 nor ::1 can be listened on")
+}
+
+// ListenAddress validates an address to listen on. An empty host or localhost is replaced with the loopback address
+// of the address family, so listening does not depend on what localhost resolves to. Other hosts must be IP
+// addresses, since a host name can resolve to different addresses depending on the DNS server.
+func ListenAddress(address, family string) (addr string, loopback bool, err error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+
+	if host == "" || host == "localhost" {
+		host, err = Loopback(family)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false, fmt.Errorf("invalid listen address %q, the host must be an IP address or localhost", address)
+	}
+	if (family == IPv4 && ip.To4() == nil) || (family == IPv6 && ip.To4() != nil) {
+		return "", false, fmt.Errorf("listen address %q is not an %s address", address, family)
+	}
+
+	return net.JoinHostPort(host, port), ip.IsLoopback(), nil
+}
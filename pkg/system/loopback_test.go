@@ -0,0 +1,41 @@
+package system
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenAddress(t *testing.T) {
+	addr, loopback, err := ListenAddress("localhost:9090", IPv6)
+	require.NoError(t, err)
+	require.Equal(t, "[::1]:9090", addr)
+	require.True(t, loopback)
+
+	addr, loopback, err = ListenAddress(":9090", IPv4)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:9090", addr)
+	require.True(t, loopback)
+
+	addr, loopback, err = ListenAddress("0.0.0.0:9090", "")
+	require.NoError(t, err)
+	require.Equal(t, "0.0.0.0:9090", addr)
+	require.False(t, loopback)
+
+	_, _, err = ListenAddress("example.com:9090", "")
+	require.ErrorContains(t, err, "must be an IP address or localhost")
+
+	_, _, err = ListenAddress("127.0.0.1:9090", IPv6)
+	require.ErrorContains(t, err, "not an ipv6 address")
+
+	_, _, err = ListenAddress("localhost:9090", "ipx")
+	require.Error(t, err)
+
+	// Without a family, the loopback address that can be listened on is used
+	host, err := Loopback("")
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+}
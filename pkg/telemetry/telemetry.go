@@ -0,0 +1,219 @@
+// Package telemetry records which features of gptscript are used, and the classes of the errors they fail with, in a
+// local file, when the user opts in. Nothing is sent anywhere: the user exports the file and shares it if they want.
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/gptscript-ai/gptscript/pkg/egress"
+	"github.com/gptscript-ai/gptscript/pkg/fsscope"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/gptscript-ai/gptscript/pkg/version"
+)
+
+// Event is an anonymous record of a command that was run. It has no arguments, values of flags, paths, names of
+// tools other than the builtin ones, or error messages.
+type Event struct {
+	// Day is the day the command was run, without the time of day
+	Day     string `json:"day"`
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	// Command is the command that was run, like run or cache prune
+	Command string `json:"command"`
+	// Features are what the run used, like flag:sandbox, builtin:sys.read or tool:container
+	Features []string `json:"features,omitempty"`
+	// Error is the class of the error the command failed with, like network or tool-not-found
+	Error string `json:"error,omitempty"`
+}
+
+// DefaultFile returns the file that the events are recorded in.
+func DefaultFile() (string, error) {
+	return xdg.DataFile("gptscript/telemetry.jsonl")
+}
+
+// Disabled returns whether the environment opts out of telemetry, with the DO_NOT_TRACK convention, even when it is
+// enabled in the config.
+func Disabled() bool {
+	v := os.Getenv("DO_NOT_TRACK")
+	return v != "" && v != "0" && v != "false"
+}
+
+// NewEvent returns the event of the command, with the features sorted and without duplicates.
+func NewEvent(command string, features []string, err error) Event {
+	seen := map[string]bool{}
+	var unique []string
+	for _, feature := range features {
+		if !seen[feature] {
+			seen[feature] = true
+			unique = append(unique, feature)
+		}
+	}
+	sort.Strings(unique)
+
+	return Event{
+		Day:      time.Now().UTC().Format(time.DateOnly),
+		Version:  version.Get().Tag,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Command:  command,
+		Features: unique,
+		Error:    ErrorClass(err),
+	}
+}
+
+// Record appends the event to the file.
+func Record(file string, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Events returns the events recorded in the file, the lines that are not events are skipped.
+func Events(file string) (result []Event, _ error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			result = append(result, event)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// ErrorClass returns the class of the error, which says what kind of failure it is without what it is about.
+func ErrorClass(err error) string {
+	var (
+		notFound *types.ErrToolNotFound
+		blocked  *egress.ErrBlocked
+		outside  *fsscope.ErrOutside
+		exitErr  *exec.ExitError
+		netErr   net.Error
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &notFound):
+		return "tool-not-found"
+	case errors.As(err, &blocked):
+		return "egress-blocked"
+	case errors.As(err, &outside):
+		return "path-outside-scope"
+	case errors.As(err, &exitErr):
+		return "command-failed"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "other"
+}
+
+// ProgramFeatures returns the features that the tools of the program use: the kinds of the tools, the builtin
+// tools they reference and the directives they set.
+func ProgramFeatures(prg types.Program) (result []string) {
+	for _, tool := range prg.ToolSet {
+		switch {
+		case tool.BuiltinFunc != nil:
+			result = append(result, "builtin:"+tool.ID)
+			continue
+		case tool.IsDaemon():
+			result = append(result, "tool:daemon")
+		case tool.IsOpenAPI():
+			result = append(result, "tool:openapi")
+		case tool.IsWorkflow():
+			result = append(result, "tool:workflow")
+		case tool.Container != "":
+			result = append(result, "tool:container")
+		case tool.IsCommand():
+			result = append(result, "tool:command")
+		default:
+			result = append(result, "tool:prompt")
+		}
+
+		if tool.Source.Repo != nil {
+			result = append(result, "tool:remote")
+		}
+		for directive, set := range map[string]bool{
+			"chat":          tool.Chat,
+			"context":       len(tool.Context) > 0,
+			"credentials":   len(tool.Credentials) > 0,
+			"runtime":       tool.Runtime != "",
+			"sandbox":       len(tool.Sandbox) > 0,
+			"allowed-hosts": len(tool.AllowedHosts) > 0,
+			"allowed-paths": len(tool.AllowedPaths) > 0,
+			"validator":     tool.Validator != "",
+			"shard-with":    tool.ShardWith != "",
+			"json-response": tool.JSONResponse,
+		} {
+			if set {
+				result = append(result, "directive:"+directive)
+			}
+		}
+	}
+	return
+}
+
+// Summary counts how many events have each command, feature and error class, keyed like command:run,
+// builtin:sys.read or error:network.
+func Summary(events []Event) map[string]int {
+	result := map[string]int{}
+	for _, event := range events {
+		result["command:"+event.Command]++
+		for _, feature := range event.Features {
+			result[feature]++
+		}
+		if event.Error != "" {
+			result["error:"+event.Error]++
+		}
+	}
+	return result
+}
+
+// Keys returns the keys of the summary, the most counted first.
+func Keys(summary map[string]int) []string {
+	keys := make([]string, 0, len(summary))
+	for key := range summary {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if summary[keys[i]] != summary[keys[j]] {
+			return summary[keys[i]] > summary[keys[j]]
+		}
+		return strings.Compare(keys[i], keys[j]) < 0
+	})
+	return keys
+}
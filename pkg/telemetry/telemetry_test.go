@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "telemetry", "events.jsonl")
+
+	events, err := Events(file)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	require.NoError(t, Record(file, NewEvent("run", []string{"flag:sandbox", "builtin:sys.read", "flag:sandbox"}, nil)))
+	require.NoError(t, Record(file, NewEvent("run", nil, fmt.Errorf("loading: %w", types.NewErrToolNotFound("secret")))))
+
+	events, err = Events(file)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, []string{"builtin:sys.read", "flag:sandbox"}, events[0].Features)
+	assert.Equal(t, "tool-not-found", events[1].Error)
+
+	summary := Summary(events)
+	assert.Equal(t, map[string]int{
+		"command:run":          2,
+		"builtin:sys.read":     1,
+		"flag:sandbox":         1,
+		"error:tool-not-found": 1,
+	}, summary)
+	assert.Equal(t, "command:run", Keys(summary)[0])
+}
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, "", ErrorClass(nil))
+	assert.Equal(t, "canceled", ErrorClass(fmt.Errorf("run: %w", context.Canceled)))
+	assert.Equal(t, "other", ErrorClass(fmt.Errorf("something about /home/user")))
+}
+
+func TestProgramFeatures(t *testing.T) {
+	features := ProgramFeatures(types.Program{
+		ToolSet: types.ToolSet{
+			"sys.read": {ID: "sys.read", BuiltinFunc: func(context.Context, []string, string) (string, error) { return "", nil }},
+			"main.gpt:1": {
+				Parameters:   types.Parameters{Name: "private", Chat: true, Sandbox: []string{"network"}},
+				Instructions: "Do something",
+			},
+			"main.gpt:5": {Instructions: "#!/bin/bash\necho hi"},
+		},
+	})
+	assert.ElementsMatch(t, []string{"builtin:sys.read", "tool:prompt", "directive:chat", "directive:sandbox", "tool:command"}, features)
+}
@@ -530,6 +530,37 @@ func TestContext(t *testing.T) {
 	assert.Equal(t, "TEST RESULT CALL: 1", x)
 }
 
+func TestInputFrom(t *testing.T) {
+	runner := tester.NewRunner(t)
+	x := runner.RunDefault()
+	assert.Equal(t, "TEST RESULT CALL: 1", x)
+}
+
+func TestWorkflow(t *testing.T) {
+	runner := tester.NewRunner(t)
+	x, err := runner.Run("", `{"topic": "news"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "TEST RESULT CALL: 1 from FETCHED NEWS", x)
+}
+
+func TestWorkflowBranch(t *testing.T) {
+	runner := tester.NewRunner(t)
+	x := runner.RunDefault()
+	assert.Equal(t, "nothing found in total", x)
+}
+
+func TestValidator(t *testing.T) {
+	runner := tester.NewRunner(t)
+	x := runner.RunDefault()
+	assert.Equal(t, "HELLO", x)
+}
+
+func TestValidatorRejected(t *testing.T) {
+	runner := tester.NewRunner(t)
+	_, err := runner.Run("", "")
+	assert.ErrorContains(t, err, "validator [check] rejected the output of tool [greet] 3 times")
+}
+
 func TestCwd(t *testing.T) {
 	runner := tester.NewRunner(t)
 
@@ -559,3 +590,59 @@ func TestExport(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "TEST RESULT CALL: 3", x)
 }
+
+func TestUsage(t *testing.T) {
+	runner := tester.NewRunner(t)
+
+	for range 2 {
+		runner.RespondWith(tester.Result{
+			Func: types.CompletionFunctionCall{
+				Name: "sub",
+			},
+		})
+	}
+	x := runner.RunDefault()
+	assert.Equal(t, "TEST RESULT CALL: 3", x)
+}
+
+func TestArgCoercion(t *testing.T) {
+	runner := tester.NewRunner(t)
+
+	// The whole arguments are sent as a JSON string, with a number for the string argument count
+	runner.RespondWith(tester.Result{
+		Func: types.CompletionFunctionCall{
+			Name:      "repeat",
+			Arguments: `"{\"word\": \"hello\", \"count\": 3}"`,
+		},
+	})
+	x := runner.RunDefault()
+	assert.Equal(t, "TEST RESULT CALL: 2", x)
+}
+
+func TestPause(t *testing.T) {
+	r := tester.NewRunner(t)
+	prg, err := r.Load("")
+	require.NoError(t, err)
+
+	r.RespondWith(tester.Result{
+		Func: types.CompletionFunctionCall{
+			Name: "sub",
+		},
+	})
+
+	paused := make(chan struct{})
+	close(paused)
+	_, err = r.Runner.Run(runner.WithPause(context.Background(), paused), prg, os.Environ(), "")
+	errPaused := (*runner.ErrPaused)(nil)
+	require.ErrorAs(t, err, &errPaused)
+
+	// The sub tool runs when the run is resumed from the saved state
+	var state runner.State
+	require.NoError(t, json.Unmarshal([]byte(toJSONString(t, errPaused.State)), &state))
+	assert.True(t, state.Paused)
+
+	x, err := r.Runner.Resume(context.Background(), prg, os.Environ(), &state)
+	require.NoError(t, err)
+	assert.Equal(t, "TEST RESULT CALL: 3", x)
+	r.AssertResponded(t)
+}
@@ -0,0 +1,123 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/mvl"
+)
+
+var (
+	log = mvl.Package()
+
+	versions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// Options configure TLS for the outbound HTTP connections: the model providers, OpenAPI and HTTP tools, built-in tools,
+// loading remote tools, and downloading runtimes. They are applied by the transport of the options, see Transport,
+// which is passed to the clients that connect, or in the context of their requests, see WithTransport.
+type Options struct {
+	TLSMinVersion   string `usage:"Minimum TLS version of outbound connections (valid: 1.0, 1.1, 1.2, 1.3)" name:"tls-min-version" env:"GPTSCRIPT_TLS_MIN_VERSION"`
+	TLSCipherSuites string `usage:"Comma separated TLS cipher suites of outbound connections, for TLS 1.2 and lower" name:"tls-cipher-suites" env:"GPTSCRIPT_TLS_CIPHER_SUITES"`
+	TLSCAFile       string `usage:"PEM file of root CAs to trust for outbound connections, in addition to the system roots" name:"tls-ca-file" env:"GPTSCRIPT_TLS_CA_FILE"`
+	DisableProxy    bool   `usage:"Ignore the proxy configured with HTTP_PROXY, HTTPS_PROXY, and NO_PROXY for outbound connections" env:"GPTSCRIPT_DISABLE_PROXY"`
+}
+
+// Config returns the TLS configuration of the options, or nil if they do not change the defaults.
+func (o Options) Config() (*tls.Config, error) {
+	if o.TLSMinVersion == "" && o.TLSCipherSuites == "" && o.TLSCAFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{}
+
+	if o.TLSMinVersion != "" {
+		version, ok := versions[o.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS version %q, must be 1.0, 1.1, 1.2, or 1.3", o.TLSMinVersion)
+		}
+		cfg.MinVersion = version
+	}
+
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	for _, name := range strings.Split(o.TLSCipherSuites, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		i := slices.IndexFunc(suites, func(suite *tls.CipherSuite) bool {
+			return suite.Name == name
+		})
+		if i == -1 {
+			return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, suites[i].ID)
+	}
+
+	if o.TLSCAFile != "" {
+		pem, err := os.ReadFile(o.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.TLSCAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// Transport returns a transport of the options, a clone of the default HTTP transport that is configured with them.
+func (o Options) Transport() (*http.Transport, error) {
+	cfg, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg != nil {
+		transport.TLSClientConfig = cfg
+	}
+	if o.DisableProxy {
+		transport.Proxy = nil
+	}
+
+	if cfg != nil || o.DisableProxy {
+		log.Debugf("configured outbound connections: min TLS version %q, cipher suites %q, CA file %q, proxy disabled %v",
+			o.TLSMinVersion, o.TLSCipherSuites, o.TLSCAFile, o.DisableProxy)
+	}
+	return transport, nil
+}
+
+type transportKey struct{}
+
+// WithTransport returns a context whose outbound HTTP requests are sent with the transport.
+func WithTransport(ctx context.Context, transport http.RoundTripper) context.Context {
+	if transport == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, transportKey{}, transport)
+}
+
+// FromContext returns the transport of the context, or http.DefaultTransport if it has none.
+func FromContext(ctx context.Context) http.RoundTripper {
+	if transport, ok := ctx.Value(transportKey{}).(http.RoundTripper); ok {
+		return transport
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,83 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	cfg, err := Options{}.Config()
+	require.NoError(t, err)
+	require.Nil(t, cfg)
+
+	cfg, err = Options{
+		TLSMinVersion:   "1.2",
+		TLSCipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	}.Config()
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	require.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, cfg.CipherSuites)
+
+	_, err = Options{TLSMinVersion: "1.4"}.Config()
+	require.Error(t, err)
+
+	_, err = Options{TLSCipherSuites: "TLS_UNKNOWN"}.Config()
+	require.Error(t, err)
+}
+
+func TestTransport(t *testing.T) {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	s.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	s.StartTLS()
+	defer s.Close()
+
+	// The certificate of the test server is not trusted until its CA file is configured
+	transport, err := Options{}.Transport()
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: transport}).Get(s.URL)
+	require.Error(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: s.Certificate().Raw,
+	}), 0600))
+
+	transport, err = Options{
+		TLSCAFile:    caFile,
+		DisableProxy: true,
+	}.Transport()
+	require.NoError(t, err)
+	require.Nil(t, transport.Proxy)
+
+	resp, err := (&http.Client{Transport: transport}).Get(s.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// The default transport is not changed
+	require.NotSame(t, transport, http.DefaultTransport)
+	_, err = http.Get(s.URL)
+	require.Error(t, err)
+
+	// The transport is passed in the context of the requests
+	ctx := WithTransport(context.Background(), transport)
+	require.Same(t, transport, FromContext(ctx))
+	require.Equal(t, http.DefaultTransport, FromContext(context.Background()))
+
+	// The server does not support TLS 1.3
+	transport, err = Options{
+		TLSCAFile:     caFile,
+		TLSMinVersion: "1.3",
+	}.Transport()
+	require.NoError(t, err)
+	_, err = (&http.Client{Transport: transport}).Get(s.URL)
+	require.ErrorContains(t, err, "protocol version")
+}
@@ -0,0 +1,104 @@
+// Package tokenizer counts the tokens of text for the families of models, so that the limits in tokens, the sizes of
+// shards and the usage reports are accurate for the models that are not OpenAI models too.
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens of text for the models of a family.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// Func is a function that is a Tokenizer.
+type Func func(text string) int
+
+func (f Func) Count(text string) int {
+	return f(text)
+}
+
+// Heuristic estimates the tokens of text from its size, at the given number of characters per token. The characters
+// of scripts without spaces between words, like Chinese and Japanese, are a token each.
+type Heuristic float64
+
+// Fallback is the tokenizer of the models of families that have no tokenizer, at about four characters per token.
+const Fallback = Heuristic(4)
+
+func (h Heuristic) Count(text string) int {
+	var (
+		chars  int
+		tokens int
+	)
+	for _, r := range text {
+		if r >= utf8.RuneSelf && unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			tokens++
+		} else {
+			chars++
+		}
+	}
+	if chars > 0 {
+		tokens += int(math.Ceil(float64(chars) / float64(h)))
+	}
+	return tokens
+}
+
+var (
+	lock       sync.RWMutex
+	tokenizers = map[string]Tokenizer{
+		"gpt-":    Heuristic(4),
+		"o1":      Heuristic(4),
+		"o3":      Heuristic(4),
+		"o4":      Heuristic(4),
+		"claude":  Heuristic(3.5),
+		"gemini":  Heuristic(4),
+		"gemma":   Heuristic(4),
+		"llama":   Heuristic(3.8),
+		"mistral": Heuristic(3.2),
+		"mixtral": Heuristic(3.2),
+		"qwen":    Heuristic(3.7),
+	}
+)
+
+// Register sets the tokenizer of the models of a family, which are the models whose names start with the family, like
+// claude for claude-3-5-sonnet. The tokenizer of the longest family that a model starts with counts its tokens. SDKs
+// register exact tokenizers of the models they use with it, instead of the estimates of the heuristics.
+func Register(family string, t Tokenizer) {
+	lock.Lock()
+	defer lock.Unlock()
+	tokenizers[strings.ToLower(family)] = t
+}
+
+// For returns the tokenizer of the model, or Fallback if its family has none. The model can be a model of a provider,
+// like claude-3-5-sonnet from github.com/gptscript-ai/claude3-anthropic-provider, or have the prefix of its vendor,
+// like anthropic/claude-3-5-sonnet.
+func For(model string) Tokenizer {
+	model, _, _ = strings.Cut(model, " from ")
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var (
+		result Tokenizer = Fallback
+		match  string
+	)
+	for family, t := range tokenizers {
+		if strings.HasPrefix(model, family) && len(family) > len(match) {
+			result, match = t, family
+		}
+	}
+	return result
+}
+
+// Count returns the tokens of the text for the model.
+func Count(model, text string) int {
+	return For(model).Count(text)
+}
@@ -0,0 +1,35 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeuristic(t *testing.T) {
+	assert.Equal(t, 0, Fallback.Count(""))
+	assert.Equal(t, 3, Fallback.Count("hello world"))
+	assert.Equal(t, 4, Heuristic(3.5).Count("hello world"))
+	// The characters of Chinese are a token each
+	assert.Equal(t, 4, Fallback.Count("你好世界"))
+	assert.Equal(t, 4, Fallback.Count("你好 world"))
+}
+
+func TestFor(t *testing.T) {
+	assert.Equal(t, Heuristic(3.5), For("claude-3-5-sonnet"))
+	assert.Equal(t, Heuristic(3.5), For("claude-3-5-sonnet from github.com/gptscript-ai/claude3-anthropic-provider"))
+	assert.Equal(t, Heuristic(3.5), For("anthropic/Claude-3-Opus"))
+	assert.Equal(t, Heuristic(4), For("gpt-4o"))
+	assert.Equal(t, Fallback, For("my-model"))
+	assert.Equal(t, Fallback, For(""))
+
+	Register("claude-3-haiku", Func(func(text string) int {
+		return len(strings.Fields(text))
+	}))
+	defer Register("claude-3-haiku", Heuristic(3.5))
+
+	// The longest family that the model starts with is its tokenizer
+	assert.Equal(t, 2, Count("claude-3-haiku-20240307", "hello world"))
+	assert.Equal(t, 4, Count("claude-3-opus", "hello world"))
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gptscript-ai/gptscript/pkg/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/gptscript-ai/gptscript"
+
+// Tracer exports the spans that end with an exporter of the OpenTelemetry SDK, in batches.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   oteltrace.Tracer
+}
+
+// New returns a tracer that exports the spans that end with the exporter until it is shut down. The spans have the
+// resource, with its service.name set to gptscript if it is not set.
+func New(exporter sdktrace.SpanExporter, res *resource.Resource) (*Tracer, error) {
+	res, err := resource.Merge(resource.NewSchemaless(
+		attribute.String("service.name", version.ProgramName),
+		attribute.String("service.version", version.Get().String()),
+	), res)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	return &Tracer{
+		provider: provider,
+		tracer:   provider.Tracer(instrumentationName, oteltrace.WithInstrumentationVersion(version.Get().String())),
+	}, nil
+}
+
+// FromEnv returns the tracer that the standard environment variables of OpenTelemetry configure, or nil if tracing is
+// not enabled. Tracing is enabled when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT is set,
+// unless OTEL_SDK_DISABLED is true or OTEL_TRACES_EXPORTER is none. The spans are exported with the OTLP exporter of
+// OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL, grpc or http/protobuf, which reads the rest of
+// the OTEL_EXPORTER_OTLP_ variables, and the resource of the spans is read from OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES.
+func FromEnv() (*Tracer, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return nil, nil
+	}
+	switch exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", "otlp":
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q (valid: otlp, none)", exporter)
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return nil, nil
+	}
+
+	var (
+		ctx      = context.Background()
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch protocol := firstEnv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"); protocol {
+	case "grpc":
+		exporter, err = otlptracegrpc.New(ctx)
+	case "", "http/protobuf":
+		exporter, err = otlptracehttp.New(ctx)
+	case "http/json":
+		log.Warnf("Exporting traces with the http/protobuf protocol of OTLP, http/json is not supported")
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q (valid: grpc, http/protobuf)", protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx, resource.WithFromEnv())
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource of the spans: %w", err)
+	}
+
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warnf("Failed to export spans: %v", err)
+	}))
+	return New(exporter, res)
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Flush exports the spans that ended, and waits until they are exported.
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	if err := t.provider.ForceFlush(context.Background()); err != nil {
+		log.Warnf("Failed to export spans: %v", err)
+	}
+}
+
+// Shutdown exports the spans that ended, and stops the tracer.
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	if err := t.provider.Shutdown(context.Background()); err != nil {
+		log.Warnf("Failed to export spans: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package trace
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Transport returns a transport that traces the requests that are sent with it, in a span of the span of the context
+// of each request until the headers of its response are received, and sends the trace to the server in the
+// traceparent header of W3C Trace Context. It returns base if tracing is disabled, or http.DefaultTransport if base is
+// nil.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if current() == nil {
+		return base
+	}
+	return roundTripper{base: base}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+}
+
+func (r roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), req.Method, KindClient,
+		String("http.request.method", req.Method),
+		String("url.full", redactURL(req)),
+		String("server.address", req.URL.Hostname()))
+	if span == nil {
+		return r.base.RoundTrip(req)
+	}
+
+	req = req.Clone(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		span.End(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode > 499 {
+		span.End(fmt.Errorf("status code %d", resp.StatusCode))
+	} else {
+		span.End(nil)
+	}
+	return resp, nil
+}
+
+// redactURL returns the URL of the request without its query and credentials, which can have secrets like API keys.
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,129 @@
+// Package trace traces runs with OpenTelemetry spans: a span for each run, each tool call, each call to a model and
+// each HTTP request, which are exported with OTLP to a collector, like Jaeger or Tempo, when the standard OTEL_
+// environment variables configure one, see FromEnv. It is a thin wrapper of the OpenTelemetry SDK, whose spans do
+// nothing when tracing is disabled.
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Kind is the kind of a span.
+type Kind = oteltrace.SpanKind
+
+// The kinds of spans.
+const (
+	KindInternal = oteltrace.SpanKindInternal
+	KindClient   = oteltrace.SpanKindClient
+)
+
+// Attribute is an attribute of a span.
+type Attribute = attribute.KeyValue
+
+func String(key, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+func Int(key string, value int) Attribute {
+	return attribute.Int(key, value)
+}
+
+func Bool(key string, value bool) Attribute {
+	return attribute.Bool(key, value)
+}
+
+// Span is an operation of a run, like a tool call. The methods of a nil span do nothing, so the code that traces does
+// not check whether tracing is enabled.
+type Span struct {
+	span  oteltrace.Span
+	start time.Time
+}
+
+type spanKey struct{}
+
+var (
+	tracerLock sync.RWMutex
+	tracer     *Tracer
+	initOnce   sync.Once
+	initErr    error
+)
+
+// SetTracer sets the tracer that the spans are exported with, tracing is disabled if it is nil.
+func SetTracer(t *Tracer) {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	tracer = t
+}
+
+// Init sets the tracer that the environment variables configure, see FromEnv, the first time it is called.
+func Init() error {
+	initOnce.Do(func() {
+		var t *Tracer
+		t, initErr = FromEnv()
+		if t != nil {
+			SetTracer(t)
+		}
+	})
+	return initErr
+}
+
+// Flush waits until the spans that ended are exported by the tracer.
+func Flush() {
+	current().Flush()
+}
+
+func current() *Tracer {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+	return tracer
+}
+
+// Start starts a span that is a child of the span of the context, or the root of a new trace, and returns a context
+// with it. It returns a nil span if tracing is disabled.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	t := current()
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		start: time.Now(),
+	}
+	ctx, span.span = t.tracer.Start(ctx, name, oteltrace.WithSpanKind(kind), oteltrace.WithAttributes(attrs...),
+		oteltrace.WithTimestamp(span.start))
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext returns the span of the context, or nil if it has none.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttributes adds the attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+// End ends the span, with the error of the operation if it failed, and queues it to be exported.
+func (s *Span) End(err error) {
+	if s == nil || !s.span.IsRecording() {
+		return
+	}
+
+	end := time.Now()
+	s.span.SetAttributes(Int("gptscript.duration_ms", int(end.Sub(s.start).Milliseconds())))
+	if err != nil {
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End(oteltrace.WithTimestamp(end))
+}
@@ -0,0 +1,186 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func span(spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	return tracetest.SpanStub{}
+}
+
+func attributeValue(attrs []attribute.KeyValue, key string) string {
+	for _, attr := range attrs {
+		if string(attr.Key) == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "run", KindInternal)
+	assert.Nil(t, span)
+	assert.Nil(t, FromContext(ctx))
+	span.SetAttributes(String("key", "value"))
+	span.End(nil)
+
+	assert.Equal(t, http.DefaultTransport, Transport(nil))
+}
+
+func TestExport(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	tracer, err := New(exporter, resource.Empty())
+	require.NoError(t, err)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+	defer tracer.Shutdown()
+
+	ctx, run := Start(context.Background(), "run", KindInternal)
+	callCtx, call := Start(ctx, "tool", KindInternal, String("gptscript.tool.name", "tool"))
+	assert.Equal(t, call, FromContext(callCtx))
+	call.SetAttributes(Int("gen_ai.usage.input_tokens", 42))
+
+	req, err := http.NewRequestWithContext(callCtx, http.MethodGet, upstream.URL+"/path?key=secret", nil)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	call.End(errors.New("failed"))
+	run.End(nil)
+	tracer.Flush()
+
+	spans := exporter.GetSpans()
+	runSpan, callSpan, httpSpan := span(spans, "run"), span(spans, "tool"), span(spans, http.MethodGet)
+	require.True(t, runSpan.SpanContext.IsValid())
+	require.True(t, callSpan.SpanContext.IsValid())
+	require.True(t, httpSpan.SpanContext.IsValid())
+
+	assert.False(t, runSpan.Parent.IsValid())
+	assert.Equal(t, runSpan.SpanContext.TraceID(), callSpan.SpanContext.TraceID())
+	assert.Equal(t, runSpan.SpanContext.SpanID(), callSpan.Parent.SpanID())
+	assert.Equal(t, callSpan.SpanContext.SpanID(), httpSpan.Parent.SpanID())
+	assert.Equal(t, "00-"+httpSpan.SpanContext.TraceID().String()+"-"+httpSpan.SpanContext.SpanID().String()+"-01", traceparent)
+
+	assert.Equal(t, codes.Unset, runSpan.Status.Code)
+	assert.Equal(t, codes.Error, callSpan.Status.Code)
+	assert.Equal(t, "failed", callSpan.Status.Description)
+	assert.Equal(t, codes.Error, httpSpan.Status.Code)
+
+	assert.Equal(t, "42", attributeValue(callSpan.Attributes, "gen_ai.usage.input_tokens"))
+	assert.NotEmpty(t, attributeValue(callSpan.Attributes, "gptscript.duration_ms"))
+	assert.NotEmpty(t, attributeValue(httpSpan.Attributes, "url.full"))
+	assert.False(t, strings.Contains(attributeValue(httpSpan.Attributes, "url.full"), "secret"))
+	assert.Equal(t, "502", attributeValue(httpSpan.Attributes, "http.response.status_code"))
+	assert.Equal(t, "gptscript", attributeValue(runSpan.Resource.Attributes(), "service.name"))
+}
+
+type collector struct {
+	lock     sync.Mutex
+	path     string
+	apiKey   string
+	requests []*collectortrace.ExportTraceServiceRequest
+}
+
+func (c *collector) ServeHTTP(_ http.ResponseWriter, req *http.Request) {
+	data, _ := io.ReadAll(req.Body)
+	var body collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(data, &body); err != nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.path = req.URL.Path
+	c.apiKey = req.Header.Get("api-key")
+	c.requests = append(c.requests, &body)
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	tracer, err := FromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, tracer)
+
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL)
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	tracer, err = FromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, tracer)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	t.Setenv("OTEL_SERVICE_NAME", "scripts")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret")
+	tracer, err = FromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, tracer)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	_, run := Start(context.Background(), "run", KindInternal)
+	run.End(nil)
+	tracer.Shutdown()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	assert.Equal(t, "/v1/traces", c.path)
+	assert.Equal(t, "secret", c.apiKey)
+	require.Len(t, c.requests, 1)
+	resourceSpans := c.requests[0].GetResourceSpans()
+	require.Len(t, resourceSpans, 1)
+	assert.Equal(t, "run", resourceSpans[0].GetScopeSpans()[0].GetSpans()[0].GetName())
+	var serviceName string
+	for _, attr := range resourceSpans[0].GetResource().GetAttributes() {
+		if attr.GetKey() == "service.name" {
+			serviceName = attr.GetValue().GetStringValue()
+		}
+	}
+	assert.Equal(t, "scripts", serviceName)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	tracer, err = FromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, tracer)
+	tracer.Shutdown()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "thrift")
+	_, err = FromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+	_, err = FromEnv()
+	assert.Error(t, err)
+}
@@ -10,6 +10,7 @@ import (
 
 type CompletionRequest struct {
 	Model                string
+	DraftModel           string `json:",omitempty"`
 	InternalSystemPrompt *bool
 	Tools                []CompletionTool
 	Messages             []CompletionMessage
@@ -56,6 +57,20 @@ type CompletionStatus struct {
 	Cached          bool
 	Chunks          any
 	PartialResponse *CompletionMessage
+	// EgressBlocked describes a request of the call that was blocked, since its host is not allowed by the tool
+	EgressBlocked string
+	// RequestPruned describes what was removed from the request to fit the size limit of the provider
+	RequestPruned string
+	// StreamResumed describes how a streamed response that dropped was recovered
+	StreamResumed string
+	// APIRequest describes a request of the call to an API that was sent with the ID RequestID in its request ID
+	// header
+	APIRequest string
+	RequestID  string
+	// DryRun describes a call that was not run, since the run is a dry run, and what it would have run
+	DryRun string
+	// DownloadProgress describes how much of a file that the call downloads was downloaded
+	DownloadProgress string
 }
 
 func (in CompletionMessage) IsToolCall() bool {
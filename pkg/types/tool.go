@@ -12,10 +12,14 @@ import (
 )
 
 const (
-	DaemonPrefix  = "#!sys.daemon"
-	OpenAPIPrefix = "#!sys.openapi"
-	PrintPrefix   = "#!sys.print"
-	CommandPrefix = "#!"
+	DaemonPrefix      = "#!sys.daemon"
+	OpenAPIPrefix     = "#!sys.openapi"
+	GRPCPrefix        = "#!sys.grpc"
+	AsyncAPIPrefix    = "#!sys.asyncapi"
+	PrintPrefix       = "#!sys.print"
+	WorkflowPrefix    = "#!sys.workflow"
+	UnavailablePrefix = "#!sys.unavailable"
+	CommandPrefix     = "#!"
 )
 
 type ErrToolNotFound struct {
@@ -44,6 +48,22 @@ func (p Program) IsChat() bool {
 	return p.ToolSet[p.EntryToolID].Chat
 }
 
+// GetToolByID returns the tool with the given ID. The IDs of tools generated from an operation of an API definition
+// used to be made of the operation number, which was not stable since the operations were numbered in a random order.
+// IDs in that form, as found in chat states saved by older versions, can not be resolved and are reported as such.
+func (p Program) GetToolByID(id string) (Tool, error) {
+	if tool, ok := p.ToolSet[id]; ok {
+		return tool, nil
+	}
+	for _, tool := range p.ToolSet {
+		if tool.Source.Operation != "" && tool.Source.LegacyString() == id {
+			return Tool{}, fmt.Errorf("tool id [%s] is the operation number of an API definition, which identified the tools "+
+				"of older versions and can not be resolved, start a new chat", id)
+		}
+	}
+	return Tool{}, fmt.Errorf("failed to find tool for id [%s]", id)
+}
+
 func (p Program) ChatName() string {
 	if p.IsChat() {
 		name := p.ToolSet[p.EntryToolID].Name
@@ -122,12 +142,20 @@ func (p Program) SetBlocking() Program {
 type BuiltinFunc func(ctx context.Context, env []string, input string) (string, error)
 
 type Parameters struct {
-	Name            string           `json:"name,omitempty"`
-	Description     string           `json:"description,omitempty"`
-	MaxTokens       int              `json:"maxTokens,omitempty"`
-	ModelName       string           `json:"modelName,omitempty"`
-	ModelProvider   bool             `json:"modelProvider,omitempty"`
-	JSONResponse    bool             `json:"jsonResponse,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Usage is the detailed guidance of how to use the tool, that is only added to the conversation with the result
+	// of the first call of the tool, instead of being sent with its description in every request
+	Usage          string `json:"usage,omitempty"`
+	MaxTokens      int    `json:"maxTokens,omitempty"`
+	ModelName      string `json:"modelName,omitempty"`
+	DraftModelName string `json:"draftModelName,omitempty"`
+	ModelProvider  bool   `json:"modelProvider,omitempty"`
+	JSONResponse   bool   `json:"jsonResponse,omitempty"`
+	// OutputFormat is the format of the output of a command, text or json. JSON output is validated and compacted
+	OutputFormat string `json:"outputFormat,omitempty"`
+	// OutputSchema adds a compact schema of the JSON output of a command before it, for the model to read it by
+	OutputSchema    bool             `json:"outputSchema,omitempty"`
 	Chat            bool             `json:"chat,omitempty"`
 	Temperature     *float32         `json:"temperature,omitempty"`
 	Cache           *bool            `json:"cache,omitempty"`
@@ -140,6 +168,16 @@ type Parameters struct {
 	ExportContext   []string         `json:"exportContext,omitempty"`
 	Export          []string         `json:"export,omitempty"`
 	Credentials     []string         `json:"credentials,omitempty"`
+	InputFrom       string           `json:"inputFrom,omitempty"`
+	Container       string           `json:"container,omitempty"`
+	Runtime         string           `json:"runtime,omitempty"`
+	Sandbox         []string         `json:"sandbox,omitempty"`
+	AllowedHosts    []string         `json:"allowedHosts,omitempty"`
+	AllowedPaths    []string         `json:"allowedPaths,omitempty"`
+	Validator       string           `json:"validator,omitempty"`
+	MaxAttempts     int              `json:"maxAttempts,omitempty"`
+	ShardWith       string           `json:"shardWith,omitempty"`
+	ShardSize       int              `json:"shardSize,omitempty"`
 	Blocking        bool             `json:"-"`
 }
 
@@ -180,6 +218,11 @@ func (t Tool) String() string {
 	if t.Parameters.Description != "" {
 		_, _ = fmt.Fprintf(buf, "Description: %s\n", t.Parameters.Description)
 	}
+	if t.Parameters.Usage != "" {
+		for _, line := range strings.Split(t.Parameters.Usage, "\n") {
+			_, _ = fmt.Fprintf(buf, "Usage: %s\n", line)
+		}
+	}
 	if len(t.Parameters.Tools) != 0 {
 		_, _ = fmt.Fprintf(buf, "Tools: %s\n", strings.Join(t.Parameters.Tools, ", "))
 	}
@@ -198,12 +241,21 @@ func (t Tool) String() string {
 	if t.Parameters.ModelName != "" {
 		_, _ = fmt.Fprintf(buf, "Model: %s\n", t.Parameters.ModelName)
 	}
+	if t.Parameters.DraftModelName != "" {
+		_, _ = fmt.Fprintf(buf, "Draft Model: %s\n", t.Parameters.DraftModelName)
+	}
 	if t.Parameters.ModelProvider {
 		_, _ = fmt.Fprintf(buf, "Model Provider: true\n")
 	}
 	if t.Parameters.JSONResponse {
 		_, _ = fmt.Fprintln(buf, "JSON Response: true")
 	}
+	if t.Parameters.OutputFormat != "" {
+		_, _ = fmt.Fprintf(buf, "Output Format: %s\n", t.Parameters.OutputFormat)
+	}
+	if t.Parameters.OutputSchema {
+		_, _ = fmt.Fprintln(buf, "Output Schema: true")
+	}
 	if t.Parameters.Cache != nil && !*t.Parameters.Cache {
 		_, _ = fmt.Fprintln(buf, "Cache: false")
 	}
@@ -231,6 +283,36 @@ func (t Tool) String() string {
 	if len(t.Parameters.Credentials) > 0 {
 		_, _ = fmt.Fprintf(buf, "Credentials: %s\n", strings.Join(t.Parameters.Credentials, ", "))
 	}
+	if t.Parameters.Container != "" {
+		_, _ = fmt.Fprintf(buf, "Container: %s\n", t.Parameters.Container)
+	}
+	if t.Parameters.Runtime != "" {
+		_, _ = fmt.Fprintf(buf, "Runtime: %s\n", t.Parameters.Runtime)
+	}
+	if len(t.Parameters.Sandbox) > 0 {
+		_, _ = fmt.Fprintf(buf, "Sandbox: %s\n", strings.Join(t.Parameters.Sandbox, ", "))
+	}
+	if len(t.Parameters.AllowedHosts) > 0 {
+		_, _ = fmt.Fprintf(buf, "Allowed Hosts: %s\n", strings.Join(t.Parameters.AllowedHosts, ", "))
+	}
+	if len(t.Parameters.AllowedPaths) > 0 {
+		_, _ = fmt.Fprintf(buf, "Allowed Paths: %s\n", strings.Join(t.Parameters.AllowedPaths, ", "))
+	}
+	if t.Parameters.InputFrom != "" {
+		_, _ = fmt.Fprintf(buf, "Input From: %s\n", t.Parameters.InputFrom)
+	}
+	if t.Parameters.Validator != "" {
+		_, _ = fmt.Fprintf(buf, "Validator: %s\n", t.Parameters.Validator)
+	}
+	if t.Parameters.MaxAttempts != 0 {
+		_, _ = fmt.Fprintf(buf, "Max Attempts: %d\n", t.Parameters.MaxAttempts)
+	}
+	if t.Parameters.ShardWith != "" {
+		_, _ = fmt.Fprintf(buf, "Shard With: %s\n", t.Parameters.ShardWith)
+	}
+	if t.Parameters.ShardSize != 0 {
+		_, _ = fmt.Fprintf(buf, "Shard Size: %d\n", t.Parameters.ShardSize)
+	}
 	if t.Chat {
 		_, _ = fmt.Fprintf(buf, "Chat: true")
 	}
@@ -346,10 +428,21 @@ type Repo struct {
 type ToolSource struct {
 	Location string `json:"location,omitempty"`
 	LineNo   int    `json:"lineNo,omitempty"`
-	Repo     *Repo  `json:"repo,omitempty"`
+	// Operation identifies the operation of an API definition the tool was generated from. When set, it is used
+	// instead of LineNo to identify the tool, so the ID does not change when other operations are added or removed.
+	Operation string `json:"operation,omitempty"`
+	Repo      *Repo  `json:"repo,omitempty"`
 }
 
 func (t ToolSource) String() string {
+	if t.Operation != "" {
+		return fmt.Sprintf("%s:%s", t.Location, t.Operation)
+	}
+	return t.LegacyString()
+}
+
+// LegacyString returns the identifier of the source made of the location and line number, ignoring the operation.
+func (t ToolSource) LegacyString() string {
 	return fmt.Sprintf("%s:%d", t.Location, t.LineNo)
 }
 
@@ -365,10 +458,26 @@ func (t Tool) IsOpenAPI() bool {
 	return strings.HasPrefix(t.Instructions, OpenAPIPrefix)
 }
 
+func (t Tool) IsGRPC() bool {
+	return strings.HasPrefix(t.Instructions, GRPCPrefix)
+}
+
+func (t Tool) IsAsyncAPI() bool {
+	return strings.HasPrefix(t.Instructions, AsyncAPIPrefix)
+}
+
 func (t Tool) IsPrint() bool {
 	return strings.HasPrefix(t.Instructions, PrintPrefix)
 }
 
+func (t Tool) IsWorkflow() bool {
+	return strings.HasPrefix(t.Instructions, WorkflowPrefix)
+}
+
+func (t Tool) IsUnavailable() bool {
+	return strings.HasPrefix(t.Instructions, UnavailablePrefix)
+}
+
 func (t Tool) IsHTTP() bool {
 	return strings.HasPrefix(t.Instructions, "#!http://") ||
 		strings.HasPrefix(t.Instructions, "#!https://")
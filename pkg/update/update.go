@@ -0,0 +1,350 @@
+// Package update updates the gptscript binary to the releases of its GitHub repository.
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+
+	DefaultReleasesURL = "https://api.github.com/repos/gptscript-ai/gptscript/releases"
+
+	checksumsName = "checksums.txt"
+)
+
+// PublicKey is the PEM encoded ECDSA key that the checksums of the releases are signed with, it is set when the
+// releases are built.
+var PublicKey = ""
+
+type Options struct {
+	// Channel is the channel that the latest release is looked up in, the beta channel includes the prereleases
+	Channel string
+	// ReleasesURL is the GitHub API URL of the releases of the repository
+	ReleasesURL string
+	// PublicKey is the key that the checksums of releases must be signed with, PublicKey if not set
+	PublicKey     string
+	SkipSignature bool
+	Client        *http.Client
+}
+
+func complete(opts ...Options) (result Options) {
+	for _, opt := range opts {
+		result.Channel = types.FirstSet(opt.Channel, result.Channel)
+		result.ReleasesURL = types.FirstSet(opt.ReleasesURL, result.ReleasesURL)
+		result.PublicKey = types.FirstSet(opt.PublicKey, result.PublicKey)
+		result.SkipSignature = types.FirstSet(opt.SkipSignature, result.SkipSignature)
+		result.Client = types.FirstSet(opt.Client, result.Client)
+	}
+	result.Channel = types.FirstSet(result.Channel, ChannelStable)
+	result.ReleasesURL = types.FirstSet(result.ReleasesURL, DefaultReleasesURL)
+	result.PublicKey = types.FirstSet(result.PublicKey, PublicKey)
+	result.Client = types.FirstSet(result.Client, http.DefaultClient)
+	return
+}
+
+type Release struct {
+	Tag        string  `json:"tag_name"`
+	Draft      bool    `json:"draft"`
+	Prerelease bool    `json:"prerelease"`
+	Assets     []Asset `json:"assets"`
+}
+
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r *Release) asset(name string) (Asset, error) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("release %s has no %s", r.Tag, name)
+}
+
+// Latest returns the newest release of the channel.
+func Latest(ctx context.Context, opts ...Options) (*Release, error) {
+	opt := complete(opts...)
+	if opt.Channel != ChannelStable && opt.Channel != ChannelBeta {
+		return nil, fmt.Errorf("invalid channel %q, it must be %s or %s", opt.Channel, ChannelStable, ChannelBeta)
+	}
+
+	var releases []Release
+	if err := getJSON(ctx, opt.Client, opt.ReleasesURL, &releases); err != nil {
+		return nil, err
+	}
+	// GitHub lists the releases from the newest to the oldest
+	for _, release := range releases {
+		if !release.Draft && (!release.Prerelease || opt.Channel == ChannelBeta) {
+			return &release, nil
+		}
+	}
+	return nil, fmt.Errorf("there are no releases in the %s channel", opt.Channel)
+}
+
+// ByTag returns the release of the tag, like v0.8.0.
+func ByTag(ctx context.Context, tag string, opts ...Options) (*Release, error) {
+	opt := complete(opts...)
+	if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+	var release Release
+	if err := getJSON(ctx, opt.Client, opt.ReleasesURL+"/tags/"+tag, &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// Download returns the gptscript binary of the release for this OS and architecture, after verifying the
+// signature of the checksums of the release and the checksum of its archive.
+func Download(ctx context.Context, release *Release, opts ...Options) ([]byte, error) {
+	opt := complete(opts...)
+
+	archiveName, binaryName := assetNames(release.Tag, runtime.GOOS, runtime.GOARCH)
+	checksums, err := download(ctx, opt.Client, release, checksumsName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opt.SkipSignature {
+		if opt.PublicKey == "" {
+			return nil, fmt.Errorf("this build of gptscript can not verify the signatures of releases, skip the verification to only verify their checksums")
+		}
+		signature, err := download(ctx, opt.Client, release, checksumsName+".sig")
+		if err != nil {
+			return nil, err
+		}
+		if err := verifySignature(opt.PublicKey, checksums, signature); err != nil {
+			return nil, fmt.Errorf("the checksums of release %s are not signed by the gptscript release key: %w", release.Tag, err)
+		}
+	}
+
+	archive, err := download(ctx, opt.Client, release, archiveName)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(checksums, archiveName, archive); err != nil {
+		return nil, err
+	}
+
+	return extract(archive, archiveName, binaryName)
+}
+
+// assetNames returns the name of the archive of the release for the OS and architecture, and the name of the
+// binary in it.
+func assetNames(tag, goos, goarch string) (archive, binary string) {
+	switch goos {
+	case "darwin":
+		return fmt.Sprintf("gptscript-%s-macOS-universal.tar.gz", tag), "gptscript"
+	case "windows":
+		return fmt.Sprintf("gptscript-%s-windows-%s.zip", tag, goarch), "gptscript.exe"
+	default:
+		return fmt.Sprintf("gptscript-%s-%s-%s.tar.gz", tag, goos, goarch), "gptscript"
+	}
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("release not found: %s", url)
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func download(ctx context.Context, client *http.Client, release *Release, name string) ([]byte, error) {
+	asset, err := release.asset(name)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", asset.URL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifySignature verifies the signature of the checksums, the base64 encoded ECDSA signature of their SHA-256
+// hash that cosign sign-blob writes.
+func verifySignature(publicKey string, checksums, signature []byte) error {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return fmt.Errorf("invalid public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("the public key is not an ECDSA key")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	hash := sha256.Sum256(checksums)
+	if !ecdsa.VerifyASN1(ecdsaKey, hash[:], sig) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func verifyChecksum(checksums []byte, name string, data []byte) error {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		expected, file, ok := strings.Cut(strings.TrimSpace(line), "  ")
+		if !ok || strings.TrimSpace(file) != name {
+			continue
+		}
+		hash := sha256.Sum256(data)
+		if actual := hex.EncodeToString(hash[:]); actual != expected {
+			return fmt.Errorf("the checksum of %s is %s, but the release says it is %s", name, actual, expected)
+		}
+		return nil
+	}
+	return fmt.Errorf("the checksums of the release have no checksum of %s", name)
+}
+
+func extract(archive []byte, archiveName, binaryName string) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range r.File {
+			if path.Base(f.Name) == binaryName {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s has no %s", archiveName, binaryName)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s has no %s", archiveName, binaryName)
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == binaryName {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// previous returns the path that the binary that an update replaces is kept at.
+func previous(exe string) string {
+	return exe + ".previous"
+}
+
+// Replace replaces the binary at exe with the new binary, and keeps the binary it replaces to roll back to.
+func Replace(exe string, binary []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	// The new binary is written next to the old one, so that it can be renamed into its place
+	f, err := os.CreateTemp(filepath.Dir(exe), ".gptscript-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to write the new binary next to %s: %w", exe, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(binary); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+
+	if err := os.Rename(exe, previous(exe)); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), exe); err != nil {
+		return errors.Join(err, os.Rename(previous(exe), exe))
+	}
+	return nil
+}
+
+// Rollback swaps the binary at exe with the binary that the last update replaced, so that rolling back again
+// returns to the updated binary.
+func Rollback(exe string) error {
+	if _, err := os.Stat(previous(exe)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("there is no previous version of %s to roll back to", exe)
+	} else if err != nil {
+		return err
+	}
+
+	tmp := exe + ".rollback"
+	if err := os.Rename(exe, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(previous(exe), exe); err != nil {
+		return errors.Join(err, os.Rename(tmp, exe))
+	}
+	return os.Rename(tmp, previous(exe))
+}
@@ -0,0 +1,149 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReleases serves the releases v1.1.0-rc.1 and v1.0.0, with the files of the release assets.
+func testReleases(t *testing.T, key *ecdsa.PrivateKey, binary []byte) *httptest.Server {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "gptscript", Mode: 0o755, Size: int64(len(binary)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(binary)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	archiveName, _ := assetNames("v1.0.0", runtime.GOOS, runtime.GOARCH)
+	hash := sha256.Sum256(archive.Bytes())
+	checksums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(hash[:]), archiveName)
+	checksumsHash := sha256.Sum256([]byte(checksums))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, checksumsHash[:])
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		archiveName:            archive.Bytes(),
+		checksumsName:          []byte(checksums),
+		checksumsName + ".sig": []byte(base64.StdEncoding.EncodeToString(sig)),
+	}
+
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release := func(tag string, prerelease bool) Release {
+			result := Release{Tag: tag, Prerelease: prerelease}
+			for name := range files {
+				result.Assets = append(result.Assets, Asset{Name: name, URL: s.URL + "/files/" + name})
+			}
+			return result
+		}
+		switch r.URL.Path {
+		case "/releases":
+			_ = json.NewEncoder(w).Encode([]Release{release("v1.1.0-rc.1", true), release("v1.0.0", false)})
+		case "/releases/tags/v1.0.0":
+			_ = json.NewEncoder(w).Encode(release("v1.0.0", false))
+		default:
+			data, ok := files[filepath.Base(r.URL.Path)]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestLatest(t *testing.T) {
+	key, _ := testKey(t)
+	s := testReleases(t, key, []byte("binary"))
+	ctx := context.Background()
+
+	release, err := Latest(ctx, Options{ReleasesURL: s.URL + "/releases"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", release.Tag)
+
+	release, err = Latest(ctx, Options{ReleasesURL: s.URL + "/releases", Channel: ChannelBeta})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1.0-rc.1", release.Tag)
+
+	_, err = Latest(ctx, Options{ReleasesURL: s.URL + "/releases", Channel: "nightly"})
+	assert.ErrorContains(t, err, "invalid channel")
+}
+
+func TestDownload(t *testing.T) {
+	key, publicKey := testKey(t)
+	s := testReleases(t, key, []byte("binary"))
+	ctx := context.Background()
+
+	release, err := ByTag(ctx, "1.0.0", Options{ReleasesURL: s.URL + "/releases"})
+	require.NoError(t, err)
+
+	binary, err := Download(ctx, release, Options{PublicKey: publicKey})
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(binary))
+
+	_, otherKey := testKey(t)
+	_, err = Download(ctx, release, Options{PublicKey: otherKey})
+	assert.ErrorContains(t, err, "not signed by the gptscript release key")
+
+	_, err = Download(ctx, release)
+	assert.ErrorContains(t, err, "can not verify the signatures")
+
+	binary, err = Download(ctx, release, Options{SkipSignature: true})
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(binary))
+}
+
+func TestReplaceAndRollback(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "gptscript")
+	require.NoError(t, os.WriteFile(exe, []byte("old"), 0o755))
+
+	assert.ErrorContains(t, Rollback(exe), "no previous version")
+
+	require.NoError(t, Replace(exe, []byte("new")))
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+
+	require.NoError(t, Rollback(exe))
+	data, err = os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data))
+
+	require.NoError(t, Rollback(exe))
+	data, err = os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+}
@@ -0,0 +1,337 @@
+// Package usage counts the tokens that the calls to models of a run use, by model and by tool, and what they cost
+// with a table of the prices of the models.
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/gptscript-ai/gptscript/pkg/engine"
+	"github.com/gptscript-ai/gptscript/pkg/tokenizer"
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Price is the price of a model, in dollars per million tokens.
+type Price struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// Prices are the prices of models, by the name of the model or of its family, like gpt-4o for gpt-4o-2024-08-06. The
+// price of the longest name that a model starts with is its price.
+type Prices map[string]Price
+
+// DefaultPrices are the prices of the models of the providers at the time of the release, the prices of a file of
+// LoadPrices override them.
+var DefaultPrices = Prices{
+	"gpt-4o":            {Input: 2.5, Output: 10},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+	"gpt-4.1":           {Input: 2, Output: 8},
+	"gpt-4.1-mini":      {Input: 0.4, Output: 1.6},
+	"gpt-4.1-nano":      {Input: 0.1, Output: 0.4},
+	"o1":                {Input: 15, Output: 60},
+	"o3":                {Input: 2, Output: 8},
+	"o3-mini":           {Input: 1.1, Output: 4.4},
+	"o4-mini":           {Input: 1.1, Output: 4.4},
+	"claude-3-5-sonnet": {Input: 3, Output: 15},
+	"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+	"claude-3-7-sonnet": {Input: 3, Output: 15},
+	"claude-3-opus":     {Input: 15, Output: 75},
+}
+
+// pricesFile is the file of prices, written in YAML.
+type pricesFile struct {
+	Prices Prices `yaml:"prices"`
+}
+
+// LoadPrices reads a YAML file of the prices of models, like:
+//
+//	prices:
+//	  gpt-4o: {input: 2.5, output: 10}
+//	  llama3.1: {input: 0, output: 0}
+//
+// over DefaultPrices.
+func LoadPrices(file string) (Prices, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var f pricesFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid prices %s: %w", file, err)
+	}
+
+	result := make(Prices, len(DefaultPrices)+len(f.Prices))
+	for name, price := range DefaultPrices {
+		result[name] = price
+	}
+	for name, price := range f.Prices {
+		result[strings.ToLower(name)] = price
+	}
+	return result, nil
+}
+
+// For returns the price of the model, and whether it has one. The model can be a model of a provider, like
+// claude-3-5-sonnet from github.com/gptscript-ai/claude3-anthropic-provider, or have the prefix of its vendor, like
+// anthropic/claude-3-5-sonnet, but a price of its full name is its price first.
+func (p Prices) For(model string) (Price, bool) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	model, _, _ = strings.Cut(model, " from ")
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+
+	var (
+		result Price
+		match  string
+	)
+	for name, price := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(match) {
+			result, match = price, name
+		}
+	}
+	return result, match != ""
+}
+
+// Tokens are the tokens of calls to models, and what they cost.
+type Tokens struct {
+	Calls int `json:"calls"`
+	// CachedCalls are the calls whose responses were cached, which use and cost no tokens
+	CachedCalls  int `json:"cachedCalls,omitempty"`
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+	// Cost is the cost in dollars, nil if a model of the calls has no price
+	Cost *float64 `json:"cost,omitempty"`
+}
+
+func (t *Tokens) add(o Tokens) {
+	t.Calls += o.Calls
+	t.CachedCalls += o.CachedCalls
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+}
+
+// ModelUsage is the usage of a model in a run.
+type ModelUsage struct {
+	Model string `json:"model"`
+	Tokens
+}
+
+// ToolUsage is the usage of the calls to models of a tool in a run.
+type ToolUsage struct {
+	Tool string `json:"tool"`
+	Tokens
+}
+
+// Report is the usage of a run. The tokens are counted with the tokenizers of the models, see tokenizer.Count, since
+// the providers stream responses without their usage.
+type Report struct {
+	Total  Tokens       `json:"total"`
+	Models []ModelUsage `json:"models,omitempty"`
+	Tools  []ToolUsage  `json:"tools,omitempty"`
+}
+
+// Write writes the report as tables by model and by tool.
+func (r Report) Write(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "MODEL\tCALLS\tINPUT TOKENS (ESTIMATED)\tOUTPUT TOKENS (ESTIMATED)\tCOST")
+	for _, model := range r.Models {
+		writeRow(w, model.Model, model.Tokens)
+	}
+	_, _ = fmt.Fprintln(w, "\t\t\t\t")
+	_, _ = fmt.Fprintln(w, "TOOL\tCALLS\tINPUT TOKENS (ESTIMATED)\tOUTPUT TOKENS (ESTIMATED)\tCOST")
+	for _, tool := range r.Tools {
+		writeRow(w, tool.Tool, tool.Tokens)
+	}
+	writeRow(w, "TOTAL", r.Total)
+	return w.Flush()
+}
+
+func writeRow(w io.Writer, name string, t Tokens) {
+	calls := fmt.Sprint(t.Calls)
+	if t.CachedCalls > 0 {
+		calls = fmt.Sprintf("%d (%d cached)", t.Calls, t.CachedCalls)
+	}
+	cost := "-"
+	if t.Cost != nil {
+		cost = fmt.Sprintf("$%.4f", *t.Cost)
+	}
+	_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", name, calls, t.InputTokens, t.OutputTokens, cost)
+}
+
+// usage is the tokens of a model or a tool, with the cost of the calls to the models that have prices.
+type usage struct {
+	Tokens
+	cost     float64
+	unpriced bool
+}
+
+func (u *usage) add(t Tokens, price Price, priced bool) {
+	u.Tokens.add(t)
+	u.cost += (float64(t.InputTokens)*price.Input + float64(t.OutputTokens)*price.Output) / 1_000_000
+	u.unpriced = u.unpriced || (!priced && t.InputTokens+t.OutputTokens > 0)
+}
+
+func (u *usage) tokens() Tokens {
+	result := u.Tokens
+	if !u.unpriced {
+		cost := u.cost
+		result.Cost = &cost
+	}
+	return result
+}
+
+// Tracker counts the tokens of the calls to models of a run.
+type Tracker struct {
+	lock   sync.Mutex
+	prices Prices
+	total  usage
+	models map[string]*usage
+	tools  map[string]*usage
+}
+
+// NewTracker returns a tracker whose costs are of the prices, or of DefaultPrices if nil.
+func NewTracker(prices Prices) *Tracker {
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	return &Tracker{
+		prices: prices,
+		models: map[string]*usage{},
+		tools:  map[string]*usage{},
+	}
+}
+
+// Add adds the tokens of calls of the tool to the model.
+func (t *Tracker) Add(tool, model string, tokens Tokens) {
+	price, priced := t.prices.For(model)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	get(t.models, model).add(tokens, price, priced)
+	get(t.tools, tool).add(tokens, price, priced)
+	t.total.add(tokens, price, priced)
+}
+
+func get(m map[string]*usage, key string) *usage {
+	u, ok := m[key]
+	if !ok {
+		u = &usage{}
+		m[key] = u
+	}
+	return u
+}
+
+// Report returns the usage of the calls so far, the models and the tools sorted by name.
+func (t *Tracker) Report() Report {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := Report{
+		Total: t.total.tokens(),
+	}
+	for model, u := range t.models {
+		result.Models = append(result.Models, ModelUsage{Model: model, Tokens: u.tokens()})
+	}
+	for tool, u := range t.tools {
+		result.Tools = append(result.Tools, ToolUsage{Tool: tool, Tokens: u.tokens()})
+	}
+	sort.Slice(result.Models, func(i, j int) bool {
+		return result.Models[i].Model < result.Models[j].Model
+	})
+	sort.Slice(result.Tools, func(i, j int) bool {
+		return result.Tools[i].Tool < result.Tools[j].Tool
+	})
+	return result
+}
+
+type trackerKey struct{}
+
+// WithTracker returns a context that the calls to models of the runs made with it are counted in by the tracker.
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// FromContext returns the tracker of the context, or nil if it has none.
+func FromContext(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+// Model returns the model that the tool calls, whose calls are counted in the tracker of the context if it has one.
+func Model(ctx context.Context, tool string, model engine.Model) engine.Model {
+	t := FromContext(ctx)
+	if t == nil {
+		return model
+	}
+	return trackedModel{
+		Model:   model,
+		tracker: t,
+		tool:    tool,
+	}
+}
+
+type trackedModel struct {
+	engine.Model
+	tracker *Tracker
+	tool    string
+}
+
+func (m trackedModel) Call(ctx context.Context, request types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	var (
+		cached  bool
+		forward = make(chan types.CompletionStatus)
+		done    = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for s := range forward {
+			cached = cached || s.Cached
+			status <- s
+		}
+	}()
+
+	resp, err := m.Model.Call(ctx, request, forward)
+	close(forward)
+	<-done
+	if err != nil {
+		return resp, err
+	}
+
+	tokens := Tokens{
+		Calls: 1,
+	}
+	if cached {
+		tokens.CachedCalls = 1
+	} else {
+		tokens.InputTokens = count(request.Model, request.Messages)
+		if resp != nil {
+			tokens.OutputTokens = count(request.Model, resp.Content)
+		}
+	}
+	m.tracker.Add(m.tool, request.Model, tokens)
+	return resp, nil
+}
+
+// count returns the tokens of the JSON of the value for the model.
+func count(model string, v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return tokenizer.Count(model, string(data))
+}
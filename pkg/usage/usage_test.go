@@ -0,0 +1,123 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeModel struct {
+	cached bool
+}
+
+func (f fakeModel) Call(_ context.Context, request types.CompletionRequest, status chan<- types.CompletionStatus) (*types.CompletionMessage, error) {
+	resp := &types.CompletionMessage{
+		Role:    types.CompletionMessageRoleTypeAssistant,
+		Content: types.Text("the answer"),
+	}
+	status <- types.CompletionStatus{
+		Request: request,
+	}
+	status <- types.CompletionStatus{
+		Response: *resp,
+		Cached:   f.cached,
+	}
+	return resp, nil
+}
+
+func drain() chan<- types.CompletionStatus {
+	status := make(chan types.CompletionStatus)
+	go func() {
+		for range status {
+		}
+	}()
+	return status
+}
+
+func TestPricesFor(t *testing.T) {
+	price, ok := DefaultPrices.For("gpt-4o-mini-2024-07-18")
+	assert.True(t, ok)
+	assert.Equal(t, Price{Input: 0.15, Output: 0.6}, price)
+
+	price, ok = DefaultPrices.For("claude-3-5-sonnet-latest from github.com/gptscript-ai/claude3-anthropic-provider")
+	assert.True(t, ok)
+	assert.Equal(t, Price{Input: 3, Output: 15}, price)
+
+	_, ok = DefaultPrices.For("llama3.1")
+	assert.False(t, ok)
+}
+
+func TestLoadPrices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prices.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("prices:\n  gpt-4o: {input: 1, output: 2}\n  Llama3.1: {input: 0, output: 0}\n"), 0600))
+
+	prices, err := LoadPrices(file)
+	require.NoError(t, err)
+	assert.Equal(t, Price{Input: 1, Output: 2}, prices["gpt-4o"])
+	_, ok := prices.For("llama3.1:8b")
+	assert.True(t, ok)
+	_, ok = prices.For("claude-3-opus")
+	assert.True(t, ok)
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(Prices{"priced": {Input: 1_000_000, Output: 2_000_000}})
+	tracker.Add("main", "priced", Tokens{Calls: 1, InputTokens: 10, OutputTokens: 5})
+	tracker.Add("helper", "priced", Tokens{Calls: 1, InputTokens: 1, OutputTokens: 1})
+	tracker.Add("helper", "unpriced", Tokens{Calls: 1, InputTokens: 1, OutputTokens: 1})
+	tracker.Add("main", "unpriced", Tokens{Calls: 1, CachedCalls: 1})
+
+	report := tracker.Report()
+	assert.Equal(t, 4, report.Total.Calls)
+	assert.Equal(t, 1, report.Total.CachedCalls)
+	assert.Equal(t, 12, report.Total.InputTokens)
+	assert.Nil(t, report.Total.Cost)
+
+	require.Len(t, report.Models, 2)
+	assert.Equal(t, "priced", report.Models[0].Model)
+	require.NotNil(t, report.Models[0].Cost)
+	assert.Equal(t, float64(11+2*6), *report.Models[0].Cost)
+	assert.Nil(t, report.Models[1].Cost)
+
+	require.Len(t, report.Tools, 2)
+	assert.Equal(t, "helper", report.Tools[0].Tool)
+	assert.Nil(t, report.Tools[0].Cost)
+	assert.Equal(t, "main", report.Tools[1].Tool)
+	require.NotNil(t, report.Tools[1].Cost)
+	assert.Equal(t, float64(10+2*5), *report.Tools[1].Cost)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, report.Write(out))
+	assert.Contains(t, out.String(), "$20.0000")
+	assert.Contains(t, out.String(), "2 (1 cached)")
+}
+
+func TestModel(t *testing.T) {
+	assert.Equal(t, fakeModel{}, Model(context.Background(), "main", fakeModel{}))
+
+	tracker := NewTracker(nil)
+	ctx := WithTracker(context.Background(), tracker)
+	request := types.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []types.CompletionMessage{{Role: types.CompletionMessageRoleTypeUser, Content: types.Text("the question")}},
+	}
+
+	_, err := Model(ctx, "main", fakeModel{}).Call(ctx, request, drain())
+	require.NoError(t, err)
+	_, err = Model(ctx, "main", fakeModel{cached: true}).Call(ctx, request, drain())
+	require.NoError(t, err)
+
+	report := tracker.Report()
+	assert.Equal(t, 2, report.Total.Calls)
+	assert.Equal(t, 1, report.Total.CachedCalls)
+	assert.Positive(t, report.Total.InputTokens)
+	assert.Positive(t, report.Total.OutputTokens)
+	require.NotNil(t, report.Total.Cost)
+	assert.Positive(t, *report.Total.Cost)
+}
@@ -0,0 +1,113 @@
+package vars
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EnvPrefix is the prefix of the environment variables that command tools get the variables of the run as.
+const EnvPrefix = "GPTSCRIPT_VAR_"
+
+var validName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Vars are the variables of a run, set with --var and with sys.var.set. The command tools of the run get them as
+// environment variables, and models read them with sys.var.get.
+type Vars struct {
+	lock   sync.RWMutex
+	values map[string]string
+}
+
+func New(values map[string]string) *Vars {
+	v := &Vars{
+		values: map[string]string{},
+	}
+	for name, value := range values {
+		v.values[name] = value
+	}
+	return v
+}
+
+// Parse parses the variables of --var, like region=us-east-1.
+func Parse(vars []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid variable %q, variables are set as name=value", v)
+		}
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+func checkName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q, names can only have letters, digits and '_', and can not start with a digit", name)
+	}
+	return nil
+}
+
+// Get returns the value of the variable, and false if it is not set.
+func (v *Vars) Get(name string) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	value, ok := v.values[name]
+	return value, ok
+}
+
+// Set sets the value of the variable for the rest of the run.
+func (v *Vars) Set(name, value string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.values[name] = value
+	return nil
+}
+
+// All returns the variables by name.
+func (v *Vars) All() map[string]string {
+	result := map[string]string{}
+	if v == nil {
+		return result
+	}
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	for name, value := range v.values {
+		result[name] = value
+	}
+	return result
+}
+
+// Env returns the variables as environment variables, like GPTSCRIPT_VAR_region=us-east-1, sorted by name.
+func (v *Vars) Env() (result []string) {
+	for name, value := range v.All() {
+		result = append(result, EnvPrefix+name+"="+value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+type varsKey struct{}
+
+// WithVars returns a context with the variables of a run.
+func WithVars(ctx context.Context, v *Vars) context.Context {
+	return context.WithValue(ctx, varsKey{}, v)
+}
+
+// FromContext returns the variables of the run of the context, or nil if it has none.
+func FromContext(ctx context.Context) *Vars {
+	v, _ := ctx.Value(varsKey{}).(*Vars)
+	return v
+}
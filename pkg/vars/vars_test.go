@@ -0,0 +1,27 @@
+package vars
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	values, err := Parse([]string{"region=us-east-1", "query=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"region": "us-east-1", "query": "a=b", "empty": ""}, values)
+
+	_, err = Parse([]string{"region"})
+	assert.Error(t, err)
+	_, err = Parse([]string{"1region=us"})
+	assert.Error(t, err)
+}
+
+func TestEnv(t *testing.T) {
+	v := New(map[string]string{"stage": "prod", "region": "us-east-1"})
+	assert.Equal(t, []string{"GPTSCRIPT_VAR_region=us-east-1", "GPTSCRIPT_VAR_stage=prod"}, v.Env())
+
+	// The variables of a context without a run are empty
+	assert.Empty(t, (*Vars)(nil).Env())
+}
@@ -0,0 +1,244 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gptscript-ai/gptscript/pkg/types"
+)
+
+const (
+	// DefaultModel is the model that the files and queries are embedded with when the index does not set one
+	DefaultModel = "text-embedding-3-small"
+	// ChunkSize is the size in bytes that the files are split into chunks of, at the end of lines
+	ChunkSize = 1500
+	// batchSize is the number of chunks that are embedded in one request to the model provider
+	batchSize = 64
+)
+
+// Embedder embeds texts as vectors, with the embeddings API of the provider of the model.
+type Embedder interface {
+	Embed(ctx context.Context, model string, input []string) ([][]float32, error)
+}
+
+type contextKey struct{}
+
+// WithContext returns a context that the builtins that index and search files embed their texts with.
+func WithContext(ctx context.Context, embedder Embedder) context.Context {
+	return context.WithValue(ctx, contextKey{}, embedder)
+}
+
+// FromContext returns the embedder of the context.
+func FromContext(ctx context.Context) (Embedder, bool) {
+	embedder, ok := ctx.Value(contextKey{}).(Embedder)
+	return embedder, ok && embedder != nil
+}
+
+// Chunk is a range of lines of a file, with the embedding of their text.
+type Chunk struct {
+	Path string `json:"path"`
+	// Start and End are the first and last lines of the chunk, starting from 1
+	Start  int       `json:"start"`
+	End    int       `json:"end"`
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+// Index is the embeddings of the chunks of a set of files, saved as a JSON file.
+type Index struct {
+	Model string `json:"model"`
+	// Files are the sha256 digests of the files that are indexed, by the path of the file
+	Files  map[string]string `json:"files"`
+	Chunks []Chunk           `json:"chunks"`
+}
+
+// Result is a chunk that matches a query, with the cosine similarity of their embeddings.
+type Result struct {
+	Chunk
+	Score float32 `json:"score"`
+}
+
+// Load reads the index in file, the index is empty if the file does not exist.
+func Load(file string) (*Index, error) {
+	index := &Index{
+		Files: map[string]string{},
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", file, err)
+	}
+	if index.Files == nil {
+		index.Files = map[string]string{}
+	}
+	return index, nil
+}
+
+// Save writes the index to file.
+func (i *Index) Save(file string) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Update indexes the files that are new or changed since they were indexed, and removes the files that no longer
+// exist from the index. Files that are not text are skipped. It returns the number of files that were indexed.
+func (i *Index) Update(ctx context.Context, embedder Embedder, model string, files []string) (int, error) {
+	model = types.FirstSet(model, i.Model, DefaultModel)
+	if model != i.Model {
+		// Vectors of different models can not be compared, so the files are all embedded again
+		i.Model, i.Files, i.Chunks = model, map[string]string{}, nil
+	}
+
+	var (
+		changed = map[string]bool{}
+		chunks  []Chunk
+	)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+		if !isText(data) {
+			continue
+		}
+		digest := sha256.Sum256(data)
+		if i.Files[file] == hex.EncodeToString(digest[:]) {
+			continue
+		}
+		changed[file] = true
+		i.Files[file] = hex.EncodeToString(digest[:])
+		chunks = append(chunks, split(file, string(data))...)
+	}
+
+	for start := 0; start < len(chunks); start += batchSize {
+		batch := chunks[start:min(start+batchSize, len(chunks))]
+		input := make([]string, len(batch))
+		for j, chunk := range batch {
+			input[j] = chunk.Text
+		}
+		vectors, err := embedder.Embed(ctx, model, input)
+		if err != nil {
+			return 0, err
+		}
+		if len(vectors) != len(batch) {
+			return 0, fmt.Errorf("model %s returned %d embeddings for %d texts", model, len(vectors), len(batch))
+		}
+		for j := range batch {
+			batch[j].Vector = vectors[j]
+		}
+	}
+
+	var kept []Chunk
+	for _, chunk := range i.Chunks {
+		if changed[chunk.Path] {
+			continue
+		}
+		if _, err := os.Stat(chunk.Path); errors.Is(err, fs.ErrNotExist) {
+			delete(i.Files, chunk.Path)
+			continue
+		}
+		kept = append(kept, chunk)
+	}
+	i.Chunks = append(kept, chunks...)
+
+	return len(changed), nil
+}
+
+// Search returns the count chunks whose embeddings are the most similar to the embedding of the query.
+func (i *Index) Search(ctx context.Context, embedder Embedder, query string, count int) ([]Result, error) {
+	if len(i.Chunks) == 0 {
+		return nil, nil
+	}
+
+	vectors, err := embedder.Embed(ctx, i.Model, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("model %s returned %d embeddings for the query", i.Model, len(vectors))
+	}
+
+	results := make([]Result, 0, len(i.Chunks))
+	for _, chunk := range i.Chunks {
+		results = append(results, Result{
+			Chunk: chunk,
+			Score: cosine(vectors[0], chunk.Vector),
+		})
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+	if count > 0 && len(results) > count {
+		results = results[:count]
+	}
+	return results, nil
+}
+
+// split splits the text of a file into chunks of about ChunkSize bytes, at the end of lines. Lines longer than
+// ChunkSize are chunks of their own.
+func split(path, text string) (result []Chunk) {
+	var (
+		lines = strings.SplitAfter(text, "\n")
+		chunk = Chunk{Path: path, Start: 1}
+		buf   strings.Builder
+	)
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		if buf.Len() > 0 && buf.Len()+len(line) > ChunkSize {
+			chunk.Text = buf.String()
+			result = append(result, chunk)
+			chunk = Chunk{Path: path, Start: n + 1}
+			buf.Reset()
+		}
+		buf.WriteString(line)
+		chunk.End = n + 1
+	}
+	if strings.TrimSpace(buf.String()) != "" {
+		chunk.Text = buf.String()
+		result = append(result, chunk)
+	}
+	return result
+}
+
+func isText(data []byte) bool {
+	return len(bytes.TrimSpace(data)) > 0 && !bytes.ContainsRune(data, 0) && utf8.Valid(data)
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, normA, normB float64
+	for i := 0; i < min(len(a), len(b)); i++ {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,94 @@
+package vector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEmbedder embeds texts as the counts of the words of vocabulary in them.
+type testEmbedder struct {
+	vocabulary []string
+	calls      int
+	texts      int
+}
+
+func (e *testEmbedder) Embed(_ context.Context, _ string, input []string) (result [][]float32, _ error) {
+	e.calls++
+	e.texts += len(input)
+	for _, text := range input {
+		vector := make([]float32, len(e.vocabulary))
+		for i, word := range e.vocabulary {
+			vector[i] = float32(strings.Count(strings.ToLower(text), word))
+		}
+		result = append(result, vector)
+	}
+	return result, nil
+}
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cats.md":  "Cats purr.\nA cat sleeps all day.\n",
+		"dogs.md":  "Dogs bark.\nA dog fetches the ball.\n",
+		"image.md": "\x00\x01",
+	}
+	var paths []string
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		paths = append(paths, filepath.Join(dir, name))
+	}
+
+	embedder := &testEmbedder{vocabulary: []string{"cat", "dog", "ball"}}
+	index, err := Load(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+
+	indexed, err := index.Update(context.Background(), embedder, "", paths)
+	require.NoError(t, err)
+	assert.Equal(t, 2, indexed)
+	assert.Equal(t, DefaultModel, index.Model)
+	assert.Len(t, index.Chunks, 2)
+	require.NoError(t, index.Save(filepath.Join(dir, "index.json")))
+
+	index, err = Load(filepath.Join(dir, "index.json"))
+	require.NoError(t, err)
+	results, err := index.Search(context.Background(), embedder, "where is the ball of the dog", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, filepath.Join(dir, "dogs.md"), results[0].Path)
+	assert.Equal(t, 1, results[0].Start)
+	assert.Equal(t, 2, results[0].End)
+
+	// Only the changed files are embedded again, and the removed files are dropped
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cats.md"), []byte("Cats again.\n"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "dogs.md")))
+	embedder.texts = 0
+	indexed, err = index.Update(context.Background(), embedder, "", []string{filepath.Join(dir, "cats.md")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, 1, embedder.texts)
+	require.Len(t, index.Chunks, 1)
+	assert.Equal(t, "Cats again.\n", index.Chunks[0].Text)
+	assert.Len(t, index.Files, 1)
+
+	// Another model embeds all the files again
+	indexed, err = index.Update(context.Background(), embedder, "other", []string{filepath.Join(dir, "cats.md")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, indexed)
+	assert.Equal(t, "other", index.Model)
+}
+
+func TestSplit(t *testing.T) {
+	line := strings.Repeat("x", ChunkSize/4) + "\n"
+	chunks := split("file", strings.Repeat(line, 5))
+	require.Len(t, chunks, 2)
+	assert.Equal(t, 1, chunks[0].Start)
+	assert.Equal(t, 3, chunks[0].End)
+	assert.Equal(t, 4, chunks[1].Start)
+	assert.Equal(t, 5, chunks[1].End)
+}